@@ -38,12 +38,14 @@ func main() {
 	}
 
 	signalmesenger := media.NewMessenger()
-	handler := media.NewMediaStateEventHandler(signalmesenger)
+	negotiator := media.NewNegotiator(signalmesenger)
+	handler := media.NewMediaStateEventHandler(signalmesenger, negotiator)
 
 	signalEndpoint, err := engine.NewSignalConnection(ctx, handler)
 	if err != nil {
 		panic(err)
 	}
+	negotiator.SetSender(signalEndpoint.PeerConnection)
 
 	signalOffer, err := signalEndpoint.GetLocalDescription(ctx)
 	if err != nil {
@@ -76,6 +78,7 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	negotiator.SetReceiver(receiveEndpoint.PeerConnection)
 	observer := newMediaObserver(receiveEndpoint, signalmesenger)
 	signalmesenger.Register(observer)
 
@@ -105,43 +108,36 @@ func (o *mediaObserver) OnMute(mute *message.Mute) {
 	panic("implement me")
 }
 
-func newMediaObserver(endpoint *rtp.Connection, messenger *media.Messenger) *mediaObserver {
-	return &mediaObserver{id: uuid.New(), endpoint: endpoint, messenger: messenger}
+func (o *mediaObserver) OnChat(chat *message.Chat) {
+	slog.Info("chat", "user", chat.User, "text", chat.Text)
 }
 
-func (o *mediaObserver) OnOffer(sdp *webrtc.SessionDescription, id uint32, number uint32) {
-	slog.Debug("############### Receive an Offer!", "offer")
-	println("Offer - x: ##########################################..")
-	println("Offer - x: ", &sdp)
-	if err := o.endpoint.PeerConnection.SetRemoteDescription(*sdp); err != nil {
-		slog.Error("set remote description", "err", err)
-		return
-	}
+func (o *mediaObserver) OnReaction(reaction *message.Reaction) {
+	slog.Info("reaction", "user", reaction.User, "emoji", reaction.Emoji)
+}
 
-	o.endpoint.GatherComplete = webrtc.GatheringCompletePromise(o.endpoint.PeerConnection)
-	answer, err := o.endpoint.PeerConnection.CreateAnswer(nil)
-	if err != nil {
-		slog.Error("create answer", "err", err)
-		return
-	}
+func (o *mediaObserver) OnRaiseHand(raiseHand *message.RaiseHand) {
+	slog.Info("raise hand", "user", raiseHand.User, "raised", raiseHand.Raised)
+}
 
-	if err = o.endpoint.PeerConnection.SetLocalDescription(answer); err != nil {
-		slog.Error("set answer", "err", err)
-		return
-	}
+func (o *mediaObserver) OnHistory(history *message.History) {
+	slog.Info("history", "chats", len(history.Chats), "raisedHands", len(history.RaisedHands))
+}
 
-	ldc, err := o.endpoint.GetLocalDescription(context.Background())
-	if err != nil {
-		slog.Error("get local dc", "err", err)
-		return
-	}
+func (o *mediaObserver) OnModeration(moderation *message.Moderation) {
+	slog.Info("moderation", "action", moderation.Action, "kind", moderation.Kind)
+}
 
-	_, err = o.messenger.SendSDP(ldc, id, number)
-	if err != nil {
-		slog.Error("send local dc", "err", err)
+func (o *mediaObserver) OnRole(role *message.Role) {
+	slog.Info("role", "role", role.Role)
+}
 
-	}
-	return
+func newMediaObserver(endpoint *rtp.Connection, messenger *media.Messenger) *mediaObserver {
+	return &mediaObserver{id: uuid.New(), endpoint: endpoint, messenger: messenger}
+}
+
+// OnOffer the negotiator answers the offers of the server
+func (o *mediaObserver) OnOffer(sdp *webrtc.SessionDescription, id uint32, number uint32) {
 }
 func (o *mediaObserver) OnAnswer(sdp *webrtc.SessionDescription, id uint32, number uint32) {
 }
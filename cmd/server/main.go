@@ -25,13 +25,11 @@ func main() {
 	conf, err := config.ParseConfig(*configArg, env)
 	if err != nil {
 		panic(fmt.Errorf("parsing config: %w", err))
-		return
 	}
 
 	log, err := logging.NewSlog(conf.LogConfig)
 	if err != nil {
 		panic(fmt.Errorf("setup logger: %w", err))
-		return
 	}
 
 	sigs := make(chan os.Signal, 1)
@@ -1,6 +1,7 @@
 package activitypub
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/gorilla/mux"
@@ -19,16 +20,18 @@ import (
 )
 
 type ApApi struct {
-	config       *instance.FederationConfig
-	Storage      storage.Storage
-	actorRepo    *models.ActorRepository
-	followRepo   *models.FollowRepository
-	videoRepo    *models.VideoRepository
-	actor        pub.FederatingActor
-	signer       *crypto.Signer
-	sender       *outbox.Sender
-	actorService *services.ActorService
-	videoService *services.VideoService
+	config        *instance.FederationConfig
+	Storage       storage.Storage
+	actorRepo     *models.ActorRepository
+	followRepo    *models.FollowRepository
+	videoRepo     *models.VideoRepository
+	outboxRepo    *models.OutboxRepository
+	actor         pub.FederatingActor
+	signer        *crypto.Signer
+	sender        *outbox.Sender
+	outboundQueue *workerpool.OutboundQueue
+	actorService  *services.ActorService
+	videoService  *services.VideoService
 }
 
 func NewApApi(
@@ -44,6 +47,8 @@ func NewApApi(
 	followRepo := models.NewFollowRepository(config, storage)
 	videoRepo := models.NewVideoRepository(config, storage)
 	instanceRepo := models.NewInstanceRepository(config, storage)
+	outboxRepo := models.NewOutboxRepository(config, storage)
+	deliveryRepo := models.NewDeliveryRepository(config, storage)
 
 	// @TODO this is a skeleton, please use this as blueprint to clean up the source
 	// @TODO currently we follow the implementation from Owncast, which is little tricky but was faster to implement
@@ -60,38 +65,50 @@ func NewApApi(
 
 	resolver := remote.NewResolver(config, signer)
 
-	sender := outbox.NewSender(config, webfingerClient, resolver, signer)
+	outboundQueue := workerpool.NewOutboundQueue(deliveryRepo, signer, config.Release)
+
+	sender := outbox.NewSender(config, webfingerClient, resolver, signer, outboundQueue)
 
 	actorService := services.NewActorService(config, actorRepo, sender)
 
 	instService := services.NewInstanceService(config, instanceRepo)
 
-	videoService := services.NewVideoService(config, actorService, videoRepo, streamService, instService)
+	announcer := outbox.NewAnnouncer(config, followRepo, outboxRepo, sender)
+
+	videoService := services.NewVideoService(config, actorService, videoRepo, streamService, instService, announcer)
 
 	return &ApApi{
-		config:       config,
-		Storage:      storage,
-		actorRepo:    actorRepo,
-		followRepo:   followRepo,
-		videoRepo:    videoRepo,
-		actor:        actor,
-		signer:       signer,
-		sender:       sender,
-		actorService: actorService,
-		videoService: videoService,
+		config:        config,
+		Storage:       storage,
+		actorRepo:     actorRepo,
+		followRepo:    followRepo,
+		videoRepo:     videoRepo,
+		outboxRepo:    outboxRepo,
+		actor:         actor,
+		signer:        signer,
+		sender:        sender,
+		outboundQueue: outboundQueue,
+		actorService:  actorService,
+		videoService:  videoService,
 	}, nil
 
 }
 
-func (a *ApApi) BoostrapApi(router *mux.Router) error {
-	if err := extendRouter(router, a.config, a.actorRepo, a.followRepo, a.signer, a.sender, a.actorService); err != nil {
+// GetVideoService returns the service, which tells the followers of this instance about its live streams
+func (a *ApApi) GetVideoService() *services.VideoService {
+	return a.videoService
+}
+
+// BoostrapApi adds the federation endpoints to the router and starts the queues, they stop when the context is done.
+func (a *ApApi) BoostrapApi(ctx context.Context, router *mux.Router) error {
+	if err := extendRouter(router, a.config, a.actorRepo, a.followRepo, a.outboxRepo, a.signer, a.sender, a.actorService); err != nil {
 		return fmt.Errorf("extending router with federation endpoints: %w", err)
 	}
 
 	if a.config.Enable {
 		resolver := remote.NewResolver(a.config, a.signer)
-		workerpool.InitOutboundWorkerPool()
-		inbox.InitInboxWorkerPool(a.followRepo, a.videoService, resolver)
+		a.outboundQueue.Start(ctx)
+		inbox.InitInboxWorkerPool(a.followRepo, a.videoService, a.actorService, a.sender, resolver)
 	}
 
 	return nil
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/shigde/sfu/internal/activitypub/crypto"
+	"github.com/shigde/sfu/internal/activitypub/instance"
+	"github.com/shigde/sfu/internal/activitypub/models"
+	"github.com/shigde/sfu/internal/activitypub/request"
+	"github.com/superseriousbusiness/activity/streams"
+	"github.com/superseriousbusiness/activity/streams/vocab"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	collectionPageSize = 50
+)
+
+// countItems returns the number of items in the collection of an actor
+type countItems func(ctx context.Context, actor *models.Actor) (int64, error)
+
+// appendItems appends a page of the collection of an actor to the ordered items
+type appendItems func(ctx context.Context, actor *models.Actor, limit int, offset int, items vocab.ActivityStreamsOrderedItemsProperty) error
+
+// getCollectionHandler serves a paged OrderedCollection of a local actor.
+// Without the "page" parameter it returns the collection with the link to the first page,
+// with the parameter it returns the OrderedCollectionPage. In private federation mode only the total count is public.
+func getCollectionHandler(
+	config *instance.FederationConfig,
+	actorRep *models.ActorRepository,
+	signer *crypto.Signer,
+	collectionIri func(actorIri *url.URL) *url.URL,
+	count countItems,
+	appendPage appendItems,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !config.Enable {
+			http.Error(w, errNoFederationSupport.Error(), http.StatusMethodNotAllowed)
+			return
+		}
+
+		accountName, err := getAccountName(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// only local actors have collections, remote actors can have the same name
+		actor, err := actorRep.GetActorByActorIRI(r.Context(), instance.BuildAccountIri(config.InstanceUrl, accountName))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		page, err := getPage(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		total, err := count(r.Context(), actor)
+		if err != nil {
+			slog.Error("handler: counting collection items", "err", err, "path", r.URL.Path)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		iri := collectionIri(actor.GetActorIri())
+		var response vocab.Type
+		switch {
+		case page == 0:
+			response = buildOrderedCollection(iri, total, !config.IsPrivate)
+		case config.IsPrivate:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		default:
+			collectionPage, err := buildOrderedCollectionPage(iri, page, total, func(items vocab.ActivityStreamsOrderedItemsProperty) error {
+				return appendPage(r.Context(), actor, collectionPageSize, (page-1)*collectionPageSize, items)
+			})
+			if err != nil {
+				slog.Error("handler: building collection page", "err", err, "path", r.URL.Path)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			response = collectionPage
+		}
+
+		publicKey := crypto.GetPublicKey(actor.GetActorIri(), actor.PublicKey)
+		privateKey := crypto.GetPrivateKey(actor.PrivateKey.String)
+
+		if err := request.NewSignedResponse(signer).WriteStreamResponse(response, w, publicKey, privateKey); err != nil {
+			slog.Error("unable to write stream response for collection handler", "err", err, "path", r.URL.Path)
+		}
+	}
+}
+
+func buildOrderedCollection(collectionIri *url.URL, total int64, withPages bool) vocab.ActivityStreamsOrderedCollection {
+	collection := streams.NewActivityStreamsOrderedCollection()
+
+	idProperty := streams.NewJSONLDIdProperty()
+	idProperty.SetIRI(collectionIri)
+	collection.SetJSONLDId(idProperty)
+
+	totalItemsProperty := streams.NewActivityStreamsTotalItemsProperty()
+	totalItemsProperty.Set(int(total))
+	collection.SetActivityStreamsTotalItems(totalItemsProperty)
+
+	if withPages {
+		first := streams.NewActivityStreamsFirstProperty()
+		first.SetIRI(buildPageIri(collectionIri, 1))
+		collection.SetActivityStreamsFirst(first)
+	}
+
+	return collection
+}
+
+func buildOrderedCollectionPage(collectionIri *url.URL, page int, total int64, appendPage func(items vocab.ActivityStreamsOrderedItemsProperty) error) (vocab.ActivityStreamsOrderedCollectionPage, error) {
+	collectionPage := streams.NewActivityStreamsOrderedCollectionPage()
+
+	idProperty := streams.NewJSONLDIdProperty()
+	idProperty.SetIRI(buildPageIri(collectionIri, page))
+	collectionPage.SetJSONLDId(idProperty)
+
+	partOf := streams.NewActivityStreamsPartOfProperty()
+	partOf.SetIRI(collectionIri)
+	collectionPage.SetActivityStreamsPartOf(partOf)
+
+	totalItemsProperty := streams.NewActivityStreamsTotalItemsProperty()
+	totalItemsProperty.Set(int(total))
+	collectionPage.SetActivityStreamsTotalItems(totalItemsProperty)
+
+	orderedItems := streams.NewActivityStreamsOrderedItemsProperty()
+	if err := appendPage(orderedItems); err != nil {
+		return nil, fmt.Errorf("appending collection items: %w", err)
+	}
+	collectionPage.SetActivityStreamsOrderedItems(orderedItems)
+
+	if page > 1 {
+		prev := streams.NewActivityStreamsPrevProperty()
+		prev.SetIRI(buildPageIri(collectionIri, page-1))
+		collectionPage.SetActivityStreamsPrev(prev)
+	}
+
+	if int64(page*collectionPageSize) < total {
+		next := streams.NewActivityStreamsNextProperty()
+		next.SetIRI(buildPageIri(collectionIri, page+1))
+		collectionPage.SetActivityStreamsNext(next)
+	}
+
+	return collectionPage, nil
+}
+
+func buildPageIri(collectionIri *url.URL, page int) *url.URL {
+	pageIri := *collectionIri
+	pageIri.RawQuery = url.Values{"page": []string{strconv.Itoa(page)}}.Encode()
+	return &pageIri
+}
+
+// getPage returns the requested page, 0 means the collection itself
+func getPage(r *http.Request) (int, error) {
+	pageParam := r.URL.Query().Get("page")
+	if len(pageParam) == 0 {
+		return 0, nil
+	}
+	page, err := strconv.Atoi(pageParam)
+	if err != nil || page < 1 {
+		return 0, errInvalidPage
+	}
+	return page, nil
+}
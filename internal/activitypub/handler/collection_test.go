@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shigde/sfu/internal/activitypub/crypto"
+	"github.com/shigde/sfu/internal/activitypub/instance"
+	"github.com/shigde/sfu/internal/activitypub/models"
+	"github.com/shigde/sfu/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// testCollectionSetup stores the local instance actor with followers, one followed actor and one published activity
+func testCollectionSetup(t *testing.T, followers int) (*mux.Router, *instance.FederationConfig) {
+	t.Helper()
+	store := storage.NewTestStore()
+	_ = store.GetDatabase().AutoMigrate(&models.Actor{}, &models.Follow{}, &models.OutboxActivity{})
+
+	instanceUrl, _ := url.Parse("http://localhost:8080")
+	config := &instance.FederationConfig{Enable: true, InstanceUsername: "shig", InstanceUrl: instanceUrl}
+	actorRepo := models.NewActorRepository(config, store)
+	followRepo := models.NewFollowRepository(config, store)
+	outboxRepo := models.NewOutboxRepository(config, store)
+	ctx := context.Background()
+
+	local, _ := models.NewInstanceActor(instanceUrl, "shig")
+	local, _ = actorRepo.Upsert(ctx, local)
+	remoteUrl, _ := url.Parse("http://remote.localhost")
+	for i := 0; i < followers; i++ {
+		follower, _ := models.NewTrustedInstanceActor(instance.BuildAccountIri(remoteUrl, fmt.Sprintf("follower-%d", i)), "shig")
+		follower, _ = actorRepo.Upsert(ctx, follower)
+		_, _ = followRepo.Add(ctx, models.NewRemoteFollow(instance.BuildFollowActivityIri(remoteUrl), follower, local))
+	}
+	followed, _ := models.NewTrustedInstanceActor(instance.BuildAccountIri(remoteUrl, "followed"), "shig")
+	followed, _ = actorRepo.Upsert(ctx, followed)
+	follow := models.NewFollow(local, followed, config)
+	follow.State = models.Accepted.String()
+	_, _ = followRepo.Add(ctx, follow)
+
+	announce := models.MakeAnnounceActivity(instance.BuildAnnounceActivityIri(instanceUrl))
+	activity, _ := models.NewOutboxActivity(local, announce)
+	_, _ = outboxRepo.Add(ctx, activity)
+
+	signer := crypto.NewSigner(actorRepo)
+	router := mux.NewRouter()
+	router.HandleFunc("/federation/accounts/{accountName}/outbox", GetOutboxHandler(config, actorRepo, outboxRepo, signer)).Methods("GET")
+	router.HandleFunc("/federation/accounts/{accountName}/followers", GetFollowersHandler(config, actorRepo, followRepo, signer)).Methods("GET")
+	router.HandleFunc("/federation/accounts/{accountName}/following", GetFollowingHandler(config, actorRepo, followRepo, signer)).Methods("GET")
+	return router, config
+}
+
+func testGetCollection(t *testing.T, router *mux.Router, target string) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var body map[string]interface{}
+	_ = json.Unmarshal(rr.Body.Bytes(), &body)
+	return rr.Code, body
+}
+
+func TestCollectionHandler(t *testing.T) {
+	t.Run("get followers collection", func(t *testing.T) {
+		router, _ := testCollectionSetup(t, 2)
+
+		code, body := testGetCollection(t, router, "/federation/accounts/shig/followers")
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "OrderedCollection", body["type"])
+		assert.Equal(t, "http://localhost:8080/federation/accounts/shig/followers", body["id"])
+		assert.Equal(t, float64(2), body["totalItems"])
+		assert.Equal(t, "http://localhost:8080/federation/accounts/shig/followers?page=1", body["first"])
+	})
+
+	t.Run("get followers pages", func(t *testing.T) {
+		router, _ := testCollectionSetup(t, collectionPageSize+1)
+
+		code, body := testGetCollection(t, router, "/federation/accounts/shig/followers?page=1")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "OrderedCollectionPage", body["type"])
+		assert.Equal(t, "http://localhost:8080/federation/accounts/shig/followers", body["partOf"])
+		assert.Len(t, body["orderedItems"], collectionPageSize)
+		assert.Equal(t, "http://localhost:8080/federation/accounts/shig/followers?page=2", body["next"])
+		assert.Nil(t, body["prev"])
+
+		code, body = testGetCollection(t, router, "/federation/accounts/shig/followers?page=2")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, fmt.Sprintf("http://remote.localhost/federation/accounts/follower-%d", collectionPageSize), body["orderedItems"])
+		assert.Equal(t, "http://localhost:8080/federation/accounts/shig/followers?page=1", body["prev"])
+		assert.Nil(t, body["next"])
+	})
+
+	t.Run("get following page", func(t *testing.T) {
+		router, _ := testCollectionSetup(t, 1)
+
+		code, body := testGetCollection(t, router, "/federation/accounts/shig/following?page=1")
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, float64(1), body["totalItems"])
+		assert.Equal(t, "http://remote.localhost/federation/accounts/followed", body["orderedItems"])
+	})
+
+	t.Run("get outbox page", func(t *testing.T) {
+		router, _ := testCollectionSetup(t, 1)
+
+		code, body := testGetCollection(t, router, "/federation/accounts/shig/outbox?page=1")
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, float64(1), body["totalItems"])
+		activity, ok := body["orderedItems"].(map[string]interface{})
+		assert.True(t, ok)
+		assert.Equal(t, "Announce", activity["type"])
+	})
+
+	t.Run("hide pages in private mode", func(t *testing.T) {
+		router, config := testCollectionSetup(t, 1)
+		config.IsPrivate = true
+
+		code, body := testGetCollection(t, router, "/federation/accounts/shig/followers")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, float64(1), body["totalItems"])
+		assert.Nil(t, body["first"])
+
+		code, _ = testGetCollection(t, router, "/federation/accounts/shig/followers?page=1")
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+
+	t.Run("reject invalid page", func(t *testing.T) {
+		router, _ := testCollectionSetup(t, 1)
+
+		code, _ := testGetCollection(t, router, "/federation/accounts/shig/followers?page=0")
+
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("collections only for local actors", func(t *testing.T) {
+		router, _ := testCollectionSetup(t, 1)
+
+		code, _ := testGetCollection(t, router, "/federation/accounts/unknown/followers")
+
+		assert.Equal(t, http.StatusNotFound, code)
+	})
+}
@@ -7,4 +7,5 @@ var (
 	errAccountIriNotFound  = errors.New("no 'accountIri' get parameter in request")
 	errAccountIriInvalid   = errors.New("invalid 'accountIri'")
 	errNoFederationSupport = errors.New("no federation support")
+	errInvalidPage         = errors.New("invalid 'page'")
 )
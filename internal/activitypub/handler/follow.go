@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/shigde/sfu/internal/activitypub/crypto"
+	"github.com/shigde/sfu/internal/activitypub/instance"
+	"github.com/shigde/sfu/internal/activitypub/models"
+	"github.com/superseriousbusiness/activity/streams/vocab"
+)
+
+// GetFollowersHandler handles requests for the followers of a local actor.
+func GetFollowersHandler(
+	config *instance.FederationConfig,
+	actorRep *models.ActorRepository,
+	followRep *models.FollowRepository,
+	signer *crypto.Signer,
+) http.HandlerFunc {
+	return getCollectionHandler(config, actorRep, signer, instance.BuildFollowersIri,
+		func(ctx context.Context, actor *models.Actor) (int64, error) {
+			return followRep.CountActorFollowers(ctx, actor.ID)
+		},
+		func(ctx context.Context, actor *models.Actor, limit int, offset int, items vocab.ActivityStreamsOrderedItemsProperty) error {
+			follows, err := followRep.GetActorFollowersPage(ctx, actor.ID, limit, offset)
+			if err != nil {
+				return fmt.Errorf("getting followers: %w", err)
+			}
+			for _, follow := range follows {
+				items.AppendIRI(follow.Actor.GetActorIri())
+			}
+			return nil
+		},
+	)
+}
+
+// GetFollowingHandler handles requests for the actors a local actor follows.
+func GetFollowingHandler(
+	config *instance.FederationConfig,
+	actorRep *models.ActorRepository,
+	followRep *models.FollowRepository,
+	signer *crypto.Signer,
+) http.HandlerFunc {
+	return getCollectionHandler(config, actorRep, signer, instance.BuildFollowingIri,
+		func(ctx context.Context, actor *models.Actor) (int64, error) {
+			return followRep.CountActorFollowing(ctx, actor.ID)
+		},
+		func(ctx context.Context, actor *models.Actor, limit int, offset int, items vocab.ActivityStreamsOrderedItemsProperty) error {
+			follows, err := followRep.GetActorFollowingPage(ctx, actor.ID, limit, offset)
+			if err != nil {
+				return fmt.Errorf("getting following: %w", err)
+			}
+			for _, follow := range follows {
+				items.AppendIRI(follow.TargetActor.GetActorIri())
+			}
+			return nil
+		},
+	)
+}
@@ -0,0 +1,89 @@
+package inbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/shigde/sfu/internal/activitypub/models"
+	"github.com/shigde/sfu/internal/activitypub/outbox"
+	"github.com/shigde/sfu/internal/activitypub/parser"
+	"github.com/shigde/sfu/internal/activitypub/services"
+	"github.com/superseriousbusiness/activity/streams/vocab"
+	"golang.org/x/exp/slog"
+)
+
+type followInbox struct {
+	followStore  *models.FollowRepository
+	actorService *services.ActorService
+	sender       *outbox.Sender
+}
+
+func newFollowInbox(followStore *models.FollowRepository, actorService *services.ActorService, sender *outbox.Sender) *followInbox {
+	return &followInbox{followStore: followStore, actorService: actorService, sender: sender}
+}
+
+/*
+	{
+		"type": "Follow",
+		"id": "http://localhost:8090/federation/follow/4f0d2a8e-5b4c-4a4e-9a57-0a3c1f1e2b7d",
+		"actor": "http://localhost:8090/federation/accounts/shig",
+		"object": "http://localhost:8080/federation/accounts/shig"
+	}
+*/
+func (fi *followInbox) handleFollowRequest(ctx context.Context, activity vocab.ActivityStreamsFollow) error {
+	slog.Debug("inbox follow: receive follow activity", "activity", activity)
+
+	idProp := activity.GetJSONLDId()
+	if idProp == nil || !idProp.IsIRI() {
+		return errors.New("inbox follow: no id property set on follow, or was not an iri")
+	}
+	followIri := idProp.GetIRI()
+
+	actorIri, err := parser.ExtractActorURI(activity)
+	if err != nil {
+		return fmt.Errorf("inbox follow: extracting actor: %w", err)
+	}
+
+	targetIri, err := parser.ExtractObjectURI(activity)
+	if err != nil {
+		return fmt.Errorf("inbox follow: extracting target actor: %w", err)
+	}
+
+	instAct, err := fi.actorService.GetLocalInstanceActor(ctx)
+	if err != nil {
+		return fmt.Errorf("inbox follow: getting local instance actor: %w", err)
+	}
+
+	if targetIri.String() != instAct.ActorIri {
+		return fmt.Errorf("inbox follow: only the instance actor can be followed, not %s", targetIri.String())
+	}
+
+	// a repeated follow request was not accepted by the remote instance, so it gets the accept once again
+	follow, err := fi.followStore.GetFollowByIri(ctx, followIri.String())
+	if err != nil && !errors.Is(err, models.ErrActorFollowNotFound) {
+		return fmt.Errorf("inbox follow: getting follow request with id %s from the database: %w", followIri.String(), err)
+	}
+
+	if follow == nil {
+		follower, err := fi.actorService.CreateActorFromRemoteAccount(ctx, actorIri.String(), instAct)
+		if err != nil {
+			return fmt.Errorf("inbox follow: getting remote account as actor: %w", err)
+		}
+
+		follow, err = fi.followStore.Add(ctx, models.NewRemoteFollow(followIri, follower, instAct))
+		if err != nil {
+			return fmt.Errorf("inbox follow: saving follow request with id %s: %w", followIri.String(), err)
+		}
+	}
+
+	if follow.Actor.ActorIri != actorIri.String() {
+		return errors.New("inbox follow: follow actor and known follow actor were not the same")
+	}
+
+	if err := fi.sender.SendAccept(follow); err != nil {
+		return fmt.Errorf("inbox follow: sending accept: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package inbox
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/shigde/sfu/internal/activitypub/crypto"
+	"github.com/shigde/sfu/internal/activitypub/instance"
+	"github.com/shigde/sfu/internal/activitypub/models"
+	"github.com/shigde/sfu/internal/activitypub/outbox"
+	"github.com/shigde/sfu/internal/activitypub/services"
+	"github.com/shigde/sfu/internal/activitypub/workerpool"
+	"github.com/shigde/sfu/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+type testInbox struct {
+	config       *instance.FederationConfig
+	actorRepo    *models.ActorRepository
+	followRepo   *models.FollowRepository
+	deliveryRepo *models.DeliveryRepository
+	actorService *services.ActorService
+	sender       *outbox.Sender
+	local        *models.Actor
+}
+
+// testInboxSetup stores the local instance actor, the outbound queue of the sender only stores the deliveries
+func testInboxSetup(t *testing.T) *testInbox {
+	t.Helper()
+	store := storage.NewTestStore()
+	_ = store.GetDatabase().AutoMigrate(&models.Actor{}, &models.Follow{}, &models.Delivery{})
+
+	instanceUrl, _ := url.Parse("http://localhost:8080")
+	config := &instance.FederationConfig{Enable: true, InstanceUsername: "shig", InstanceUrl: instanceUrl}
+	actorRepo := models.NewActorRepository(config, store)
+	deliveryRepo := models.NewDeliveryRepository(config, store)
+	local, _ := models.NewInstanceActor(instanceUrl, "shig")
+	local, _ = actorRepo.Upsert(context.Background(), local)
+	signer := crypto.NewSigner(actorRepo)
+	sender := outbox.NewSender(config, nil, nil, signer, workerpool.NewOutboundQueue(deliveryRepo, signer, "test"))
+
+	return &testInbox{
+		config:       config,
+		actorRepo:    actorRepo,
+		followRepo:   models.NewFollowRepository(config, store),
+		deliveryRepo: deliveryRepo,
+		actorService: services.NewActorService(config, actorRepo, sender),
+		sender:       sender,
+		local:        local,
+	}
+}
+
+var testRemoteUrl, _ = url.Parse("http://remote.localhost")
+
+func testStoredFollow(t *testing.T, inbox *testInbox) *models.Follow {
+	t.Helper()
+	follower, _ := models.NewTrustedInstanceActor(instance.BuildAccountIri(testRemoteUrl, "shig"), "shig")
+	follower, _ = inbox.actorRepo.Upsert(context.Background(), follower)
+	follow, err := inbox.followRepo.Add(context.Background(), models.NewRemoteFollow(instance.BuildFollowActivityIri(testRemoteUrl), follower, inbox.local))
+	assert.NoError(t, err)
+	return follow
+}
+
+// testRemoteInstance serves the instance actor of a remote instance, the follow inbox fetches unknown followers
+func testRemoteInstance(t *testing.T, config *instance.FederationConfig) (*models.Actor, *url.URL) {
+	t.Helper()
+	var remote *models.Actor
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := models.Serialize(models.BuildActivityApplication(remote, config))
+		w.Header().Set("Content-Type", "application/activity+json")
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+
+	remoteUrl, _ := url.Parse(server.URL)
+	remote, _ = models.NewInstanceActor(remoteUrl, "remote")
+	return remote, remoteUrl
+}
+
+func testPendingActivity(t *testing.T, deliveryRepo *models.DeliveryRepository) (*models.Delivery, map[string]interface{}) {
+	t.Helper()
+	pending, err := deliveryRepo.GetPending(context.Background(), 10)
+	assert.NoError(t, err)
+	if !assert.Len(t, pending, 1) {
+		t.FailNow()
+	}
+	var activity map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(pending[0].Payload), &activity))
+	return pending[0], activity
+}
+
+func TestFollowInbox(t *testing.T) {
+	t.Run("store the follow of a new follower and accept it", func(t *testing.T) {
+		inbox := testInboxSetup(t)
+		remote, remoteUrl := testRemoteInstance(t, inbox.config)
+		followIri := instance.BuildFollowActivityIri(remoteUrl)
+		activity, _ := models.NewRemoteFollow(followIri, remote, inbox.local).ToAS()
+
+		err := newFollowInbox(inbox.followRepo, inbox.actorService, inbox.sender).handleFollowRequest(context.Background(), activity)
+
+		assert.NoError(t, err)
+		follow, err := inbox.followRepo.GetFollowByIri(context.Background(), followIri.String())
+		assert.NoError(t, err)
+		assert.Equal(t, remote.ActorIri, follow.Actor.ActorIri)
+		assert.Equal(t, inbox.local.ActorIri, follow.TargetActor.ActorIri)
+		assert.Equal(t, models.Accepted.String(), follow.State)
+
+		delivery, accept := testPendingActivity(t, inbox.deliveryRepo)
+		assert.Equal(t, remote.InboxIri, delivery.InboxIri)
+		assert.Equal(t, "Accept", accept["type"])
+		assert.Equal(t, inbox.local.ActorIri, accept["actor"])
+	})
+
+	t.Run("accept a repeated follow again", func(t *testing.T) {
+		inbox := testInboxSetup(t)
+		follow := testStoredFollow(t, inbox)
+		activity, _ := follow.ToAS()
+
+		// the follower is known, so it is not fetched again
+		err := newFollowInbox(inbox.followRepo, inbox.actorService, inbox.sender).handleFollowRequest(context.Background(), activity)
+
+		assert.NoError(t, err)
+		delivery, accept := testPendingActivity(t, inbox.deliveryRepo)
+		assert.Equal(t, follow.Actor.InboxIri, delivery.InboxIri)
+		assert.Equal(t, "Accept", accept["type"])
+	})
+
+	t.Run("reject the follow of another actor than the instance actor", func(t *testing.T) {
+		inbox := testInboxSetup(t)
+		follower, _ := models.NewTrustedInstanceActor(instance.BuildAccountIri(testRemoteUrl, "shig"), "shig")
+		other, _ := models.NewTrustedInstanceActor(instance.BuildAccountIri(inbox.config.InstanceUrl, "other"), "other")
+		activity, _ := models.NewRemoteFollow(instance.BuildFollowActivityIri(testRemoteUrl), follower, other).ToAS()
+
+		err := newFollowInbox(inbox.followRepo, inbox.actorService, inbox.sender).handleFollowRequest(context.Background(), activity)
+
+		assert.Error(t, err)
+		pending, _ := inbox.deliveryRepo.GetPending(context.Background(), 10)
+		assert.Empty(t, pending)
+	})
+}
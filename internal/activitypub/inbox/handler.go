@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/shigde/sfu/internal/activitypub/models"
+	"github.com/shigde/sfu/internal/activitypub/outbox"
 	"github.com/shigde/sfu/internal/activitypub/remote"
 	"github.com/shigde/sfu/internal/activitypub/services"
 )
@@ -15,11 +16,15 @@ type handler struct {
 	announceInbox *announceInbox
 	updateInbox   *updateInbox
 	deleteInbox   *deleteInbox
+	followInbox   *followInbox
+	undoInbox     *undoInbox
 }
 
 func newHandler(
 	followRep *models.FollowRepository,
 	videoService *services.VideoService,
+	actorService *services.ActorService,
+	sender *outbox.Sender,
 	resolver *remote.Resolver,
 ) *handler {
 	return &handler{
@@ -28,6 +33,8 @@ func newHandler(
 		announceInbox: newAnnounceInbox(videoService),
 		updateInbox:   newUpdateInbox(videoService),
 		deleteInbox:   newDeleteInbox(videoService),
+		followInbox:   newFollowInbox(followRep, actorService, sender),
+		undoInbox:     newUndoInbox(followRep),
 	}
 }
 
@@ -37,6 +44,8 @@ func (h *handler) resolve(ctx context.Context, request InboxRequest) error {
 		h.updateInbox.handleUpdateRequest,
 		h.deleteInbox.handleDeleteRequest,
 		h.acceptInbox.handleAcceptRequest,
+		h.followInbox.handleFollowRequest,
+		h.undoInbox.handleUndoRequest,
 	); err != nil {
 		return fmt.Errorf("handel resolve: %w", err)
 	}
@@ -0,0 +1,90 @@
+package inbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/shigde/sfu/internal/activitypub/models"
+	"github.com/shigde/sfu/internal/activitypub/parser"
+	"github.com/superseriousbusiness/activity/streams/vocab"
+	"golang.org/x/exp/slog"
+)
+
+type undoInbox struct {
+	followStore *models.FollowRepository
+}
+
+func newUndoInbox(followStore *models.FollowRepository) *undoInbox {
+	return &undoInbox{followStore: followStore}
+}
+
+/*
+	{
+		"type": "Undo",
+		"id": "http://localhost:8090/federation/follow/4f0d2a8e-5b4c-4a4e-9a57-0a3c1f1e2b7d/undo",
+		"actor": "http://localhost:8090/federation/accounts/shig",
+		"object": {
+			"type": "Follow",
+			"id": "http://localhost:8090/federation/follow/4f0d2a8e-5b4c-4a4e-9a57-0a3c1f1e2b7d",
+			"actor": "http://localhost:8090/federation/accounts/shig",
+			"object": "http://localhost:8080/federation/accounts/shig"
+		}
+	}
+*/
+func (ui *undoInbox) handleUndoRequest(ctx context.Context, activity vocab.ActivityStreamsUndo) error {
+	slog.Debug("inbox undo: receive undo activity", "activity", activity)
+
+	undoObject := activity.GetActivityStreamsObject()
+	if undoObject == nil {
+		return errors.New("inbox undo: no object set on vocab.ActivityStreamsUndo")
+	}
+
+	actorIri, err := parser.ExtractActorURI(activity)
+	if err != nil {
+		return fmt.Errorf("inbox undo: extracting actor: %w", err)
+	}
+
+	for iter := undoObject.Begin(); iter != undoObject.End(); iter = iter.Next() {
+		// We only care about undoing follows.
+		if iter.IsIRI() {
+			if err := ui.deleteFollow(ctx, iter.GetIRI(), actorIri); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !iter.IsActivityStreamsFollow() {
+			continue
+		}
+		idProp := iter.GetActivityStreamsFollow().GetJSONLDId()
+		if idProp == nil || !idProp.IsIRI() {
+			return errors.New("inbox undo: no id property set on follow, or was not an iri")
+		}
+		if err := ui.deleteFollow(ctx, idProp.GetIRI(), actorIri); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ui *undoInbox) deleteFollow(ctx context.Context, followIri *url.URL, actorIri *url.URL) error {
+	follow, err := ui.followStore.GetFollowByIri(ctx, followIri.String())
+	if errors.Is(err, models.ErrActorFollowNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("inbox undo: getting follow request with id %s from the database: %w", followIri.String(), err)
+	}
+
+	if follow.Actor.ActorIri != actorIri.String() {
+		return errors.New("inbox undo: only the follower can undo a follow")
+	}
+
+	if err := ui.followStore.Delete(ctx, follow); err != nil {
+		return fmt.Errorf("inbox undo: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package inbox
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/shigde/sfu/internal/activitypub/instance"
+	"github.com/shigde/sfu/internal/activitypub/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/superseriousbusiness/activity/streams"
+	"github.com/superseriousbusiness/activity/streams/vocab"
+)
+
+func testUndoActivity(t *testing.T, actorIri *url.URL, follow *models.Follow) vocab.ActivityStreamsUndo {
+	t.Helper()
+	undo := streams.NewActivityStreamsUndo()
+	actor := streams.NewActivityStreamsActorProperty()
+	actor.AppendIRI(actorIri)
+	undo.SetActivityStreamsActor(actor)
+
+	activity, err := follow.ToAS()
+	assert.NoError(t, err)
+	object := streams.NewActivityStreamsObjectProperty()
+	object.AppendActivityStreamsFollow(activity)
+	undo.SetActivityStreamsObject(object)
+	return undo
+}
+
+func TestUndoInbox(t *testing.T) {
+	t.Run("delete the follow of the follower", func(t *testing.T) {
+		inbox := testInboxSetup(t)
+		follow := testStoredFollow(t, inbox)
+
+		err := newUndoInbox(inbox.followRepo).handleUndoRequest(context.Background(), testUndoActivity(t, follow.Actor.GetActorIri(), follow))
+
+		assert.NoError(t, err)
+		_, err = inbox.followRepo.GetFollowByIri(context.Background(), follow.Iri)
+		assert.ErrorIs(t, err, models.ErrActorFollowNotFound)
+	})
+
+	t.Run("reject the undo of another actor than the follower", func(t *testing.T) {
+		inbox := testInboxSetup(t)
+		follow := testStoredFollow(t, inbox)
+		other := instance.BuildAccountIri(testRemoteUrl, "other")
+
+		err := newUndoInbox(inbox.followRepo).handleUndoRequest(context.Background(), testUndoActivity(t, other, follow))
+
+		assert.Error(t, err)
+		_, err = inbox.followRepo.GetFollowByIri(context.Background(), follow.Iri)
+		assert.NoError(t, err)
+	})
+
+	t.Run("ignore the undo of an unknown follow", func(t *testing.T) {
+		inbox := testInboxSetup(t)
+		follow := testStoredFollow(t, inbox)
+		unknown := models.NewRemoteFollow(instance.BuildFollowActivityIri(testRemoteUrl), follow.Actor, inbox.local)
+
+		err := newUndoInbox(inbox.followRepo).handleUndoRequest(context.Background(), testUndoActivity(t, follow.Actor.GetActorIri(), unknown))
+
+		assert.NoError(t, err)
+		_, err = inbox.followRepo.GetFollowByIri(context.Background(), follow.Iri)
+		assert.NoError(t, err)
+	})
+}
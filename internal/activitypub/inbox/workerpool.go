@@ -4,6 +4,7 @@ import (
 	"runtime"
 
 	"github.com/shigde/sfu/internal/activitypub/models"
+	"github.com/shigde/sfu/internal/activitypub/outbox"
 	"github.com/shigde/sfu/internal/activitypub/remote"
 	"github.com/shigde/sfu/internal/activitypub/services"
 	"golang.org/x/exp/slog"
@@ -23,11 +24,13 @@ var queue chan Job
 func InitInboxWorkerPool(
 	followRep *models.FollowRepository,
 	videoService *services.VideoService,
+	actorService *services.ActorService,
+	sender *outbox.Sender,
 	resolver *remote.Resolver,
 ) {
 	queue = make(chan Job)
 
-	handler := newHandler(followRep, videoService, resolver)
+	handler := newHandler(followRep, videoService, actorService, sender, resolver)
 	// start workers
 	for i := 1; i <= workerPoolSize; i++ {
 		go worker(i, queue, handler)
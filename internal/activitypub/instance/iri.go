@@ -55,6 +55,26 @@ func BuildFollowActivityIri(instanceUrl *url.URL) *url.URL {
 	return iri
 }
 
+func BuildAcceptActivityIri(instanceUrl *url.URL) *url.URL {
+	iri, _ := url.Parse(instanceUrl.JoinPath("federation", "accept", uuid.NewString()).String())
+	return iri
+}
+
+func BuildAnnounceActivityIri(instanceUrl *url.URL) *url.URL {
+	iri, _ := url.Parse(instanceUrl.JoinPath("federation", "announce", uuid.NewString()).String())
+	return iri
+}
+
+func BuildUpdateActivityIri(instanceUrl *url.URL) *url.URL {
+	iri, _ := url.Parse(instanceUrl.JoinPath("federation", "update", uuid.NewString()).String())
+	return iri
+}
+
+func BuildDeleteActivityIri(instanceUrl *url.URL) *url.URL {
+	iri, _ := url.Parse(instanceUrl.JoinPath("federation", "delete", uuid.NewString()).String())
+	return iri
+}
+
 var followPath = regexp.MustCompile("^/federation/follow/[0123456789abcdefABCDEF-]{36}")
 
 func IsFollowActivityIri(iri *url.URL) bool {
@@ -129,6 +129,26 @@ func MakeUpdateActivity(activityID *url.URL, isPrivate bool) vocab.ActivityStrea
 	return activity
 }
 
+// MakeAnnounceActivity will return a new Announce activity with the provided ID.
+func MakeAnnounceActivity(activityID *url.URL) vocab.ActivityStreamsAnnounce {
+	activity := streams.NewActivityStreamsAnnounce()
+	id := streams.NewJSONLDIdProperty()
+	id.Set(activityID)
+	activity.SetJSONLDId(id)
+
+	return activity
+}
+
+// MakeDeleteActivity will return a new Delete activity with the provided ID.
+func MakeDeleteActivity(activityID *url.URL) vocab.ActivityStreamsDelete {
+	activity := streams.NewActivityStreamsDelete()
+	id := streams.NewJSONLDIdProperty()
+	id.Set(activityID)
+	activity.SetJSONLDId(id)
+
+	return activity
+}
+
 // MakeNote will return a new Note object.
 func MakeNote(text string, noteIRI *url.URL, attributedToIRI *url.URL) vocab.ActivityStreamsNote {
 	note := streams.NewActivityStreamsNote()
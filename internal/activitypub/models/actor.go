@@ -116,6 +116,16 @@ func (s *Actor) GetOutboxIri() *url.URL {
 	return iri
 }
 
+func (s *Actor) GetFollowersIri() *url.URL {
+	iri, _ := url.Parse(s.FollowersIri)
+	return iri
+}
+
+func (s *Actor) GetFollowingIri() *url.URL {
+	iri, _ := url.Parse(s.FollowingIri)
+	return iri
+}
+
 func (s *Actor) GetSharedInboxIri() *url.URL {
 	iri, _ := url.Parse(s.SharedInboxIri)
 	return iri
@@ -122,6 +122,11 @@ func BuildActivityApplication(actor *Actor, config *instance.FederationConfig) v
 	followersProperty.SetIRI(&followersURL)
 	app.SetActivityStreamsFollowers(followersProperty)
 
+	// Following
+	followingProperty := streams.NewActivityStreamsFollowingProperty()
+	followingProperty.SetIRI(instance.BuildFollowingIri(actorIRI))
+	app.SetActivityStreamsFollowing(followingProperty)
+
 	// Tags
 	tagProp := streams.NewActivityStreamsTagProperty()
 	//for _, tagString := range data.GetServerMetadataTags() {
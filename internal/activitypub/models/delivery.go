@@ -0,0 +1,54 @@
+package models
+
+import (
+	"net/url"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type DeliveryState uint
+
+const (
+	DeliveryPending DeliveryState = iota
+	DeliveryDead
+)
+
+func (ds DeliveryState) String() string {
+	return []string{"pending", "dead"}[ds]
+}
+
+// Delivery is an activity waiting to be delivered to a remote inbox.
+// Deliveries to the same inbox are delivered in the order they were added.
+type Delivery struct {
+	ActivityIri   string    `gorm:"not null;uniqueIndex:idx_delivery_activity_inbox;"`
+	InboxIri      string    `gorm:"not null;uniqueIndex:idx_delivery_activity_inbox;index;"`
+	ActorIri      string    `gorm:"not null;"`
+	Payload       string    `gorm:"not null;"`
+	State         string    `gorm:"not null;index;"`
+	Attempts      uint      `gorm:"not null;default:0;"`
+	NextAttemptAt time.Time `gorm:"not null;"`
+	LastError     string    `gorm:""`
+	gorm.Model
+}
+
+func NewDelivery(activityIri string, inbox *url.URL, actorIri *url.URL, payload []byte) *Delivery {
+	return &Delivery{
+		ActivityIri:   activityIri,
+		InboxIri:      inbox.String(),
+		ActorIri:      actorIri.String(),
+		Payload:       string(payload),
+		State:         DeliveryPending.String(),
+		NextAttemptAt: time.Now(),
+	}
+}
+
+func (d *Delivery) GetInboxIri() *url.URL {
+	iri, _ := url.Parse(d.InboxIri)
+	return iri
+}
+
+func (d *Delivery) GetActorIri() *url.URL {
+	iri, _ := url.Parse(d.ActorIri)
+	return iri
+}
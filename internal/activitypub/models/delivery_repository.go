@@ -0,0 +1,130 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shigde/sfu/internal/activitypub/instance"
+	"github.com/shigde/sfu/internal/storage"
+	"gorm.io/gorm/clause"
+)
+
+type DeliveryRepository struct {
+	locker  *sync.RWMutex
+	config  *instance.FederationConfig
+	storage storage.Storage
+}
+
+func NewDeliveryRepository(config *instance.FederationConfig, storage storage.Storage) *DeliveryRepository {
+	return &DeliveryRepository{
+		&sync.RWMutex{},
+		config,
+		storage,
+	}
+}
+
+// Add stores the delivery, an activity already waiting for the same inbox is not added twice
+func (r *DeliveryRepository) Add(ctx context.Context, delivery *Delivery) error {
+	tx, cancel := r.storage.GetDatabaseWithContext(ctx)
+	defer cancel()
+
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(delivery)
+	if result.Error != nil {
+		return fmt.Errorf("adding delivery of %s to %s: %w", delivery.ActivityIri, delivery.InboxIri, result.Error)
+	}
+	return nil
+}
+
+// GetPending returns the pending deliveries in the order they were added
+func (r *DeliveryRepository) GetPending(ctx context.Context, limit int) ([]*Delivery, error) {
+	tx, cancel := r.storage.GetDatabaseWithContext(ctx)
+	defer cancel()
+
+	var deliveries []*Delivery
+	result := tx.Where("state = ?", DeliveryPending.String()).Order("id").Limit(limit).Find(&deliveries)
+	if result.Error != nil {
+		return nil, fmt.Errorf("finding pending deliveries: %w", result.Error)
+	}
+	return deliveries, nil
+}
+
+// GetDueInboxes returns the inboxes, whose oldest pending delivery is due, in the order they were added.
+// The deliveries following the oldest one wait for it, so an inbox in backoff is not due.
+func (r *DeliveryRepository) GetDueInboxes(ctx context.Context, now time.Time, limit int) ([]string, error) {
+	tx, cancel := r.storage.GetDatabaseWithContext(ctx)
+	defer cancel()
+
+	oldest := tx.Model(&Delivery{}).Select("MIN(id)").Where("state = ?", DeliveryPending.String()).Group("inbox_iri")
+	var inboxes []string
+	result := tx.Model(&Delivery{}).
+		Where("id IN (?) AND next_attempt_at <= ?", oldest, now).
+		Order("id").Limit(limit).
+		Pluck("inbox_iri", &inboxes)
+	if result.Error != nil {
+		return nil, fmt.Errorf("finding due inboxes: %w", result.Error)
+	}
+	return inboxes, nil
+}
+
+// GetPendingByInbox returns the pending deliveries of an inbox in the order they were added
+func (r *DeliveryRepository) GetPendingByInbox(ctx context.Context, inboxIri string, limit int) ([]*Delivery, error) {
+	tx, cancel := r.storage.GetDatabaseWithContext(ctx)
+	defer cancel()
+
+	var deliveries []*Delivery
+	result := tx.Where("inbox_iri = ? AND state = ?", inboxIri, DeliveryPending.String()).Order("id").Limit(limit).Find(&deliveries)
+	if result.Error != nil {
+		return nil, fmt.Errorf("finding pending deliveries of %s: %w", inboxIri, result.Error)
+	}
+	return deliveries, nil
+}
+
+func (r *DeliveryRepository) GetDead(ctx context.Context) ([]*Delivery, error) {
+	tx, cancel := r.storage.GetDatabaseWithContext(ctx)
+	defer cancel()
+
+	var deliveries []*Delivery
+	result := tx.Where("state = ?", DeliveryDead.String()).Order("id").Find(&deliveries)
+	if result.Error != nil {
+		return nil, fmt.Errorf("finding dead deliveries: %w", result.Error)
+	}
+	return deliveries, nil
+}
+
+func (r *DeliveryRepository) Update(ctx context.Context, delivery *Delivery) error {
+	tx, cancel := r.storage.GetDatabaseWithContext(ctx)
+	defer cancel()
+
+	result := tx.Save(delivery)
+	if result.Error != nil {
+		return fmt.Errorf("updating delivery %d: %w", delivery.ID, result.Error)
+	}
+	return nil
+}
+
+func (r *DeliveryRepository) Delete(ctx context.Context, delivery *Delivery) error {
+	tx, cancel := r.storage.GetDatabaseWithContext(ctx)
+	defer cancel()
+
+	result := tx.Unscoped().Delete(&Delivery{}, delivery.ID)
+	if result.Error != nil {
+		return fmt.Errorf("deleting delivery %d: %w", delivery.ID, result.Error)
+	}
+	return nil
+}
+
+// DeadLetterInbox moves all pending deliveries of an inbox to the dead letters
+func (r *DeliveryRepository) DeadLetterInbox(ctx context.Context, inboxIri string, reason string) error {
+	tx, cancel := r.storage.GetDatabaseWithContext(ctx)
+	defer cancel()
+
+	result := tx.Model(&Delivery{}).
+		Where("inbox_iri = ? AND state = ?", inboxIri, DeliveryPending.String()).
+		Updates(map[string]interface{}{"state": DeliveryDead.String(), "last_error": reason})
+	if result.Error != nil {
+		return fmt.Errorf("dead lettering deliveries of %s: %w", inboxIri, result.Error)
+	}
+	return nil
+}
@@ -45,6 +45,21 @@ func NewFollow(actor *Actor, target *Actor, config *instance.FederationConfig) *
 		iriUrl:        iri,
 	}
 }
+
+// NewRemoteFollow creates the follow of a remote actor, who follows a local actor with the follow activity iri.
+// A local actor accepts every follow, so the follow is accepted from the beginning.
+func NewRemoteFollow(iri *url.URL, actor *Actor, target *Actor) *Follow {
+	return &Follow{
+		Iri:           iri.String(),
+		ActorId:       actor.ID,
+		TargetActorId: target.ID,
+		Actor:         actor,
+		TargetActor:   target,
+		State:         Accepted.String(),
+		iriUrl:        iri,
+	}
+}
+
 func (f *Follow) GetIri() *url.URL {
 	if f.iriUrl == nil {
 		f.iriUrl, _ = url.Parse(f.Iri)
@@ -101,3 +116,31 @@ func (f *Follow) ToAS() (vocab.ActivityStreamsFollow, error) {
 
 	return follow, nil
 }
+
+// ToAcceptAS builds the accept activity of the followed actor for this follow
+func (f *Follow) ToAcceptAS(acceptIri *url.URL) (vocab.ActivityStreamsAccept, error) {
+	follow, err := f.ToAS()
+	if err != nil {
+		return nil, fmt.Errorf("building follow activity: %w", err)
+	}
+
+	targetAccountURI, err := url.Parse(f.TargetActor.ActorIri)
+	if err != nil {
+		return nil, fmt.Errorf("parsing target account uri: %w", err)
+	}
+	targetActor := streams.NewActivityStreamsActorProperty()
+	targetActor.AppendIRI(targetAccountURI)
+
+	accept := streams.NewActivityStreamsAccept()
+	accept.SetActivityStreamsActor(targetActor)
+
+	acceptIDProp := streams.NewJSONLDIdProperty()
+	acceptIDProp.SetIRI(acceptIri)
+	accept.SetJSONLDId(acceptIDProp)
+
+	acceptObjectProp := streams.NewActivityStreamsObjectProperty()
+	acceptObjectProp.AppendActivityStreamsFollow(follow)
+	accept.SetActivityStreamsObject(acceptObjectProp)
+
+	return accept, nil
+}
@@ -55,12 +55,12 @@ func (r *FollowRepository) GetFollowByIri(ctx context.Context, iri string) (*Fol
 	tx, cancel := r.storage.GetDatabaseWithContext(ctx)
 	defer cancel()
 
-	actorFollow := &Follow{Iri: iri}
-	result := tx.Preload("Actor").Preload("TargetActor").First(actorFollow)
+	actorFollow := &Follow{}
+	result := tx.Preload("Actor").Preload("TargetActor").Where("iri = ?", iri).First(actorFollow)
 	if result.Error != nil {
 		err := fmt.Errorf("finding actor follow for iri %s: %w", iri, result.Error)
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return nil, errors.Join(err, ErrActorNotFound)
+			return nil, errors.Join(err, ErrActorFollowNotFound)
 		}
 		return nil, err
 	}
@@ -73,7 +73,7 @@ func (r *FollowRepository) GetActorFollowsFromActorId(ctx context.Context, actor
 	defer cancel()
 
 	var actorFollows []*Follow
-	results := tx.Where("actorId = ? AND state = ?", actorId, "accepted").Find(&actorFollows)
+	results := tx.Preload("TargetActor").Where("actor_id = ? AND state = ?", actorId, Accepted.String()).Find(&actorFollows)
 	if results.Error != nil {
 		err := fmt.Errorf("finding actor follows for actor %d: %w", actorId, results.Error)
 		if errors.Is(results.Error, gorm.ErrRecordNotFound) {
@@ -90,7 +90,7 @@ func (r *FollowRepository) GetActorFollowers(ctx context.Context, actorId uint)
 	defer cancel()
 
 	var actorFollows []*Follow
-	results := tx.Where("targetActorId = ? AND state = ?", actorId, "accepted").Find(&actorFollows)
+	results := tx.Preload("Actor").Where("target_actor_id = ? AND state = ?", actorId, Accepted.String()).Find(&actorFollows)
 	if results.Error != nil {
 		err := fmt.Errorf("finding follower for actor %d: %w", actorId, results.Error)
 		if errors.Is(results.Error, gorm.ErrRecordNotFound) {
@@ -102,6 +102,67 @@ func (r *FollowRepository) GetActorFollowers(ctx context.Context, actorId uint)
 	return actorFollows, nil
 }
 
+func (r *FollowRepository) CountActorFollowers(ctx context.Context, actorId uint) (int64, error) {
+	return r.countAccepted(ctx, "target_actor_id", actorId)
+}
+
+func (r *FollowRepository) CountActorFollowing(ctx context.Context, actorId uint) (int64, error) {
+	return r.countAccepted(ctx, "actor_id", actorId)
+}
+
+// GetActorFollowersPage returns the accepted follows of the followers of the actor, the oldest first
+func (r *FollowRepository) GetActorFollowersPage(ctx context.Context, actorId uint, limit int, offset int) ([]*Follow, error) {
+	tx, cancel := r.storage.GetDatabaseWithContext(ctx)
+	defer cancel()
+
+	var actorFollows []*Follow
+	results := tx.Preload("Actor").Where("target_actor_id = ? AND state = ?", actorId, Accepted.String()).Order("id").Limit(limit).Offset(offset).Find(&actorFollows)
+	if results.Error != nil {
+		return nil, fmt.Errorf("finding follower page for actor %d: %w", actorId, results.Error)
+	}
+
+	return actorFollows, nil
+}
+
+// GetActorFollowingPage returns the accepted follows of the actor, the oldest first
+func (r *FollowRepository) GetActorFollowingPage(ctx context.Context, actorId uint, limit int, offset int) ([]*Follow, error) {
+	tx, cancel := r.storage.GetDatabaseWithContext(ctx)
+	defer cancel()
+
+	var actorFollows []*Follow
+	results := tx.Preload("TargetActor").Where("actor_id = ? AND state = ?", actorId, Accepted.String()).Order("id").Limit(limit).Offset(offset).Find(&actorFollows)
+	if results.Error != nil {
+		return nil, fmt.Errorf("finding following page for actor %d: %w", actorId, results.Error)
+	}
+
+	return actorFollows, nil
+}
+
+func (r *FollowRepository) countAccepted(ctx context.Context, column string, actorId uint) (int64, error) {
+	tx, cancel := r.storage.GetDatabaseWithContext(ctx)
+	defer cancel()
+
+	var count int64
+	results := tx.Model(&Follow{}).Where(column+" = ? AND state = ?", actorId, Accepted.String()).Count(&count)
+	if results.Error != nil {
+		return 0, fmt.Errorf("counting follows of actor %d: %w", actorId, results.Error)
+	}
+
+	return count, nil
+}
+
+func (r *FollowRepository) Delete(ctx context.Context, follow *Follow) error {
+	tx, cancel := r.storage.GetDatabaseWithContext(ctx)
+	defer cancel()
+
+	results := tx.Unscoped().Delete(&Follow{}, "iri = ?", follow.Iri)
+	if results.Error != nil {
+		return fmt.Errorf("deleting actor follow for iri %s: %w", follow.Iri, results.Error)
+	}
+
+	return nil
+}
+
 func (r *FollowRepository) UpdateFollower(ctx context.Context, falower *Follow) error {
 	return nil
 }
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/superseriousbusiness/activity/streams"
+	"github.com/superseriousbusiness/activity/streams/vocab"
+	"gorm.io/gorm"
+)
+
+// OutboxActivity is an activity a local actor has published, the outbox of the actor lists them.
+type OutboxActivity struct {
+	Iri     string `gorm:"not null;index;unique;"`
+	ActorId uint   `gorm:"not null;index;"`
+	Actor   *Actor `gorm:"foreignKey:ActorId;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Type    string `gorm:"not null;"`
+	Payload string `gorm:"not null;"`
+	gorm.Model
+}
+
+func NewOutboxActivity(actor *Actor, activity vocab.Type) (*OutboxActivity, error) {
+	idProp := activity.GetJSONLDId()
+	if idProp == nil || !idProp.IsIRI() {
+		return nil, fmt.Errorf("outbox activity has no id")
+	}
+
+	payload, err := Serialize(activity)
+	if err != nil {
+		return nil, fmt.Errorf("serializing outbox activity: %w", err)
+	}
+
+	return &OutboxActivity{
+		Iri:     idProp.GetIRI().String(),
+		ActorId: actor.ID,
+		Actor:   actor,
+		Type:    activity.GetTypeName(),
+		Payload: string(payload),
+	}, nil
+}
+
+// ToAS resolves the stored activity
+func (a *OutboxActivity) ToAS(ctx context.Context) (vocab.Type, error) {
+	var jsonMap map[string]interface{}
+	if err := json.Unmarshal([]byte(a.Payload), &jsonMap); err != nil {
+		return nil, fmt.Errorf("unmarshalling outbox activity: %w", err)
+	}
+	activity, err := streams.ToType(ctx, jsonMap)
+	if err != nil {
+		return nil, fmt.Errorf("resolving outbox activity: %w", err)
+	}
+	return activity, nil
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/shigde/sfu/internal/activitypub/instance"
+	"github.com/shigde/sfu/internal/storage"
+)
+
+type OutboxRepository struct {
+	locker  *sync.RWMutex
+	config  *instance.FederationConfig
+	storage storage.Storage
+}
+
+func NewOutboxRepository(config *instance.FederationConfig, storage storage.Storage) *OutboxRepository {
+	return &OutboxRepository{
+		&sync.RWMutex{},
+		config,
+		storage,
+	}
+}
+
+func (r *OutboxRepository) Add(ctx context.Context, activity *OutboxActivity) (*OutboxActivity, error) {
+	tx, cancel := r.storage.GetDatabaseWithContext(ctx)
+	defer cancel()
+
+	result := tx.Create(activity)
+	if result.Error != nil {
+		return nil, fmt.Errorf("adding outbox activity %s: %w", activity.Iri, result.Error)
+	}
+
+	return activity, nil
+}
+
+func (r *OutboxRepository) CountByActorId(ctx context.Context, actorId uint) (int64, error) {
+	tx, cancel := r.storage.GetDatabaseWithContext(ctx)
+	defer cancel()
+
+	var count int64
+	result := tx.Model(&OutboxActivity{}).Where("actor_id = ?", actorId).Count(&count)
+	if result.Error != nil {
+		return 0, fmt.Errorf("counting outbox activities for actor %d: %w", actorId, result.Error)
+	}
+
+	return count, nil
+}
+
+// GetPageByActorId returns the activities of the actor, the newest first
+func (r *OutboxRepository) GetPageByActorId(ctx context.Context, actorId uint, limit int, offset int) ([]*OutboxActivity, error) {
+	tx, cancel := r.storage.GetDatabaseWithContext(ctx)
+	defer cancel()
+
+	var activities []*OutboxActivity
+	result := tx.Where("actor_id = ?", actorId).Order("id desc").Limit(limit).Offset(offset).Find(&activities)
+	if result.Error != nil {
+		return nil, fmt.Errorf("finding outbox activities for actor %d: %w", actorId, result.Error)
+	}
+
+	return activities, nil
+}
@@ -2,8 +2,12 @@ package models
 
 import (
 	"database/sql"
+	"fmt"
 	"net/url"
+	"strings"
 
+	"github.com/superseriousbusiness/activity/streams"
+	"github.com/superseriousbusiness/activity/streams/vocab"
 	"gorm.io/gorm"
 )
 
@@ -36,6 +40,67 @@ func (s *Video) GetVideoIri() *url.URL {
 	return s.iriUrl
 }
 
+// ToAS builds the video object with the live stream properties PeerTube sends, so that other instances can read
+// the video like a video of PeerTube.
+func (s *Video) ToAS() (vocab.ActivityStreamsVideo, error) {
+	videoIri, err := url.Parse(s.Iri)
+	if err != nil {
+		return nil, fmt.Errorf("parsing video iri: %w", err)
+	}
+
+	video := streams.NewActivityStreamsVideo()
+	idProp := streams.NewJSONLDIdProperty()
+	idProp.SetIRI(videoIri)
+	video.SetJSONLDId(idProp)
+
+	name := streams.NewActivityStreamsNameProperty()
+	name.AppendXMLSchemaString(s.Name)
+	video.SetActivityStreamsName(name)
+
+	if s.Published.Valid {
+		published := streams.NewActivityStreamsPublishedProperty()
+		published.Set(s.Published.Time)
+		video.SetActivityStreamsPublished(published)
+	}
+
+	attributedTo := streams.NewActivityStreamsAttributedToProperty()
+	for _, actor := range []*Actor{s.Owner, s.Channel} {
+		if actor != nil {
+			attributedTo.AppendIRI(actor.GetActorIri())
+		}
+	}
+	video.SetActivityStreamsAttributedTo(attributedTo)
+
+	props := video.GetUnknownProperties()
+	props["uuid"] = s.Uuid
+	props["state"] = s.State
+	props["latencyMode"] = s.LatencyMode
+	props["isLiveBroadcast"] = s.IsLiveBroadcast
+	props["permanentLive"] = s.PermanentLive
+	props["liveSaveReplay"] = s.LiveSaveReplay
+	if s.ShigActive && s.Instance != nil && s.Instance.Actor != nil {
+		props["peertubeShig"] = s.shigProps()
+	}
+
+	return video, nil
+}
+
+func (s *Video) shigProps() map[string]interface{} {
+	guests := make([]string, 3)
+	for i, guest := range s.Guests {
+		if i < len(guests) {
+			guests[i] = fmt.Sprintf("%s@%s", guest.PreferredUsername, guest.GetActorIri().Host)
+		}
+	}
+	return map[string]interface{}{
+		"shigActive":      s.ShigActive,
+		"shigInstanceUrl": strings.TrimSuffix(s.Instance.Actor.ActorIri, "/federation/accounts/shig"),
+		"firstGuest":      guests[0],
+		"secondGuest":     guests[1],
+		"thirdGuest":      guests[2],
+	}
+}
+
 func (s *Video) GetState() VideoState {
 	return VideoState(s.State)
 }
@@ -2,14 +2,18 @@ package models
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 
 	"github.com/shigde/sfu/internal/activitypub/instance"
 	"github.com/shigde/sfu/internal/storage"
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
+var ErrVideoNotFound = errors.New("video not found")
+
 type VideoRepository struct {
 	locker  *sync.RWMutex
 	config  *instance.FederationConfig
@@ -39,6 +43,35 @@ func (r *VideoRepository) Upsert(ctx context.Context, video *Video) (*Video, err
 	return video, nil
 }
 
+// UpdateState stores the state of the video, like the end of a live stream
+func (r *VideoRepository) UpdateState(ctx context.Context, iri string, state VideoState) error {
+	tx, cancel := r.storage.GetDatabaseWithContext(ctx)
+	defer cancel()
+
+	result := tx.Model(&Video{}).Where("iri = ?", iri).Update("state", uint(state))
+	if result.Error != nil {
+		return fmt.Errorf("update state of video for iri %s: %w", iri, result.Error)
+	}
+	return nil
+}
+
+func (r *VideoRepository) GetByIri(ctx context.Context, iri string) (*Video, error) {
+	tx, cancel := r.storage.GetDatabaseWithContext(ctx)
+	defer cancel()
+
+	video := &Video{}
+	result := tx.Preload("Owner").Preload("Channel").Preload("Instance").Where("iri = ?", iri).First(video)
+	if result.Error != nil {
+		err := fmt.Errorf("finding video for iri %s: %w", iri, result.Error)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, errors.Join(err, ErrVideoNotFound)
+		}
+		return nil, err
+	}
+
+	return video, nil
+}
+
 func (r *VideoRepository) DeleteByIri(ctx context.Context, iri string) error {
 	tx, cancel := r.storage.GetDatabaseWithContext(ctx)
 	defer cancel()
@@ -0,0 +1,123 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/shigde/sfu/internal/activitypub/instance"
+	"github.com/shigde/sfu/internal/activitypub/models"
+	"github.com/superseriousbusiness/activity/streams"
+	"github.com/superseriousbusiness/activity/streams/vocab"
+)
+
+// Announcer publishes the live streams hosted by this instance to the followers of the instance actor.
+// The activities look like the activities of PeerTube, so a following Shig instance handles them like
+// the activities of a followed PeerTube instance. Every published activity is kept in the outbox of the instance actor.
+type Announcer struct {
+	config     *instance.FederationConfig
+	followRepo *models.FollowRepository
+	outboxRepo *models.OutboxRepository
+	sender     *Sender
+}
+
+func NewAnnouncer(config *instance.FederationConfig, followRepo *models.FollowRepository, outboxRepo *models.OutboxRepository, sender *Sender) *Announcer {
+	return &Announcer{
+		config:     config,
+		followRepo: followRepo,
+		outboxRepo: outboxRepo,
+		sender:     sender,
+	}
+}
+
+// AnnounceVideo announces a new live stream, the followers fetch the video from its origin
+func (a *Announcer) AnnounceVideo(ctx context.Context, instanceActor *models.Actor, video *models.Video) error {
+	activity := models.MakeAnnounceActivity(instance.BuildAnnounceActivityIri(a.config.InstanceUrl))
+	activity.SetActivityStreamsActor(buildActorProperty(instanceActor))
+	activity.SetActivityStreamsTo(buildToProperty(instanceActor, video))
+
+	object := streams.NewActivityStreamsObjectProperty()
+	object.AppendIRI(video.GetVideoIri())
+	activity.SetActivityStreamsObject(object)
+
+	if err := a.publish(ctx, instanceActor, activity); err != nil {
+		return fmt.Errorf("announcing video %s: %w", video.Iri, err)
+	}
+	return nil
+}
+
+// UpdateVideo publishes the changed state of a live stream
+func (a *Announcer) UpdateVideo(ctx context.Context, instanceActor *models.Actor, video *models.Video) error {
+	asVideo, err := video.ToAS()
+	if err != nil {
+		return fmt.Errorf("building video activity stream: %w", err)
+	}
+
+	activity := models.MakeUpdateActivity(instance.BuildUpdateActivityIri(a.config.InstanceUrl), a.config.IsPrivate)
+	activity.SetActivityStreamsActor(buildActorProperty(instanceActor))
+	activity.SetActivityStreamsTo(buildToProperty(instanceActor, video))
+
+	object := streams.NewActivityStreamsObjectProperty()
+	object.AppendActivityStreamsVideo(asVideo)
+	activity.SetActivityStreamsObject(object)
+
+	if err := a.publish(ctx, instanceActor, activity); err != nil {
+		return fmt.Errorf("updating video %s: %w", video.Iri, err)
+	}
+	return nil
+}
+
+// DeleteVideo publishes the removal of a live stream
+func (a *Announcer) DeleteVideo(ctx context.Context, instanceActor *models.Actor, video *models.Video) error {
+	activity := models.MakeDeleteActivity(instance.BuildDeleteActivityIri(a.config.InstanceUrl))
+	activity.SetActivityStreamsActor(buildActorProperty(instanceActor))
+	activity.SetActivityStreamsTo(buildToProperty(instanceActor, video))
+
+	object := streams.NewActivityStreamsObjectProperty()
+	object.AppendIRI(video.GetVideoIri())
+	activity.SetActivityStreamsObject(object)
+
+	if err := a.publish(ctx, instanceActor, activity); err != nil {
+		return fmt.Errorf("deleting video %s: %w", video.Iri, err)
+	}
+	return nil
+}
+
+func (a *Announcer) publish(ctx context.Context, instanceActor *models.Actor, activity vocab.Type) error {
+	outboxActivity, err := models.NewOutboxActivity(instanceActor, activity)
+	if err != nil {
+		return fmt.Errorf("building outbox activity: %w", err)
+	}
+	if _, err := a.outboxRepo.Add(ctx, outboxActivity); err != nil {
+		return fmt.Errorf("saving outbox activity: %w", err)
+	}
+
+	followers, err := a.followRepo.GetActorFollowers(ctx, instanceActor.ID)
+	if err != nil {
+		return fmt.Errorf("getting followers of instance actor: %w", err)
+	}
+
+	return a.sender.SendToFollowers(followers, []byte(outboxActivity.Payload))
+}
+
+func buildActorProperty(actor *models.Actor) vocab.ActivityStreamsActorProperty {
+	actorProp := streams.NewActivityStreamsActorProperty()
+	actorProp.AppendIRI(actor.GetActorIri())
+	return actorProp
+}
+
+// buildToProperty addresses the followers of the video owner and channel like PeerTube does,
+// the receiving instance determines the owners of the video by them.
+func buildToProperty(instanceActor *models.Actor, video *models.Video) vocab.ActivityStreamsToProperty {
+	to := streams.NewActivityStreamsToProperty()
+	to.AppendIRI(instance.BuildFollowersIri(instanceActor.GetActorIri()))
+	for _, actor := range []*models.Actor{video.Owner, video.Channel} {
+		if actor == nil || len(actor.FollowersIri) == 0 {
+			continue
+		}
+		if followersIri, err := url.Parse(actor.FollowersIri); err == nil {
+			to.AppendIRI(followersIri)
+		}
+	}
+	return to
+}
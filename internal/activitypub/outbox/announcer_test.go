@@ -0,0 +1,121 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shigde/sfu/internal/activitypub/instance"
+	"github.com/shigde/sfu/internal/activitypub/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// testAnnouncerSetup stores two followers of one instance with a shared inbox and one follower without
+func testAnnouncerSetup(t *testing.T) (*Announcer, *testOutbox, *models.Video) {
+	t.Helper()
+	outbox := testSenderSetup(t)
+	for _, follow := range []*models.Follow{
+		testRemoteFollower(t, outbox.actorRepo, outbox.local, "http://remote.localhost", "first"),
+		testRemoteFollower(t, outbox.actorRepo, outbox.local, "http://remote.localhost", "second"),
+	} {
+		_, err := outbox.followRepo.Add(context.Background(), follow)
+		assert.NoError(t, err)
+	}
+	single := testRemoteFollower(t, outbox.actorRepo, outbox.local, "http://other.localhost", "single")
+	single.Actor.SharedInboxIri = ""
+	_, _ = outbox.actorRepo.Upsert(context.Background(), single.Actor)
+	_, err := outbox.followRepo.Add(context.Background(), single)
+	assert.NoError(t, err)
+
+	owner, _ := models.NewPersonActor(outbox.config.InstanceUrl, "owner")
+	videoUuid := uuid.NewString()
+	video := &models.Video{
+		Iri:   outbox.config.InstanceUrl.JoinPath("videos", "watch", videoUuid).String(),
+		Uuid:  videoUuid,
+		Name:  "live",
+		Owner: owner,
+	}
+	return NewAnnouncer(outbox.config, outbox.followRepo, outbox.outboxRepo, outbox.sender), outbox, video
+}
+
+// testPublished returns the published activity, after it was stored in the outbox and queued for every inbox once
+func testPublished(t *testing.T, outbox *testOutbox) map[string]interface{} {
+	t.Helper()
+	activities, err := outbox.outboxRepo.GetPageByActorId(context.Background(), outbox.local.ID, 10, 0)
+	assert.NoError(t, err)
+	if !assert.Len(t, activities, 1) {
+		t.FailNow()
+	}
+
+	pending, _ := outbox.deliveryRepo.GetPending(context.Background(), 10)
+	inboxes := make([]string, 0, len(pending))
+	for _, delivery := range pending {
+		assert.Equal(t, activities[0].Iri, delivery.ActivityIri)
+		assert.Equal(t, activities[0].Payload, delivery.Payload)
+		inboxes = append(inboxes, delivery.InboxIri)
+	}
+	single, _ := url.Parse("http://other.localhost")
+	assert.ElementsMatch(t, []string{
+		"http://remote.localhost/federation/inbox",
+		instance.BuildInboxIri(instance.BuildAccountIri(single, "single")).String(),
+	}, inboxes)
+
+	var activity map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(activities[0].Payload), &activity))
+	return activity
+}
+
+func TestAnnouncer(t *testing.T) {
+	t.Run("announce video to followers", func(t *testing.T) {
+		announcer, outbox, video := testAnnouncerSetup(t)
+
+		err := announcer.AnnounceVideo(context.Background(), outbox.local, video)
+
+		assert.NoError(t, err)
+		activity := testPublished(t, outbox)
+		assert.Equal(t, "Announce", activity["type"])
+		assert.Equal(t, outbox.local.ActorIri, activity["actor"])
+		assert.Equal(t, video.Iri, activity["object"])
+	})
+
+	t.Run("update video of followers", func(t *testing.T) {
+		announcer, outbox, video := testAnnouncerSetup(t)
+
+		err := announcer.UpdateVideo(context.Background(), outbox.local, video)
+
+		assert.NoError(t, err)
+		activity := testPublished(t, outbox)
+		assert.Equal(t, "Update", activity["type"])
+		object, ok := activity["object"].(map[string]interface{})
+		assert.True(t, ok)
+		assert.Equal(t, "Video", object["type"])
+		assert.Equal(t, video.Iri, object["id"])
+	})
+
+	t.Run("delete video of followers", func(t *testing.T) {
+		announcer, outbox, video := testAnnouncerSetup(t)
+
+		err := announcer.DeleteVideo(context.Background(), outbox.local, video)
+
+		assert.NoError(t, err)
+		activity := testPublished(t, outbox)
+		assert.Equal(t, "Delete", activity["type"])
+		assert.Equal(t, video.Iri, activity["object"])
+	})
+
+	t.Run("publish without followers", func(t *testing.T) {
+		outbox := testSenderSetup(t)
+		announcer := NewAnnouncer(outbox.config, outbox.followRepo, outbox.outboxRepo, outbox.sender)
+		video := &models.Video{Iri: outbox.config.InstanceUrl.JoinPath("videos", "watch", uuid.NewString()).String()}
+
+		err := announcer.AnnounceVideo(context.Background(), outbox.local, video)
+
+		assert.NoError(t, err)
+		count, _ := outbox.outboxRepo.CountByActorId(context.Background(), outbox.local.ID)
+		assert.Equal(t, int64(1), count)
+		pending, _ := outbox.deliveryRepo.GetPending(context.Background(), 10)
+		assert.Empty(t, pending)
+	})
+}
@@ -2,13 +2,13 @@ package outbox
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 
-	"github.com/pkg/errors"
 	"github.com/shigde/sfu/internal/activitypub/crypto"
 	"github.com/shigde/sfu/internal/activitypub/instance"
 	"github.com/shigde/sfu/internal/activitypub/models"
@@ -18,7 +18,6 @@ import (
 	"github.com/superseriousbusiness/activity/streams"
 	"github.com/superseriousbusiness/activity/streams/vocab"
 	"github.com/teris-io/shortid"
-	"golang.org/x/exp/slog"
 )
 
 type Sender struct {
@@ -26,6 +25,7 @@ type Sender struct {
 	webfingerClient *webfinger.Client
 	resolver        *remote.Resolver
 	signer          *crypto.Signer
+	queue           *workerpool.OutboundQueue
 }
 
 func NewSender(
@@ -33,12 +33,14 @@ func NewSender(
 	webfingerClient *webfinger.Client,
 	resolver *remote.Resolver,
 	signer *crypto.Signer,
+	queue *workerpool.OutboundQueue,
 ) *Sender {
 	return &Sender{
 		config,
 		webfingerClient,
 		resolver,
 		signer,
+		queue,
 	}
 }
 
@@ -56,6 +58,19 @@ func (s *Sender) SendFollowRequest(follow *models.Follow) error {
 
 }
 
+func (s *Sender) SendAccept(follow *models.Follow) error {
+	activity, err := follow.ToAcceptAS(instance.BuildAcceptActivityIri(s.config.InstanceUrl))
+	if err != nil {
+		return fmt.Errorf("bilding accept activiy stream: %w", err)
+	}
+	b, err := models.Serialize(activity)
+	if err != nil {
+		return fmt.Errorf("serializing accept activity: %w", err)
+	}
+
+	return s.SendToUser(follow.Actor.GetInboxIri(), b)
+}
+
 func (s *Sender) GetSignedRequest(fromActorIRI *url.URL, url string) (*http.Request, error) {
 	req, err := http.NewRequest(http.MethodGet, url, bytes.NewBuffer(nil))
 	if err != nil {
@@ -110,30 +125,30 @@ func (s *Sender) createBaseOutboundMessage(textContent string) (vocab.ActivitySt
 func (s *Sender) SendToUser(inbox *url.URL, payload []byte) error {
 	localActor := instance.BuildAccountIri(s.config.InstanceUrl, s.config.InstanceUsername)
 
-	req, err := s.createSignedRequest(payload, inbox, localActor)
-	if err != nil {
-		return errors.Wrap(err, "unable to create outbox request")
+	if err := s.queue.Add(context.Background(), inbox, localActor, payload); err != nil {
+		return fmt.Errorf("queueing outbox request: %w", err)
 	}
 
-	workerpool.AddToOutboundQueue(req)
-
 	return nil
 }
 
-func (s *Sender) createSignedRequest(payload []byte, url *url.URL, fromActorIRI *url.URL) (*http.Request, error) {
-	slog.Debug("Sending", "payload", string(payload), "toUrl", url)
-
-	req, _ := http.NewRequest(http.MethodPost, url.String(), bytes.NewBuffer(payload))
-
-	ua := fmt.Sprintf("%s; https://stream.shig.de", s.config.Release)
-
-	req.Header.Set("User-Agent", ua)
-	req.Header.Set("Content-Type", "application/activity+json")
-
-	if err := s.signer.SignRequest(req, payload, fromActorIRI); err != nil {
-		slog.Error("error signing request:", "err", err)
-		return nil, err
+// SendToFollowers delivers the activity to the followers. Followers of the same instance share an inbox,
+// so the instance gets the activity only once.
+func (s *Sender) SendToFollowers(follows []*models.Follow, payload []byte) error {
+	sent := make(map[string]struct{}, len(follows))
+	for _, follow := range follows {
+		inbox := follow.Actor.GetInboxIri()
+		if len(follow.Actor.SharedInboxIri) > 0 {
+			inbox = follow.Actor.GetSharedInboxIri()
+		}
+		if _, ok := sent[inbox.String()]; ok {
+			continue
+		}
+		sent[inbox.String()] = struct{}{}
+
+		if err := s.SendToUser(inbox, payload); err != nil {
+			return fmt.Errorf("sending activity to follower %s: %w", follow.Actor.ActorIri, err)
+		}
 	}
-
-	return req, nil
+	return nil
 }
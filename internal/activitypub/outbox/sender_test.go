@@ -0,0 +1,90 @@
+package outbox
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/shigde/sfu/internal/activitypub/crypto"
+	"github.com/shigde/sfu/internal/activitypub/instance"
+	"github.com/shigde/sfu/internal/activitypub/models"
+	"github.com/shigde/sfu/internal/activitypub/workerpool"
+	"github.com/shigde/sfu/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+type testOutbox struct {
+	config       *instance.FederationConfig
+	actorRepo    *models.ActorRepository
+	followRepo   *models.FollowRepository
+	outboxRepo   *models.OutboxRepository
+	deliveryRepo *models.DeliveryRepository
+	sender       *Sender
+	local        *models.Actor
+}
+
+// testSenderSetup returns a sender, whose queue only stores the deliveries
+func testSenderSetup(t *testing.T) *testOutbox {
+	t.Helper()
+	store := storage.NewTestStore()
+	_ = store.GetDatabase().AutoMigrate(&models.Actor{}, &models.Follow{}, &models.OutboxActivity{}, &models.Delivery{})
+
+	instanceUrl, _ := url.Parse("http://localhost:8080")
+	config := &instance.FederationConfig{Enable: true, InstanceUsername: "shig", InstanceUrl: instanceUrl}
+	actorRepo := models.NewActorRepository(config, store)
+	deliveryRepo := models.NewDeliveryRepository(config, store)
+	local, _ := models.NewInstanceActor(instanceUrl, "shig")
+	local, _ = actorRepo.Upsert(context.Background(), local)
+	signer := crypto.NewSigner(actorRepo)
+	queue := workerpool.NewOutboundQueue(deliveryRepo, signer, "test")
+
+	return &testOutbox{
+		config:       config,
+		actorRepo:    actorRepo,
+		followRepo:   models.NewFollowRepository(config, store),
+		outboxRepo:   models.NewOutboxRepository(config, store),
+		deliveryRepo: deliveryRepo,
+		sender:       NewSender(config, nil, nil, signer, queue),
+		local:        local,
+	}
+}
+
+func testRemoteFollower(t *testing.T, actorRepo *models.ActorRepository, local *models.Actor, instanceUrl string, name string) *models.Follow {
+	t.Helper()
+	remoteUrl, _ := url.Parse(instanceUrl)
+	follower, _ := models.NewTrustedInstanceActor(instance.BuildAccountIri(remoteUrl, name), "shig")
+	follower, _ = actorRepo.Upsert(context.Background(), follower)
+	return models.NewRemoteFollow(instance.BuildFollowActivityIri(remoteUrl), follower, local)
+}
+
+func TestSender(t *testing.T) {
+	t.Run("send to followers of the same instance only once", func(t *testing.T) {
+		outbox := testSenderSetup(t)
+		first := testRemoteFollower(t, outbox.actorRepo, outbox.local, "http://remote.localhost", "first")
+		second := testRemoteFollower(t, outbox.actorRepo, outbox.local, "http://remote.localhost", "second")
+
+		err := outbox.sender.SendToFollowers([]*models.Follow{first, second}, []byte(`{"id":"http://localhost:8080/federation/announce/1","type":"Announce"}`))
+
+		assert.NoError(t, err)
+		pending, _ := outbox.deliveryRepo.GetPending(context.Background(), 10)
+		assert.Len(t, pending, 1)
+		assert.Equal(t, "http://remote.localhost/federation/inbox", pending[0].InboxIri)
+	})
+
+	t.Run("send to the inbox of followers without shared inbox", func(t *testing.T) {
+		outbox := testSenderSetup(t)
+		shared := testRemoteFollower(t, outbox.actorRepo, outbox.local, "http://remote.localhost", "shared")
+		first := testRemoteFollower(t, outbox.actorRepo, outbox.local, "http://other.localhost", "first")
+		first.Actor.SharedInboxIri = ""
+		second := testRemoteFollower(t, outbox.actorRepo, outbox.local, "http://other.localhost", "second")
+		second.Actor.SharedInboxIri = ""
+
+		err := outbox.sender.SendToFollowers([]*models.Follow{shared, first, second}, []byte(`{"id":"http://localhost:8080/federation/announce/1","type":"Announce"}`))
+
+		assert.NoError(t, err)
+		pending, _ := outbox.deliveryRepo.GetPending(context.Background(), 10)
+		assert.Len(t, pending, 3)
+		assert.Equal(t, first.Actor.InboxIri, pending[1].InboxIri)
+		assert.Equal(t, second.Actor.InboxIri, pending[2].InboxIri)
+	})
+}
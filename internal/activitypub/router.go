@@ -16,6 +16,7 @@ func extendRouter(
 	config *instance.FederationConfig,
 	actorRep *models.ActorRepository,
 	followRep *models.FollowRepository,
+	outboxRep *models.OutboxRepository,
 	signer *crypto.Signer,
 	sender *outbox.Sender,
 	actorService *services.ActorService,
@@ -27,7 +28,9 @@ func extendRouter(
 	router.HandleFunc("/federation/accounts/{accountName}/inbox", handler.GetInboxHandler(config, actorRep)).Methods("POST")
 	router.HandleFunc("/federation/inbox", handler.GetSharedInboxHandler(config)).Methods("POST")
 
-	//outbox, followers, following
+	router.HandleFunc("/federation/accounts/{accountName}/outbox", handler.GetOutboxHandler(config, actorRep, outboxRep, signer)).Methods("GET")
+	router.HandleFunc("/federation/accounts/{accountName}/followers", handler.GetFollowersHandler(config, actorRep, followRep, signer)).Methods("GET")
+	router.HandleFunc("/federation/accounts/{accountName}/following", handler.GetFollowingHandler(config, actorRep, followRep, signer)).Methods("GET")
 
 	// Single AP object
 	router.HandleFunc("/federation/", handler.GetObjectHandler(config, signer))
@@ -10,8 +10,10 @@ import (
 
 	"github.com/shigde/sfu/internal/activitypub/instance"
 	"github.com/shigde/sfu/internal/activitypub/models"
+	"github.com/shigde/sfu/internal/activitypub/outbox"
 	"github.com/shigde/sfu/internal/activitypub/parser"
 	"github.com/superseriousbusiness/activity/streams/vocab"
+	"golang.org/x/exp/slog"
 )
 
 type VideoService struct {
@@ -20,10 +22,11 @@ type VideoService struct {
 	instanceService *InstanceService
 	videoRep        *models.VideoRepository
 	streamService   StreamService
+	announcer       *outbox.Announcer
 }
 
-func NewVideoService(config *instance.FederationConfig, actorService *ActorService, videoRep *models.VideoRepository, streamService StreamService, instanceService *InstanceService) *VideoService {
-	return &VideoService{config: config, actorService: actorService, videoRep: videoRep, instanceService: instanceService, streamService: streamService}
+func NewVideoService(config *instance.FederationConfig, actorService *ActorService, videoRep *models.VideoRepository, streamService StreamService, instanceService *InstanceService, announcer *outbox.Announcer) *VideoService {
+	return &VideoService{config: config, actorService: actorService, videoRep: videoRep, instanceService: instanceService, streamService: streamService, announcer: announcer}
 }
 
 func (s *VideoService) AddVideo(ctx context.Context, announceObject vocab.ActivityStreamsObjectProperty, toFollowerIris []*url.URL) error {
@@ -77,6 +80,7 @@ func (s *VideoService) AddVideo(ctx context.Context, announceObject vocab.Activi
 		return fmt.Errorf("stream acces for new video: %w", err)
 	}
 
+	s.publishHostedVideo(ctx, video, s.announcer.AnnounceVideo)
 	return nil
 }
 
@@ -134,6 +138,8 @@ func (s *VideoService) UpsertVideo(ctx context.Context, updateObject vocab.Activ
 	if err := s.streamService.UpdateStreamAccessByVideo(ctx, video); err != nil {
 		return fmt.Errorf("update stream acces for new video: %w", err)
 	}
+
+	s.publishHostedVideo(ctx, video, s.announcer.UpdateVideo)
 	return nil
 }
 
@@ -141,6 +147,12 @@ func (s *VideoService) DeleteVideo(ctx context.Context, deleteObject vocab.Activ
 	for iter := deleteObject.Begin(); iter != deleteObject.End(); iter = iter.Next() {
 		if iter.IsIRI() {
 			videoIri := iter.GetIRI()
+			// the video is needed to tell the followers about the deletion, if this instance hosts the live stream
+			video, err := s.videoRep.GetByIri(ctx, videoIri.String())
+			if err != nil && !errors.Is(err, models.ErrVideoNotFound) {
+				return fmt.Errorf("reading video: %w", err)
+			}
+
 			if err := s.videoRep.DeleteByIri(ctx, videoIri.String()); err != nil {
 				return fmt.Errorf("saving video: %w", err)
 			}
@@ -148,12 +160,56 @@ func (s *VideoService) DeleteVideo(ctx context.Context, deleteObject vocab.Activ
 			if err := s.streamService.DeleteStreamAccessByVideo(ctx, videoIri.String()); err != nil {
 				return fmt.Errorf("remove stream access for video: %w", err)
 			}
+
+			if video != nil {
+				s.publishHostedVideo(ctx, video, s.announcer.DeleteVideo)
+			}
 		}
 	}
 
 	return nil
 }
 
+// PublishLiveState stores the state of a live stream and tells the followers of this instance, if this instance hosts it.
+// A live stream that goes live is announced, so followers that missed the video fetch it, the end of a live stream is an update.
+func (s *VideoService) PublishLiveState(ctx context.Context, iri string, live bool) error {
+	state, publish := models.LIVE_ENDED, s.announcer.UpdateVideo
+	if live {
+		state, publish = models.PUBLISHED, s.announcer.AnnounceVideo
+	}
+	if err := s.videoRep.UpdateState(ctx, iri, state); err != nil {
+		return fmt.Errorf("storing live state: %w", err)
+	}
+	if !s.config.Enable {
+		return nil
+	}
+	video, err := s.videoRep.GetByIri(ctx, iri)
+	if err != nil {
+		return fmt.Errorf("reading video: %w", err)
+	}
+	s.publishHostedVideo(ctx, video, publish)
+	return nil
+}
+
+// publishHostedVideo tells the followers of this instance about a live stream, if this instance hosts it.
+// The video is already stored, so a failed delivery does not fail the inbox request.
+func (s *VideoService) publishHostedVideo(ctx context.Context, video *models.Video, publish func(context.Context, *models.Actor, *models.Video) error) {
+	if video.Instance == nil {
+		return
+	}
+	instAct, err := s.actorService.GetLocalInstanceActor(ctx)
+	if err != nil {
+		slog.Error("services.VideoService: getting local instance actor", "err", err)
+		return
+	}
+	if video.Instance.ActorId != instAct.ID {
+		return
+	}
+	if err := publish(ctx, instAct, video); err != nil {
+		slog.Warn("services.VideoService: publishing hosted live stream to followers", "err", err, "video", video.Iri)
+	}
+}
+
 func (s *VideoService) addOwnerAndChannel(ctx context.Context, owners []*url.URL, video *models.Video, instAct *models.Actor) error {
 	for _, iri := range owners {
 		if actor, err := s.createActor(ctx, iri, instAct); err == nil {
@@ -1,58 +1,213 @@
 package workerpool
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"runtime"
+	"sync"
+	"time"
 
+	"github.com/shigde/sfu/internal/activitypub/crypto"
+	"github.com/shigde/sfu/internal/activitypub/models"
 	"golang.org/x/exp/slog"
 )
 
 // workerPoolSize defines the number of concurrent HTTP ActivityPub requests.
 var workerPoolSize = runtime.GOMAXPROCS(0)
 
-// Job struct bundling the ActivityPub and the payload in one struct.
-type Job struct {
-	request *http.Request
+var (
+	pollInterval        = 5 * time.Second
+	minRetryDelay       = 30 * time.Second
+	maxRetryDelay       = 6 * time.Hour
+	maxDeliveryAttempts = uint(12)
+	deliveryBatchSize   = 500
+	requestTimeout      = 30 * time.Second
+)
+
+var errPermanentDelivery = errors.New("inbox rejected activity")
+
+// OutboundQueue delivers activities to remote inboxes.
+// The deliveries are stored, so a restart does not lose them, and each inbox gets its deliveries in order.
+// A failed delivery is retried with exponential backoff, the following deliveries of the inbox wait for it.
+// If an inbox keeps failing, all its pending deliveries are moved to the dead letters.
+type OutboundQueue struct {
+	repo    *models.DeliveryRepository
+	signer  *crypto.Signer
+	release string
+	client  *http.Client
+	wake    chan struct{}
 }
 
-var queue chan Job
+func NewOutboundQueue(repo *models.DeliveryRepository, signer *crypto.Signer, release string) *OutboundQueue {
+	return &OutboundQueue{
+		repo:    repo,
+		signer:  signer,
+		release: release,
+		client:  &http.Client{Timeout: requestTimeout},
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Start delivers the queued activities until the context is done.
+func (q *OutboundQueue) Start(ctx context.Context) {
+	go q.run(ctx)
+}
+
+// Add queues up the activity for the inbox, the actor signs the request when it is delivered.
+// An activity is delivered only once to the same inbox.
+func (q *OutboundQueue) Add(ctx context.Context, inbox *url.URL, actorIri *url.URL, payload []byte) error {
+	activityIri, err := getActivityIri(payload)
+	if err != nil {
+		return fmt.Errorf("reading activity id: %w", err)
+	}
 
-// InitOutboundWorkerPool starts n go routines that await ActivityPub jobs.
-func InitOutboundWorkerPool() {
-	queue = make(chan Job)
+	if err := q.repo.Add(ctx, models.NewDelivery(activityIri, inbox, actorIri, payload)); err != nil {
+		return fmt.Errorf("queueing delivery: %w", err)
+	}
+	slog.Info("Queued request for ActivityPub", "destination", inbox.String(), "activity", activityIri)
 
-	// start workers
-	for i := 1; i <= workerPoolSize; i++ {
-		go worker(i, queue)
+	select {
+	case q.wake <- struct{}{}:
+	default:
 	}
+	return nil
 }
 
-// AddToOutboundQueue will queue up an outbound http request.
-func AddToOutboundQueue(req *http.Request) {
-	slog.Info("Queued request for ActivityPub", "destination", req.RequestURI)
-	queue <- Job{req}
+func (q *OutboundQueue) run(ctx context.Context) {
+	slog.Debug("Started ActivityPub outbound queue", "workers", workerPoolSize)
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		q.deliverDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-q.wake:
+		}
+	}
 }
 
-func worker(workerID int, queue <-chan Job) {
-	slog.Debug("Started ActivityPub worker", "workerId", workerID)
+// deliverDue delivers the due deliveries of every inbox, the inboxes are delivered concurrently
+func (q *OutboundQueue) deliverDue(ctx context.Context) {
+	inboxes, err := q.repo.GetDueInboxes(ctx, time.Now(), deliveryBatchSize)
+	if err != nil {
+		slog.Error("workerpool.OutboundQueue: reading due inboxes", "err", err)
+		return
+	}
+
+	workers := make(chan struct{}, workerPoolSize)
+	var wg sync.WaitGroup
+	for _, inboxIri := range inboxes {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func(inboxIri string) {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+			deliveries, err := q.repo.GetPendingByInbox(ctx, inboxIri, deliveryBatchSize)
+			if err != nil {
+				slog.Error("workerpool.OutboundQueue: reading pending deliveries", "err", err, "destination", inboxIri)
+				return
+			}
+			q.deliverInbox(ctx, deliveries)
+		}(inboxIri)
+	}
+	wg.Wait()
+}
 
-	for job := range queue {
-		if err := sendActivityPubMessageToInbox(job); err != nil {
-			slog.Error("ActivityPub destination failed to send", "destination", job.request.RequestURI, "err", err)
+// deliverInbox delivers in order and stops at the first delivery, that has to be retried
+func (q *OutboundQueue) deliverInbox(ctx context.Context, deliveries []*models.Delivery) {
+	for _, delivery := range deliveries {
+		err := q.deliver(ctx, delivery)
+		switch {
+		case err == nil:
+			if err := q.repo.Delete(ctx, delivery); err != nil {
+				slog.Error("workerpool.OutboundQueue: removing delivered activity", "err", err, "destination", delivery.InboxIri)
+			}
+		case errors.Is(err, errPermanentDelivery):
+			slog.Warn("workerpool.OutboundQueue: inbox rejected activity", "err", err, "destination", delivery.InboxIri, "activity", delivery.ActivityIri)
+			delivery.State = models.DeliveryDead.String()
+			delivery.LastError = err.Error()
+			if err := q.repo.Update(ctx, delivery); err != nil {
+				slog.Error("workerpool.OutboundQueue: dead lettering rejected activity", "err", err, "destination", delivery.InboxIri)
+			}
+		default:
+			q.retry(ctx, delivery, err)
+			return
 		}
-		slog.Info("Done with ActivityPub destination using worker", "destination", job.request.RequestURI, "workerId", workerID)
 	}
 }
 
-func sendActivityPubMessageToInbox(job Job) error {
-	client := &http.Client{}
+func (q *OutboundQueue) retry(ctx context.Context, delivery *models.Delivery, deliveryErr error) {
+	delivery.Attempts++
+	delivery.LastError = deliveryErr.Error()
+	if delivery.Attempts >= maxDeliveryAttempts {
+		slog.Warn("workerpool.OutboundQueue: inbox keeps failing, dead lettering its deliveries", "err", deliveryErr, "destination", delivery.InboxIri, "attempts", delivery.Attempts)
+		if err := q.repo.DeadLetterInbox(ctx, delivery.InboxIri, delivery.LastError); err != nil {
+			slog.Error("workerpool.OutboundQueue: dead lettering inbox", "err", err, "destination", delivery.InboxIri)
+		}
+		return
+	}
 
-	resp, err := client.Do(job.request)
+	delay := retryDelay(delivery.Attempts)
+	delivery.NextAttemptAt = time.Now().Add(delay)
+	slog.Info("ActivityPub destination failed to send, retrying", "destination", delivery.InboxIri, "err", deliveryErr, "attempts", delivery.Attempts, "retryIn", delay)
+	if err := q.repo.Update(ctx, delivery); err != nil {
+		slog.Error("workerpool.OutboundQueue: scheduling retry", "err", err, "destination", delivery.InboxIri)
+	}
+}
+
+func (q *OutboundQueue) deliver(ctx context.Context, delivery *models.Delivery) error {
+	// the request is signed for every attempt, because the signature covers the date of the request
+	req, err := q.signer.CreateSignedRequest([]byte(delivery.Payload), delivery.GetInboxIri(), delivery.GetActorIri(), q.release)
 	if err != nil {
-		return err
+		return fmt.Errorf("signing request: %w", err)
 	}
 
+	resp, err := q.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
 	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
 
-	return nil
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	// an instance answers unauthorized, as long as it cannot fetch the key of the actor
+	case resp.StatusCode == http.StatusUnauthorized, resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("inbox responded with status %d", resp.StatusCode)
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return fmt.Errorf("%w: status %d", errPermanentDelivery, resp.StatusCode)
+	default:
+		return fmt.Errorf("inbox responded with status %d", resp.StatusCode)
+	}
+}
+
+func retryDelay(attempts uint) time.Duration {
+	delay := minRetryDelay
+	for i := uint(1); i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+func getActivityIri(payload []byte) (string, error) {
+	var activity struct {
+		Id string `json:"id"`
+	}
+	if err := json.Unmarshal(payload, &activity); err != nil {
+		return "", fmt.Errorf("unmarshalling activity: %w", err)
+	}
+	if len(activity.Id) == 0 {
+		return "", errors.New("activity has no id")
+	}
+	return activity.Id, nil
 }
@@ -0,0 +1,239 @@
+package workerpool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shigde/sfu/internal/activitypub/crypto"
+	"github.com/shigde/sfu/internal/activitypub/instance"
+	"github.com/shigde/sfu/internal/activitypub/models"
+	"github.com/shigde/sfu/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+type testInbox struct {
+	mutex     sync.Mutex
+	received  []string
+	responses []int
+	signed    bool
+}
+
+// respond answers with the prepared status codes, after them it accepts every activity
+func (i *testInbox) respond(w http.ResponseWriter, r *http.Request) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	var activity struct {
+		Id string `json:"id"`
+	}
+	_ = json.Unmarshal(body, &activity)
+	i.received = append(i.received, activity.Id)
+	i.signed = len(r.Header.Get("Signature")) > 0
+
+	status := http.StatusAccepted
+	if len(i.responses) > 0 {
+		status, i.responses = i.responses[0], i.responses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (i *testInbox) getReceived() []string {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return append([]string{}, i.received...)
+}
+
+func testOutboundQueueSetup(t *testing.T, responses ...int) (*OutboundQueue, *models.DeliveryRepository, *testInbox, *url.URL, *url.URL) {
+	t.Helper()
+	testRestoreQueueSettings(t)
+	pollInterval = 10 * time.Millisecond
+	minRetryDelay = 10 * time.Millisecond
+	maxRetryDelay = 40 * time.Millisecond
+	maxDeliveryAttempts = 4
+
+	store := storage.NewTestStore()
+	// every connection opens its own in memory database, but the queue delivers concurrently
+	db, _ := store.GetDatabase().DB()
+	db.SetMaxOpenConns(1)
+	_ = store.GetDatabase().AutoMigrate(&models.Actor{}, &models.Delivery{})
+	instanceUrl, _ := url.Parse("http://localhost:8080")
+	config := &instance.FederationConfig{Enable: true, InstanceUsername: "shig", InstanceUrl: instanceUrl}
+	actorRepo := models.NewActorRepository(config, store)
+	actor, _ := models.NewInstanceActor(instanceUrl, "shig")
+	actor, _ = actorRepo.Upsert(context.Background(), actor)
+	deliveryRepo := models.NewDeliveryRepository(config, store)
+
+	inbox := &testInbox{responses: responses}
+	server := httptest.NewServer(http.HandlerFunc(inbox.respond))
+	t.Cleanup(server.Close)
+	inboxIri, _ := url.Parse(server.URL + "/inbox")
+
+	queue := NewOutboundQueue(deliveryRepo, crypto.NewSigner(actorRepo), "test")
+	return queue, deliveryRepo, inbox, inboxIri, actor.GetActorIri()
+}
+
+// testRestoreQueueSettings restores the queue settings a test changes
+func testRestoreQueueSettings(t *testing.T) {
+	interval, minDelay, maxDelay, attempts, batchSize := pollInterval, minRetryDelay, maxRetryDelay, maxDeliveryAttempts, deliveryBatchSize
+	t.Cleanup(func() {
+		pollInterval, minRetryDelay, maxRetryDelay, maxDeliveryAttempts, deliveryBatchSize = interval, minDelay, maxDelay, attempts, batchSize
+	})
+}
+
+func testActivity(id string) []byte {
+	return []byte(fmt.Sprintf(`{"id":"http://localhost:8080/federation/announce/%s","type":"Announce"}`, id))
+}
+
+func testActivityIri(id string) string {
+	return "http://localhost:8080/federation/announce/" + id
+}
+
+func TestOutboundQueue(t *testing.T) {
+	t.Run("deliver queued activity signed", func(t *testing.T) {
+		queue, repo, inbox, inboxIri, actorIri := testOutboundQueueSetup(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		assert.NoError(t, queue.Add(ctx, inboxIri, actorIri, testActivity("1")))
+		queue.Start(ctx)
+
+		assert.Eventually(t, func() bool {
+			pending, _ := repo.GetPending(ctx, 10)
+			return len(pending) == 0
+		}, time.Second, 10*time.Millisecond)
+		assert.Equal(t, []string{testActivityIri("1")}, inbox.getReceived())
+		assert.True(t, inbox.signed)
+	})
+
+	t.Run("keep activities stored until they are delivered", func(t *testing.T) {
+		queue, repo, _, inboxIri, actorIri := testOutboundQueueSetup(t)
+		ctx := context.Background()
+
+		assert.NoError(t, queue.Add(ctx, inboxIri, actorIri, testActivity("1")))
+
+		pending, err := repo.GetPending(ctx, 10)
+		assert.NoError(t, err)
+		assert.Len(t, pending, 1)
+		assert.Equal(t, testActivityIri("1"), pending[0].ActivityIri)
+		assert.Equal(t, actorIri.String(), pending[0].ActorIri)
+	})
+
+	t.Run("queue activity only once per inbox", func(t *testing.T) {
+		queue, repo, _, inboxIri, actorIri := testOutboundQueueSetup(t)
+		ctx := context.Background()
+		otherInbox, _ := url.Parse("http://remote.localhost/inbox")
+
+		assert.NoError(t, queue.Add(ctx, inboxIri, actorIri, testActivity("1")))
+		assert.NoError(t, queue.Add(ctx, inboxIri, actorIri, testActivity("1")))
+		assert.NoError(t, queue.Add(ctx, otherInbox, actorIri, testActivity("1")))
+
+		pending, _ := repo.GetPending(ctx, 10)
+		assert.Len(t, pending, 2)
+	})
+
+	t.Run("reject activity without id", func(t *testing.T) {
+		queue, _, _, inboxIri, actorIri := testOutboundQueueSetup(t)
+
+		err := queue.Add(context.Background(), inboxIri, actorIri, []byte(`{"type":"Announce"}`))
+
+		assert.Error(t, err)
+	})
+
+	t.Run("retry failed delivery in order of the inbox", func(t *testing.T) {
+		queue, repo, inbox, inboxIri, actorIri := testOutboundQueueSetup(t, http.StatusInternalServerError, http.StatusServiceUnavailable)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		assert.NoError(t, queue.Add(ctx, inboxIri, actorIri, testActivity("1")))
+		assert.NoError(t, queue.Add(ctx, inboxIri, actorIri, testActivity("2")))
+		queue.Start(ctx)
+
+		assert.Eventually(t, func() bool {
+			pending, _ := repo.GetPending(ctx, 10)
+			return len(pending) == 0
+		}, time.Second, 10*time.Millisecond)
+		assert.Equal(t, []string{testActivityIri("1"), testActivityIri("1"), testActivityIri("1"), testActivityIri("2")}, inbox.getReceived())
+	})
+
+	t.Run("deliver to other inboxes while an inbox waits for its retry", func(t *testing.T) {
+		queue, repo, inbox, inboxIri, actorIri := testOutboundQueueSetup(t)
+		deliveryBatchSize = 2
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		waitingInbox, _ := url.Parse("http://remote.localhost/inbox")
+		for i := 0; i < deliveryBatchSize+1; i++ {
+			assert.NoError(t, queue.Add(ctx, waitingInbox, actorIri, testActivity(fmt.Sprintf("waiting-%d", i))))
+		}
+		waiting, _ := repo.GetPending(ctx, 1)
+		waiting[0].Attempts = 1
+		waiting[0].NextAttemptAt = time.Now().Add(time.Hour)
+		assert.NoError(t, repo.Update(ctx, waiting[0]))
+		assert.NoError(t, queue.Add(ctx, inboxIri, actorIri, testActivity("1")))
+		queue.Start(ctx)
+
+		assert.Eventually(t, func() bool {
+			return len(inbox.getReceived()) == 1
+		}, time.Second, 10*time.Millisecond)
+		assert.Equal(t, []string{testActivityIri("1")}, inbox.getReceived())
+		pending, _ := repo.GetPending(ctx, 10)
+		assert.Len(t, pending, deliveryBatchSize+1)
+		assert.Equal(t, uint(1), pending[0].Attempts)
+	})
+
+	t.Run("dead letter rejected activity", func(t *testing.T) {
+		queue, repo, inbox, inboxIri, actorIri := testOutboundQueueSetup(t, http.StatusNotFound)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		assert.NoError(t, queue.Add(ctx, inboxIri, actorIri, testActivity("1")))
+		assert.NoError(t, queue.Add(ctx, inboxIri, actorIri, testActivity("2")))
+		queue.Start(ctx)
+
+		assert.Eventually(t, func() bool {
+			pending, _ := repo.GetPending(ctx, 10)
+			return len(pending) == 0
+		}, time.Second, 10*time.Millisecond)
+		dead, _ := repo.GetDead(ctx)
+		assert.Len(t, dead, 1)
+		assert.Equal(t, testActivityIri("1"), dead[0].ActivityIri)
+		assert.Equal(t, []string{testActivityIri("1"), testActivityIri("2")}, inbox.getReceived())
+	})
+
+	t.Run("dead letter inbox that keeps failing", func(t *testing.T) {
+		queue, repo, inbox, inboxIri, actorIri := testOutboundQueueSetup(t, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		assert.NoError(t, queue.Add(ctx, inboxIri, actorIri, testActivity("1")))
+		assert.NoError(t, queue.Add(ctx, inboxIri, actorIri, testActivity("2")))
+		queue.Start(ctx)
+
+		assert.Eventually(t, func() bool {
+			dead, _ := repo.GetDead(ctx)
+			return len(dead) == 2
+		}, time.Second, 10*time.Millisecond)
+		pending, _ := repo.GetPending(ctx, 10)
+		assert.Len(t, pending, 0)
+		assert.Len(t, inbox.getReceived(), int(maxDeliveryAttempts))
+	})
+}
+
+func TestRetryDelay(t *testing.T) {
+	testRestoreQueueSettings(t)
+	minRetryDelay = 30 * time.Second
+	maxRetryDelay = 6 * time.Hour
+
+	assert.Equal(t, 30*time.Second, retryDelay(1))
+	assert.Equal(t, time.Minute, retryDelay(2))
+	assert.Equal(t, 4*time.Minute, retryDelay(4))
+	assert.Equal(t, 6*time.Hour, retryDelay(20))
+}
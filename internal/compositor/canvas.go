@@ -0,0 +1,46 @@
+package compositor
+
+import (
+	"image"
+)
+
+const (
+	blackLuma   = 16
+	blackChroma = 128
+)
+
+func newCanvas(width int, height int) *image.YCbCr {
+	canvas := image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420)
+	for i := range canvas.Y {
+		canvas.Y[i] = blackLuma
+	}
+	for i := range canvas.Cb {
+		canvas.Cb[i] = blackChroma
+		canvas.Cr[i] = blackChroma
+	}
+	return canvas
+}
+
+// drawScaled scales the image into the region of the canvas with nearest neighbour sampling
+func drawScaled(canvas *image.YCbCr, region image.Rectangle, img *image.YCbCr) {
+	region = region.Intersect(canvas.Rect)
+	src := img.Rect
+	if region.Empty() || src.Empty() {
+		return
+	}
+	dw, dh := region.Dx(), region.Dy()
+	sw, sh := src.Dx(), src.Dy()
+	for y := 0; y < dh; y++ {
+		sy := src.Min.Y + y*sh/dh
+		for x := 0; x < dw; x++ {
+			sx := src.Min.X + x*sw/dw
+			dx, dy := region.Min.X+x, region.Min.Y+y
+			canvas.Y[canvas.YOffset(dx, dy)] = img.Y[img.YOffset(sx, sy)]
+			if dx%2 == 0 && dy%2 == 0 {
+				dc, sc := canvas.COffset(dx, dy), img.COffset(sx, sy)
+				canvas.Cb[dc] = img.Cb[sc]
+				canvas.Cr[dc] = img.Cr[sc]
+			}
+		}
+	}
+}
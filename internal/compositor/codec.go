@@ -0,0 +1,49 @@
+package compositor
+
+import (
+	"errors"
+	"image"
+
+	"github.com/pion/webrtc/v3"
+)
+
+var ErrNoCodecs = errors.New("no compositor codecs configured")
+
+// VideoDecoder decodes the frames of a guest video track
+type VideoDecoder interface {
+	// Decode returns nil if the frame does not complete an image
+	Decode(frame []byte) (*image.YCbCr, error)
+	Close() error
+}
+
+// VideoEncoder encodes the composed video, the images have always the size of the composition
+type VideoEncoder interface {
+	// Encode returns nil if the encoder has no frame ready yet
+	Encode(img *image.YCbCr, keyframe bool) ([]byte, error)
+	Close() error
+}
+
+// AudioDecoder decodes the packets of a guest audio track to interleaved 48 kHz stereo samples
+type AudioDecoder interface {
+	Decode(packet []byte) ([]int16, error)
+	Close() error
+}
+
+// AudioEncoder encodes 20 ms of interleaved 48 kHz stereo samples to an Opus packet
+type AudioEncoder interface {
+	// Encode returns nil if the encoder has no packet ready yet
+	Encode(pcm []int16) ([]byte, error)
+	Close() error
+}
+
+// Codecs creates the decoders and encoders of a compositor.
+// The compositor has no codecs on its own, an implementation (like the ffmpeg codecs)
+// is passed with the Codecs of the CompositorConfig.
+type Codecs interface {
+	NewVideoDecoder(mimeType string) (VideoDecoder, error)
+	NewAudioDecoder(mimeType string) (AudioDecoder, error)
+	NewVideoEncoder(width int, height int, frameRate int) (VideoEncoder, error)
+	NewAudioEncoder() (AudioEncoder, error)
+	// VideoCodec is the codec of the composed video with the payload type of the main track
+	VideoCodec() webrtc.RTPCodecParameters
+}
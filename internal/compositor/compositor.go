@@ -0,0 +1,296 @@
+package compositor
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media"
+	"github.com/shigde/sfu/internal/rtp"
+	"golang.org/x/exp/slog"
+)
+
+const audioFrameDuration = 20 * time.Millisecond
+
+// dynamic payload type of the main audio track, used by receivers forwarding the packets unchanged.
+// The payload type of the main video track comes with the video codec of the Codecs.
+const audioPayloadType webrtc.PayloadType = 111
+
+// Compositor decodes the guest tracks of a lobby, tiles the videos in a layout, mixes the audio
+// and publishes the result as main tracks of the lobby.
+type Compositor struct {
+	ctx       context.Context
+	id        uuid.UUID
+	sessionId uuid.UUID
+	config    *CompositorConfig
+	layout    layout
+	codecs    Codecs
+	publish   func(info *rtp.TrackInfo)
+
+	mu        sync.Mutex
+	inputs    map[uuid.UUID]*input
+	order     []*input
+	speaker   uuid.UUID
+	counter   uint32
+	published bool
+}
+
+// NewCompositor creates a compositor for the lobby. The compositor calls publish with its main tracks,
+// when the first guest joins. The session id of these tracks is the id of the compositor.
+func NewCompositor(ctx context.Context, config *CompositorConfig, liveStreamId uuid.UUID, codecs Codecs, publish func(info *rtp.TrackInfo)) (*Compositor, error) {
+	l, err := newLayout(config.Layout)
+	if err != nil {
+		return nil, fmt.Errorf("creating layout: %w", err)
+	}
+	return &Compositor{
+		ctx:       ctx,
+		id:        liveStreamId,
+		sessionId: uuid.New(),
+		config:    config,
+		layout:    l,
+		codecs:    codecs,
+		publish:   publish,
+		inputs:    make(map[uuid.UUID]*input),
+		order:     make([]*input, 0),
+	}, nil
+}
+
+// AddTrack adds a guest track to the composition, main tracks and the own tracks are ignored
+func (c *Compositor) AddTrack(info *rtp.TrackInfo) {
+	if info.GetPurpose() == rtp.PurposeMain || info.GetSessionId() == c.sessionId {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ctx.Err() != nil {
+		return
+	}
+	if _, found := c.inputs[info.GetId()]; found {
+		return
+	}
+
+	c.counter++
+	in := newInput(info, fmt.Sprintf("compositor-%d", c.counter), webrtc.SSRC(c.counter))
+	codec, err := info.GetTrackLocal().Bind(in.context)
+	if err != nil {
+		slog.Warn("compositor.Compositor: guest track can not be composed", "liveStreamId", c.id, "track", info.GetTrackLocal().ID(), "err", err)
+		return
+	}
+	if err := c.startDecoding(in, codec); err != nil {
+		slog.Warn("compositor.Compositor: guest track can not be decoded", "liveStreamId", c.id, "track", info.GetTrackLocal().ID(), "err", err)
+		c.unbind(in)
+		return
+	}
+	c.inputs[info.GetId()] = in
+	c.order = append(c.order, in)
+	if in.isVideo {
+		info.RequestKeyframe()
+	}
+
+	if !c.published {
+		c.published = true
+		go c.run()
+	}
+	slog.Debug("compositor.Compositor: add guest track", "liveStreamId", c.id, "track", info.GetTrackLocal().ID(), "kind", info.GetTrackLocal().Kind())
+}
+
+func (c *Compositor) RemoveTrack(info *rtp.TrackInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	in, found := c.inputs[info.GetId()]
+	if !found {
+		return
+	}
+	delete(c.inputs, info.GetId())
+	for i, ordered := range c.order {
+		if ordered == in {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+	c.unbind(in)
+	slog.Debug("compositor.Compositor: remove guest track", "liveStreamId", c.id, "track", info.GetTrackLocal().ID())
+}
+
+// SetSpeaker shows the videos of the session as speaker in the speaker and picture-in-picture layouts
+func (c *Compositor) SetSpeaker(sessionId uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.speaker = sessionId
+}
+
+func (c *Compositor) startDecoding(in *input, codec webrtc.RTPCodecParameters) error {
+	if in.isVideo {
+		decoder, err := c.codecs.NewVideoDecoder(codec.MimeType)
+		if err != nil {
+			return err
+		}
+		go in.decodeVideo(codec, decoder)
+		return nil
+	}
+	decoder, err := c.codecs.NewAudioDecoder(codec.MimeType)
+	if err != nil {
+		return err
+	}
+	go in.decodeAudio(decoder)
+	return nil
+}
+
+// run writes the composed media until the lobby ends
+func (c *Compositor) run() {
+	defer c.closeInputs()
+	if err := c.stream(); err != nil {
+		slog.Error("compositor.Compositor: composing main stream", "liveStreamId", c.id, "err", err)
+		<-c.ctx.Done()
+	}
+}
+
+// stream publishes the main tracks and writes the composed media to them
+func (c *Compositor) stream() error {
+	videoCodec := c.codecs.VideoCodec()
+	videoTrack, err := webrtc.NewTrackLocalStaticSample(videoCodec.RTPCodecCapability, "compositor-video", c.id.String())
+	if err != nil {
+		return fmt.Errorf("creating video track: %w", err)
+	}
+	audioTrack, err := webrtc.NewTrackLocalStaticSample(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: audioSampleRate, Channels: audioChannels}, "compositor-audio", c.id.String())
+	if err != nil {
+		return fmt.Errorf("creating audio track: %w", err)
+	}
+	videoEncoder, err := c.codecs.NewVideoEncoder(c.config.Width, c.config.Height, c.config.FrameRate)
+	if err != nil {
+		return fmt.Errorf("creating video encoder: %w", err)
+	}
+	defer closeEncoder(videoEncoder, c.id)
+	audioEncoder, err := c.codecs.NewAudioEncoder()
+	if err != nil {
+		return fmt.Errorf("creating audio encoder: %w", err)
+	}
+	defer closeEncoder(audioEncoder, c.id)
+
+	c.publish(c.trackInfo(videoTrack, videoCodec.PayloadType))
+	c.publish(c.trackInfo(audioTrack, audioPayloadType))
+
+	frameDuration := time.Second / time.Duration(c.config.FrameRate)
+	videoTicker := time.NewTicker(frameDuration)
+	defer videoTicker.Stop()
+	audioTicker := time.NewTicker(audioFrameDuration)
+	defer audioTicker.Stop()
+
+	keyframeFrames := c.config.FrameRate * c.config.KeyframeInterval
+	frames := 0
+	for {
+		select {
+		case <-c.ctx.Done():
+			return nil
+		case <-videoTicker.C:
+			frame, err := videoEncoder.Encode(c.compose(), frames%keyframeFrames == 0)
+			frames++
+			if err != nil {
+				slog.Debug("compositor.Compositor: encoding video", "liveStreamId", c.id, "err", err)
+				continue
+			}
+			if frame == nil {
+				continue
+			}
+			if err := videoTrack.WriteSample(media.Sample{Data: frame, Duration: frameDuration}); err != nil {
+				slog.Debug("compositor.Compositor: writing video", "liveStreamId", c.id, "err", err)
+			}
+		case <-audioTicker.C:
+			packet, err := audioEncoder.Encode(c.mixAudio())
+			if err != nil {
+				slog.Debug("compositor.Compositor: encoding audio", "liveStreamId", c.id, "err", err)
+				continue
+			}
+			if packet == nil {
+				continue
+			}
+			if err := audioTrack.WriteSample(media.Sample{Data: packet, Duration: audioFrameDuration}); err != nil {
+				slog.Debug("compositor.Compositor: writing audio", "liveStreamId", c.id, "err", err)
+			}
+		}
+	}
+}
+
+// trackInfo describes a main track, the compositor is its publisher and chooses the payload type
+func (c *Compositor) trackInfo(track *webrtc.TrackLocalStaticSample, payloadType webrtc.PayloadType) *rtp.TrackInfo {
+	return &rtp.TrackInfo{
+		TrackSdpInfo: rtp.TrackSdpInfo{
+			Id:        uuid.New(),
+			SessionId: c.sessionId,
+			Purpose:   rtp.PurposeMain,
+		},
+		Track: track,
+		Codec: webrtc.RTPCodecParameters{RTPCodecCapability: track.Codec(), PayloadType: payloadType},
+	}
+}
+
+// compose draws the latest images of the guests in the layout
+func (c *Compositor) compose() *image.YCbCr {
+	canvas := newCanvas(c.config.Width, c.config.Height)
+	videos := c.videoInputs()
+	regions := c.layout(len(videos), c.config.Width, c.config.Height)
+	for i, region := range regions {
+		if img := videos[i].latestImage(); img != nil {
+			drawScaled(canvas, region, img)
+		}
+	}
+	return canvas
+}
+
+// videoInputs returns the guest videos in the order they joined, the video of the speaker first
+func (c *Compositor) videoInputs() []*input {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	videos := make([]*input, 0, len(c.order))
+	for _, in := range c.order {
+		if !in.isVideo {
+			continue
+		}
+		if in.info.GetSessionId() == c.speaker {
+			videos = append([]*input{in}, videos...)
+			continue
+		}
+		videos = append(videos, in)
+	}
+	return videos
+}
+
+func (c *Compositor) mixAudio() []int16 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	frames := make([][]int16, 0, len(c.order))
+	for _, in := range c.order {
+		if !in.isVideo {
+			frames = append(frames, in.nextAudioFrame())
+		}
+	}
+	return mix(frames)
+}
+
+func (c *Compositor) closeInputs() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, in := range c.inputs {
+		c.unbind(in)
+		delete(c.inputs, id)
+	}
+	c.order = nil
+}
+
+// unbind stops the decoding of the input, no packets are written to the input after the track is unbound
+func (c *Compositor) unbind(in *input) {
+	if err := in.info.GetTrackLocal().Unbind(in.context); err != nil {
+		slog.Warn("compositor.Compositor: unbind track", "liveStreamId", c.id, "err", err)
+	}
+	close(in.packets)
+}
+
+func closeEncoder(encoder interface{ Close() error }, liveStreamId uuid.UUID) {
+	if err := encoder.Close(); err != nil {
+		slog.Warn("compositor.Compositor: closing encoder", "liveStreamId", liveStreamId, "err", err)
+	}
+}
@@ -0,0 +1,230 @@
+package compositor
+
+import (
+	"context"
+	"image"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	pionRtp "github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/shigde/sfu/internal/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testFakeVideoMimeType                       = "video/fake"
+	testFakeVideoPayloadType webrtc.PayloadType = 98
+)
+
+// fakeCodecs decodes a video frame to an image filled with the luma of the first byte of the frame
+type fakeCodecs struct {
+	composed chan *image.YCbCr
+}
+
+func newFakeCodecs() *fakeCodecs {
+	return &fakeCodecs{composed: make(chan *image.YCbCr, 100)}
+}
+
+func (f *fakeCodecs) NewVideoDecoder(_ string) (VideoDecoder, error) { return &fakeVideoDecoder{}, nil }
+func (f *fakeCodecs) NewAudioDecoder(_ string) (AudioDecoder, error) { return &fakeAudioCodec{}, nil }
+func (f *fakeCodecs) NewVideoEncoder(_ int, _ int, _ int) (VideoEncoder, error) {
+	return &fakeVideoEncoder{composed: f.composed}, nil
+}
+func (f *fakeCodecs) NewAudioEncoder() (AudioEncoder, error) { return &fakeAudioCodec{}, nil }
+func (f *fakeCodecs) VideoCodec() webrtc.RTPCodecParameters {
+	return webrtc.RTPCodecParameters{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: testFakeVideoMimeType, ClockRate: 90000}, PayloadType: testFakeVideoPayloadType}
+}
+
+type fakeVideoDecoder struct{}
+
+func (d *fakeVideoDecoder) Decode(frame []byte) (*image.YCbCr, error) {
+	return testImage(4, 4, frame[0]), nil
+}
+func (d *fakeVideoDecoder) Close() error { return nil }
+
+type fakeVideoEncoder struct {
+	composed chan *image.YCbCr
+}
+
+func (e *fakeVideoEncoder) Encode(img *image.YCbCr, _ bool) ([]byte, error) {
+	select {
+	case e.composed <- img:
+	default:
+	}
+	return []byte{0x00}, nil
+}
+func (e *fakeVideoEncoder) Close() error { return nil }
+
+type fakeAudioCodec struct{}
+
+func (c *fakeAudioCodec) Decode(packet []byte) ([]int16, error) {
+	return make([]int16, len(packet)), nil
+}
+func (c *fakeAudioCodec) Encode(_ []int16) ([]byte, error) { return []byte{0xfc}, nil }
+func (c *fakeAudioCodec) Close() error                     { return nil }
+
+func testImage(width int, height int, luma uint8) *image.YCbCr {
+	img := newCanvas(width, height)
+	for i := range img.Y {
+		img.Y[i] = luma
+	}
+	return img
+}
+
+func testSetImage(in *input, img *image.YCbCr) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.image = img
+}
+
+func testCompositorSetup(t *testing.T, layout string) (*Compositor, *fakeCodecs, chan *rtp.TrackInfo, func()) {
+	t.Helper()
+	config := &CompositorConfig{Enable: true, Layout: layout, Width: 8, Height: 8, FrameRate: 30, KeyframeInterval: 1}
+	codecs := newFakeCodecs()
+	published := make(chan *rtp.TrackInfo, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	compositor, err := NewCompositor(ctx, config, uuid.New(), codecs, func(info *rtp.TrackInfo) {
+		published <- info
+	})
+	assert.NoError(t, err)
+	return compositor, codecs, published, cancel
+}
+
+func testGuestTrack(t *testing.T, mimeType string, clockRate uint32, purpose rtp.Purpose) (*webrtc.TrackLocalStaticRTP, *rtp.TrackInfo) {
+	t.Helper()
+	track, err := webrtc.NewTrackLocalStaticRTP(webrtc.RTPCodecCapability{MimeType: mimeType, ClockRate: clockRate}, uuid.NewString(), "stream")
+	assert.NoError(t, err)
+	info := &rtp.TrackInfo{TrackSdpInfo: rtp.TrackSdpInfo{Id: uuid.New(), SessionId: uuid.New(), Purpose: purpose}, Track: track}
+	return track, info
+}
+
+func TestCompositor(t *testing.T) {
+	t.Run("compose guest video to the main stream", func(t *testing.T) {
+		compositor, codecs, published, cancel := testCompositorSetup(t, LayoutGrid)
+		defer cancel()
+		video, info := testGuestTrack(t, webrtc.MimeTypeVP8, 90000, rtp.PurposeGuest)
+		compositor.AddTrack(info)
+
+		main := <-published
+		assert.Equal(t, rtp.PurposeMain, main.GetPurpose())
+		assert.Equal(t, testFakeVideoMimeType, main.GetTrackLocal().(*webrtc.TrackLocalStaticSample).Codec().MimeType)
+		audio := <-published
+		assert.Equal(t, webrtc.RTPCodecTypeAudio, audio.GetTrackLocal().Kind())
+		assert.Equal(t, main.GetSessionId(), audio.GetSessionId())
+
+		// the second frame completes the first one in the sample builder
+		for seq := uint16(1); seq <= 2; seq++ {
+			err := video.WriteRTP(&pionRtp.Packet{
+				Header:  pionRtp.Header{Version: 2, SequenceNumber: seq, Timestamp: uint32(seq) * 3000, Marker: true},
+				Payload: []byte{0x10, 200},
+			})
+			assert.NoError(t, err)
+		}
+
+		assert.Eventually(t, func() bool {
+			img := <-codecs.composed
+			return img.Y[0] == 200 && img.Y[len(img.Y)-1] == 200
+		}, 2*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("ignore main tracks and the own tracks", func(t *testing.T) {
+		compositor, _, published, cancel := testCompositorSetup(t, LayoutGrid)
+		defer cancel()
+		_, main := testGuestTrack(t, webrtc.MimeTypeVP8, 90000, rtp.PurposeMain)
+		compositor.AddTrack(main)
+		_, own := testGuestTrack(t, webrtc.MimeTypeVP8, 90000, rtp.PurposeGuest)
+		own.SessionId = compositor.sessionId
+		compositor.AddTrack(own)
+
+		assert.Len(t, compositor.inputs, 0)
+		assert.Len(t, published, 0)
+	})
+
+	t.Run("remove guest track", func(t *testing.T) {
+		compositor, _, _, cancel := testCompositorSetup(t, LayoutGrid)
+		defer cancel()
+		_, info := testGuestTrack(t, webrtc.MimeTypeOpus, 48000, rtp.PurposeGuest)
+		compositor.AddTrack(info)
+		assert.Len(t, compositor.inputs, 1)
+
+		compositor.RemoveTrack(info)
+		assert.Len(t, compositor.inputs, 0)
+		assert.Len(t, compositor.order, 0)
+	})
+
+	t.Run("show the speaker first", func(t *testing.T) {
+		compositor, _, _, cancel := testCompositorSetup(t, LayoutSpeaker)
+		defer cancel()
+		_, first := testGuestTrack(t, webrtc.MimeTypeVP8, 90000, rtp.PurposeGuest)
+		_, second := testGuestTrack(t, webrtc.MimeTypeVP8, 90000, rtp.PurposeGuest)
+		compositor.AddTrack(first)
+		compositor.AddTrack(second)
+
+		compositor.SetSpeaker(second.GetSessionId())
+		videos := compositor.videoInputs()
+		assert.Len(t, videos, 2)
+		assert.Equal(t, second.GetId(), videos[0].info.GetId())
+	})
+
+	t.Run("draw latest images in the layout regions", func(t *testing.T) {
+		compositor, _, _, cancel := testCompositorSetup(t, LayoutGrid)
+		defer cancel()
+		_, first := testGuestTrack(t, webrtc.MimeTypeVP8, 90000, rtp.PurposeGuest)
+		_, second := testGuestTrack(t, webrtc.MimeTypeVP8, 90000, rtp.PurposeGuest)
+		_, third := testGuestTrack(t, webrtc.MimeTypeVP8, 90000, rtp.PurposeGuest)
+		compositor.AddTrack(first)
+		compositor.AddTrack(second)
+		compositor.AddTrack(third)
+		testSetImage(compositor.inputs[first.GetId()], testImage(2, 2, 100))
+		testSetImage(compositor.inputs[second.GetId()], testImage(2, 2, 200))
+
+		canvas := compositor.compose()
+		assert.Equal(t, uint8(100), canvas.Y[canvas.YOffset(0, 0)])
+		assert.Equal(t, uint8(200), canvas.Y[canvas.YOffset(4, 0)])
+		// the third guest has no decoded image yet
+		assert.Equal(t, uint8(blackLuma), canvas.Y[canvas.YOffset(0, 4)])
+	})
+}
+
+func TestLayout(t *testing.T) {
+	t.Run("tile videos in a grid", func(t *testing.T) {
+		regions := gridLayout(3, 1280, 720)
+		assert.Equal(t, []image.Rectangle{
+			image.Rect(0, 0, 640, 360),
+			image.Rect(640, 0, 1280, 360),
+			image.Rect(0, 360, 640, 720),
+		}, regions)
+	})
+
+	t.Run("show speaker above the thumbnails", func(t *testing.T) {
+		regions := speakerLayout(3, 1280, 720)
+		assert.Equal(t, []image.Rectangle{
+			image.Rect(0, 0, 1280, 540),
+			image.Rect(0, 540, 640, 720),
+			image.Rect(640, 540, 1280, 720),
+		}, regions)
+	})
+
+	t.Run("show pictures in the speaker video", func(t *testing.T) {
+		regions := pipLayout(2, 1280, 720)
+		assert.Equal(t, []image.Rectangle{
+			image.Rect(0, 0, 1280, 720),
+			image.Rect(1280-320-22, 720-22-180, 1280-22, 720-22),
+		}, regions)
+	})
+
+	t.Run("reject unknown layout", func(t *testing.T) {
+		_, err := newLayout("mosaic")
+		assert.ErrorIs(t, err, errUnknownLayout)
+	})
+}
+
+func TestMix(t *testing.T) {
+	t.Run("add and clip samples", func(t *testing.T) {
+		mixed := mix([][]int16{{1000, 30000}, {-500, 30000, 7}})
+		assert.Len(t, mixed, audioFrameSamples)
+		assert.Equal(t, []int16{500, 32767, 7, 0}, mixed[:4])
+	})
+}
@@ -0,0 +1,36 @@
+package compositor
+
+import "fmt"
+
+type CompositorConfig struct {
+	Enable bool `mapstructure:"enable"`
+	// layout of the guest videos: "grid", "speaker" or "pip"
+	Layout    string `mapstructure:"layout"`
+	Width     int    `mapstructure:"width"`
+	Height    int    `mapstructure:"height"`
+	FrameRate int    `mapstructure:"frameRate"`
+	// seconds between two keyframes of the composed video
+	KeyframeInterval int `mapstructure:"keyframeInterval"`
+	// Codecs are not part of the config file, the server sets them on startup
+	Codecs Codecs `mapstructure:"-"`
+}
+
+func ValidateCompositorConfig(config *CompositorConfig) error {
+	if !config.Enable {
+		return nil
+	}
+	if _, err := newLayout(config.Layout); err != nil {
+		return fmt.Errorf("compositor.layout should be grid, speaker or pip: %w", err)
+	}
+	// the chroma planes of 4:2:0 images need even sizes
+	if config.Width < 2 || config.Width%2 != 0 || config.Height < 2 || config.Height%2 != 0 {
+		return fmt.Errorf("compositor.width and compositor.height should be even and greater than 0")
+	}
+	if config.FrameRate < 1 || config.FrameRate > 60 {
+		return fmt.Errorf("compositor.frameRate should be between 1 and 60")
+	}
+	if config.KeyframeInterval < 1 {
+		return fmt.Errorf("compositor.keyframeInterval should be greater than 0")
+	}
+	return nil
+}
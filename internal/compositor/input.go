@@ -0,0 +1,147 @@
+package compositor
+
+import (
+	"image"
+	"strings"
+	"sync"
+
+	pionRtp "github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media/samplebuilder"
+	"github.com/shigde/sfu/internal/rtp"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	inputQueueSize = 256
+	maxLatePackets = 64
+)
+
+var supportedCodecs = []webrtc.RTPCodecParameters{
+	{
+		RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000},
+		PayloadType:        96,
+	},
+	{
+		RTPCodecCapability: webrtc.RTPCodecCapability{
+			MimeType:    webrtc.MimeTypeH264,
+			ClockRate:   90000,
+			SDPFmtpLine: "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f",
+		},
+		PayloadType: 102,
+	},
+	{
+		RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2},
+		PayloadType:        111,
+	},
+}
+
+// input decodes a guest track. The rtp packets are decoded in an own goroutine,
+// because the ingress of the guest writes the packets to all subscribers one after the other.
+type input struct {
+	info    *rtp.TrackInfo
+	context *rtp.TrackLocalContext
+	packets chan *pionRtp.Packet
+	isVideo bool
+
+	mu    sync.Mutex
+	image *image.YCbCr
+	audio []int16
+}
+
+func newInput(info *rtp.TrackInfo, id string, ssrc webrtc.SSRC) *input {
+	in := &input{
+		info:    info,
+		packets: make(chan *pionRtp.Packet, inputQueueSize),
+		isVideo: info.GetTrackLocal().Kind() == webrtc.RTPCodecTypeVideo,
+	}
+	in.context = rtp.NewTrackLocalContext(id, ssrc, supportedCodecs, in)
+	return in
+}
+
+// WriteRTP never returns an error, because the track local would report it to the ingress media writer of the track
+func (in *input) WriteRTP(header *pionRtp.Header, payload []byte) (int, error) {
+	packet := &pionRtp.Packet{Header: *header, Payload: append([]byte(nil), payload...)}
+	select {
+	case in.packets <- packet:
+	default:
+		slog.Debug("compositor.input: queue full, drop packet", "track", in.info.GetTrackLocal().ID())
+	}
+	return len(payload), nil
+}
+
+func (in *input) Write(b []byte) (int, error) {
+	packet := &pionRtp.Packet{}
+	if err := packet.Unmarshal(b); err != nil {
+		return len(b), nil
+	}
+	return in.WriteRTP(&packet.Header, packet.Payload)
+}
+
+// decodeVideo runs until the packet queue is closed
+func (in *input) decodeVideo(codec webrtc.RTPCodecParameters, decoder VideoDecoder) {
+	defer closeCodec(decoder, in.info)
+	var depacketizer pionRtp.Depacketizer = &codecs.VP8Packet{}
+	if strings.EqualFold(codec.MimeType, webrtc.MimeTypeH264) {
+		depacketizer = &codecs.H264Packet{}
+	}
+	builder := samplebuilder.New(maxLatePackets, depacketizer, codec.ClockRate)
+	for packet := range in.packets {
+		builder.Push(packet)
+		for sample := builder.Pop(); sample != nil; sample = builder.Pop() {
+			img, err := decoder.Decode(sample.Data)
+			if err != nil {
+				slog.Debug("compositor.input: decoding video", "track", in.info.GetTrackLocal().ID(), "err", err)
+				in.info.RequestKeyframe()
+				continue
+			}
+			if img == nil {
+				continue
+			}
+			in.mu.Lock()
+			in.image = img
+			in.mu.Unlock()
+		}
+	}
+}
+
+// decodeAudio runs until the packet queue is closed
+func (in *input) decodeAudio(decoder AudioDecoder) {
+	defer closeCodec(decoder, in.info)
+	for packet := range in.packets {
+		pcm, err := decoder.Decode(packet.Payload)
+		if err != nil {
+			slog.Debug("compositor.input: decoding audio", "track", in.info.GetTrackLocal().ID(), "err", err)
+			continue
+		}
+		in.mu.Lock()
+		in.audio = append(in.audio, pcm...)
+		if len(in.audio) > maxBufferedAudio {
+			in.audio = in.audio[len(in.audio)-maxBufferedAudio:]
+		}
+		in.mu.Unlock()
+	}
+}
+
+func (in *input) latestImage() *image.YCbCr {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.image
+}
+
+// nextAudioFrame takes the samples of the next 20 ms
+func (in *input) nextAudioFrame() []int16 {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	n := min(len(in.audio), audioFrameSamples)
+	frame := in.audio[:n:n]
+	in.audio = in.audio[n:]
+	return frame
+}
+
+func closeCodec(codec interface{ Close() error }, info *rtp.TrackInfo) {
+	if err := codec.Close(); err != nil {
+		slog.Warn("compositor.input: closing codec", "track", info.GetTrackLocal().ID(), "err", err)
+	}
+}
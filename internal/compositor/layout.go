@@ -0,0 +1,92 @@
+package compositor
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+)
+
+const (
+	LayoutGrid    = "grid"
+	LayoutSpeaker = "speaker"
+	LayoutPip     = "pip"
+)
+
+var errUnknownLayout = errors.New("unknown layout")
+
+// layout returns the regions of the videos on the canvas, the first video is the speaker
+type layout func(videos int, width int, height int) []image.Rectangle
+
+func newLayout(name string) (layout, error) {
+	switch name {
+	case LayoutGrid:
+		return gridLayout, nil
+	case LayoutSpeaker:
+		return speakerLayout, nil
+	case LayoutPip:
+		return pipLayout, nil
+	default:
+		return nil, fmt.Errorf("%s: %w", name, errUnknownLayout)
+	}
+}
+
+// gridLayout tiles all videos in equal cells
+func gridLayout(videos int, width int, height int) []image.Rectangle {
+	if videos == 0 {
+		return nil
+	}
+	cols := int(math.Ceil(math.Sqrt(float64(videos))))
+	rows := (videos + cols - 1) / cols
+	cellWidth, cellHeight := even(width/cols), even(height/rows)
+	regions := make([]image.Rectangle, 0, videos)
+	for i := 0; i < videos; i++ {
+		x, y := (i%cols)*cellWidth, (i/cols)*cellHeight
+		regions = append(regions, image.Rect(x, y, x+cellWidth, y+cellHeight))
+	}
+	return regions
+}
+
+// speakerLayout shows the speaker large and the other videos as thumbnails in a row below
+func speakerLayout(videos int, width int, height int) []image.Rectangle {
+	if videos <= 1 {
+		return fullLayout(videos, width, height)
+	}
+	thumbHeight := even(height / 4)
+	thumbWidth := even(width / (videos - 1))
+	regions := []image.Rectangle{image.Rect(0, 0, width, height-thumbHeight)}
+	for i := 0; i < videos-1; i++ {
+		regions = append(regions, image.Rect(i*thumbWidth, height-thumbHeight, (i+1)*thumbWidth, height))
+	}
+	return regions
+}
+
+// pipLayout shows the speaker on the whole canvas and the other videos as small pictures in the bottom right corner
+func pipLayout(videos int, width int, height int) []image.Rectangle {
+	if videos <= 1 {
+		return fullLayout(videos, width, height)
+	}
+	pipWidth, pipHeight := even(width/4), even(height/4)
+	margin := even(min(width, height) / 32)
+	regions := []image.Rectangle{image.Rect(0, 0, width, height)}
+	for i := 0; i < videos-1; i++ {
+		x := width - (i+1)*(pipWidth+margin)
+		if x < 0 {
+			// no space left on the canvas
+			break
+		}
+		regions = append(regions, image.Rect(x, height-margin-pipHeight, x+pipWidth, height-margin))
+	}
+	return regions
+}
+
+func fullLayout(videos int, width int, height int) []image.Rectangle {
+	if videos == 0 {
+		return nil
+	}
+	return []image.Rectangle{image.Rect(0, 0, width, height)}
+}
+
+func even(n int) int {
+	return n &^ 1
+}
@@ -0,0 +1,37 @@
+package compositor
+
+import "math"
+
+const (
+	audioSampleRate = 48000
+	audioChannels   = 2
+	// samples of a 20 ms Opus frame of all channels
+	audioFrameSamples = audioSampleRate / 50 * audioChannels
+	// limits the audio buffered per guest, older samples are dropped
+	maxBufferedAudio = 10 * audioFrameSamples
+)
+
+// mix adds the samples of all guests, missing samples are silence
+func mix(frames [][]int16) []int16 {
+	mixed := make([]int16, audioFrameSamples)
+	for i := range mixed {
+		var sum int32
+		for _, frame := range frames {
+			if i < len(frame) {
+				sum += int32(frame[i])
+			}
+		}
+		mixed[i] = clip(sum)
+	}
+	return mixed
+}
+
+func clip(sample int32) int16 {
+	if sample > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if sample < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(sample)
+}
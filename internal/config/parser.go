@@ -6,11 +6,15 @@ import (
 
 	"github.com/shigde/sfu/internal/activitypub/instance"
 	"github.com/shigde/sfu/internal/auth"
+	"github.com/shigde/sfu/internal/compositor"
+	"github.com/shigde/sfu/internal/hls"
 	"github.com/shigde/sfu/internal/metric"
+	"github.com/shigde/sfu/internal/record"
 	"github.com/shigde/sfu/internal/rtp"
 	"github.com/shigde/sfu/internal/sfu"
 	"github.com/shigde/sfu/internal/storage"
 	"github.com/shigde/sfu/internal/telemetry"
+	"github.com/shigde/sfu/internal/turn"
 	"github.com/spf13/viper"
 )
 
@@ -52,6 +56,42 @@ func ParseConfig(file string, env *sfu.Environment) (*sfu.Config, error) {
 		return nil, err
 	}
 
+	// hls is optional
+	if config.HlsConfig == nil {
+		config.HlsConfig = &hls.HlsConfig{}
+	}
+	if err := hls.ValidateHlsConfig(config.HlsConfig); err != nil {
+		return nil, err
+	}
+
+	// recording is optional
+	if config.RecordConfig == nil {
+		config.RecordConfig = &record.RecordConfig{}
+	}
+	if err := record.ValidateRecordConfig(config.RecordConfig); err != nil {
+		return nil, err
+	}
+
+	// compositor is optional
+	if config.CompositorConfig == nil {
+		config.CompositorConfig = &compositor.CompositorConfig{}
+	}
+	if err := compositor.ValidateCompositorConfig(config.CompositorConfig); err != nil {
+		return nil, err
+	}
+
+	// turn server is optional
+	if config.TurnConfig == nil {
+		config.TurnConfig = &turn.TurnConfig{}
+	}
+	if err := turn.ValidateTurnConfig(config.TurnConfig); err != nil {
+		return nil, err
+	}
+	// the embedded turn server is the first ice server of the clients
+	if config.TurnConfig.Enable {
+		config.RtpConfig.ICEServer = append([]rtp.ICEServer{config.TurnConfig.GetICEServer()}, config.RtpConfig.ICEServer...)
+	}
+
 	if err := instance.ValidateFederationConfig(config.FederationConfig, &env.FederationEnv); err != nil {
 		return nil, err
 	}
@@ -0,0 +1,150 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	pionRtp "github.com/pion/rtp"
+	"github.com/pion/webrtc/v3/pkg/media/oggwriter"
+	"github.com/shigde/sfu/internal/rtmp"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// samples of one channel in an AAC frame
+	aacFrameSamples = 1024
+	aacBitrate      = "128k"
+)
+
+// aacAudioSpecificConfig describes AAC-LC with 48 kHz and two channels
+var aacAudioSpecificConfig = []byte{0x11, 0x90}
+
+// AacEncoder transcodes the Opus audio of a live stream to AAC with an ffmpeg process, because RTMP servers do not accept Opus.
+// Go has no AAC encoder and the C encoders would need cgo in every build, while the compositor needs ffmpeg anyway.
+// The encoder therefore shares the ffmpeg installation and runs without audio, if ffmpeg is not installed.
+type AacEncoder struct {
+	process *Process
+	ogg     *oggwriter.OggWriter
+
+	mu      sync.Mutex
+	packets []opusPacket // written packets, whose samples are not encoded completely
+	decoded uint64       // samples of the written packets
+	encoded uint64       // encoded AAC frames
+	frames  []rtmp.AudioFrame
+}
+
+// opusPacket places the decoded samples of an Opus packet on the timeline of the RTP timestamps
+type opusPacket struct {
+	offset    uint64 // first sample of the packet in the decoded audio
+	timestamp uint32 // milliseconds
+}
+
+// NewAacEncoder starts the encoder, ffmpeg has to be installed
+func NewAacEncoder() (*AacEncoder, error) {
+	if err := Available(); err != nil {
+		return nil, err
+	}
+	process, err := Start(
+		"-fflags", "nobuffer", "-f", "ogg", "-i", "pipe:0",
+		"-c:a", "aac", "-b:a", aacBitrate, "-ar", strconv.Itoa(audioSampleRate), "-ac", strconv.Itoa(audioChannels),
+		"-f", "adts", "-flush_packets", "1", "pipe:1",
+	)
+	if err != nil {
+		return nil, err
+	}
+	ogg, err := oggwriter.NewWith(process, audioSampleRate, audioChannels)
+	if err != nil {
+		_ = process.Close()
+		return nil, fmt.Errorf("writing ogg header: %w", err)
+	}
+	encoder := &AacEncoder{process: process, ogg: ogg}
+	go encoder.read()
+	return encoder, nil
+}
+
+func (e *AacEncoder) AudioSpecificConfig() []byte {
+	return aacAudioSpecificConfig
+}
+
+// Encode returns the AAC frames ffmpeg encoded since the last call.
+// ffmpeg encodes the decoded audio without the gaps of lost packets, so the timestamp of a frame is taken from
+// the Opus packet its first sample belongs to, which keeps the audio in sync with the video after a loss.
+func (e *AacEncoder) Encode(opus []byte, timestamp uint32) ([]rtmp.AudioFrame, error) {
+	e.mu.Lock()
+	e.packets = append(e.packets, opusPacket{offset: e.decoded, timestamp: timestamp})
+	e.decoded += uint64(opusSamples(opus))
+	e.mu.Unlock()
+
+	// the ogg stream needs the timestamp in samples to place the packet
+	packet := &pionRtp.Packet{Header: pionRtp.Header{Timestamp: timestamp * (audioSampleRate / 1000)}, Payload: opus}
+	if err := e.ogg.WriteRTP(packet); err != nil {
+		return nil, fmt.Errorf("writing packet to ffmpeg: %w", err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	frames := e.frames
+	e.frames = nil
+	return frames, nil
+}
+
+func (e *AacEncoder) read() {
+	reader := newAdtsReader(e.process.Stdout())
+	for {
+		frame, err := reader.next()
+		if err != nil {
+			slog.Debug("ffmpeg.AacEncoder: reading encoded audio", "err", err)
+			return
+		}
+		e.mu.Lock()
+		timestamp := e.frameTimestamp(e.encoded * aacFrameSamples)
+		e.encoded++
+		e.frames = append(e.frames, rtmp.AudioFrame{Data: frame, Timestamp: timestamp})
+		e.mu.Unlock()
+	}
+}
+
+// frameTimestamp returns the timestamp of the decoded sample and forgets the packets before the packet of the sample
+func (e *AacEncoder) frameTimestamp(offset uint64) uint32 {
+	for len(e.packets) > 1 && e.packets[1].offset <= offset {
+		e.packets = e.packets[1:]
+	}
+	if len(e.packets) == 0 || e.packets[0].offset > offset {
+		return 0
+	}
+	packet := e.packets[0]
+	return packet.timestamp + uint32((offset-packet.offset)*1000/audioSampleRate)
+}
+
+// opusSamples returns the samples of an Opus packet at 48 kHz by the frame size and the frame count of its TOC byte (RFC 6716, 3.1)
+func opusSamples(packet []byte) int {
+	if len(packet) == 0 {
+		return 0
+	}
+	config := packet[0] >> 3
+	var frameSamples int
+	switch {
+	case config < 12: // SILK 10, 20, 40, 60 ms
+		frameSamples = []int{480, 960, 1920, 2880}[config%4]
+	case config < 16: // Hybrid 10, 20 ms
+		frameSamples = []int{480, 960}[config%2]
+	default: // CELT 2.5, 5, 10, 20 ms
+		frameSamples = []int{120, 240, 480, 960}[config%4]
+	}
+	switch packet[0] & 0x03 {
+	case 0:
+		return frameSamples
+	case 1, 2:
+		return 2 * frameSamples
+	default:
+		if len(packet) < 2 {
+			return 0
+		}
+		return int(packet[1]&0x3F) * frameSamples
+	}
+}
+
+func (e *AacEncoder) Close() error {
+	return e.process.Close()
+}
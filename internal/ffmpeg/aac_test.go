@@ -0,0 +1,67 @@
+package ffmpeg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shigde/sfu/internal/rtmp"
+	"github.com/stretchr/testify/assert"
+)
+
+// testOpusSilence is a 20 ms Opus frame of silence
+var testOpusSilence = []byte{0xf8, 0xff, 0xfe}
+
+func TestAacEncoder(t *testing.T) {
+	t.Run("describe AAC-LC with 48 kHz stereo", func(t *testing.T) {
+		encoder := &AacEncoder{}
+
+		config := encoder.AudioSpecificConfig()
+
+		// object type 2, sampling frequency index 3, channel configuration 2
+		assert.Equal(t, byte(2), config[0]>>3)
+		assert.Equal(t, byte(3), (config[0]&0x07)<<1|config[1]>>7)
+		assert.Equal(t, byte(2), (config[1]>>3)&0x0F)
+	})
+
+	t.Run("encode opus to aac frames", func(t *testing.T) {
+		encoder, err := NewAacEncoder()
+		if err != nil {
+			t.Skipf("ffmpeg not available: %s", err)
+		}
+		defer encoder.Close()
+
+		frames := make([]rtmp.AudioFrame, 0)
+		timestamp := uint32(1000)
+		assert.Eventually(t, func() bool {
+			encoded, err := encoder.Encode(testOpusSilence, timestamp)
+			assert.NoError(t, err)
+			timestamp += 20
+			frames = append(frames, encoded...)
+			return len(frames) > 1
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(t, uint32(1000), frames[0].Timestamp)
+		assert.Equal(t, uint32(1021), frames[1].Timestamp)
+	})
+	t.Run("take the frame timestamps from the opus packets", func(t *testing.T) {
+		encoder := &AacEncoder{}
+		// 20 ms packets, the packets between 1040 and 1100 are lost
+		for _, timestamp := range []uint32{1000, 1020, 1040, 1100, 1120} {
+			encoder.packets = append(encoder.packets, opusPacket{offset: encoder.decoded, timestamp: timestamp})
+			encoder.decoded += uint64(opusSamples(testOpusSilence))
+		}
+
+		assert.Equal(t, uint32(1000), encoder.frameTimestamp(0))
+		assert.Equal(t, uint32(1021), encoder.frameTimestamp(1024))
+		assert.Equal(t, uint32(1102), encoder.frameTimestamp(3*960+96))
+		assert.Len(t, encoder.packets, 2)
+	})
+}
+
+func TestOpusSamples(t *testing.T) {
+	assert.Equal(t, 960, opusSamples(testOpusSilence))
+	// SILK 60 ms, two frames
+	assert.Equal(t, 5760, opusSamples([]byte{0x19}))
+	// CELT 2.5 ms, three frames in code 3 packet
+	assert.Equal(t, 360, opusSamples([]byte{0x83, 0x03}))
+	assert.Equal(t, 0, opusSamples(nil))
+}
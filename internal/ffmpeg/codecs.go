@@ -0,0 +1,332 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	pionRtp "github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media/oggwriter"
+	"github.com/shigde/sfu/internal/compositor"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	audioSampleRate = 48000
+	audioChannels   = 2
+	// samples of a 20 ms Opus frame of one channel
+	opusFrameSamples = audioSampleRate / 50
+	// encoded frames waiting for the compositor, older frames are dropped
+	encodedQueueSize = 32
+	// payload type of the WebRTC engine for constrained baseline H264
+	h264PayloadType webrtc.PayloadType = 106
+)
+
+var ErrUnsupportedCodec = errors.New("codec not supported")
+
+// CompositorCodecs decodes and encodes the media of a compositor with ffmpeg processes.
+// The composed video is H264 in the constrained baseline profile, the HLS packager and the RTMP publisher only take H264.
+// Every decoder and encoder is an own process, it returns the transcoded media as soon as ffmpeg outputs it.
+type CompositorCodecs struct {
+	videoBitrate  string
+	keyframeEvery int
+}
+
+// NewCompositorCodecs creates the codecs for the configured compositor, ffmpeg has to be installed with libx264 and libopus
+func NewCompositorCodecs(config *compositor.CompositorConfig) (*CompositorCodecs, error) {
+	if err := Available(); err != nil {
+		return nil, err
+	}
+	return &CompositorCodecs{
+		videoBitrate:  "2M",
+		keyframeEvery: config.FrameRate * config.KeyframeInterval,
+	}, nil
+}
+
+func (c *CompositorCodecs) VideoCodec() webrtc.RTPCodecParameters {
+	return webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{
+			MimeType:    webrtc.MimeTypeH264,
+			ClockRate:   90000,
+			SDPFmtpLine: "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f",
+		},
+		PayloadType: h264PayloadType,
+	}
+}
+
+func (c *CompositorCodecs) NewVideoDecoder(mimeType string) (compositor.VideoDecoder, error) {
+	var format string
+	switch {
+	case strings.EqualFold(mimeType, webrtc.MimeTypeVP8):
+		format = "ivf"
+	case strings.EqualFold(mimeType, webrtc.MimeTypeH264):
+		format = "h264"
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedCodec, mimeType)
+	}
+	process, err := Start(
+		"-fflags", "nobuffer", "-flags", "low_delay", "-f", format, "-i", "pipe:0",
+		"-f", "yuv4mpegpipe", "-pix_fmt", "yuv420p", "pipe:1",
+	)
+	if err != nil {
+		return nil, err
+	}
+	decoder := &videoDecoder{process: process, ivf: format == "ivf"}
+	if decoder.ivf {
+		if err := writeIvfHeader(process, 30); err != nil {
+			_ = process.Close()
+			return nil, fmt.Errorf("writing ivf header: %w", err)
+		}
+	}
+	go decoder.read()
+	return decoder, nil
+}
+
+func (c *CompositorCodecs) NewVideoEncoder(width int, height int, frameRate int) (compositor.VideoEncoder, error) {
+	process, err := Start(
+		"-f", "rawvideo", "-pix_fmt", "yuv420p", "-s", fmt.Sprintf("%dx%d", width, height), "-r", strconv.Itoa(frameRate), "-i", "pipe:0",
+		"-c:v", "libx264", "-preset", "ultrafast", "-tune", "zerolatency", "-profile:v", "baseline", "-level", "3.1",
+		"-x264-params", "aud=1", "-g", strconv.Itoa(c.keyframeEvery), "-b:v", c.videoBitrate,
+		"-f", "h264", "-flush_packets", "1", "pipe:1",
+	)
+	if err != nil {
+		return nil, err
+	}
+	encoder := &videoEncoder{process: process, frames: make(chan []byte, encodedQueueSize)}
+	go encoder.read()
+	return encoder, nil
+}
+
+func (c *CompositorCodecs) NewAudioDecoder(mimeType string) (compositor.AudioDecoder, error) {
+	if !strings.EqualFold(mimeType, webrtc.MimeTypeOpus) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedCodec, mimeType)
+	}
+	process, err := Start(
+		"-fflags", "nobuffer", "-f", "ogg", "-i", "pipe:0",
+		"-f", "s16le", "-ar", strconv.Itoa(audioSampleRate), "-ac", strconv.Itoa(audioChannels), "pipe:1",
+	)
+	if err != nil {
+		return nil, err
+	}
+	ogg, err := oggwriter.NewWith(process, audioSampleRate, audioChannels)
+	if err != nil {
+		_ = process.Close()
+		return nil, fmt.Errorf("writing ogg header: %w", err)
+	}
+	decoder := &audioDecoder{process: process, ogg: ogg}
+	go decoder.read()
+	return decoder, nil
+}
+
+func (c *CompositorCodecs) NewAudioEncoder() (compositor.AudioEncoder, error) {
+	process, err := Start(
+		"-f", "s16le", "-ar", strconv.Itoa(audioSampleRate), "-ac", strconv.Itoa(audioChannels), "-i", "pipe:0",
+		"-c:a", "libopus", "-application", "lowdelay", "-frame_duration", "20", "-b:a", "128k",
+		"-f", "ogg", "-page_duration", "20000", "-flush_packets", "1", "pipe:1",
+	)
+	if err != nil {
+		return nil, err
+	}
+	encoder := &audioEncoder{process: process, packets: make(chan []byte, encodedQueueSize)}
+	go encoder.read()
+	return encoder, nil
+}
+
+// videoDecoder decodes VP8 frames in an IVF stream or H264 frames in an Annex B stream
+type videoDecoder struct {
+	process *Process
+	ivf     bool
+	pts     uint64
+
+	mu    sync.Mutex
+	image *image.YCbCr
+}
+
+// Decode returns the latest image ffmpeg decoded since the last call, or nil
+func (d *videoDecoder) Decode(frame []byte) (*image.YCbCr, error) {
+	var err error
+	if d.ivf {
+		err = writeIvfFrame(d.process, frame, d.pts)
+		d.pts++
+	} else {
+		_, err = d.process.Write(frame)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("writing frame to ffmpeg: %w", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	img := d.image
+	d.image = nil
+	return img, nil
+}
+
+func (d *videoDecoder) read() {
+	reader, err := newY4mReader(d.process.Stdout())
+	if err != nil {
+		slog.Debug("ffmpeg.videoDecoder: reading decoded video", "err", err)
+		return
+	}
+	for {
+		img, err := reader.next()
+		if err != nil {
+			return
+		}
+		d.mu.Lock()
+		d.image = img
+		d.mu.Unlock()
+	}
+}
+
+func (d *videoDecoder) Close() error {
+	return d.process.Close()
+}
+
+// videoEncoder encodes raw images to H264 access units in an Annex B stream
+type videoEncoder struct {
+	process *Process
+	frames  chan []byte
+}
+
+// Encode returns the next frame ffmpeg encoded, or nil. ffmpeg places the keyframes itself.
+func (e *videoEncoder) Encode(img *image.YCbCr, _ bool) ([]byte, error) {
+	if err := writeRawImage(e.process, img); err != nil {
+		return nil, fmt.Errorf("writing image to ffmpeg: %w", err)
+	}
+	select {
+	case frame := <-e.frames:
+		return frame, nil
+	default:
+		return nil, nil
+	}
+}
+
+func (e *videoEncoder) read() {
+	reader, err := newH264AccessUnitReader(e.process.Stdout())
+	if err != nil {
+		slog.Debug("ffmpeg.videoEncoder: reading encoded video", "err", err)
+		return
+	}
+	for {
+		frame, err := reader.next()
+		if err != nil {
+			return
+		}
+		queue(e.frames, frame)
+	}
+}
+
+func (e *videoEncoder) Close() error {
+	return e.process.Close()
+}
+
+// audioDecoder decodes Opus packets in an Ogg stream to interleaved samples
+type audioDecoder struct {
+	process   *Process
+	ogg       *oggwriter.OggWriter
+	timestamp uint32
+
+	mu      sync.Mutex
+	samples []int16
+}
+
+// Decode returns the samples ffmpeg decoded since the last call
+func (d *audioDecoder) Decode(packet []byte) ([]int16, error) {
+	if err := d.ogg.WriteRTP(&pionRtp.Packet{Header: pionRtp.Header{Timestamp: d.timestamp}, Payload: packet}); err != nil {
+		return nil, fmt.Errorf("writing packet to ffmpeg: %w", err)
+	}
+	d.timestamp += opusFrameSamples
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	samples := d.samples
+	d.samples = nil
+	return samples, nil
+}
+
+func (d *audioDecoder) read() {
+	buf := make([]byte, opusFrameSamples*audioChannels*2)
+	for {
+		if _, err := io.ReadFull(d.process.Stdout(), buf); err != nil {
+			return
+		}
+		d.mu.Lock()
+		for i := 0; i < len(buf); i += 2 {
+			d.samples = append(d.samples, int16(binary.LittleEndian.Uint16(buf[i:])))
+		}
+		d.mu.Unlock()
+	}
+}
+
+func (d *audioDecoder) Close() error {
+	return d.process.Close()
+}
+
+// audioEncoder encodes interleaved samples to Opus packets in an Ogg stream
+type audioEncoder struct {
+	process *Process
+	packets chan []byte
+}
+
+// Encode returns the next packet ffmpeg encoded, or nil
+func (e *audioEncoder) Encode(pcm []int16) ([]byte, error) {
+	buf := make([]byte, 2*len(pcm))
+	for i, sample := range pcm {
+		binary.LittleEndian.PutUint16(buf[2*i:], uint16(sample))
+	}
+	if _, err := e.process.Write(buf); err != nil {
+		return nil, fmt.Errorf("writing samples to ffmpeg: %w", err)
+	}
+	select {
+	case packet := <-e.packets:
+		return packet, nil
+	default:
+		return nil, nil
+	}
+}
+
+func (e *audioEncoder) read() {
+	reader := newOggReader(e.process.Stdout())
+	for {
+		packet, err := reader.next()
+		if err != nil {
+			return
+		}
+		// the id and comment headers of the Opus stream are no audio
+		if isOpusHeader(packet) {
+			continue
+		}
+		queue(e.packets, packet)
+	}
+}
+
+func (e *audioEncoder) Close() error {
+	return e.process.Close()
+}
+
+func isOpusHeader(packet []byte) bool {
+	return bytes.HasPrefix(packet, []byte("OpusHead")) || bytes.HasPrefix(packet, []byte("OpusTags"))
+}
+
+// queue drops the oldest media, if the consumer is too slow
+func queue(media chan []byte, data []byte) {
+	for {
+		select {
+		case media <- data:
+			return
+		default:
+		}
+		select {
+		case <-media:
+		default:
+		}
+	}
+}
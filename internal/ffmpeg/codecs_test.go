@@ -0,0 +1,72 @@
+package ffmpeg
+
+import (
+	"image"
+	"testing"
+	"time"
+
+	"github.com/shigde/sfu/internal/compositor"
+	"github.com/stretchr/testify/assert"
+)
+
+func testCompositorCodecsSetup(t *testing.T) *CompositorCodecs {
+	t.Helper()
+	codecs, err := NewCompositorCodecs(&compositor.CompositorConfig{Enable: true, Layout: "grid", Width: 64, Height: 64, FrameRate: 30, KeyframeInterval: 1})
+	if err != nil {
+		t.Skipf("ffmpeg not available: %s", err)
+	}
+	return codecs
+}
+
+func TestCompositorCodecs(t *testing.T) {
+	t.Run("encode and decode video", func(t *testing.T) {
+		codecs := testCompositorCodecsSetup(t)
+		encoder, err := codecs.NewVideoEncoder(64, 64, 30)
+		assert.NoError(t, err)
+		defer encoder.Close()
+		decoder, err := codecs.NewVideoDecoder(codecs.VideoCodec().MimeType)
+		assert.NoError(t, err)
+		defer decoder.Close()
+
+		img := image.NewYCbCr(image.Rect(0, 0, 64, 64), image.YCbCrSubsampleRatio420)
+		var decoded *image.YCbCr
+		assert.Eventually(t, func() bool {
+			frame, err := encoder.Encode(img, false)
+			if err != nil || frame == nil {
+				return false
+			}
+			decoded, _ = decoder.Decode(frame)
+			return decoded != nil
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(t, img.Rect, decoded.Rect)
+	})
+
+	t.Run("encode and decode audio", func(t *testing.T) {
+		codecs := testCompositorCodecsSetup(t)
+		encoder, err := codecs.NewAudioEncoder()
+		assert.NoError(t, err)
+		defer encoder.Close()
+		decoder, err := codecs.NewAudioDecoder("audio/opus")
+		assert.NoError(t, err)
+		defer decoder.Close()
+
+		pcm := make([]int16, opusFrameSamples*audioChannels)
+		assert.Eventually(t, func() bool {
+			packet, err := encoder.Encode(pcm)
+			if err != nil || packet == nil {
+				return false
+			}
+			samples, _ := decoder.Decode(packet)
+			return len(samples) > 0
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("reject unsupported codecs", func(t *testing.T) {
+		codecs := &CompositorCodecs{}
+
+		_, err := codecs.NewVideoDecoder("video/AV1")
+		assert.ErrorIs(t, err, ErrUnsupportedCodec)
+		_, err = codecs.NewAudioDecoder("audio/PCMU")
+		assert.ErrorIs(t, err, ErrUnsupportedCodec)
+	})
+}
@@ -0,0 +1,245 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+
+	"github.com/pion/webrtc/v3/pkg/media/h264reader"
+)
+
+const (
+	ivfFileHeaderSize  = 32
+	ivfFrameHeaderSize = 12
+	oggPageHeaderSize  = 27
+	adtsHeaderSize     = 7
+)
+
+var errInvalidContainer = errors.New("invalid container")
+
+// writeIvfHeader starts an IVF stream of VP8 frames, ffmpeg reads the size of the video from the frames
+func writeIvfHeader(w io.Writer, frameRate int) error {
+	header := make([]byte, ivfFileHeaderSize)
+	copy(header[0:], "DKIF")
+	binary.LittleEndian.PutUint16(header[6:], ivfFileHeaderSize)
+	copy(header[8:], "VP80")
+	binary.LittleEndian.PutUint32(header[16:], uint32(frameRate))
+	binary.LittleEndian.PutUint32(header[20:], 1)
+	_, err := w.Write(header)
+	return err
+}
+
+func writeIvfFrame(w io.Writer, frame []byte, pts uint64) error {
+	header := make([]byte, ivfFrameHeaderSize, ivfFrameHeaderSize+len(frame))
+	binary.LittleEndian.PutUint32(header[0:], uint32(len(frame)))
+	binary.LittleEndian.PutUint64(header[4:], pts)
+	_, err := w.Write(append(header, frame...))
+	return err
+}
+
+// annexBStartCode separates the NAL units of an H264 access unit
+var annexBStartCode = []byte{0x00, 0x00, 0x00, 0x01}
+
+// h264AccessUnitReader reads the access units of an H264 Annex B stream, every access unit has to start with an access unit delimiter
+type h264AccessUnitReader struct {
+	reader *h264reader.H264Reader
+	nals   [][]byte
+}
+
+func newH264AccessUnitReader(r io.Reader) (*h264AccessUnitReader, error) {
+	reader, err := h264reader.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading h264 stream: %w", err)
+	}
+	return &h264AccessUnitReader{reader: reader}, nil
+}
+
+// next returns the NAL units of an access unit with Annex B start codes, without the access unit delimiter.
+// An access unit is complete with the delimiter of the next one.
+func (h *h264AccessUnitReader) next() ([]byte, error) {
+	for {
+		nal, err := h.reader.NextNAL()
+		if err != nil {
+			return nil, err
+		}
+		if nal.UnitType != h264reader.NalUnitTypeAUD {
+			h.nals = append(h.nals, nal.Data)
+			continue
+		}
+		if len(h.nals) == 0 {
+			continue
+		}
+		accessUnit := h.accessUnit()
+		h.nals = nil
+		return accessUnit, nil
+	}
+}
+
+func (h *h264AccessUnitReader) accessUnit() []byte {
+	var accessUnit []byte
+	for _, nal := range h.nals {
+		accessUnit = append(accessUnit, annexBStartCode...)
+		accessUnit = append(accessUnit, nal...)
+	}
+	return accessUnit
+}
+
+// y4mReader reads the 4:2:0 images of a YUV4MPEG2 stream
+type y4mReader struct {
+	r      *bufio.Reader
+	width  int
+	height int
+}
+
+func newY4mReader(r io.Reader) (*y4mReader, error) {
+	br := bufio.NewReader(r)
+	line, err := br.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("reading y4m header: %w", err)
+	}
+	fields := bytes.Fields(line)
+	if len(fields) == 0 || string(fields[0]) != "YUV4MPEG2" {
+		return nil, fmt.Errorf("%w: no y4m signature", errInvalidContainer)
+	}
+	reader := &y4mReader{r: br}
+	for _, field := range fields[1:] {
+		switch field[0] {
+		case 'W':
+			reader.width, err = strconv.Atoi(string(field[1:]))
+		case 'H':
+			reader.height, err = strconv.Atoi(string(field[1:]))
+		case 'C':
+			if !bytes.HasPrefix(field[1:], []byte("420")) {
+				return nil, fmt.Errorf("%w: y4m color space %s is not 4:2:0", errInvalidContainer, field[1:])
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: y4m header %s", errInvalidContainer, field)
+		}
+	}
+	if reader.width < 1 || reader.height < 1 {
+		return nil, fmt.Errorf("%w: y4m header without size", errInvalidContainer)
+	}
+	return reader, nil
+}
+
+func (y *y4mReader) next() (*image.YCbCr, error) {
+	line, err := y.r.ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(line, []byte("FRAME")) {
+		return nil, fmt.Errorf("%w: no y4m frame", errInvalidContainer)
+	}
+	img := image.NewYCbCr(image.Rect(0, 0, y.width, y.height), image.YCbCrSubsampleRatio420)
+	for _, plane := range [][]byte{img.Y, img.Cb, img.Cr} {
+		if _, err := io.ReadFull(y.r, plane); err != nil {
+			return nil, fmt.Errorf("reading y4m frame: %w", err)
+		}
+	}
+	return img, nil
+}
+
+// writeRawImage writes the planes of an image without the padding of its strides
+func writeRawImage(w io.Writer, img *image.YCbCr) error {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	chromaWidth, chromaHeight := (width+1)/2, (height+1)/2
+	buf := make([]byte, 0, width*height+2*chromaWidth*chromaHeight)
+	for row := 0; row < height; row++ {
+		buf = append(buf, img.Y[row*img.YStride:row*img.YStride+width]...)
+	}
+	for _, plane := range [][]byte{img.Cb, img.Cr} {
+		for row := 0; row < chromaHeight; row++ {
+			buf = append(buf, plane[row*img.CStride:row*img.CStride+chromaWidth]...)
+		}
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// oggReader reads the packets of an Ogg stream with a single logical stream
+type oggReader struct {
+	r       io.Reader
+	packets [][]byte
+	partial []byte
+}
+
+func newOggReader(r io.Reader) *oggReader {
+	return &oggReader{r: r}
+}
+
+func (o *oggReader) next() ([]byte, error) {
+	for len(o.packets) == 0 {
+		if err := o.readPage(); err != nil {
+			return nil, err
+		}
+	}
+	packet := o.packets[0]
+	o.packets = o.packets[1:]
+	return packet, nil
+}
+
+// readPage splits a page in packets, a segment of 255 bytes continues the packet in the next segment or page
+func (o *oggReader) readPage() error {
+	header := make([]byte, oggPageHeaderSize)
+	if _, err := io.ReadFull(o.r, header); err != nil {
+		return err
+	}
+	if string(header[0:4]) != "OggS" {
+		return fmt.Errorf("%w: no ogg page signature", errInvalidContainer)
+	}
+	segments := make([]byte, header[26])
+	if _, err := io.ReadFull(o.r, segments); err != nil {
+		return fmt.Errorf("reading ogg segment table: %w", err)
+	}
+	for _, size := range segments {
+		segment := make([]byte, size)
+		if _, err := io.ReadFull(o.r, segment); err != nil {
+			return fmt.Errorf("reading ogg segment: %w", err)
+		}
+		o.partial = append(o.partial, segment...)
+		if size < 255 {
+			o.packets = append(o.packets, o.partial)
+			o.partial = nil
+		}
+	}
+	return nil
+}
+
+// adtsReader reads the raw AAC frames of an ADTS stream (ISO/IEC 13818-7)
+type adtsReader struct {
+	r io.Reader
+}
+
+func newAdtsReader(r io.Reader) *adtsReader {
+	return &adtsReader{r: r}
+}
+
+func (a *adtsReader) next() ([]byte, error) {
+	header := make([]byte, adtsHeaderSize)
+	if _, err := io.ReadFull(a.r, header); err != nil {
+		return nil, err
+	}
+	if header[0] != 0xFF || header[1]&0xF0 != 0xF0 {
+		return nil, fmt.Errorf("%w: no adts sync word", errInvalidContainer)
+	}
+	headerSize := adtsHeaderSize
+	// without protection the header has no crc
+	if header[1]&0x01 == 0 {
+		headerSize += 2
+	}
+	frameSize := int(header[3]&0x03)<<11 | int(header[4])<<3 | int(header[5])>>5
+	if frameSize < headerSize {
+		return nil, fmt.Errorf("%w: adts frame smaller than its header", errInvalidContainer)
+	}
+	frame := make([]byte, frameSize-adtsHeaderSize)
+	if _, err := io.ReadFull(a.r, frame); err != nil {
+		return nil, fmt.Errorf("reading adts frame: %w", err)
+	}
+	return frame[headerSize-adtsHeaderSize:], nil
+}
@@ -0,0 +1,146 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"testing"
+
+	pionRtp "github.com/pion/rtp"
+	"github.com/pion/webrtc/v3/pkg/media/oggwriter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIvf(t *testing.T) {
+	t.Run("write frames", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, writeIvfHeader(&buf, 30))
+		assert.NoError(t, writeIvfFrame(&buf, []byte{0x01, 0x02}, 1))
+
+		data := buf.Bytes()
+		assert.Equal(t, "DKIF", string(data[0:4]))
+		assert.Equal(t, ivfFileHeaderSize+ivfFrameHeaderSize+2, len(data))
+		assert.Equal(t, uint32(2), binary.LittleEndian.Uint32(data[ivfFileHeaderSize:]))
+		assert.Equal(t, uint64(1), binary.LittleEndian.Uint64(data[ivfFileHeaderSize+4:]))
+		assert.Equal(t, []byte{0x01, 0x02}, data[ivfFileHeaderSize+ivfFrameHeaderSize:])
+	})
+}
+
+func TestH264AccessUnits(t *testing.T) {
+	t.Run("read access units without delimiters", func(t *testing.T) {
+		aud := []byte{0x00, 0x00, 0x00, 0x01, 0x09, 0xf0}
+		sps := []byte{0x00, 0x00, 0x00, 0x01, 0x67, 0x42}
+		idr := []byte{0x00, 0x00, 0x00, 0x01, 0x65, 0x88}
+		slice := []byte{0x00, 0x00, 0x00, 0x01, 0x41, 0x9a}
+		stream := bytes.Join([][]byte{aud, sps, idr, aud, slice, aud}, nil)
+
+		reader, err := newH264AccessUnitReader(bytes.NewReader(stream))
+		assert.NoError(t, err)
+		accessUnit, err := reader.next()
+		assert.NoError(t, err)
+		assert.Equal(t, append(append([]byte{}, sps...), idr...), accessUnit)
+		accessUnit, err = reader.next()
+		assert.NoError(t, err)
+		assert.Equal(t, slice, accessUnit)
+	})
+}
+
+func TestY4m(t *testing.T) {
+	t.Run("read raw images", func(t *testing.T) {
+		img := image.NewYCbCr(image.Rect(0, 0, 4, 2), image.YCbCrSubsampleRatio420)
+		for i := range img.Y {
+			img.Y[i] = byte(i)
+		}
+		img.Cb[0], img.Cb[1], img.Cr[0], img.Cr[1] = 10, 11, 20, 21
+
+		var buf bytes.Buffer
+		buf.WriteString("YUV4MPEG2 W4 H2 F30:1 Ip A1:1 C420jpeg XYSCSS=420JPEG\n")
+		for i := 0; i < 2; i++ {
+			buf.WriteString("FRAME\n")
+			assert.NoError(t, writeRawImage(&buf, img))
+		}
+
+		reader, err := newY4mReader(&buf)
+		assert.NoError(t, err)
+		for i := 0; i < 2; i++ {
+			decoded, err := reader.next()
+			assert.NoError(t, err)
+			assert.Equal(t, img.Rect, decoded.Rect)
+			assert.Equal(t, img.Y, decoded.Y)
+			assert.Equal(t, img.Cb, decoded.Cb)
+			assert.Equal(t, img.Cr, decoded.Cr)
+		}
+	})
+
+	t.Run("write image without stride padding", func(t *testing.T) {
+		img := image.NewYCbCr(image.Rect(0, 0, 4, 4), image.YCbCrSubsampleRatio420)
+		sub := img.SubImage(image.Rect(0, 0, 2, 2)).(*image.YCbCr)
+
+		var buf bytes.Buffer
+		assert.NoError(t, writeRawImage(&buf, sub))
+		assert.Equal(t, 2*2+2, buf.Len())
+	})
+
+	t.Run("reject other color spaces", func(t *testing.T) {
+		_, err := newY4mReader(bytes.NewBufferString("YUV4MPEG2 W4 H2 C444\n"))
+		assert.ErrorIs(t, err, errInvalidContainer)
+	})
+}
+
+func TestOgg(t *testing.T) {
+	t.Run("read packets of pages", func(t *testing.T) {
+		var buf bytes.Buffer
+		writer, _ := oggwriter.NewWith(&buf, 48000, 2)
+		long := bytes.Repeat([]byte{0x42}, 600)
+		assert.NoError(t, writer.WriteRTP(&pionRtp.Packet{Header: pionRtp.Header{Timestamp: 960}, Payload: []byte{0xfc, 0x01}}))
+		assert.NoError(t, writer.WriteRTP(&pionRtp.Packet{Header: pionRtp.Header{Timestamp: 1920}, Payload: long}))
+
+		reader := newOggReader(&buf)
+		packets := make([][]byte, 0)
+		for packet, err := reader.next(); err == nil; packet, err = reader.next() {
+			packets = append(packets, packet)
+		}
+		assert.Len(t, packets, 4)
+		assert.True(t, isOpusHeader(packets[0]))
+		assert.True(t, isOpusHeader(packets[1]))
+		assert.Equal(t, []byte{0xfc, 0x01}, packets[2])
+		assert.Equal(t, long, packets[3])
+	})
+}
+
+// testAdtsFrame writes an ADTS header of AAC-LC 48 kHz stereo in front of the frame
+func testAdtsFrame(frame []byte, protected bool) []byte {
+	headerSize := adtsHeaderSize
+	protection := byte(0x01)
+	if protected {
+		headerSize += 2
+		protection = 0x00
+	}
+	size := headerSize + len(frame)
+	header := []byte{0xFF, 0xF0 | protection, 0x4C, 0x80 | byte(size>>11)&0x03, byte(size >> 3), byte(size&0x07)<<5 | 0x1F, 0xFC}
+	if protected {
+		header = append(header, 0x00, 0x00)
+	}
+	return append(header, frame...)
+}
+
+func TestAdts(t *testing.T) {
+	t.Run("read raw frames", func(t *testing.T) {
+		var buf bytes.Buffer
+		buf.Write(testAdtsFrame([]byte{0x21, 0x42}, false))
+		buf.Write(testAdtsFrame([]byte{0x43}, true))
+
+		reader := newAdtsReader(&buf)
+		frame, err := reader.next()
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{0x21, 0x42}, frame)
+		frame, err = reader.next()
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{0x43}, frame)
+	})
+
+	t.Run("reject stream without sync word", func(t *testing.T) {
+		_, err := newAdtsReader(bytes.NewReader(make([]byte, adtsHeaderSize))).next()
+		assert.ErrorIs(t, err, errInvalidContainer)
+	})
+}
@@ -0,0 +1,83 @@
+package ffmpeg
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// Path of the ffmpeg binary, a path without directory is looked up in the PATH
+var Path = "ffmpeg"
+
+var ErrNotInstalled = errors.New("ffmpeg not installed")
+
+const closeTimeout = 2 * time.Second
+
+// Available checks that ffmpeg can be started
+func Available() error {
+	if _, err := exec.LookPath(Path); err != nil {
+		return fmt.Errorf("%w: %w", ErrNotInstalled, err)
+	}
+	return nil
+}
+
+// Process transcodes media with ffmpeg. The media is written to the standard input of ffmpeg
+// and the transcoded media is read from its standard output.
+type Process struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+}
+
+func Start(args ...string) (*Process, error) {
+	args = append([]string{"-hide_banner", "-loglevel", "error"}, args...)
+	cmd := exec.Command(Path, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("opening ffmpeg input: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("opening ffmpeg output: %w", err)
+	}
+	cmd.Stderr = logWriter{}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting ffmpeg: %w", err)
+	}
+	return &Process{cmd: cmd, stdin: stdin, stdout: stdout}, nil
+}
+
+func (p *Process) Write(b []byte) (int, error) {
+	return p.stdin.Write(b)
+}
+
+// Stdout returns the transcoded media, the reader ends when the process exits
+func (p *Process) Stdout() io.Reader {
+	return p.stdout
+}
+
+// Close ends the input, so that ffmpeg flushes its output and exits. A process that does not exit in time is killed.
+func (p *Process) Close() error {
+	_ = p.stdin.Close()
+	kill := time.AfterFunc(closeTimeout, func() {
+		_ = p.cmd.Process.Kill()
+	})
+	defer kill.Stop()
+	if err := p.cmd.Wait(); err != nil {
+		return fmt.Errorf("waiting for ffmpeg: %w", err)
+	}
+	return nil
+}
+
+// logWriter logs the errors ffmpeg writes to its standard error
+type logWriter struct{}
+
+func (w logWriter) Write(b []byte) (int, error) {
+	slog.Warn("ffmpeg.Process: " + strings.TrimSpace(string(b)))
+	return len(b), nil
+}
@@ -0,0 +1,45 @@
+package ffmpeg
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testProcessSetup replaces ffmpeg with a script, that copies its input to its output
+func testProcessSetup(t *testing.T) {
+	t.Helper()
+	script := filepath.Join(t.TempDir(), "ffmpeg")
+	_ = os.WriteFile(script, []byte("#!/bin/sh\nexec cat\n"), 0755)
+	path := Path
+	Path = script
+	t.Cleanup(func() { Path = path })
+}
+
+func TestProcess(t *testing.T) {
+	t.Run("pipe media through process", func(t *testing.T) {
+		testProcessSetup(t)
+		process, err := Start("-f", "ivf", "-i", "pipe:0", "pipe:1")
+		assert.NoError(t, err)
+
+		_, err = process.Write([]byte("media"))
+		assert.NoError(t, err)
+		out := make([]byte, 5)
+		_, err = io.ReadFull(process.Stdout(), out)
+		assert.NoError(t, err)
+		assert.Equal(t, "media", string(out))
+		assert.NoError(t, process.Close())
+	})
+
+	t.Run("report missing ffmpeg", func(t *testing.T) {
+		testProcessSetup(t)
+		Path = filepath.Join(t.TempDir(), "missing")
+
+		assert.ErrorIs(t, Available(), ErrNotInstalled)
+		_, err := Start()
+		assert.Error(t, err)
+	})
+}
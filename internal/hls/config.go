@@ -0,0 +1,33 @@
+package hls
+
+import "fmt"
+
+type HlsConfig struct {
+	Enable bool   `mapstructure:"enable"`
+	Dir    string `mapstructure:"dir"`
+	// target duration of a segment in seconds
+	SegmentDuration float64 `mapstructure:"segmentDuration"`
+	// target duration of a partial segment (LL-HLS) in seconds, 0 disables LL-HLS
+	PartDuration float64 `mapstructure:"partDuration"`
+	// number of segments in the playlist
+	PlaylistLength int `mapstructure:"playlistLength"`
+}
+
+func ValidateHlsConfig(config *HlsConfig) error {
+	if !config.Enable {
+		return nil
+	}
+	if len(config.Dir) == 0 {
+		return fmt.Errorf("hls.dir should not be empty")
+	}
+	if config.SegmentDuration <= 0 {
+		return fmt.Errorf("hls.segmentDuration should be greater than 0")
+	}
+	if config.PartDuration < 0 || config.PartDuration >= config.SegmentDuration {
+		return fmt.Errorf("hls.partDuration should be 0 or less than hls.segmentDuration")
+	}
+	if config.PlaylistLength < 3 {
+		return fmt.Errorf("hls.playlistLength should be at least 3")
+	}
+	return nil
+}
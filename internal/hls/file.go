@@ -0,0 +1,101 @@
+package hls
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const pollInterval = 50 * time.Millisecond
+
+var (
+	ErrInvalidFile       = errors.New("invalid hls file name")
+	fileNamePattern      = regexp.MustCompile(`^(index\.m3u8|init\d+\.mp4|segment\d+(\.\d+)?\.m4s)$`)
+	mediaSequencePattern = regexp.MustCompile(`#EXT-X-MEDIA-SEQUENCE:(\d+)`)
+)
+
+// FilePath returns the path of a playlist, init section or segment of a live stream
+func FilePath(config *HlsConfig, liveStreamId uuid.UUID, name string) (string, error) {
+	if !fileNamePattern.MatchString(name) {
+		return "", ErrInvalidFile
+	}
+	return filepath.Join(StreamDir(config, liveStreamId), name), nil
+}
+
+func IsPlaylist(name string) bool {
+	return name == playlistName
+}
+
+func ContentType(name string) string {
+	if IsPlaylist(name) {
+		return "application/vnd.apple.mpegurl"
+	}
+	return "video/mp4"
+}
+
+// WaitForPlaylist implements the blocking playlist reload of LL-HLS.
+// It returns the playlist as soon as it contains the requested segment (msn) or partial segment (part >= 0).
+// After three target durations the current playlist is returned.
+func WaitForPlaylist(ctx context.Context, config *HlsConfig, liveStreamId uuid.UUID, msn uint64, part int) ([]byte, error) {
+	path := filepath.Join(StreamDir(config, liveStreamId), playlistName)
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(3*config.SegmentDuration*float64(time.Second)))
+	defer cancel()
+	for {
+		playlist, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("reading playlist: %w", err)
+		}
+		if err == nil && containsMedia(playlist, msn, part) {
+			return playlist, nil
+		}
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return nil, err
+			}
+			return playlist, nil
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// WaitForFile blocks until a segment exists, a player requests the preload hint before it is written
+func WaitForFile(ctx context.Context, config *HlsConfig, path string) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(3*config.SegmentDuration*float64(time.Second)))
+	defer cancel()
+	for {
+		_, err := os.Stat(path)
+		if err == nil || !os.IsNotExist(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+func containsMedia(playlist []byte, msn uint64, part int) bool {
+	if match := mediaSequencePattern.FindSubmatch(playlist); match != nil {
+		if first, err := strconv.ParseUint(string(match[1]), 10, 64); err == nil && msn < first {
+			return true
+		}
+	}
+	// a later segment implies that the requested one is finished
+	if bytes.Contains(playlist, []byte(fmt.Sprintf("\"segment%d.", msn+1))) || bytes.Contains(playlist, []byte("\n"+segmentUri(msn+1)+"\n")) {
+		return true
+	}
+	if bytes.Contains(playlist, []byte("\n"+segmentUri(msn)+"\n")) {
+		return true
+	}
+	return part >= 0 && bytes.Contains(playlist, []byte("\""+partUri(msn, part)+"\""))
+}
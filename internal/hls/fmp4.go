@@ -0,0 +1,311 @@
+package hls
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+const (
+	videoTimescale = 90000
+	audioTimescale = 48000
+
+	sampleFlagsKeyframe    = 0x02000000 // sample_depends_on = 2 (does not depend on others)
+	sampleFlagsNonKeyframe = 0x01010000 // sample_depends_on = 1, sample_is_non_sync_sample = 1
+)
+
+var unityMatrix = []uint32{0x00010000, 0, 0, 0, 0x00010000, 0, 0, 0, 0x40000000}
+
+// mp4Track describes a track of the init segment
+type mp4Track struct {
+	id        uint32
+	isVideo   bool
+	timescale uint32
+	// video only
+	sps, pps      []byte
+	width, height uint16
+}
+
+// mp4Sample is one frame of a track
+type mp4Sample struct {
+	dts      uint64
+	duration uint32
+	keyframe bool
+	data     []byte
+}
+
+// mp4Fragment holds the samples of all tracks of one part (moof + mdat)
+type mp4Fragment struct {
+	track   *mp4Track
+	samples []*mp4Sample
+}
+
+// boxWriter writes ISO BMFF boxes (ISO/IEC 14496-12)
+type boxWriter struct {
+	bytes.Buffer
+}
+
+func (w *boxWriter) box(typ string, body func()) {
+	start := w.Len()
+	w.u32(0)
+	w.WriteString(typ)
+	body()
+	binary.BigEndian.PutUint32(w.Bytes()[start:], uint32(w.Len()-start))
+}
+
+func (w *boxWriter) fullBox(typ string, version uint8, flags uint32, body func()) {
+	w.box(typ, func() {
+		w.u32(uint32(version)<<24 | flags&0x00FFFFFF)
+		body()
+	})
+}
+
+func (w *boxWriter) u8(v uint8) { w.WriteByte(v) }
+
+func (w *boxWriter) u16(v uint16) {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], v)
+	w.Write(b[:])
+}
+
+func (w *boxWriter) u32(v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	w.Write(b[:])
+}
+
+func (w *boxWriter) u64(v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	w.Write(b[:])
+}
+
+func (w *boxWriter) zeros(n int) { w.Write(make([]byte, n)) }
+
+func (w *boxWriter) matrix() {
+	for _, v := range unityMatrix {
+		w.u32(v)
+	}
+}
+
+// marshalInitSegment creates the initialization section (ftyp + moov) of a fragmented mp4 stream
+func marshalInitSegment(tracks []*mp4Track) []byte {
+	w := &boxWriter{}
+	w.box("ftyp", func() {
+		w.WriteString("iso5")
+		w.u32(512)
+		w.WriteString("iso5iso6mp41")
+	})
+	w.box("moov", func() {
+		w.fullBox("mvhd", 0, 0, func() {
+			w.u32(0)    // creation time
+			w.u32(0)    // modification time
+			w.u32(1000) // timescale
+			w.u32(0)    // duration
+			w.u32(0x00010000)
+			w.u16(0x0100)
+			w.zeros(10)
+			w.matrix()
+			w.zeros(24)
+			w.u32(uint32(len(tracks) + 1)) // next track id
+		})
+		for _, track := range tracks {
+			writeTrak(w, track)
+		}
+		w.box("mvex", func() {
+			for _, track := range tracks {
+				w.fullBox("trex", 0, 0, func() {
+					w.u32(track.id)
+					w.u32(1) // sample description index
+					w.u32(0)
+					w.u32(0)
+					w.u32(0)
+				})
+			}
+		})
+	})
+	return w.Bytes()
+}
+
+func writeTrak(w *boxWriter, track *mp4Track) {
+	w.box("trak", func() {
+		w.fullBox("tkhd", 0, 3, func() {
+			w.u32(0) // creation time
+			w.u32(0) // modification time
+			w.u32(track.id)
+			w.u32(0)
+			w.u32(0) // duration
+			w.zeros(8)
+			w.u16(0) // layer
+			w.u16(0) // alternate group
+			if track.isVideo {
+				w.u16(0)
+			} else {
+				w.u16(0x0100)
+			}
+			w.u16(0)
+			w.matrix()
+			w.u32(uint32(track.width) << 16)
+			w.u32(uint32(track.height) << 16)
+		})
+		w.box("mdia", func() {
+			w.fullBox("mdhd", 0, 0, func() {
+				w.u32(0)
+				w.u32(0)
+				w.u32(track.timescale)
+				w.u32(0)
+				w.u16(0x55C4) // language: und
+				w.u16(0)
+			})
+			w.fullBox("hdlr", 0, 0, func() {
+				w.u32(0)
+				if track.isVideo {
+					w.WriteString("vide")
+				} else {
+					w.WriteString("soun")
+				}
+				w.zeros(12)
+				if track.isVideo {
+					w.WriteString("VideoHandler\x00")
+				} else {
+					w.WriteString("SoundHandler\x00")
+				}
+			})
+			w.box("minf", func() {
+				if track.isVideo {
+					w.fullBox("vmhd", 0, 1, func() {
+						w.zeros(8)
+					})
+				} else {
+					w.fullBox("smhd", 0, 0, func() {
+						w.zeros(4)
+					})
+				}
+				w.box("dinf", func() {
+					w.fullBox("dref", 0, 0, func() {
+						w.u32(1)
+						w.fullBox("url ", 0, 1, func() {})
+					})
+				})
+				w.box("stbl", func() {
+					w.fullBox("stsd", 0, 0, func() {
+						w.u32(1)
+						if track.isVideo {
+							writeAvc1(w, track)
+						} else {
+							writeOpus(w)
+						}
+					})
+					w.fullBox("stts", 0, 0, func() { w.u32(0) })
+					w.fullBox("stsc", 0, 0, func() { w.u32(0) })
+					w.fullBox("stsz", 0, 0, func() { w.u32(0); w.u32(0) })
+					w.fullBox("stco", 0, 0, func() { w.u32(0) })
+				})
+			})
+		})
+	})
+}
+
+func writeAvc1(w *boxWriter, track *mp4Track) {
+	w.box("avc1", func() {
+		w.zeros(6)
+		w.u16(1) // data reference index
+		w.zeros(16)
+		w.u16(track.width)
+		w.u16(track.height)
+		w.u32(0x00480000) // 72 dpi
+		w.u32(0x00480000)
+		w.u32(0)
+		w.u16(1) // frame count
+		w.zeros(32)
+		w.u16(0x0018)
+		w.u16(0xFFFF)
+		w.box("avcC", func() {
+			w.u8(1)
+			w.u8(track.sps[1]) // profile
+			w.u8(track.sps[2]) // profile compatibility
+			w.u8(track.sps[3]) // level
+			w.u8(0xFF)         // 4 bytes nalu length
+			w.u8(0xE1)         // 1 sps
+			w.u16(uint16(len(track.sps)))
+			w.Write(track.sps)
+			w.u8(1) // 1 pps
+			w.u16(uint16(len(track.pps)))
+			w.Write(track.pps)
+		})
+	})
+}
+
+// writeOpus writes the sample entry of "Encapsulation of Opus in ISO Base Media File Format"
+func writeOpus(w *boxWriter) {
+	w.box("Opus", func() {
+		w.zeros(6)
+		w.u16(1) // data reference index
+		w.zeros(8)
+		w.u16(2)  // channels
+		w.u16(16) // sample size
+		w.u16(0)
+		w.u16(0)
+		w.u32(audioTimescale << 16)
+		w.box("dOps", func() {
+			w.u8(0)
+			w.u8(2)    // output channels
+			w.u16(312) // pre skip
+			w.u32(audioTimescale)
+			w.u16(0) // output gain
+			w.u8(0)  // channel mapping family
+		})
+	})
+}
+
+// marshalFragment creates a movie fragment (moof + mdat) with the samples of all tracks
+func marshalFragment(sequence uint32, fragments []*mp4Fragment) []byte {
+	// the moof size does not depend on the data offsets, so we write it twice
+	moof := marshalMoof(sequence, fragments, 0)
+	moof = marshalMoof(sequence, fragments, uint32(len(moof)))
+
+	w := &boxWriter{}
+	w.Write(moof)
+	w.box("mdat", func() {
+		for _, fragment := range fragments {
+			for _, sample := range fragment.samples {
+				w.Write(sample.data)
+			}
+		}
+	})
+	return w.Bytes()
+}
+
+func marshalMoof(sequence uint32, fragments []*mp4Fragment, moofSize uint32) []byte {
+	w := &boxWriter{}
+	dataOffset := moofSize + 8 // mdat header
+	w.box("moof", func() {
+		w.fullBox("mfhd", 0, 0, func() {
+			w.u32(sequence)
+		})
+		for _, fragment := range fragments {
+			w.box("traf", func() {
+				w.fullBox("tfhd", 0, 0x020000, func() { // default base is moof
+					w.u32(fragment.track.id)
+				})
+				w.fullBox("tfdt", 1, 0, func() {
+					w.u64(fragment.samples[0].dts)
+				})
+				w.fullBox("trun", 0, 0x000701, func() { // data offset, duration, size, flags
+					w.u32(uint32(len(fragment.samples)))
+					w.u32(dataOffset)
+					for _, sample := range fragment.samples {
+						w.u32(sample.duration)
+						w.u32(uint32(len(sample.data)))
+						if sample.keyframe {
+							w.u32(sampleFlagsKeyframe)
+						} else {
+							w.u32(sampleFlagsNonKeyframe)
+						}
+						dataOffset += uint32(len(sample.data))
+					}
+				})
+			})
+		}
+	})
+	return w.Bytes()
+}
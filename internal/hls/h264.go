@@ -0,0 +1,259 @@
+package hls
+
+import (
+	"errors"
+)
+
+const (
+	naluTypeIdr = 5
+	naluTypeSps = 7
+	naluTypePps = 8
+	naluTypeAud = 9
+)
+
+var errInvalidSps = errors.New("invalid sps")
+
+// bitReader reads the exp-Golomb coded fields of a h264 parameter set
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) bit() (uint32, error) {
+	if r.pos >= len(r.data)*8 {
+		return 0, errInvalidSps
+	}
+	b := (r.data[r.pos/8] >> (7 - r.pos%8)) & 1
+	r.pos++
+	return uint32(b), nil
+}
+
+func (r *bitReader) bits(n int) (uint32, error) {
+	var v uint32
+	for i := 0; i < n; i++ {
+		b, err := r.bit()
+		if err != nil {
+			return 0, err
+		}
+		v = v<<1 | b
+	}
+	return v, nil
+}
+
+func (r *bitReader) ue() (uint32, error) {
+	zeros := 0
+	for {
+		b, err := r.bit()
+		if err != nil {
+			return 0, err
+		}
+		if b == 1 {
+			break
+		}
+		zeros++
+		if zeros > 31 {
+			return 0, errInvalidSps
+		}
+	}
+	v, err := r.bits(zeros)
+	if err != nil {
+		return 0, err
+	}
+	return (1<<zeros - 1) + v, nil
+}
+
+func (r *bitReader) se() (int32, error) {
+	v, err := r.ue()
+	if err != nil {
+		return 0, err
+	}
+	if v&1 == 1 {
+		return int32((v + 1) / 2), nil
+	}
+	return -int32(v / 2), nil
+}
+
+// removeEmulationPrevention converts the NAL unit payload to the raw byte sequence payload
+func removeEmulationPrevention(nalu []byte) []byte {
+	rbsp := make([]byte, 0, len(nalu))
+	zeros := 0
+	for _, b := range nalu {
+		if zeros >= 2 && b == 3 {
+			zeros = 0
+			continue
+		}
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		rbsp = append(rbsp, b)
+	}
+	return rbsp
+}
+
+// parseSpsResolution reads the picture size of a sequence parameter set (ITU-T H.264 7.3.2.1.1)
+func parseSpsResolution(sps []byte) (uint16, uint16, error) {
+	if len(sps) < 4 {
+		return 0, 0, errInvalidSps
+	}
+	r := &bitReader{data: removeEmulationPrevention(sps[1:])}
+	profile, _ := r.bits(8)
+	if _, err := r.bits(16); err != nil { // constraint flags and level
+		return 0, 0, err
+	}
+	if _, err := r.ue(); err != nil { // seq_parameter_set_id
+		return 0, 0, err
+	}
+
+	chromaFormat := uint32(1)
+	switch profile {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		var err error
+		if chromaFormat, err = r.ue(); err != nil {
+			return 0, 0, err
+		}
+		if chromaFormat == 3 {
+			if _, err = r.bit(); err != nil { // separate_colour_plane_flag
+				return 0, 0, err
+			}
+		}
+		if _, err = r.ue(); err != nil { // bit_depth_luma
+			return 0, 0, err
+		}
+		if _, err = r.ue(); err != nil { // bit_depth_chroma
+			return 0, 0, err
+		}
+		if _, err = r.bit(); err != nil { // qpprime_y_zero_transform_bypass_flag
+			return 0, 0, err
+		}
+		scalingMatrix, err := r.bit()
+		if err != nil {
+			return 0, 0, err
+		}
+		if scalingMatrix == 1 {
+			lists := 8
+			if chromaFormat == 3 {
+				lists = 12
+			}
+			for i := 0; i < lists; i++ {
+				present, err := r.bit()
+				if err != nil {
+					return 0, 0, err
+				}
+				if present == 0 {
+					continue
+				}
+				size := 16
+				if i >= 6 {
+					size = 64
+				}
+				if err := skipScalingList(r, size); err != nil {
+					return 0, 0, err
+				}
+			}
+		}
+	}
+
+	if _, err := r.ue(); err != nil { // log2_max_frame_num_minus4
+		return 0, 0, err
+	}
+	pocType, err := r.ue()
+	if err != nil {
+		return 0, 0, err
+	}
+	switch pocType {
+	case 0:
+		if _, err := r.ue(); err != nil {
+			return 0, 0, err
+		}
+	case 1:
+		if _, err := r.bit(); err != nil {
+			return 0, 0, err
+		}
+		if _, err := r.se(); err != nil {
+			return 0, 0, err
+		}
+		if _, err := r.se(); err != nil {
+			return 0, 0, err
+		}
+		cycle, err := r.ue()
+		if err != nil {
+			return 0, 0, err
+		}
+		for i := uint32(0); i < cycle; i++ {
+			if _, err := r.se(); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+	if _, err := r.ue(); err != nil { // max_num_ref_frames
+		return 0, 0, err
+	}
+	if _, err := r.bit(); err != nil { // gaps_in_frame_num_value_allowed_flag
+		return 0, 0, err
+	}
+	widthInMbs, err := r.ue()
+	if err != nil {
+		return 0, 0, err
+	}
+	heightInMapUnits, err := r.ue()
+	if err != nil {
+		return 0, 0, err
+	}
+	frameMbsOnly, err := r.bit()
+	if err != nil {
+		return 0, 0, err
+	}
+	if frameMbsOnly == 0 {
+		if _, err := r.bit(); err != nil { // mb_adaptive_frame_field_flag
+			return 0, 0, err
+		}
+	}
+	if _, err := r.bit(); err != nil { // direct_8x8_inference_flag
+		return 0, 0, err
+	}
+
+	width := (widthInMbs + 1) * 16
+	height := (2 - frameMbsOnly) * (heightInMapUnits + 1) * 16
+
+	cropping, err := r.bit()
+	if err != nil {
+		return 0, 0, err
+	}
+	if cropping == 1 {
+		var crop [4]uint32
+		for i := range crop {
+			if crop[i], err = r.ue(); err != nil {
+				return 0, 0, err
+			}
+		}
+		cropUnitX, cropUnitY := uint32(1), 2-frameMbsOnly
+		if chromaFormat == 1 || chromaFormat == 2 {
+			cropUnitX = 2
+		}
+		if chromaFormat == 1 {
+			cropUnitY *= 2
+		}
+		width -= (crop[0] + crop[1]) * cropUnitX
+		height -= (crop[2] + crop[3]) * cropUnitY
+	}
+	return uint16(width), uint16(height), nil
+}
+
+func skipScalingList(r *bitReader, size int) error {
+	last, next := int32(8), int32(8)
+	for j := 0; j < size; j++ {
+		if next != 0 {
+			delta, err := r.se()
+			if err != nil {
+				return err
+			}
+			next = (last + delta + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+	return nil
+}
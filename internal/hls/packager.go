@@ -0,0 +1,129 @@
+package hls
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/pion/webrtc/v3"
+	"github.com/shigde/sfu/internal/rtp"
+	"golang.org/x/exp/slog"
+)
+
+// Packager writes the main stream of a lobby as HLS (fMP4 segments and a rolling playlist).
+// The segments start at H264 keyframes and carry the Opus audio unchanged, other codecs are not packaged.
+type Packager struct {
+	mu          sync.Mutex
+	ctx         context.Context
+	id          uuid.UUID
+	dir         string
+	segmenter   *segmenter
+	tracks      map[uuid.UUID]*packagerBinding
+	nextTrackId uint32
+}
+
+type packagerBinding struct {
+	info    *rtp.TrackInfo
+	track   *packagerTrack
+	context *rtp.TrackLocalContext
+}
+
+func NewPackager(ctx context.Context, config *HlsConfig, liveStreamId uuid.UUID) (*Packager, error) {
+	dir := StreamDir(config, liveStreamId)
+	// remove the segments of an old lobby of the stream
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, fmt.Errorf("removing old hls dir: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating hls dir: %w", err)
+	}
+
+	packager := &Packager{
+		ctx:    ctx,
+		id:     liveStreamId,
+		dir:    dir,
+		tracks: make(map[uuid.UUID]*packagerBinding),
+	}
+	packager.segmenter = newSegmenter(dir, config, packager.requestKeyframes)
+	go packager.run()
+	return packager, nil
+}
+
+func (p *Packager) run() {
+	<-p.ctx.Done()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id, binding := range p.tracks {
+		p.unbind(binding)
+		delete(p.tracks, id)
+	}
+	p.segmenter.close()
+	if err := os.RemoveAll(p.dir); err != nil {
+		slog.Error("hls.Packager: removing hls dir", "liveStreamId", p.id, "err", err)
+	}
+	slog.Info("hls.Packager: stopped", "liveStreamId", p.id)
+}
+
+func (p *Packager) AddTrack(info *rtp.TrackInfo) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ctx.Err() != nil {
+		return
+	}
+	if _, found := p.tracks[info.GetId()]; found {
+		return
+	}
+
+	p.nextTrackId++
+	track := newPackagerTrack(p.nextTrackId, info.GetTrackLocal().Kind(), p.segmenter)
+	binding := &packagerBinding{
+		info:    info,
+		track:   track,
+		context: rtp.NewTrackLocalContext(track.bindingId, webrtc.SSRC(p.nextTrackId), supportedCodecs, track),
+	}
+	if _, err := info.GetTrackLocal().Bind(binding.context); err != nil {
+		slog.Warn("hls.Packager: track can not be packaged", "liveStreamId", p.id, "track", info.GetTrackLocal().ID(), "kind", info.GetTrackLocal().Kind(), "err", err)
+		return
+	}
+	p.tracks[info.GetId()] = binding
+	p.segmenter.addTrack(track.mp4)
+	slog.Debug("hls.Packager: add track", "liveStreamId", p.id, "track", info.GetTrackLocal().ID(), "kind", info.GetTrackLocal().Kind())
+}
+
+func (p *Packager) RemoveTrack(info *rtp.TrackInfo) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	binding, found := p.tracks[info.GetId()]
+	if !found {
+		return
+	}
+	delete(p.tracks, info.GetId())
+	p.unbind(binding)
+	p.segmenter.removeTrack(binding.track.mp4)
+	slog.Debug("hls.Packager: remove track", "liveStreamId", p.id, "track", info.GetTrackLocal().ID(), "kind", info.GetTrackLocal().Kind())
+}
+
+func (p *Packager) unbind(binding *packagerBinding) {
+	if err := binding.info.GetTrackLocal().Unbind(binding.context); err != nil {
+		slog.Warn("hls.Packager: unbind track", "liveStreamId", p.id, "err", err)
+	}
+}
+
+// requestKeyframes asks the publishers for keyframes, to start a stream or a segment
+func (p *Packager) requestKeyframes() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, binding := range p.tracks {
+		if binding.track.mp4.isVideo {
+			binding.info.RequestKeyframe()
+		}
+	}
+}
+
+// StreamDir is the directory of the segments and playlist of a live stream
+func StreamDir(config *HlsConfig, liveStreamId uuid.UUID) string {
+	return filepath.Join(config.Dir, liveStreamId.String())
+}
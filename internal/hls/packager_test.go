@@ -0,0 +1,142 @@
+package hls
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	pionRtp "github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/shigde/sfu/internal/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testSps      = []byte{0x67, 0x42, 0x00, 0x1e, 0x95, 0xa8, 0x28, 0x0f, 0x64}
+	testPps      = []byte{0x68, 0xce, 0x3c, 0x80}
+	testIdrSlice = []byte{0x65, 0x88, 0x84, 0x00, 0x33}
+	testSlice    = []byte{0x41, 0x9a, 0x02, 0x03, 0x04}
+)
+
+func testPackagerSetup(t *testing.T, partDuration float64) (*Packager, *webrtc.TrackLocalStaticRTP, string, func()) {
+	t.Helper()
+	config := &HlsConfig{Enable: true, Dir: t.TempDir(), SegmentDuration: 1, PartDuration: partDuration, PlaylistLength: 3}
+	ctx, cancel := context.WithCancel(context.Background())
+	liveStreamId := uuid.New()
+	packager, err := NewPackager(ctx, config, liveStreamId)
+	assert.NoError(t, err)
+
+	codec := webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264, ClockRate: videoTimescale}
+	track, err := webrtc.NewTrackLocalStaticRTP(codec, "video", "stream")
+	assert.NoError(t, err)
+	packager.AddTrack(&rtp.TrackInfo{TrackSdpInfo: rtp.TrackSdpInfo{Id: uuid.New()}, Track: track})
+	return packager, track, StreamDir(config, liveStreamId), cancel
+}
+
+// testWriteH264 writes frames with 30 fps and a keyframe every half second
+func testWriteH264(t *testing.T, track *webrtc.TrackLocalStaticRTP, frames int) {
+	t.Helper()
+	seq := uint16(0)
+	write := func(ts uint32, marker bool, payload []byte) {
+		seq++
+		err := track.WriteRTP(&pionRtp.Packet{
+			Header:  pionRtp.Header{Version: 2, SequenceNumber: seq, Timestamp: ts, Marker: marker},
+			Payload: payload,
+		})
+		assert.NoError(t, err)
+	}
+	for i := 0; i < frames; i++ {
+		ts := uint32(1000 + i*3000)
+		if i%15 == 0 {
+			write(ts, false, testSps)
+			write(ts, false, testPps)
+			write(ts, true, testIdrSlice)
+			continue
+		}
+		write(ts, true, testSlice)
+	}
+}
+
+// testWaitForFile waits for the file writer of the segmenter
+func testWaitForFile(t *testing.T, dir string, name string) {
+	t.Helper()
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+// testWaitForPlaylist waits for a playlist that contains the text
+func testWaitForPlaylist(t *testing.T, dir string, text string) string {
+	t.Helper()
+	var playlist []byte
+	assert.Eventually(t, func() bool {
+		playlist, _ = os.ReadFile(filepath.Join(dir, playlistName))
+		return bytes.Contains(playlist, []byte(text))
+	}, time.Second, 10*time.Millisecond)
+	return string(playlist)
+}
+
+func TestPackager(t *testing.T) {
+	t.Run("write init section, segments and playlist", func(t *testing.T) {
+		_, track, dir, cancel := testPackagerSetup(t, 0)
+		defer cancel()
+		testWriteH264(t, track, 100)
+		testWaitForFile(t, dir, "segment1.m4s")
+
+		init, err := os.ReadFile(filepath.Join(dir, "init1.mp4"))
+		assert.NoError(t, err)
+		assert.Equal(t, "ftyp", string(init[4:8]))
+
+		segment, err := os.ReadFile(filepath.Join(dir, "segment0.m4s"))
+		assert.NoError(t, err)
+		assert.Equal(t, "moof", string(segment[4:8]))
+
+		playlist := testWaitForPlaylist(t, dir, "segment1.m4s")
+		assert.Contains(t, string(playlist), "#EXT-X-MAP:URI=\"init1.mp4\"")
+		assert.Contains(t, string(playlist), "#EXT-X-MEDIA-SEQUENCE:0")
+		assert.Contains(t, string(playlist), "#EXTINF:1.00000,\nsegment0.m4s")
+		assert.NotContains(t, string(playlist), "#EXT-X-PART")
+	})
+
+	t.Run("roll playlist window", func(t *testing.T) {
+		_, track, dir, cancel := testPackagerSetup(t, 0)
+		defer cancel()
+		testWriteH264(t, track, 200)
+
+		playlist := testWaitForPlaylist(t, dir, "#EXT-X-MEDIA-SEQUENCE:3")
+		assert.Contains(t, string(playlist), "#EXT-X-MEDIA-SEQUENCE:3")
+		assert.Eventually(t, func() bool {
+			_, err := os.Stat(filepath.Join(dir, "segment0.m4s"))
+			return os.IsNotExist(err)
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("write partial segments for low latency", func(t *testing.T) {
+		_, track, dir, cancel := testPackagerSetup(t, 0.2)
+		defer cancel()
+		testWriteH264(t, track, 50)
+
+		playlist := testWaitForPlaylist(t, dir, "URI=\"segment0.0.m4s\"")
+		assert.Contains(t, string(playlist), "#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES")
+		assert.Contains(t, string(playlist), "#EXT-X-PART:DURATION=0.20000,URI=\"segment0.0.m4s\",INDEPENDENT=YES")
+		assert.Contains(t, string(playlist), "#EXT-X-PRELOAD-HINT:TYPE=PART")
+		_, err := os.Stat(filepath.Join(dir, "segment0.0.m4s"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("remove stream dir when lobby ends", func(t *testing.T) {
+		_, track, dir, cancel := testPackagerSetup(t, 0)
+		testWriteH264(t, track, 50)
+		cancel()
+
+		assert.Eventually(t, func() bool {
+			_, err := os.Stat(dir)
+			return os.IsNotExist(err)
+		}, time.Second, 10*time.Millisecond)
+	})
+}
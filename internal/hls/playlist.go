@@ -0,0 +1,164 @@
+package hls
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const playlistName = "index.m3u8"
+
+// number of segments the partial segments are kept for, the spec requires at least the last three target durations
+const partSegments = 3
+
+type playlistPart struct {
+	uri         string
+	duration    float64
+	independent bool
+}
+
+type playlistSegment struct {
+	msn           uint64
+	uri           string
+	initUri       string
+	duration      float64
+	discontinuity bool
+	parts         []*playlistPart
+}
+
+// playlist is the rolling media playlist of a stream (RFC 8216 and draft-pantos-hls-rfc8216bis for LL-HLS)
+type playlist struct {
+	length                int
+	targetDuration        float64
+	partTarget            float64
+	segments              []*playlistSegment
+	discontinuitySequence uint64
+	// parts of the segment in progress
+	current *playlistSegment
+	// uri of the next part
+	preloadHint string
+}
+
+func newPlaylist(config *HlsConfig) *playlist {
+	return &playlist{
+		length:         config.PlaylistLength,
+		targetDuration: config.SegmentDuration,
+		partTarget:     config.PartDuration,
+	}
+}
+
+func (p *playlist) isLowLatency() bool {
+	return p.partTarget > 0
+}
+
+// addSegment appends a finished segment and returns the segments which fell out of the window
+func (p *playlist) addSegment(segment *playlistSegment) []*playlistSegment {
+	p.segments = append(p.segments, segment)
+	p.current = nil
+	if segment.duration > p.targetDuration {
+		p.targetDuration = segment.duration
+	}
+
+	var removed []*playlistSegment
+	for len(p.segments) > p.length {
+		if p.segments[0].discontinuity {
+			p.discontinuitySequence++
+		}
+		removed = append(removed, p.segments[0])
+		p.segments = p.segments[1:]
+	}
+	return removed
+}
+
+// expiredParts returns the segment of which the partial segments are not listed anymore
+func (p *playlist) expiredParts() *playlistSegment {
+	if len(p.segments) <= partSegments {
+		return nil
+	}
+	return p.segments[len(p.segments)-partSegments-1]
+}
+
+func (p *playlist) initUris() map[string]bool {
+	uris := make(map[string]bool)
+	for _, segment := range p.segments {
+		uris[segment.initUri] = true
+	}
+	if p.current != nil {
+		uris[p.current.initUri] = true
+	}
+	return uris
+}
+
+func (p *playlist) marshal() []byte {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	if p.isLowLatency() {
+		b.WriteString("#EXT-X-VERSION:9\n")
+	} else {
+		b.WriteString("#EXT-X-VERSION:7\n")
+	}
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", int(math.Ceil(p.targetDuration)))
+	if p.isLowLatency() {
+		fmt.Fprintf(&b, "#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES,PART-HOLD-BACK=%.3f\n", 3*p.partTarget)
+		fmt.Fprintf(&b, "#EXT-X-PART-INF:PART-TARGET=%.3f\n", p.partTarget)
+	}
+
+	msn := uint64(0)
+	if len(p.segments) > 0 {
+		msn = p.segments[0].msn
+	} else if p.current != nil {
+		msn = p.current.msn
+	}
+	fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", msn)
+	fmt.Fprintf(&b, "#EXT-X-DISCONTINUITY-SEQUENCE:%d\n", p.discontinuitySequence)
+
+	mapUri := ""
+	withParts := len(p.segments) - partSegments
+	for i, segment := range p.segments {
+		marshalSegmentHeader(&b, segment, &mapUri)
+		if p.isLowLatency() && i >= withParts {
+			marshalParts(&b, segment.parts)
+		}
+		fmt.Fprintf(&b, "#EXTINF:%.5f,\n%s\n", segment.duration, segment.uri)
+	}
+	if p.isLowLatency() && p.current != nil {
+		marshalSegmentHeader(&b, p.current, &mapUri)
+		marshalParts(&b, p.current.parts)
+	}
+	if p.isLowLatency() && p.preloadHint != "" {
+		fmt.Fprintf(&b, "#EXT-X-PRELOAD-HINT:TYPE=PART,URI=\"%s\"\n", p.preloadHint)
+	}
+	return []byte(b.String())
+}
+
+func marshalSegmentHeader(b *strings.Builder, segment *playlistSegment, mapUri *string) {
+	if segment.discontinuity {
+		b.WriteString("#EXT-X-DISCONTINUITY\n")
+	}
+	if segment.initUri != *mapUri {
+		fmt.Fprintf(b, "#EXT-X-MAP:URI=\"%s\"\n", segment.initUri)
+		*mapUri = segment.initUri
+	}
+}
+
+func marshalParts(b *strings.Builder, parts []*playlistPart) {
+	for _, part := range parts {
+		fmt.Fprintf(b, "#EXT-X-PART:DURATION=%.5f,URI=\"%s\"", part.duration, part.uri)
+		if part.independent {
+			b.WriteString(",INDEPENDENT=YES")
+		}
+		b.WriteString("\n")
+	}
+}
+
+func segmentUri(msn uint64) string {
+	return fmt.Sprintf("segment%d.m4s", msn)
+}
+
+func partUri(msn uint64, part int) string {
+	return fmt.Sprintf("segment%d.%d.m4s", msn, part)
+}
+
+func initUri(n int) string {
+	return fmt.Sprintf("init%d.mp4", n)
+}
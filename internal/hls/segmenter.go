@@ -0,0 +1,318 @@
+package hls
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// segmenter collects the samples of all tracks of a stream into parts and segments and maintains the playlist.
+// Segments always start with a keyframe of the first video track. If there is no video track, the audio track leads.
+// Each change of the tracks or the video parameter sets starts a new init section after a discontinuity.
+type segmenter struct {
+	mu               sync.Mutex
+	files            *fileWriter
+	config           *HlsConfig
+	startedAt        time.Time
+	tracks           []*mp4Track
+	playlist         *playlist
+	onKeyframeNeeded func()
+
+	running       bool
+	initCount     int
+	discontinuity bool
+	msn           uint64
+	sequence      uint32
+	// decode times of the lead track
+	segmentStart    uint64
+	partStart       uint64
+	leadDts         uint64
+	keyframeRequest uint64
+	partIndependent bool
+	pending         map[*mp4Track]*mp4Sample
+	partSamples     map[*mp4Track][]*mp4Sample
+	segmentData     bytes.Buffer
+	segmentParts    []*playlistPart
+}
+
+func newSegmenter(dir string, config *HlsConfig, onKeyframeNeeded func()) *segmenter {
+	return &segmenter{
+		files:            newFileWriter(dir),
+		config:           config,
+		startedAt:        time.Now(),
+		playlist:         newPlaylist(config),
+		onKeyframeNeeded: onKeyframeNeeded,
+		pending:          make(map[*mp4Track]*mp4Sample),
+		partSamples:      make(map[*mp4Track][]*mp4Sample),
+	}
+}
+
+func (s *segmenter) addTrack(track *mp4Track) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.restart()
+	s.tracks = append(s.tracks, track)
+}
+
+func (s *segmenter) removeTrack(track *mp4Track) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, t := range s.tracks {
+		if t == track {
+			s.restart()
+			s.tracks = append(s.tracks[:i], s.tracks[i+1:]...)
+			return
+		}
+	}
+}
+
+// setParameterSets updates the sps or pps of a video track
+func (s *segmenter) setParameterSets(track *mp4Track, sps []byte, pps []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := false
+	if sps != nil && !bytes.Equal(sps, track.sps) {
+		changed = track.sps != nil
+		track.sps = append([]byte(nil), sps...)
+		width, height, err := parseSpsResolution(track.sps)
+		if err != nil {
+			slog.Warn("hls.segmenter: parse sps", "err", err)
+		}
+		track.width, track.height = width, height
+	}
+	if pps != nil && !bytes.Equal(pps, track.pps) {
+		changed = changed || track.pps != nil
+		track.pps = append([]byte(nil), pps...)
+	}
+	// The publisher switched the resolution (or the simulcast layer changed), the players need a new init section.
+	if changed && s.running && s.hasTrack(track) {
+		s.restart()
+	}
+}
+
+func (s *segmenter) writeSample(track *mp4Track, sample *mp4Sample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.files == nil || !s.hasTrack(track) {
+		return
+	}
+	lead := s.leadTrack()
+	if !s.running {
+		if track != lead || !sample.keyframe || !s.ready() {
+			return
+		}
+		s.start(sample)
+	}
+
+	if prev, ok := s.pending[track]; ok {
+		if sample.dts <= prev.dts {
+			// late packet
+			return
+		}
+		prev.duration = uint32(sample.dts - prev.dts)
+		s.partSamples[track] = append(s.partSamples[track], prev)
+	}
+	s.pending[track] = sample
+	if track != lead {
+		return
+	}
+
+	s.leadDts = sample.dts
+	segmentDuration := s.ticks(s.config.SegmentDuration)
+	switch {
+	case sample.keyframe && s.leadDts-s.segmentStart >= segmentDuration:
+		s.flushPart()
+		s.finishSegment()
+		s.segmentStart = s.leadDts
+		s.partStart = s.leadDts
+		s.partIndependent = true
+	case s.playlist.isLowLatency() && s.leadDts-s.partStart >= s.ticks(s.config.PartDuration):
+		s.flushPart()
+		s.partStart = s.leadDts
+		s.partIndependent = sample.keyframe
+	}
+
+	// Without rtcp feedback of the publisher's own viewers, the keyframes can be far apart.
+	if s.leadDts-s.segmentStart >= 2*segmentDuration && s.leadDts-s.keyframeRequest >= segmentDuration {
+		s.keyframeRequest = s.leadDts
+		s.requestKeyframe()
+	}
+}
+
+// start writes a new init section, the caller must hold the lock
+func (s *segmenter) start(sample *mp4Sample) {
+	s.initCount++
+	s.writeFile(initUri(s.initCount), marshalInitSegment(s.tracks))
+	s.running = true
+	s.leadDts = sample.dts
+	s.segmentStart = sample.dts
+	s.partStart = sample.dts
+	s.keyframeRequest = sample.dts
+	s.partIndependent = true
+}
+
+// restart closes the current segment and waits for the next keyframe to start with a new init section.
+// The caller must hold the lock.
+func (s *segmenter) restart() {
+	if s.running {
+		s.flushPart()
+		s.finishSegment()
+		s.discontinuity = true
+	}
+	s.running = false
+	s.pending = make(map[*mp4Track]*mp4Sample)
+	s.partSamples = make(map[*mp4Track][]*mp4Sample)
+	s.requestKeyframe()
+}
+
+func (s *segmenter) requestKeyframe() {
+	if s.onKeyframeNeeded != nil {
+		go s.onKeyframeNeeded()
+	}
+}
+
+func (s *segmenter) flushPart() {
+	fragments := make([]*mp4Fragment, 0, len(s.tracks))
+	for _, track := range s.tracks {
+		if samples := s.partSamples[track]; len(samples) > 0 {
+			fragments = append(fragments, &mp4Fragment{track: track, samples: samples})
+		}
+	}
+	s.partSamples = make(map[*mp4Track][]*mp4Sample)
+	if len(fragments) == 0 {
+		return
+	}
+
+	s.sequence++
+	data := marshalFragment(s.sequence, fragments)
+	s.segmentData.Write(data)
+	if !s.playlist.isLowLatency() {
+		return
+	}
+
+	uri := partUri(s.msn, len(s.segmentParts))
+	s.writeFile(uri, data)
+	s.segmentParts = append(s.segmentParts, &playlistPart{
+		uri:         uri,
+		duration:    s.seconds(s.leadDts - s.partStart),
+		independent: s.partIndependent,
+	})
+	s.playlist.current = s.currentSegment()
+	s.playlist.preloadHint = partUri(s.msn, len(s.segmentParts))
+	s.writeFile(playlistName, s.playlist.marshal())
+}
+
+func (s *segmenter) finishSegment() {
+	if s.segmentData.Len() == 0 {
+		return
+	}
+	segment := s.currentSegment()
+	segment.duration = s.seconds(s.leadDts - s.segmentStart)
+	s.writeFile(segment.uri, bytes.Clone(s.segmentData.Bytes()))
+
+	for _, removed := range s.playlist.addSegment(segment) {
+		s.removeFile(removed.uri)
+		s.removeParts(removed)
+	}
+	if expired := s.playlist.expiredParts(); expired != nil {
+		s.removeParts(expired)
+	}
+	s.removeUnusedInits()
+
+	s.msn++
+	s.segmentData.Reset()
+	s.segmentParts = nil
+	s.discontinuity = false
+	s.playlist.preloadHint = partUri(s.msn, 0)
+	s.writeFile(playlistName, s.playlist.marshal())
+}
+
+func (s *segmenter) currentSegment() *playlistSegment {
+	return &playlistSegment{
+		msn:           s.msn,
+		uri:           segmentUri(s.msn),
+		initUri:       initUri(s.initCount),
+		discontinuity: s.discontinuity,
+		parts:         s.segmentParts,
+	}
+}
+
+func (s *segmenter) removeParts(segment *playlistSegment) {
+	for _, part := range segment.parts {
+		s.removeFile(part.uri)
+	}
+	segment.parts = nil
+}
+
+func (s *segmenter) removeUnusedInits() {
+	used := s.playlist.initUris()
+	for n := 1; n < s.initCount; n++ {
+		if !used[initUri(n)] {
+			s.removeFile(initUri(n))
+		}
+	}
+}
+
+// ticks converts seconds to the timescale of the lead track
+func (s *segmenter) ticks(seconds float64) uint64 {
+	return uint64(seconds * float64(s.leadTrack().timescale))
+}
+
+func (s *segmenter) seconds(ticks uint64) float64 {
+	return float64(ticks) / float64(s.leadTrack().timescale)
+}
+
+// ready checks that the parameter sets of all video tracks are known
+func (s *segmenter) ready() bool {
+	for _, track := range s.tracks {
+		if track.isVideo && (track.sps == nil || track.pps == nil) {
+			return false
+		}
+	}
+	return len(s.tracks) > 0
+}
+
+func (s *segmenter) leadTrack() *mp4Track {
+	for _, track := range s.tracks {
+		if track.isVideo {
+			return track
+		}
+	}
+	if len(s.tracks) > 0 {
+		return s.tracks[0]
+	}
+	return nil
+}
+
+func (s *segmenter) hasTrack(track *mp4Track) bool {
+	for _, t := range s.tracks {
+		if t == track {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *segmenter) writeFile(name string, data []byte) {
+	if s.files != nil {
+		s.files.write(name, data)
+	}
+}
+
+func (s *segmenter) removeFile(name string) {
+	if s.files != nil {
+		s.files.remove(name)
+	}
+}
+
+// close waits for the files written so far, the segmenter ignores the samples afterward
+func (s *segmenter) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.files != nil {
+		s.files.close()
+		s.files = nil
+	}
+}
@@ -0,0 +1,168 @@
+package hls
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/webrtc/v3"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	h264PayloadType = 102
+	opusPayloadType = 111
+)
+
+var supportedCodecs = []webrtc.RTPCodecParameters{
+	{
+		RTPCodecCapability: webrtc.RTPCodecCapability{
+			MimeType:    webrtc.MimeTypeH264,
+			ClockRate:   videoTimescale,
+			SDPFmtpLine: "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f",
+		},
+		PayloadType: h264PayloadType,
+	},
+	{
+		RTPCodecCapability: webrtc.RTPCodecCapability{
+			MimeType:  webrtc.MimeTypeOpus,
+			ClockRate: audioTimescale,
+			Channels:  2,
+		},
+		PayloadType: opusPayloadType,
+	},
+}
+
+// packagerTrack receives the rtp packets of a bound track local and converts them to mp4 samples
+type packagerTrack struct {
+	mu        sync.Mutex
+	bindingId string
+	mp4       *mp4Track
+	segmenter *segmenter
+	// timestamp handling
+	started  bool
+	baseDts  uint64
+	firstTs  uint32
+	lastTs   uint32
+	tsCycles uint64
+	// h264 frame assembly
+	h264     *codecs.H264Packet
+	frame    []byte
+	frameTs  uint32
+	frameDts uint64
+	idr      bool
+}
+
+func newPackagerTrack(id uint32, kind webrtc.RTPCodecType, s *segmenter) *packagerTrack {
+	track := &packagerTrack{
+		bindingId: fmt.Sprintf("hls-%d", id),
+		segmenter: s,
+		mp4:       &mp4Track{id: id, isVideo: kind == webrtc.RTPCodecTypeVideo},
+	}
+	if track.mp4.isVideo {
+		track.mp4.timescale = videoTimescale
+		track.h264 = &codecs.H264Packet{IsAVC: true}
+	} else {
+		track.mp4.timescale = audioTimescale
+	}
+	return track
+}
+
+func (t *packagerTrack) WriteRTP(header *rtp.Header, payload []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	dts := t.dts(header.Timestamp)
+	if !t.mp4.isVideo {
+		t.segmenter.writeSample(t.mp4, &mp4Sample{dts: dts, keyframe: true, data: append([]byte(nil), payload...)})
+		return len(payload), nil
+	}
+
+	if len(t.frame) > 0 && header.Timestamp != t.frameTs {
+		// the marker of the last frame got lost
+		t.flushFrame()
+	}
+	// Errors are not returned, because the track local would report them to the ingress media writer of the track
+	nalus, err := t.h264.Unmarshal(payload)
+	if err != nil {
+		slog.Debug("hls.packagerTrack: depacketize h264", "err", err)
+		return len(payload), nil
+	}
+	t.frameTs = header.Timestamp
+	t.collectNalus(nalus, dts)
+	if header.Marker {
+		t.flushFrame()
+	}
+	return len(payload), nil
+}
+
+func (t *packagerTrack) Write(b []byte) (int, error) {
+	packet := &rtp.Packet{}
+	if err := packet.Unmarshal(b); err != nil {
+		return 0, fmt.Errorf("unmarshal rtp packet: %w", err)
+	}
+	return t.WriteRTP(&packet.Header, packet.Payload)
+}
+
+// collectNalus adds the length prefixed nal units to the current frame, parameter sets are stored for the init segment
+func (t *packagerTrack) collectNalus(nalus []byte, dts uint64) {
+	if len(t.frame) == 0 {
+		t.frameDts = dts
+	}
+	for len(nalus) > 4 {
+		size := int(binary.BigEndian.Uint32(nalus))
+		if size > len(nalus)-4 {
+			return
+		}
+		nalu := nalus[4 : 4+size]
+		nalus = nalus[4+size:]
+		if len(nalu) == 0 {
+			continue
+		}
+		switch nalu[0] & 0x1F {
+		case naluTypeSps:
+			t.segmenter.setParameterSets(t.mp4, nalu, nil)
+			continue
+		case naluTypePps:
+			t.segmenter.setParameterSets(t.mp4, nil, nalu)
+			continue
+		case naluTypeAud:
+			continue
+		case naluTypeIdr:
+			t.idr = true
+		}
+		t.frame = binary.BigEndian.AppendUint32(t.frame, uint32(len(nalu)))
+		t.frame = append(t.frame, nalu...)
+	}
+}
+
+func (t *packagerTrack) flushFrame() {
+	if len(t.frame) > 0 {
+		t.segmenter.writeSample(t.mp4, &mp4Sample{dts: t.frameDts, keyframe: t.idr, data: t.frame})
+	}
+	t.frame = nil
+	t.idr = false
+}
+
+// dts converts the rtp timestamp to the decode time of the track.
+// All tracks start relative to the start of the packager, so audio and video stay in sync.
+func (t *packagerTrack) dts(ts uint32) uint64 {
+	if !t.started {
+		t.started = true
+		t.firstTs = ts
+		t.lastTs = ts
+		t.baseDts = uint64(time.Since(t.segmenter.startedAt).Seconds() * float64(t.mp4.timescale))
+	}
+	if ts < t.lastTs && t.lastTs-ts > 1<<31 {
+		t.tsCycles++
+	}
+	t.lastTs = ts
+	if t.tsCycles == 0 && ts < t.firstTs {
+		// reordered packet of the first frame
+		return t.baseDts
+	}
+	unwrapped := t.tsCycles<<32 + uint64(ts) - uint64(t.firstTs)
+	return t.baseDts + unwrapped
+}
@@ -0,0 +1,87 @@
+package hls
+
+import (
+	"os"
+	"path/filepath"
+
+	"golang.org/x/exp/slog"
+)
+
+// fileQueueSize limits the file operations waiting for the disk, a stalled disk must not stall the packets
+const fileQueueSize = 64
+
+type fileOperation struct {
+	name   string
+	data   []byte
+	remove bool
+}
+
+// fileWriter writes and removes the files of a stream in its own goroutine, in the order of the calls.
+// The segmenter calls it while it packages the packets of the hub, so the calls never wait for the disk.
+type fileWriter struct {
+	dir        string
+	operations chan *fileOperation
+	done       chan struct{}
+}
+
+func newFileWriter(dir string) *fileWriter {
+	writer := &fileWriter{
+		dir:        dir,
+		operations: make(chan *fileOperation, fileQueueSize),
+		done:       make(chan struct{}),
+	}
+	go writer.run()
+	return writer
+}
+
+func (w *fileWriter) run() {
+	defer close(w.done)
+	for operation := range w.operations {
+		if operation.remove {
+			w.removeFile(operation.name)
+			continue
+		}
+		w.writeFile(operation.name, operation.data)
+	}
+}
+
+// write takes the ownership of data
+func (w *fileWriter) write(name string, data []byte) {
+	w.queue(&fileOperation{name: name, data: data})
+}
+
+func (w *fileWriter) remove(name string) {
+	w.queue(&fileOperation{name: name, remove: true})
+}
+
+func (w *fileWriter) queue(operation *fileOperation) {
+	select {
+	case w.operations <- operation:
+	default:
+		slog.Error("hls.fileWriter: queue full, dropping file operation", "file", operation.name, "remove", operation.remove)
+	}
+}
+
+// close waits until the queued operations are done, the writer must not be called afterward
+func (w *fileWriter) close() {
+	close(w.operations)
+	<-w.done
+}
+
+// writeFile replaces the file atomically, so that the http handler never reads a partly written file
+func (w *fileWriter) writeFile(name string, data []byte) {
+	tmp := filepath.Join(w.dir, "."+name+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		slog.Error("hls.fileWriter: write file", "file", name, "err", err)
+		return
+	}
+	if err := os.Rename(tmp, filepath.Join(w.dir, name)); err != nil {
+		slog.Error("hls.fileWriter: rename file", "file", name, "err", err)
+	}
+}
+
+func (w *fileWriter) removeFile(name string) {
+	if err := os.Remove(filepath.Join(w.dir, name)); err != nil && !os.IsNotExist(err) {
+		slog.Warn("hls.fileWriter: remove file", "file", name, "err", err)
+	}
+}
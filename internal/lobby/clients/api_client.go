@@ -13,6 +13,12 @@ import (
 	"github.com/shigde/sfu/pkg/authentication"
 )
 
+var (
+	requestTimeout = 5 * time.Second
+	// the remote instance answers after its ICE gathering is complete
+	offerTimeout = 15 * time.Second
+)
+
 type ApiClient struct {
 	login    loginGetter
 	spaceId  string
@@ -43,7 +49,7 @@ func (a *ApiClient) Login() (*authentication.Token, error) {
 	}
 	body := bytes.NewBuffer(userJSON)
 
-	c := http.Client{Timeout: time.Duration(1) * time.Second}
+	c := http.Client{Timeout: requestTimeout}
 	req, err := http.NewRequest("POST", loginUrl, body)
 	if err != nil {
 		return nil, fmt.Errorf("create login request: %w", err)
@@ -78,18 +84,40 @@ func (a *ApiClient) Login() (*authentication.Token, error) {
 }
 
 func (a *ApiClient) PostWhepOffer(offer *webrtc.SessionDescription) (*webrtc.SessionDescription, error) {
-	requestUrl := fmt.Sprintf("%s/space/%s/stream/%s/whep", a.url, a.spaceId, a.streamId)
+	requestUrl := fmt.Sprintf("%s/fed/space/%s/stream/%s/whep", a.url, a.spaceId, a.streamId)
 	return a.doOfferRequest(requestUrl, offer)
 }
 
 func (a *ApiClient) PostWhipOffer(offer *webrtc.SessionDescription) (*webrtc.SessionDescription, error) {
-	requestUrl := fmt.Sprintf("%s/space/%s/stream/%s/whip", a.url, a.spaceId, a.streamId)
+	requestUrl := fmt.Sprintf("%s/fed/space/%s/stream/%s/whip", a.url, a.spaceId, a.streamId)
 	return a.doOfferRequest(requestUrl, offer)
 }
 
+// DeleteResource closes the connections of this instance to the lobby of the remote instance
+func (a *ApiClient) DeleteResource() error {
+	requestUrl := fmt.Sprintf("%s/fed/space/%s/stream/%s/res", a.url, a.spaceId, a.streamId)
+	c := http.Client{Timeout: requestTimeout}
+	req, err := http.NewRequest("DELETE", requestUrl, nil)
+	if err != nil {
+		return fmt.Errorf("create delete request: %w", err)
+	}
+	req.Header.Set("Authorization", a.getBearer())
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return fmt.Errorf("doing request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("server answer with wrong status code %s", resp.Status)
+	}
+	return nil
+}
+
 func (a *ApiClient) doOfferRequest(reqUrl string, offer *webrtc.SessionDescription) (*webrtc.SessionDescription, error) {
 	body := bytes.NewBuffer([]byte(offer.SDP))
-	c := http.Client{Timeout: time.Duration(1) * time.Second}
+	c := http.Client{Timeout: offerTimeout}
 	req, err := http.NewRequest("POST", reqUrl, body)
 
 	if err != nil {
@@ -124,6 +124,256 @@ func (m *Messenger) SendMute(mute *message.Mute) error {
 	return nil
 }
 
+func (m *Messenger) SendDominantSpeaker(speaker *message.DominantSpeaker) error {
+	channelMsg := &message.ChannelMsg{
+		Id:   0,
+		Type: message.DominantSpeakerMsg,
+		Data: speaker,
+	}
+
+	byteMsg, err := message.Marshal(channelMsg)
+	if err != nil {
+		return fmt.Errorf("marshaling dominant speaker message: %w", err)
+	}
+
+	select {
+	case <-m.quit:
+	default:
+		select {
+		case m.queueChan <- byteMsg:
+			slog.Debug("lobby.Messenger: dominant speaker is send")
+		case <-m.quit:
+		}
+	}
+
+	return nil
+}
+
+func (m *Messenger) SendForwarding(forwarding *message.Forwarding) error {
+	channelMsg := &message.ChannelMsg{
+		Id:   0,
+		Type: message.ForwardingMsg,
+		Data: forwarding,
+	}
+
+	byteMsg, err := message.Marshal(channelMsg)
+	if err != nil {
+		return fmt.Errorf("marshaling forwarding message: %w", err)
+	}
+
+	select {
+	case <-m.quit:
+	default:
+		select {
+		case m.queueChan <- byteMsg:
+			slog.Debug("lobby.Messenger: forwarding is send")
+		case <-m.quit:
+		}
+	}
+
+	return nil
+}
+
+func (m *Messenger) SendChat(chat *message.Chat) error {
+	channelMsg := &message.ChannelMsg{
+		Id:   0,
+		Type: message.ChatMsg,
+		Data: chat,
+	}
+
+	byteMsg, err := message.Marshal(channelMsg)
+	if err != nil {
+		return fmt.Errorf("marshaling chat message: %w", err)
+	}
+
+	select {
+	case <-m.quit:
+	default:
+		select {
+		case m.queueChan <- byteMsg:
+			slog.Debug("lobby.Messenger: chat is send")
+		case <-m.quit:
+		}
+	}
+
+	return nil
+}
+
+func (m *Messenger) SendReaction(reaction *message.Reaction) error {
+	channelMsg := &message.ChannelMsg{
+		Id:   0,
+		Type: message.ReactionMsg,
+		Data: reaction,
+	}
+
+	byteMsg, err := message.Marshal(channelMsg)
+	if err != nil {
+		return fmt.Errorf("marshaling reaction message: %w", err)
+	}
+
+	select {
+	case <-m.quit:
+	default:
+		select {
+		case m.queueChan <- byteMsg:
+			slog.Debug("lobby.Messenger: reaction is send")
+		case <-m.quit:
+		}
+	}
+
+	return nil
+}
+
+func (m *Messenger) SendRaiseHand(raiseHand *message.RaiseHand) error {
+	channelMsg := &message.ChannelMsg{
+		Id:   0,
+		Type: message.RaiseHandMsg,
+		Data: raiseHand,
+	}
+
+	byteMsg, err := message.Marshal(channelMsg)
+	if err != nil {
+		return fmt.Errorf("marshaling raise hand message: %w", err)
+	}
+
+	select {
+	case <-m.quit:
+	default:
+		select {
+		case m.queueChan <- byteMsg:
+			slog.Debug("lobby.Messenger: raise hand is send")
+		case <-m.quit:
+		}
+	}
+
+	return nil
+}
+
+func (m *Messenger) SendHistory(history *message.History) error {
+	channelMsg := &message.ChannelMsg{
+		Id:   0,
+		Type: message.HistoryMsg,
+		Data: history,
+	}
+
+	byteMsg, err := message.Marshal(channelMsg)
+	if err != nil {
+		return fmt.Errorf("marshaling history message: %w", err)
+	}
+
+	select {
+	case <-m.quit:
+	default:
+		select {
+		case m.queueChan <- byteMsg:
+			slog.Debug("lobby.Messenger: history is send")
+		case <-m.quit:
+		}
+	}
+
+	return nil
+}
+
+func (m *Messenger) SendModeration(moderation *message.Moderation) error {
+	channelMsg := &message.ChannelMsg{
+		Id:   0,
+		Type: message.ModerationMsg,
+		Data: moderation,
+	}
+
+	byteMsg, err := message.Marshal(channelMsg)
+	if err != nil {
+		return fmt.Errorf("marshaling moderation message: %w", err)
+	}
+
+	select {
+	case <-m.quit:
+	default:
+		select {
+		case m.queueChan <- byteMsg:
+			slog.Debug("lobby.Messenger: moderation is send")
+		case <-m.quit:
+		}
+	}
+
+	return nil
+}
+
+func (m *Messenger) SendRole(role *message.Role) error {
+	channelMsg := &message.ChannelMsg{
+		Id:   0,
+		Type: message.RoleMsg,
+		Data: role,
+	}
+
+	byteMsg, err := message.Marshal(channelMsg)
+	if err != nil {
+		return fmt.Errorf("marshaling role message: %w", err)
+	}
+
+	select {
+	case <-m.quit:
+	default:
+		select {
+		case m.queueChan <- byteMsg:
+			slog.Debug("lobby.Messenger: role is send")
+		case <-m.quit:
+		}
+	}
+
+	return nil
+}
+
+func (m *Messenger) SendRelayTracks(relayTracks *message.RelayTracks) error {
+	channelMsg := &message.ChannelMsg{
+		Id:   0,
+		Type: message.RelayTracksMsg,
+		Data: relayTracks,
+	}
+
+	byteMsg, err := message.Marshal(channelMsg)
+	if err != nil {
+		return fmt.Errorf("marshaling relay tracks message: %w", err)
+	}
+
+	select {
+	case <-m.quit:
+	default:
+		select {
+		case m.queueChan <- byteMsg:
+			slog.Debug("lobby.Messenger: relay tracks are send")
+		case <-m.quit:
+		}
+	}
+
+	return nil
+}
+
+func (m *Messenger) SendRelaySubscription(subscription *message.RelaySubscription) error {
+	channelMsg := &message.ChannelMsg{
+		Id:   0,
+		Type: message.RelaySubscriptionMsg,
+		Data: subscription,
+	}
+
+	byteMsg, err := message.Marshal(channelMsg)
+	if err != nil {
+		return fmt.Errorf("marshaling relay subscription message: %w", err)
+	}
+
+	select {
+	case <-m.quit:
+	default:
+		select {
+		case m.queueChan <- byteMsg:
+			slog.Debug("lobby.Messenger: relay subscription is send")
+		case <-m.quit:
+		}
+	}
+
+	return nil
+}
+
 func (m *Messenger) onMessages(dcMsg webrtc.DataChannelMessage) {
 	if dcMsg.IsString {
 		slog.Debug("lobby.Messenger: message (string)", "dataChannel", m.sender.Label(), "msg", string(dcMsg.Data))
@@ -161,6 +411,20 @@ func (m *Messenger) notifyAll(msg *message.ChannelMsg) {
 		m.handleOfferMsg(msg)
 	case message.MuteMsg:
 		m.handleMuteMsg(msg)
+	case message.LastNMsg:
+		m.handleLastNMsg(msg)
+	case message.ChatMsg:
+		m.handleChatMsg(msg)
+	case message.ReactionMsg:
+		m.handleReactionMsg(msg)
+	case message.RaiseHandMsg:
+		m.handleRaiseHandMsg(msg)
+	case message.ModerationMsg:
+		m.handleModerationMsg(msg)
+	case message.RelayTracksMsg:
+		m.handleRelayTracksMsg(msg)
+	case message.RelaySubscriptionMsg:
+		m.handleRelaySubscriptionMsg(msg)
 	default:
 		slog.Error("lobby.Messenger: unknown msg type", "err", fmt.Sprintf("unknown msg type: %d", msg.Type), "dataChannel", m.sender.Label())
 	}
@@ -220,6 +484,146 @@ func (m *Messenger) handleMuteMsg(msg *message.ChannelMsg) {
 	}
 }
 
+func (m *Messenger) handleLastNMsg(msg *message.ChannelMsg) {
+	jsonStr, err := json.Marshal(msg.Data)
+	if err != nil {
+		slog.Error("lobby.Messenger: unmarshal last-n", "err", err, "dataChannel", m.sender.Label())
+		return
+	}
+	lastN, err := message.LastNUnmarshal(jsonStr)
+	if err != nil {
+		slog.Error("lobby.Messenger: unmarshal last-n", "err", err, "dataChannel", m.sender.Label())
+		return
+	}
+	slog.Debug("lobby.Messenger: handle incoming last-n Msg")
+
+	m.locker.RLock()
+	defer m.locker.RUnlock()
+	for _, observer := range m.observerList {
+		observer.OnLastN(lastN)
+	}
+}
+
+func (m *Messenger) handleChatMsg(msg *message.ChannelMsg) {
+	jsonStr, err := json.Marshal(msg.Data)
+	if err != nil {
+		slog.Error("lobby.Messenger: unmarshal chat", "err", err, "dataChannel", m.sender.Label())
+		return
+	}
+	chat, err := message.ChatUnmarshal(jsonStr)
+	if err != nil {
+		slog.Error("lobby.Messenger: unmarshal chat", "err", err, "dataChannel", m.sender.Label())
+		return
+	}
+	slog.Debug("lobby.Messenger: handle incoming chat Msg")
+
+	m.locker.RLock()
+	defer m.locker.RUnlock()
+	for _, observer := range m.observerList {
+		observer.OnChat(chat)
+	}
+}
+
+func (m *Messenger) handleReactionMsg(msg *message.ChannelMsg) {
+	jsonStr, err := json.Marshal(msg.Data)
+	if err != nil {
+		slog.Error("lobby.Messenger: unmarshal reaction", "err", err, "dataChannel", m.sender.Label())
+		return
+	}
+	reaction, err := message.ReactionUnmarshal(jsonStr)
+	if err != nil {
+		slog.Error("lobby.Messenger: unmarshal reaction", "err", err, "dataChannel", m.sender.Label())
+		return
+	}
+	slog.Debug("lobby.Messenger: handle incoming reaction Msg")
+
+	m.locker.RLock()
+	defer m.locker.RUnlock()
+	for _, observer := range m.observerList {
+		observer.OnReaction(reaction)
+	}
+}
+
+func (m *Messenger) handleRaiseHandMsg(msg *message.ChannelMsg) {
+	jsonStr, err := json.Marshal(msg.Data)
+	if err != nil {
+		slog.Error("lobby.Messenger: unmarshal raise hand", "err", err, "dataChannel", m.sender.Label())
+		return
+	}
+	raiseHand, err := message.RaiseHandUnmarshal(jsonStr)
+	if err != nil {
+		slog.Error("lobby.Messenger: unmarshal raise hand", "err", err, "dataChannel", m.sender.Label())
+		return
+	}
+	slog.Debug("lobby.Messenger: handle incoming raise hand Msg")
+
+	m.locker.RLock()
+	defer m.locker.RUnlock()
+	for _, observer := range m.observerList {
+		observer.OnRaiseHand(raiseHand)
+	}
+}
+
+func (m *Messenger) handleModerationMsg(msg *message.ChannelMsg) {
+	jsonStr, err := json.Marshal(msg.Data)
+	if err != nil {
+		slog.Error("lobby.Messenger: unmarshal moderation", "err", err, "dataChannel", m.sender.Label())
+		return
+	}
+	moderation, err := message.ModerationUnmarshal(jsonStr)
+	if err != nil {
+		slog.Error("lobby.Messenger: unmarshal moderation", "err", err, "dataChannel", m.sender.Label())
+		return
+	}
+	slog.Debug("lobby.Messenger: handle incoming moderation Msg")
+
+	m.locker.RLock()
+	defer m.locker.RUnlock()
+	for _, observer := range m.observerList {
+		observer.OnModeration(moderation)
+	}
+}
+
+func (m *Messenger) handleRelayTracksMsg(msg *message.ChannelMsg) {
+	jsonStr, err := json.Marshal(msg.Data)
+	if err != nil {
+		slog.Error("lobby.Messenger: unmarshal relay tracks", "err", err, "dataChannel", m.sender.Label())
+		return
+	}
+	relayTracks, err := message.RelayTracksUnmarshal(jsonStr)
+	if err != nil {
+		slog.Error("lobby.Messenger: unmarshal relay tracks", "err", err, "dataChannel", m.sender.Label())
+		return
+	}
+	slog.Debug("lobby.Messenger: handle incoming relay tracks Msg")
+
+	m.locker.RLock()
+	defer m.locker.RUnlock()
+	for _, observer := range m.observerList {
+		observer.OnRelayTracks(relayTracks)
+	}
+}
+
+func (m *Messenger) handleRelaySubscriptionMsg(msg *message.ChannelMsg) {
+	jsonStr, err := json.Marshal(msg.Data)
+	if err != nil {
+		slog.Error("lobby.Messenger: unmarshal relay subscription", "err", err, "dataChannel", m.sender.Label())
+		return
+	}
+	subscription, err := message.RelaySubscriptionUnmarshal(jsonStr)
+	if err != nil {
+		slog.Error("lobby.Messenger: unmarshal relay subscription", "err", err, "dataChannel", m.sender.Label())
+		return
+	}
+	slog.Debug("lobby.Messenger: handle incoming relay subscription Msg")
+
+	m.locker.RLock()
+	defer m.locker.RUnlock()
+	for _, observer := range m.observerList {
+		observer.OnRelaySubscription(subscription)
+	}
+}
+
 func (m *Messenger) close() {
 	select {
 	case <-m.quit:
@@ -244,5 +648,12 @@ type msgObserver interface {
 	OnAnswer(sdp *webrtc.SessionDescription, number uint32)
 	OnOffer(sdp *webrtc.SessionDescription, responseId uint32, responseMsgNumber uint32)
 	OnMute(mute *message.Mute)
+	OnLastN(lastN *message.LastN)
+	OnChat(chat *message.Chat)
+	OnReaction(reaction *message.Reaction)
+	OnRaiseHand(raiseHand *message.RaiseHand)
+	OnModeration(moderation *message.Moderation)
+	OnRelayTracks(relayTracks *message.RelayTracks)
+	OnRelaySubscription(subscription *message.RelaySubscription)
 	GetId() uuid.UUID
 }
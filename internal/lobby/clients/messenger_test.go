@@ -50,6 +50,88 @@ func TestMessenger(t *testing.T) {
 		assert.Equal(t, mocks.Answer, answer)
 		assert.Equal(t, uint32(3), index)
 	})
+
+	t.Run("send dominant speaker", func(t *testing.T) {
+		m, sender, _ := testMessengerSetup(t)
+		_ = m.SendDominantSpeaker(&message.DominantSpeaker{SessionId: "speaker", Mids: []string{"1", "2"}})
+		assert.Equal(t, `{"id":0,"data":{"sessionId":"speaker","mids":["1","2"]},"type":4}`, string(<-sender.testSendData))
+	})
+
+	t.Run("receive last-n", func(t *testing.T) {
+		_, sender, o := testMessengerSetup(t)
+
+		var lastN *message.LastN
+		var wg sync.WaitGroup
+		wg.Add(1)
+		o.onLastNCallback = func(l *message.LastN) {
+			defer wg.Done()
+			lastN = l
+		}
+
+		sender.updateOnmessageListener(webrtc.DataChannelMessage{Data: []byte(`{"id":0,"data":{"n":2,"pinned":["guest"]},"type":5}`)})
+		wg.Wait()
+
+		assert.Equal(t, 2, *lastN.N)
+		assert.Equal(t, []string{"guest"}, lastN.Pinned)
+	})
+
+	t.Run("receive chat", func(t *testing.T) {
+		_, sender, o := testMessengerSetup(t)
+
+		var chat *message.Chat
+		var wg sync.WaitGroup
+		wg.Add(1)
+		o.onChatCallback = func(c *message.Chat) {
+			defer wg.Done()
+			chat = c
+		}
+
+		sender.updateOnmessageListener(webrtc.DataChannelMessage{Data: []byte(`{"id":0,"data":{"text":"hello"},"type":7}`)})
+		wg.Wait()
+
+		assert.Equal(t, "hello", chat.Text)
+	})
+
+	t.Run("send moderation", func(t *testing.T) {
+		m, sender, _ := testMessengerSetup(t)
+		_ = m.SendModeration(&message.Moderation{Action: message.ModerationMute, SessionId: "guest", Kind: "audio"})
+		assert.Equal(t, `{"id":0,"data":{"action":"mute","sessionId":"guest","kind":"audio"},"type":11}`, string(<-sender.testSendData))
+	})
+
+	t.Run("send role", func(t *testing.T) {
+		m, sender, _ := testMessengerSetup(t)
+		_ = m.SendRole(&message.Role{SessionId: "guest", Role: "co-host"})
+		assert.Equal(t, `{"id":0,"data":{"sessionId":"guest","role":"co-host"},"type":12}`, string(<-sender.testSendData))
+	})
+
+	t.Run("receive relay tracks", func(t *testing.T) {
+		_, sender, o := testMessengerSetup(t)
+
+		var relayTracks *message.RelayTracks
+		var wg sync.WaitGroup
+		wg.Add(1)
+		o.onRelayTracksCallback = func(r *message.RelayTracks) {
+			defer wg.Done()
+			relayTracks = r
+		}
+
+		sender.updateOnmessageListener(webrtc.DataChannelMessage{Data: []byte(`{"id":0,"data":{"tracks":[{"id":"track","sessionId":"guest","kind":"video","purpose":"guest","mute":true,"info":"Guest","layers":["LOW","HIGH"]}]},"type":13}`)})
+		wg.Wait()
+
+		assert.Equal(t, []message.RelayTrack{{Id: "track", SessionId: "guest", Kind: "video", Purpose: "guest", Mute: true, Info: "Guest", Layers: []string{"LOW", "HIGH"}}}, relayTracks.Tracks)
+	})
+
+	t.Run("send relay subscription", func(t *testing.T) {
+		m, sender, _ := testMessengerSetup(t)
+		_ = m.SendRelaySubscription(&message.RelaySubscription{Tracks: []message.SubscribedTrack{{Id: "audio"}, {Id: "video", Layer: "MEDIUM"}}})
+		assert.Equal(t, `{"id":0,"data":{"tracks":[{"id":"audio"},{"id":"video","layer":"MEDIUM"}]},"type":14}`, string(<-sender.testSendData))
+	})
+
+	t.Run("send history", func(t *testing.T) {
+		m, sender, _ := testMessengerSetup(t)
+		_ = m.SendHistory(&message.History{Chats: []message.Chat{}, RaisedHands: []message.RaiseHand{}})
+		assert.Equal(t, `{"id":0,"data":{"chats":[],"raisedHands":[]},"type":10}`, string(<-sender.testSendData))
+	})
 }
 
 type senderMock struct {
@@ -85,6 +167,10 @@ type msgObserverMock struct {
 	id               uuid.UUID
 	onAnswerCallback func(sdp *webrtc.SessionDescription, number uint32)
 	onMuteCallback   func(mute *message.Mute)
+	onLastNCallback  func(lastN *message.LastN)
+	onChatCallback   func(chat *message.Chat)
+
+	onRelayTracksCallback func(relayTracks *message.RelayTracks)
 }
 
 func newMsgObserverMock(t *testing.T) *msgObserverMock {
@@ -106,6 +192,32 @@ func (o *msgObserverMock) OnMute(mute *message.Mute) {
 	}
 }
 
+func (o *msgObserverMock) OnLastN(lastN *message.LastN) {
+	if o.onLastNCallback != nil {
+		o.onLastNCallback(lastN)
+	}
+}
+
+func (o *msgObserverMock) OnChat(chat *message.Chat) {
+	if o.onChatCallback != nil {
+		o.onChatCallback(chat)
+	}
+}
+
+func (o *msgObserverMock) OnReaction(_ *message.Reaction) {}
+
+func (o *msgObserverMock) OnRaiseHand(_ *message.RaiseHand) {}
+
+func (o *msgObserverMock) OnModeration(_ *message.Moderation) {}
+
+func (o *msgObserverMock) OnRelayTracks(relayTracks *message.RelayTracks) {
+	if o.onRelayTracksCallback != nil {
+		o.onRelayTracksCallback(relayTracks)
+	}
+}
+
+func (o *msgObserverMock) OnRelaySubscription(_ *message.RelaySubscription) {}
+
 func (o *msgObserverMock) GetId() uuid.UUID {
 	return o.id
 }
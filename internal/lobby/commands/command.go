@@ -11,6 +11,7 @@ import (
 type Command struct {
 	ParentCtx context.Context
 	user      uuid.UUID
+	right     sessions.Right
 	Err       error
 	done      chan struct{}
 }
@@ -19,6 +20,7 @@ func NewCommand(ctx context.Context, user uuid.UUID) *Command {
 	return &Command{
 		ParentCtx: ctx,
 		user:      user,
+		right:     sessions.RightSubscribe,
 		Err:       nil,
 		done:      make(chan struct{}),
 	}
@@ -27,6 +29,11 @@ func (c *Command) GetUserId() uuid.UUID {
 	return c.user
 }
 
+// GetRight returns the right the session needs to run the command, commands need the subscribe right by default
+func (c *Command) GetRight() sessions.Right {
+	return c.right
+}
+
 func (c *Command) SetError(err error) {
 	select {
 	case <-c.done:
@@ -33,8 +33,9 @@ func (c *CreateEgress) Execute(session *sessions.Session) {
 	}
 
 	c.Response = &resources.WebRTC{
-		Id:  session.Id.String(),
-		SDP: answer,
+		Id:          session.Id.String(),
+		SDP:         answer,
+		ResumeToken: session.GetResumeToken(),
 	}
 	c.SetDone()
 }
@@ -23,6 +23,7 @@ func NewCreateIngress(
 	signalKind sessions.SignalChannelKind,
 ) *CresteIngress {
 	command := NewCommand(ctx, user)
+	command.right = sessions.RightPublish
 	return &CresteIngress{
 		Command:    command,
 		sdp:        sdp,
@@ -38,8 +39,9 @@ func (c *CresteIngress) Execute(session *sessions.Session) {
 	}
 
 	c.Response = &resources.WebRTC{
-		Id:  session.Id.String(),
-		SDP: answer,
+		Id:          session.Id.String(),
+		SDP:         answer,
+		ResumeToken: session.GetResumeToken(),
 	}
 	c.SetDone()
 }
@@ -43,7 +43,9 @@ func (c *OfferEgress) Execute(session *sessions.Session) {
 		return
 	}
 
-	done := session.SetEgressAnswer(answer)
-	<-done
+	if err = session.SetEgressAnswer(answer); err != nil {
+		c.SetError(err)
+		return
+	}
 	c.SetDone()
 }
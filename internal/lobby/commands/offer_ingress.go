@@ -23,6 +23,7 @@ type OfferIngress struct {
 
 func NewOfferIngress(ctx context.Context, api egressRestApi, user uuid.UUID, signalKind sessions.SignalChannelKind) *OfferIngress {
 	command := NewCommand(ctx, user)
+	command.right = sessions.RightPublish
 	return &OfferIngress{
 		Command:    command,
 		api:        api,
@@ -43,7 +44,9 @@ func (c *OfferIngress) Execute(session *sessions.Session) {
 		return
 	}
 
-	done := session.SetIngressAnswer(answer)
-	<-done
+	if err = session.SetIngressAnswer(answer); err != nil {
+		c.SetError(err)
+		return
+	}
 	c.SetDone()
 }
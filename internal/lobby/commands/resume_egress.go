@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/pion/webrtc/v3"
+	"github.com/shigde/sfu/internal/lobby/resources"
+	"github.com/shigde/sfu/internal/lobby/sessions"
+)
+
+type ResumeEgress struct {
+	*Command
+	sdp         *webrtc.SessionDescription
+	signalKind  sessions.SignalChannelKind
+	resumeToken string
+	Response    *resources.WebRTC
+}
+
+func NewResumeEgress(ctx context.Context, user uuid.UUID, sdp *webrtc.SessionDescription, signalKind sessions.SignalChannelKind, resumeToken string) *ResumeEgress {
+	command := NewCommand(ctx, user)
+	return &ResumeEgress{
+		Command:     command,
+		sdp:         sdp,
+		signalKind:  signalKind,
+		resumeToken: resumeToken,
+		Response:    nil,
+	}
+}
+func (c *ResumeEgress) Execute(session *sessions.Session) {
+	answer, err := session.ResumeEgressEndpoint(c.ParentCtx, c.sdp, c.signalKind, c.resumeToken)
+	if err != nil {
+		c.SetError(err)
+		return
+	}
+
+	c.Response = &resources.WebRTC{
+		Id:          session.Id.String(),
+		SDP:         answer,
+		ResumeToken: session.GetResumeToken(),
+	}
+	c.SetDone()
+}
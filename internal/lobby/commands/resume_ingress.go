@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/pion/webrtc/v3"
+	"github.com/shigde/sfu/internal/lobby/resources"
+	"github.com/shigde/sfu/internal/lobby/sessions"
+)
+
+type ResumeIngress struct {
+	*Command
+	sdp         *webrtc.SessionDescription
+	signalKind  sessions.SignalChannelKind
+	resumeToken string
+	Response    *resources.WebRTC
+}
+
+func NewResumeIngress(
+	ctx context.Context,
+	user uuid.UUID,
+	sdp *webrtc.SessionDescription,
+	signalKind sessions.SignalChannelKind,
+	resumeToken string,
+) *ResumeIngress {
+	command := NewCommand(ctx, user)
+	command.right = sessions.RightPublish
+	return &ResumeIngress{
+		Command:     command,
+		sdp:         sdp,
+		signalKind:  signalKind,
+		resumeToken: resumeToken,
+		Response:    nil,
+	}
+}
+func (c *ResumeIngress) Execute(session *sessions.Session) {
+	answer, err := session.ResumeIngressEndpoint(c.ParentCtx, c.sdp, c.signalKind, c.resumeToken)
+	if err != nil {
+		c.SetError(err)
+		return
+	}
+
+	c.Response = &resources.WebRTC{
+		Id:          session.Id.String(),
+		SDP:         answer,
+		ResumeToken: session.GetResumeToken(),
+	}
+	c.SetDone()
+}
@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/shigde/sfu/internal/lobby/resources"
+	"github.com/shigde/sfu/internal/lobby/sessions"
+	"github.com/shigde/sfu/internal/rtp"
+)
+
+type TrickleIce struct {
+	*Command
+	fragment   *rtp.SdpFragment
+	iceSession resources.IceSession
+	Response   *rtp.SdpFragment
+}
+
+func NewTrickleIce(ctx context.Context, user uuid.UUID, fragment *rtp.SdpFragment, iceSession resources.IceSession) *TrickleIce {
+	command := NewCommand(ctx, user)
+	return &TrickleIce{
+		Command:    command,
+		fragment:   fragment,
+		iceSession: iceSession,
+		Response:   nil,
+	}
+}
+
+func (c *TrickleIce) Execute(session *sessions.Session) {
+	local, err := session.TrickleIce(c.ParentCtx, c.fragment, c.iceSession.Endpoint, c.iceSession.ETag)
+	if err != nil {
+		c.SetError(err)
+		return
+	}
+	c.Response = local
+	c.SetDone()
+}
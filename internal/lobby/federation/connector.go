@@ -34,8 +34,8 @@ func NewConnector(
 	liveStream string,
 	token string,
 ) *Connector {
-	host := newHost(hostActorIri, token)
-	api := clients.NewApiClient(host, hostActorIri.Host, space, liveStream)
+	host := newHost(hostActorIri, homeActorIri, token)
+	api := clients.NewApiClient(host, host.getUrl(), space, liveStream)
 	return &Connector{
 		ctx:          ctx,
 		homeActorIri: homeActorIri,
@@ -49,29 +49,38 @@ func NewConnector(
 func (c *Connector) Login() error {
 	slog.Debug("federation connector login to live stream host instance", "instanceId", c.host.instanceId)
 	if _, err := c.api.Login(); err != nil {
-		return fmt.Errorf("login to remote host: %w", err)
+		return fmt.Errorf("%w: %w", LoginError, err)
 	}
 	return nil
 }
 
-func (c *Connector) BuildIngress() (*commands.OfferIngress, error) {
+// BuildIngress builds the command to open the local ingress endpoint, it receives the tracks of the host instance.
+// The host instance signals its changes over the channel of the egress endpoint, so the ingress channel is silent.
+func (c *Connector) BuildIngress(ctx context.Context) (*commands.OfferIngress, error) {
 	slog.Debug("federation connector build ingress for live stream host instance", "instanceId", c.host.instanceId)
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	cmd := commands.NewOfferIngress(ctx, c.api, c.host.instanceId, sessions.BidirectionalSignalChannel)
+	cmd := commands.NewOfferIngress(ctx, c.api, c.host.instanceId, sessions.SilentSignalChannel)
 
 	return cmd, nil
 }
 
-func (c *Connector) BuildEgress() (*commands.OfferEgress, error) {
+// BuildEgress builds the command to open the local egress endpoint, it sends the tracks of this lobby to the host instance.
+// Both instances signal their changes over the channel of the egress endpoint.
+func (c *Connector) BuildEgress(ctx context.Context) (*commands.OfferEgress, error) {
 	slog.Debug("federation connector build egress for live stream host instance", "instanceId", c.host.instanceId)
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
 	cmd := commands.NewOfferEgress(ctx, c.api, c.host.instanceId, sessions.BidirectionalSignalChannel)
 
 	return cmd, nil
 }
 
+// Close removes the connections of this instance from the lobby of the host instance
+func (c *Connector) Close() error {
+	slog.Debug("federation connector close connection to live stream host instance", "instanceId", c.host.instanceId)
+	if err := c.api.DeleteResource(); err != nil {
+		return fmt.Errorf("deleting remote resource: %w", err)
+	}
+	return nil
+}
+
 func (c *Connector) IsThisInstanceLiveSteamHost() bool {
 	return c.homeActorIri.String() == c.host.actorIri.String()
 }
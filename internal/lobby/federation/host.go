@@ -15,23 +15,29 @@ type host struct {
 	actorId    string
 	token      string
 	instanceId uuid.UUID
+	// account of this instance at the host instance
+	homeActorId string
 
 	liveStreamId string
 	space        string
 }
 
-func newHost(actorIri url.URL, token string) *host {
-	preferredName := preferredNameFromActor(actorIri)
-	actorId := fmt.Sprintf("%s@%s", preferredName, actorIri.Host)
+func newHost(actorIri url.URL, homeActorIri url.URL, token string) *host {
+	actorId := actorIdFromActor(actorIri)
 	instanceId := auth.CreateInstanceUuid(actorId)
 	return &host{
-		actorIri:   actorIri,
-		actorId:    actorId,
-		token:      token,
-		instanceId: instanceId,
+		actorIri:    actorIri,
+		actorId:     actorId,
+		token:       token,
+		instanceId:  instanceId,
+		homeActorId: actorIdFromActor(homeActorIri),
 	}
 }
 
+func actorIdFromActor(actorIri url.URL) string {
+	return fmt.Sprintf("%s@%s", preferredNameFromActor(actorIri), actorIri.Host)
+}
+
 func preferredNameFromActor(actorIri url.URL) string {
 	path := strings.Split(actorIri.Path, "/")
 	length := len(path)
@@ -42,9 +48,14 @@ func preferredNameFromActor(actorIri url.URL) string {
 	return path[length-1]
 }
 
+// GetUser returns the user this instance logs in to the host instance with
 func (h *host) GetUser() *authentication.User {
 	return &authentication.User{
-		UserId: h.actorId,
+		UserId: h.homeActorId,
 		Token:  h.token,
 	}
 }
+
+func (h *host) getUrl() string {
+	return fmt.Sprintf("%s://%s", h.actorIri.Scheme, h.actorIri.Host)
+}
@@ -0,0 +1,146 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shigde/sfu/pkg/authentication"
+	"github.com/stretchr/testify/assert"
+)
+
+type testHostInstance struct {
+	logins  atomic.Int32
+	deletes atomic.Int32
+	user    chan string
+}
+
+// testHostControllerSetup starts a remote host instance, the connector of the lobby logs in to it
+func testHostControllerSetup(t *testing.T) (*Connector, *testHostInstance, func()) {
+	t.Helper()
+	minReconnectDelay = 10 * time.Millisecond
+	remote := &testHostInstance{user: make(chan string, 10)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/authenticate":
+			var user authentication.User
+			_ = json.NewDecoder(r.Body).Decode(&user)
+			remote.logins.Add(1)
+			remote.user <- user.UserId
+			_ = json.NewEncoder(w).Encode(authentication.Token{JWT: "jwt"})
+		case r.Method == http.MethodDelete && r.URL.Path == "/fed/space/space/stream/stream/res":
+			remote.deletes.Add(1)
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	serverUrl, _ := url.Parse(server.URL)
+	homeActorIri, _ := url.Parse("http://home.localhost/federation/accounts/shig")
+	hostActorIri := serverUrl.JoinPath("federation", "accounts", "shig")
+	connector := NewConnector(context.Background(), *homeActorIri, *hostActorIri, "space", "stream", "token")
+	return connector, remote, server.Close
+}
+
+func TestHostController(t *testing.T) {
+	t.Run("login with account of home instance", func(t *testing.T) {
+		connector, remote, stop := testHostControllerSetup(t)
+		defer stop()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		connected := make(chan struct{})
+		NewHostController(ctx, connector, func(ctx context.Context) (<-chan struct{}, error) {
+			close(connected)
+			return make(chan struct{}), nil
+		}, func() {})
+
+		select {
+		case <-connected:
+		case <-time.After(time.Second):
+			t.Fatalf("lobby was not connected")
+		}
+		assert.Equal(t, "shig@home.localhost", <-remote.user)
+		assert.Equal(t, int32(1), remote.logins.Load())
+	})
+
+	t.Run("reconnect after connection was lost", func(t *testing.T) {
+		connector, remote, stop := testHostControllerSetup(t)
+		defer stop()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		attempts := make(chan chan struct{}, 10)
+		disconnects := atomic.Int32{}
+		deletesBeforeConnect := make(chan int32, 10)
+		NewHostController(ctx, connector, func(ctx context.Context) (<-chan struct{}, error) {
+			deletesBeforeConnect <- remote.deletes.Load()
+			lost := make(chan struct{})
+			attempts <- lost
+			return lost, nil
+		}, func() { disconnects.Add(1) })
+
+		close(<-attempts)
+		select {
+		case <-attempts:
+		case <-time.After(time.Second):
+			t.Fatalf("lobby was not reconnected")
+		}
+		assert.Equal(t, int32(1), disconnects.Load())
+		assert.Equal(t, int32(2), remote.logins.Load())
+		// the remote resource of the lost connection is deleted before the lobby reconnects
+		assert.Equal(t, int32(0), <-deletesBeforeConnect)
+		assert.Equal(t, int32(1), <-deletesBeforeConnect)
+	})
+
+	t.Run("retry failed connection", func(t *testing.T) {
+		connector, remote, stop := testHostControllerSetup(t)
+		defer stop()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		attempts := atomic.Int32{}
+		disconnects := atomic.Int32{}
+		connected := make(chan struct{})
+		NewHostController(ctx, connector, func(ctx context.Context) (<-chan struct{}, error) {
+			if attempts.Add(1) < 3 {
+				return nil, errors.New("no connection")
+			}
+			close(connected)
+			return make(chan struct{}), nil
+		}, func() { disconnects.Add(1) })
+
+		select {
+		case <-connected:
+		case <-time.After(time.Second):
+			t.Fatalf("lobby was not connected")
+		}
+		assert.Equal(t, int32(2), disconnects.Load())
+		assert.Equal(t, int32(2), remote.deletes.Load())
+	})
+
+	t.Run("close remote resource when lobby stops", func(t *testing.T) {
+		connector, remote, stop := testHostControllerSetup(t)
+		defer stop()
+		ctx, cancel := context.WithCancel(context.Background())
+
+		connected := make(chan struct{})
+		NewHostController(ctx, connector, func(ctx context.Context) (<-chan struct{}, error) {
+			close(connected)
+			return make(chan struct{}), nil
+		}, func() {})
+		<-connected
+		cancel()
+
+		assert.Eventually(t, func() bool {
+			return remote.deletes.Load() == 1
+		}, time.Second, 10*time.Millisecond)
+	})
+}
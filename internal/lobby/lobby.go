@@ -3,25 +3,54 @@ package lobby
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/url"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/pion/webrtc/v3"
+	"github.com/shigde/sfu/internal/compositor"
+	"github.com/shigde/sfu/internal/ffmpeg"
+	"github.com/shigde/sfu/internal/hls"
 	"github.com/shigde/sfu/internal/lobby/federation"
 	"github.com/shigde/sfu/internal/lobby/sessions"
+	"github.com/shigde/sfu/internal/record"
+	"github.com/shigde/sfu/internal/rtmp"
+	"github.com/shigde/sfu/internal/rtp"
+	"github.com/shigde/sfu/pkg/message"
 	"golang.org/x/exp/slog"
 )
 
 type command interface {
 	GetUserId() uuid.UUID
+	GetRight() sessions.Right
 	Execute(session *sessions.Session)
 	SetError(err error)
 }
 
+type liveStreamPackager interface {
+	AddTrack(info *rtp.TrackInfo)
+	RemoveTrack(info *rtp.TrackInfo)
+}
+
 var (
 	ErrNoSession            = errors.New("no session exists")
 	ErrSessionAlreadyExists = errors.New("session already exists")
 	ErrLobbyClosed          = errors.New("lobby already closed")
+	ErrLobbyNotRunning      = errors.New("lobby not running")
+	ErrAlreadyLive          = errors.New("lobby already live")
+	ErrNotLive              = errors.New("lobby not live")
+	ErrRecordingDisabled    = errors.New("recording disabled")
+	ErrAlreadyRecording     = errors.New("lobby already recording")
+	ErrNotRecording         = errors.New("lobby not recording")
+	ErrInvalidLastN         = errors.New("last-n must not be negative")
+	ErrUserBanned           = errors.New("user banned from lobby")
+	ErrNoTracksToMute       = errors.New("session has no tracks to mute")
+	ErrNoTracksToUnmute     = errors.New("session has no tracks a moderator muted")
+	ErrPermissionDenied     = errors.New("role of the user does not permit the action")
+	ErrInvalidRole          = errors.New("invalid lobby role")
+	ErrInstanceSession      = errors.New("no session for the live stream host instance")
 )
 
 // lobby, is a container for all sessions of a stream
@@ -40,13 +69,24 @@ type lobby struct {
 	lobbyGarbage   chan<- lobbyItem
 	cmdRunner      chan<- command
 
-	connector *federation.Connector
+	connector      *federation.Connector
+	hostController *federation.HostController
+
+	liveLock   sync.Mutex
+	publisher  *rtmp.Publisher
+	liveSender *rtp.LiveStreamSender
+	recorder   *record.Recorder
+	compositor *compositor.Compositor
+
+	events *eventBroker
+	// onBan persists a ban of a user, it is set by the lobby repository
+	onBan func(ctx context.Context, userId uuid.UUID) error
 }
 
-func newLobby(entity *LobbyEntity, rtp sessions.RtpEngine, homeActorIri *url.URL, registerToken string, lobbyGarbage chan<- lobbyItem) *lobby {
+func newLobby(entity *LobbyEntity, rtp sessions.RtpEngine, homeActorIri *url.URL, registerToken string, hlsConfig *hls.HlsConfig, compositorConfig *compositor.CompositorConfig, gracePeriod time.Duration, events *eventBroker, lobbyGarbage chan<- lobbyItem) *lobby {
 	ctx, stop := context.WithCancel(context.Background())
 	sessRep := sessions.NewSessionRepository()
-	hub := sessions.NewHub(ctx, sessRep, entity.LiveStreamId, nil)
+	hub := sessions.NewHub(ctx, sessRep, entity.LiveStreamId, nil, newHlsPackager(ctx, hlsConfig, entity.LiveStreamId))
 	hostActorIri, _ := url.Parse(entity.Host)
 
 	garbage := make(chan sessions.Item)
@@ -70,6 +110,19 @@ func newLobby(entity *LobbyEntity, rtp sessions.RtpEngine, homeActorIri *url.URL
 		cmdRunner:      runner,
 
 		connector: connector,
+
+		compositor: newCompositor(ctx, compositorConfig, entity.LiveStreamId, hub),
+
+		events: events,
+	}
+	if lobObj.compositor != nil {
+		hub.DispatchSetCompositor(ctx, lobObj.compositor)
+	}
+	if events != nil {
+		hub.DispatchAddRecorder(ctx, &trackEvents{lobbyId: entity.UUID, events: events})
+	}
+	if entity.LastN > 0 {
+		hub.DispatchSetLastN(ctx, entity.LastN)
 	}
 	// session handling should be sequentiell to avoid race conditions in whole group state
 	go func(l *lobby, sessionCreator <-chan sessions.Item, sessionGarbage chan sessions.Item, cmdRunner <-chan command) {
@@ -81,12 +134,19 @@ func newLobby(entity *LobbyEntity, rtp sessions.RtpEngine, homeActorIri *url.URL
 				case <-l.ctx.Done():
 					item.Done <- false
 				default:
-					session := sessions.NewSession(l.ctx, item.UserId, l.hub, l.rtp, item.SessionType, sessionGarbage)
+					session := sessions.NewSession(l.ctx, item.UserId, l.hub, l.rtp, item.SessionType, sessionGarbage, sessions.SessionWithRole(item.Role), sessions.SessionWithModerationHandler(l.onModeration), sessions.SessionWithGracePeriod(gracePeriod))
 					ok := l.sessions.New(session)
+					if ok {
+						l.events.publish(newSessionEvent(EventSessionJoined, l.Id, session))
+					}
 					item.Done <- ok
 				}
 			case item := <-sessionGarbage:
+				session, found := l.sessions.FindByUserId(item.UserId)
 				ok := l.sessions.DeleteByUser(item.UserId)
+				if ok && found {
+					l.events.publish(newSessionEvent(EventSessionLeft, l.Id, session))
+				}
 				item.Done <- ok
 				if l.sessions.LenUserSession() == 0 {
 					item := newLobbyItem(l.Id)
@@ -116,18 +176,17 @@ func newLobby(entity *LobbyEntity, rtp sessions.RtpEngine, homeActorIri *url.URL
 
 	// if this local instance not the owner of the stream, the lobby will relay all tracks to the remote host of the
 	// stream
-	//if !connector.IsThisInstanceLiveSteamHost() {
-	//	go func(l *lobby) {
-	//		l.connectToLiveStreamHostInstance()
-	//	}(lobObj)
-	//}
+	if !connector.IsThisInstanceLiveSteamHost() {
+		lobObj.hostController = federation.NewHostController(ctx, connector, lobObj.connectToLiveStreamHostInstance, lobObj.disconnectFromLiveStreamHostInstance)
+	}
 
 	return lobObj
 }
 
-func (l *lobby) newSession(userId uuid.UUID, sType sessions.SessionType) bool {
+func (l *lobby) newSession(userId uuid.UUID, sType sessions.SessionType, role sessions.Role) bool {
 	item := sessions.NewItem(userId)
 	item.SessionType = sType
+	item.Role = role
 	select {
 	case l.sessionCreator <- item:
 		ok := <-item.Done
@@ -154,6 +213,40 @@ func (l *lobby) removeSession(userId uuid.UUID) bool {
 	}
 }
 
+// leave removes the session of the user from the lobby and closes it
+func (l *lobby) leave(userId uuid.UUID) bool {
+	session, found := l.sessions.FindByUserId(userId)
+	if !found {
+		return false
+	}
+	if ok := l.removeSession(userId); !ok {
+		return false
+	}
+	session.Close()
+	return true
+}
+
+// deleteResource closes the endpoint of the deleted resource, the user leaves the lobby with the last endpoint of its session
+func (l *lobby) deleteResource(userId uuid.UUID, endpointType rtp.EndpointType) bool {
+	session, found := l.sessions.FindByUserId(userId)
+	if !found {
+		return false
+	}
+	if session.CloseEndpoint(endpointType) {
+		return true
+	}
+	return l.leave(userId)
+}
+
+// hasResource returns true, if the session of the user is the resource, every session is a resource of the user if no resource is given
+func (l *lobby) hasResource(userId uuid.UUID, resource uuid.UUID) bool {
+	session, found := l.sessions.FindByUserId(userId)
+	if !found {
+		return false
+	}
+	return resource == uuid.Nil || session.Id == resource
+}
+
 func (l *lobby) runCommand(cmd command) {
 	select {
 	case l.cmdRunner <- cmd:
@@ -162,50 +255,261 @@ func (l *lobby) runCommand(cmd command) {
 	}
 }
 
-// handle, run session commands on existing sessions
+// handle, run session commands on existing sessions, if the role of the session permits the command
 func (l *lobby) handle(cmd command) {
-	if session, found := l.sessions.FindByUserId(cmd.GetUserId()); found {
-		cmd.Execute(session)
+	session, found := l.sessions.FindByUserId(cmd.GetUserId())
+	if !found {
+		cmd.SetError(ErrNoSession)
+		return
+	}
+	if !session.HasRight(cmd.GetRight()) {
+		cmd.SetError(ErrPermissionDenied)
+		return
 	}
-	cmd.SetError(ErrNoSession)
+	cmd.Execute(session)
 }
 
-func (l *lobby) connectToLiveStreamHostInstance() {
-	// First login to remote owner instance of this live stream
-	if err := l.connector.Login(); err != nil {
-		slog.Error("logging in to remote shig instance")
-		return
+// startLiveStream publishes the main stream of the lobby to the rtmp server.
+// If the live stream sender is enabled, the main stream is forwarded as RTP to the allocated udp ports as well.
+func (l *lobby) startLiveStream(rtmpUrl string, key string, senderConfig *rtp.LiveStreamSenderConfig, ports *rtp.PortAllocator, onStatus func(status rtmp.Status, err error)) error {
+	l.liveLock.Lock()
+	defer l.liveLock.Unlock()
+	if l.ctx.Err() != nil {
+		return ErrLobbyClosed
+	}
+	if l.publisher != nil {
+		if status, _ := l.publisher.Status(); status != rtmp.StatusFailed && status != rtmp.StatusStopped {
+			return ErrAlreadyLive
+		}
+		// the failed or stopped publisher is still a packager of the hub
+		l.hub.DispatchRemovePackager(l.ctx, l.publisher)
+		l.publisher.Stop()
+		l.publisher = nil
 	}
+	if ports != nil && l.liveSender == nil {
+		sender, err := rtp.NewLiveStreamSender(l.ctx, l.entity.LiveStreamId, senderConfig, ports)
+		if err != nil {
+			return fmt.Errorf("creating live stream sender: %w", err)
+		}
+		l.liveSender = sender
+		l.hub.DispatchAddPackager(l.ctx, sender)
+	}
+	options := []rtmp.PublisherOption{rtmp.PublisherWithStatusListener(onStatus)}
+	// RTMP servers do not accept Opus, without ffmpeg the live stream has no audio
+	if encoder, err := ffmpeg.NewAacEncoder(); err != nil {
+		slog.Warn("lobby.lobby: no audio encoder for the live stream", "lobby", l.Id, "err", err)
+	} else {
+		options = append(options, rtmp.PublisherWithAudioEncoder(encoder))
+	}
+	l.publisher = rtmp.NewPublisher(l.ctx, l.entity.LiveStreamId, rtmpUrl, key, options...)
+	l.hub.DispatchAddPackager(l.ctx, l.publisher)
+	l.events.publish(LobbyEvent{Type: EventLiveStarted, LobbyId: l.Id, Time: time.Now()})
+	return nil
+}
 
-	// After logged in we build a local session for this connection
-	if ok := l.newSession(l.connector.GetInstanceId(), sessions.InstanceSession); !ok {
-		slog.Error("no session connected for instance connection")
-		return
+func (l *lobby) stopLiveStream() error {
+	l.liveLock.Lock()
+	defer l.liveLock.Unlock()
+	if l.publisher == nil {
+		return ErrNotLive
+	}
+	if l.liveSender != nil {
+		l.hub.DispatchRemovePackager(l.ctx, l.liveSender)
+		l.liveSender.Stop()
+		l.liveSender = nil
 	}
+	l.hub.DispatchRemovePackager(l.ctx, l.publisher)
+	l.publisher.Stop()
+	l.publisher = nil
+	l.events.publish(LobbyEvent{Type: EventLiveStopped, LobbyId: l.Id, Time: time.Now()})
+	return nil
+}
 
-	// We open a local Egress endpoint and connecting to a remote Ingress Endpoint
-	cmdEgress, err := l.connector.BuildEgress()
+// startRecording records all tracks of the lobby until the recording or the lobby is stopped
+func (l *lobby) startRecording(config *record.RecordConfig) (string, error) {
+	l.liveLock.Lock()
+	defer l.liveLock.Unlock()
+	if l.ctx.Err() != nil {
+		return "", ErrLobbyClosed
+	}
+	if l.recorder != nil {
+		return "", ErrAlreadyRecording
+	}
+	recorder, err := record.NewRecorder(l.ctx, config, l.entity.LiveStreamId)
 	if err != nil {
-		slog.Error("build Egress connection", "err", err)
+		return "", fmt.Errorf("creating recorder: %w", err)
+	}
+	l.recorder = recorder
+	l.hub.DispatchAddRecorder(l.ctx, recorder)
+	return recorder.Dir(), nil
+}
+
+func (l *lobby) stopRecording() error {
+	l.liveLock.Lock()
+	defer l.liveLock.Unlock()
+	if l.recorder == nil {
+		return ErrNotRecording
+	}
+	l.hub.DispatchRemoveRecorder(l.ctx, l.recorder)
+	l.recorder.Stop()
+	l.recorder = nil
+	return nil
+}
+
+// setLastN limits the guest videos every session receives, sessions can override the limit
+func (l *lobby) setLastN(lastN int) {
+	l.hub.DispatchSetLastN(l.ctx, lastN)
+}
+
+// setRole changes the role of a connected user, the client is told about its new role
+func (l *lobby) setRole(userId uuid.UUID, role sessions.Role) {
+	if session, found := l.sessions.FindByUserId(userId); found {
+		session.SetRole(role)
+	}
+}
+
+// canModerate returns whether the user may moderate the target, nobody but the host moderates the host
+func (l *lobby) canModerate(userId uuid.UUID, target *sessions.Session) bool {
+	session, found := l.sessions.FindByUserId(userId)
+	if !found || !session.HasRight(sessions.RightModerate) {
+		return false
+	}
+	return target.GetRole() != sessions.RoleHost || session.GetRole() == sessions.RoleHost
+}
+
+// mute mutes the tracks of a kind of the user for all other sessions, all tracks if the kind is unknown
+func (l *lobby) mute(ctx context.Context, userId uuid.UUID, kind webrtc.RTPCodecType) error {
+	session, found := l.sessions.FindByUserId(userId)
+	if !found {
+		return ErrNoSession
+	}
+	if !session.Mute(ctx, kind) {
+		return ErrNoTracksToMute
+	}
+	return nil
+}
+
+// kick removes the session of the user from the lobby, the client is told that it was removed
+func (l *lobby) kick(userId uuid.UUID) error {
+	session, found := l.sessions.FindByUserId(userId)
+	if !found {
+		return ErrNoSession
+	}
+	if ok := l.removeSession(userId); !ok {
+		return ErrNoSession
+	}
+	session.Kick()
+	return nil
+}
+
+// onModeration executes the moderation commands of the signal channels, if the sender is a moderator
+func (l *lobby) onModeration(moderatorId uuid.UUID, moderation *message.Moderation) {
+	sessionId, err := uuid.Parse(moderation.SessionId)
+	if err != nil {
+		slog.Warn("lobby.lobby: moderation of invalid session", "lobby", l.Id, "sessionId", moderation.SessionId)
+		return
+	}
+	target, found := l.sessions.FindById(sessionId)
+	if !found {
+		slog.Warn("lobby.lobby: moderation of unknown session", "lobby", l.Id, "sessionId", sessionId)
+		return
+	}
+	if !l.canModerate(moderatorId, target) {
+		slog.Warn("lobby.lobby: moderation not permitted", "lobby", l.Id, "user", moderatorId, "action", moderation.Action, "sessionId", sessionId)
 		return
 	}
+
+	switch moderation.Action {
+	case message.ModerationMute:
+		err = l.mute(l.ctx, target.GetUserId(), webrtc.NewRTPCodecType(moderation.Kind))
+	case message.ModerationUnmute:
+		if !target.Unmute(l.ctx, webrtc.NewRTPCodecType(moderation.Kind)) {
+			err = ErrNoTracksToUnmute
+		}
+	case message.ModerationKick:
+		err = l.kick(target.GetUserId())
+	case message.ModerationBan:
+		if err = l.onBan(l.ctx, target.GetUserId()); err == nil {
+			err = l.kick(target.GetUserId())
+		}
+	default:
+		err = fmt.Errorf("unknown moderation action: %s", moderation.Action)
+	}
+	if err != nil {
+		slog.Error("lobby.lobby: moderation", "err", err, "lobby", l.Id, "action", moderation.Action, "sessionId", sessionId)
+	}
+}
+
+// connectToLiveStreamHostInstance relays the lobby to the remote instance hosting the live stream over a local
+// instance session. The returned channel is closed, when the instance session was closed.
+func (l *lobby) connectToLiveStreamHostInstance(ctx context.Context) (<-chan struct{}, error) {
+	instanceId := l.connector.GetInstanceId()
+	if ok := l.newSession(instanceId, sessions.InstanceSession, sessions.RoleGuest); !ok {
+		return nil, ErrInstanceSession
+	}
+	session, found := l.sessions.FindByUserId(instanceId)
+	if !found {
+		return nil, ErrInstanceSession
+	}
+
+	// First we open a local Egress endpoint and connect it to a remote Ingress endpoint, it carries the signal channel
+	cmdEgress, err := l.connector.BuildEgress(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("building egress connection: %w", err)
+	}
 	l.runCommand(cmdEgress)
 	if err = cmdEgress.WaitForDone(); err != nil {
-		slog.Error("run egress build connection cmd", "err", err)
-		return
+		return nil, fmt.Errorf("connecting egress: %w", err)
 	}
 
-	// At least we open a local Ingress endpoint and connection to a remote Egress endpoint
-	cmdIngress, err := l.connector.BuildIngress()
+	// At least we open a local Ingress endpoint and connect it to a remote Egress endpoint
+	cmdIngress, err := l.connector.BuildIngress(ctx)
 	if err != nil {
-		slog.Error("build Ingress connection", "err", err)
-		return
+		return nil, fmt.Errorf("building ingress connection: %w", err)
 	}
 	l.runCommand(cmdIngress)
 	if err = cmdIngress.WaitForDone(); err != nil {
-		slog.Error("run ingress build connection cmd", "err", err)
-		return
+		return nil, fmt.Errorf("connecting ingress: %w", err)
+	}
+
+	l.events.publish(LobbyEvent{Type: EventPipeConnected, LobbyId: l.Id, User: instanceId.String(), Time: time.Now()})
+	return session.Done(), nil
+}
+
+// disconnectFromLiveStreamHostInstance removes the instance session of the remote host instance
+func (l *lobby) disconnectFromLiveStreamHostInstance() {
+	l.leave(l.connector.GetInstanceId())
+}
+
+// newHlsPackager returns nil if HLS is disabled or the packager could not be created, the lobby works without HLS anyway
+func newHlsPackager(ctx context.Context, hlsConfig *hls.HlsConfig, liveStreamId uuid.UUID) liveStreamPackager {
+	if hlsConfig == nil || !hlsConfig.Enable {
+		return nil
+	}
+	packager, err := hls.NewPackager(ctx, hlsConfig, liveStreamId)
+	if err != nil {
+		slog.Error("lobby: creating hls packager", "liveStreamId", liveStreamId, "err", err)
+		return nil
 	}
+	return packager
+}
 
-	// Connection established!!
+// newCompositor returns nil if the compositor is disabled or has no codecs, the guests are not composed then
+func newCompositor(ctx context.Context, compositorConfig *compositor.CompositorConfig, liveStreamId uuid.UUID, hub *sessions.Hub) *compositor.Compositor {
+	if compositorConfig == nil || !compositorConfig.Enable {
+		return nil
+	}
+	if compositorConfig.Codecs == nil {
+		slog.Error("lobby: creating compositor", "liveStreamId", liveStreamId, "err", compositor.ErrNoCodecs)
+		return nil
+	}
+	publish := func(info *rtp.TrackInfo) {
+		hub.DispatchAddTrack(ctx, info)
+	}
+	comp, err := compositor.NewCompositor(ctx, compositorConfig, liveStreamId, compositorConfig.Codecs, publish)
+	if err != nil {
+		slog.Error("lobby: creating compositor", "liveStreamId", liveStreamId, "err", err)
+		return nil
+	}
+	return comp
 }
@@ -2,6 +2,7 @@ package lobby
 
 import (
 	"github.com/google/uuid"
+	"github.com/shigde/sfu/internal/lobby/sessions"
 	"gorm.io/gorm"
 )
 
@@ -11,7 +12,15 @@ type LobbyEntity struct {
 	Space        string
 	IsRunning    bool   `json:"isLobbyRunning"`
 	IsLive       bool   `json:"isLive"`
-	Host         string `json:"-"`
+	LiveStatus   string `json:"liveStatus"`
+	LiveError    string `json:"liveError,omitempty"`
+	IsRecording  bool   `json:"isRecording"`
+	LastN        int    `json:"lastN"`
+	// users that are not allowed to join the lobby
+	BannedUsers []uuid.UUID `json:"-" gorm:"serializer:json"`
+	// roles the host granted, they override the roles resolved from the live stream video
+	Roles map[uuid.UUID]sessions.Role `json:"-" gorm:"serializer:json"`
+	Host  string                      `json:"-"`
 	gorm.Model
 }
 
@@ -0,0 +1,134 @@
+package lobby
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shigde/sfu/internal/lobby/sessions"
+	"github.com/shigde/sfu/internal/rtp"
+	"golang.org/x/exp/slog"
+)
+
+// events a subscriber can lag behind, before events are dropped for the subscriber
+const eventQueueSize = 64
+
+type LobbyEventType string
+
+const (
+	EventSessionJoined LobbyEventType = "session-joined"
+	EventSessionLeft   LobbyEventType = "session-left"
+	EventTrackAdded    LobbyEventType = "track-added"
+	EventTrackRemoved  LobbyEventType = "track-removed"
+	EventTrackMuted    LobbyEventType = "track-muted"
+	EventLiveStarted   LobbyEventType = "live-started"
+	EventLiveStopped   LobbyEventType = "live-stopped"
+	EventPipeConnected LobbyEventType = "pipe-connected"
+)
+
+// LobbyEvent is a lifecycle event of a lobby, clients without a WebRTC connection can follow the lobby with these events
+type LobbyEvent struct {
+	Type      LobbyEventType `json:"type"`
+	LobbyId   uuid.UUID      `json:"-"`
+	SessionId string         `json:"sessionId,omitempty"`
+	User      string         `json:"user,omitempty"`
+	TrackId   string         `json:"trackId,omitempty"`
+	Kind      string         `json:"kind,omitempty"`
+	Purpose   string         `json:"purpose,omitempty"`
+	Muted     bool           `json:"muted,omitempty"`
+	Time      time.Time      `json:"time"`
+}
+
+func newSessionEvent(eventType LobbyEventType, lobbyId uuid.UUID, session *sessions.Session) LobbyEvent {
+	return LobbyEvent{
+		Type:      eventType,
+		LobbyId:   lobbyId,
+		SessionId: session.Id.String(),
+		User:      session.GetUserId().String(),
+		Time:      time.Now(),
+	}
+}
+
+func newTrackEvent(eventType LobbyEventType, lobbyId uuid.UUID, info *rtp.TrackInfo) LobbyEvent {
+	return LobbyEvent{
+		Type:      eventType,
+		LobbyId:   lobbyId,
+		SessionId: info.GetSessionId().String(),
+		TrackId:   info.GetId().String(),
+		Kind:      info.GetTrackLocal().Kind().String(),
+		Purpose:   info.GetPurpose().ToString(),
+		Muted:     info.GetMute(),
+		Time:      time.Now(),
+	}
+}
+
+// eventBroker fans the events of all lobbies out to the subscribers of the lobbies.
+// The subscriptions do not depend on a running lobby, so that a subscriber sees when the lobby starts.
+type eventBroker struct {
+	mu          sync.RWMutex
+	subscribers map[*eventSubscriber]struct{}
+}
+
+type eventSubscriber struct {
+	lobbyId uuid.UUID
+	events  chan LobbyEvent
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{subscribers: make(map[*eventSubscriber]struct{})}
+}
+
+// publish never blocks, events are dropped for subscribers that can not keep up
+func (b *eventBroker) publish(event LobbyEvent) {
+	if b == nil {
+		return
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for subscriber := range b.subscribers {
+		if subscriber.lobbyId != event.LobbyId {
+			continue
+		}
+		select {
+		case subscriber.events <- event:
+		default:
+			slog.Warn("lobby.eventBroker: subscriber too slow, drop event", "lobby", event.LobbyId, "type", event.Type)
+		}
+	}
+}
+
+// subscribe returns the events of the lobby until the context is done, then the channel is closed
+func (b *eventBroker) subscribe(ctx context.Context, lobbyId uuid.UUID) <-chan LobbyEvent {
+	subscriber := &eventSubscriber{lobbyId: lobbyId, events: make(chan LobbyEvent, eventQueueSize)}
+	b.mu.Lock()
+	b.subscribers[subscriber] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers, subscriber)
+		close(subscriber.events)
+	}()
+	return subscriber.events
+}
+
+// trackEvents publishes the track events of the hub, the hub treats it as recorder of all tracks
+type trackEvents struct {
+	lobbyId uuid.UUID
+	events  *eventBroker
+}
+
+func (t *trackEvents) AddTrack(info *rtp.TrackInfo) {
+	t.events.publish(newTrackEvent(EventTrackAdded, t.lobbyId, info))
+}
+
+func (t *trackEvents) RemoveTrack(info *rtp.TrackInfo) {
+	t.events.publish(newTrackEvent(EventTrackRemoved, t.lobbyId, info))
+}
+
+func (t *trackEvents) MuteTrack(info *rtp.TrackInfo) {
+	t.events.publish(newTrackEvent(EventTrackMuted, t.lobbyId, info))
+}
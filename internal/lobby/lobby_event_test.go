@@ -0,0 +1,75 @@
+package lobby
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shigde/sfu/internal/lobby/sessions"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventBroker(t *testing.T) {
+	t.Run("publish events to the subscribers of the lobby", func(t *testing.T) {
+		broker := newEventBroker()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		lobbyId := uuid.New()
+		events := broker.subscribe(ctx, lobbyId)
+		otherEvents := broker.subscribe(ctx, uuid.New())
+
+		broker.publish(LobbyEvent{Type: EventLiveStarted, LobbyId: lobbyId, Time: time.Now()})
+
+		event := <-events
+		assert.Equal(t, EventLiveStarted, event.Type)
+		assert.Len(t, otherEvents, 0)
+	})
+
+	t.Run("drop events of slow subscribers", func(t *testing.T) {
+		broker := newEventBroker()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		lobbyId := uuid.New()
+		events := broker.subscribe(ctx, lobbyId)
+
+		for i := 0; i < eventQueueSize+1; i++ {
+			broker.publish(LobbyEvent{Type: EventTrackAdded, LobbyId: lobbyId})
+		}
+		assert.Len(t, events, eventQueueSize)
+	})
+
+	t.Run("close events when subscription ends", func(t *testing.T) {
+		broker := newEventBroker()
+		ctx, cancel := context.WithCancel(context.Background())
+		events := broker.subscribe(ctx, uuid.New())
+		cancel()
+
+		select {
+		case _, ok := <-events:
+			assert.False(t, ok)
+		case <-time.After(time.Second):
+			t.Fatalf("events not closed")
+		}
+	})
+
+	t.Run("lobby publishes joined sessions", func(t *testing.T) {
+		lobby, _ := testLobbySetup(t)
+		broker := newEventBroker()
+		lobby.events = broker
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		events := broker.subscribe(ctx, lobby.Id)
+		user := uuid.New()
+
+		lobby.newSession(user, sessions.UserSession, sessions.RoleGuest)
+		lobby.removeSession(user)
+
+		joined := <-events
+		assert.Equal(t, EventSessionJoined, joined.Type)
+		assert.Equal(t, user.String(), joined.User)
+		left := <-events
+		assert.Equal(t, EventSessionLeft, left.Type)
+		assert.Equal(t, joined.SessionId, left.SessionId)
+	})
+}
@@ -2,14 +2,21 @@ package lobby
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/url"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/pion/webrtc/v3"
+	"github.com/shigde/sfu/internal/compositor"
+	"github.com/shigde/sfu/internal/hls"
 	"github.com/shigde/sfu/internal/lobby/commands"
 	"github.com/shigde/sfu/internal/lobby/resources"
 	"github.com/shigde/sfu/internal/lobby/sessions"
+	"github.com/shigde/sfu/internal/record"
+	"github.com/shigde/sfu/internal/rtmp"
+	"github.com/shigde/sfu/internal/rtp"
 	"github.com/shigde/sfu/internal/storage"
 	"golang.org/x/exp/slog"
 )
@@ -17,10 +24,13 @@ import (
 type LobbyManager struct {
 	lobbies      *lobbyRepository
 	lobbyGarbage chan<- lobbyItem
+	senderConfig *rtp.LiveStreamSenderConfig
+	ports        *rtp.PortAllocator
+	recordConfig *record.RecordConfig
 }
 
-func NewLobbyManager(storage storage.Storage, e sessions.RtpEngine, homeUrl *url.URL, registerToken string) *LobbyManager {
-	lobbyRep := newLobbyRepository(storage, e, homeUrl, registerToken)
+func NewLobbyManager(storage storage.Storage, e sessions.RtpEngine, homeUrl *url.URL, registerToken string, hlsConfig *hls.HlsConfig, senderConfig *rtp.LiveStreamSenderConfig, recordConfig *record.RecordConfig, compositorConfig *compositor.CompositorConfig, gracePeriod time.Duration) *LobbyManager {
+	lobbyRep := newLobbyRepository(storage, e, homeUrl, registerToken, hlsConfig, compositorConfig, gracePeriod)
 	lobbyGarbage := make(chan lobbyItem)
 
 	go func() {
@@ -33,23 +43,38 @@ func NewLobbyManager(storage storage.Storage, e sessions.RtpEngine, homeUrl *url
 			item.Done <- ok
 		}
 	}()
-	return &LobbyManager{lobbyRep, lobbyGarbage}
+	return &LobbyManager{lobbyRep, lobbyGarbage, senderConfig, newPortAllocator(senderConfig), recordConfig}
 }
 
 func (m *LobbyManager) NewIngressResource(ctx context.Context, lobbyId uuid.UUID, user uuid.UUID, offer *webrtc.SessionDescription, option ...resources.Option) (*resources.WebRTC, error) {
+	if err := m.checkBan(ctx, lobbyId, user); err != nil {
+		return nil, err
+	}
+	if token := resumeToken(option...); token != "" {
+		return m.resumeIngressResource(ctx, lobbyId, user, offer, token)
+	}
+	role, err := m.resolveRole(ctx, lobbyId, user, option...)
+	if err != nil {
+		return nil, err
+	}
 	lobbyObj, err := m.lobbies.getOrCreateLobby(ctx, lobbyId, m.lobbyGarbage)
 	if err != nil {
 		return nil, fmt.Errorf("getting or creating lobby: %w", err)
 	}
-	if ok := lobbyObj.newSession(user, sessions.UserSession); !ok {
+	sessionType, signalKind := sessionOf(option...)
+	if ok := lobbyObj.newSession(user, sessionType, role); !ok {
 		return nil, fmt.Errorf("creating new session failes")
 	}
 
-	cmd := commands.NewCreateIngress(ctx, user, offer, sessions.UnidirectionalSignalChannel)
+	cmd := commands.NewCreateIngress(ctx, user, offer, signalKind)
 	lobbyObj.runCommand(cmd)
 
 	select {
 	case <-cmd.Done():
+		if errors.Is(cmd.Err, ErrPermissionDenied) {
+			// the session of a user who is not allowed to publish would keep the lobby running
+			lobbyObj.removeSession(user)
+		}
 		return cmd.Response, cmd.Err
 	case <-ctx.Done():
 		return nil, fmt.Errorf("time out")
@@ -57,12 +82,67 @@ func (m *LobbyManager) NewIngressResource(ctx context.Context, lobbyId uuid.UUID
 }
 
 func (m *LobbyManager) NewEgressResource(ctx context.Context, lobbyId uuid.UUID, user uuid.UUID, offer *webrtc.SessionDescription, option ...resources.Option) (*resources.WebRTC, error) {
+	if err := m.checkBan(ctx, lobbyId, user); err != nil {
+		return nil, err
+	}
+	if token := resumeToken(option...); token != "" {
+		return m.resumeEgressResource(ctx, lobbyId, user, offer, token)
+	}
+	role, err := m.resolveRole(ctx, lobbyId, user, option...)
+	if err != nil {
+		return nil, err
+	}
 	lobbyObj, err := m.lobbies.getOrCreateLobby(ctx, lobbyId, m.lobbyGarbage)
 	if err != nil {
 		return nil, fmt.Errorf("getting or creating lobby: %w", err)
 	}
+	// viewers only subscribe, their session is created with the egress resource
+	sessionType, signalKind := sessionOf(option...)
+	if _, found := lobbyObj.sessions.FindByUserId(user); !found {
+		lobbyObj.newSession(user, sessionType, role)
+	}
+	// instances signal over the channel of their egress endpoint, the channel of the ingress endpoint is silent
+	if sessionType == sessions.RemoteInstanceSession {
+		signalKind = sessions.SilentSignalChannel
+	}
+
+	cmd := commands.NewCreateEgress(ctx, user, offer, signalKind)
+	lobbyObj.runCommand(cmd)
+
+	select {
+	case <-cmd.Done():
+		return cmd.Response, cmd.Err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("time out")
+	}
+}
+
+// resumeIngressResource reconnects the ingress endpoint of a client to its session, the session keeps its id and its tracks
+func (m *LobbyManager) resumeIngressResource(ctx context.Context, lobbyId uuid.UUID, user uuid.UUID, offer *webrtc.SessionDescription, token string) (*resources.WebRTC, error) {
+	lobbyObj, ok := m.lobbies.getLobby(lobbyId)
+	if !ok {
+		return nil, ErrNoSession
+	}
+
+	cmd := commands.NewResumeIngress(ctx, user, offer, sessions.UnidirectionalSignalChannel, token)
+	lobbyObj.runCommand(cmd)
+
+	select {
+	case <-cmd.Done():
+		return cmd.Response, cmd.Err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("time out")
+	}
+}
+
+// resumeEgressResource reconnects the egress endpoint of a client to its session
+func (m *LobbyManager) resumeEgressResource(ctx context.Context, lobbyId uuid.UUID, user uuid.UUID, offer *webrtc.SessionDescription, token string) (*resources.WebRTC, error) {
+	lobbyObj, ok := m.lobbies.getLobby(lobbyId)
+	if !ok {
+		return nil, ErrNoSession
+	}
 
-	cmd := commands.NewCreateEgress(ctx, user, offer, sessions.UnidirectionalSignalChannel)
+	cmd := commands.NewResumeEgress(ctx, user, offer, sessions.UnidirectionalSignalChannel, token)
 	lobbyObj.runCommand(cmd)
 
 	select {
@@ -73,8 +153,96 @@ func (m *LobbyManager) NewEgressResource(ctx context.Context, lobbyId uuid.UUID,
 	}
 }
 
+// TrickleIce adds the trickled ICE candidates of a client to its session and returns the local ICE credentials and candidates,
+// ICE restarts return the new ones
+func (m *LobbyManager) TrickleIce(ctx context.Context, lobbyId uuid.UUID, user uuid.UUID, fragment *rtp.SdpFragment, iceSession ...resources.IceSession) (*rtp.SdpFragment, error) {
+	lobbyObj, ok := m.lobbies.getLobby(lobbyId)
+	if !ok {
+		return nil, ErrLobbyNotRunning
+	}
+	var selected resources.IceSession
+	for _, ice := range iceSession {
+		selected = ice
+	}
+	if !lobbyObj.hasResource(user, selected.Resource) {
+		return nil, ErrNoSession
+	}
+
+	cmd := commands.NewTrickleIce(ctx, user, fragment, selected)
+	lobbyObj.runCommand(cmd)
+
+	select {
+	case <-cmd.Done():
+		return cmd.Response, cmd.Err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("time out")
+	}
+}
+
+// DeleteResource closes the endpoint of the WHIP or WHEP resource, the resources of a user share the session.
+// The session leaves the lobby together with its last endpoint.
+func (m *LobbyManager) DeleteResource(ctx context.Context, lobbyId uuid.UUID, user uuid.UUID, resource uuid.UUID, endpointType rtp.EndpointType) (bool, error) {
+	lobbyObj, ok := m.lobbies.getLobby(lobbyId)
+	if !ok {
+		return false, ErrLobbyNotRunning
+	}
+	if !lobbyObj.hasResource(user, resource) {
+		return false, ErrNoSession
+	}
+	return lobbyObj.deleteResource(user, endpointType), nil
+}
+
 func (m *LobbyManager) LeaveLobby(ctx context.Context, lobbyId uuid.UUID, userId uuid.UUID) (bool, error) {
-	return false, nil
+	lobbyObj, ok := m.lobbies.getLobby(lobbyId)
+	if !ok {
+		return false, ErrLobbyNotRunning
+	}
+	return lobbyObj.leave(userId), nil
+}
+
+func (m *LobbyManager) resolveRole(ctx context.Context, lobbyId uuid.UUID, user uuid.UUID, option ...resources.Option) (sessions.Role, error) {
+	resolved := sessions.RoleViewer
+	for _, opt := range option {
+		if opt.Role != "" {
+			resolved = opt.Role
+		}
+	}
+	role, err := m.lobbies.resolveRole(ctx, lobbyId, user, resolved)
+	if err != nil {
+		return "", fmt.Errorf("resolving role: %w", err)
+	}
+	return role, nil
+}
+
+func resumeToken(option ...resources.Option) string {
+	token := ""
+	for _, opt := range option {
+		if opt.ResumeToken != "" {
+			token = opt.ResumeToken
+		}
+	}
+	return token
+}
+
+// sessionOf returns the session type and the signal channel kind of the resources of a user or another Shig instance
+func sessionOf(option ...resources.Option) (sessions.SessionType, sessions.SignalChannelKind) {
+	for _, opt := range option {
+		if opt.Instance {
+			return sessions.RemoteInstanceSession, sessions.BidirectionalSignalChannel
+		}
+	}
+	return sessions.UserSession, sessions.UnidirectionalSignalChannel
+}
+
+func (m *LobbyManager) checkBan(ctx context.Context, lobbyId uuid.UUID, user uuid.UUID) error {
+	banned, err := m.lobbies.isBanned(ctx, lobbyId, user)
+	if err != nil {
+		return fmt.Errorf("checking ban list: %w", err)
+	}
+	if banned {
+		return ErrUserBanned
+	}
+	return nil
 }
 
 // Live Stream Publish API
@@ -86,6 +254,21 @@ func (m *LobbyManager) StartLiveStream(
 	rtmpUrl string,
 	userId uuid.UUID,
 ) error {
+	lobbyObj, ok := m.lobbies.getLobby(lobbyId)
+	if !ok {
+		return ErrLobbyNotRunning
+	}
+	onStatus := func(status rtmp.Status, err error) {
+		slog.Info("lobby.LobbyManager: live stream status", "lobby", lobbyId, "status", status, "err", err)
+		m.lobbies.setLiveStatus(context.Background(), lobbyId, status, err)
+	}
+	if err := lobbyObj.startLiveStream(rtmpUrl, key, m.senderConfig, m.ports, onStatus); err != nil {
+		if errors.Is(err, rtp.ErrNoPortAvailable) {
+			// the owner of the stream can see why the stream is not live
+			m.lobbies.setLiveStatus(ctx, lobbyId, rtmp.StatusFailed, err)
+		}
+		return fmt.Errorf("starting live stream: %w", err)
+	}
 	return nil
 }
 
@@ -94,9 +277,153 @@ func (m *LobbyManager) StopLiveStream(
 	lobbyId uuid.UUID,
 	userId uuid.UUID,
 ) error {
+	lobbyObj, ok := m.lobbies.getLobby(lobbyId)
+	if !ok {
+		return ErrLobbyNotRunning
+	}
+	if err := lobbyObj.stopLiveStream(); err != nil {
+		return fmt.Errorf("stopping live stream: %w", err)
+	}
+	return nil
+}
+
+// Recording API
+
+func (m *LobbyManager) StartRecording(ctx context.Context, lobbyId uuid.UUID, userId uuid.UUID) error {
+	if m.recordConfig == nil || !m.recordConfig.Enable {
+		return ErrRecordingDisabled
+	}
+	lobbyObj, ok := m.lobbies.getLobby(lobbyId)
+	if !ok {
+		return ErrLobbyNotRunning
+	}
+	dir, err := lobbyObj.startRecording(m.recordConfig)
+	if err != nil {
+		return fmt.Errorf("starting recording: %w", err)
+	}
+	m.lobbies.setRecording(ctx, lobbyId, true)
+	slog.Info("lobby.LobbyManager: recording started", "lobby", lobbyId, "dir", dir)
+	return nil
+}
+
+func (m *LobbyManager) StopRecording(ctx context.Context, lobbyId uuid.UUID, userId uuid.UUID) error {
+	if m.recordConfig == nil || !m.recordConfig.Enable {
+		return ErrRecordingDisabled
+	}
+	lobbyObj, ok := m.lobbies.getLobby(lobbyId)
+	if !ok {
+		return ErrLobbyNotRunning
+	}
+	if err := lobbyObj.stopRecording(); err != nil {
+		return fmt.Errorf("stopping recording: %w", err)
+	}
+	m.lobbies.setRecording(ctx, lobbyId, false)
+	return nil
+}
+
+// Forwarding API
+
+// SetLastN limits the guest videos each session of the lobby receives to the N most recently active speakers, 0 forwards all videos
+func (m *LobbyManager) SetLastN(ctx context.Context, lobbyId uuid.UUID, lastN int, userId uuid.UUID) error {
+	if lastN < 0 {
+		return ErrInvalidLastN
+	}
+	if err := m.lobbies.setLastN(ctx, lobbyId, lastN); err != nil {
+		return fmt.Errorf("setting last-n: %w", err)
+	}
+	return nil
+}
+
+// Event API
+
+// SubscribeEvents returns the lifecycle events of the lobby until the context is done.
+// The subscription outlives the lobby, a subscriber sees when the lobby starts again.
+func (m *LobbyManager) SubscribeEvents(ctx context.Context, lobbyId uuid.UUID, userId uuid.UUID) <-chan LobbyEvent {
+	return m.lobbies.events.subscribe(ctx, lobbyId)
+}
+
+// Moderation API
+
+// MuteUser mutes the tracks of a kind of a lobby user for all other sessions, all tracks if the kind is empty
+func (m *LobbyManager) MuteUser(ctx context.Context, lobbyId uuid.UUID, targetUserId uuid.UUID, kind string) error {
+	lobbyObj, ok := m.lobbies.getLobby(lobbyId)
+	if !ok {
+		return ErrLobbyNotRunning
+	}
+	if err := lobbyObj.mute(ctx, targetUserId, webrtc.NewRTPCodecType(kind)); err != nil {
+		return fmt.Errorf("muting user: %w", err)
+	}
+	return nil
+}
+
+func (m *LobbyManager) KickUser(ctx context.Context, lobbyId uuid.UUID, targetUserId uuid.UUID) error {
+	lobbyObj, ok := m.lobbies.getLobby(lobbyId)
+	if !ok {
+		return ErrLobbyNotRunning
+	}
+	if err := lobbyObj.kick(targetUserId); err != nil {
+		return fmt.Errorf("kicking user: %w", err)
+	}
 	return nil
 }
 
+// BanUser bans the user from the lobby, a connected user is removed from the lobby
+func (m *LobbyManager) BanUser(ctx context.Context, lobbyId uuid.UUID, targetUserId uuid.UUID) error {
+	if err := m.lobbies.setBan(ctx, lobbyId, targetUserId, true); err != nil {
+		return fmt.Errorf("banning user: %w", err)
+	}
+	if lobbyObj, ok := m.lobbies.getLobby(lobbyId); ok {
+		if err := lobbyObj.kick(targetUserId); err != nil && !errors.Is(err, ErrNoSession) {
+			return fmt.Errorf("kicking banned user: %w", err)
+		}
+	}
+	return nil
+}
+
+func (m *LobbyManager) UnbanUser(ctx context.Context, lobbyId uuid.UUID, targetUserId uuid.UUID) error {
+	if err := m.lobbies.setBan(ctx, lobbyId, targetUserId, false); err != nil {
+		return fmt.Errorf("unbanning user: %w", err)
+	}
+	return nil
+}
+
+func (m *LobbyManager) GetBannedUsers(ctx context.Context, lobbyId uuid.UUID) ([]uuid.UUID, error) {
+	bannedUsers, err := m.lobbies.getBannedUsers(ctx, lobbyId)
+	if err != nil {
+		return nil, fmt.Errorf("getting banned users: %w", err)
+	}
+	return bannedUsers, nil
+}
+
+// SetRole grants a lobby role to the user, the host role can not be granted
+func (m *LobbyManager) SetRole(ctx context.Context, lobbyId uuid.UUID, targetUserId uuid.UUID, role sessions.Role) error {
+	if !role.IsValid() || role == sessions.RoleHost {
+		return ErrInvalidRole
+	}
+	if lobbyObj, ok := m.lobbies.getLobby(lobbyId); ok {
+		if session, found := lobbyObj.sessions.FindByUserId(targetUserId); found && session.GetRole() == sessions.RoleHost {
+			return ErrPermissionDenied
+		}
+	}
+	if err := m.lobbies.setRole(ctx, lobbyId, targetUserId, role); err != nil {
+		return fmt.Errorf("setting role: %w", err)
+	}
+	return nil
+}
+
+// newPortAllocator returns nil if the live stream sender is disabled
+func newPortAllocator(senderConfig *rtp.LiveStreamSenderConfig) *rtp.PortAllocator {
+	if senderConfig == nil || !senderConfig.Enable {
+		return nil
+	}
+	ports, err := rtp.NewPortAllocator(senderConfig.PortMin, senderConfig.PortMax)
+	if err != nil {
+		slog.Error("lobby.LobbyManager: creating port allocator", "err", err)
+		return nil
+	}
+	return ports
+}
+
 // Old API -----------------------------------
 
 // CreateLobbyIngressEndpoint
@@ -8,6 +8,8 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/shigde/sfu/internal/lobby/mocks"
+	"github.com/shigde/sfu/internal/lobby/resources"
+	"github.com/shigde/sfu/internal/lobby/sessions"
 	"github.com/shigde/sfu/internal/storage"
 	"github.com/stretchr/testify/assert"
 )
@@ -30,7 +32,7 @@ func testLobbyManagerSetup(t *testing.T) (*LobbyManager, uuid.UUID, *mocks.RtpEn
 		Host:         fmt.Sprintf("%s/federation/accounts/shig-test", homeUrl.Host),
 	}
 	store.GetDatabase().Create(entity)
-	manager := NewLobbyManager(store, rtp, homeUrl, registerToken)
+	manager := NewLobbyManager(store, rtp, homeUrl, registerToken, nil, nil, nil, nil, 0)
 
 	return manager, lobbyId, rtp
 }
@@ -38,8 +40,95 @@ func testLobbyManagerSetup(t *testing.T) (*LobbyManager, uuid.UUID, *mocks.RtpEn
 func TestLobbyManager_NewIngressResource(t *testing.T) {
 	t.Run("get ingress resource", func(t *testing.T) {
 		manager, lobbyId, _ := testLobbyManagerSetup(t)
-		resource, err := manager.NewIngressResource(context.Background(), lobbyId, uuid.New(), mocks.Offer)
+		resource, err := manager.NewIngressResource(context.Background(), lobbyId, uuid.New(), mocks.Offer, resources.Option{Role: sessions.RoleGuest})
 		assert.NoError(t, err)
 		assert.Equal(t, mocks.Answer, resource.SDP)
 	})
+
+	t.Run("viewer is not allowed to publish", func(t *testing.T) {
+		manager, lobbyId, _ := testLobbyManagerSetup(t)
+		_, err := manager.NewIngressResource(context.Background(), lobbyId, uuid.New(), mocks.Offer)
+		assert.ErrorIs(t, err, ErrPermissionDenied)
+	})
+
+	t.Run("get ingress resource of another instance", func(t *testing.T) {
+		manager, lobbyId, _ := testLobbyManagerSetup(t)
+		resource, err := manager.NewIngressResource(context.Background(), lobbyId, uuid.New(), mocks.Offer, resources.Option{Role: sessions.RoleGuest, Instance: true})
+		assert.NoError(t, err)
+		assert.Equal(t, mocks.Answer, resource.SDP)
+
+		sessionType, signalKind := sessionOf(resources.Option{Instance: true})
+		assert.Equal(t, sessions.RemoteInstanceSession, sessionType)
+		assert.Equal(t, sessions.BidirectionalSignalChannel, signalKind)
+	})
+
+	t.Run("granted role overrides resolved role", func(t *testing.T) {
+		manager, lobbyId, _ := testLobbyManagerSetup(t)
+		user := uuid.New()
+		err := manager.SetRole(context.Background(), lobbyId, user, sessions.RoleCoHost)
+		assert.NoError(t, err)
+
+		resource, err := manager.NewIngressResource(context.Background(), lobbyId, user, mocks.Offer)
+		assert.NoError(t, err)
+		assert.Equal(t, mocks.Answer, resource.SDP)
+	})
+}
+
+func TestLobbyManager_SetRole(t *testing.T) {
+	t.Run("host role can not be granted", func(t *testing.T) {
+		manager, lobbyId, _ := testLobbyManagerSetup(t)
+		err := manager.SetRole(context.Background(), lobbyId, uuid.New(), sessions.RoleHost)
+		assert.ErrorIs(t, err, ErrInvalidRole)
+	})
+
+	t.Run("unknown role can not be granted", func(t *testing.T) {
+		manager, lobbyId, _ := testLobbyManagerSetup(t)
+		err := manager.SetRole(context.Background(), lobbyId, uuid.New(), sessions.Role("admin"))
+		assert.ErrorIs(t, err, ErrInvalidRole)
+	})
+}
+
+func TestLobbyManager_Moderation(t *testing.T) {
+	t.Run("reject banned user", func(t *testing.T) {
+		manager, lobbyId, _ := testLobbyManagerSetup(t)
+		user := uuid.New()
+		err := manager.BanUser(context.Background(), lobbyId, user)
+		assert.NoError(t, err)
+
+		_, err = manager.NewIngressResource(context.Background(), lobbyId, user, mocks.Offer)
+		assert.ErrorIs(t, err, ErrUserBanned)
+		_, err = manager.NewEgressResource(context.Background(), lobbyId, user, mocks.Offer)
+		assert.ErrorIs(t, err, ErrUserBanned)
+	})
+
+	t.Run("unban user", func(t *testing.T) {
+		manager, lobbyId, _ := testLobbyManagerSetup(t)
+		user := uuid.New()
+		_ = manager.BanUser(context.Background(), lobbyId, user)
+		bannedUsers, err := manager.GetBannedUsers(context.Background(), lobbyId)
+		assert.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{user}, bannedUsers)
+
+		err = manager.UnbanUser(context.Background(), lobbyId, user)
+		assert.NoError(t, err)
+		bannedUsers, _ = manager.GetBannedUsers(context.Background(), lobbyId)
+		assert.Empty(t, bannedUsers)
+
+		resource, err := manager.NewIngressResource(context.Background(), lobbyId, user, mocks.Offer, resources.Option{Role: sessions.RoleGuest})
+		assert.NoError(t, err)
+		assert.Equal(t, mocks.Answer, resource.SDP)
+	})
+
+	t.Run("kick user of not running lobby", func(t *testing.T) {
+		manager, lobbyId, _ := testLobbyManagerSetup(t)
+		err := manager.KickUser(context.Background(), lobbyId, uuid.New())
+		assert.ErrorIs(t, err, ErrLobbyNotRunning)
+	})
+
+	t.Run("leave not running lobby", func(t *testing.T) {
+		manager, lobbyId, _ := testLobbyManagerSetup(t)
+		left, err := manager.LeaveLobby(context.Background(), lobbyId, uuid.New())
+		assert.ErrorIs(t, err, ErrLobbyNotRunning)
+		assert.False(t, left)
+	})
 }
@@ -6,10 +6,14 @@ import (
 	"fmt"
 	"net/url"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/shigde/sfu/internal/compositor"
+	"github.com/shigde/sfu/internal/hls"
 	"github.com/shigde/sfu/internal/lobby/sessions"
 	"github.com/shigde/sfu/internal/metric"
+	"github.com/shigde/sfu/internal/rtmp"
 	"github.com/shigde/sfu/internal/storage"
 	"golang.org/x/exp/slog"
 	"gorm.io/gorm"
@@ -18,15 +22,19 @@ import (
 var errLobbyNotFound = errors.New("lobby not found")
 
 type lobbyRepository struct {
-	locker        *sync.RWMutex
-	lobbies       map[uuid.UUID]*lobby
-	homeActorIri  *url.URL
-	registerToken string
-	store         storage.Storage
-	rtpEngine     sessions.RtpEngine
+	locker           *sync.RWMutex
+	lobbies          map[uuid.UUID]*lobby
+	homeActorIri     *url.URL
+	registerToken    string
+	store            storage.Storage
+	rtpEngine        sessions.RtpEngine
+	hlsConfig        *hls.HlsConfig
+	compositorConfig *compositor.CompositorConfig
+	gracePeriod      time.Duration
+	events           *eventBroker
 }
 
-func newLobbyRepository(store storage.Storage, rtpEngine sessions.RtpEngine, hostUrl *url.URL, registerToken string) *lobbyRepository {
+func newLobbyRepository(store storage.Storage, rtpEngine sessions.RtpEngine, hostUrl *url.URL, registerToken string, hlsConfig *hls.HlsConfig, compositorConfig *compositor.CompositorConfig, gracePeriod time.Duration) *lobbyRepository {
 	lobbies := make(map[uuid.UUID]*lobby)
 	return &lobbyRepository{
 		&sync.RWMutex{},
@@ -35,6 +43,10 @@ func newLobbyRepository(store storage.Storage, rtpEngine sessions.RtpEngine, hos
 		registerToken,
 		store,
 		rtpEngine,
+		hlsConfig,
+		compositorConfig,
+		gracePeriod,
+		newEventBroker(),
 	}
 }
 
@@ -53,7 +65,10 @@ func (r *lobbyRepository) getOrCreateLobby(ctx context.Context, lobbyId uuid.UUI
 			return nil, fmt.Errorf("updating lobby entity as running: %w", err)
 		}
 
-		lobby := newLobby(entity, r.rtpEngine, r.homeActorIri, r.registerToken, lobbyGarbage)
+		lobby := newLobby(entity, r.rtpEngine, r.homeActorIri, r.registerToken, r.hlsConfig, r.compositorConfig, r.gracePeriod, r.events, lobbyGarbage)
+		lobby.onBan = func(ctx context.Context, userId uuid.UUID) error {
+			return r.setBan(ctx, lobbyId, userId, true)
+		}
 		r.lobbies[lobbyId] = lobby
 		metric.RunningLobbyInc(lobby.entity.LiveStreamId.String(), lobbyId.String())
 		return lobby, nil
@@ -79,6 +94,170 @@ func (r *lobbyRepository) setLobbyLive(ctx context.Context, id uuid.UUID, isLive
 	return false
 }
 
+// setLiveStatus persists the status of the rtmp publisher, so that the status can be requested over the live stream api
+func (r *lobbyRepository) setLiveStatus(ctx context.Context, id uuid.UUID, status rtmp.Status, liveErr error) bool {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	if currentLobby, ok := r.lobbies[id]; ok {
+		currentLobby.entity.IsLive = status == rtmp.StatusConnecting || status == rtmp.StatusPublishing || status == rtmp.StatusReconnecting
+		currentLobby.entity.LiveStatus = string(status)
+		currentLobby.entity.LiveError = ""
+		if liveErr != nil {
+			currentLobby.entity.LiveError = liveErr.Error()
+		}
+		if _, err := r.updateLobbyEntity(ctx, currentLobby.entity); err != nil {
+			slog.Error("lobby.lobbyRepository: can not update live status", "err", err, "lobby", id)
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+func (r *lobbyRepository) setRecording(ctx context.Context, id uuid.UUID, isRecording bool) bool {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	if currentLobby, ok := r.lobbies[id]; ok {
+		currentLobby.entity.IsRecording = isRecording
+		if _, err := r.updateLobbyEntity(ctx, currentLobby.entity); err != nil {
+			slog.Error("lobby.lobbyRepository: can not update recording state", "err", err, "lobby", id)
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// setLastN persists the last-N setting of a lobby, a running lobby applies it immediately
+func (r *lobbyRepository) setLastN(ctx context.Context, id uuid.UUID, lastN int) error {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	currentLobby, err := r.modifyLobbyEntity(ctx, id, func(entity *LobbyEntity) {
+		entity.LastN = lastN
+	})
+	if err != nil {
+		return fmt.Errorf("updating last-n: %w", err)
+	}
+	if currentLobby != nil {
+		currentLobby.setLastN(lastN)
+	}
+	return nil
+}
+
+// setBan adds the user to the ban list of the lobby or removes it from the list
+func (r *lobbyRepository) setBan(ctx context.Context, id uuid.UUID, userId uuid.UUID, banned bool) error {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	_, err := r.modifyLobbyEntity(ctx, id, func(entity *LobbyEntity) {
+		bannedUsers := make([]uuid.UUID, 0, len(entity.BannedUsers)+1)
+		for _, bannedUser := range entity.BannedUsers {
+			if bannedUser != userId {
+				bannedUsers = append(bannedUsers, bannedUser)
+			}
+		}
+		if banned {
+			bannedUsers = append(bannedUsers, userId)
+		}
+		entity.BannedUsers = bannedUsers
+	})
+	if err != nil {
+		return fmt.Errorf("updating ban list: %w", err)
+	}
+	return nil
+}
+
+func (r *lobbyRepository) getBannedUsers(ctx context.Context, id uuid.UUID) ([]uuid.UUID, error) {
+	r.locker.RLock()
+	defer r.locker.RUnlock()
+	entity, err := r.getLobbyEntity(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("fetching lobby entity: %w", err)
+	}
+	return append([]uuid.UUID{}, entity.BannedUsers...), nil
+}
+
+func (r *lobbyRepository) isBanned(ctx context.Context, id uuid.UUID, userId uuid.UUID) (bool, error) {
+	bannedUsers, err := r.getBannedUsers(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	for _, bannedUser := range bannedUsers {
+		if bannedUser == userId {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// setRole grants a role to the user in the lobby
+func (r *lobbyRepository) setRole(ctx context.Context, id uuid.UUID, userId uuid.UUID, role sessions.Role) error {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	currentLobby, err := r.modifyLobbyEntity(ctx, id, func(entity *LobbyEntity) {
+		if entity.Roles == nil {
+			entity.Roles = make(map[uuid.UUID]sessions.Role)
+		}
+		entity.Roles[userId] = role
+	})
+	if err != nil {
+		return fmt.Errorf("updating roles: %w", err)
+	}
+	if currentLobby != nil {
+		currentLobby.setRole(userId, role)
+	}
+	return nil
+}
+
+// resolveRole returns the role of the user in the lobby. A granted role overrides the resolved role, but the host stays host.
+func (r *lobbyRepository) resolveRole(ctx context.Context, id uuid.UUID, userId uuid.UUID, resolved sessions.Role) (sessions.Role, error) {
+	if resolved == sessions.RoleHost {
+		return resolved, nil
+	}
+	r.locker.RLock()
+	defer r.locker.RUnlock()
+	entity, err := r.getLobbyEntity(ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("fetching lobby entity: %w", err)
+	}
+	if granted, ok := entity.Roles[userId]; ok {
+		return granted, nil
+	}
+	if !resolved.IsValid() {
+		return sessions.RoleViewer, nil
+	}
+	return resolved, nil
+}
+
+// getLobbyEntity returns the entity of a running lobby or queries it. The caller must hold the lock.
+func (r *lobbyRepository) getLobbyEntity(ctx context.Context, id uuid.UUID) (*LobbyEntity, error) {
+	if currentLobby, ok := r.lobbies[id]; ok {
+		return currentLobby.entity, nil
+	}
+	return r.queryLobbyEntity(ctx, id.String())
+}
+
+// modifyLobbyEntity changes and persists the entity of a lobby, whether the lobby is running or not.
+// It returns the running lobby or nil. The caller must hold the lock.
+func (r *lobbyRepository) modifyLobbyEntity(ctx context.Context, id uuid.UUID, modify func(entity *LobbyEntity)) (*lobby, error) {
+	if currentLobby, ok := r.lobbies[id]; ok {
+		modify(currentLobby.entity)
+		if _, err := r.updateLobbyEntity(ctx, currentLobby.entity); err != nil {
+			return nil, err
+		}
+		return currentLobby, nil
+	}
+
+	entity, err := r.queryLobbyEntity(ctx, id.String())
+	if err != nil {
+		return nil, fmt.Errorf("fetching lobby entity: %w", err)
+	}
+	modify(entity)
+	if _, err := r.updateLobbyEntity(ctx, entity); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
 func (r *lobbyRepository) delete(ctx context.Context, id uuid.UUID) bool {
 	r.locker.Lock()
 	defer r.locker.Unlock()
@@ -89,6 +268,12 @@ func (r *lobbyRepository) delete(ctx context.Context, id uuid.UUID) bool {
 			return false
 		}
 		lobby.entity.IsRunning = false
+		if lobby.entity.IsLive {
+			lobby.entity.IsLive = false
+			lobby.entity.LiveStatus = string(rtmp.StatusStopped)
+		}
+		// the recording stops with the lobby
+		lobby.entity.IsRecording = false
 		if _, err := r.updateLobbyEntity(ctx, lobby.entity); err != nil {
 			slog.Error("can not update lobby entity on delete lobby", "err", err, "lobby", id)
 			return false
@@ -23,7 +23,7 @@ func testLobbyRepositorySetup(t *testing.T) *lobbyRepository {
 	_ = store.GetDatabase().AutoMigrate(&LobbyEntity{Host: homeActorIri.String()})
 
 	var engine sessions.RtpEngine
-	repository := newLobbyRepository(store, engine, homeActorIri, "test-key")
+	repository := newLobbyRepository(store, engine, homeActorIri, "test-key", nil, nil, 0)
 
 	return repository
 }
@@ -13,6 +13,7 @@ import (
 	"github.com/shigde/sfu/internal/lobby/resources"
 	"github.com/shigde/sfu/internal/lobby/sessions"
 	"github.com/shigde/sfu/internal/logging"
+	"github.com/shigde/sfu/internal/rtp"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -29,9 +30,9 @@ func testLobbySetup(t *testing.T) (*lobby, uuid.UUID) {
 		Host:         hostActorIri.String(),
 	}
 
-	lobby := newLobby(entity, nil, homeActorIri, "token", make(chan<- lobbyItem, 1))
+	lobby := newLobby(entity, nil, homeActorIri, "token", nil, nil, 0, nil, make(chan<- lobbyItem, 1))
 	user := uuid.New()
-	lobby.newSession(user, sessions.UserSession)
+	lobby.newSession(user, sessions.UserSession, sessions.RoleGuest)
 	return lobby, user
 }
 func TestLobby_handle(t *testing.T) {
@@ -84,6 +85,22 @@ func TestLobby_handle(t *testing.T) {
 		}
 	})
 
+	t.Run("command fails, because role of session has no permission", func(t *testing.T) {
+		lobby, _ := testLobbySetup(t)
+		viewer := uuid.New()
+		lobby.newSession(viewer, sessions.UserSession, sessions.RoleViewer)
+		cmd := commands.NewCreateIngress(context.Background(), viewer, mocks.Offer, sessions.UnidirectionalSignalChannel)
+		lobby.runCommand(cmd)
+
+		select {
+		case <-cmd.Done():
+			assert.Nil(t, cmd.Response)
+			assert.ErrorIs(t, cmd.Err, ErrPermissionDenied)
+		case <-time.After(time.Second * 3):
+			t.Fatalf("run in timeout")
+		}
+	})
+
 	t.Run("command fails, because lobby context was done", func(t *testing.T) {
 		lobby, user := testLobbySetup(t)
 		cmd := newCmdMock(context.Background(), user)
@@ -107,7 +124,7 @@ func TestLobby_newSession(t *testing.T) {
 	t.Run("new session added", func(t *testing.T) {
 		lobby, _ := testLobbySetup(t)
 		user := uuid.New()
-		ok := lobby.newSession(user, sessions.UserSession)
+		ok := lobby.newSession(user, sessions.UserSession, sessions.RoleGuest)
 		assert.True(t, ok)
 		_, found := lobby.sessions.FindByUserId(user)
 		assert.True(t, found)
@@ -115,7 +132,7 @@ func TestLobby_newSession(t *testing.T) {
 
 	t.Run("not add already existing session", func(t *testing.T) {
 		lobby, user := testLobbySetup(t)
-		ok := lobby.newSession(user, sessions.UserSession)
+		ok := lobby.newSession(user, sessions.UserSession, sessions.RoleGuest)
 		assert.False(t, ok)
 		_, found := lobby.sessions.FindByUserId(user)
 		assert.True(t, found)
@@ -170,9 +187,9 @@ func TestLobby_sessionSequence(t *testing.T) {
 		user3 := uuid.New()
 		user4 := uuid.New()
 
-		ok := lobby.newSession(user2, sessions.UserSession)
+		ok := lobby.newSession(user2, sessions.UserSession, sessions.RoleGuest)
 		assert.True(t, ok)
-		ok = lobby.newSession(user3, sessions.UserSession)
+		ok = lobby.newSession(user3, sessions.UserSession, sessions.RoleGuest)
 		assert.True(t, ok)
 
 		ok = lobby.removeSession(user2)
@@ -187,7 +204,7 @@ func TestLobby_sessionSequence(t *testing.T) {
 		item.Done <- true
 
 		// we can not add a new session in a closed lobby
-		ok = lobby.newSession(user4, sessions.UserSession)
+		ok = lobby.newSession(user4, sessions.UserSession, sessions.RoleGuest)
 		assert.False(t, ok)
 		assert.Equal(t, 0, lobby.sessions.Len())
 		select {
@@ -221,3 +238,19 @@ func (mc *mockCmd) Execute(session *sessions.Session) {
 	mc.Response = res
 	mc.SetDone()
 }
+
+func TestLobby_deleteResource(t *testing.T) {
+	t.Run("leave lobby with last endpoint of session", func(t *testing.T) {
+		lobby, user := testLobbySetup(t)
+		ok := lobby.deleteResource(user, rtp.EgressEndpoint)
+		assert.True(t, ok)
+		_, found := lobby.sessions.FindByUserId(user)
+		assert.False(t, found)
+	})
+
+	t.Run("delete resource of unknown user", func(t *testing.T) {
+		lobby, _ := testLobbySetup(t)
+		ok := lobby.deleteResource(uuid.New(), rtp.IngressEndpoint)
+		assert.False(t, ok)
+	})
+}
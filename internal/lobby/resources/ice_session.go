@@ -0,0 +1,15 @@
+package resources
+
+import (
+	"github.com/google/uuid"
+	"github.com/shigde/sfu/internal/rtp"
+)
+
+// IceSession selects the ICE session of a resource the trickled candidates of a client belong to
+type IceSession struct {
+	// Resource of the user, a session is the resource of its endpoints
+	Resource uuid.UUID
+	Endpoint rtp.EndpointType
+	// ETag of the ICE session the client trickles for, "*" to restart ICE
+	ETag string
+}
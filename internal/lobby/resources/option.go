@@ -1,4 +1,12 @@
 package resources
 
+import "github.com/shigde/sfu/internal/lobby/sessions"
+
 type Option struct {
+	// Role of the user resolved from the live stream video, users without a role are viewers
+	Role sessions.Role
+	// ResumeToken of the session a reconnecting client resumes
+	ResumeToken string
+	// Instance is set for the connections of other Shig instances relaying the lobby
+	Instance bool
 }
@@ -5,4 +5,6 @@ import "github.com/pion/webrtc/v3"
 type WebRTC struct {
 	Id  string
 	SDP *webrtc.SessionDescription
+	// ResumeToken reconnects a client to the session of the resource, after the client lost its connection
+	ResumeToken string
 }
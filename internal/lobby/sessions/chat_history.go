@@ -0,0 +1,63 @@
+package sessions
+
+import (
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/shigde/sfu/pkg/message"
+)
+
+const (
+	// number of chat messages a session gets when it connects
+	chatHistorySize = 100
+	maxChatLength   = 2000
+	maxEmojiLength  = 32
+)
+
+// chatHistory keeps the latest chat messages and the raised hands of a lobby for sessions that connect later.
+// It is only used by the hub loop and has no own lock.
+type chatHistory struct {
+	chats       []message.Chat
+	raisedHands map[uuid.UUID]message.RaiseHand // sessionId --> RaiseHand
+}
+
+func newChatHistory() *chatHistory {
+	return &chatHistory{
+		chats:       make([]message.Chat, 0, chatHistorySize),
+		raisedHands: make(map[uuid.UUID]message.RaiseHand),
+	}
+}
+
+func (c *chatHistory) addChat(chat message.Chat) {
+	if len(c.chats) == chatHistorySize {
+		c.chats = append(c.chats[:0], c.chats[1:]...)
+	}
+	c.chats = append(c.chats, chat)
+}
+
+func (c *chatHistory) setRaiseHand(sessionId uuid.UUID, raiseHand message.RaiseHand) {
+	if !raiseHand.Raised {
+		delete(c.raisedHands, sessionId)
+		return
+	}
+	c.raisedHands[sessionId] = raiseHand
+}
+
+// history returns the chat messages and the raised hands of the sessions that are still in the lobby
+func (c *chatHistory) history(inLobby func(sessionId uuid.UUID) bool) *message.History {
+	raisedHands := make([]message.RaiseHand, 0, len(c.raisedHands))
+	for sessionId, raiseHand := range c.raisedHands {
+		if !inLobby(sessionId) {
+			delete(c.raisedHands, sessionId)
+			continue
+		}
+		raisedHands = append(raisedHands, raiseHand)
+	}
+	sort.Slice(raisedHands, func(i, j int) bool {
+		return raisedHands[i].Time.Before(raisedHands[j].Time)
+	})
+	return &message.History{
+		Chats:       append([]message.Chat(nil), c.chats...),
+		RaisedHands: raisedHands,
+	}
+}
@@ -0,0 +1,40 @@
+package sessions
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shigde/sfu/pkg/message"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChatHistory(t *testing.T) {
+	t.Run("keep the latest chat messages", func(t *testing.T) {
+		history := newChatHistory()
+		for i := 0; i < chatHistorySize+5; i++ {
+			history.addChat(message.Chat{Text: fmt.Sprintf("msg-%d", i)})
+		}
+
+		chats := history.history(func(_ uuid.UUID) bool { return true }).Chats
+		assert.Len(t, chats, chatHistorySize)
+		assert.Equal(t, "msg-5", chats[0].Text)
+		assert.Equal(t, fmt.Sprintf("msg-%d", chatHistorySize+4), chats[chatHistorySize-1].Text)
+	})
+
+	t.Run("keep raised hands of sessions in the lobby", func(t *testing.T) {
+		history := newChatHistory()
+		raised, lowered, left := uuid.New(), uuid.New(), uuid.New()
+		now := time.Now()
+		history.setRaiseHand(raised, message.RaiseHand{SessionId: raised.String(), Raised: true, Time: now})
+		history.setRaiseHand(lowered, message.RaiseHand{SessionId: lowered.String(), Raised: true, Time: now})
+		history.setRaiseHand(lowered, message.RaiseHand{SessionId: lowered.String(), Raised: false, Time: now})
+		history.setRaiseHand(left, message.RaiseHand{SessionId: left.String(), Raised: true, Time: now})
+
+		hands := history.history(func(sessionId uuid.UUID) bool { return sessionId != left }).RaisedHands
+		assert.Len(t, hands, 1)
+		assert.Equal(t, raised.String(), hands[0].SessionId)
+		assert.Len(t, history.raisedHands, 1)
+	})
+}
@@ -3,12 +3,14 @@ package sessions
 import (
 	"context"
 	"errors"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/pion/webrtc/v3"
 	"github.com/shigde/sfu/internal/metric"
 	"github.com/shigde/sfu/internal/rtp"
+	"github.com/shigde/sfu/pkg/message"
 	"golang.org/x/exp/slog"
 )
 
@@ -23,31 +25,75 @@ type liveStreamSender interface {
 	RemoveTrack(track webrtc.TrackLocal)
 }
 
+// liveStreamPackager packages the main tracks for none WebRTC viewers, like the HLS packager or the RTMP publisher
+type liveStreamPackager interface {
+	AddTrack(info *rtp.TrackInfo)
+	RemoveTrack(info *rtp.TrackInfo)
+}
+
+// trackRecorder receives all tracks of the lobby, not only the main tracks
+type trackRecorder interface {
+	AddTrack(info *rtp.TrackInfo)
+	RemoveTrack(info *rtp.TrackInfo)
+}
+
+// trackCompositor composes the guest tracks of the lobby to main tracks and shows the dominant speaker
+type trackCompositor interface {
+	AddTrack(info *rtp.TrackInfo)
+	RemoveTrack(info *rtp.TrackInfo)
+	SetSpeaker(sessionId uuid.UUID)
+}
+
+// muteListener is a trackRecorder that follows the mute state of the tracks
+type muteListener interface {
+	MuteTrack(info *rtp.TrackInfo)
+}
+
 type Hub struct {
 	ctx           context.Context
 	LiveStreamId  uuid.UUID
 	sessionRepo   *SessionRepository
 	sender        liveStreamSender
+	packagers     []liveStreamPackager
+	recorders     []trackRecorder
+	compositor    trackCompositor
 	reqChan       chan *hubRequest
 	tracks        map[string]*rtp.TrackInfo   // trackID --> TrackInfo
 	metricNodes   map[string]metric.GraphNode // sessionId --> metric Node
 	hubMetricNode metric.GraphNode
+	speakers      *speakerRanking
+	lastN         int                  // 0 forwards all guest videos to the sessions
+	joined        map[uuid.UUID]uint64 // sessionId --> join order of sessions with guest videos
+	joinCounter   uint64
+	chatHistory   *chatHistory
 }
 
-func NewHub(ctx context.Context, sessionRepo *SessionRepository, liveStream uuid.UUID, sender liveStreamSender) *Hub {
+func NewHub(ctx context.Context, sessionRepo *SessionRepository, liveStream uuid.UUID, sender liveStreamSender, packager liveStreamPackager) *Hub {
 	tracks := make(map[string]*rtp.TrackInfo)
 	metricNodes := make(map[string]metric.GraphNode)
 	requests := make(chan *hubRequest)
 	hubMetricNode := metric.GraphNodeUpdate(metric.BuildNode(liveStream.String(), liveStream.String(), "Hub"))
+	packagers := make([]liveStreamPackager, 0)
+	if packager != nil {
+		packagers = append(packagers, packager)
+	}
 	hub := &Hub{
 		ctx,
 		liveStream,
 		sessionRepo,
 		sender,
+		packagers,
+		make([]trackRecorder, 0),
+		nil,
 		requests,
 		tracks,
 		metricNodes,
 		hubMetricNode,
+		newSpeakerRanking(),
+		0,
+		make(map[uuid.UUID]uint64),
+		0,
+		newChatHistory(),
 	}
 	go hub.run()
 
@@ -61,6 +107,8 @@ func NewHub(ctx context.Context, sessionRepo *SessionRepository, liveStream uuid
 
 func (h *Hub) run() {
 	slog.Info("lobby.Hub: run")
+	speakerTicker := time.NewTicker(speakerInterval)
+	defer speakerTicker.Stop()
 	for {
 		select {
 		case trackEvent := <-h.reqChan:
@@ -73,7 +121,35 @@ func (h *Hub) run() {
 				h.onGetTrackList(trackEvent)
 			case muteTrack:
 				h.onMuteTrack(trackEvent)
+			case addPackager:
+				h.onAddPackager(trackEvent)
+			case removePackager:
+				h.onRemovePackager(trackEvent)
+			case addRecorder:
+				h.onAddRecorder(trackEvent)
+			case removeRecorder:
+				h.onRemoveRecorder(trackEvent)
+			case setCompositor:
+				h.onSetCompositor(trackEvent)
+			case setLastN:
+				h.onSetLastN(trackEvent)
+			case updateForwarding:
+				h.onUpdateForwarding(trackEvent)
+			case sendChat:
+				h.onChat(trackEvent)
+			case sendReaction:
+				h.onReaction(trackEvent)
+			case sendRaiseHand:
+				h.onRaiseHand(trackEvent)
+			case sendHistory:
+				h.onSendHistory(trackEvent)
+			case replaceTrack:
+				h.onReplaceTrack(trackEvent)
+			case pauseSession:
+				h.onPauseSession(trackEvent)
 			}
+		case <-speakerTicker.C:
+			h.onSpeakerTick()
 		case <-h.ctx.Done():
 			slog.Info("lobby.Hub: closed Hub")
 			return
@@ -114,6 +190,169 @@ func (h *Hub) DispatchMuteTrack(ctx context.Context, track *rtp.TrackInfo) {
 	}
 }
 
+// DispatchReplaceTrack replaces a track of a session with a new track of the same session, like after a reconnect of the session.
+// The sessions receive the new track in place of the replaced track, where possible without renegotiation.
+func (h *Hub) DispatchReplaceTrack(ctx context.Context, replaced *rtp.TrackInfo, track *rtp.TrackInfo) {
+	select {
+	case h.reqChan <- &hubRequest{ctx: ctx, kind: replaceTrack, replaced: replaced, track: track}:
+		slog.Debug("lobby.Hub: dispatch replace track", "replacedTrack", replaced.GetTrackLocal().ID(), "track", track.GetTrackLocal().ID(), "kind", track.GetTrackLocal().Kind(), "purpose", track.Purpose.ToString())
+	case <-h.ctx.Done():
+		slog.Warn("lobby.Hub: dispatch replace track even on closed Hub")
+	case <-time.After(hubDispatchTimeout):
+		slog.Error("lobby.Hub: dispatch replace track - interrupted because dispatch timeout")
+	}
+}
+
+// DispatchPauseSession pauses or resumes the tracks of a session that lost its connection, the other sessions see paused tracks as muted
+func (h *Hub) DispatchPauseSession(ctx context.Context, sessionId uuid.UUID, paused bool) {
+	select {
+	case h.reqChan <- &hubRequest{ctx: ctx, kind: pauseSession, sessionId: sessionId, paused: paused}:
+		slog.Debug("lobby.Hub: dispatch pause session", "sessionId", sessionId, "paused", paused)
+	case <-h.ctx.Done():
+		slog.Warn("lobby.Hub: dispatch pause session even on closed Hub")
+	case <-time.After(hubDispatchTimeout):
+		slog.Error("lobby.Hub: dispatch pause session - interrupted because dispatch timeout")
+	}
+}
+
+// DispatchAddPackager adds a packager while the lobby is running, the packager receives the current main tracks
+func (h *Hub) DispatchAddPackager(ctx context.Context, packager liveStreamPackager) {
+	select {
+	case h.reqChan <- &hubRequest{ctx: ctx, kind: addPackager, packager: packager}:
+		slog.Debug("lobby.Hub: dispatch add packager")
+	case <-h.ctx.Done():
+		slog.Warn("lobby.Hub: dispatch add packager even on closed Hub")
+	case <-time.After(hubDispatchTimeout):
+		slog.Error("lobby.Hub: dispatch add packager - interrupted because dispatch timeout")
+	}
+}
+
+func (h *Hub) DispatchRemovePackager(ctx context.Context, packager liveStreamPackager) {
+	select {
+	case h.reqChan <- &hubRequest{ctx: ctx, kind: removePackager, packager: packager}:
+		slog.Debug("lobby.Hub: dispatch remove packager")
+	case <-h.ctx.Done():
+		slog.Warn("lobby.Hub: dispatch remove packager even on closed Hub")
+	case <-time.After(hubDispatchTimeout):
+		slog.Error("lobby.Hub: dispatch remove packager - interrupted because dispatch timeout")
+	}
+}
+
+// DispatchAddRecorder adds a recorder, the recorder receives all current and upcoming tracks of the lobby
+func (h *Hub) DispatchAddRecorder(ctx context.Context, recorder trackRecorder) {
+	select {
+	case h.reqChan <- &hubRequest{ctx: ctx, kind: addRecorder, recorder: recorder}:
+		slog.Debug("lobby.Hub: dispatch add recorder")
+	case <-h.ctx.Done():
+		slog.Warn("lobby.Hub: dispatch add recorder even on closed Hub")
+	case <-time.After(hubDispatchTimeout):
+		slog.Error("lobby.Hub: dispatch add recorder - interrupted because dispatch timeout")
+	}
+}
+
+func (h *Hub) DispatchRemoveRecorder(ctx context.Context, recorder trackRecorder) {
+	select {
+	case h.reqChan <- &hubRequest{ctx: ctx, kind: removeRecorder, recorder: recorder}:
+		slog.Debug("lobby.Hub: dispatch remove recorder")
+	case <-h.ctx.Done():
+		slog.Warn("lobby.Hub: dispatch remove recorder even on closed Hub")
+	case <-time.After(hubDispatchTimeout):
+		slog.Error("lobby.Hub: dispatch remove recorder - interrupted because dispatch timeout")
+	}
+}
+
+// DispatchSetCompositor sets the compositor of the lobby, the compositor receives all current and upcoming guest tracks
+// and publishes its composition as main tracks
+func (h *Hub) DispatchSetCompositor(ctx context.Context, compositor trackCompositor) {
+	select {
+	case h.reqChan <- &hubRequest{ctx: ctx, kind: setCompositor, compositor: compositor}:
+		slog.Debug("lobby.Hub: dispatch set compositor")
+	case <-h.ctx.Done():
+		slog.Warn("lobby.Hub: dispatch set compositor even on closed Hub")
+	case <-time.After(hubDispatchTimeout):
+		slog.Error("lobby.Hub: dispatch set compositor - interrupted because dispatch timeout")
+	}
+}
+
+// DispatchSetLastN limits the guest videos of every session to the N most recently active speakers, 0 forwards all guest videos.
+// Sessions can override N with a last-N message.
+func (h *Hub) DispatchSetLastN(ctx context.Context, lastN int) {
+	select {
+	case h.reqChan <- &hubRequest{ctx: ctx, kind: setLastN, lastN: lastN}:
+		slog.Debug("lobby.Hub: dispatch set last-n", "lastN", lastN)
+	case <-h.ctx.Done():
+		slog.Warn("lobby.Hub: dispatch set last-n even on closed Hub")
+	case <-time.After(hubDispatchTimeout):
+		slog.Error("lobby.Hub: dispatch set last-n - interrupted because dispatch timeout")
+	}
+}
+
+// DispatchUpdateForwarding selects the guest videos of a session again, after the session changed its last-N settings
+func (h *Hub) DispatchUpdateForwarding(ctx context.Context, sessionId uuid.UUID) {
+	select {
+	case h.reqChan <- &hubRequest{ctx: ctx, kind: updateForwarding, sessionId: sessionId}:
+		slog.Debug("lobby.Hub: dispatch update forwarding", "sessionId", sessionId)
+	case <-h.ctx.Done():
+		slog.Warn("lobby.Hub: dispatch update forwarding even on closed Hub")
+	case <-time.After(hubDispatchTimeout):
+		slog.Error("lobby.Hub: dispatch update forwarding - interrupted because dispatch timeout")
+	}
+}
+
+// DispatchChat sends a chat message to all sessions of the lobby and keeps it in the history
+func (h *Hub) DispatchChat(ctx context.Context, chat *message.Chat) {
+	select {
+	case h.reqChan <- &hubRequest{ctx: ctx, kind: sendChat, chat: chat}:
+		slog.Debug("lobby.Hub: dispatch chat", "sessionId", chat.SessionId)
+	case <-h.ctx.Done():
+		slog.Warn("lobby.Hub: dispatch chat even on closed Hub")
+	case <-time.After(hubDispatchTimeout):
+		slog.Error("lobby.Hub: dispatch chat - interrupted because dispatch timeout")
+	}
+}
+
+// DispatchReaction sends an emoji reaction to all sessions of the lobby
+func (h *Hub) DispatchReaction(ctx context.Context, reaction *message.Reaction) {
+	select {
+	case h.reqChan <- &hubRequest{ctx: ctx, kind: sendReaction, reaction: reaction}:
+		slog.Debug("lobby.Hub: dispatch reaction", "sessionId", reaction.SessionId)
+	case <-h.ctx.Done():
+		slog.Warn("lobby.Hub: dispatch reaction even on closed Hub")
+	case <-time.After(hubDispatchTimeout):
+		slog.Error("lobby.Hub: dispatch reaction - interrupted because dispatch timeout")
+	}
+}
+
+// DispatchRaiseHand sends a raised or lowered hand to all sessions of the lobby, raised hands are kept in the history
+func (h *Hub) DispatchRaiseHand(ctx context.Context, raiseHand *message.RaiseHand) {
+	select {
+	case h.reqChan <- &hubRequest{ctx: ctx, kind: sendRaiseHand, raiseHand: raiseHand}:
+		slog.Debug("lobby.Hub: dispatch raise hand", "sessionId", raiseHand.SessionId)
+	case <-h.ctx.Done():
+		slog.Warn("lobby.Hub: dispatch raise hand even on closed Hub")
+	case <-time.After(hubDispatchTimeout):
+		slog.Error("lobby.Hub: dispatch raise hand - interrupted because dispatch timeout")
+	}
+}
+
+// DispatchSendHistory sends the chat history to a session that just connected
+func (h *Hub) DispatchSendHistory(ctx context.Context, sessionId uuid.UUID) {
+	select {
+	case h.reqChan <- &hubRequest{ctx: ctx, kind: sendHistory, sessionId: sessionId}:
+		slog.Debug("lobby.Hub: dispatch send history", "sessionId", sessionId)
+	case <-h.ctx.Done():
+		slog.Warn("lobby.Hub: dispatch send history even on closed Hub")
+	case <-time.After(hubDispatchTimeout):
+		slog.Error("lobby.Hub: dispatch send history - interrupted because dispatch timeout")
+	}
+}
+
+// UpdateAudioLevel is called by the ingress media writers for every audio packet with an audio level.
+// The level is not dispatched to the hub loop, the speaker ranking is evaluated periodically by the loop instead.
+func (h *Hub) UpdateAudioLevel(sessionId uuid.UUID, level uint8) {
+	h.speakers.update(sessionId, level, time.Now())
+}
+
 // getTrackList Is called from the Egress endpoints when the connection is established.
 // In ths wax the egress endpoints can receive the current tracks of the lobby
 // The session set this methode as callback to the egress egress
@@ -121,7 +360,7 @@ func (h *Hub) getTrackList(ctx context.Context, sessionId uuid.UUID, filters ...
 	var hubList []*rtp.TrackInfo
 	trackListChan := make(chan []*rtp.TrackInfo)
 	select {
-	case h.reqChan <- &hubRequest{ctx: ctx, kind: getTrackList, trackListChan: trackListChan}:
+	case h.reqChan <- &hubRequest{ctx: ctx, kind: getTrackList, sessionId: sessionId, trackListChan: trackListChan}:
 	case <-h.ctx.Done():
 		slog.Warn("lobby.Hub: get track list on closed Hub")
 		return nil, errHubAlreadyClosed
@@ -171,10 +410,28 @@ func (h *Hub) onAddTrack(event *hubRequest) {
 	h.hubMetricNode = metric.GraphNodeUpdateInc(h.hubMetricNode, event.track.Purpose.ToString())
 	if event.track.GetPurpose() == rtp.PurposeMain {
 		slog.Debug("lobby.Hub: add live track ro sender", "streamId", event.track.GetTrackLocal().StreamID(), "track", event.track.GetTrackLocal().ID(), "kind", event.track.GetTrackLocal().Kind(), "purpose", event.track.Purpose.ToString())
-		h.sender.AddTrack(event.track.GetTrackLocal())
+		if h.sender != nil {
+			h.sender.AddTrack(event.track.GetTrackLocal())
+		}
+		for _, packager := range h.packagers {
+			packager.AddTrack(event.track)
+		}
+	}
+
+	for _, recorder := range h.recorders {
+		recorder.AddTrack(event.track)
+	}
+	if h.compositor != nil {
+		h.compositor.AddTrack(event.track)
 	}
 
 	h.tracks[event.track.GetTrackLocal().ID()] = event.track
+	if isLastNVideo(event.track) {
+		if _, found := h.joined[event.track.GetSessionId()]; !found {
+			h.joinCounter++
+			h.joined[event.track.GetSessionId()] = h.joinCounter
+		}
+	}
 	h.sessionRepo.Iter(func(s *Session) {
 		// If a session has just been created, this call blocks for seconds.
 		// This is because the ice gathering sometimes takes seconds. That's why we don't block the call
@@ -184,6 +441,11 @@ func (h *Hub) onAddTrack(event *hubRequest) {
 		slog.Debug("bug-1: hub-add", "session", s.Id, "trackId", event.track.GetTrackLocal().ID(), "kind", event.track.GetTrackLocal().Kind())
 
 		if filterForSession(s.Id)(event.track) {
+			// the track is forwarded if the session selects it
+			if s.isSelected(event.track) {
+				h.forward(event.ctx, s)
+				return
+			}
 			slog.Debug("lobby.Hub: add egress track to session", "sessionId", s.Id, "sourceSessionId", event.track.SessionId, "streamId", event.track.GetTrackLocal().StreamID(), "track", event.track.GetTrackLocal().ID(), "kind", event.track.GetTrackLocal().Kind(), "purpose", event.track.Purpose.ToString())
 			s.addTrack(event.ctx, event.track)
 		}
@@ -196,13 +458,32 @@ func (h *Hub) onRemoveTrack(event *hubRequest) {
 	h.hubMetricNode = metric.GraphNodeUpdateDec(h.hubMetricNode, event.track.Purpose.ToString())
 	h.decreaseNodeGraphStats(event.track.SessionId.String(), rtp.IngressEndpoint, event.track.Purpose)
 
+	if event.track.GetTrackLocal().Kind() == webrtc.RTPCodecTypeAudio {
+		h.speakers.remove(event.track.GetSessionId())
+	}
+
 	if event.track.GetPurpose() == rtp.PurposeMain {
-		h.sender.RemoveTrack(event.track.GetTrackLocal())
+		if h.sender != nil {
+			h.sender.RemoveTrack(event.track.GetTrackLocal())
+		}
+		for _, packager := range h.packagers {
+			packager.RemoveTrack(event.track)
+		}
+	}
+
+	for _, recorder := range h.recorders {
+		recorder.RemoveTrack(event.track)
+	}
+	if h.compositor != nil {
+		h.compositor.RemoveTrack(event.track)
 	}
 
 	if _, ok := h.tracks[event.track.GetTrackLocal().ID()]; ok {
 		delete(h.tracks, event.track.GetTrackLocal().ID())
 	}
+	if isLastNVideo(event.track) && len(h.guestVideos(event.track.GetSessionId())) == 0 {
+		delete(h.joined, event.track.GetSessionId())
+	}
 
 	h.sessionRepo.Iter(func(s *Session) {
 		// If a session has just been created, this call blocks for seconds.
@@ -211,6 +492,14 @@ func (h *Hub) onRemoveTrack(event *hubRequest) {
 			return
 		}
 		if filterForSession(s.Id)(event.track) {
+			// another track can take the place of the removed track
+			if s.isSelected(event.track) {
+				if s.isForwarding(event.track) {
+					h.decreaseNodeGraphStats(s.Id.String(), rtp.EgressEndpoint, event.track.Purpose)
+				}
+				h.forward(event.ctx, s)
+				return
+			}
 			slog.Debug("lobby.Hub: remove egress track from session", "sessionId", s.Id, "sourceSessionId", event.track.SessionId, "streamId", event.track.GetTrackLocal().StreamID(), "track", event.track.GetTrackLocal().ID(), "kind", event.track.GetTrackLocal().Kind(), "purpose", event.track.Purpose.ToString())
 			slog.Debug("bug-1: hub-remove", "session", s.Id, "trackId", event.track.GetTrackLocal().ID(), "kind", event.track.GetTrackLocal().Kind())
 			s.removeTrack(event.ctx, event.track)
@@ -219,11 +508,73 @@ func (h *Hub) onRemoveTrack(event *hubRequest) {
 	})
 }
 
+func (h *Hub) onReplaceTrack(event *hubRequest) {
+	replaced, track := event.replaced, event.track
+	slog.Debug("lobby.Hub: replace track", "sourceSessionId", track.SessionId, "replacedTrack", replaced.GetTrackLocal().ID(), "track", track.GetTrackLocal().ID(), "kind", track.GetTrackLocal().Kind(), "purpose", track.Purpose.ToString())
+
+	// the replaced track could be removed in the meantime
+	if _, found := h.tracks[replaced.GetTrackLocal().ID()]; !found {
+		h.onAddTrack(&hubRequest{ctx: event.ctx, kind: addTrack, track: track})
+		return
+	}
+	delete(h.tracks, replaced.GetTrackLocal().ID())
+	h.tracks[track.GetTrackLocal().ID()] = track
+
+	if track.GetPurpose() == rtp.PurposeMain {
+		if h.sender != nil {
+			h.sender.RemoveTrack(replaced.GetTrackLocal())
+			h.sender.AddTrack(track.GetTrackLocal())
+		}
+		for _, packager := range h.packagers {
+			packager.RemoveTrack(replaced)
+			packager.AddTrack(track)
+		}
+	}
+	for _, recorder := range h.recorders {
+		recorder.RemoveTrack(replaced)
+		recorder.AddTrack(track)
+	}
+	if h.compositor != nil {
+		h.compositor.RemoveTrack(replaced)
+		h.compositor.AddTrack(track)
+	}
+
+	h.sessionRepo.Iter(func(s *Session) {
+		if !s.initComplete() || !filterForSession(s.Id)(track) {
+			return
+		}
+		slog.Debug("lobby.Hub: replace egress track of session", "sessionId", s.Id, "sourceSessionId", track.SessionId, "replacedTrack", replaced.GetTrackLocal().ID(), "track", track.GetTrackLocal().ID())
+		s.replaceTrack(event.ctx, replaced, track)
+	})
+}
+
+// onPauseSession mutes the tracks of a paused session for the other sessions, resumed tracks get their own mute state back
+func (h *Hub) onPauseSession(event *hubRequest) {
+	for _, track := range h.tracks {
+		if track.GetSessionId() != event.sessionId {
+			continue
+		}
+		paused := *track
+		paused.SetMute(event.paused || track.GetMute())
+		h.onMuteTrack(&hubRequest{ctx: event.ctx, kind: muteTrack, track: &paused})
+	}
+}
+
 func (h *Hub) onGetTrackList(event *hubRequest) {
 	list := make([]*rtp.TrackInfo, 0, len(h.tracks))
+	session, found := h.sessionRepo.FindById(event.sessionId)
 	for _, track := range h.tracks {
+		if found && session.isSelected(track) {
+			continue
+		}
 		list = append(list, track)
 	}
+	if found && session.isInstance() {
+		relayed, _ := h.selectRelayed(session)
+		list = append(list, relayed...)
+	} else if found {
+		list = append(list, h.selectVideos(session)...)
+	}
 
 	select {
 	case event.trackListChan <- list:
@@ -234,8 +585,67 @@ func (h *Hub) onGetTrackList(event *hubRequest) {
 	}
 }
 
+func (h *Hub) onAddPackager(event *hubRequest) {
+	slog.Debug("lobby.Hub: add packager")
+	h.packagers = append(h.packagers, event.packager)
+	for _, track := range h.tracks {
+		if track.GetPurpose() == rtp.PurposeMain {
+			event.packager.AddTrack(track)
+		}
+	}
+}
+
+func (h *Hub) onRemovePackager(event *hubRequest) {
+	slog.Debug("lobby.Hub: remove packager")
+	for i, packager := range h.packagers {
+		if packager == event.packager {
+			h.packagers = append(h.packagers[:i], h.packagers[i+1:]...)
+			break
+		}
+	}
+	for _, track := range h.tracks {
+		if track.GetPurpose() == rtp.PurposeMain {
+			event.packager.RemoveTrack(track)
+		}
+	}
+}
+
+func (h *Hub) onAddRecorder(event *hubRequest) {
+	slog.Debug("lobby.Hub: add recorder")
+	h.recorders = append(h.recorders, event.recorder)
+	for _, track := range h.tracks {
+		event.recorder.AddTrack(track)
+	}
+}
+
+func (h *Hub) onRemoveRecorder(event *hubRequest) {
+	slog.Debug("lobby.Hub: remove recorder")
+	for i, recorder := range h.recorders {
+		if recorder == event.recorder {
+			h.recorders = append(h.recorders[:i], h.recorders[i+1:]...)
+			break
+		}
+	}
+	for _, track := range h.tracks {
+		event.recorder.RemoveTrack(track)
+	}
+}
+
+func (h *Hub) onSetCompositor(event *hubRequest) {
+	slog.Debug("lobby.Hub: set compositor")
+	h.compositor = event.compositor
+	for _, track := range h.tracks {
+		event.compositor.AddTrack(track)
+	}
+}
+
 func (h *Hub) onMuteTrack(event *hubRequest) {
 	slog.Debug("lobby.Hub: mute track", "sourceSessionId", event.track.SessionId, "streamId", "purpose", event.track.Purpose.ToString())
+	for _, recorder := range h.recorders {
+		if listener, ok := recorder.(muteListener); ok {
+			listener.MuteTrack(event.track)
+		}
+	}
 	h.sessionRepo.Iter(func(s *Session) {
 		if filterForSession(s.Id)(event.track) {
 			slog.Debug("lobby.Hub: mute egress track from session", "sessionId", s.Id, "sourceSessionId", event.track.SessionId, event.track.Purpose.ToString())
@@ -248,6 +658,118 @@ func (h *Hub) onMuteTrack(event *hubRequest) {
 	})
 }
 
+func (h *Hub) onSetLastN(event *hubRequest) {
+	slog.Debug("lobby.Hub: set last-n", "lastN", event.lastN)
+	h.lastN = event.lastN
+	h.sessionRepo.Iter(func(s *Session) {
+		if s.initComplete() {
+			h.forward(event.ctx, s)
+		}
+	})
+}
+
+func (h *Hub) onUpdateForwarding(event *hubRequest) {
+	if s, found := h.sessionRepo.FindById(event.sessionId); found && s.initComplete() {
+		h.forward(event.ctx, s)
+	}
+}
+
+// The data channel messages are sent in own goroutines, because a messenger blocks until its data channel is open
+
+func (h *Hub) onChat(event *hubRequest) {
+	h.chatHistory.addChat(*event.chat)
+	h.sessionRepo.Iter(func(s *Session) {
+		go s.sendChat(event.chat)
+	})
+}
+
+func (h *Hub) onReaction(event *hubRequest) {
+	h.sessionRepo.Iter(func(s *Session) {
+		go s.sendReaction(event.reaction)
+	})
+}
+
+func (h *Hub) onRaiseHand(event *hubRequest) {
+	if sessionId, err := uuid.Parse(event.raiseHand.SessionId); err == nil {
+		h.chatHistory.setRaiseHand(sessionId, *event.raiseHand)
+	}
+	h.sessionRepo.Iter(func(s *Session) {
+		go s.sendRaiseHand(event.raiseHand)
+	})
+}
+
+func (h *Hub) onSendHistory(event *hubRequest) {
+	if s, found := h.sessionRepo.FindById(event.sessionId); found {
+		go s.sendHistory(h.chatHistory.history(h.sessionRepo.Contains))
+	}
+}
+
+func (h *Hub) onSpeakerTick() {
+	// the most recently active speakers change more often than the dominant speaker
+	h.sessionRepo.Iter(func(s *Session) {
+		if s.initComplete() && !s.isInstance() && s.lastN(h.lastN) > 0 {
+			h.forward(h.ctx, s)
+		}
+	})
+	// the remote instances follow the tracks and the viewers of the lobby
+	h.sessionRepo.Iter(func(s *Session) {
+		if s.initComplete() && s.isInstance() {
+			h.syncRelay(s)
+		}
+	})
+
+	speaker, changed := h.speakers.updateDominant(time.Now())
+	if !changed {
+		return
+	}
+	slog.Debug("lobby.Hub: dominant speaker changed", "sessionId", speaker)
+	if h.compositor != nil {
+		h.compositor.SetSpeaker(speaker)
+	}
+	h.sessionRepo.Iter(func(s *Session) {
+		if !s.initComplete() {
+			return
+		}
+		go s.sendDominantSpeaker(speaker)
+	})
+}
+
+// forward swaps the guest videos of the session egress to the videos the session selects,
+// instance sessions get the tracks the remote instance subscribed
+func (h *Hub) forward(ctx context.Context, s *Session) {
+	if s.isInstance() {
+		tracks, layers := h.selectRelayed(s)
+		s.forwardVideos(ctx, tracks)
+		s.selectLayers(layers)
+		return
+	}
+	s.forwardVideos(ctx, h.selectVideos(s))
+}
+
+func (h *Hub) selectVideos(s *Session) []*rtp.TrackInfo {
+	videos := make([]*rtp.TrackInfo, 0)
+	for _, track := range h.tracks {
+		if isLastNVideo(track) && filterForSession(s.Id)(track) {
+			videos = append(videos, track)
+		}
+	}
+	// the order of the map is random, the selection of sessions with the same activity must not change with every call
+	sort.Slice(videos, func(i, j int) bool {
+		return videos[i].GetTrackLocal().ID() < videos[j].GetTrackLocal().ID()
+	})
+	return selectLastN(videos, s.lastN(h.lastN), s.pinnedSessions(), h.speakers.lastSpoken(), h.joined)
+}
+
+func (h *Hub) guestVideos(sessionId uuid.UUID) []*rtp.TrackInfo {
+	videos := make([]*rtp.TrackInfo, 0)
+	for _, track := range h.tracks {
+		if isLastNVideo(track) && track.GetSessionId() == sessionId {
+			videos = append(videos, track)
+		}
+	}
+	return videos
+}
+
 func (h *Hub) increaseNodeGraphStats(sessionId string, endpointType rtp.EndpointType, purpose rtp.Purpose) {
 	index := endpointType.ToString() + sessionId
 	metricNode, ok := h.metricNodes[index]
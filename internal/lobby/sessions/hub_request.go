@@ -3,14 +3,27 @@ package sessions
 import (
 	"context"
 
+	"github.com/google/uuid"
+
 	"github.com/shigde/sfu/internal/rtp"
+	"github.com/shigde/sfu/pkg/message"
 )
 
 type hubRequest struct {
 	ctx           context.Context
 	kind          hubRequestKind
+	sessionId     uuid.UUID
 	track         *rtp.TrackInfo
+	replaced      *rtp.TrackInfo
 	trackListChan chan<- []*rtp.TrackInfo
+	packager      liveStreamPackager
+	recorder      trackRecorder
+	compositor    trackCompositor
+	lastN         int
+	chat          *message.Chat
+	reaction      *message.Reaction
+	raiseHand     *message.RaiseHand
+	paused        bool
 }
 
 type hubRequestKind int
@@ -20,4 +33,17 @@ const (
 	removeTrack
 	getTrackList
 	muteTrack
+	addPackager
+	removePackager
+	addRecorder
+	removeRecorder
+	setCompositor
+	setLastN
+	updateForwarding
+	sendChat
+	sendReaction
+	sendRaiseHand
+	sendHistory
+	replaceTrack
+	pauseSession
 )
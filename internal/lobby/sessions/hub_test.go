@@ -3,9 +3,11 @@ package sessions
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/shigde/sfu/internal/lobby/mocks"
+	"github.com/shigde/sfu/internal/rtp"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -15,7 +17,7 @@ func testHubSetup(t *testing.T) (*Hub, func()) {
 	engine := mocks.NewRtpEngine()
 	forwarder := mocks.NewLiveSender()
 	ctx, cancel := context.WithCancel(context.Background())
-	hub := NewHub(ctx, sessions, uuid.New(), forwarder)
+	hub := NewHub(ctx, sessions, uuid.New(), forwarder, nil)
 	s1 := NewSession(ctx, uuid.New(), hub, engine, UserSession, nil)
 	s2 := NewSession(ctx, uuid.New(), hub, engine, UserSession, nil)
 	sessions.Add(s1)
@@ -30,6 +32,20 @@ func testHubSessionSetup(t *testing.T, hub *Hub) *Session {
 	return s
 }
 
+// testCompositor reports the tracks it composes
+type testCompositor struct {
+	added   chan *rtp.TrackInfo
+	removed chan *rtp.TrackInfo
+}
+
+func newTestCompositor() *testCompositor {
+	return &testCompositor{added: make(chan *rtp.TrackInfo, 10), removed: make(chan *rtp.TrackInfo, 10)}
+}
+
+func (c *testCompositor) AddTrack(info *rtp.TrackInfo)    { c.added <- info }
+func (c *testCompositor) RemoveTrack(info *rtp.TrackInfo) { c.removed <- info }
+func (c *testCompositor) SetSpeaker(_ uuid.UUID)          {}
+
 func TestHub(t *testing.T) {
 
 	t.Run("get tracks from other session", func(t *testing.T) {
@@ -37,4 +53,32 @@ func TestHub(t *testing.T) {
 		defer stop()
 		assert.NotNil(t, hub)
 	})
+	t.Run("compose current and upcoming tracks", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		hub := NewHub(ctx, NewSessionRepository(), uuid.New(), mocks.NewLiveSender(), nil)
+		current := testGuestVideo(t, uuid.New())
+		upcoming := testGuestVideo(t, uuid.New())
+		compositor := newTestCompositor()
+
+		hub.DispatchAddTrack(ctx, current)
+		hub.DispatchSetCompositor(ctx, compositor)
+		hub.DispatchAddTrack(ctx, upcoming)
+		hub.DispatchRemoveTrack(ctx, current)
+
+		assert.Equal(t, current, testReceiveTrack(t, compositor.added))
+		assert.Equal(t, upcoming, testReceiveTrack(t, compositor.added))
+		assert.Equal(t, current, testReceiveTrack(t, compositor.removed))
+	})
+}
+
+func testReceiveTrack(t *testing.T, tracks <-chan *rtp.TrackInfo) *rtp.TrackInfo {
+	t.Helper()
+	select {
+	case track := <-tracks:
+		return track
+	case <-time.After(time.Second):
+		t.Fatal("no track received")
+		return nil
+	}
 }
@@ -0,0 +1,82 @@
+package sessions
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/shigde/sfu/internal/rtp"
+	"golang.org/x/exp/slog"
+)
+
+// time the tracks of a reconnected client have to replace the kept tracks of its former ingress endpoint
+var replaceTrackTimeout = 5 * time.Second
+
+// ingressDispatcher dispatches the ingress tracks of a session to the hub.
+// While a session waits for its client to reconnect, the removed tracks of the lost ingress endpoint are kept in the hub.
+// The tracks of the reconnected ingress endpoint replace them, so that the other sessions do not see the tracks leave and come back.
+type ingressDispatcher struct {
+	mu      sync.Mutex
+	hub     *Hub
+	keeping bool
+	kept    []*rtp.TrackInfo
+}
+
+func newIngressDispatcher(hub *Hub) *ingressDispatcher {
+	return &ingressDispatcher{hub: hub, kept: make([]*rtp.TrackInfo, 0)}
+}
+
+func (d *ingressDispatcher) DispatchAddTrack(ctx context.Context, track *rtp.TrackInfo) {
+	if replaced, found := d.takeKept(track); found {
+		d.hub.DispatchReplaceTrack(ctx, replaced, track)
+		return
+	}
+	d.hub.DispatchAddTrack(ctx, track)
+}
+
+func (d *ingressDispatcher) DispatchRemoveTrack(ctx context.Context, track *rtp.TrackInfo) {
+	d.mu.Lock()
+	if d.keeping {
+		slog.Debug("sessions.ingressDispatcher: keep removed track", "sessionId", track.GetSessionId(), "track", track.GetTrackLocal().ID(), "kind", track.GetTrackLocal().Kind())
+		d.kept = append(d.kept, track)
+		d.mu.Unlock()
+		return
+	}
+	d.mu.Unlock()
+	d.hub.DispatchRemoveTrack(ctx, track)
+}
+
+// keep starts or stops keeping the removed tracks in the hub
+func (d *ingressDispatcher) keep(keeping bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.keeping = keeping
+}
+
+// release removes the kept tracks, that were not replaced, from the hub, unless the dispatcher keeps tracks again
+func (d *ingressDispatcher) release(ctx context.Context) {
+	d.mu.Lock()
+	if d.keeping {
+		d.mu.Unlock()
+		return
+	}
+	kept := d.kept
+	d.kept = make([]*rtp.TrackInfo, 0)
+	d.mu.Unlock()
+	for _, track := range kept {
+		d.hub.DispatchRemoveTrack(ctx, track)
+	}
+}
+
+// takeKept returns a kept track the track can replace, the track needs the same kind and purpose
+func (d *ingressDispatcher) takeKept(track *rtp.TrackInfo) (*rtp.TrackInfo, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, kept := range d.kept {
+		if kept.GetTrackLocal().Kind() == track.GetTrackLocal().Kind() && kept.GetPurpose() == track.GetPurpose() {
+			d.kept = append(d.kept[:i], d.kept[i+1:]...)
+			return kept, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,84 @@
+package sessions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/pion/webrtc/v3"
+	"github.com/shigde/sfu/internal/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func testIngressTrack(t *testing.T, sessionId uuid.UUID, mimeType string) *rtp.TrackInfo {
+	t.Helper()
+	track, err := webrtc.NewTrackLocalStaticRTP(webrtc.RTPCodecCapability{MimeType: mimeType}, uuid.NewString(), "stream")
+	assert.NoError(t, err)
+	return &rtp.TrackInfo{TrackSdpInfo: rtp.TrackSdpInfo{Id: uuid.New(), SessionId: sessionId, Purpose: rtp.PurposeGuest}, Track: track}
+}
+
+func testHubTrackIds(t *testing.T, hub *Hub) []string {
+	t.Helper()
+	list, err := hub.getTrackList(context.Background(), uuid.New())
+	assert.NoError(t, err)
+	ids := make([]string, 0, len(list))
+	for _, track := range list {
+		ids = append(ids, track.GetTrackLocal().ID())
+	}
+	return ids
+}
+
+func TestIngressDispatcher(t *testing.T) {
+	t.Run("remove tracks", func(t *testing.T) {
+		hub, stop := testHubSetup(t)
+		defer stop()
+		dispatcher := newIngressDispatcher(hub)
+		track := testIngressTrack(t, uuid.New(), webrtc.MimeTypeOpus)
+
+		dispatcher.DispatchAddTrack(context.Background(), track)
+		assert.Equal(t, []string{track.GetTrackLocal().ID()}, testHubTrackIds(t, hub))
+
+		dispatcher.DispatchRemoveTrack(context.Background(), track)
+		assert.Empty(t, testHubTrackIds(t, hub))
+	})
+
+	t.Run("keep removed tracks until a track of the same kind replaces them", func(t *testing.T) {
+		hub, stop := testHubSetup(t)
+		defer stop()
+		dispatcher := newIngressDispatcher(hub)
+		sessionId := uuid.New()
+		audio := testIngressTrack(t, sessionId, webrtc.MimeTypeOpus)
+		dispatcher.DispatchAddTrack(context.Background(), audio)
+
+		dispatcher.keep(true)
+		dispatcher.DispatchRemoveTrack(context.Background(), audio)
+		assert.Equal(t, []string{audio.GetTrackLocal().ID()}, testHubTrackIds(t, hub))
+
+		video := testIngressTrack(t, sessionId, webrtc.MimeTypeVP8)
+		dispatcher.DispatchAddTrack(context.Background(), video)
+		assert.Len(t, testHubTrackIds(t, hub), 2)
+
+		resumed := testIngressTrack(t, sessionId, webrtc.MimeTypeOpus)
+		dispatcher.DispatchAddTrack(context.Background(), resumed)
+		assert.ElementsMatch(t, []string{video.GetTrackLocal().ID(), resumed.GetTrackLocal().ID()}, testHubTrackIds(t, hub))
+		assert.Empty(t, dispatcher.kept)
+	})
+
+	t.Run("release kept tracks", func(t *testing.T) {
+		hub, stop := testHubSetup(t)
+		defer stop()
+		dispatcher := newIngressDispatcher(hub)
+		track := testIngressTrack(t, uuid.New(), webrtc.MimeTypeOpus)
+		dispatcher.DispatchAddTrack(context.Background(), track)
+		dispatcher.keep(true)
+		dispatcher.DispatchRemoveTrack(context.Background(), track)
+
+		// kept tracks are not released, while the dispatcher keeps tracks
+		dispatcher.release(context.Background())
+		assert.Len(t, testHubTrackIds(t, hub), 1)
+
+		dispatcher.keep(false)
+		dispatcher.release(context.Background())
+		assert.Empty(t, testHubTrackIds(t, hub))
+	})
+}
@@ -5,6 +5,7 @@ import "github.com/google/uuid"
 type Item struct {
 	UserId      uuid.UUID
 	SessionType SessionType
+	Role        Role
 	Done        chan bool
 }
 
@@ -12,6 +13,7 @@ func NewItem(userId uuid.UUID) Item {
 	return Item{
 		UserId:      userId,
 		SessionType: UserSession,
+		Role:        RoleViewer,
 		Done:        make(chan bool),
 	}
 }
@@ -0,0 +1,56 @@
+package sessions
+
+import (
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pion/webrtc/v3"
+	"github.com/shigde/sfu/internal/rtp"
+)
+
+// isLastNVideo reports whether the track is a guest video, only guest videos are limited by last-N
+func isLastNVideo(track *rtp.TrackInfo) bool {
+	return track.GetPurpose() == rtp.PurposeGuest && track.GetTrackLocal().Kind() == webrtc.RTPCodecTypeVideo
+}
+
+// selectLastN selects the guest videos of the pinned sessions and of the N sessions that spoke most recently.
+// Sessions that never spoke are ordered by the time they joined. With N <= 0 all videos are selected.
+func selectLastN(videos []*rtp.TrackInfo, lastN int, pinned []uuid.UUID, spoken map[uuid.UUID]time.Time, joined map[uuid.UUID]uint64) []*rtp.TrackInfo {
+	if lastN <= 0 {
+		return videos
+	}
+
+	selected := make(map[uuid.UUID]bool)
+	for _, sessionId := range pinned {
+		selected[sessionId] = true
+	}
+
+	candidates := make([]uuid.UUID, 0)
+	for _, video := range videos {
+		sessionId := video.GetSessionId()
+		if _, found := selected[sessionId]; found {
+			continue
+		}
+		selected[sessionId] = false
+		candidates = append(candidates, sessionId)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		spokeI, spokeJ := spoken[candidates[i]], spoken[candidates[j]]
+		if !spokeI.Equal(spokeJ) {
+			return spokeI.After(spokeJ)
+		}
+		return joined[candidates[i]] < joined[candidates[j]]
+	})
+	for _, sessionId := range candidates[:min(lastN, len(candidates))] {
+		selected[sessionId] = true
+	}
+
+	list := make([]*rtp.TrackInfo, 0, len(videos))
+	for _, video := range videos {
+		if selected[video.GetSessionId()] {
+			list = append(list, video)
+		}
+	}
+	return list
+}
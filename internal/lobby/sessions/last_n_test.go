@@ -0,0 +1,47 @@
+package sessions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pion/webrtc/v3"
+	"github.com/shigde/sfu/internal/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func testGuestVideo(t *testing.T, sessionId uuid.UUID) *rtp.TrackInfo {
+	t.Helper()
+	track, err := webrtc.NewTrackLocalStaticRTP(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8}, uuid.NewString(), sessionId.String())
+	assert.NoError(t, err)
+	return &rtp.TrackInfo{TrackSdpInfo: rtp.TrackSdpInfo{Id: uuid.New(), SessionId: sessionId, Purpose: rtp.PurposeGuest}, Track: track}
+}
+
+func TestSelectLastN(t *testing.T) {
+	first, second, third := uuid.New(), uuid.New(), uuid.New()
+	videos := []*rtp.TrackInfo{testGuestVideo(t, first), testGuestVideo(t, second), testGuestVideo(t, third)}
+	joined := map[uuid.UUID]uint64{first: 1, second: 2, third: 3}
+	now := time.Now()
+
+	t.Run("select all videos without last-n", func(t *testing.T) {
+		selected := selectLastN(videos, 0, nil, map[uuid.UUID]time.Time{}, joined)
+		assert.Equal(t, videos, selected)
+	})
+
+	t.Run("select the most recent speakers", func(t *testing.T) {
+		spoken := map[uuid.UUID]time.Time{second: now.Add(-time.Second), third: now}
+		selected := selectLastN(videos, 2, nil, spoken, joined)
+		assert.Equal(t, []*rtp.TrackInfo{videos[1], videos[2]}, selected)
+	})
+
+	t.Run("select the first joined sessions if nobody spoke", func(t *testing.T) {
+		selected := selectLastN(videos, 1, nil, map[uuid.UUID]time.Time{}, joined)
+		assert.Equal(t, []*rtp.TrackInfo{videos[0]}, selected)
+	})
+
+	t.Run("select pinned sessions in addition", func(t *testing.T) {
+		spoken := map[uuid.UUID]time.Time{third: now}
+		selected := selectLastN(videos, 1, []uuid.UUID{first}, spoken, joined)
+		assert.Equal(t, []*rtp.TrackInfo{videos[0], videos[2]}, selected)
+	})
+}
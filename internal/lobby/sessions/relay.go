@@ -0,0 +1,411 @@
+package sessions
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pion/webrtc/v3"
+	"github.com/shigde/sfu/internal/rtp"
+	"github.com/shigde/sfu/pkg/message"
+	"golang.org/x/exp/slog"
+)
+
+// relay is the state of the relay protocol between two Shig instances, only instance sessions relay tracks.
+// Both instances announce their tracks and subscribe to the announced tracks their viewers need.
+// The egress endpoint of an instance session forwards every track until the remote instance subscribes the first time,
+// afterward only the subscribed tracks.
+type relay struct {
+	mu           sync.Mutex
+	announced    []message.RelayTrack               // tracks the remote instance announced
+	subscribed   map[string]message.SubscribedTrack // relay track id --> track the remote instance subscribed, nil before the first subscription
+	tracks       *message.RelayTracks               // announcement and subscription of this instance to send
+	subscription *message.RelaySubscription
+	updated      chan struct{}
+}
+
+func newRelay() *relay {
+	return &relay{
+		announced: make([]message.RelayTrack, 0),
+		updated:   make(chan struct{}, 1),
+	}
+}
+
+// relayDemand is what the lobby needs of the tracks a remote instance announced
+type relayDemand struct {
+	consumers bool                        // viewers, the live stream sender, packagers or recorders need the main tracks
+	viewers   []relayViewer               // last-N selection of the sessions of this instance
+	relayed   map[string]rtp.VideoQuality // relay track id --> layer, tracks other remote instances subscribed
+}
+
+type relayViewer struct {
+	lastN  int
+	pinned []uuid.UUID
+}
+
+func (s *Session) isInstance() bool {
+	return s.sessionType == InstanceSession || s.sessionType == RemoteInstanceSession
+}
+
+// isSelected reports whether the hub selects the track for the egress endpoint of the session, instead of adding every track.
+// Instance sessions receive the tracks the remote instance subscribed, user sessions the guest videos of their last-N selection.
+func (s *Session) isSelected(trackInfo *rtp.TrackInfo) bool {
+	return s.isInstance() || isLastNVideo(trackInfo)
+}
+
+func (s *Session) onRelayTracks(relayTracks *message.RelayTracks) {
+	if !s.isInstance() {
+		slog.Warn("sessions: ignore relay tracks of user session", "sessionId", s.Id, "user", s.user)
+		return
+	}
+	s.relay.mu.Lock()
+	s.relay.announced = relayTracks.Tracks
+	s.relay.mu.Unlock()
+	s.muteRelayedTracks(relayTracks.Tracks)
+}
+
+// muteRelayedTracks applies the announced mute state to the tracks the ingress endpoint receives from the remote instance
+func (s *Session) muteRelayedTracks(tracks []message.RelayTrack) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.ingress == nil {
+		return
+	}
+	for _, track := range tracks {
+		if track.TrackId == "" {
+			continue
+		}
+		if trackInfo, ok := s.ingress.SetIngressTrackMute(track.TrackId, track.Mute); ok {
+			go s.hub.DispatchMuteTrack(s.ctx, trackInfo)
+		}
+	}
+}
+
+func (s *Session) onRelaySubscription(subscription *message.RelaySubscription) {
+	if !s.isInstance() {
+		slog.Warn("sessions: ignore relay subscription of user session", "sessionId", s.Id, "user", s.user)
+		return
+	}
+	subscribed := make(map[string]message.SubscribedTrack, len(subscription.Tracks))
+	for _, track := range subscription.Tracks {
+		subscribed[track.Id] = track
+	}
+	s.relay.mu.Lock()
+	s.relay.subscribed = subscribed
+	s.relay.mu.Unlock()
+
+	go s.hub.DispatchUpdateForwarding(s.ctx, s.Id)
+}
+
+func (s *Session) announcedTracks() []message.RelayTrack {
+	s.relay.mu.Lock()
+	defer s.relay.mu.Unlock()
+	return append([]message.RelayTrack(nil), s.relay.announced...)
+}
+
+// announcedTrack returns the announcement of a track the ingress endpoint receives from the remote instance
+func (s *Session) announcedTrack(trackId string) (message.RelayTrack, bool) {
+	s.relay.mu.Lock()
+	defer s.relay.mu.Unlock()
+	for _, track := range s.relay.announced {
+		if track.TrackId != "" && track.TrackId == trackId {
+			return track, true
+		}
+	}
+	return message.RelayTrack{}, false
+}
+
+// subscribedTracks returns nil, until the remote instance subscribed the first time
+func (s *Session) subscribedTracks() map[string]message.SubscribedTrack {
+	s.relay.mu.Lock()
+	defer s.relay.mu.Unlock()
+	if s.relay.subscribed == nil {
+		return nil
+	}
+	subscribed := make(map[string]message.SubscribedTrack, len(s.relay.subscribed))
+	for id, track := range s.relay.subscribed {
+		subscribed[id] = track
+	}
+	return subscribed
+}
+
+// relayTo updates the announcement and the subscription the remote instance gets
+func (s *Session) relayTo(tracks *message.RelayTracks, subscription *message.RelaySubscription) {
+	s.relay.mu.Lock()
+	s.relay.tracks = tracks
+	s.relay.subscription = subscription
+	s.relay.mu.Unlock()
+	select {
+	case s.relay.updated <- struct{}{}:
+	default:
+	}
+}
+
+// runRelay sends the announcement and the subscription, when they changed.
+// A messenger blocks until its data channel is open, so the messages are sent in an own goroutine, always the latest first.
+func (s *Session) runRelay() {
+	var sentTracks *message.RelayTracks
+	var sentSubscription *message.RelaySubscription
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-s.relay.updated:
+		}
+		if s.signal.messenger == nil {
+			continue
+		}
+
+		s.relay.mu.Lock()
+		tracks, subscription := s.relay.tracks, s.relay.subscription
+		s.relay.mu.Unlock()
+
+		if tracks != nil && !reflect.DeepEqual(tracks, sentTracks) {
+			if err := s.signal.messenger.SendRelayTracks(tracks); err != nil {
+				slog.Error("sessions: send relay tracks", "err", err, "sessionId", s.Id, "user", s.user)
+				continue
+			}
+			sentTracks = tracks
+		}
+		if subscription != nil && !reflect.DeepEqual(subscription, sentSubscription) {
+			if err := s.signal.messenger.SendRelaySubscription(subscription); err != nil {
+				slog.Error("sessions: send relay subscription", "err", err, "sessionId", s.Id, "user", s.user)
+				continue
+			}
+			sentSubscription = subscription
+		}
+	}
+}
+
+// selectLayers selects the simulcast layers of the videos the remote instance subscribed
+func (s *Session) selectLayers(layers map[uuid.UUID]rtp.VideoQuality) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.egress == nil {
+		return
+	}
+	for id, quality := range layers {
+		s.egress.SetVideoQuality(id, quality)
+	}
+}
+
+// syncRelay announces the tracks of the lobby to the remote instance of the session, subscribes to the announced tracks
+// the lobby needs and forwards the tracks the remote instance subscribed
+func (h *Hub) syncRelay(s *Session) {
+	s.relayTo(h.relayTracks(s), selectRelayTracks(s.announcedTracks(), h.relayDemand(s)))
+	h.forward(h.ctx, s)
+}
+
+// relayTracks returns the tracks of the lobby for the remote instance of the session.
+// Tracks of other remote instances, this instance does not receive yet, are announced as well, so that the remote
+// instance can subscribe to them over this instance.
+func (h *Hub) relayTracks(s *Session) *message.RelayTracks {
+	spoken := h.speakers.lastSpoken()
+	tracks := make([]message.RelayTrack, 0, len(h.tracks))
+	announced := make(map[string]bool)
+	for _, track := range h.tracks {
+		if !filterForSession(s.Id)(track) {
+			continue
+		}
+		relayTrack := h.relayTrack(track, spoken)
+		announced[relayTrack.Id] = true
+		tracks = append(tracks, relayTrack)
+	}
+
+	others := make([]*Session, 0)
+	h.sessionRepo.Iter(func(other *Session) {
+		if other.Id != s.Id && other.isInstance() {
+			others = append(others, other)
+		}
+	})
+	for _, other := range others {
+		for _, track := range other.announcedTracks() {
+			if announced[track.Id] {
+				continue
+			}
+			track.TrackId = ""
+			announced[track.Id] = true
+			tracks = append(tracks, track)
+		}
+	}
+
+	sort.Slice(tracks, func(i, j int) bool {
+		return tracks[i].Id < tracks[j].Id
+	})
+	return &message.RelayTracks{Tracks: tracks}
+}
+
+// relayTrack announces a track of the lobby. A track received from a remote instance keeps the id, session and
+// speaker activity of its announcement, so that every instance knows the track by the same id.
+func (h *Hub) relayTrack(track *rtp.TrackInfo, spoken map[uuid.UUID]time.Time) message.RelayTrack {
+	relayTrack := message.RelayTrack{
+		Id:        track.GetTrackLocal().ID(),
+		TrackId:   track.GetTrackLocal().ID(),
+		SessionId: track.GetSessionId().String(),
+		Kind:      track.GetTrackLocal().Kind().String(),
+		Purpose:   track.GetPurpose().ToString(),
+		Mute:      track.GetMute(),
+		Info:      track.Info,
+		Layers:    layerNames(track.GetLayers()),
+	}
+	if lastSpoken, found := spoken[track.GetSessionId()]; found {
+		relayTrack.Spoken = lastSpoken.Unix()
+	}
+
+	if source, found := h.sessionRepo.FindById(track.GetSessionId()); found && source.isInstance() {
+		if origin, found := source.announcedTrack(track.IngressTrackId); found {
+			relayTrack.Id = origin.Id
+			relayTrack.SessionId = origin.SessionId
+			relayTrack.Spoken = origin.Spoken
+		}
+	}
+	return relayTrack
+}
+
+// relayDemand collects what the sessions of this instance and the other remote instances need of the remote instance
+func (h *Hub) relayDemand(s *Session) relayDemand {
+	demand := relayDemand{
+		consumers: h.sender != nil || len(h.packagers) > 0 || len(h.recorders) > 0,
+		viewers:   make([]relayViewer, 0),
+		relayed:   make(map[string]rtp.VideoQuality),
+	}
+	h.sessionRepo.Iter(func(other *Session) {
+		if other.Id == s.Id {
+			return
+		}
+		if !other.isInstance() {
+			demand.consumers = true
+			demand.viewers = append(demand.viewers, relayViewer{lastN: other.lastN(h.lastN), pinned: other.pinnedSessions()})
+			return
+		}
+		for id, track := range other.subscribedTracks() {
+			quality, ok := parseLayer(track.Layer)
+			if !ok {
+				quality = rtp.VideoQuality_LOW
+			}
+			if current, found := demand.relayed[id]; !found || current < quality {
+				demand.relayed[id] = quality
+			}
+		}
+	})
+	return demand
+}
+
+// selectRelayed returns the tracks the remote instance of the session subscribed and the layers of the videos.
+// A remote instance that did not subscribe yet receives every track, like an instance that does not know the relay protocol.
+func (h *Hub) selectRelayed(s *Session) ([]*rtp.TrackInfo, map[uuid.UUID]rtp.VideoQuality) {
+	subscribed := s.subscribedTracks()
+	tracks := make([]*rtp.TrackInfo, 0, len(h.tracks))
+	layers := make(map[uuid.UUID]rtp.VideoQuality)
+	for _, track := range h.tracks {
+		if !filterForSession(s.Id)(track) {
+			continue
+		}
+		if subscribed == nil {
+			tracks = append(tracks, track)
+			continue
+		}
+		subscription, found := subscribed[h.relayTrack(track, nil).Id]
+		if !found {
+			continue
+		}
+		tracks = append(tracks, track)
+		if quality, ok := parseLayer(subscription.Layer); ok {
+			layers[track.GetId()] = quality
+		}
+	}
+	sort.Slice(tracks, func(i, j int) bool {
+		return tracks[i].GetTrackLocal().ID() < tracks[j].GetTrackLocal().ID()
+	})
+	return tracks, layers
+}
+
+// selectRelayTracks subscribes to the announced tracks the lobby needs. The main tracks are needed by every consumer,
+// the guest audio by every viewer. The guest videos are selected like the last-N selection of the viewers,
+// but only among the announced videos, so the subscription contains every video a viewer could select.
+// Pinned videos and main videos are subscribed in high quality, the other videos in medium quality.
+func selectRelayTracks(announced []message.RelayTrack, demand relayDemand) *message.RelaySubscription {
+	wanted := make(map[string]rtp.VideoQuality)
+	want := func(id string, quality rtp.VideoQuality) {
+		if current, found := wanted[id]; !found || current < quality {
+			wanted[id] = quality
+		}
+	}
+
+	videos := make([]message.RelayTrack, 0)
+	for _, track := range announced {
+		if quality, found := demand.relayed[track.Id]; found {
+			want(track.Id, quality)
+		}
+		switch {
+		case track.Purpose == rtp.PurposeMain.ToString():
+			if demand.consumers {
+				want(track.Id, rtp.VideoQuality_HIGH)
+			}
+		case track.Kind == webrtc.RTPCodecTypeVideo.String():
+			videos = append(videos, track)
+		default:
+			if len(demand.viewers) > 0 {
+				want(track.Id, rtp.VideoQuality_LOW)
+			}
+		}
+	}
+
+	// the most recent speakers first
+	sort.SliceStable(videos, func(i, j int) bool {
+		return videos[i].Spoken > videos[j].Spoken
+	})
+	for _, viewer := range demand.viewers {
+		pinned := make(map[string]bool, len(viewer.pinned))
+		for _, sessionId := range viewer.pinned {
+			pinned[sessionId.String()] = true
+		}
+		selected := make(map[string]bool)
+		for _, video := range videos {
+			if pinned[video.SessionId] {
+				want(video.Id, rtp.VideoQuality_HIGH)
+				continue
+			}
+			if viewer.lastN > 0 && !selected[video.SessionId] && len(selected) >= viewer.lastN {
+				continue
+			}
+			selected[video.SessionId] = true
+			want(video.Id, rtp.VideoQuality_MEDIUM)
+		}
+	}
+
+	subscription := &message.RelaySubscription{Tracks: make([]message.SubscribedTrack, 0, len(wanted))}
+	for _, track := range announced {
+		quality, found := wanted[track.Id]
+		if !found {
+			continue
+		}
+		subscribed := message.SubscribedTrack{Id: track.Id}
+		if len(track.Layers) > 0 {
+			subscribed.Layer = rtp.VideoQuality_name[int32(quality)]
+		}
+		subscription.Tracks = append(subscription.Tracks, subscribed)
+	}
+	return subscription
+}
+
+func layerNames(qualities []rtp.VideoQuality) []string {
+	if len(qualities) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(qualities))
+	for _, quality := range qualities {
+		names = append(names, rtp.VideoQuality_name[int32(quality)])
+	}
+	return names
+}
+
+func parseLayer(layer string) (rtp.VideoQuality, bool) {
+	quality, found := rtp.VideoQuality_value[layer]
+	if !found || rtp.VideoQuality(quality) == rtp.VideoQuality_OFF {
+		return rtp.VideoQuality_OFF, false
+	}
+	return rtp.VideoQuality(quality), true
+}
@@ -0,0 +1,121 @@
+package sessions
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shigde/sfu/internal/lobby/mocks"
+	"github.com/shigde/sfu/internal/rtp"
+	"github.com/shigde/sfu/pkg/message"
+	"github.com/stretchr/testify/assert"
+)
+
+func testRelayTracksSetup(t *testing.T) ([]message.RelayTrack, uuid.UUID, uuid.UUID) {
+	t.Helper()
+	first, second := uuid.New(), uuid.New()
+	announced := []message.RelayTrack{
+		{Id: "main-video", SessionId: uuid.NewString(), Kind: "video", Purpose: "main", Layers: []string{"LOW", "HIGH"}},
+		{Id: "first-audio", SessionId: first.String(), Kind: "audio", Purpose: "guest"},
+		{Id: "first-video", SessionId: first.String(), Kind: "video", Purpose: "guest", Layers: []string{"LOW", "MEDIUM", "HIGH"}},
+		{Id: "second-video", SessionId: second.String(), Kind: "video", Purpose: "guest", Spoken: 10},
+	}
+	return announced, first, second
+}
+
+func TestSelectRelayTracks(t *testing.T) {
+	t.Run("subscribe to nothing without consumers", func(t *testing.T) {
+		announced, _, _ := testRelayTracksSetup(t)
+		subscription := selectRelayTracks(announced, relayDemand{})
+		assert.Empty(t, subscription.Tracks)
+	})
+
+	t.Run("subscribe to main tracks for the live stream", func(t *testing.T) {
+		announced, _, _ := testRelayTracksSetup(t)
+		subscription := selectRelayTracks(announced, relayDemand{consumers: true})
+		assert.Equal(t, []message.SubscribedTrack{{Id: "main-video", Layer: "HIGH"}}, subscription.Tracks)
+	})
+
+	t.Run("subscribe to all tracks for viewers without last-n", func(t *testing.T) {
+		announced, _, _ := testRelayTracksSetup(t)
+		subscription := selectRelayTracks(announced, relayDemand{consumers: true, viewers: []relayViewer{{lastN: 0}}})
+		assert.Equal(t, []message.SubscribedTrack{
+			{Id: "main-video", Layer: "HIGH"},
+			{Id: "first-audio"},
+			{Id: "first-video", Layer: "MEDIUM"},
+			{Id: "second-video"},
+		}, subscription.Tracks)
+	})
+
+	t.Run("subscribe to the videos of the most recent speakers", func(t *testing.T) {
+		announced, _, _ := testRelayTracksSetup(t)
+		subscription := selectRelayTracks(announced, relayDemand{consumers: true, viewers: []relayViewer{{lastN: 1}}})
+		assert.Equal(t, []message.SubscribedTrack{
+			{Id: "main-video", Layer: "HIGH"},
+			{Id: "first-audio"},
+			{Id: "second-video"},
+		}, subscription.Tracks)
+	})
+
+	t.Run("subscribe to pinned videos in high quality", func(t *testing.T) {
+		announced, first, _ := testRelayTracksSetup(t)
+		subscription := selectRelayTracks(announced, relayDemand{consumers: true, viewers: []relayViewer{{lastN: 1, pinned: []uuid.UUID{first}}}})
+		assert.Contains(t, subscription.Tracks, message.SubscribedTrack{Id: "first-video", Layer: "HIGH"})
+		assert.Contains(t, subscription.Tracks, message.SubscribedTrack{Id: "second-video"})
+	})
+
+	t.Run("subscribe to the tracks other instances subscribed", func(t *testing.T) {
+		announced, _, _ := testRelayTracksSetup(t)
+		subscription := selectRelayTracks(announced, relayDemand{relayed: map[string]rtp.VideoQuality{"first-video": rtp.VideoQuality_LOW}})
+		assert.Equal(t, []message.SubscribedTrack{{Id: "first-video", Layer: "LOW"}}, subscription.Tracks)
+	})
+}
+
+func TestSessionRelay(t *testing.T) {
+	t.Run("ignore relay messages of user sessions", func(t *testing.T) {
+		hub, stop := testHubSetup(t)
+		defer stop()
+		session := testHubSessionSetup(t, hub)
+
+		session.onRelayTracks(&message.RelayTracks{Tracks: []message.RelayTrack{{Id: "track"}}})
+		session.onRelaySubscription(&message.RelaySubscription{Tracks: []message.SubscribedTrack{{Id: "track"}}})
+
+		assert.Empty(t, session.announcedTracks())
+		assert.Empty(t, session.subscribedTracks())
+	})
+
+	t.Run("find announced track by the track id of the connection", func(t *testing.T) {
+		hub, stop := testHubSetup(t)
+		defer stop()
+		session := NewSession(hub.ctx, uuid.New(), hub, nil, RemoteInstanceSession, nil)
+
+		session.onRelayTracks(&message.RelayTracks{Tracks: []message.RelayTrack{{Id: "origin", TrackId: "track"}, {Id: "pending"}}})
+
+		track, found := session.announcedTrack("track")
+		assert.True(t, found)
+		assert.Equal(t, "origin", track.Id)
+		_, found = session.announcedTrack("")
+		assert.False(t, found)
+	})
+
+	t.Run("forward every track until the remote instance subscribes", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		hub := NewHub(ctx, NewSessionRepository(), uuid.New(), mocks.NewLiveSender(), nil)
+		session := NewSession(ctx, uuid.New(), hub, nil, RemoteInstanceSession, nil)
+		first, second := testGuestVideo(t, uuid.New()), testGuestVideo(t, uuid.New())
+		hub.tracks[first.GetTrackLocal().ID()] = first
+		hub.tracks[second.GetTrackLocal().ID()] = second
+
+		tracks, _ := hub.selectRelayed(session)
+		assert.Len(t, tracks, 2)
+
+		session.onRelaySubscription(&message.RelaySubscription{Tracks: []message.SubscribedTrack{{Id: first.GetTrackLocal().ID()}}})
+		tracks, _ = hub.selectRelayed(session)
+		assert.Equal(t, []*rtp.TrackInfo{first}, tracks)
+
+		session.onRelaySubscription(&message.RelaySubscription{Tracks: []message.SubscribedTrack{}})
+		tracks, _ = hub.selectRelayed(session)
+		assert.Empty(t, tracks)
+	})
+}
@@ -0,0 +1,46 @@
+package sessions
+
+// Role of a user in a lobby, the role decides what the session of the user is allowed to do
+type Role string
+
+const (
+	// RoleHost is the owner of the live stream
+	RoleHost Role = "host"
+	// RoleCoHost is a user the host granted the moderation of the lobby
+	RoleCoHost Role = "co-host"
+	// RoleGuest is a guest of the live stream video, guests publish their tracks to the lobby
+	RoleGuest Role = "guest"
+	// RoleViewer only watches the lobby
+	RoleViewer Role = "viewer"
+)
+
+// Right is an action in a lobby that needs a permission
+type Right int
+
+const (
+	RightPublish Right = iota + 1
+	RightSubscribe
+	RightModerate
+)
+
+var roleRights = map[Role][]Right{
+	RoleHost:   {RightPublish, RightSubscribe, RightModerate},
+	RoleCoHost: {RightPublish, RightSubscribe, RightModerate},
+	RoleGuest:  {RightPublish, RightSubscribe},
+	RoleViewer: {RightSubscribe},
+}
+
+// Has returns whether the role grants the right, unknown roles have no rights
+func (r Role) Has(right Right) bool {
+	for _, granted := range roleRights[r] {
+		if granted == right {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Role) IsValid() bool {
+	_, ok := roleRights[r]
+	return ok
+}
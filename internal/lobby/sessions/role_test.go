@@ -0,0 +1,35 @@
+package sessions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRole(t *testing.T) {
+	t.Run("moderators have all rights", func(t *testing.T) {
+		for _, role := range []Role{RoleHost, RoleCoHost} {
+			assert.True(t, role.Has(RightPublish))
+			assert.True(t, role.Has(RightSubscribe))
+			assert.True(t, role.Has(RightModerate))
+		}
+	})
+
+	t.Run("guests publish but do not moderate", func(t *testing.T) {
+		assert.True(t, RoleGuest.Has(RightPublish))
+		assert.True(t, RoleGuest.Has(RightSubscribe))
+		assert.False(t, RoleGuest.Has(RightModerate))
+	})
+
+	t.Run("viewers only subscribe", func(t *testing.T) {
+		assert.False(t, RoleViewer.Has(RightPublish))
+		assert.True(t, RoleViewer.Has(RightSubscribe))
+		assert.False(t, RoleViewer.Has(RightModerate))
+	})
+
+	t.Run("unknown role has no rights", func(t *testing.T) {
+		role := Role("admin")
+		assert.False(t, role.IsValid())
+		assert.False(t, role.Has(RightSubscribe))
+	})
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -29,8 +31,13 @@ var (
 	ErrSessionAlreadyClosed         = errors.New("session already closed")
 	ErrIngressAlreadyExists         = errors.New("ingress resource already exists in session")
 	ErrEgressAlreadyExists          = errors.New("egress resource already exists in session")
+	ErrNoIngress                    = errors.New("no ingress resource exists in session")
+	ErrNoEgress                     = errors.New("no egress resource exists in session")
 	ErrNoSignalChannel              = errors.New("no signal channel connection exists in session")
 	ErrSessionProcessWaitingTimeout = errors.New("session process waiting timeout")
+	ErrNoIceSession                 = errors.New("no ice session of the session matches the sdp fragment")
+	ErrIceSessionChanged            = errors.New("ice session of the endpoint changed")
+	ErrInvalidResumeToken           = errors.New("invalid resume token of the session")
 	processWaitingTimeout           = 10 * time.Second // Ice gathering could take a long tine :-(
 
 )
@@ -50,11 +57,38 @@ type Session struct {
 	signalChannel *rtp.Endpoint
 	signal        *signal
 
+	// last-N settings of the session and the guest videos forwarded to the egress endpoint
+	forwardingMu  sync.Mutex
+	lastNOverride *int
+	pinned        []uuid.UUID
+	forwarded     map[string]*rtp.TrackInfo // trackID --> TrackInfo
+
+	// tracks the remote instance of an instance session announced and subscribed
+	relay *relay
+
+	onModerationHandler func(user uuid.UUID, moderation *message.Moderation)
+
+	// ingress mids of the tracks a moderator muted, the client can not unmute them itself
+	moderatedMu sync.Mutex
+	moderated   map[string]bool
+
+	roleMu sync.RWMutex
+	role   Role
+
+	// a client that lost its connection can reconnect with the resume token, until the grace period is over
+	gracePeriod  time.Duration
+	resumeToken  string
+	dispatcher   *ingressDispatcher
+	pauseMu      sync.Mutex
+	resumed      chan struct{} // nil while the session is not paused
+	closeIngress context.CancelFunc
+	closeEgress  context.CancelFunc
+
 	stop    context.CancelFunc
 	garbage chan<- Item
 }
 
-func NewSession(ctx context.Context, user uuid.UUID, hub *Hub, engine RtpEngine, sType SessionType, garbage chan Item) *Session {
+func NewSession(ctx context.Context, user uuid.UUID, hub *Hub, engine RtpEngine, sType SessionType, garbage chan Item, options ...SessionOption) *Session {
 	sessionId := uuid.New()
 	ctx = telemetry.ContextWithSessionValue(ctx, sessionId.String(), hub.LiveStreamId.String(), user.String())
 	ctx, cancel := context.WithCancel(ctx)
@@ -72,11 +106,35 @@ func NewSession(ctx context.Context, user uuid.UUID, hub *Hub, engine RtpEngine,
 		rtpEngine: engine,
 		hub:       hub,
 		signal:    signal,
-		stop:      cancel,
-		garbage:   garbage,
+		forwarded: make(map[string]*rtp.TrackInfo),
+		relay:     newRelay(),
+		moderated: make(map[string]bool),
+		role:      RoleViewer,
+
+		resumeToken: uuid.NewString(),
+		dispatcher:  newIngressDispatcher(hub),
+
+		stop:    cancel,
+		garbage: garbage,
 	}
 
 	signal.onMuteCbk = session.onMuteTrack
+	signal.onLastNCbk = session.onLastN
+	signal.onChatCbk = session.onChat
+	signal.onReactionCbk = session.onReaction
+	signal.onRaiseHandCbk = session.onRaiseHand
+	signal.onMessengerCbk = session.onMessenger
+	signal.onModerationCbk = session.onModeration
+	signal.onRelayTracksCbk = session.onRelayTracks
+	signal.onSubscriptionCbk = session.onRelaySubscription
+
+	for _, opt := range options {
+		opt(session)
+	}
+
+	if session.isInstance() {
+		go session.runRelay()
+	}
 
 	return session
 }
@@ -95,22 +153,52 @@ func (s *Session) CreateIngressEndpoint(ctx context.Context, offer *webrtc.Sessi
 	if s.ingress != nil {
 		return nil, telemetry.RecordError(span, ErrIngressAlreadyExists)
 	}
+	return s.createIngressEndpoint(ctx, span, offer, signalKind)
+}
+
+// ResumeIngressEndpoint
+// Replaces the ingress endpoint of a client that reconnects with the resume token of the session.
+// The tracks of the former endpoint stay in the lobby, until the tracks of the new endpoint replace them.
+func (s *Session) ResumeIngressEndpoint(ctx context.Context, offer *webrtc.SessionDescription, signalKind SignalChannelKind, resumeToken string) (*webrtc.SessionDescription, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ctx, span := s.trace(ctx, "resume_ingress_endpoint")
+	defer span.End()
+	if s.isDone() {
+		return nil, telemetry.RecordError(span, ErrSessionAlreadyClosed)
+	}
+	if resumeToken != s.resumeToken {
+		return nil, telemetry.RecordError(span, ErrInvalidResumeToken)
+	}
+
+	// the client could notice the lost connection before the server
+	s.pause()
+	if s.closeIngress != nil {
+		s.closeIngress()
+	}
+	s.ingress = nil
+	return s.createIngressEndpoint(ctx, span, offer, signalKind)
+}
 
+func (s *Session) createIngressEndpoint(ctx context.Context, span trace.Span, offer *webrtc.SessionDescription, signalKind SignalChannelKind) (*webrtc.SessionDescription, error) {
+	endpointCtx, closeEndpoint := context.WithCancel(s.ctx)
 	option := make([]rtp.EndpointOption, 0)
-	option = append(option, rtp.EndpointWithDataChannel(buildSignalDataChannelCbk(s, signalKind)))
-	option = append(option, rtp.EndpointWithLostConnectionListener(s.onLostConnection))
-	option = append(option, rtp.EndpointWithTrackDispatcher(s.hub))
+	option = append(option, rtp.EndpointWithDataChannel(buildSignalDataChannelCbk(s, signalKind, rtp.IngressEndpoint)))
+	option = append(option, rtp.EndpointWithLostConnectionListener(s.lostConnectionListener(endpointCtx)))
+	option = append(option, rtp.EndpointWithonIceStateConnectedListener(s.onIceConnected))
+	option = append(option, rtp.EndpointWithTrackDispatcher(s.dispatcher))
+	option = append(option, rtp.EndpointWithAudioLevelListener(s.onAudioLevel))
 
-	endpoint, err := s.rtpEngine.EstablishEndpoint(ctx, s.ctx, s.Id, s.hub.LiveStreamId, *offer, rtp.IngressEndpoint, option...)
+	endpoint, err := s.rtpEngine.EstablishEndpoint(ctx, endpointCtx, s.Id, s.hub.LiveStreamId, *offer, rtp.IngressEndpoint, option...)
 	if err != nil {
+		closeEndpoint()
 		return nil, telemetry.RecordErrorf(span, "create rtp endpoint", err)
 	}
 	s.ingress = endpoint
+	s.closeIngress = closeEndpoint
+	s.signal.setAnswerer(s.ingress)
 
-	span.AddEvent("Wait for Local Description.")
-	ctxTimeout, cancel := context.WithTimeout(ctx, processWaitingTimeout)
-	defer cancel()
-	answer, err := s.ingress.GetLocalDescription(ctxTimeout)
+	answer, err := s.answer(ctx, span, s.ingress, offer)
 	if err != nil {
 		return nil, telemetry.RecordErrorf(span, "create ingress answer resource", err)
 	}
@@ -119,6 +207,31 @@ func (s *Session) CreateIngressEndpoint(ctx context.Context, offer *webrtc.Sessi
 	return answer, nil
 }
 
+type trickleIceContextKey struct{}
+
+// ContextWithTrickleIce marks the request of a client that opted in to trickle ICE.
+// Nearly every offer announces trickle ICE, but only clients that opted in send their candidates later on.
+func ContextWithTrickleIce(ctx context.Context) context.Context {
+	return context.WithValue(ctx, trickleIceContextKey{}, true)
+}
+
+func trickleIceFromContext(ctx context.Context) bool {
+	trickle, _ := ctx.Value(trickleIceContextKey{}).(bool)
+	return trickle
+}
+
+// answer returns the answer of the endpoint. Clients that opted in to trickle ICE receive the answer right away,
+// all other clients wait until the ICE gathering is complete and all candidates are part of the answer.
+func (s *Session) answer(ctx context.Context, span trace.Span, endpoint *rtp.Endpoint, offer *webrtc.SessionDescription) (*webrtc.SessionDescription, error) {
+	if trickleIceFromContext(ctx) && rtp.SupportsTrickle(offer) {
+		return endpoint.GetCurrentLocalDescription(ctx)
+	}
+	span.AddEvent("Wait for Local Description.")
+	ctxTimeout, cancel := context.WithTimeout(ctx, processWaitingTimeout)
+	defer cancel()
+	return endpoint.GetLocalDescription(ctxTimeout)
+}
+
 // OfferIngressEndpoint
 // Creates an offer and prepares an Ingress WebRTC connection. The connection then waits for the response from the
 // other peer.
@@ -135,16 +248,22 @@ func (s *Session) OfferIngressEndpoint(ctx context.Context, signalKind SignalCha
 		return nil, telemetry.RecordError(span, ErrIngressAlreadyExists)
 	}
 
+	endpointCtx, closeEndpoint := context.WithCancel(s.ctx)
 	option := make([]rtp.EndpointOption, 0)
-	option = append(option, rtp.EndpointWithDataChannel(buildSignalDataChannelCbk(s, signalKind)))
-	option = append(option, rtp.EndpointWithLostConnectionListener(s.onLostConnection))
-	option = append(option, rtp.EndpointWithTrackDispatcher(s.hub))
+	option = append(option, rtp.EndpointWithDataChannel(buildSignalDataChannelCbk(s, signalKind, rtp.IngressEndpoint)))
+	option = append(option, rtp.EndpointWithLostConnectionListener(s.lostConnectionListener(endpointCtx)))
+	option = append(option, rtp.EndpointWithonIceStateConnectedListener(s.onIceConnected))
+	option = append(option, rtp.EndpointWithTrackDispatcher(s.dispatcher))
+	option = append(option, rtp.EndpointWithAudioLevelListener(s.onAudioLevel))
 
-	endpoint, err := s.rtpEngine.OfferEndpoint(ctx, s.ctx, s.Id, s.hub.LiveStreamId, rtp.IngressEndpoint, option...)
+	endpoint, err := s.rtpEngine.OfferEndpoint(ctx, endpointCtx, s.Id, s.hub.LiveStreamId, rtp.IngressEndpoint, option...)
 	if err != nil {
+		closeEndpoint()
 		return nil, telemetry.RecordErrorf(span, "create rtp endpoint", err)
 	}
 	s.ingress = endpoint
+	s.closeIngress = closeEndpoint
+	s.signal.setAnswerer(s.ingress)
 
 	span.AddEvent("Wait for Local Description.")
 	ctxTimeout, cancel := context.WithTimeout(ctx, processWaitingTimeout)
@@ -172,33 +291,70 @@ func (s *Session) CreateEgressEndpoint(ctx context.Context, offer *webrtc.Sessio
 	if s.egress != nil {
 		return nil, telemetry.RecordError(span, ErrEgressAlreadyExists)
 	}
+	return s.createEgressEndpoint(ctx, span, offer, signalKind)
+}
+
+// ResumeEgressEndpoint
+// Replaces the egress endpoint of a client that reconnects with the resume token of the session.
+func (s *Session) ResumeEgressEndpoint(ctx context.Context, offer *webrtc.SessionDescription, signalKind SignalChannelKind, resumeToken string) (*webrtc.SessionDescription, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ctx, span := s.trace(ctx, "resume_egress_endpoint")
+	defer span.End()
+	if s.isDone() {
+		return nil, telemetry.RecordError(span, ErrSessionAlreadyClosed)
+	}
+	if resumeToken != s.resumeToken {
+		return nil, telemetry.RecordError(span, ErrInvalidResumeToken)
+	}
 
+	// the client could notice the lost connection before the server
+	s.pause()
+	if s.closeEgress != nil {
+		s.closeEgress()
+	}
+	s.egress = nil
+	s.forwardingMu.Lock()
+	s.forwarded = make(map[string]*rtp.TrackInfo)
+	s.forwardingMu.Unlock()
+	return s.createEgressEndpoint(ctx, span, offer, signalKind)
+}
+
+func (s *Session) createEgressEndpoint(ctx context.Context, span trace.Span, offer *webrtc.SessionDescription, signalKind SignalChannelKind) (*webrtc.SessionDescription, error) {
 	if err := s.waitForSignalChannel(); err != nil {
 		return nil, telemetry.RecordErrorf(span, "waiting for signal channel", err)
 	}
 
 	hub := s.hub
 	withTrackCbk := rtp.EndpointWithGetCurrentTrackCbk(func(ctx context.Context, sessionId uuid.UUID) ([]*rtp.TrackInfo, error) {
-		return hub.getTrackList(ctx, sessionId, filterForSession(sessionId))
+		list, err := hub.getTrackList(ctx, sessionId, filterForSession(sessionId))
+		s.setForwarded(list)
+		return list, err
 	})
 
+	endpointCtx, closeEndpoint := context.WithCancel(s.ctx)
 	option := make([]rtp.EndpointOption, 0)
 	option = append(option, withTrackCbk)
-	option = append(option, rtp.EndpointWithDataChannel(buildSignalDataChannelCbk(s, signalKind))) // silent
+	option = append(option, rtp.EndpointWithDataChannel(buildSignalDataChannelCbk(s, signalKind, rtp.EgressEndpoint))) // silent
 	option = append(option, rtp.EndpointWithNegotiationNeededListener(s.signal.OnNegotiationNeeded))
-	option = append(option, rtp.EndpointWithLostConnectionListener(s.onLostConnection))
+	option = append(option, rtp.EndpointWithIceStateListener(s.signal.OnConnectionStateChange))
+	option = append(option, rtp.EndpointWithLostConnectionListener(s.lostConnectionListener(endpointCtx)))
+	option = append(option, rtp.EndpointWithonIceStateConnectedListener(s.onIceConnected))
+	// the remote instance selects the layers of the videos it subscribed
+	if !s.isInstance() {
+		option = append(option, rtp.EndpointWithAdaptiveVideoQuality())
+	}
 
-	endpoint, err := s.rtpEngine.EstablishEndpoint(ctx, s.ctx, s.Id, s.hub.LiveStreamId, *offer, rtp.EgressEndpoint, option...)
+	endpoint, err := s.rtpEngine.EstablishEndpoint(ctx, endpointCtx, s.Id, s.hub.LiveStreamId, *offer, rtp.EgressEndpoint, option...)
 	if err != nil {
+		closeEndpoint()
 		return nil, telemetry.RecordErrorf(span, "create rtp endpoint", err)
 	}
 	s.egress = endpoint
+	s.closeEgress = closeEndpoint
 	s.signal.setOfferer(s.egress)
 
-	span.AddEvent("Wait for Local Description.")
-	ctxTimeout, cancel := context.WithTimeout(ctx, processWaitingTimeout)
-	defer cancel()
-	answer, err := s.egress.GetLocalDescription(ctxTimeout)
+	answer, err := s.answer(ctx, span, s.egress, offer)
 	if err != nil {
 		return nil, telemetry.RecordErrorf(span, "create egress answer resource", err)
 	}
@@ -222,26 +378,40 @@ func (s *Session) OfferEgressEndpoint(ctx context.Context, signalKind SignalChan
 		return nil, telemetry.RecordError(span, ErrEgressAlreadyExists)
 	}
 
-	if err := s.waitForSignalChannel(); err != nil {
-		return nil, telemetry.RecordErrorf(span, "waiting for signal channel", err)
+	// an egress endpoint that is not silent creates the signal channel itself
+	if signalKind == SilentSignalChannel {
+		if err := s.waitForSignalChannel(); err != nil {
+			return nil, telemetry.RecordErrorf(span, "waiting for signal channel", err)
+		}
 	}
 
 	hub := s.hub
 	withTrackCbk := rtp.EndpointWithGetCurrentTrackCbk(func(ctx context.Context, sessionId uuid.UUID) ([]*rtp.TrackInfo, error) {
-		return hub.getTrackList(ctx, sessionId, filterForSession(sessionId))
+		list, err := hub.getTrackList(ctx, sessionId, filterForSession(sessionId))
+		s.setForwarded(list)
+		return list, err
 	})
 
+	endpointCtx, closeEndpoint := context.WithCancel(s.ctx)
 	option := make([]rtp.EndpointOption, 0)
 	option = append(option, withTrackCbk)
-	option = append(option, rtp.EndpointWithDataChannel(buildSignalDataChannelCbk(s, signalKind))) // silent
+	option = append(option, rtp.EndpointWithDataChannel(buildSignalDataChannelCbk(s, signalKind, rtp.EgressEndpoint))) // silent
 	option = append(option, rtp.EndpointWithNegotiationNeededListener(s.signal.OnNegotiationNeeded))
-	option = append(option, rtp.EndpointWithLostConnectionListener(s.onLostConnection))
+	option = append(option, rtp.EndpointWithIceStateListener(s.signal.OnConnectionStateChange))
+	option = append(option, rtp.EndpointWithLostConnectionListener(s.lostConnectionListener(endpointCtx)))
+	option = append(option, rtp.EndpointWithonIceStateConnectedListener(s.onIceConnected))
+	// the remote instance selects the layers of the videos it subscribed
+	if !s.isInstance() {
+		option = append(option, rtp.EndpointWithAdaptiveVideoQuality())
+	}
 
-	endpoint, err := s.rtpEngine.OfferEndpoint(ctx, s.ctx, s.Id, s.hub.LiveStreamId, rtp.EgressEndpoint, option...)
+	endpoint, err := s.rtpEngine.OfferEndpoint(ctx, endpointCtx, s.Id, s.hub.LiveStreamId, rtp.EgressEndpoint, option...)
 	if err != nil {
+		closeEndpoint()
 		return nil, telemetry.RecordErrorf(span, "create rtp endpoint", err)
 	}
 	s.egress = endpoint
+	s.closeEgress = closeEndpoint
 	// @TODO: The signaling should be independent of ingress and egress
 	s.signal.setOfferer(s.egress)
 
@@ -256,6 +426,70 @@ func (s *Session) OfferEgressEndpoint(ctx context.Context, signalKind SignalChan
 	return answer, nil
 }
 
+// TrickleIce adds the trickled candidates of a sdp fragment to the endpoint the ICE credentials of the fragment belong to
+// and returns the local candidates gathered so far, so the client receives the candidates missing in the answer.
+// A fragment with new ICE credentials restarts ICE of the endpoint that lost its connection and returns the new local ICE credentials and candidates.
+// The endpoint type and the entity tag of the ICE session select the endpoint of a resource, if the client knows them.
+func (s *Session) TrickleIce(ctx context.Context, frag *rtp.SdpFragment, endpointType rtp.EndpointType, eTag string) (*rtp.SdpFragment, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ctx, span := s.trace(ctx, "trickle_ice")
+	defer span.End()
+	if s.isDone() {
+		return nil, telemetry.RecordError(span, ErrSessionAlreadyClosed)
+	}
+
+	selected := []*rtp.Endpoint{s.ingress, s.egress}
+	switch endpointType {
+	case rtp.IngressEndpoint:
+		selected = []*rtp.Endpoint{s.ingress}
+	case rtp.EgressEndpoint:
+		selected = []*rtp.Endpoint{s.egress}
+	}
+
+	endpoints := make([]*rtp.Endpoint, 0, 2)
+	for _, endpoint := range selected {
+		if endpoint == nil {
+			continue
+		}
+		// "*" matches every ICE session, clients use it to restart ICE
+		if eTag != "" && eTag != "*" && eTag != endpoint.GetIceSessionETag() {
+			return nil, telemetry.RecordError(span, ErrIceSessionChanged)
+		}
+		if endpoint.HasRemoteIceUfrag(frag.IceUfrag) {
+			if err := endpoint.AddIceCandidates(frag); err != nil {
+				return nil, telemetry.RecordErrorf(span, "trickle ice", err)
+			}
+			local, err := endpoint.GetLocalCandidates()
+			if err != nil {
+				return nil, telemetry.RecordErrorf(span, "get local candidates", err)
+			}
+			return local, nil
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+
+	// with new credentials the client restarts ICE, if a session has two endpoints, the restart is for the disconnected one
+	restart := make([]*rtp.Endpoint, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if len(endpoints) == 1 || !endpoint.IsIceConnected() {
+			restart = append(restart, endpoint)
+		}
+	}
+	if len(restart) != 1 {
+		return nil, telemetry.RecordError(span, ErrNoIceSession)
+	}
+
+	span.AddEvent("Restart ICE.")
+	ctxTimeout, cancel := context.WithTimeout(ctx, processWaitingTimeout)
+	defer cancel()
+	local, err := restart[0].RestartIce(ctxTimeout, frag)
+	if err != nil {
+		return nil, telemetry.RecordErrorf(span, "restart ice", err)
+	}
+	return local, nil
+}
+
 // waitForSignalChannel
 // For an egress endpoint we need a data channel. The data channel is used for media update signaling.
 // That's why we're waiting until it's built
@@ -280,6 +514,94 @@ func (s *Session) waitForSignalChannel() error {
 
 func (s *Session) onLostConnection() {
 	slog.Warn("session: connect lost connection", "sessionId", s.Id, "userId", s.user)
+	if s.gracePeriod > 0 {
+		s.pause()
+		return
+	}
+	s.leave()
+}
+
+// lostConnectionListener ignores the lost connections of endpoints the session closed, like the endpoints a reconnected client replaced
+func (s *Session) lostConnectionListener(endpointCtx context.Context) func() {
+	return func() {
+		if endpointCtx.Err() != nil {
+			return
+		}
+		s.onLostConnection()
+	}
+}
+
+// onIceConnected resumes a paused session, after all endpoints of the session are connected again
+func (s *Session) onIceConnected() {
+	go func() {
+		s.mutex.RLock()
+		connected := (s.ingress == nil || s.ingress.IsIceConnected()) && (s.egress == nil || s.egress.IsIceConnected())
+		s.mutex.RUnlock()
+		if connected {
+			s.resume()
+		}
+	}()
+}
+
+// pause keeps the session and its tracks in the lobby, until the client reconnects or the grace period is over.
+// The other sessions see the tracks of a paused session as muted.
+func (s *Session) pause() {
+	s.pauseMu.Lock()
+	defer s.pauseMu.Unlock()
+	if s.resumed != nil || s.isDone() {
+		return
+	}
+	slog.Info("sessions: pause session", "sessionId", s.Id, "user", s.user, "gracePeriod", s.gracePeriod)
+	resumed := make(chan struct{})
+	s.resumed = resumed
+	s.dispatcher.keep(true)
+	go s.hub.DispatchPauseSession(s.ctx, s.Id, true)
+	go s.waitForReconnect(resumed)
+}
+
+func (s *Session) resume() {
+	s.pauseMu.Lock()
+	defer s.pauseMu.Unlock()
+	if s.resumed == nil {
+		return
+	}
+	slog.Info("sessions: resume session", "sessionId", s.Id, "user", s.user)
+	close(s.resumed)
+	s.resumed = nil
+	s.dispatcher.keep(false)
+	// the tracks of the reconnected client replace the kept tracks in the meantime
+	time.AfterFunc(replaceTrackTimeout, func() {
+		s.dispatcher.release(s.ctx)
+	})
+	go s.hub.DispatchPauseSession(s.ctx, s.Id, false)
+}
+
+// waitForReconnect removes the session from the lobby, if the client does not reconnect in the grace period
+func (s *Session) waitForReconnect(resumed <-chan struct{}) {
+	select {
+	case <-resumed:
+		return
+	case <-s.ctx.Done():
+		return
+	case <-time.After(s.gracePeriod):
+	}
+
+	s.pauseMu.Lock()
+	if s.resumed != resumed {
+		s.pauseMu.Unlock()
+		return
+	}
+	// the session stays paused, a late reconnect must not resume it
+	s.dispatcher.keep(false)
+	s.pauseMu.Unlock()
+
+	slog.Warn("sessions: client did not reconnect in grace period", "sessionId", s.Id, "user", s.user, "gracePeriod", s.gracePeriod)
+	s.dispatcher.release(s.ctx)
+	s.leave()
+}
+
+// leave removes the session from the lobby and stops it
+func (s *Session) leave() {
 	go func(session *Session) {
 		select {
 		case <-session.ctx.Done():
@@ -327,6 +649,34 @@ func (s *Session) removeTrack(ctx context.Context, trackInfo *rtp.TrackInfo) {
 	}
 }
 
+// replaceTrack swaps a track of the egress endpoint for the track that replaces it, the client gets an offer if the track can not be swapped
+func (s *Session) replaceTrack(ctx context.Context, replaced *rtp.TrackInfo, trackInfo *rtp.TrackInfo) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.egress == nil {
+		return
+	}
+
+	if s.isSelected(trackInfo) {
+		s.forwardingMu.Lock()
+		defer s.forwardingMu.Unlock()
+		// tracks the session does not receive are selected by the forwarding
+		if _, found := s.forwarded[replaced.GetTrackLocal().ID()]; !found {
+			return
+		}
+		delete(s.forwarded, replaced.GetTrackLocal().ID())
+		s.forwarded[trackInfo.GetTrackLocal().ID()] = trackInfo
+	}
+
+	ctx, span := s.trace(ctx, "egress_replace_track")
+	defer span.End()
+	if _, ok := s.egress.ReplaceTrack(ctx, replaced, trackInfo); ok {
+		return
+	}
+	s.egress.RemoveTrack(ctx, replaced)
+	s.egress.AddTrack(ctx, trackInfo)
+}
+
 func (s *Session) muteTrack(ctx context.Context, trackInfo *rtp.TrackInfo) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
@@ -368,6 +718,11 @@ func (s *Session) onMuteTrack(mute *message.Mute) {
 		attribute.String("mid", mute.Mid),
 		attribute.String("mid", strconv.FormatBool(mute.Mute)),
 	)
+	if !mute.Mute && s.isModerated(mute.Mid) {
+		slog.Warn("session: reject unmute of a track a moderator muted", "sessionId", s.Id, "userId", s.user, "mid", mute.Mid)
+		span.AddEvent("Reject Unmute of Moderated Track")
+		return
+	}
 	if trackInfo, ok := s.ingress.SetIngressMute(mute.Mid, mute.Mute); ok {
 		go s.hub.DispatchMuteTrack(ctx, trackInfo)
 		// telemetry event
@@ -375,6 +730,394 @@ func (s *Session) onMuteTrack(mute *message.Mute) {
 	}
 }
 
+func (s *Session) isModerated(mid string) bool {
+	s.moderatedMu.Lock()
+	defer s.moderatedMu.Unlock()
+	return s.moderated[mid]
+}
+
+func (s *Session) onAudioLevel(level uint8) {
+	s.hub.UpdateAudioLevel(s.Id, level)
+}
+
+// sendDominantSpeaker tells the client who is speaking, with the mids of the speaker tracks in the egress connection
+func (s *Session) sendDominantSpeaker(speakerId uuid.UUID) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.signal.messenger == nil {
+		return
+	}
+	mids := make([]string, 0)
+	if s.egress != nil {
+		mids = s.egress.GetEgressMids(speakerId)
+	}
+	_ = s.signal.messenger.SendDominantSpeaker(&message.DominantSpeaker{
+		SessionId: speakerId.String(),
+		Mids:      mids,
+	})
+}
+
+func (s *Session) onLastN(lastN *message.LastN) {
+	pinned := make([]uuid.UUID, 0, len(lastN.Pinned))
+	for _, id := range lastN.Pinned {
+		sessionId, err := uuid.Parse(id)
+		if err != nil {
+			slog.Warn("sessions: ignore invalid pinned session", "pinned", id, "sessionId", s.Id, "user", s.user)
+			continue
+		}
+		pinned = append(pinned, sessionId)
+	}
+
+	s.forwardingMu.Lock()
+	s.lastNOverride = lastN.N
+	s.pinned = pinned
+	s.forwardingMu.Unlock()
+
+	go s.hub.DispatchUpdateForwarding(s.ctx, s.Id)
+}
+
+// lastN returns the last-N override of the session or the last-N of the lobby
+func (s *Session) lastN(lobbyLastN int) int {
+	s.forwardingMu.Lock()
+	defer s.forwardingMu.Unlock()
+	if s.lastNOverride != nil {
+		return *s.lastNOverride
+	}
+	return lobbyLastN
+}
+
+func (s *Session) pinnedSessions() []uuid.UUID {
+	s.forwardingMu.Lock()
+	defer s.forwardingMu.Unlock()
+	return append([]uuid.UUID(nil), s.pinned...)
+}
+
+func (s *Session) isForwarding(trackInfo *rtp.TrackInfo) bool {
+	s.forwardingMu.Lock()
+	defer s.forwardingMu.Unlock()
+	_, found := s.forwarded[trackInfo.GetTrackLocal().ID()]
+	return found
+}
+
+// setForwarded remembers the selected tracks the egress endpoint receives when it is established
+func (s *Session) setForwarded(list []*rtp.TrackInfo) {
+	s.forwardingMu.Lock()
+	defer s.forwardingMu.Unlock()
+	for _, trackInfo := range list {
+		if s.isSelected(trackInfo) {
+			s.forwarded[trackInfo.GetTrackLocal().ID()] = trackInfo
+		}
+	}
+}
+
+// forwardVideos changes the guest videos of the egress endpoint to the selected videos.
+// Where possible a video is swapped with a video that is no longer selected, this needs no renegotiation.
+// The tracks of instance sessions are never swapped, the remote instance knows a track by its id.
+func (s *Session) forwardVideos(ctx context.Context, selected []*rtp.TrackInfo) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.egress == nil {
+		return
+	}
+	s.forwardingMu.Lock()
+	defer s.forwardingMu.Unlock()
+
+	wanted := make(map[string]*rtp.TrackInfo, len(selected))
+	added := make([]*rtp.TrackInfo, 0)
+	for _, trackInfo := range selected {
+		wanted[trackInfo.GetTrackLocal().ID()] = trackInfo
+		if _, found := s.forwarded[trackInfo.GetTrackLocal().ID()]; !found {
+			added = append(added, trackInfo)
+		}
+	}
+	removed := make([]*rtp.TrackInfo, 0)
+	for id, trackInfo := range s.forwarded {
+		if _, found := wanted[id]; !found {
+			removed = append(removed, trackInfo)
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return
+	}
+
+	ctx, span := s.trace(ctx, "egress_forward_videos")
+	defer span.End()
+	span.SetAttributes(
+		attribute.Int("added", len(added)),
+		attribute.Int("removed", len(removed)),
+	)
+
+	replaced := false
+	for _, trackInfo := range added {
+		if len(removed) > 0 && !s.isInstance() {
+			old := removed[0]
+			removed = removed[1:]
+			delete(s.forwarded, old.GetTrackLocal().ID())
+			if _, ok := s.egress.ReplaceTrack(ctx, old, trackInfo); ok {
+				s.forwarded[trackInfo.GetTrackLocal().ID()] = trackInfo
+				replaced = true
+				continue
+			}
+			s.egress.RemoveTrack(ctx, old)
+		}
+		s.egress.AddTrack(ctx, trackInfo)
+		s.forwarded[trackInfo.GetTrackLocal().ID()] = trackInfo
+	}
+	for _, old := range removed {
+		delete(s.forwarded, old.GetTrackLocal().ID())
+		s.egress.RemoveTrack(ctx, old)
+	}
+
+	// the client does not get an offer for swapped videos, it needs to know whose video a mid shows now
+	if replaced && s.signal.messenger != nil {
+		_ = s.signal.messenger.SendForwarding(s.forwarding())
+		span.AddEvent("Send Forwarding to Client")
+	}
+}
+
+func (s *Session) forwarding() *message.Forwarding {
+	videos := make([]message.ForwardedVideo, 0, len(s.forwarded))
+	for _, trackInfo := range s.forwarded {
+		mid := s.egress.GetEgressMid(trackInfo.GetId())
+		if mid == "" {
+			continue
+		}
+		videos = append(videos, message.ForwardedVideo{
+			Mid:       mid,
+			SessionId: trackInfo.GetSessionId().String(),
+			Info:      trackInfo.Info,
+		})
+	}
+	sort.Slice(videos, func(i, j int) bool {
+		return videos[i].Mid < videos[j].Mid
+	})
+	return &message.Forwarding{Videos: videos}
+}
+
+// onChat stamps the chat message of the client, the client can not send messages in the name of others
+func (s *Session) onChat(chat *message.Chat) {
+	text := strings.TrimSpace(chat.Text)
+	if text == "" || len(text) > maxChatLength {
+		slog.Warn("sessions: ignore invalid chat message", "length", len(text), "sessionId", s.Id, "user", s.user)
+		return
+	}
+	go s.hub.DispatchChat(s.ctx, &message.Chat{
+		Id:        uuid.NewString(),
+		SessionId: s.Id.String(),
+		User:      s.user.String(),
+		Role:      string(s.GetRole()),
+		Text:      text,
+		Time:      time.Now(),
+	})
+}
+
+func (s *Session) onReaction(reaction *message.Reaction) {
+	if reaction.Emoji == "" || len(reaction.Emoji) > maxEmojiLength {
+		slog.Warn("sessions: ignore invalid reaction", "sessionId", s.Id, "user", s.user)
+		return
+	}
+	go s.hub.DispatchReaction(s.ctx, &message.Reaction{
+		SessionId: s.Id.String(),
+		User:      s.user.String(),
+		Role:      string(s.GetRole()),
+		Emoji:     reaction.Emoji,
+		Time:      time.Now(),
+	})
+}
+
+func (s *Session) onRaiseHand(raiseHand *message.RaiseHand) {
+	go s.hub.DispatchRaiseHand(s.ctx, &message.RaiseHand{
+		SessionId: s.Id.String(),
+		User:      s.user.String(),
+		Role:      string(s.GetRole()),
+		Raised:    raiseHand.Raised,
+		Time:      time.Now(),
+	})
+}
+
+// onMessenger sends the role and the chat history to the client, after the signal channel is established
+func (s *Session) onMessenger() {
+	go s.sendRole()
+	go s.hub.DispatchSendHistory(s.ctx, s.Id)
+}
+
+func (s *Session) sendChat(chat *message.Chat) {
+	if s.signal.messenger == nil {
+		return
+	}
+	_ = s.signal.messenger.SendChat(chat)
+}
+
+func (s *Session) sendReaction(reaction *message.Reaction) {
+	if s.signal.messenger == nil {
+		return
+	}
+	_ = s.signal.messenger.SendReaction(reaction)
+}
+
+func (s *Session) sendRaiseHand(raiseHand *message.RaiseHand) {
+	if s.signal.messenger == nil {
+		return
+	}
+	_ = s.signal.messenger.SendRaiseHand(raiseHand)
+}
+
+func (s *Session) sendRole() {
+	if s.signal.messenger == nil {
+		return
+	}
+	_ = s.signal.messenger.SendRole(&message.Role{SessionId: s.Id.String(), Role: string(s.GetRole())})
+}
+
+func (s *Session) sendHistory(history *message.History) {
+	if s.signal.messenger == nil {
+		return
+	}
+	_ = s.signal.messenger.SendHistory(history)
+}
+
+func (s *Session) onModeration(moderation *message.Moderation) {
+	if s.onModerationHandler == nil {
+		slog.Warn("sessions: moderation not supported", "sessionId", s.Id, "user", s.user)
+		return
+	}
+	go s.onModerationHandler(s.user, moderation)
+}
+
+// Mute mutes the ingress tracks of a kind for all other sessions, all tracks if the kind is unknown.
+// The client is told that a moderator muted it. Mute returns false if the session has no tracks to mute.
+func (s *Session) Mute(ctx context.Context, kind webrtc.RTPCodecType) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	ctx, span := s.trace(ctx, "moderator_mute")
+	defer span.End()
+	if s.ingress == nil {
+		return false
+	}
+
+	muted := false
+	s.moderatedMu.Lock()
+	for _, mid := range s.ingress.GetIngressMids(kind) {
+		if trackInfo, ok := s.ingress.SetIngressMute(mid, true); ok {
+			go s.hub.DispatchMuteTrack(ctx, trackInfo)
+			s.moderated[mid] = true
+			muted = true
+		}
+	}
+	s.moderatedMu.Unlock()
+	if muted && s.signal.messenger != nil {
+		moderation := &message.Moderation{Action: message.ModerationMute, SessionId: s.Id.String()}
+		if kind != 0 {
+			moderation.Kind = kind.String()
+		}
+		_ = s.signal.messenger.SendModeration(moderation)
+		span.AddEvent("Send Moderation to Client")
+	}
+	return muted
+}
+
+// Unmute allows the client to unmute the tracks of a kind a moderator muted, all tracks if the kind is unknown.
+// The tracks stay muted until the client unmutes them.
+func (s *Session) Unmute(ctx context.Context, kind webrtc.RTPCodecType) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	_, span := s.trace(ctx, "moderator_unmute")
+	defer span.End()
+	if s.ingress == nil {
+		return false
+	}
+
+	allowed := false
+	s.moderatedMu.Lock()
+	for _, mid := range s.ingress.GetIngressMids(kind) {
+		if s.moderated[mid] {
+			delete(s.moderated, mid)
+			allowed = true
+		}
+	}
+	s.moderatedMu.Unlock()
+	if allowed && s.signal.messenger != nil {
+		moderation := &message.Moderation{Action: message.ModerationUnmute, SessionId: s.Id.String()}
+		if kind != 0 {
+			moderation.Kind = kind.String()
+		}
+		_ = s.signal.messenger.SendModeration(moderation)
+		span.AddEvent("Send Moderation to Client")
+	}
+	return allowed
+}
+
+// Kick tells the client that a moderator removed it and closes the session.
+// The caller has to remove the session from the lobby.
+func (s *Session) Kick() {
+	if s.signal.messenger != nil {
+		_ = s.signal.messenger.SendModeration(&message.Moderation{Action: message.ModerationKick, SessionId: s.Id.String()})
+	}
+	s.Close()
+}
+
+// Close closes the endpoints of the session, the caller has to remove the session from the lobby
+func (s *Session) Close() {
+	s.stop()
+}
+
+// CloseEndpoint closes the ingress or egress endpoint of a deleted resource and keeps the other endpoint of the session.
+// It returns false, if the session has no endpoint left, then the caller has to remove the session from the lobby.
+func (s *Session) CloseEndpoint(endpointType rtp.EndpointType) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch endpointType {
+	case rtp.IngressEndpoint:
+		if s.closeIngress != nil {
+			s.closeIngress()
+		}
+		s.ingress = nil
+		s.moderatedMu.Lock()
+		s.moderated = make(map[string]bool)
+		s.moderatedMu.Unlock()
+	case rtp.EgressEndpoint:
+		if s.closeEgress != nil {
+			s.closeEgress()
+		}
+		s.egress = nil
+		s.forwardingMu.Lock()
+		s.forwarded = make(map[string]*rtp.TrackInfo)
+		s.forwardingMu.Unlock()
+	}
+	return s.ingress != nil || s.egress != nil
+}
+
+// GetResumeToken returns the token a client needs to reconnect to the session
+func (s *Session) GetResumeToken() string {
+	return s.resumeToken
+}
+
+func (s *Session) GetUserId() uuid.UUID {
+	return s.user
+}
+
+func (s *Session) GetRole() Role {
+	s.roleMu.RLock()
+	defer s.roleMu.RUnlock()
+	return s.role
+}
+
+// SetRole changes the role of the session and tells the client about its new role
+func (s *Session) SetRole(role Role) {
+	s.roleMu.Lock()
+	changed := s.role != role
+	s.role = role
+	s.roleMu.Unlock()
+	if changed {
+		go s.sendRole()
+	}
+}
+
+// HasRight returns whether the role of the session grants the right
+func (s *Session) HasRight(right Right) bool {
+	return s.GetRole().Has(right)
+}
+
 func (s *Session) isDone() bool {
 	select {
 	case <-s.ctx.Done():
@@ -404,10 +1147,41 @@ func (s *Session) initComplete() bool {
 	return false
 }
 
-func (s *Session) SetEgressAnswer(answer *webrtc.SessionDescription) chan struct{} {
-	return make(chan struct{})
+// SetEgressAnswer sets the answer of the remote peer to the offer of the egress endpoint,
+// afterward the egress endpoint renegotiates its tracks over the signal channel
+func (s *Session) SetEgressAnswer(answer *webrtc.SessionDescription) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.isDone() {
+		return ErrSessionAlreadyClosed
+	}
+	if s.egress == nil {
+		return ErrNoEgress
+	}
+	if err := s.egress.SetAnswer(answer); err != nil {
+		return fmt.Errorf("setting egress answer: %w", err)
+	}
+	s.egress.SetInitComplete()
+	return nil
+}
+
+// SetIngressAnswer sets the answer of the remote peer to the offer of the ingress endpoint
+func (s *Session) SetIngressAnswer(answer *webrtc.SessionDescription) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.isDone() {
+		return ErrSessionAlreadyClosed
+	}
+	if s.ingress == nil {
+		return ErrNoIngress
+	}
+	if err := s.ingress.SetAnswer(answer); err != nil {
+		return fmt.Errorf("setting ingress answer: %w", err)
+	}
+	return nil
 }
 
-func (s *Session) SetIngressAnswer(answer *webrtc.SessionDescription) chan struct{} {
-	return make(chan struct{})
+// Done is closed, when the session is closed
+func (s *Session) Done() <-chan struct{} {
+	return s.ctx.Done()
 }
@@ -0,0 +1,33 @@
+package sessions
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shigde/sfu/pkg/message"
+)
+
+type SessionOption func(s *Session)
+
+// SessionWithModerationHandler handles the moderation commands the client of the session sends over the signal channel.
+// The handler has to check whether the user is allowed to moderate.
+func SessionWithModerationHandler(handler func(user uuid.UUID, moderation *message.Moderation)) SessionOption {
+	return func(s *Session) {
+		s.onModerationHandler = handler
+	}
+}
+
+// SessionWithRole sets the role of the user in the lobby, sessions without a role are viewers
+func SessionWithRole(role Role) SessionOption {
+	return func(s *Session) {
+		s.role = role
+	}
+}
+
+// SessionWithGracePeriod keeps the session of a client that lost its connection, until the grace period is over.
+// Without grace period the session is closed right away.
+func SessionWithGracePeriod(gracePeriod time.Duration) SessionOption {
+	return func(s *Session) {
+		s.gracePeriod = gracePeriod
+	}
+}
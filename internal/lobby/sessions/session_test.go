@@ -4,15 +4,20 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/pion/webrtc/v3"
 	"github.com/shigde/sfu/internal/lobby/mocks"
 	"github.com/shigde/sfu/internal/logging"
+	"github.com/shigde/sfu/internal/rtp"
+	"github.com/shigde/sfu/pkg/message"
 	"github.com/stretchr/testify/assert"
 )
 
 var (
 	runtimeProcessWaitingTimeout = processWaitingTimeout
+	testTrickleOffer             = &webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: "v=0\r\na=ice-options:trickle\r\n"}
 )
 
 func testSessionSetup(t *testing.T) (*Session, *mocks.RtpEngineMock) {
@@ -21,7 +26,7 @@ func testSessionSetup(t *testing.T) (*Session, *mocks.RtpEngineMock) {
 	engine := mocks.NewRtpEngineForOffer(mocks.Answer)
 	forwarder := mocks.NewLiveSender()
 	ctx, _ := context.WithCancel(context.Background())
-	hub := NewHub(ctx, NewSessionRepository(), uuid.New(), forwarder)
+	hub := NewHub(ctx, NewSessionRepository(), uuid.New(), forwarder, nil)
 	session := NewSession(ctx, uuid.New(), hub, engine, UserSession, nil)
 	return session, engine
 }
@@ -75,6 +80,25 @@ func TestSession_CreateIngressEndpoint(t *testing.T) {
 		answer, _ := session.CreateIngressEndpoint(context.Background(), mocks.Offer, SilentSignalChannel)
 		assert.Equal(t, mocks.Answer, answer)
 	})
+
+	t.Run("wait for ice gathering, if the client did not opt in to trickle ice", func(t *testing.T) {
+		session, engine := testSessionSetup(t)
+		engine.Conn = rtp.NewMockConnection(rtp.MockConnectionOps{Answer: mocks.Answer, GatherComplete: make(chan struct{})})
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err := session.CreateIngressEndpoint(ctx, testTrickleOffer, SilentSignalChannel)
+		assert.ErrorContains(t, err, "create ingress answer resource")
+	})
+
+	t.Run("get answer before ice gathering, if the client opted in to trickle ice", func(t *testing.T) {
+		session, engine := testSessionSetup(t)
+		engine.Conn = rtp.NewMockConnection(rtp.MockConnectionOps{Answer: mocks.Answer, GatherComplete: make(chan struct{})})
+
+		answer, err := session.CreateIngressEndpoint(ContextWithTrickleIce(context.Background()), testTrickleOffer, SilentSignalChannel)
+		assert.NoError(t, err)
+		assert.Equal(t, mocks.Answer.SDP, answer.SDP)
+	})
 }
 
 func TestSession_CreateEgressEndpoint(t *testing.T) {
@@ -140,3 +164,107 @@ func TestSession_CreateEgressEndpoint(t *testing.T) {
 		assert.Equal(t, mocks.Answer, answer)
 	})
 }
+
+func TestSession_ResumeIngressEndpoint(t *testing.T) {
+	t.Run("with invalid resume token", func(t *testing.T) {
+		session, _ := testSessionSetup(t)
+		session.ingress = mocks.NewEndpoint(nil)
+
+		_, err := session.ResumeIngressEndpoint(context.Background(), mocks.Offer, SilentSignalChannel, "invalid")
+		assert.ErrorIs(t, err, ErrInvalidResumeToken)
+	})
+
+	t.Run("replace endpoint", func(t *testing.T) {
+		session, _ := testSessionSetup(t)
+		former := mocks.NewEndpoint(nil)
+		session.ingress = former
+
+		answer, err := session.ResumeIngressEndpoint(context.Background(), mocks.Offer, SilentSignalChannel, session.GetResumeToken())
+		assert.NoError(t, err)
+		assert.Equal(t, mocks.Answer, answer)
+		assert.NotSame(t, former, session.ingress)
+		// the session stays paused until the client is connected again
+		assert.NotNil(t, session.resumed)
+	})
+}
+
+func TestSession_GracePeriod(t *testing.T) {
+	t.Run("leave lobby without grace period", func(t *testing.T) {
+		session, _ := testSessionSetup(t)
+		garbage := make(chan Item)
+		session.garbage = garbage
+
+		session.onLostConnection()
+		item := <-garbage
+		item.Done <- true
+		assert.True(t, session.isDone())
+	})
+
+	t.Run("leave lobby after grace period", func(t *testing.T) {
+		session, _ := testSessionSetup(t)
+		session.gracePeriod = 100 * time.Millisecond
+		garbage := make(chan Item)
+		session.garbage = garbage
+
+		session.onLostConnection()
+		assert.False(t, session.isDone())
+		item := <-garbage
+		item.Done <- true
+		assert.True(t, session.isDone())
+	})
+
+	t.Run("stay in lobby after reconnect", func(t *testing.T) {
+		session, _ := testSessionSetup(t)
+		session.gracePeriod = 100 * time.Millisecond
+		garbage := make(chan Item)
+		session.garbage = garbage
+
+		session.onLostConnection()
+		session.resume()
+		select {
+		case <-garbage:
+			t.Fatal("session left the lobby after reconnect")
+		case <-time.After(300 * time.Millisecond):
+		}
+		assert.False(t, session.isDone())
+	})
+}
+
+func TestSession_ModeratedMute(t *testing.T) {
+	t.Run("reject unmute of a track a moderator muted", func(t *testing.T) {
+		session, _ := testSessionSetup(t)
+		session.moderated["0"] = true
+
+		// the session has no ingress, so an accepted unmute would fail here
+		session.onMuteTrack(&message.Mute{Mid: "0", Mute: false})
+		assert.True(t, session.isModerated("0"))
+	})
+
+	t.Run("unmute without ingress", func(t *testing.T) {
+		session, _ := testSessionSetup(t)
+		session.moderated["0"] = true
+
+		assert.False(t, session.Unmute(context.Background(), webrtc.RTPCodecTypeAudio))
+		assert.True(t, session.isModerated("0"))
+	})
+}
+
+func TestSession_CloseEndpoint(t *testing.T) {
+	t.Run("keep ingress when egress resource is deleted", func(t *testing.T) {
+		session, _ := testSessionSetup(t)
+		session.ingress = mocks.NewEndpoint(nil)
+		session.egress = mocks.NewEndpoint(nil)
+
+		assert.True(t, session.CloseEndpoint(rtp.EgressEndpoint))
+		assert.NotNil(t, session.ingress)
+		assert.Nil(t, session.egress)
+	})
+
+	t.Run("no endpoint left after last resource is deleted", func(t *testing.T) {
+		session, _ := testSessionSetup(t)
+		session.ingress = mocks.NewEndpoint(nil)
+
+		assert.False(t, session.CloseEndpoint(rtp.IngressEndpoint))
+		assert.Nil(t, session.ingress)
+	})
+}
@@ -26,7 +26,16 @@ type signal struct {
 	offerer           *rtp.Endpoint // The offerer is always an egress endpoint or nil
 	answerer          *rtp.Endpoint // The answerer is always an ingress endpoint or nil
 	onMuteCbk         func(_ *message.Mute)
+	onLastNCbk        func(_ *message.LastN)
+	onChatCbk         func(_ *message.Chat)
+	onReactionCbk     func(_ *message.Reaction)
+	onRaiseHandCbk    func(_ *message.RaiseHand)
+	onModerationCbk   func(_ *message.Moderation)
+	onRelayTracksCbk  func(_ *message.RelayTracks)
+	onSubscriptionCbk func(_ *message.RelaySubscription)
+	onMessengerCbk    func()
 	messenger         *clients.Messenger
+	messengerEndpoint rtp.EndpointType // the endpoint whose connection carries the messenger
 	offerNumber       atomic.Uint32
 	receivedMessenger chan struct{}
 }
@@ -41,12 +50,16 @@ func newSignal(sessionCtx context.Context, session uuid.UUID, user uuid.UUID) *s
 	}
 }
 
-func (s *signal) OnSenderChannel(channel *webrtc.DataChannel) {
-	slog.Debug("signal: get signal channel and create messenger", "sessionId", s.session, "userId", s.user)
+func (s *signal) OnSenderChannel(channel *webrtc.DataChannel, endpointType rtp.EndpointType) {
+	slog.Debug("signal: get signal channel and create messenger", "sessionId", s.session, "userId", s.user, "endpoint", endpointType.ToString())
+	s.messengerEndpoint = endpointType
 	s.messenger = clients.NewMessenger(channel)
 	// we register this signaler for datachannel messages after we received a webrtc channel
 	s.messenger.Register(s)
 	s.stopWaitingForMessenger()
+	if s.onMessengerCbk != nil {
+		s.onMessengerCbk()
+	}
 }
 
 func (s *signal) OnSilentChannel(_ *webrtc.DataChannel) {
@@ -77,6 +90,41 @@ func (s *signal) OnNegotiationNeeded(offer webrtc.SessionDescription) {
 	}
 }
 
+// OnConnectionStateChange restarts ICE, when the connection of the egress endpoint is disconnected, for example because
+// the client switched the network. The client answers the restart offer like every other offer of the egress endpoint.
+// The client restarts ICE of the ingress endpoint itself, because the ingress endpoint never offers.
+// If the messenger runs on the disconnected egress connection, the offer would never arrive. The client restarts ICE
+// with a PATCH request of the egress resource then.
+func (s *signal) OnConnectionStateChange(state webrtc.ICEConnectionState) {
+	if state != webrtc.ICEConnectionStateDisconnected {
+		return
+	}
+	go s.restartIce()
+}
+
+func (s *signal) restartIce() {
+	offerer := s.offerer
+	if offerer == nil || s.messenger == nil {
+		return
+	}
+	if s.messengerEndpoint == rtp.EgressEndpoint {
+		slog.Info("lobby.signal: messenger is disconnected, wait for the client to restart ice", "sessionId", s.session, "user", s.user)
+		return
+	}
+	ctx, cancel := context.WithTimeout(s.sessionCtx, waitingTimeOut)
+	defer cancel()
+	offer, err := offerer.CreateIceRestartOffer(ctx)
+	if err != nil {
+		slog.Error("lobby.signal: create ice restart offer", "err", err, "sessionId", s.session, "user", s.user)
+		return
+	}
+	number := s.nextOffer()
+	slog.Info("lobby.signal: restart ice", "number", number, "sessionId", s.session, "user", s.user)
+	if _, err := s.messenger.SendOffer(offer, number); err != nil {
+		slog.Error("lobby.signal: send ice restart offer", "err", err, "sessionId", s.session, "user", s.user)
+	}
+}
+
 func (s *signal) OnAnswer(sdp *webrtc.SessionDescription, number uint32) {
 	if s.offerer == nil {
 		slog.Warn("lobby.signal: no offerer exists to get this answer onAnswer", "number", number, "sessionId", s.session, "user", s.user)
@@ -105,9 +153,12 @@ func (s *signal) OnOffer(sdp *webrtc.SessionDescription, responseId uint32, numb
 		return
 	}
 
-	answer, err := s.answerer.SetNewOffer(sdp)
+	ctx, cancel := context.WithTimeout(s.sessionCtx, waitingTimeOut)
+	defer cancel()
+	answer, err := s.answerer.SetNewOffer(ctx, sdp)
 	if err != nil {
 		slog.Error("lobby.signal: on answer was trigger with error", "err", err, "sessionId", s.session, "userId", s.user)
+		return
 	}
 	if _, err := s.messenger.SendAnswer(answer, responseId, number); err != nil {
 		slog.Error("lobby.signal: on answer was trigger with error", "err", err, "sessionId", s.session, "userId", s.user)
@@ -120,6 +171,48 @@ func (s *signal) OnMute(mute *message.Mute) {
 	}
 }
 
+func (s *signal) OnLastN(lastN *message.LastN) {
+	if s.onLastNCbk != nil {
+		s.onLastNCbk(lastN)
+	}
+}
+
+func (s *signal) OnChat(chat *message.Chat) {
+	if s.onChatCbk != nil {
+		s.onChatCbk(chat)
+	}
+}
+
+func (s *signal) OnReaction(reaction *message.Reaction) {
+	if s.onReactionCbk != nil {
+		s.onReactionCbk(reaction)
+	}
+}
+
+func (s *signal) OnRaiseHand(raiseHand *message.RaiseHand) {
+	if s.onRaiseHandCbk != nil {
+		s.onRaiseHandCbk(raiseHand)
+	}
+}
+
+func (s *signal) OnModeration(moderation *message.Moderation) {
+	if s.onModerationCbk != nil {
+		s.onModerationCbk(moderation)
+	}
+}
+
+func (s *signal) OnRelayTracks(relayTracks *message.RelayTracks) {
+	if s.onRelayTracksCbk != nil {
+		s.onRelayTracksCbk(relayTracks)
+	}
+}
+
+func (s *signal) OnRelaySubscription(subscription *message.RelaySubscription) {
+	if s.onSubscriptionCbk != nil {
+		s.onSubscriptionCbk(subscription)
+	}
+}
+
 func (s *signal) nextOffer() uint32 {
 	return s.offerNumber.Add(1)
 }
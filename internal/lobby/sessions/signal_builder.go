@@ -2,6 +2,7 @@ package sessions
 
 import (
 	"github.com/pion/webrtc/v3"
+	"github.com/shigde/sfu/internal/rtp"
 )
 
 /**+++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
@@ -65,9 +66,11 @@ const (
 	BidirectionalSignalChannel
 )
 
-func buildSignalDataChannelCbk(s *Session, kind SignalChannelKind) func(*webrtc.DataChannel) {
+func buildSignalDataChannelCbk(s *Session, kind SignalChannelKind, endpointType rtp.EndpointType) func(*webrtc.DataChannel) {
 	if kind == SilentSignalChannel {
 		return s.signal.OnSilentChannel
 	}
-	return s.signal.OnSenderChannel
+	return func(channel *webrtc.DataChannel) {
+		s.signal.OnSenderChannel(channel, endpointType)
+	}
 }
@@ -0,0 +1,124 @@
+package sessions
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// interval the hub checks whether the dominant speaker changed
+	speakerInterval = 300 * time.Millisecond
+	// audio levels are -dBov, 0 is the loudest and 127 silence
+	silentAudioLevel = 127
+	// sessions quieter than -50 dBov are not speaking
+	minSpeakerLoudness = silentAudioLevel - 50
+	// a speaker has to be louder than the dominant speaker by this margin to replace them
+	speakerSwitchMargin = 6
+	// sessions without audio levels in this window are not speaking
+	speakerActivityWindow = 1 * time.Second
+	// smoothing of the loudness over the audio packets
+	speakerSmoothing = 0.1
+)
+
+type speakerActivity struct {
+	loudness float64
+	updated  time.Time
+	// the last time the session was loud enough to speak
+	spoke time.Time
+}
+
+// speakerRanking ranks the sessions of a lobby by the audio levels of their audio tracks.
+// The levels are updated by the ingress media writers, that's why the ranking has an own lock and is not part of the hub loop.
+type speakerRanking struct {
+	mu       sync.Mutex
+	speakers map[uuid.UUID]*speakerActivity
+	dominant uuid.UUID
+}
+
+func newSpeakerRanking() *speakerRanking {
+	return &speakerRanking{speakers: make(map[uuid.UUID]*speakerActivity)}
+}
+
+func (r *speakerRanking) update(sessionId uuid.UUID, level uint8, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	loudness := float64(silentAudioLevel - min(level, silentAudioLevel))
+	activity, found := r.speakers[sessionId]
+	if !found {
+		activity = &speakerActivity{loudness: loudness}
+		r.speakers[sessionId] = activity
+	} else {
+		activity.loudness += (loudness - activity.loudness) * speakerSmoothing
+	}
+	activity.updated = now
+	if activity.loudness >= minSpeakerLoudness {
+		activity.spoke = now
+	}
+}
+
+func (r *speakerRanking) remove(sessionId uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.speakers, sessionId)
+	if r.dominant == sessionId {
+		r.dominant = uuid.Nil
+	}
+}
+
+// ranking returns the speaking sessions, the loudest first
+func (r *speakerRanking) ranking(now time.Time) []uuid.UUID {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.speaking(now)
+}
+
+// lastSpoken returns the last time each session spoke, sessions that never spoke are missing
+func (r *speakerRanking) lastSpoken() map[uuid.UUID]time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	spoken := make(map[uuid.UUID]time.Time, len(r.speakers))
+	for id, activity := range r.speakers {
+		if !activity.spoke.IsZero() {
+			spoken[id] = activity.spoke
+		}
+	}
+	return spoken
+}
+
+// updateDominant returns the dominant speaker and whether it changed since the last call
+func (r *speakerRanking) updateDominant(now time.Time) (uuid.UUID, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	speaking := r.speaking(now)
+	// the dominant speaker stays until somebody else speaks
+	if len(speaking) == 0 || speaking[0] == r.dominant {
+		return r.dominant, false
+	}
+	if current, found := r.speakers[r.dominant]; found && r.isSpeaking(current, now) {
+		if r.speakers[speaking[0]].loudness < current.loudness+speakerSwitchMargin {
+			return r.dominant, false
+		}
+	}
+	r.dominant = speaking[0]
+	return r.dominant, true
+}
+
+func (r *speakerRanking) speaking(now time.Time) []uuid.UUID {
+	speaking := make([]uuid.UUID, 0, len(r.speakers))
+	for id, activity := range r.speakers {
+		if r.isSpeaking(activity, now) {
+			speaking = append(speaking, id)
+		}
+	}
+	sort.Slice(speaking, func(i, j int) bool {
+		return r.speakers[speaking[i]].loudness > r.speakers[speaking[j]].loudness
+	})
+	return speaking
+}
+
+func (r *speakerRanking) isSpeaking(activity *speakerActivity, now time.Time) bool {
+	return activity.loudness >= minSpeakerLoudness && now.Sub(activity.updated) <= speakerActivityWindow
+}
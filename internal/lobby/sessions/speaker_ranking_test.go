@@ -0,0 +1,89 @@
+package sessions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func testSpeak(ranking *speakerRanking, sessionId uuid.UUID, level uint8, now time.Time) {
+	for i := 0; i < 50; i++ {
+		ranking.update(sessionId, level, now)
+	}
+}
+
+func TestSpeakerRanking(t *testing.T) {
+	t.Run("rank speaking sessions by loudness", func(t *testing.T) {
+		ranking := newSpeakerRanking()
+		now := time.Now()
+		quiet, loud, silent := uuid.New(), uuid.New(), uuid.New()
+		testSpeak(ranking, quiet, 40, now)
+		testSpeak(ranking, loud, 20, now)
+		testSpeak(ranking, silent, 127, now)
+
+		assert.Equal(t, []uuid.UUID{loud, quiet}, ranking.ranking(now))
+	})
+
+	t.Run("change dominant speaker", func(t *testing.T) {
+		ranking := newSpeakerRanking()
+		now := time.Now()
+		first, second := uuid.New(), uuid.New()
+		testSpeak(ranking, first, 30, now)
+
+		speaker, changed := ranking.updateDominant(now)
+		assert.True(t, changed)
+		assert.Equal(t, first, speaker)
+
+		// a bit louder is not enough to replace the dominant speaker
+		testSpeak(ranking, second, 28, now)
+		speaker, changed = ranking.updateDominant(now)
+		assert.False(t, changed)
+		assert.Equal(t, first, speaker)
+
+		testSpeak(ranking, second, 10, now)
+		speaker, changed = ranking.updateDominant(now)
+		assert.True(t, changed)
+		assert.Equal(t, second, speaker)
+	})
+
+	t.Run("keep dominant speaker when nobody speaks", func(t *testing.T) {
+		ranking := newSpeakerRanking()
+		now := time.Now()
+		first := uuid.New()
+		testSpeak(ranking, first, 30, now)
+		_, _ = ranking.updateDominant(now)
+
+		speaker, changed := ranking.updateDominant(now.Add(2 * speakerActivityWindow))
+		assert.False(t, changed)
+		assert.Equal(t, first, speaker)
+	})
+
+	t.Run("replace removed dominant speaker", func(t *testing.T) {
+		ranking := newSpeakerRanking()
+		now := time.Now()
+		first, second := uuid.New(), uuid.New()
+		testSpeak(ranking, first, 10, now)
+		testSpeak(ranking, second, 30, now)
+		_, _ = ranking.updateDominant(now)
+
+		ranking.remove(first)
+		speaker, changed := ranking.updateDominant(now)
+		assert.True(t, changed)
+		assert.Equal(t, second, speaker)
+	})
+
+	t.Run("remember when sessions spoke", func(t *testing.T) {
+		ranking := newSpeakerRanking()
+		now := time.Now()
+		speaker, silent := uuid.New(), uuid.New()
+		testSpeak(ranking, speaker, 30, now)
+		testSpeak(ranking, silent, 127, now)
+		testSpeak(ranking, speaker, 127, now.Add(time.Minute))
+
+		spoken := ranking.lastSpoken()
+		assert.Len(t, spoken, 1)
+		assert.True(t, spoken[speaker].After(now))
+	})
+}
@@ -0,0 +1,67 @@
+package media
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/shigde/sfu/internal/stream"
+	"golang.org/x/exp/slog"
+)
+
+// proxies close idle connections, a comment line keeps the event stream open
+const eventKeepAliveInterval = 15 * time.Second
+
+var errStreamingNotSupported = errors.New("response writer does not support streaming")
+
+// getLobbyEvents pushes the lifecycle events of the lobby as server-sent events, until the client closes the connection
+func getLobbyEvents(streamService *stream.LiveStreamService, liveService *stream.LiveLobbyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		liveStream, userId, err := readingRequestData(w, r, streamService)
+		if err != nil {
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			httpError(w, "error streaming events", http.StatusInternalServerError, errStreamingNotSupported)
+			return
+		}
+
+		events := liveService.SubscribeEvents(r.Context(), liveStream, userId)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(eventKeepAliveInterval)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				data, err := json.Marshal(event)
+				if err != nil {
+					slog.Error("media.getLobbyEvents: marshaling event", "err", err, "type", event.Type)
+					continue
+				}
+				if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-keepAlive.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+}
@@ -1,15 +1,56 @@
 package media
 
 import (
+	"errors"
 	"net/http"
 
+	"github.com/shigde/sfu/internal/auth"
 	"github.com/shigde/sfu/internal/stream"
+	"github.com/shigde/sfu/internal/telemetry"
 	"go.opentelemetry.io/otel"
 )
 
+// fedResource closes the connections of another Shig instance to the lobby
 func fedResource(streamService *stream.LiveStreamService, liveService *stream.LiveLobbyService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		_, span := otel.Tracer(tracerName).Start(r.Context(), "api: fed_whep_create")
+		ctx, span := otel.Tracer(tracerName).Start(r.Context(), "api: fed_resource_delete")
 		defer span.End()
+
+		user, ok := auth.PrincipalFromContext(r.Context())
+		if !ok {
+			_ = telemetry.RecordError(span, errors.New("no user"))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		instanceId, err := user.GetUuid()
+		if err != nil {
+			_ = telemetry.RecordError(span, err)
+			httpError(w, "error user", http.StatusBadRequest, err)
+			return
+		}
+
+		liveStream, _, err := getLiveStream(r, streamService)
+		if err != nil {
+			_ = telemetry.RecordError(span, err)
+			handleResourceError(w, err)
+			return
+		}
+
+		left, err := liveService.LeaveLobby(ctx, liveStream, instanceId)
+		if err != nil {
+			_ = telemetry.RecordError(span, err)
+			if errors.Is(err, stream.ErrLobbyNotActive) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			httpError(w, "error", http.StatusInternalServerError, err)
+			return
+		}
+		if !left {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
 	}
 }
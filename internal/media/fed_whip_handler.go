@@ -1,15 +1,86 @@
 package media
 
 import (
+	"crypto/md5"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
+	"github.com/pion/webrtc/v3"
+	"github.com/shigde/sfu/internal/auth"
+	"github.com/shigde/sfu/internal/lobby"
 	"github.com/shigde/sfu/internal/stream"
+	"github.com/shigde/sfu/internal/telemetry"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// fedWhip receives the offer of the egress endpoint of another Shig instance, it relays the tracks of its lobby to this lobby
 func fedWhip(streamService *stream.LiveStreamService, liveService *stream.LiveLobbyService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		_, span := otel.Tracer(tracerName).Start(r.Context(), "api: fed_whep_create")
+		ctx, span := otel.Tracer(tracerName).Start(r.Context(), "api: fed_whip_create")
 		defer span.End()
+
+		w.Header().Set("Content-Type", "application/sdp")
+
+		liveStream, _, err := getLiveStream(r, streamService)
+		if err != nil {
+			_ = telemetry.RecordError(span, err)
+			handleResourceError(w, err)
+			return
+		}
+
+		offer, err := getSdpPayload(w, r, webrtc.SDPTypeOffer)
+		if err != nil {
+			_ = telemetry.RecordError(span, err)
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(err.Error()))
+			return
+		}
+
+		user, ok := auth.PrincipalFromContext(r.Context())
+		if !ok {
+			_ = telemetry.RecordError(span, errors.New("no user"))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		instanceId, err := user.GetUuid()
+		if err != nil {
+			_ = telemetry.RecordError(span, err)
+			httpError(w, "error user", http.StatusBadRequest, err)
+			return
+		}
+		// track request meta by otel
+		span.SetAttributes(
+			attribute.String("streamId", liveStream.UUID.String()),
+			attribute.String("instanceId", instanceId.String()),
+		)
+
+		resource, err := liveService.CreateLobbyInstanceIngressEndpoint(ctx, offer, liveStream, instanceId)
+		if err != nil {
+			_ = telemetry.RecordError(span, err)
+			switch {
+			case errors.Is(err, stream.ErrNoInstance) || errors.Is(err, lobby.ErrUserBanned):
+				httpError(w, "forbidden", http.StatusForbidden, err)
+			case errors.Is(err, lobby.ErrSessionAlreadyExists):
+				httpError(w, "session already exists", http.StatusConflict, err)
+			default:
+				httpError(w, "error build fed whip", http.StatusInternalServerError, err)
+			}
+			return
+		}
+
+		response := []byte(resource.SDP.SDP)
+		hash := md5.Sum(response)
+
+		w.Header().Set("etag", fmt.Sprintf("%x", hash))
+		w.Header().Set("Location", "res")
+		w.Header().Set("Content-Length", strconv.Itoa(len(response)))
+		w.WriteHeader(http.StatusCreated)
+		if _, err = w.Write(response); err != nil {
+			_ = telemetry.RecordError(span, err)
+		}
 	}
 }
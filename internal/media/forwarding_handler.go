@@ -0,0 +1,38 @@
+package media
+
+import (
+	"net/http"
+
+	"github.com/shigde/sfu/internal/stream"
+)
+
+type lastNPayload struct {
+	LastN int `json:"lastN"`
+}
+
+func setLastN(streamService *stream.LiveStreamService, liveService *stream.LiveLobbyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		liveStream, userId, err := readingRequestData(w, r, streamService)
+		if err != nil {
+			return
+		}
+
+		dec, err := getJsonPayload(w, r)
+		if err != nil {
+			httpError(w, "invalid payload", http.StatusBadRequest, err)
+			return
+		}
+		payload := &lastNPayload{}
+		if err := dec.Decode(payload); err != nil || payload.LastN < 0 {
+			httpError(w, "invalid payload", http.StatusBadRequest, invalidPayload)
+			return
+		}
+
+		if err := liveService.SetLastN(r.Context(), liveStream, payload.LastN, userId); err != nil {
+			httpError(w, "error set last-n", http.StatusBadRequest, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
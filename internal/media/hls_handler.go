@@ -0,0 +1,86 @@
+package media
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/shigde/sfu/internal/hls"
+	"github.com/shigde/sfu/internal/stream"
+)
+
+var errInvalidHlsQuery = errors.New("invalid hls query")
+
+// getHlsFile serves the playlist and segments of a live stream, it needs no token because HLS players and CDNs cannot send one.
+func getHlsFile(hlsConfig *hls.HlsConfig, streamService *stream.LiveStreamService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		liveStream, _, err := getLiveStream(r, streamService)
+		if err != nil {
+			handleResourceError(w, err)
+			return
+		}
+
+		name := mux.Vars(r)["file"]
+		path, err := hls.FilePath(hlsConfig, liveStream.UUID, name)
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", hls.ContentType(name))
+
+		if !hls.IsPlaylist(name) {
+			if err := hls.WaitForFile(r.Context(), hlsConfig, path); err != nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			http.ServeFile(w, r, path)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "no-cache")
+		msn, part, blocking, err := getHlsBlockingQuery(r)
+		if err != nil {
+			httpError(w, "invalid blocking playlist request", http.StatusBadRequest, err)
+			return
+		}
+		if !blocking {
+			http.ServeFile(w, r, path)
+			return
+		}
+
+		playlist, err := hls.WaitForPlaylist(r.Context(), hlsConfig, liveStream.UUID, msn, part)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			httpError(w, "error reading playlist", http.StatusInternalServerError, err)
+			return
+		}
+		_, _ = w.Write(playlist)
+	}
+}
+
+// getHlsBlockingQuery reads the delivery directives _HLS_msn and _HLS_part of a blocking playlist reload
+func getHlsBlockingQuery(r *http.Request) (uint64, int, bool, error) {
+	query := r.URL.Query()
+	if !query.Has("_HLS_msn") {
+		if query.Has("_HLS_part") {
+			return 0, 0, false, errInvalidHlsQuery
+		}
+		return 0, 0, false, nil
+	}
+	msn, err := strconv.ParseUint(query.Get("_HLS_msn"), 10, 64)
+	if err != nil {
+		return 0, 0, false, errors.Join(errInvalidHlsQuery, err)
+	}
+	part := -1
+	if query.Has("_HLS_part") {
+		if part, err = strconv.Atoi(query.Get("_HLS_part")); err != nil || part < 0 {
+			return 0, 0, false, errors.Join(errInvalidHlsQuery, err)
+		}
+	}
+	return msn, part, true, nil
+}
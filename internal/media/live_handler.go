@@ -2,12 +2,15 @@ package media
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/google/uuid"
 	"github.com/shigde/sfu/internal/stream"
 )
 
+var errNotStreamOwner = errors.New("user is not the owner of the stream")
+
 func publishLiveStream(streamService *stream.LiveStreamService, liveService *stream.LiveLobbyService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -97,8 +100,8 @@ func readingRequestData(w http.ResponseWriter, r *http.Request, streamService *s
 	}
 
 	if liveStream.Account.UUID != user.UUID {
-		httpError(w, "forbidden", http.StatusForbidden, err)
-		return nil, uuid.Nil, err
+		httpError(w, "forbidden", http.StatusForbidden, errNotStreamOwner)
+		return nil, uuid.Nil, errNotStreamOwner
 	}
 
 	userId, err := user.GetUuid()
@@ -1,22 +1,41 @@
 package media
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/shigde/sfu/internal/lobby/sessions"
 	"github.com/shigde/sfu/internal/stream"
 	"golang.org/x/exp/slog"
 )
 
+// resumeTokenHeader carries the token of a session, a client that lost its connection reconnects to its session with the token
+const resumeTokenHeader = "Resume-Token"
+
+// trickleIceHeader opts in to trickle ICE, the client receives the answer before the ICE gathering is complete
+// and has to send a PATCH request to receive the missing candidates of the server
+const trickleIceHeader = "Trickle-Ice"
+
 var (
 	errSpaceRequestIdNotFound  = errors.New("reading space id from request")
 	errSpaceNotFound           = errors.New("reading space from manager")
 	errStreamRequestIdNotFound = errors.New("reading stream id from request")
 	errStreamNotFound          = errors.New("reading stream from manager")
+	errResourceIdNotFound      = errors.New("reading resource id from request")
 )
 
+// trickleIceContext marks the context of a request, if the client opted in to trickle ICE
+func trickleIceContext(ctx context.Context, r *http.Request) context.Context {
+	if r.Header.Get(trickleIceHeader) == "true" {
+		return sessions.ContextWithTrickleIce(ctx)
+	}
+	return ctx
+}
+
 func getLiveStream(r *http.Request, streamService *stream.LiveStreamService) (*stream.LiveStream, string, error) {
 	spaceIdentifier, err := getSpaceIdentifier(r)
 	if err != nil {
@@ -48,6 +67,18 @@ func getSpaceIdentifier(r *http.Request) (string, error) {
 	return spaceId, nil
 }
 
+func getResourceId(r *http.Request) (uuid.UUID, error) {
+	resource, ok := mux.Vars(r)["resource"]
+	if !ok {
+		return uuid.Nil, errResourceIdNotFound
+	}
+	resourceId, err := uuid.Parse(resource)
+	if err != nil {
+		return uuid.Nil, errors.Join(err, errResourceIdNotFound)
+	}
+	return resourceId, nil
+}
+
 func getSpace(r *http.Request, manager spaceGetCreator) (*stream.Space, error) {
 	spaceId, err := getSpaceIdentifier(r)
 	if err != nil {
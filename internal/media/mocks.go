@@ -6,7 +6,9 @@ import (
 	"github.com/google/uuid"
 	"github.com/pion/webrtc/v3"
 	"github.com/shigde/sfu/internal/auth"
+	"github.com/shigde/sfu/internal/lobby"
 	"github.com/shigde/sfu/internal/lobby/resources"
+	"github.com/shigde/sfu/internal/lobby/sessions"
 	"github.com/shigde/sfu/internal/rtp"
 )
 
@@ -103,6 +105,14 @@ func (l *testLobbyManager) CreateMainStreamLobbyEgressEndpoint(_ context.Context
 	return data, nil
 }
 
+func (l *testLobbyManager) TrickleIce(ctx context.Context, liveStreamId uuid.UUID, userId uuid.UUID, fragment *rtp.SdpFragment, iceSession ...resources.IceSession) (*rtp.SdpFragment, error) {
+	return rtp.ParseSdpFragment(testAnswer)
+}
+
+func (l *testLobbyManager) DeleteResource(ctx context.Context, liveStreamId uuid.UUID, userId uuid.UUID, resource uuid.UUID, endpointType rtp.EndpointType) (bool, error) {
+	return true, nil
+}
+
 func (l *testLobbyManager) LeaveLobby(ctx context.Context, liveStreamId uuid.UUID, userId uuid.UUID) (bool, error) {
 	return true, nil
 }
@@ -125,6 +135,51 @@ func (m *testLobbyManager) StopLiveStream(
 	return nil
 }
 
+func (m *testLobbyManager) StartRecording(ctx context.Context, liveStreamId uuid.UUID, userId uuid.UUID) error {
+	return nil
+}
+
+func (m *testLobbyManager) StopRecording(ctx context.Context, liveStreamId uuid.UUID, userId uuid.UUID) error {
+	return nil
+}
+
+func (m *testLobbyManager) SetLastN(ctx context.Context, liveStreamId uuid.UUID, lastN int, userId uuid.UUID) error {
+	return nil
+}
+
+func (m *testLobbyManager) SubscribeEvents(ctx context.Context, liveStreamId uuid.UUID, userId uuid.UUID) <-chan lobby.LobbyEvent {
+	events := make(chan lobby.LobbyEvent)
+	go func() {
+		<-ctx.Done()
+		close(events)
+	}()
+	return events
+}
+
+func (m *testLobbyManager) MuteUser(ctx context.Context, liveStreamId uuid.UUID, targetUserId uuid.UUID, kind string) error {
+	return nil
+}
+
+func (m *testLobbyManager) KickUser(ctx context.Context, liveStreamId uuid.UUID, targetUserId uuid.UUID) error {
+	return nil
+}
+
+func (m *testLobbyManager) BanUser(ctx context.Context, liveStreamId uuid.UUID, targetUserId uuid.UUID) error {
+	return nil
+}
+
+func (m *testLobbyManager) UnbanUser(ctx context.Context, liveStreamId uuid.UUID, targetUserId uuid.UUID) error {
+	return nil
+}
+
+func (m *testLobbyManager) GetBannedUsers(ctx context.Context, liveStreamId uuid.UUID) ([]uuid.UUID, error) {
+	return []uuid.UUID{}, nil
+}
+
+func (m *testLobbyManager) SetRole(ctx context.Context, liveStreamId uuid.UUID, targetUserId uuid.UUID, role sessions.Role) error {
+	return nil
+}
+
 func (m *testLobbyManager) CreateLobbyHostPipe(ctx context.Context, u uuid.UUID, offer *webrtc.SessionDescription, instanceId uuid.UUID) (struct {
 	Answer       *webrtc.SessionDescription
 	Resource     uuid.UUID
@@ -6,7 +6,10 @@ import (
 	"github.com/google/uuid"
 	"github.com/pion/webrtc/v3"
 
+	"github.com/shigde/sfu/internal/lobby"
 	"github.com/shigde/sfu/internal/lobby/resources"
+	"github.com/shigde/sfu/internal/lobby/sessions"
+	"github.com/shigde/sfu/internal/rtp"
 )
 
 type LobbyManagerMock struct {
@@ -16,17 +19,24 @@ func NewLobbyManager() *LobbyManagerMock {
 	return &LobbyManagerMock{}
 }
 
-func (l *LobbyManagerMock) NewIngressResource(_ context.Context, _ uuid.UUID, _ uuid.UUID, _ *webrtc.SessionDescription, _ ...resources.Option) (*resources.WebRTC, error) {
-	return &resources.WebRTC{
-		Id:  ResourceID,
-		SDP: &webrtc.SessionDescription{Type: webrtc.SDPTypeAnswer, SDP: Answer},
-	}, nil
+func (l *LobbyManagerMock) NewIngressResource(_ context.Context, _ uuid.UUID, _ uuid.UUID, _ *webrtc.SessionDescription, option ...resources.Option) (*resources.WebRTC, error) {
+	return newResource(option...)
 }
 
-func (l *LobbyManagerMock) NewEgressResource(_ context.Context, _ uuid.UUID, _ uuid.UUID, _ *webrtc.SessionDescription, _ ...resources.Option) (*resources.WebRTC, error) {
+func (l *LobbyManagerMock) NewEgressResource(_ context.Context, _ uuid.UUID, _ uuid.UUID, _ *webrtc.SessionDescription, option ...resources.Option) (*resources.WebRTC, error) {
+	return newResource(option...)
+}
+
+func newResource(option ...resources.Option) (*resources.WebRTC, error) {
+	for _, opt := range option {
+		if opt.ResumeToken != "" && opt.ResumeToken != ResumeToken {
+			return nil, sessions.ErrInvalidResumeToken
+		}
+	}
 	return &resources.WebRTC{
-		Id:  ResourceID,
-		SDP: &webrtc.SessionDescription{Type: webrtc.SDPTypeAnswer, SDP: Answer},
+		Id:          ResourceID,
+		SDP:         &webrtc.SessionDescription{Type: webrtc.SDPTypeAnswer, SDP: Answer},
+		ResumeToken: ResumeToken,
 	}, nil
 }
 
@@ -93,6 +103,14 @@ func (l *LobbyManagerMock) CreateMainStreamLobbyEgressEndpoint(_ context.Context
 	return data, nil
 }
 
+func (l *LobbyManagerMock) TrickleIce(ctx context.Context, liveStreamId uuid.UUID, userId uuid.UUID, fragment *rtp.SdpFragment, iceSession ...resources.IceSession) (*rtp.SdpFragment, error) {
+	return rtp.ParseSdpFragment(Answer)
+}
+
+func (l *LobbyManagerMock) DeleteResource(ctx context.Context, liveStreamId uuid.UUID, userId uuid.UUID, resource uuid.UUID, endpointType rtp.EndpointType) (bool, error) {
+	return true, nil
+}
+
 func (l *LobbyManagerMock) LeaveLobby(ctx context.Context, liveStreamId uuid.UUID, userId uuid.UUID) (bool, error) {
 	return true, nil
 }
@@ -115,6 +133,51 @@ func (m *LobbyManagerMock) StopLiveStream(
 	return nil
 }
 
+func (m *LobbyManagerMock) StartRecording(ctx context.Context, liveStreamId uuid.UUID, userId uuid.UUID) error {
+	return nil
+}
+
+func (m *LobbyManagerMock) StopRecording(ctx context.Context, liveStreamId uuid.UUID, userId uuid.UUID) error {
+	return nil
+}
+
+func (m *LobbyManagerMock) SetLastN(ctx context.Context, liveStreamId uuid.UUID, lastN int, userId uuid.UUID) error {
+	return nil
+}
+
+func (m *LobbyManagerMock) SubscribeEvents(ctx context.Context, liveStreamId uuid.UUID, userId uuid.UUID) <-chan lobby.LobbyEvent {
+	events := make(chan lobby.LobbyEvent)
+	go func() {
+		<-ctx.Done()
+		close(events)
+	}()
+	return events
+}
+
+func (m *LobbyManagerMock) MuteUser(ctx context.Context, liveStreamId uuid.UUID, targetUserId uuid.UUID, kind string) error {
+	return nil
+}
+
+func (m *LobbyManagerMock) KickUser(ctx context.Context, liveStreamId uuid.UUID, targetUserId uuid.UUID) error {
+	return nil
+}
+
+func (m *LobbyManagerMock) BanUser(ctx context.Context, liveStreamId uuid.UUID, targetUserId uuid.UUID) error {
+	return nil
+}
+
+func (m *LobbyManagerMock) UnbanUser(ctx context.Context, liveStreamId uuid.UUID, targetUserId uuid.UUID) error {
+	return nil
+}
+
+func (m *LobbyManagerMock) GetBannedUsers(ctx context.Context, liveStreamId uuid.UUID) ([]uuid.UUID, error) {
+	return []uuid.UUID{}, nil
+}
+
+func (m *LobbyManagerMock) SetRole(ctx context.Context, liveStreamId uuid.UUID, targetUserId uuid.UUID, role sessions.Role) error {
+	return nil
+}
+
 func (m *LobbyManagerMock) CreateLobbyHostPipe(ctx context.Context, u uuid.UUID, offer *webrtc.SessionDescription, instanceId uuid.UUID) (struct {
 	Answer       *webrtc.SessionDescription
 	Resource     uuid.UUID
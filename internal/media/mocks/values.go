@@ -2,6 +2,7 @@ package mocks
 
 import (
 	"github.com/shigde/sfu/internal/auth"
+	"github.com/shigde/sfu/internal/hls"
 	"github.com/shigde/sfu/internal/rtp"
 )
 
@@ -10,6 +11,7 @@ const (
 	SpaceId             = "abc123"
 	ResourceID          = "152cca71-7156-455b-8b30-a90a4bf8f772"
 	RtpSessionId        = "dac0039e-947a-4d22-8207-4e81a5cdcf19"
+	ResumeToken         = "0b0b6a62-3c5e-4d59-9a41-2a4a4c1b8f10"
 	ReqTokenHeaderName  = "X-Req-Token"
 	CsrfTokenHeaderName = "X-Csrf-Token"
 	Offer               = "v=0\no=- 5228595038118931041 2 IN IP4 127.0.0.1\ns=-\nt=0 0\na=group:BUNDLE 0 1\na=extmap-allow-mixed\na=msid-semantic: WMS\nm=audio 9 UDP/TLS/RTP/SAVPF 111\nc=IN IP4 0.0.0.0\na=rtcp:9 IN IP4 0.0.0.0\na=ice-ufrag:EsAw\na=ice-pwd:bP+XJMM09aR8AiX1jdukzR6Y\na=ice-options:trickle\na=fingerprint:sha-256 DA:7B:57:DC:28:CE:04:4F:31:79:85:C4:31:67:EB:27:58:29:ED:77:2A:0D:24:AE:ED:AD:30:BC:BD:F1:9C:02\na=setup:actpass\na=mid:0\na=bundle-only\na=extmap:4 urn:ietf:params:rtp-hdrext:sdes:mid\na=sendonly\na=msid:- d46fb922-d52a-4e9c-aa87-444eadc1521b\na=rtcp-mux\na=rtpmap:111 opus/48000/2\na=fmtp:111 minptime=10;useinbandfec=1\nm=video 9 UDP/TLS/RTP/SAVPF 96 97\nc=IN IP4 0.0.0.0\na=rtcp:9 IN IP4 0.0.0.0\na=ice-ufrag:EsAw\na=ice-pwd:bP+XJMM09aR8AiX1jdukzR6Y\na=ice-options:trickle\na=fingerprint:sha-256 DA:7B:57:DC:28:CE:04:4F:31:79:85:C4:31:67:EB:27:58:29:ED:77:2A:0D:24:AE:ED:AD:30:BC:BD:F1:9C:02\na=setup:actpass\na=mid:1\na=bundle-only\na=extmap:4 urn:ietf:params:rtp-hdrext:sdes:mid\na=extmap:10 urn:ietf:params:rtp-hdrext:sdes:rtp-stream-id\na=extmap:11 urn:ietf:params:rtp-hdrext:sdes:repaired-rtp-stream-id\na=sendonly\na=msid:- d46fb922-d52a-4e9c-aa87-444eadc1521b\na=rtcp-mux\na=rtcp-rsize\na=rtpmap:96 VP8/90000\na=rtcp-fb:96 ccm fir\na=rtcp-fb:96 nack\na=rtcp-fb:96 nack pli\na=rtpmap:97 rtx/90000\na=fmtp:97 apt=96"
@@ -19,6 +21,11 @@ const (
 
 var (
 	SecurityConfig = &auth.SecurityConfig{JWT: JWT, TrustedOrigins: []string{"*"}}
-	RtpConfig      = &rtp.RtpConfig{ICEServer: []rtp.ICEServer{{Urls: []string{"stun:stun.l.google.com:19302"}}}}
+	HlsConfig      = &hls.HlsConfig{Enable: false}
 	JWT            = &auth.JwtToken{Enabled: true, Key: "SecretValueReplaceThis", DefaultExpireTime: 604800}
+	// the first ice server hands out short-lived credentials like the embedded turn server
+	RtpConfig = &rtp.RtpConfig{ICEServer: []rtp.ICEServer{
+		{Urls: []string{"stun:192.0.2.1:3478", "turn:192.0.2.1:3478?transport=udp"}, Realm: "shig", Secret: "SecretValueReplaceThis", CredentialTtl: 3600},
+		{Urls: []string{"stun:stun.l.google.com:19302"}},
+	}}
 )
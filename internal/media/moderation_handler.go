@@ -0,0 +1,182 @@
+package media
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/shigde/sfu/internal/lobby"
+	"github.com/shigde/sfu/internal/lobby/sessions"
+	"github.com/shigde/sfu/internal/stream"
+)
+
+type moderationPayload struct {
+	User string `json:"user"`
+	Kind string `json:"kind,omitempty"`
+	Role string `json:"role,omitempty"`
+}
+
+func muteUser(streamService *stream.LiveStreamService, liveService *stream.LiveLobbyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		liveStream, _, err := readingRequestData(w, r, streamService)
+		if err != nil {
+			return
+		}
+		payload, targetUserId, err := getModerationPayload(w, r)
+		if err != nil {
+			return
+		}
+		if payload.Kind != "" && payload.Kind != "audio" && payload.Kind != "video" {
+			httpError(w, "invalid payload", http.StatusBadRequest, invalidPayload)
+			return
+		}
+
+		if err := liveService.MuteUser(r.Context(), liveStream, targetUserId, payload.Kind); err != nil {
+			handleModerationError(w, "error mute user", err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func kickUser(streamService *stream.LiveStreamService, liveService *stream.LiveLobbyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		liveStream, _, err := readingRequestData(w, r, streamService)
+		if err != nil {
+			return
+		}
+		_, targetUserId, err := getModerationPayload(w, r)
+		if err != nil {
+			return
+		}
+
+		if err := liveService.KickUser(r.Context(), liveStream, targetUserId); err != nil {
+			handleModerationError(w, "error kick user", err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func getBannedUsers(streamService *stream.LiveStreamService, liveService *stream.LiveLobbyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		liveStream, _, err := readingRequestData(w, r, streamService)
+		if err != nil {
+			return
+		}
+
+		bannedUsers, err := liveService.GetBannedUsers(r.Context(), liveStream)
+		if err != nil {
+			httpError(w, "error get banned users", http.StatusInternalServerError, err)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(bannedUsers); err != nil {
+			httpError(w, "error get banned users", http.StatusInternalServerError, err)
+		}
+	}
+}
+
+func banUser(streamService *stream.LiveStreamService, liveService *stream.LiveLobbyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		liveStream, _, err := readingRequestData(w, r, streamService)
+		if err != nil {
+			return
+		}
+		_, targetUserId, err := getModerationPayload(w, r)
+		if err != nil {
+			return
+		}
+
+		if err := liveService.BanUser(r.Context(), liveStream, targetUserId); err != nil {
+			handleModerationError(w, "error ban user", err)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func unbanUser(streamService *stream.LiveStreamService, liveService *stream.LiveLobbyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		liveStream, _, err := readingRequestData(w, r, streamService)
+		if err != nil {
+			return
+		}
+		targetUserId, err := uuid.Parse(mux.Vars(r)["user"])
+		if err != nil {
+			httpError(w, "invalid user", http.StatusBadRequest, err)
+			return
+		}
+
+		if err := liveService.UnbanUser(r.Context(), liveStream, targetUserId); err != nil {
+			handleModerationError(w, "error unban user", err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func setRole(streamService *stream.LiveStreamService, liveService *stream.LiveLobbyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		liveStream, _, err := readingRequestData(w, r, streamService)
+		if err != nil {
+			return
+		}
+		payload, targetUserId, err := getModerationPayload(w, r)
+		if err != nil {
+			return
+		}
+
+		if err := liveService.SetRole(r.Context(), liveStream, targetUserId, sessions.Role(payload.Role)); err != nil {
+			handleModerationError(w, "error set role", err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func getModerationPayload(w http.ResponseWriter, r *http.Request) (*moderationPayload, uuid.UUID, error) {
+	dec, err := getJsonPayload(w, r)
+	if err != nil {
+		httpError(w, "invalid payload", http.StatusBadRequest, err)
+		return nil, uuid.Nil, err
+	}
+	payload := &moderationPayload{}
+	if err := dec.Decode(payload); err != nil {
+		httpError(w, "invalid payload", http.StatusBadRequest, invalidPayload)
+		return nil, uuid.Nil, invalidPayload
+	}
+	targetUserId, err := uuid.Parse(payload.User)
+	if err != nil {
+		httpError(w, "invalid payload", http.StatusBadRequest, invalidPayload)
+		return nil, uuid.Nil, invalidPayload
+	}
+	return payload, targetUserId, nil
+}
+
+func handleModerationError(w http.ResponseWriter, errResponse string, err error) {
+	if errors.Is(err, lobby.ErrLobbyNotRunning) || errors.Is(err, lobby.ErrNoSession) {
+		httpError(w, errResponse, http.StatusNotFound, err)
+		return
+	}
+	if errors.Is(err, lobby.ErrInvalidRole) {
+		httpError(w, errResponse, http.StatusBadRequest, err)
+		return
+	}
+	if errors.Is(err, lobby.ErrPermissionDenied) {
+		httpError(w, errResponse, http.StatusForbidden, err)
+		return
+	}
+	if errors.Is(err, lobby.ErrNoTracksToMute) {
+		httpError(w, errResponse, http.StatusConflict, err)
+		return
+	}
+	httpError(w, errResponse, http.StatusInternalServerError, err)
+}
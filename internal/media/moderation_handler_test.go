@@ -0,0 +1,36 @@
+package media
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shigde/sfu/internal/auth"
+	"github.com/shigde/sfu/internal/media/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModerationReq(t *testing.T) {
+	t.Run("reject user who does not own the stream", func(t *testing.T) {
+		th, space, stream, _, _ := testRouterSetup(t)
+		other := &auth.Account{}
+		other.UUID = uuid.NewString()
+		other.User = "otherUser@test.de"
+		bearer, _ := auth.CreateJWTToken(other.UUID, mocks.SecurityConfig.JWT)
+		bearer = "Bearer " + bearer
+		sessionCookie, reqToken := runWhipRequest(t, th.router, space.Identifier, stream.UUID.String(), bearer)
+
+		payload := []byte(fmt.Sprintf(`{"user":"%s"}`, uuid.NewString()))
+		req := newJsonContentRequest("POST", fmt.Sprintf("/space/%s/stream/%s/moderation/mute", space.Identifier, stream.UUID.String()), bytes.NewBuffer(payload), bearer)
+		req.AddCookie(sessionCookie)
+		req.Header.Set(mocks.ReqTokenHeaderName, reqToken)
+
+		rr := httptest.NewRecorder()
+		th.router.ServeHTTP(rr, req)
+		// Then: status is 403
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 
 	"github.com/pion/webrtc/v3"
+	"github.com/shigde/sfu/internal/rtp"
 )
 
 const maxPayloadByte = 1048576
@@ -52,3 +53,27 @@ func getSdpPayload(w http.ResponseWriter, r *http.Request, sdpType webrtc.SDPTyp
 		Type: sdpType,
 	}, nil
 }
+
+func getSdpFragmentPayload(w http.ResponseWriter, r *http.Request) (*rtp.SdpFragment, error) {
+	contentType := r.Header.Get("Content-Type")
+	if contentType != rtp.SdpFragmentMimeType {
+		return nil, invalidContentType
+	}
+	if r.Body == nil {
+		return nil, emptyPayload
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPayloadByte)
+	bodyBytes, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+
+	if err != nil {
+		return nil, invalidPayload
+	}
+
+	fragment, err := rtp.ParseSdpFragment(string(bodyBytes))
+	if err != nil {
+		return nil, invalidPayload
+	}
+	return fragment, nil
+}
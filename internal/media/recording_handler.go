@@ -0,0 +1,39 @@
+package media
+
+import (
+	"net/http"
+
+	"github.com/shigde/sfu/internal/stream"
+)
+
+func startRecording(streamService *stream.LiveStreamService, liveService *stream.LiveLobbyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		liveStream, userId, err := readingRequestData(w, r, streamService)
+		if err != nil {
+			return
+		}
+
+		if err := liveService.StartRecording(r.Context(), liveStream, userId); err != nil {
+			httpError(w, "error start recording", http.StatusBadRequest, err)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func stopRecording(streamService *stream.LiveStreamService, liveService *stream.LiveLobbyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		liveStream, userId, err := readingRequestData(w, r, streamService)
+		if err != nil {
+			return
+		}
+
+		if err := liveService.StopRecording(r.Context(), liveStream, userId); err != nil {
+			httpError(w, "error can not stop recording", http.StatusBadRequest, err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/shigde/sfu/internal/auth"
+	"github.com/shigde/sfu/internal/hls"
 	"github.com/shigde/sfu/internal/logging"
 	"github.com/shigde/sfu/internal/rtp"
 	"github.com/shigde/sfu/internal/stream"
@@ -18,6 +19,7 @@ const tracerName = telemetry.TracerName
 func NewRouter(
 	securityConfig *auth.SecurityConfig,
 	rtpConfig *rtp.RtpConfig,
+	hlsConfig *hls.HlsConfig,
 	accountService *auth.AccountService,
 	streamService *stream.LiveStreamService,
 	liveLobbyService *stream.LiveLobbyService,
@@ -25,12 +27,16 @@ func NewRouter(
 	router := mux.NewRouter()
 	cors := handlers.CORS(
 		handlers.AllowedOrigins([]string{"*"}),
+		// browser based WHIP and WHEP clients trickle ICE, delete resources and read the resource URL, the ICE session tag and the ICE servers
+		handlers.AllowedMethods([]string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}),
+		handlers.AllowedHeaders([]string{"Authorization", "Content-Type", "If-Match", "Resume-Token"}),
+		handlers.ExposedHeaders([]string{"Location", "ETag", "Link", "Resume-Token"}),
 	)
 	//	handlers.AllowedOrigins([]string{"http://localhost:3000/"}),
 	//	handlers.AllowedMethods([]string{"GET", "HEAD", "POST", "PUT", "OPTIONS"}),
 	//	handlers.AllowedHeaders([]string{"X-Req-Token"}),
 	//)
-	router.Use(cors)
+	router.Use(withPlainOptions(cors))
 	// Auth
 	router.Use(logging.LoggingMiddleware)
 
@@ -44,18 +50,47 @@ func NewRouter(
 	router.HandleFunc("/space/{space}/stream/{id}/whip", auth.HttpMiddleware(securityConfig, whip(streamService, liveLobbyService))).Methods("POST")
 	router.HandleFunc("/space/{space}/stream/{id}/whep", auth.TokenMiddleware(whep(streamService, liveLobbyService))).Methods("POST")
 	router.HandleFunc("/space/{space}/stream/{id}/res", auth.TokenMiddleware(whipDelete(streamService, liveLobbyService))).Methods("DELETE")
+	router.HandleFunc("/space/{space}/stream/{id}/res", auth.TokenMiddleware(whipPatch(streamService, liveLobbyService))).Methods("PATCH")
 
 	// RTMP Live Endpoints
 	router.HandleFunc("/space/{space}/stream/{id}/live", auth.TokenMiddleware(publishLiveStream(streamService, liveLobbyService))).Methods("POST")
 	router.HandleFunc("/space/{space}/stream/{id}/live", auth.TokenMiddleware(getStatusOfLiveStream(streamService))).Methods("GET")
 	router.HandleFunc("/space/{space}/stream/{id}/live", auth.TokenMiddleware(stopLiveStream(streamService, liveLobbyService))).Methods("DELETE")
+	router.HandleFunc("/space/{space}/stream/{id}/recording", auth.TokenMiddleware(startRecording(streamService, liveLobbyService))).Methods("POST")
+	router.HandleFunc("/space/{space}/stream/{id}/recording", auth.TokenMiddleware(stopRecording(streamService, liveLobbyService))).Methods("DELETE")
+	router.HandleFunc("/space/{space}/stream/{id}/lastn", auth.TokenMiddleware(setLastN(streamService, liveLobbyService))).Methods("PUT")
+	router.HandleFunc("/space/{space}/stream/{id}/events", auth.HttpMiddleware(securityConfig, getLobbyEvents(streamService, liveLobbyService))).Methods("GET")
+
+	// Moderation Endpoints
+	router.HandleFunc("/space/{space}/stream/{id}/moderation/mute", auth.TokenMiddleware(muteUser(streamService, liveLobbyService))).Methods("POST")
+	router.HandleFunc("/space/{space}/stream/{id}/moderation/kick", auth.TokenMiddleware(kickUser(streamService, liveLobbyService))).Methods("POST")
+	router.HandleFunc("/space/{space}/stream/{id}/moderation/bans", auth.TokenMiddleware(getBannedUsers(streamService, liveLobbyService))).Methods("GET")
+	router.HandleFunc("/space/{space}/stream/{id}/moderation/bans", auth.TokenMiddleware(banUser(streamService, liveLobbyService))).Methods("POST")
+	router.HandleFunc("/space/{space}/stream/{id}/moderation/bans/{user}", auth.TokenMiddleware(unbanUser(streamService, liveLobbyService))).Methods("DELETE")
+	router.HandleFunc("/space/{space}/stream/{id}/moderation/roles", auth.TokenMiddleware(setRole(streamService, liveLobbyService))).Methods("PUT")
+
+	// HLS Endpoints
+	if hlsConfig != nil && hlsConfig.Enable {
+		router.HandleFunc("/space/{space}/stream/{id}/hls/{file}", getHlsFile(hlsConfig, streamService)).Methods("GET")
+	}
+
+	// Spec WHIP and WHEP Endpoints
+	router.HandleFunc("/spec/space/{space}/stream/{id}/whip", auth.HttpMiddleware(securityConfig, specWhip(streamService, liveLobbyService, rtpConfig))).Methods("POST")
+	router.HandleFunc("/spec/space/{space}/stream/{id}/whip", specEndpointOptions()).Methods("OPTIONS")
+	router.HandleFunc("/spec/space/{space}/stream/{id}/whip/{resource}", auth.HttpMiddleware(securityConfig, specPatchResource(streamService, liveLobbyService, rtp.IngressEndpoint))).Methods("PATCH")
+	router.HandleFunc("/spec/space/{space}/stream/{id}/whip/{resource}", auth.HttpMiddleware(securityConfig, specDeleteResource(streamService, liveLobbyService, rtp.IngressEndpoint))).Methods("DELETE")
+	router.HandleFunc("/spec/space/{space}/stream/{id}/whip/{resource}", specResourceOptions()).Methods("OPTIONS")
+	router.HandleFunc("/spec/space/{space}/stream/{id}/whep", auth.HttpMiddleware(securityConfig, specWhep(streamService, liveLobbyService, rtpConfig))).Methods("POST")
+	router.HandleFunc("/spec/space/{space}/stream/{id}/whep", specEndpointOptions()).Methods("OPTIONS")
+	router.HandleFunc("/spec/space/{space}/stream/{id}/whep/{resource}", auth.HttpMiddleware(securityConfig, specPatchResource(streamService, liveLobbyService, rtp.EgressEndpoint))).Methods("PATCH")
+	router.HandleFunc("/spec/space/{space}/stream/{id}/whep/{resource}", auth.HttpMiddleware(securityConfig, specDeleteResource(streamService, liveLobbyService, rtp.EgressEndpoint))).Methods("DELETE")
+	router.HandleFunc("/spec/space/{space}/stream/{id}/whep/{resource}", specResourceOptions()).Methods("OPTIONS")
 
 	// Federartion api endpoints
 	router.HandleFunc("/fed/space/{space}/stream/{id}/whep", auth.HttpMiddleware(securityConfig, fedWhep(streamService, liveLobbyService))).Methods("POST")
 	router.HandleFunc("/fed/space/{space}/stream/{id}/whip", auth.HttpMiddleware(securityConfig, fedWhip(streamService, liveLobbyService))).Methods("POST")
 	router.HandleFunc("/fed/space/{space}/stream/{id}/res", auth.HttpMiddleware(securityConfig, fedResource(streamService, liveLobbyService))).Methods("DELETE")
 	router.NotFoundHandler = indexHTMLWhenNotFound(http.Dir("./web")) // Fallthrough for HTML5 routing
-	http.Handle("/", router)
 	return router
 }
 
@@ -63,3 +98,18 @@ type spaceGetCreator interface {
 	GetSpace(ctx context.Context, id string) (*stream.Space, error)
 	CreateSpace(ctx context.Context, id string) (*stream.Space, error)
 }
+
+// withPlainOptions lets OPTIONS requests without CORS preflight through to the router, WHIP and WHEP clients ask for the options of the endpoints.
+// The CORS middleware would answer all OPTIONS requests.
+func withPlainOptions(cors func(http.Handler) http.Handler) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		corsHandler := cors(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			corsHandler.ServeHTTP(w, r)
+		})
+	}
+}
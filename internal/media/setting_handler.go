@@ -2,16 +2,26 @@ package media
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/gorilla/csrf"
+	"github.com/shigde/sfu/internal/auth"
 	"github.com/shigde/sfu/internal/rtp"
 )
 
 func getSettings(config *rtp.RtpConfig) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := auth.PrincipalFromContext(r.Context())
+		if !ok {
+			httpError(w, "no user", http.StatusBadRequest, errors.New("no user"))
+			return
+		}
+		// TURN servers with a shared secret, like the embedded one, hand out short-lived credentials for every user
+		iceServer := config.GetICEServerFor(user.GetUuidString())
+
 		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(config.ICEServer); err != nil {
+		if err := json.NewEncoder(w).Encode(iceServer); err != nil {
 			httpError(w, "stream invalid", http.StatusInternalServerError, err)
 		}
 		w.Header().Set("X-CSRF-Token", csrf.Token(r))
@@ -1,11 +1,14 @@
 package media
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/gorilla/mux"
+	"github.com/shigde/sfu/internal/media/mocks"
+	"github.com/shigde/sfu/internal/rtp"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -19,6 +22,17 @@ func TestGetSettingReq(t *testing.T) {
 
 	// Then: status is 200
 	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// And: the turn server with a shared secret has short-lived credentials
+	var iceServer []rtp.ICEServer
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &iceServer))
+	assert.Len(t, iceServer, 2)
+	assert.Equal(t, []string{"stun:192.0.2.1:3478", "turn:192.0.2.1:3478?transport=udp"}, iceServer[0].Urls)
+	assert.Equal(t, "password", iceServer[0].CredentialType)
+	assert.NotEmpty(t, iceServer[0].Username)
+	assert.NotEmpty(t, iceServer[0].Credential)
+	assert.NotZero(t, iceServer[0].Expires)
+	assert.Equal(t, mocks.RtpConfig.ICEServer[1], iceServer[1])
 }
 
 func getCsrfRequestToken(t *testing.T, router *mux.Router, bearer string) string {
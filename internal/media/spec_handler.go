@@ -0,0 +1,272 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pion/webrtc/v3"
+	"github.com/shigde/sfu/internal/auth"
+	"github.com/shigde/sfu/internal/lobby"
+	"github.com/shigde/sfu/internal/lobby/resources"
+	"github.com/shigde/sfu/internal/lobby/sessions"
+	"github.com/shigde/sfu/internal/rtp"
+	"github.com/shigde/sfu/internal/stream"
+	"github.com/shigde/sfu/internal/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// The spec API follows the WHIP (RFC 9725) and WHEP specifications, so that off-the-shelf clients like OBS work unmodified.
+// Clients authenticate with the bearer token only and every WHIP or WHEP session is a resource with its own URL.
+// A client that lost its connection creates its resource again with the resume token of the session and keeps its session.
+
+type createEndpoint func(ctx context.Context, sdp *webrtc.SessionDescription, stream *stream.LiveStream, userId uuid.UUID, resumeToken string) (*resources.WebRTC, error)
+
+func specWhip(streamService *stream.LiveStreamService, liveService *stream.LiveLobbyService, rtpConfig *rtp.RtpConfig) http.HandlerFunc {
+	return specCreateResource("api: spec_whip_create", streamService, liveService.CreateLobbyIngressEndpoint, rtpConfig)
+}
+
+func specWhep(streamService *stream.LiveStreamService, liveService *stream.LiveLobbyService, rtpConfig *rtp.RtpConfig) http.HandlerFunc {
+	return specCreateResource("api: spec_whep_create", streamService, liveService.CreateLobbyEgressEndpoint, rtpConfig)
+}
+
+// specCreateResource answers the offer of a client and responds the resource URL, the ICE session tag and the ICE servers
+func specCreateResource(spanName string, streamService *stream.LiveStreamService, create createEndpoint, rtpConfig *rtp.RtpConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := otel.Tracer(tracerName).Start(r.Context(), spanName)
+		defer span.End()
+
+		userId, err := getBearerUserId(r)
+		if err != nil {
+			_ = telemetry.RecordError(span, err)
+			httpError(w, "error user", http.StatusBadRequest, err)
+			return
+		}
+
+		liveStream, _, err := getLiveStream(r, streamService)
+		if err != nil {
+			_ = telemetry.RecordError(span, err)
+			handleResourceError(w, err)
+			return
+		}
+
+		offer, err := getSdpPayload(w, r, webrtc.SDPTypeOffer)
+		if err != nil {
+			_ = telemetry.RecordError(span, err)
+			if errors.Is(err, invalidContentType) {
+				httpError(w, "unsupported media type", http.StatusUnsupportedMediaType, err)
+				return
+			}
+			httpError(w, "invalid payload", http.StatusBadRequest, err)
+			return
+		}
+		offerIce, err := rtp.ParseSdpFragment(offer.SDP)
+		if err != nil {
+			_ = telemetry.RecordError(span, err)
+			httpError(w, "invalid offer", http.StatusBadRequest, err)
+			return
+		}
+
+		// track request meta by otel
+		span.SetAttributes(
+			attribute.String("streamId", liveStream.UUID.String()),
+			attribute.String("userId", userId.String()),
+		)
+
+		resource, err := create(trickleIceContext(ctx, r), offer, liveStream, userId, r.Header.Get(resumeTokenHeader))
+		if err != nil {
+			_ = telemetry.RecordError(span, err)
+			switch {
+			case errors.Is(err, lobby.ErrSessionAlreadyExists):
+				httpError(w, "session already exists", http.StatusConflict, err)
+			case errors.Is(err, lobby.ErrUserBanned) || errors.Is(err, lobby.ErrPermissionDenied) || errors.Is(err, sessions.ErrInvalidResumeToken):
+				httpError(w, "forbidden", http.StatusForbidden, err)
+			case errors.Is(err, lobby.ErrNoSession) || errors.Is(err, sessions.ErrSessionAlreadyClosed):
+				httpError(w, "no session to resume", http.StatusNotFound, err)
+			default:
+				httpError(w, "error build resource", http.StatusInternalServerError, err)
+			}
+			return
+		}
+		span.SetAttributes(attribute.String("sessionId", resource.Id))
+
+		response := []byte(resource.SDP.SDP)
+		w.Header().Set("Content-Type", "application/sdp")
+		w.Header().Set("Location", fmt.Sprintf("%s/%s", strings.TrimSuffix(r.URL.Path, "/"), resource.Id))
+		w.Header().Set("ETag", rtp.IceSessionETag(offerIce.IceUfrag))
+		w.Header().Set(resumeTokenHeader, resource.ResumeToken)
+		w.Header().Set("Content-Length", strconv.Itoa(len(response)))
+		for _, link := range iceServerLinks(rtpConfig, userId.String()) {
+			w.Header().Add("Link", link)
+		}
+		w.WriteHeader(http.StatusCreated)
+		if _, err := w.Write(response); err != nil {
+			_ = telemetry.RecordError(span, err)
+		}
+	}
+}
+
+// specPatchResource receives the trickled ICE candidates of the resource, answers with the candidates of the server and restarts ICE
+func specPatchResource(streamService *stream.LiveStreamService, liveService *stream.LiveLobbyService, endpointType rtp.EndpointType) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := otel.Tracer(tracerName).Start(r.Context(), "api: spec_resource_patch")
+		defer span.End()
+
+		userId, err := getBearerUserId(r)
+		if err != nil {
+			_ = telemetry.RecordError(span, err)
+			httpError(w, "error user", http.StatusBadRequest, err)
+			return
+		}
+
+		liveStream, _, err := getLiveStream(r, streamService)
+		if err != nil {
+			_ = telemetry.RecordError(span, err)
+			handleResourceError(w, err)
+			return
+		}
+
+		resourceId, err := getResourceId(r)
+		if err != nil {
+			_ = telemetry.RecordError(span, err)
+			httpError(w, "invalid resource", http.StatusNotFound, err)
+			return
+		}
+
+		fragment, err := getSdpFragmentPayload(w, r)
+		if err != nil {
+			_ = telemetry.RecordError(span, err)
+			if errors.Is(err, invalidContentType) {
+				httpError(w, "unsupported media type", http.StatusUnsupportedMediaType, err)
+				return
+			}
+			httpError(w, "invalid payload", http.StatusBadRequest, err)
+			return
+		}
+
+		iceSession := resources.IceSession{Resource: resourceId, Endpoint: endpointType, ETag: r.Header.Get("If-Match")}
+		local, err := liveService.TrickleIce(ctx, liveStream, userId, fragment, iceSession)
+		if err != nil {
+			_ = telemetry.RecordError(span, err)
+			switch {
+			case errors.Is(err, stream.ErrLobbyNotActive) || errors.Is(err, lobby.ErrNoSession):
+				httpError(w, "no resource", http.StatusNotFound, err)
+			case errors.Is(err, sessions.ErrIceSessionChanged):
+				httpError(w, "ice session changed", http.StatusPreconditionFailed, err)
+			case errors.Is(err, sessions.ErrNoIceSession):
+				httpError(w, "no ice session", http.StatusConflict, err)
+			default:
+				httpError(w, "error trickle ice", http.StatusInternalServerError, err)
+			}
+			return
+		}
+
+		if local == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		response := []byte(local.String())
+		w.Header().Set("Content-Type", rtp.SdpFragmentMimeType)
+		w.Header().Set("ETag", rtp.IceSessionETag(fragment.IceUfrag))
+		w.Header().Set("Content-Length", strconv.Itoa(len(response)))
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(response); err != nil {
+			_ = telemetry.RecordError(span, err)
+		}
+	}
+}
+
+func specDeleteResource(streamService *stream.LiveStreamService, liveService *stream.LiveLobbyService, endpointType rtp.EndpointType) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := otel.Tracer(tracerName).Start(r.Context(), "api: spec_resource_delete")
+		defer span.End()
+
+		userId, err := getBearerUserId(r)
+		if err != nil {
+			_ = telemetry.RecordError(span, err)
+			httpError(w, "error user", http.StatusBadRequest, err)
+			return
+		}
+
+		liveStream, _, err := getLiveStream(r, streamService)
+		if err != nil {
+			_ = telemetry.RecordError(span, err)
+			handleResourceError(w, err)
+			return
+		}
+
+		resourceId, err := getResourceId(r)
+		if err != nil {
+			_ = telemetry.RecordError(span, err)
+			httpError(w, "invalid resource", http.StatusNotFound, err)
+			return
+		}
+
+		deleted, err := liveService.DeleteResource(ctx, liveStream, userId, resourceId, endpointType)
+		if err != nil {
+			_ = telemetry.RecordError(span, err)
+			if errors.Is(err, stream.ErrLobbyNotActive) || errors.Is(err, lobby.ErrNoSession) {
+				httpError(w, "no resource", http.StatusNotFound, err)
+				return
+			}
+			httpError(w, "error", http.StatusInternalServerError, err)
+			return
+		}
+
+		if !deleted {
+			httpError(w, "error", http.StatusInternalServerError, errors.New("deleting resource was not possible"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// specEndpointOptions tells clients how to create resources
+func specEndpointOptions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", "OPTIONS, POST")
+		w.Header().Set("Accept-Post", "application/sdp")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// specResourceOptions tells clients how to trickle ICE and delete the resource
+func specResourceOptions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", "OPTIONS, PATCH, DELETE")
+		w.Header().Set("Accept-Patch", rtp.SdpFragmentMimeType)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// iceServerLinks returns the ICE servers of the rtp config for the user as link header values
+func iceServerLinks(rtpConfig *rtp.RtpConfig, user string) []string {
+	links := make([]string, 0)
+	for _, server := range rtpConfig.GetICEServerFor(user) {
+		for _, url := range server.Urls {
+			link := fmt.Sprintf("<%s>; rel=\"ice-server\"", url)
+			if server.Username != "" {
+				link += fmt.Sprintf("; username=\"%s\"; credential=\"%s\"", server.Username, server.Credential)
+				if server.CredentialType != "" {
+					link += fmt.Sprintf("; credential-type=\"%s\"", server.CredentialType)
+				}
+			}
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+func getBearerUserId(r *http.Request) (uuid.UUID, error) {
+	user, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		return uuid.Nil, errors.New("no user")
+	}
+	return user.GetUuid()
+}
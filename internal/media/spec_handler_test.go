@@ -0,0 +1,131 @@
+package media
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shigde/sfu/internal/media/mocks"
+	"github.com/shigde/sfu/internal/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpecReq(t *testing.T) {
+	th, space, stream, _, bearer := testRouterSetup(t)
+	endpoint := fmt.Sprintf("/space/%s/stream/%s", space.Identifier, stream.UUID.String())
+	resource := fmt.Sprintf("/spec%s/whip/%s", endpoint, mocks.ResourceID)
+	fragment := []byte("a=ice-ufrag:EsAw\r\na=ice-pwd:bP+XJMM09aR8AiX1jdukzR6Y\r\nm=audio 9 RTP/AVP 0\r\na=mid:0\r\na=candidate:1387637174 1 udp 2122260223 192.0.2.1 61764 typ host\r\n")
+
+	t.Run("create WHIP resource", func(t *testing.T) {
+		offer := []byte(mocks.Offer)
+		req := newSDPContentRequest("POST", "/spec"+endpoint+"/whip", bytes.NewBuffer(offer), bearer, len(offer))
+		rr := httptest.NewRecorder()
+		th.router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		assert.Equal(t, resource, rr.Header().Get("Location"))
+		assert.Equal(t, rtp.IceSessionETag("EsAw"), rr.Header().Get("ETag"))
+		assert.Contains(t, rr.Header().Values("Link"), `<stun:stun.l.google.com:19302>; rel="ice-server"`)
+		assert.Equal(t, mocks.ResumeToken, rr.Header().Get("Resume-Token"))
+		assert.Equal(t, mocks.Answer, rr.Body.String())
+	})
+
+	t.Run("resume WHIP resource with invalid token", func(t *testing.T) {
+		offer := []byte(mocks.Offer)
+		req := newSDPContentRequest("POST", "/spec"+endpoint+"/whip", bytes.NewBuffer(offer), bearer, len(offer))
+		req.Header.Set("Resume-Token", "invalid")
+		rr := httptest.NewRecorder()
+		th.router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("create WHEP resource without web session", func(t *testing.T) {
+		offer := []byte(mocks.Offer)
+		req := newSDPContentRequest("POST", "/spec"+endpoint+"/whep", bytes.NewBuffer(offer), bearer, len(offer))
+		rr := httptest.NewRecorder()
+		th.router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		assert.Equal(t, fmt.Sprintf("/spec%s/whep/%s", endpoint, mocks.ResourceID), rr.Header().Get("Location"))
+	})
+
+	t.Run("trickle ICE candidates", func(t *testing.T) {
+		req := newSDPContentRequest("PATCH", resource, bytes.NewBuffer(fragment), bearer, len(fragment))
+		req.Header.Set("Content-Type", rtp.SdpFragmentMimeType)
+		req.Header.Set("If-Match", rtp.IceSessionETag("EsAw"))
+		rr := httptest.NewRecorder()
+		th.router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, rtp.SdpFragmentMimeType, rr.Header().Get("Content-Type"))
+		assert.Contains(t, rr.Body.String(), "a=candidate:1 1 UDP 2130706431 198.51.100.1 39132 typ host")
+	})
+
+	t.Run("restart ICE", func(t *testing.T) {
+		req := newSDPContentRequest("PATCH", resource, bytes.NewBuffer(fragment), bearer, len(fragment))
+		req.Header.Set("Content-Type", rtp.SdpFragmentMimeType)
+		req.Header.Set("If-Match", "*")
+		rr := httptest.NewRecorder()
+		th.router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, rtp.SdpFragmentMimeType, rr.Header().Get("Content-Type"))
+		assert.Equal(t, rtp.IceSessionETag("EsAw"), rr.Header().Get("ETag"))
+		assert.Contains(t, rr.Body.String(), "a=ice-ufrag:38sdf4fdsf54")
+	})
+
+	t.Run("reject invalid resource", func(t *testing.T) {
+		req := newSDPContentRequest("DELETE", fmt.Sprintf("/spec%s/whip/invalid", endpoint), nil, bearer, 0)
+		rr := httptest.NewRecorder()
+		th.router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("delete resource", func(t *testing.T) {
+		req := newSDPContentRequest("DELETE", resource, nil, bearer, 0)
+		rr := httptest.NewRecorder()
+		th.router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("options of endpoint and resource", func(t *testing.T) {
+		req, _ := http.NewRequest("OPTIONS", "/spec"+endpoint+"/whip", nil)
+		rr := httptest.NewRecorder()
+		th.router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+		assert.Equal(t, "application/sdp", rr.Header().Get("Accept-Post"))
+
+		req, _ = http.NewRequest("OPTIONS", resource, nil)
+		rr = httptest.NewRecorder()
+		th.router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+		assert.Equal(t, rtp.SdpFragmentMimeType, rr.Header().Get("Accept-Patch"))
+	})
+}
+
+func TestIceServerLinks(t *testing.T) {
+	t.Run("advertise ice servers with credentials", func(t *testing.T) {
+		config := &rtp.RtpConfig{ICEServer: []rtp.ICEServer{
+			{Urls: []string{"stun:stun.example.net"}},
+			{Urls: []string{"turn:turn.example.net?transport=udp"}, Username: "user", Credential: "pass", CredentialType: "password"},
+		}}
+		assert.Equal(t, []string{
+			`<stun:stun.example.net>; rel="ice-server"`,
+			`<turn:turn.example.net?transport=udp>; rel="ice-server"; username="user"; credential="pass"; credential-type="password"`,
+		}, iceServerLinks(config, "user"))
+	})
+
+	t.Run("advertise short-lived credentials of the user", func(t *testing.T) {
+		config := &rtp.RtpConfig{ICEServer: []rtp.ICEServer{
+			{Urls: []string{"turn:turn.example.net?transport=udp"}, Realm: "example", Secret: "secret", CredentialTtl: 60},
+		}}
+		links := iceServerLinks(config, "user")
+		assert.Len(t, links, 1)
+		assert.Regexp(t, `^<turn:turn.example.net\?transport=udp>; rel="ice-server"; username="\d+:user"; credential="[^"]+"; credential-type="password"$`, links[0])
+	})
+}
@@ -26,7 +26,7 @@ func testRouterSetup(t *testing.T) (*testHelper, *stream.Space, *stream.LiveStre
 	accountRepo := auth.NewAccountRepository(store)
 
 	liveStreamService := stream.NewLiveStreamService(streamRepo, spaceRepo)
-	liveLobbyService := stream.NewLiveLobbyService(store, lobbyManager)
+	liveLobbyService := stream.NewLiveLobbyService(store, lobbyManager, nil)
 	accountService := auth.NewAccountService(accountRepo, "test-token", mocks.SecurityConfig)
 
 	account := &auth.Account{}
@@ -57,7 +57,7 @@ func testRouterSetup(t *testing.T) (*testHelper, *stream.Space, *stream.LiveStre
 	bearer = "Bearer " + bearer
 
 	th := &testHelper{}
-	th.router = NewRouter(mocks.SecurityConfig, mocks.RtpConfig, accountService, liveStreamService, liveLobbyService)
+	th.router = NewRouter(mocks.SecurityConfig, mocks.RtpConfig, mocks.HlsConfig, accountService, liveStreamService, liveLobbyService)
 	th.liveStreamRepo = streamRepo
 	return th, space, liveStream, account, bearer
 }
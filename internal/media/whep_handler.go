@@ -10,6 +10,7 @@ import (
 	"github.com/pion/webrtc/v3"
 	"github.com/shigde/sfu/internal/auth"
 	"github.com/shigde/sfu/internal/lobby"
+	"github.com/shigde/sfu/internal/lobby/sessions"
 	"github.com/shigde/sfu/internal/stream"
 	"github.com/shigde/sfu/internal/telemetry"
 	"go.opentelemetry.io/otel"
@@ -62,7 +63,7 @@ func whep(streamService *stream.LiveStreamService, liveService *stream.LiveLobby
 			attribute.String("userId", userId.String()),
 		)
 
-		answer, resourceId, err := liveService.CreateLobbyEgressEndpoint(ctx, offer, liveStream, userId)
+		resource, err := liveService.CreateLobbyEgressEndpoint(trickleIceContext(ctx, r), offer, liveStream, userId, r.Header.Get(resumeTokenHeader))
 		if err != nil && errors.Is(err, lobby.ErrSessionAlreadyExists) {
 			w.WriteHeader(http.StatusConflict)
 			_ = telemetry.RecordError(span, err)
@@ -70,19 +71,32 @@ func whep(streamService *stream.LiveStreamService, liveService *stream.LiveLobby
 			return
 		}
 
+		if err != nil && (errors.Is(err, lobby.ErrUserBanned) || errors.Is(err, lobby.ErrPermissionDenied) || errors.Is(err, sessions.ErrInvalidResumeToken)) {
+			_ = telemetry.RecordError(span, err)
+			httpError(w, "forbidden", http.StatusForbidden, err)
+			return
+		}
+
+		if err != nil && (errors.Is(err, lobby.ErrNoSession) || errors.Is(err, sessions.ErrSessionAlreadyClosed)) {
+			_ = telemetry.RecordError(span, err)
+			httpError(w, "no session to resume", http.StatusNotFound, err)
+			return
+		}
+
 		if err != nil {
 			_ = telemetry.RecordError(span, err)
 			httpError(w, "error build whep", http.StatusInternalServerError, err)
 			return
 		}
-		span.SetAttributes(attribute.String("sessionId", resourceId))
+		span.SetAttributes(attribute.String("sessionId", resource.Id))
 
-		response := []byte(answer.SDP)
+		response := []byte(resource.SDP.SDP)
 		hash := md5.Sum(response)
 
+		w.Header().Set(resumeTokenHeader, resource.ResumeToken)
 		w.WriteHeader(http.StatusCreated)
 		w.Header().Set("etag", fmt.Sprintf("%x", hash))
-		w.Header().Set("Location", "resource/"+resourceId)
+		w.Header().Set("Location", "resource/"+resource.Id)
 		contentLen, err := w.Write(response)
 		if err != nil {
 			_ = telemetry.RecordError(span, err)
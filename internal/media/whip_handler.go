@@ -10,6 +10,8 @@ import (
 	"github.com/pion/webrtc/v3"
 	"github.com/shigde/sfu/internal/auth"
 	"github.com/shigde/sfu/internal/lobby"
+	"github.com/shigde/sfu/internal/lobby/sessions"
+	"github.com/shigde/sfu/internal/rtp"
 	"github.com/shigde/sfu/internal/stream"
 	"github.com/shigde/sfu/internal/telemetry"
 	"go.opentelemetry.io/otel"
@@ -63,7 +65,7 @@ func whip(streamService *stream.LiveStreamService, liveService *stream.LiveLobby
 		)
 		auth.SetNewRequestToken(w, user.UUID)
 
-		answer, resourceId, err := liveService.CreateLobbyIngressEndpoint(ctx, offer, liveStream, userId)
+		resource, err := liveService.CreateLobbyIngressEndpoint(trickleIceContext(ctx, r), offer, liveStream, userId, r.Header.Get(resumeTokenHeader))
 		if err != nil && errors.Is(err, lobby.ErrSessionAlreadyExists) {
 			w.WriteHeader(http.StatusConflict)
 			_ = telemetry.RecordError(span, err)
@@ -71,20 +73,32 @@ func whip(streamService *stream.LiveStreamService, liveService *stream.LiveLobby
 			return
 		}
 
-		span.SetAttributes(attribute.String("sessionId", resourceId))
+		if err != nil && (errors.Is(err, lobby.ErrUserBanned) || errors.Is(err, lobby.ErrPermissionDenied) || errors.Is(err, sessions.ErrInvalidResumeToken)) {
+			_ = telemetry.RecordError(span, err)
+			httpError(w, "forbidden", http.StatusForbidden, err)
+			return
+		}
+
+		if err != nil && (errors.Is(err, lobby.ErrNoSession) || errors.Is(err, sessions.ErrSessionAlreadyClosed)) {
+			_ = telemetry.RecordError(span, err)
+			httpError(w, "no session to resume", http.StatusNotFound, err)
+			return
+		}
 
 		if err != nil {
 			_ = telemetry.RecordError(span, err)
 			httpError(w, "error build whip", http.StatusInternalServerError, err)
 			return
 		}
+		span.SetAttributes(attribute.String("sessionId", resource.Id))
 
-		response := []byte(answer.SDP)
+		response := []byte(resource.SDP.SDP)
 		hash := md5.Sum(response)
 
+		w.Header().Set(resumeTokenHeader, resource.ResumeToken)
 		w.WriteHeader(http.StatusCreated)
 		w.Header().Set("etag", fmt.Sprintf("%x", hash))
-		w.Header().Set("Location", "resource/"+resourceId)
+		w.Header().Set("Location", "resource/"+resource.Id)
 		contentLen, err := w.Write(response)
 		if err != nil {
 			_ = telemetry.RecordError(span, err)
@@ -153,3 +167,77 @@ func whipDelete(streamService *stream.LiveStreamService, liveService *stream.Liv
 		w.WriteHeader(http.StatusOK)
 	}
 }
+
+// whipPatch receives the trickled ICE candidates of WHIP and WHEP clients and answers with the candidates of the server,
+// candidates with new ICE credentials restart ICE
+func whipPatch(streamService *stream.LiveStreamService, liveService *stream.LiveLobbyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := otel.Tracer(tracerName).Start(r.Context(), "api: whip_patch")
+		defer span.End()
+
+		user, err := auth.GetPrincipalFromSession(r)
+		if err != nil {
+			switch {
+			case errors.Is(err, auth.ErrNotAuthenticatedSession):
+				httpError(w, "no session", http.StatusForbidden, err)
+			case errors.Is(err, auth.ErrNoUserSession):
+				httpError(w, "no user session", http.StatusForbidden, err)
+			default:
+				httpError(w, "internal error", http.StatusInternalServerError, err)
+			}
+			_ = telemetry.RecordError(span, err)
+			return
+		}
+
+		userId, err := user.GetUuid()
+		if err != nil {
+			_ = telemetry.RecordError(span, err)
+			httpError(w, "error user", http.StatusBadRequest, err)
+			return
+		}
+
+		liveStream, _, err := getLiveStream(r, streamService)
+		if err != nil {
+			_ = telemetry.RecordError(span, err)
+			handleResourceError(w, err)
+			return
+		}
+
+		fragment, err := getSdpFragmentPayload(w, r)
+		if err != nil {
+			_ = telemetry.RecordError(span, err)
+			if errors.Is(err, invalidContentType) {
+				httpError(w, "unsupported media type", http.StatusUnsupportedMediaType, err)
+				return
+			}
+			httpError(w, "invalid payload", http.StatusBadRequest, err)
+			return
+		}
+
+		local, err := liveService.TrickleIce(ctx, liveStream, userId, fragment)
+		if err != nil {
+			_ = telemetry.RecordError(span, err)
+			switch {
+			case errors.Is(err, stream.ErrLobbyNotActive) || errors.Is(err, lobby.ErrNoSession):
+				httpError(w, "no resource", http.StatusNotFound, err)
+			case errors.Is(err, sessions.ErrNoIceSession):
+				httpError(w, "no ice session", http.StatusConflict, err)
+			default:
+				httpError(w, "error trickle ice", http.StatusInternalServerError, err)
+			}
+			return
+		}
+
+		// the server candidates missing in the answer reach the client with the answers to its trickled candidates
+		if local == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Content-Type", rtp.SdpFragmentMimeType)
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write([]byte(local.String())); err != nil {
+			_ = telemetry.RecordError(span, err)
+		}
+	}
+}
@@ -69,3 +69,35 @@ func newSDPContentRequest(method string, url string, body io.Reader, bearer stri
 	req.Header.Set("Authorization", bearer)
 	return req
 }
+
+func TestWhipPatchReq(t *testing.T) {
+	th, space, stream, _, bearer := testRouterSetup(t)
+	fragment := []byte("a=ice-ufrag:EsAw\r\na=ice-pwd:P2uYro0UCOQ4zxjKXaWCBui1\r\nm=audio 9 RTP/AVP 0\r\na=mid:0\r\na=candidate:1387637174 1 udp 2122260223 192.0.2.1 61764 typ host generation 0 ufrag EsAw network-id 1\r\n")
+
+	t.Run("add trickled candidates", func(t *testing.T) {
+		sessionCookie, reqToken := runWhipRequest(t, th.router, space.Identifier, stream.UUID.String(), bearer)
+		req := newSDPContentRequest("PATCH", fmt.Sprintf("/space/%s/stream/%s/res", space.Identifier, stream.UUID.String()), bytes.NewBuffer(fragment), bearer, len(fragment))
+		req.Header.Set("Content-Type", "application/trickle-ice-sdpfrag")
+		req.AddCookie(sessionCookie)
+		req.Header.Set(mocks.ReqTokenHeaderName, reqToken)
+
+		rr := httptest.NewRecorder()
+		th.router.ServeHTTP(rr, req)
+		// Then: status is 200 and the server candidates are returned
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "application/trickle-ice-sdpfrag", rr.Header().Get("Content-Type"))
+		assert.Contains(t, rr.Body.String(), "a=candidate:1 1 UDP 2130706431 198.51.100.1 39132 typ host")
+	})
+
+	t.Run("reject other content types", func(t *testing.T) {
+		sessionCookie, reqToken := runWhipRequest(t, th.router, space.Identifier, stream.UUID.String(), bearer)
+		req := newSDPContentRequest("PATCH", fmt.Sprintf("/space/%s/stream/%s/res", space.Identifier, stream.UUID.String()), bytes.NewBuffer(fragment), bearer, len(fragment))
+		req.AddCookie(sessionCookie)
+		req.Header.Set(mocks.ReqTokenHeaderName, reqToken)
+
+		rr := httptest.NewRecorder()
+		th.router.ServeHTTP(rr, req)
+		// Then: status is 415
+		assert.Equal(t, http.StatusUnsupportedMediaType, rr.Code)
+	})
+}
@@ -24,6 +24,8 @@ func Migrate(config *instance.FederationConfig, storage storage.Storage) error {
 		&models.Follow{},
 		&models.Actor{},
 		&models.Instance{},
+		&models.OutboxActivity{},
+		&models.Delivery{},
 		&auth.Account{},
 		&lobby.LobbyEntity{},
 		&stream.Space{},
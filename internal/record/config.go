@@ -0,0 +1,19 @@
+package record
+
+import "fmt"
+
+type RecordConfig struct {
+	Enable bool `mapstructure:"enable"`
+	// directory of the recordings, every live stream gets a sub directory
+	Dir string `mapstructure:"dir"`
+}
+
+func ValidateRecordConfig(config *RecordConfig) error {
+	if !config.Enable {
+		return nil
+	}
+	if len(config.Dir) == 0 {
+		return fmt.Errorf("record.dir should not be empty")
+	}
+	return nil
+}
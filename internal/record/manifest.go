@@ -0,0 +1,60 @@
+package record
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const manifestName = "manifest.json"
+
+// Manifest links the files of a recording to the sessions of the lobby
+type Manifest struct {
+	LiveStreamId uuid.UUID        `json:"streamId"`
+	StartedAt    time.Time        `json:"startedAt"`
+	StoppedAt    *time.Time       `json:"stoppedAt,omitempty"`
+	Tracks       []*ManifestTrack `json:"tracks"`
+}
+
+type ManifestTrack struct {
+	TrackId   uuid.UUID  `json:"trackId"`
+	SessionId uuid.UUID  `json:"sessionId"`
+	Kind      string     `json:"kind"`
+	Purpose   string     `json:"purpose"`
+	MimeType  string     `json:"mimeType"`
+	File      string     `json:"file"`
+	StartedAt time.Time  `json:"startedAt"`
+	StoppedAt *time.Time `json:"stoppedAt,omitempty"`
+}
+
+// writeManifest replaces the manifest atomically, so that a reader never sees a partly written file
+func writeManifest(dir string, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding manifest: %w", err)
+	}
+	tmp := filepath.Join(dir, manifestName+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("writing manifest: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, manifestName)); err != nil {
+		return fmt.Errorf("renaming manifest: %w", err)
+	}
+	return nil
+}
+
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("decoding manifest: %w", err)
+	}
+	return manifest, nil
+}
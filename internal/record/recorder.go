@@ -0,0 +1,173 @@
+package record
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pion/webrtc/v3"
+	"github.com/shigde/sfu/internal/rtp"
+	"golang.org/x/exp/slog"
+)
+
+const recordingDirTimeFormat = "20060102-150405"
+
+// Recorder writes every track of a lobby to its own file, VP8 to IVF, H264 to an Annex B stream and Opus to OGG.
+// The manifest of the recording links the files to the sessions and the time they were recorded.
+type Recorder struct {
+	ctx  context.Context
+	stop context.CancelFunc
+	done chan struct{}
+	id   uuid.UUID
+	dir  string
+
+	mu       sync.Mutex
+	tracks   map[uuid.UUID]*recording
+	manifest *Manifest
+	counter  uint32
+}
+
+type recording struct {
+	info    *rtp.TrackInfo
+	track   *recorderTrack
+	context *rtp.TrackLocalContext
+	entry   *ManifestTrack
+}
+
+func NewRecorder(ctx context.Context, config *RecordConfig, liveStreamId uuid.UUID) (*Recorder, error) {
+	startedAt := time.Now().UTC()
+	dir := filepath.Join(config.Dir, liveStreamId.String(), startedAt.Format(recordingDirTimeFormat))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating recording directory: %w", err)
+	}
+	manifest := &Manifest{LiveStreamId: liveStreamId, StartedAt: startedAt, Tracks: make([]*ManifestTrack, 0)}
+	if err := writeManifest(dir, manifest); err != nil {
+		return nil, err
+	}
+
+	ctx, stop := context.WithCancel(ctx)
+	r := &Recorder{
+		ctx:      ctx,
+		stop:     stop,
+		done:     make(chan struct{}),
+		id:       liveStreamId,
+		dir:      dir,
+		tracks:   make(map[uuid.UUID]*recording),
+		manifest: manifest,
+	}
+	go r.run()
+	return r, nil
+}
+
+func (r *Recorder) AddTrack(info *rtp.TrackInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ctx.Err() != nil {
+		return
+	}
+	if _, found := r.tracks[info.GetId()]; found {
+		return
+	}
+
+	r.counter++
+	track := &recorderTrack{id: info.GetTrackLocal().ID()}
+	rec := &recording{
+		info:    info,
+		track:   track,
+		context: rtp.NewTrackLocalContext(fmt.Sprintf("record-%d", r.counter), webrtc.SSRC(r.counter), supportedCodecs, track),
+	}
+	codec, err := info.GetTrackLocal().Bind(rec.context)
+	if err != nil {
+		slog.Warn("record.Recorder: track can not be recorded", "liveStreamId", r.id, "track", info.GetTrackLocal().ID(), "err", err)
+		return
+	}
+
+	kind := info.GetTrackLocal().Kind()
+	name := fmt.Sprintf("%d-%s-%s", r.counter, info.GetSessionId(), kind)
+	ext, err := track.open(codec, filepath.Join(r.dir, name))
+	if err != nil {
+		slog.Warn("record.Recorder: track can not be recorded", "liveStreamId", r.id, "track", info.GetTrackLocal().ID(), "err", err)
+		r.unbind(rec)
+		return
+	}
+
+	rec.entry = &ManifestTrack{
+		TrackId:   info.GetId(),
+		SessionId: info.GetSessionId(),
+		Kind:      kind.String(),
+		Purpose:   info.GetPurpose().ToString(),
+		MimeType:  codec.MimeType,
+		File:      name + ext,
+		StartedAt: time.Now().UTC(),
+	}
+	r.tracks[info.GetId()] = rec
+	r.manifest.Tracks = append(r.manifest.Tracks, rec.entry)
+	r.writeManifest()
+	if kind == webrtc.RTPCodecTypeVideo {
+		// the recording of a video can only start with a keyframe
+		info.RequestKeyframe()
+	}
+	slog.Debug("record.Recorder: add track", "liveStreamId", r.id, "track", info.GetTrackLocal().ID(), "file", rec.entry.File)
+}
+
+func (r *Recorder) RemoveTrack(info *rtp.TrackInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rec, found := r.tracks[info.GetId()]
+	if !found {
+		return
+	}
+	delete(r.tracks, info.GetId())
+	r.finish(rec)
+	r.writeManifest()
+	slog.Debug("record.Recorder: remove track", "liveStreamId", r.id, "track", info.GetTrackLocal().ID())
+}
+
+// Stop ends the recording, all files and the manifest are complete after Stop returns
+func (r *Recorder) Stop() {
+	r.stop()
+	<-r.done
+}
+
+// Dir is the directory of the recording files and the manifest
+func (r *Recorder) Dir() string {
+	return r.dir
+}
+
+func (r *Recorder) run() {
+	<-r.ctx.Done()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, rec := range r.tracks {
+		r.finish(rec)
+		delete(r.tracks, id)
+	}
+	stoppedAt := time.Now().UTC()
+	r.manifest.StoppedAt = &stoppedAt
+	r.writeManifest()
+	slog.Info("record.Recorder: recording stopped", "liveStreamId", r.id, "dir", r.dir)
+	close(r.done)
+}
+
+func (r *Recorder) finish(rec *recording) {
+	r.unbind(rec)
+	rec.track.close()
+	stoppedAt := time.Now().UTC()
+	rec.entry.StoppedAt = &stoppedAt
+}
+
+func (r *Recorder) unbind(rec *recording) {
+	if err := rec.info.GetTrackLocal().Unbind(rec.context); err != nil {
+		slog.Warn("record.Recorder: unbind track", "liveStreamId", r.id, "err", err)
+	}
+}
+
+func (r *Recorder) writeManifest() {
+	if err := writeManifest(r.dir, r.manifest); err != nil {
+		slog.Error("record.Recorder: writing manifest", "liveStreamId", r.id, "err", err)
+	}
+}
@@ -0,0 +1,106 @@
+package record
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	pionRtp "github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/shigde/sfu/internal/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func testRecorderSetup(t *testing.T) (*Recorder, func()) {
+	t.Helper()
+	config := &RecordConfig{Enable: true, Dir: t.TempDir()}
+	ctx, cancel := context.WithCancel(context.Background())
+	recorder, err := NewRecorder(ctx, config, uuid.New())
+	assert.NoError(t, err)
+	return recorder, cancel
+}
+
+func testTrack(t *testing.T, mimeType string, clockRate uint32) (*webrtc.TrackLocalStaticRTP, *rtp.TrackInfo) {
+	t.Helper()
+	track, err := webrtc.NewTrackLocalStaticRTP(webrtc.RTPCodecCapability{MimeType: mimeType, ClockRate: clockRate}, uuid.NewString(), "stream")
+	assert.NoError(t, err)
+	info := &rtp.TrackInfo{TrackSdpInfo: rtp.TrackSdpInfo{Id: uuid.New(), SessionId: uuid.New()}, Track: track}
+	return track, info
+}
+
+func testWriteRtp(t *testing.T, track *webrtc.TrackLocalStaticRTP, seq uint16, payload []byte) {
+	t.Helper()
+	err := track.WriteRTP(&pionRtp.Packet{
+		Header:  pionRtp.Header{Version: 2, SequenceNumber: seq, Timestamp: uint32(seq) * 960, Marker: true},
+		Payload: payload,
+	})
+	assert.NoError(t, err)
+}
+
+func TestRecorder(t *testing.T) {
+	t.Run("record tracks to files", func(t *testing.T) {
+		recorder, cancel := testRecorderSetup(t)
+		defer cancel()
+		video, videoInfo := testTrack(t, webrtc.MimeTypeVP8, 90000)
+		audio, audioInfo := testTrack(t, webrtc.MimeTypeOpus, 48000)
+		recorder.AddTrack(videoInfo)
+		recorder.AddTrack(audioInfo)
+
+		// vp8 keyframe with the start code 0x9d012a
+		testWriteRtp(t, video, 1, []byte{0x10, 0x00, 0x00, 0x9d, 0x01, 0x2a})
+		testWriteRtp(t, audio, 1, []byte{0xfc, 0x01, 0x02})
+		recorder.Stop()
+
+		manifest, err := ReadManifest(recorder.Dir())
+		assert.NoError(t, err)
+		assert.NotNil(t, manifest.StoppedAt)
+		assert.Len(t, manifest.Tracks, 2)
+
+		ivf, err := os.ReadFile(filepath.Join(recorder.Dir(), manifest.Tracks[0].File))
+		assert.NoError(t, err)
+		assert.Equal(t, "DKIF", string(ivf[:4]))
+		assert.Equal(t, videoInfo.SessionId, manifest.Tracks[0].SessionId)
+
+		ogg, err := os.ReadFile(filepath.Join(recorder.Dir(), manifest.Tracks[1].File))
+		assert.NoError(t, err)
+		assert.Equal(t, "OggS", string(ogg[:4]))
+		assert.Equal(t, webrtc.MimeTypeOpus, manifest.Tracks[1].MimeType)
+	})
+
+	t.Run("finish track in manifest when removed", func(t *testing.T) {
+		recorder, cancel := testRecorderSetup(t)
+		defer cancel()
+		_, info := testTrack(t, webrtc.MimeTypeOpus, 48000)
+		recorder.AddTrack(info)
+		recorder.RemoveTrack(info)
+
+		manifest, err := ReadManifest(recorder.Dir())
+		assert.NoError(t, err)
+		assert.Len(t, manifest.Tracks, 1)
+		assert.NotNil(t, manifest.Tracks[0].StoppedAt)
+		assert.Nil(t, manifest.StoppedAt)
+	})
+
+	t.Run("skip tracks with unsupported codec", func(t *testing.T) {
+		recorder, cancel := testRecorderSetup(t)
+		defer cancel()
+		_, info := testTrack(t, webrtc.MimeTypeVP9, 90000)
+		recorder.AddTrack(info)
+
+		manifest, err := ReadManifest(recorder.Dir())
+		assert.NoError(t, err)
+		assert.Len(t, manifest.Tracks, 0)
+	})
+
+	t.Run("stop recording with the lobby", func(t *testing.T) {
+		recorder, cancel := testRecorderSetup(t)
+		cancel()
+		<-recorder.done
+
+		manifest, err := ReadManifest(recorder.Dir())
+		assert.NoError(t, err)
+		assert.NotNil(t, manifest.StoppedAt)
+	})
+}
@@ -0,0 +1,131 @@
+package record
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media/h264writer"
+	"github.com/pion/webrtc/v3/pkg/media/ivfwriter"
+	"github.com/pion/webrtc/v3/pkg/media/oggwriter"
+	"golang.org/x/exp/slog"
+)
+
+var errUnsupportedCodec = errors.New("codec can not be recorded")
+
+var supportedCodecs = []webrtc.RTPCodecParameters{
+	{
+		RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000},
+		PayloadType:        96,
+	},
+	{
+		RTPCodecCapability: webrtc.RTPCodecCapability{
+			MimeType:    webrtc.MimeTypeH264,
+			ClockRate:   90000,
+			SDPFmtpLine: "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f",
+		},
+		PayloadType: 102,
+	},
+	{
+		RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2},
+		PayloadType:        111,
+	},
+}
+
+// packetQueueSize limits the packets waiting for the disk, a stalled disk must not stall the packets of the hub
+const packetQueueSize = 512
+
+type mediaWriter interface {
+	WriteRTP(packet *rtp.Packet) error
+	Close() error
+}
+
+// recorderTrack writes the rtp packets of a bound track local to a file.
+// The packets are queued and written in an own goroutine, so the track local never waits for the disk.
+type recorderTrack struct {
+	mu      sync.Mutex
+	id      string
+	packets chan *rtp.Packet
+	done    chan struct{}
+}
+
+// open creates the file for the codec negotiated by binding the track and returns the file extension
+func (t *recorderTrack) open(codec webrtc.RTPCodecParameters, fileWithoutExt string) (string, error) {
+	var writer mediaWriter
+	var err error
+	ext := ""
+	switch strings.ToLower(codec.MimeType) {
+	case strings.ToLower(webrtc.MimeTypeVP8):
+		ext = ".ivf"
+		writer, err = ivfwriter.New(fileWithoutExt+ext, ivfwriter.WithCodec(webrtc.MimeTypeVP8))
+	case strings.ToLower(webrtc.MimeTypeH264):
+		ext = ".h264"
+		writer, err = h264writer.New(fileWithoutExt + ext)
+	case strings.ToLower(webrtc.MimeTypeOpus):
+		ext = ".ogg"
+		writer, err = oggwriter.New(fileWithoutExt+ext, codec.ClockRate, 2)
+	default:
+		return "", fmt.Errorf("%s: %w", codec.MimeType, errUnsupportedCodec)
+	}
+	if err != nil {
+		return "", fmt.Errorf("creating %s file: %w", codec.MimeType, err)
+	}
+	t.mu.Lock()
+	t.packets = make(chan *rtp.Packet, packetQueueSize)
+	t.done = make(chan struct{})
+	go t.write(writer, t.packets, t.done)
+	t.mu.Unlock()
+	return ext, nil
+}
+
+func (t *recorderTrack) write(writer mediaWriter, packets <-chan *rtp.Packet, done chan<- struct{}) {
+	defer close(done)
+	for packet := range packets {
+		if err := writer.WriteRTP(packet); err != nil {
+			slog.Debug("record.recorderTrack: writing packet", "track", t.id, "err", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		slog.Warn("record.recorderTrack: closing file", "track", t.id, "err", err)
+	}
+}
+
+// WriteRTP never returns an error, because the track local would report it to the ingress media writer of the track
+func (t *recorderTrack) WriteRTP(header *rtp.Header, payload []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.packets == nil {
+		return len(payload), nil
+	}
+	// the track local reuses the packet after the call
+	packet := &rtp.Packet{Header: header.Clone(), Payload: append([]byte(nil), payload...)}
+	select {
+	case t.packets <- packet:
+	default:
+		slog.Warn("record.recorderTrack: queue full, dropping packet", "track", t.id)
+	}
+	return len(payload), nil
+}
+
+func (t *recorderTrack) Write(b []byte) (int, error) {
+	packet := &rtp.Packet{}
+	if err := packet.Unmarshal(b); err != nil {
+		return len(b), nil
+	}
+	return t.WriteRTP(&packet.Header, packet.Payload)
+}
+
+// close waits until the queued packets are written and the file is closed
+func (t *recorderTrack) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.packets == nil {
+		return
+	}
+	close(t.packets)
+	<-t.done
+	t.packets = nil
+}
@@ -0,0 +1,214 @@
+package rtmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// AMF0 type markers (Action Message Format AMF 0, section 2.1)
+const (
+	amfNumber      = 0x00
+	amfBoolean     = 0x01
+	amfString      = 0x02
+	amfObject      = 0x03
+	amfNull        = 0x05
+	amfUndefined   = 0x06
+	amfEcmaArray   = 0x08
+	amfObjectEnd   = 0x09
+	amfStrictArray = 0x0A
+	amfLongString  = 0x0C
+)
+
+var errAmfUnsupportedType = errors.New("unsupported amf type")
+
+// amfProperty is a property of an amf object, the properties are kept in order
+type amfProperty struct {
+	key   string
+	value any
+}
+
+type amfObjectValue []amfProperty
+
+type amfEcmaArrayValue []amfProperty
+
+func amfEncode(values ...any) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	for _, value := range values {
+		if err := amfEncodeValue(buf, value); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func amfEncodeValue(buf *bytes.Buffer, value any) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteByte(amfNull)
+	case float64:
+		buf.WriteByte(amfNumber)
+		_ = binary.Write(buf, binary.BigEndian, math.Float64bits(v))
+	case int:
+		return amfEncodeValue(buf, float64(v))
+	case uint32:
+		return amfEncodeValue(buf, float64(v))
+	case bool:
+		buf.WriteByte(amfBoolean)
+		if v {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	case string:
+		if len(v) > math.MaxUint16 {
+			buf.WriteByte(amfLongString)
+			_ = binary.Write(buf, binary.BigEndian, uint32(len(v)))
+			buf.WriteString(v)
+			return nil
+		}
+		buf.WriteByte(amfString)
+		amfWriteKey(buf, v)
+	case amfObjectValue:
+		buf.WriteByte(amfObject)
+		if err := amfEncodeProperties(buf, v); err != nil {
+			return err
+		}
+	case amfEcmaArrayValue:
+		buf.WriteByte(amfEcmaArray)
+		_ = binary.Write(buf, binary.BigEndian, uint32(len(v)))
+		if err := amfEncodeProperties(buf, v); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("encoding %T: %w", value, errAmfUnsupportedType)
+	}
+	return nil
+}
+
+func amfEncodeProperties(buf *bytes.Buffer, properties []amfProperty) error {
+	for _, property := range properties {
+		amfWriteKey(buf, property.key)
+		if err := amfEncodeValue(buf, property.value); err != nil {
+			return err
+		}
+	}
+	buf.Write([]byte{0, 0, amfObjectEnd})
+	return nil
+}
+
+func amfWriteKey(buf *bytes.Buffer, key string) {
+	_ = binary.Write(buf, binary.BigEndian, uint16(len(key)))
+	buf.WriteString(key)
+}
+
+// amfDecode decodes all values of a command or data message. Objects are decoded to maps.
+func amfDecode(data []byte) ([]any, error) {
+	r := bytes.NewReader(data)
+	values := make([]any, 0)
+	for r.Len() > 0 {
+		value, err := amfDecodeValue(r)
+		if err != nil {
+			return values, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func amfDecodeValue(r *bytes.Reader) (any, error) {
+	marker, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch marker {
+	case amfNumber:
+		var bits uint64
+		if err := binary.Read(r, binary.BigEndian, &bits); err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(bits), nil
+	case amfBoolean:
+		b, err := r.ReadByte()
+		return b != 0, err
+	case amfString:
+		return amfReadKey(r)
+	case amfLongString:
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return nil, err
+		}
+		return amfReadString(r, int(size))
+	case amfNull, amfUndefined:
+		return nil, nil
+	case amfObject:
+		return amfDecodeProperties(r)
+	case amfEcmaArray:
+		if _, err := r.Seek(4, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+		return amfDecodeProperties(r)
+	case amfStrictArray:
+		var count uint32
+		if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+			return nil, err
+		}
+		values := make([]any, 0, count)
+		for i := uint32(0); i < count; i++ {
+			value, err := amfDecodeValue(r)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("decoding marker %d: %w", marker, errAmfUnsupportedType)
+	}
+}
+
+func amfDecodeProperties(r *bytes.Reader) (map[string]any, error) {
+	properties := make(map[string]any)
+	for {
+		key, err := amfReadKey(r)
+		if err != nil {
+			return nil, err
+		}
+		if key == "" {
+			end, err := r.ReadByte()
+			if err != nil {
+				return nil, err
+			}
+			if end == amfObjectEnd {
+				return properties, nil
+			}
+			if err := r.UnreadByte(); err != nil {
+				return nil, err
+			}
+		}
+		value, err := amfDecodeValue(r)
+		if err != nil {
+			return nil, err
+		}
+		properties[key] = value
+	}
+}
+
+func amfReadKey(r *bytes.Reader) (string, error) {
+	var size uint16
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return "", err
+	}
+	return amfReadString(r, int(size))
+}
+
+func amfReadString(r *bytes.Reader, size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
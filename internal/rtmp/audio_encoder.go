@@ -0,0 +1,19 @@
+package rtmp
+
+// AudioFrame is an encoded AAC access unit
+type AudioFrame struct {
+	Data []byte
+	// Timestamp in milliseconds
+	Timestamp uint32
+}
+
+// AudioEncoder transcodes the Opus audio of the lobby to AAC, because RTMP servers do not accept Opus.
+// The publisher sends video only, if no encoder is set.
+type AudioEncoder interface {
+	// AudioSpecificConfig describes the AAC stream (ISO/IEC 14496-3, 1.6.2.1)
+	AudioSpecificConfig() []byte
+	// Encode consumes an Opus packet and returns the AAC frames which are complete afterwards
+	Encode(opus []byte, timestamp uint32) ([]AudioFrame, error)
+	// Close releases the encoder, the publisher closes it when it stops
+	Close() error
+}
@@ -0,0 +1,254 @@
+package rtmp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// RTMP message types (Adobe RTMP Specification 1.0, section 5.4 and 7.1)
+const (
+	msgSetChunkSize     = 1
+	msgAbort            = 2
+	msgAcknowledgement  = 3
+	msgUserControl      = 4
+	msgWindowAckSize    = 5
+	msgSetPeerBandwidth = 6
+	msgAudio            = 8
+	msgVideo            = 9
+	msgDataAmf0         = 18
+	msgCommandAmf0      = 20
+)
+
+// chunk stream ids used by the publisher
+const (
+	csidProtocolControl = 2
+	csidCommand         = 3
+	csidAudio           = 4
+	csidVideo           = 6
+	csidStream          = 8
+)
+
+const (
+	defaultChunkSize     = 128
+	publishChunkSize     = 4096
+	maxMessageSize       = 16 * 1024 * 1024
+	extendedTimestampMin = 0xFFFFFF
+)
+
+const userControlPingRequest = 6
+const userControlPingResponse = 7
+
+var errMessageTooLarge = errors.New("rtmp message too large")
+
+type message struct {
+	csid      uint32
+	typeId    uint8
+	streamId  uint32
+	timestamp uint32
+	payload   []byte
+}
+
+// chunkWriter splits messages into chunks, every message starts with a type 0 chunk
+type chunkWriter struct {
+	w         io.Writer
+	chunkSize int
+}
+
+func newChunkWriter(w io.Writer) *chunkWriter {
+	return &chunkWriter{w: w, chunkSize: defaultChunkSize}
+}
+
+func (c *chunkWriter) writeMessage(msg *message) error {
+	header := make([]byte, 0, 18)
+	header = appendBasicHeader(header, 0, msg.csid)
+	timestamp := msg.timestamp
+	extended := timestamp >= extendedTimestampMin
+	if extended {
+		timestamp = extendedTimestampMin
+	}
+	header = append(header, byte(timestamp>>16), byte(timestamp>>8), byte(timestamp))
+	size := len(msg.payload)
+	header = append(header, byte(size>>16), byte(size>>8), byte(size))
+	header = append(header, msg.typeId)
+	header = binary.LittleEndian.AppendUint32(header, msg.streamId)
+	if extended {
+		header = binary.BigEndian.AppendUint32(header, msg.timestamp)
+	}
+	if _, err := c.w.Write(header); err != nil {
+		return fmt.Errorf("writing chunk header: %w", err)
+	}
+
+	payload := msg.payload
+	for {
+		n := min(len(payload), c.chunkSize)
+		if _, err := c.w.Write(payload[:n]); err != nil {
+			return fmt.Errorf("writing chunk: %w", err)
+		}
+		payload = payload[n:]
+		if len(payload) == 0 {
+			return nil
+		}
+		continuation := appendBasicHeader(make([]byte, 0, 7), 3, msg.csid)
+		if extended {
+			continuation = binary.BigEndian.AppendUint32(continuation, msg.timestamp)
+		}
+		if _, err := c.w.Write(continuation); err != nil {
+			return fmt.Errorf("writing chunk header: %w", err)
+		}
+	}
+}
+
+func appendBasicHeader(b []byte, format uint8, csid uint32) []byte {
+	switch {
+	case csid < 64:
+		return append(b, format<<6|byte(csid))
+	case csid < 320:
+		return append(b, format<<6, byte(csid-64))
+	default:
+		return append(b, format<<6|1, byte(csid-64), byte((csid-64)>>8))
+	}
+}
+
+type chunkStreamState struct {
+	timestamp      uint32
+	timestampDelta uint32
+	extended       bool
+	length         uint32
+	typeId         uint8
+	streamId       uint32
+	payload        []byte
+}
+
+// chunkReader assembles the messages of the chunk streams of a connection
+type chunkReader struct {
+	r         io.Reader
+	chunkSize uint32
+	streams   map[uint32]*chunkStreamState
+	// bytesRead is used for the acknowledgements
+	bytesRead uint64
+}
+
+func newChunkReader(r io.Reader) *chunkReader {
+	return &chunkReader{r: r, chunkSize: defaultChunkSize, streams: make(map[uint32]*chunkStreamState)}
+}
+
+func (c *chunkReader) read(n int) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(c.r, buf); err != nil {
+		return nil, err
+	}
+	c.bytesRead += uint64(n)
+	return buf, nil
+}
+
+// readMessage returns the next complete message, protocol control messages are returned as well
+func (c *chunkReader) readMessage() (*message, error) {
+	for {
+		msg, err := c.readChunk()
+		if err != nil {
+			return nil, err
+		}
+		if msg == nil {
+			continue
+		}
+		if msg.typeId == msgSetChunkSize && len(msg.payload) >= 4 {
+			c.chunkSize = binary.BigEndian.Uint32(msg.payload) & 0x7FFFFFFF
+		}
+		return msg, nil
+	}
+}
+
+func (c *chunkReader) readChunk() (*message, error) {
+	b, err := c.read(1)
+	if err != nil {
+		return nil, err
+	}
+	format := b[0] >> 6
+	csid := uint32(b[0] & 0x3F)
+	switch csid {
+	case 0:
+		ext, err := c.read(1)
+		if err != nil {
+			return nil, err
+		}
+		csid = uint32(ext[0]) + 64
+	case 1:
+		ext, err := c.read(2)
+		if err != nil {
+			return nil, err
+		}
+		csid = uint32(ext[1])<<8 + uint32(ext[0]) + 64
+	}
+
+	state, ok := c.streams[csid]
+	if !ok {
+		state = &chunkStreamState{}
+		c.streams[csid] = state
+	}
+
+	headerSize := []int{11, 7, 3, 0}[format]
+	header, err := c.read(headerSize)
+	if err != nil {
+		return nil, err
+	}
+	if format <= 2 {
+		timestamp := uint32(header[0])<<16 | uint32(header[1])<<8 | uint32(header[2])
+		state.extended = timestamp == extendedTimestampMin
+		if format <= 1 {
+			state.length = uint32(header[3])<<16 | uint32(header[4])<<8 | uint32(header[5])
+			state.typeId = header[6]
+		}
+		if format == 0 {
+			state.streamId = binary.LittleEndian.Uint32(header[7:11])
+		}
+		if state.extended {
+			ext, err := c.read(4)
+			if err != nil {
+				return nil, err
+			}
+			timestamp = binary.BigEndian.Uint32(ext)
+		}
+		if format == 0 {
+			state.timestamp = timestamp
+			state.timestampDelta = 0
+		} else {
+			state.timestampDelta = timestamp
+			state.timestamp += timestamp
+		}
+	} else {
+		if state.extended {
+			if _, err := c.read(4); err != nil {
+				return nil, err
+			}
+		}
+		if len(state.payload) == 0 {
+			// a new message with the header of the previous one
+			state.timestamp += state.timestampDelta
+		}
+	}
+
+	if state.length > maxMessageSize {
+		return nil, errMessageTooLarge
+	}
+	remaining := state.length - uint32(len(state.payload))
+	data, err := c.read(int(min(remaining, c.chunkSize)))
+	if err != nil {
+		return nil, err
+	}
+	state.payload = append(state.payload, data...)
+	if uint32(len(state.payload)) < state.length {
+		return nil, nil
+	}
+
+	msg := &message{
+		csid:      csid,
+		typeId:    state.typeId,
+		streamId:  state.streamId,
+		timestamp: state.timestamp,
+		payload:   state.payload,
+	}
+	state.payload = nil
+	return msg, nil
+}
@@ -0,0 +1,340 @@
+package rtmp
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+const (
+	handshakeSize    = 1536
+	rtmpVersion      = 3
+	defaultPort      = "1935"
+	defaultTlsPort   = "443"
+	responseTimeout  = 10 * time.Second
+	windowAckSize    = 2500000
+	flashVersion     = "FMLE/3.0 (compatible; shig)"
+	publishStartCode = "NetStream.Publish.Start"
+)
+
+var (
+	ErrInvalidUrl     = errors.New("invalid rtmp url")
+	errPublishFailed  = errors.New("rtmp publish rejected")
+	errCommandFailed  = errors.New("rtmp command failed")
+	errConnectionLost = errors.New("rtmp connection lost")
+)
+
+// client is a RTMP connection publishing one stream
+type client struct {
+	conn     net.Conn
+	mu       sync.Mutex
+	writer   *bufio.Writer
+	chunks   *chunkWriter
+	reader   *chunkReader
+	streamId uint32
+	txn      float64
+	// responses of the command transactions
+	responses chan []any
+	status    chan map[string]any
+	closed    chan struct{}
+	closeOnce sync.Once
+	err       error
+}
+
+// dial connects to the server of the url and publishes the stream with the stream key
+func dial(ctx context.Context, rtmpUrl string, streamKey string) (*client, error) {
+	u, err := url.Parse(rtmpUrl)
+	if err != nil {
+		return nil, errors.Join(ErrInvalidUrl, err)
+	}
+	app := strings.Trim(u.Path, "/")
+	if app == "" {
+		return nil, fmt.Errorf("missing application: %w", ErrInvalidUrl)
+	}
+
+	host := u.Host
+	var conn net.Conn
+	dialer := &net.Dialer{Timeout: responseTimeout}
+	switch u.Scheme {
+	case "rtmp":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), defaultPort)
+		}
+		conn, err = dialer.DialContext(ctx, "tcp", host)
+	case "rtmps":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), defaultTlsPort)
+		}
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: u.Hostname()}}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", host)
+	default:
+		return nil, fmt.Errorf("scheme %s: %w", u.Scheme, ErrInvalidUrl)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dialing rtmp server: %w", err)
+	}
+
+	c := &client{
+		conn:      conn,
+		writer:    bufio.NewWriterSize(conn, 64*1024),
+		reader:    newChunkReader(bufio.NewReader(conn)),
+		responses: make(chan []any, 8),
+		status:    make(chan map[string]any, 8),
+		closed:    make(chan struct{}),
+	}
+	c.chunks = newChunkWriter(c.writer)
+
+	// the context only limits the connecting phase
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	if err := c.handshake(); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("rtmp handshake: %w", err)
+	}
+	go c.readLoop()
+	if err := c.publish(u, app, streamKey); err != nil {
+		c.close(err)
+		return nil, err
+	}
+	return c, nil
+}
+
+// handshake performs the simple (not encrypted) handshake of the RTMP specification, section 5.2
+func (c *client) handshake() error {
+	_ = c.conn.SetDeadline(time.Now().Add(responseTimeout))
+	defer func() { _ = c.conn.SetDeadline(time.Time{}) }()
+
+	c0c1 := make([]byte, 1+handshakeSize)
+	c0c1[0] = rtmpVersion
+	if _, err := rand.Read(c0c1[9:]); err != nil {
+		return fmt.Errorf("creating handshake: %w", err)
+	}
+	if _, err := c.conn.Write(c0c1); err != nil {
+		return fmt.Errorf("writing c0 and c1: %w", err)
+	}
+
+	s0s1s2 := make([]byte, 1+2*handshakeSize)
+	if _, err := io.ReadFull(c.reader.r, s0s1s2); err != nil {
+		return fmt.Errorf("reading s0, s1 and s2: %w", err)
+	}
+	if s0s1s2[0] != rtmpVersion {
+		return fmt.Errorf("unsupported rtmp version %d", s0s1s2[0])
+	}
+	// c2 echoes s1
+	if _, err := c.conn.Write(s0s1s2[1 : 1+handshakeSize]); err != nil {
+		return fmt.Errorf("writing c2: %w", err)
+	}
+	return nil
+}
+
+func (c *client) publish(u *url.URL, app string, streamKey string) error {
+	chunkSize := make([]byte, 4)
+	binary.BigEndian.PutUint32(chunkSize, publishChunkSize)
+	if err := c.writeMessage(&message{csid: csidProtocolControl, typeId: msgSetChunkSize, payload: chunkSize}); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.chunks.chunkSize = publishChunkSize
+	c.mu.Unlock()
+
+	tcUrl := fmt.Sprintf("%s://%s/%s", u.Scheme, u.Host, app)
+	if _, err := c.call("connect", amfObjectValue{
+		{"app", app},
+		{"type", "nonprivate"},
+		{"flashVer", flashVersion},
+		{"tcUrl", tcUrl},
+	}); err != nil {
+		return fmt.Errorf("connecting application %s: %w", app, err)
+	}
+
+	// announce the stream like OBS and ffmpeg do, the server does not need to answer
+	if err := c.send("releaseStream", nil, streamKey); err != nil {
+		return err
+	}
+	if err := c.send("FCPublish", nil, streamKey); err != nil {
+		return err
+	}
+	result, err := c.call("createStream", nil)
+	if err != nil {
+		return fmt.Errorf("creating stream: %w", err)
+	}
+	if len(result) < 4 {
+		return fmt.Errorf("creating stream without stream id: %w", errCommandFailed)
+	}
+	streamId, ok := result[3].(float64)
+	if !ok {
+		return fmt.Errorf("creating stream without stream id: %w", errCommandFailed)
+	}
+	c.streamId = uint32(streamId)
+
+	payload, err := amfEncode("publish", 0, nil, streamKey, "live")
+	if err != nil {
+		return err
+	}
+	if err := c.writeMessage(&message{csid: csidStream, typeId: msgCommandAmf0, streamId: c.streamId, payload: payload}); err != nil {
+		return err
+	}
+	select {
+	case status := <-c.status:
+		if code, _ := status["code"].(string); code != publishStartCode {
+			return fmt.Errorf("publishing %s: %v: %w", code, status["description"], errPublishFailed)
+		}
+		return nil
+	case <-c.closed:
+		return c.err
+	case <-time.After(responseTimeout):
+		return fmt.Errorf("publishing: timeout: %w", errCommandFailed)
+	}
+}
+
+// call sends a command and waits for the _result
+func (c *client) call(command string, args ...any) ([]any, error) {
+	c.txn++
+	txn := c.txn
+	values := append([]any{command, txn}, args...)
+	payload, err := amfEncode(values...)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.writeMessage(&message{csid: csidCommand, typeId: msgCommandAmf0, payload: payload}); err != nil {
+		return nil, err
+	}
+	timeout := time.After(responseTimeout)
+	for {
+		select {
+		case response := <-c.responses:
+			if len(response) < 2 || response[1] != txn {
+				continue
+			}
+			if response[0] != "_result" {
+				return nil, fmt.Errorf("%s: %v: %w", command, response, errCommandFailed)
+			}
+			return response, nil
+		case <-c.closed:
+			return nil, c.err
+		case <-timeout:
+			return nil, fmt.Errorf("%s: timeout: %w", command, errCommandFailed)
+		}
+	}
+}
+
+// send sends a command without waiting for a response
+func (c *client) send(command string, args ...any) error {
+	c.txn++
+	payload, err := amfEncode(append([]any{command, c.txn}, args...)...)
+	if err != nil {
+		return err
+	}
+	return c.writeMessage(&message{csid: csidCommand, typeId: msgCommandAmf0, payload: payload})
+}
+
+func (c *client) writeMessage(msg *message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.closed:
+		return c.err
+	default:
+	}
+	_ = c.conn.SetWriteDeadline(time.Now().Add(responseTimeout))
+	if err := c.chunks.writeMessage(msg); err != nil {
+		return err
+	}
+	return c.writer.Flush()
+}
+
+// writeMedia writes an audio, video or data message of the published stream
+func (c *client) writeMedia(typeId uint8, timestamp uint32, payload []byte) error {
+	csid := uint32(csidVideo)
+	switch typeId {
+	case msgAudio:
+		csid = csidAudio
+	case msgDataAmf0:
+		csid = csidStream
+	}
+	return c.writeMessage(&message{csid: csid, typeId: typeId, streamId: c.streamId, timestamp: timestamp, payload: payload})
+}
+
+// readLoop handles the messages of the server, this is needed to answer pings and acknowledge received bytes
+func (c *client) readLoop() {
+	var acknowledged uint64
+	for {
+		msg, err := c.reader.readMessage()
+		if err != nil {
+			c.close(errors.Join(errConnectionLost, err))
+			return
+		}
+		switch msg.typeId {
+		case msgCommandAmf0:
+			values, err := amfDecode(msg.payload)
+			if err != nil || len(values) == 0 {
+				slog.Debug("rtmp.client: decode command", "err", err)
+				continue
+			}
+			c.onCommand(values)
+		case msgUserControl:
+			if len(msg.payload) >= 6 && binary.BigEndian.Uint16(msg.payload) == userControlPingRequest {
+				pong := binary.BigEndian.AppendUint16(nil, userControlPingResponse)
+				pong = append(pong, msg.payload[2:6]...)
+				go func() {
+					_ = c.writeMessage(&message{csid: csidProtocolControl, typeId: msgUserControl, payload: pong})
+				}()
+			}
+		}
+		if c.reader.bytesRead-acknowledged >= windowAckSize/2 {
+			acknowledged = c.reader.bytesRead
+			ack := binary.BigEndian.AppendUint32(nil, uint32(acknowledged))
+			go func() {
+				_ = c.writeMessage(&message{csid: csidProtocolControl, typeId: msgAcknowledgement, payload: ack})
+			}()
+		}
+	}
+}
+
+func (c *client) onCommand(values []any) {
+	name, _ := values[0].(string)
+	switch name {
+	case "_result", "_error":
+		select {
+		case c.responses <- values:
+		default:
+		}
+	case "onStatus":
+		status, _ := values[len(values)-1].(map[string]any)
+		if level, _ := status["level"].(string); level == "error" {
+			slog.Warn("rtmp.client: server status", "code", status["code"], "description", status["description"])
+		}
+		select {
+		case c.status <- status:
+		default:
+		}
+	}
+}
+
+func (c *client) done() <-chan struct{} {
+	return c.closed
+}
+
+func (c *client) close(err error) {
+	c.closeOnce.Do(func() {
+		c.err = err
+		if c.err == nil {
+			c.err = errConnectionLost
+		}
+		close(c.closed)
+		_ = c.conn.Close()
+	})
+}
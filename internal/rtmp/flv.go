@@ -0,0 +1,70 @@
+package rtmp
+
+import (
+	"encoding/binary"
+)
+
+// FLV tag bodies (Adobe Flash Video File Format Specification 10.1, annex E)
+const (
+	flvVideoKeyframe   = 0x10
+	flvVideoInterFrame = 0x20
+	flvCodecAvc        = 0x07
+
+	flvAvcSequenceHeader = 0x00
+	flvAvcNalu           = 0x01
+
+	// AAC, 44 kHz, 16 bit, stereo: AAC is always signaled with these values, the real format is in the AudioSpecificConfig
+	flvAudioAac           = 0xAF
+	flvAacSequenceHeader  = 0x00
+	flvAacRaw             = 0x01
+	flvVideoCodecIdAvc    = 7
+	flvAudioCodecIdAac    = 10
+	avcNaluLengthSize     = 4
+	avcConfigurationBytes = 11
+)
+
+// avcSequenceHeader creates the video tag body with the AVCDecoderConfigurationRecord of the parameter sets
+func avcSequenceHeader(sps []byte, pps []byte) []byte {
+	body := make([]byte, 0, 5+avcConfigurationBytes+len(sps)+len(pps))
+	body = append(body, flvVideoKeyframe|flvCodecAvc, flvAvcSequenceHeader, 0, 0, 0)
+	body = append(body, 1, sps[1], sps[2], sps[3], 0xFC|(avcNaluLengthSize-1), 0xE1)
+	body = binary.BigEndian.AppendUint16(body, uint16(len(sps)))
+	body = append(body, sps...)
+	body = append(body, 1)
+	body = binary.BigEndian.AppendUint16(body, uint16(len(pps)))
+	body = append(body, pps...)
+	return body
+}
+
+// avcVideoTag creates the video tag body of a frame in AVCC format (length prefixed nal units)
+func avcVideoTag(frame []byte, keyframe bool) []byte {
+	frameType := byte(flvVideoInterFrame)
+	if keyframe {
+		frameType = flvVideoKeyframe
+	}
+	body := make([]byte, 0, 5+len(frame))
+	// composition time is 0, because WebRTC streams have no B-frames
+	body = append(body, frameType|flvCodecAvc, flvAvcNalu, 0, 0, 0)
+	return append(body, frame...)
+}
+
+func aacSequenceHeader(audioSpecificConfig []byte) []byte {
+	return append([]byte{flvAudioAac, flvAacSequenceHeader}, audioSpecificConfig...)
+}
+
+func aacAudioTag(frame []byte) []byte {
+	return append([]byte{flvAudioAac, flvAacRaw}, frame...)
+}
+
+// metadata creates the onMetaData data message of the stream
+func metadata(hasVideo bool, hasAudio bool) ([]byte, error) {
+	properties := amfEcmaArrayValue{}
+	if hasVideo {
+		properties = append(properties, amfProperty{"videocodecid", flvVideoCodecIdAvc})
+	}
+	if hasAudio {
+		properties = append(properties, amfProperty{"audiocodecid", flvAudioCodecIdAac})
+	}
+	properties = append(properties, amfProperty{"encoder", "shig"})
+	return amfEncode("@setDataFrame", "onMetaData", properties)
+}
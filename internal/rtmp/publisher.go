@@ -0,0 +1,372 @@
+package rtmp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pion/webrtc/v3"
+	"github.com/shigde/sfu/internal/rtp"
+	"golang.org/x/exp/slog"
+)
+
+type Status string
+
+const (
+	StatusConnecting   Status = "connecting"
+	StatusPublishing   Status = "publishing"
+	StatusReconnecting Status = "reconnecting"
+	StatusFailed       Status = "failed"
+	StatusStopped      Status = "stopped"
+)
+
+const (
+	mediaQueueSize             = 512
+	defaultReconnectBackoff    = time.Second
+	defaultMaxReconnectBackoff = 30 * time.Second
+)
+
+type mediaTag struct {
+	typeId    uint8
+	timestamp uint32
+	keyframe  bool
+	payload   []byte
+}
+
+// Publisher pushes the main stream of a lobby to a RTMP server, like PeerTube or a streaming platform.
+// The H264 video is sent as FLV tags and the Opus audio is transcoded by the AudioEncoder,
+// the status tells whether the server accepted the stream or the connection failed.
+type Publisher struct {
+	ctx       context.Context
+	stop      context.CancelFunc
+	id        uuid.UUID
+	url       string
+	streamKey string
+	startedAt time.Time
+	media     chan *mediaTag
+
+	audioEncoder        AudioEncoder
+	onStatus            func(status Status, err error)
+	reconnectBackoff    time.Duration
+	maxReconnectBackoff time.Duration
+
+	mu          sync.Mutex
+	tracks      map[uuid.UUID]*publisherBinding
+	nextTrackId uint32
+	sps, pps    []byte
+	status      Status
+	err         error
+}
+
+type publisherBinding struct {
+	info    *rtp.TrackInfo
+	track   *publisherTrack
+	context *rtp.TrackLocalContext
+}
+
+type PublisherOption func(p *Publisher)
+
+func PublisherWithAudioEncoder(encoder AudioEncoder) PublisherOption {
+	return func(p *Publisher) {
+		p.audioEncoder = encoder
+	}
+}
+
+// PublisherWithStatusListener sets a listener which is called on every status change of the publisher
+func PublisherWithStatusListener(onStatus func(status Status, err error)) PublisherOption {
+	return func(p *Publisher) {
+		p.onStatus = onStatus
+	}
+}
+
+// PublisherWithReconnectBackoff sets the first and the maximal waiting time between reconnects, the time doubles on each attempt
+func PublisherWithReconnectBackoff(backoff time.Duration, maxBackoff time.Duration) PublisherOption {
+	return func(p *Publisher) {
+		p.reconnectBackoff = backoff
+		p.maxReconnectBackoff = maxBackoff
+	}
+}
+
+func NewPublisher(ctx context.Context, liveStreamId uuid.UUID, rtmpUrl string, streamKey string, options ...PublisherOption) *Publisher {
+	ctx, stop := context.WithCancel(ctx)
+	p := &Publisher{
+		ctx:                 ctx,
+		stop:                stop,
+		id:                  liveStreamId,
+		url:                 rtmpUrl,
+		streamKey:           streamKey,
+		startedAt:           time.Now(),
+		media:               make(chan *mediaTag, mediaQueueSize),
+		reconnectBackoff:    defaultReconnectBackoff,
+		maxReconnectBackoff: defaultMaxReconnectBackoff,
+		tracks:              make(map[uuid.UUID]*publisherBinding),
+	}
+	for _, option := range options {
+		option(p)
+	}
+	go p.run()
+	return p
+}
+
+func (p *Publisher) AddTrack(info *rtp.TrackInfo) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ctx.Err() != nil {
+		return
+	}
+	if _, found := p.tracks[info.GetId()]; found {
+		return
+	}
+	kind := info.GetTrackLocal().Kind()
+	if kind == webrtc.RTPCodecTypeAudio && p.audioEncoder == nil {
+		slog.Warn("rtmp.Publisher: no audio encoder, the stream has no audio", "liveStreamId", p.id)
+		return
+	}
+
+	p.nextTrackId++
+	track := newPublisherTrack(kind, p)
+	binding := &publisherBinding{
+		info:    info,
+		track:   track,
+		context: rtp.NewTrackLocalContext(fmt.Sprintf("rtmp-%d", p.nextTrackId), webrtc.SSRC(p.nextTrackId), supportedCodecs, track),
+	}
+	if _, err := info.GetTrackLocal().Bind(binding.context); err != nil {
+		slog.Warn("rtmp.Publisher: track can not be published", "liveStreamId", p.id, "track", info.GetTrackLocal().ID(), "kind", kind, "err", err)
+		return
+	}
+	p.tracks[info.GetId()] = binding
+	if track.isVideo {
+		info.RequestKeyframe()
+	}
+	slog.Debug("rtmp.Publisher: add track", "liveStreamId", p.id, "track", info.GetTrackLocal().ID(), "kind", kind)
+}
+
+func (p *Publisher) RemoveTrack(info *rtp.TrackInfo) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	binding, found := p.tracks[info.GetId()]
+	if !found {
+		return
+	}
+	delete(p.tracks, info.GetId())
+	p.unbind(binding)
+	slog.Debug("rtmp.Publisher: remove track", "liveStreamId", p.id, "track", info.GetTrackLocal().ID())
+}
+
+// Stop closes the connection to the server and releases all tracks
+func (p *Publisher) Stop() {
+	p.stop()
+}
+
+func (p *Publisher) Status() (Status, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status, p.err
+}
+
+func (p *Publisher) run() {
+	defer func() {
+		p.mu.Lock()
+		for id, binding := range p.tracks {
+			p.unbind(binding)
+			delete(p.tracks, id)
+		}
+		p.mu.Unlock()
+		if p.audioEncoder != nil {
+			if err := p.audioEncoder.Close(); err != nil {
+				slog.Warn("rtmp.Publisher: closing audio encoder", "liveStreamId", p.id, "err", err)
+			}
+		}
+	}()
+
+	p.setStatus(StatusConnecting, nil)
+	backoff := p.reconnectBackoff
+	for {
+		c, err := dial(p.ctx, p.url, p.streamKey)
+		if err == nil {
+			backoff = p.reconnectBackoff
+			p.setStatus(StatusPublishing, nil)
+			err = p.stream(c)
+			c.close(nil)
+		}
+		if p.ctx.Err() != nil {
+			p.setStatus(StatusStopped, nil)
+			return
+		}
+		if errors.Is(err, ErrInvalidUrl) || errors.Is(err, errPublishFailed) {
+			slog.Error("rtmp.Publisher: publishing failed", "liveStreamId", p.id, "err", err)
+			p.setStatus(StatusFailed, err)
+			p.stop()
+			return
+		}
+
+		slog.Warn("rtmp.Publisher: connection lost, reconnecting", "liveStreamId", p.id, "backoff", backoff, "err", err)
+		p.setStatus(StatusReconnecting, err)
+		select {
+		case <-p.ctx.Done():
+			p.setStatus(StatusStopped, nil)
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, p.maxReconnectBackoff)
+	}
+}
+
+// stream writes the queued media to the connection until the connection is lost or the publisher stopped
+func (p *Publisher) stream(c *client) error {
+	// frames of the last connection are outdated
+	p.drainQueue()
+	p.requestKeyframes()
+
+	hasVideo, hasAudio := p.hasMedia()
+	meta, err := metadata(hasVideo, hasAudio)
+	if err != nil {
+		return fmt.Errorf("creating metadata: %w", err)
+	}
+	if err := c.writeMedia(msgDataAmf0, 0, meta); err != nil {
+		return fmt.Errorf("writing metadata: %w", err)
+	}
+
+	var videoConfig, audioConfig []byte
+	var baseTimestamp uint32
+	started := false
+	// relative converts the timestamps, so that the timestamps of a connection start at 0
+	relative := func(timestamp uint32) uint32 {
+		if !started {
+			baseTimestamp = timestamp
+			started = true
+		}
+		if timestamp < baseTimestamp {
+			return 0
+		}
+		return timestamp - baseTimestamp
+	}
+
+	for {
+		select {
+		case <-p.ctx.Done():
+			return nil
+		case <-c.done():
+			return c.err
+		case tag := <-p.media:
+			switch tag.typeId {
+			case msgVideo:
+				config := p.videoConfig()
+				// the server can not decode frames before the first keyframe
+				if videoConfig == nil && (!tag.keyframe || config == nil) {
+					continue
+				}
+				timestamp := relative(tag.timestamp)
+				if tag.keyframe && config != nil && !bytes.Equal(config, videoConfig) {
+					if err := c.writeMedia(msgVideo, timestamp, config); err != nil {
+						return fmt.Errorf("writing video sequence header: %w", err)
+					}
+					videoConfig = config
+				}
+				if err := c.writeMedia(msgVideo, timestamp, avcVideoTag(tag.payload, tag.keyframe)); err != nil {
+					return fmt.Errorf("writing video: %w", err)
+				}
+			case msgAudio:
+				frames, err := p.audioEncoder.Encode(tag.payload, tag.timestamp)
+				if err != nil {
+					slog.Debug("rtmp.Publisher: encoding audio", "liveStreamId", p.id, "err", err)
+					continue
+				}
+				for _, frame := range frames {
+					timestamp := relative(frame.Timestamp)
+					if audioConfig == nil {
+						audioConfig = aacSequenceHeader(p.audioEncoder.AudioSpecificConfig())
+						if err := c.writeMedia(msgAudio, timestamp, audioConfig); err != nil {
+							return fmt.Errorf("writing audio sequence header: %w", err)
+						}
+					}
+					if err := c.writeMedia(msgAudio, timestamp, aacAudioTag(frame.Data)); err != nil {
+						return fmt.Errorf("writing audio: %w", err)
+					}
+				}
+			}
+		}
+	}
+}
+
+// queue adds media to the send queue, if the connection is too slow the media is dropped
+func (p *Publisher) queue(tag *mediaTag) {
+	select {
+	case p.media <- tag:
+	default:
+		slog.Debug("rtmp.Publisher: queue full, drop media", "liveStreamId", p.id)
+	}
+}
+
+func (p *Publisher) drainQueue() {
+	for {
+		select {
+		case <-p.media:
+		default:
+			return
+		}
+	}
+}
+
+func (p *Publisher) setParameterSets(sps []byte, pps []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if sps != nil {
+		p.sps = append([]byte(nil), sps...)
+	}
+	if pps != nil {
+		p.pps = append([]byte(nil), pps...)
+	}
+}
+
+func (p *Publisher) videoConfig() []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.sps) < 4 || p.pps == nil {
+		return nil
+	}
+	return avcSequenceHeader(p.sps, p.pps)
+}
+
+func (p *Publisher) hasMedia() (bool, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	hasVideo, hasAudio := false, false
+	for _, binding := range p.tracks {
+		hasVideo = hasVideo || binding.track.isVideo
+		hasAudio = hasAudio || !binding.track.isVideo
+	}
+	return hasVideo, hasAudio
+}
+
+// requestKeyframes asks the publishers of the lobby for keyframes, a new rtmp connection needs one to start
+func (p *Publisher) requestKeyframes() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, binding := range p.tracks {
+		if binding.track.isVideo {
+			binding.info.RequestKeyframe()
+		}
+	}
+}
+
+func (p *Publisher) setStatus(status Status, err error) {
+	p.mu.Lock()
+	changed := p.status != status || err != nil
+	p.status = status
+	p.err = err
+	p.mu.Unlock()
+	if changed && p.onStatus != nil {
+		p.onStatus(status, err)
+	}
+}
+
+func (p *Publisher) unbind(binding *publisherBinding) {
+	if err := binding.info.GetTrackLocal().Unbind(binding.context); err != nil {
+		slog.Warn("rtmp.Publisher: unbind track", "liveStreamId", p.id, "err", err)
+	}
+}
@@ -0,0 +1,296 @@
+package rtmp
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	pionRtp "github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/shigde/sfu/internal/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testSps      = []byte{0x67, 0x42, 0x00, 0x1e, 0x95, 0xa8, 0x28, 0x0f, 0x64}
+	testPps      = []byte{0x68, 0xce, 0x3c, 0x80}
+	testIdrSlice = []byte{0x65, 0x88, 0x84, 0x00, 0x33}
+	testSlice    = []byte{0x41, 0x9a, 0x02, 0x03, 0x04}
+)
+
+// testServer is a stand-in for a RTMP server, it accepts every stream and records the received media messages
+type testServer struct {
+	listener    net.Listener
+	publishCode string
+	messages    chan *message
+	conns       chan net.Conn
+	commands    chan string
+}
+
+func newTestServer(t *testing.T, publishCode string) *testServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	server := &testServer{
+		listener:    listener,
+		publishCode: publishCode,
+		messages:    make(chan *message, 1024),
+		conns:       make(chan net.Conn, 8),
+		commands:    make(chan string, 64),
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server.conns <- conn
+			go server.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { _ = listener.Close() })
+	return server
+}
+
+func (s *testServer) url() string {
+	return "rtmp://" + s.listener.Addr().String() + "/live"
+}
+
+func (s *testServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	c0c1 := make([]byte, 1+handshakeSize)
+	if _, err := io.ReadFull(reader, c0c1); err != nil {
+		return
+	}
+	s0s1s2 := make([]byte, 1+2*handshakeSize)
+	s0s1s2[0] = rtmpVersion
+	copy(s0s1s2[1+handshakeSize:], c0c1[1:])
+	if _, err := conn.Write(s0s1s2); err != nil {
+		return
+	}
+	c2 := make([]byte, handshakeSize)
+	if _, err := io.ReadFull(reader, c2); err != nil {
+		return
+	}
+
+	chunks := newChunkReader(reader)
+	writer := newChunkWriter(conn)
+	respond := func(streamId uint32, values ...any) {
+		payload, _ := amfEncode(values...)
+		_ = writer.writeMessage(&message{csid: csidCommand, typeId: msgCommandAmf0, streamId: streamId, payload: payload})
+	}
+	for {
+		msg, err := chunks.readMessage()
+		if err != nil {
+			return
+		}
+		switch msg.typeId {
+		case msgCommandAmf0:
+			values, err := amfDecode(msg.payload)
+			if err != nil || len(values) < 2 {
+				return
+			}
+			command, _ := values[0].(string)
+			s.commands <- command
+			switch command {
+			case "connect":
+				respond(0, "_result", values[1], nil, amfObjectValue{{"code", "NetConnection.Connect.Success"}})
+			case "createStream":
+				respond(0, "_result", values[1], nil, 1)
+			case "publish":
+				level := "status"
+				if s.publishCode != publishStartCode {
+					level = "error"
+				}
+				respond(1, "onStatus", 0, nil, amfObjectValue{{"level", level}, {"code", s.publishCode}})
+			}
+		case msgAudio, msgVideo, msgDataAmf0:
+			s.messages <- msg
+		}
+	}
+}
+
+func (s *testServer) nextMessage(t *testing.T) *message {
+	t.Helper()
+	select {
+	case msg := <-s.messages:
+		return msg
+	case <-time.After(3 * time.Second):
+		t.Fatalf("no message received")
+		return nil
+	}
+}
+
+type testAudioEncoder struct {
+	closed atomic.Bool
+}
+
+func (e *testAudioEncoder) AudioSpecificConfig() []byte {
+	return []byte{0x12, 0x10}
+}
+
+func (e *testAudioEncoder) Encode(opus []byte, timestamp uint32) ([]AudioFrame, error) {
+	return []AudioFrame{{Data: []byte{0x21, 0x42}, Timestamp: timestamp}}, nil
+}
+
+func (e *testAudioEncoder) Close() error {
+	e.closed.Store(true)
+	return nil
+}
+
+func testPublisherSetup(t *testing.T, server *testServer, options ...PublisherOption) (*Publisher, <-chan Status) {
+	t.Helper()
+	statusChan := make(chan Status, 16)
+	options = append(options, PublisherWithStatusListener(func(status Status, err error) {
+		statusChan <- status
+	}))
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	publisher := NewPublisher(ctx, uuid.New(), server.url(), "stream-key", options...)
+	return publisher, statusChan
+}
+
+func testWaitForStatus(t *testing.T, statusChan <-chan Status, expected Status) {
+	t.Helper()
+	for {
+		select {
+		case status := <-statusChan:
+			if status == expected {
+				return
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("status %s not reached", expected)
+		}
+	}
+}
+
+func testTrack(t *testing.T, publisher *Publisher, mimeType string, clockRate uint32) *webrtc.TrackLocalStaticRTP {
+	t.Helper()
+	track, err := webrtc.NewTrackLocalStaticRTP(webrtc.RTPCodecCapability{MimeType: mimeType, ClockRate: clockRate}, uuid.NewString(), "stream")
+	assert.NoError(t, err)
+	publisher.AddTrack(&rtp.TrackInfo{TrackSdpInfo: rtp.TrackSdpInfo{Id: uuid.New()}, Track: track})
+	return track
+}
+
+func testWriteRtp(t *testing.T, track *webrtc.TrackLocalStaticRTP, seq uint16, ts uint32, payload []byte) {
+	t.Helper()
+	err := track.WriteRTP(&pionRtp.Packet{
+		Header:  pionRtp.Header{Version: 2, SequenceNumber: seq, Timestamp: ts, Marker: true},
+		Payload: payload,
+	})
+	assert.NoError(t, err)
+}
+
+func TestPublisher(t *testing.T) {
+	t.Run("publish stream with sequence header and keyframe", func(t *testing.T) {
+		server := newTestServer(t, publishStartCode)
+		publisher, statusChan := testPublisherSetup(t, server)
+		track := testTrack(t, publisher, webrtc.MimeTypeH264, 90000)
+		testWaitForStatus(t, statusChan, StatusPublishing)
+
+		assert.Equal(t, "connect", <-server.commands)
+		assert.Equal(t, "releaseStream", <-server.commands)
+		assert.Equal(t, "FCPublish", <-server.commands)
+		assert.Equal(t, "createStream", <-server.commands)
+		assert.Equal(t, "publish", <-server.commands)
+
+		meta := server.nextMessage(t)
+		assert.Equal(t, uint8(msgDataAmf0), meta.typeId)
+		values, err := amfDecode(meta.payload)
+		assert.NoError(t, err)
+		assert.Equal(t, "@setDataFrame", values[0])
+		assert.Equal(t, "onMetaData", values[1])
+
+		// a frame without keyframe before is dropped
+		testWriteRtp(t, track, 1, 1000, testSlice)
+		testWriteRtp(t, track, 2, 4000, testSps)
+		testWriteRtp(t, track, 3, 4000, testPps)
+		testWriteRtp(t, track, 4, 4000, testIdrSlice)
+		testWriteRtp(t, track, 5, 7000, testSlice)
+
+		header := server.nextMessage(t)
+		assert.Equal(t, uint8(msgVideo), header.typeId)
+		assert.Equal(t, []byte{0x17, 0x00}, header.payload[:2])
+		assert.Equal(t, testSps[1:4], header.payload[6:9])
+
+		keyframe := server.nextMessage(t)
+		assert.Equal(t, []byte{0x17, 0x01}, keyframe.payload[:2])
+		assert.Equal(t, testIdrSlice, keyframe.payload[9:])
+		assert.Equal(t, uint32(0), keyframe.timestamp)
+
+		interFrame := server.nextMessage(t)
+		assert.Equal(t, []byte{0x27, 0x01}, interFrame.payload[:2])
+		assert.Equal(t, uint32(33), interFrame.timestamp)
+	})
+
+	t.Run("publish audio with audio encoder", func(t *testing.T) {
+		server := newTestServer(t, publishStartCode)
+		publisher, statusChan := testPublisherSetup(t, server, PublisherWithAudioEncoder(&testAudioEncoder{}))
+		track := testTrack(t, publisher, webrtc.MimeTypeOpus, 48000)
+		testWaitForStatus(t, statusChan, StatusPublishing)
+		_ = server.nextMessage(t)
+
+		testWriteRtp(t, track, 1, 960, []byte{0xfc, 0x01})
+		header := server.nextMessage(t)
+		assert.Equal(t, uint8(msgAudio), header.typeId)
+		assert.Equal(t, []byte{0xAF, 0x00, 0x12, 0x10}, header.payload)
+		frame := server.nextMessage(t)
+		assert.Equal(t, []byte{0xAF, 0x01, 0x21, 0x42}, frame.payload)
+	})
+
+	t.Run("close audio encoder when stopped", func(t *testing.T) {
+		server := newTestServer(t, publishStartCode)
+		encoder := &testAudioEncoder{}
+		publisher, statusChan := testPublisherSetup(t, server, PublisherWithAudioEncoder(encoder))
+		testWaitForStatus(t, statusChan, StatusPublishing)
+
+		publisher.Stop()
+		testWaitForStatus(t, statusChan, StatusStopped)
+
+		assert.Eventually(t, encoder.closed.Load, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("fail if the server rejects the stream", func(t *testing.T) {
+		server := newTestServer(t, "NetStream.Publish.BadName")
+		publisher, statusChan := testPublisherSetup(t, server)
+		testWaitForStatus(t, statusChan, StatusFailed)
+		status, err := publisher.Status()
+		assert.Equal(t, StatusFailed, status)
+		assert.ErrorIs(t, err, errPublishFailed)
+	})
+
+	t.Run("fail on invalid url", func(t *testing.T) {
+		statusChan := make(chan Status, 4)
+		publisher := NewPublisher(context.Background(), uuid.New(), "http://localhost/live", "key", PublisherWithStatusListener(func(status Status, err error) {
+			statusChan <- status
+		}))
+		testWaitForStatus(t, statusChan, StatusFailed)
+		_, err := publisher.Status()
+		assert.ErrorIs(t, err, ErrInvalidUrl)
+	})
+
+	t.Run("reconnect after connection lost", func(t *testing.T) {
+		server := newTestServer(t, publishStartCode)
+		_, statusChan := testPublisherSetup(t, server, PublisherWithReconnectBackoff(10*time.Millisecond, 100*time.Millisecond))
+		testWaitForStatus(t, statusChan, StatusPublishing)
+
+		conn := <-server.conns
+		_ = conn.Close()
+		testWaitForStatus(t, statusChan, StatusReconnecting)
+		testWaitForStatus(t, statusChan, StatusPublishing)
+	})
+
+	t.Run("stop publisher", func(t *testing.T) {
+		server := newTestServer(t, publishStartCode)
+		publisher, statusChan := testPublisherSetup(t, server)
+		testWaitForStatus(t, statusChan, StatusPublishing)
+		publisher.Stop()
+		testWaitForStatus(t, statusChan, StatusStopped)
+	})
+}
@@ -0,0 +1,159 @@
+package rtmp
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/webrtc/v3"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	naluTypeIdr = 5
+	naluTypeSps = 7
+	naluTypePps = 8
+	naluTypeAud = 9
+)
+
+var supportedCodecs = []webrtc.RTPCodecParameters{
+	{
+		RTPCodecCapability: webrtc.RTPCodecCapability{
+			MimeType:    webrtc.MimeTypeH264,
+			ClockRate:   90000,
+			SDPFmtpLine: "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f",
+		},
+		PayloadType: 102,
+	},
+	{
+		RTPCodecCapability: webrtc.RTPCodecCapability{
+			MimeType:  webrtc.MimeTypeOpus,
+			ClockRate: 48000,
+			Channels:  2,
+		},
+		PayloadType: 111,
+	},
+}
+
+// publisherTrack receives the rtp packets of a bound track local and converts them to media tags of the publisher
+type publisherTrack struct {
+	mu        sync.Mutex
+	isVideo   bool
+	clockRate uint32
+	publisher *Publisher
+	// timestamp handling
+	started  bool
+	baseMs   uint32
+	firstTs  uint32
+	lastTs   uint32
+	tsCycles uint64
+	// h264 frame assembly
+	h264     *codecs.H264Packet
+	frame    []byte
+	frameTs  uint32
+	keyframe bool
+}
+
+func newPublisherTrack(kind webrtc.RTPCodecType, publisher *Publisher) *publisherTrack {
+	track := &publisherTrack{
+		isVideo:   kind == webrtc.RTPCodecTypeVideo,
+		publisher: publisher,
+		clockRate: 48000,
+	}
+	if track.isVideo {
+		track.clockRate = 90000
+		track.h264 = &codecs.H264Packet{IsAVC: true}
+	}
+	return track
+}
+
+// WriteRTP never returns an error, because the track local would report it to the ingress media writer of the track
+func (t *publisherTrack) WriteRTP(header *rtp.Header, payload []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.isVideo {
+		t.publisher.queue(&mediaTag{typeId: msgAudio, timestamp: t.timestamp(header.Timestamp), payload: append([]byte(nil), payload...)})
+		return len(payload), nil
+	}
+
+	if len(t.frame) > 0 && header.Timestamp != t.frameTs {
+		// the marker of the last frame got lost
+		t.flushFrame()
+	}
+	nalus, err := t.h264.Unmarshal(payload)
+	if err != nil {
+		slog.Debug("rtmp.publisherTrack: depacketize h264", "err", err)
+		return len(payload), nil
+	}
+	t.frameTs = header.Timestamp
+	t.collectNalus(nalus)
+	if header.Marker {
+		t.flushFrame()
+	}
+	return len(payload), nil
+}
+
+func (t *publisherTrack) Write(b []byte) (int, error) {
+	packet := &rtp.Packet{}
+	if err := packet.Unmarshal(b); err != nil {
+		return len(b), nil
+	}
+	return t.WriteRTP(&packet.Header, packet.Payload)
+}
+
+func (t *publisherTrack) collectNalus(nalus []byte) {
+	for len(nalus) > 4 {
+		size := int(binary.BigEndian.Uint32(nalus))
+		if size > len(nalus)-4 {
+			return
+		}
+		nalu := nalus[4 : 4+size]
+		nalus = nalus[4+size:]
+		if len(nalu) == 0 {
+			continue
+		}
+		switch nalu[0] & 0x1F {
+		case naluTypeSps:
+			t.publisher.setParameterSets(nalu, nil)
+			continue
+		case naluTypePps:
+			t.publisher.setParameterSets(nil, nalu)
+			continue
+		case naluTypeAud:
+			continue
+		case naluTypeIdr:
+			t.keyframe = true
+		}
+		t.frame = binary.BigEndian.AppendUint32(t.frame, uint32(len(nalu)))
+		t.frame = append(t.frame, nalu...)
+	}
+}
+
+func (t *publisherTrack) flushFrame() {
+	if len(t.frame) > 0 {
+		t.publisher.queue(&mediaTag{typeId: msgVideo, timestamp: t.timestamp(t.frameTs), keyframe: t.keyframe, payload: t.frame})
+	}
+	t.frame = nil
+	t.keyframe = false
+}
+
+// timestamp converts the rtp timestamp to milliseconds since the start of the publisher
+func (t *publisherTrack) timestamp(ts uint32) uint32 {
+	if !t.started {
+		t.started = true
+		t.firstTs = ts
+		t.lastTs = ts
+		t.baseMs = uint32(time.Since(t.publisher.startedAt).Milliseconds())
+	}
+	if ts < t.lastTs && t.lastTs-ts > 1<<31 {
+		t.tsCycles++
+	}
+	t.lastTs = ts
+	if t.tsCycles == 0 && ts < t.firstTs {
+		return t.baseMs
+	}
+	unwrapped := t.tsCycles<<32 + uint64(ts) - uint64(t.firstTs)
+	return t.baseMs + uint32(unwrapped*1000/uint64(t.clockRate))
+}
@@ -0,0 +1,37 @@
+package rtp
+
+import (
+	"github.com/pion/rtp"
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v3"
+)
+
+// audioLevelExtensionId returns the negotiated id of the RFC 6464 audio level header extension, 0 if it was not negotiated
+func audioLevelExtensionId(receiver *webrtc.RTPReceiver) uint8 {
+	if receiver == nil {
+		return 0
+	}
+	for _, ext := range receiver.GetParameters().HeaderExtensions {
+		if ext.URI == sdp.AudioLevelURI {
+			return uint8(ext.ID)
+		}
+	}
+	return 0
+}
+
+// readAudioLevel reads the audio level of a rtp packet in -dBov, 0 is the loudest and 127 silence
+func readAudioLevel(buf []byte, extensionId uint8) (uint8, bool) {
+	header := &rtp.Header{}
+	if _, err := header.Unmarshal(buf); err != nil {
+		return 0, false
+	}
+	payload := header.GetExtension(extensionId)
+	if payload == nil {
+		return 0, false
+	}
+	ext := &rtp.AudioLevelExtension{}
+	if err := ext.Unmarshal(payload); err != nil {
+		return 0, false
+	}
+	return ext.Level, true
+}
@@ -0,0 +1,37 @@
+package rtp
+
+import (
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadAudioLevel(t *testing.T) {
+	testPacket := func(t *testing.T, extensionId uint8, level uint8) []byte {
+		t.Helper()
+		ext, err := (&rtp.AudioLevelExtension{Level: level, Voice: true}).Marshal()
+		assert.NoError(t, err)
+		packet := &rtp.Packet{Header: rtp.Header{Version: 2, SequenceNumber: 1}, Payload: []byte{0xfc}}
+		assert.NoError(t, packet.SetExtension(extensionId, ext))
+		buf, err := packet.Marshal()
+		assert.NoError(t, err)
+		return buf
+	}
+
+	t.Run("read audio level of negotiated extension", func(t *testing.T) {
+		level, ok := readAudioLevel(testPacket(t, 1, 42), 1)
+		assert.True(t, ok)
+		assert.Equal(t, uint8(42), level)
+	})
+
+	t.Run("ignore other extensions", func(t *testing.T) {
+		_, ok := readAudioLevel(testPacket(t, 2, 42), 1)
+		assert.False(t, ok)
+	})
+
+	t.Run("ignore invalid packets", func(t *testing.T) {
+		_, ok := readAudioLevel([]byte{0x80}, 1)
+		assert.False(t, ok)
+	})
+}
@@ -2,12 +2,28 @@ package rtp
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/pion/webrtc/v3"
 )
 
 type RtpConfig struct {
-	ICEServer []ICEServer `mapstructure:"iceServer"`
+	ICEServer        []ICEServer            `mapstructure:"iceServer"`
+	LiveStreamSender LiveStreamSenderConfig `mapstructure:"liveStreamSender"`
+	// seconds a session waits for its client to reconnect after the connection was lost, 0 closes the session right away
+	ReconnectGracePeriod int `mapstructure:"reconnectGracePeriod"`
+}
+
+// LiveStreamSenderConfig configures the forwarding of the main stream of a lobby as plain RTP over UDP,
+// for external tools like ffmpeg or gstreamer
+type LiveStreamSenderConfig struct {
+	Enable bool `mapstructure:"enable"`
+	// address of the receiving tool
+	Host    string `mapstructure:"host"`
+	PortMin int    `mapstructure:"portMin"`
+	PortMax int    `mapstructure:"portMax"`
+	// directory of the generated sdp files, the receiving tool reads the ports from these files
+	SdpDir string `mapstructure:"sdpDir"`
 }
 
 type ICEServer struct {
@@ -15,11 +31,39 @@ type ICEServer struct {
 	Username       string   `mapstructure:"username"`
 	Credential     string   `mapstructure:"credential"`
 	CredentialType string   `mapstructure:"credentialType"`
+	// realm of a TURN server, every realm has its own secret
+	Realm string `mapstructure:"realm" json:",omitempty"`
+	// shared secret of the TURN REST API, the clients get short-lived credentials instead of static ones
+	Secret string `mapstructure:"secret" json:"-"`
+	// seconds the short-lived credentials are valid
+	CredentialTtl int `mapstructure:"credentialTtl" json:"-"`
+	// unix time the short-lived credentials of a client expire
+	Expires int64 `mapstructure:"-" json:",omitempty"`
+}
+
+// GetICEServerFor returns the ICE servers for a user, the servers with a shared secret get short-lived credentials of the user
+func (c *RtpConfig) GetICEServerFor(user string) []ICEServer {
+	iceServerList := make([]ICEServer, 0, len(c.ICEServer))
+	for _, server := range c.ICEServer {
+		if len(server.Secret) != 0 {
+			username, password, expires := NewTurnCredentials(server.Secret, user, time.Duration(server.CredentialTtl)*time.Second)
+			server.Username = username
+			server.Credential = password
+			server.CredentialType = "password"
+			server.Expires = expires.Unix()
+		}
+		iceServerList = append(iceServerList, server)
+	}
+	return iceServerList
 }
 
 func (c *RtpConfig) getIceServer() []webrtc.ICEServer {
 	iceServerList := []webrtc.ICEServer{}
 	for _, server := range c.ICEServer {
+		// short-lived credentials are for the clients, the sfu does not relay over TURN servers with a shared secret
+		if len(server.Secret) != 0 {
+			continue
+		}
 		iceServer := webrtc.ICEServer{}
 		iceServer.URLs = server.Urls
 		iceServer.CredentialType = newICECredentialType(server.CredentialType)
@@ -31,6 +75,11 @@ func (c *RtpConfig) getIceServer() []webrtc.ICEServer {
 	return iceServerList
 }
 
+// GetReconnectGracePeriod returns how long a session waits for its client to reconnect
+func (c *RtpConfig) GetReconnectGracePeriod() time.Duration {
+	return time.Duration(c.ReconnectGracePeriod) * time.Second
+}
+
 func (c *RtpConfig) getWebrtcConf() webrtc.Configuration {
 	conf := webrtc.Configuration{}
 	conf.ICEServers = c.getIceServer()
@@ -38,6 +87,9 @@ func (c *RtpConfig) getWebrtcConf() webrtc.Configuration {
 }
 
 func ValidateRtpConfig(config *RtpConfig) error {
+	if config.ReconnectGracePeriod < 0 {
+		return fmt.Errorf("rtp.reconnectGracePeriod should not be negative")
+	}
 	if len(config.ICEServer) != 0 {
 		for n, server := range config.ICEServer {
 			if err := validateConfigIceServer(server, n); err != nil {
@@ -46,6 +98,26 @@ func ValidateRtpConfig(config *RtpConfig) error {
 		}
 	}
 
+	return validateLiveStreamSenderConfig(&config.LiveStreamSender)
+}
+
+func validateLiveStreamSenderConfig(config *LiveStreamSenderConfig) error {
+	if !config.Enable {
+		return nil
+	}
+	if len(config.Host) == 0 {
+		return fmt.Errorf("rtp.liveStreamSender.host should not be empty")
+	}
+	if config.PortMin < 1 || config.PortMax > 65535 {
+		return fmt.Errorf("rtp.liveStreamSender.portMin and rtp.liveStreamSender.portMax should be between 1 and 65535")
+	}
+	// every sender needs two ports for audio and two ports for video
+	if config.PortMax-config.PortMin < 4 {
+		return fmt.Errorf("rtp.liveStreamSender.portMax should be at least 4 greater than rtp.liveStreamSender.portMin")
+	}
+	if len(config.SdpDir) == 0 {
+		return fmt.Errorf("rtp.liveStreamSender.sdpDir should not be empty")
+	}
 	return nil
 }
 
@@ -57,6 +129,15 @@ func validateConfigIceServer(iceServer ICEServer, n int) error {
 	if len(iceServer.CredentialType) != 0 && iceServer.CredentialType != "password" && iceServer.CredentialType != "oauth" {
 		return fmt.Errorf("rtp.iceServer[]{credentialType=} has to be 'password', 'oauth' ore empty, entry %d", n)
 	}
+
+	if len(iceServer.Secret) != 0 {
+		if len(iceServer.Username) != 0 || len(iceServer.Credential) != 0 || iceServer.CredentialType == "oauth" {
+			return fmt.Errorf("rtp.iceServer[]{secret=} can not be combined with static credentials, entry %d", n)
+		}
+		if iceServer.CredentialTtl < 1 {
+			return fmt.Errorf("rtp.iceServer[]{credentialTtl=} has to be greater than 0 for a secret, entry %d", n)
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,70 @@
+package rtp
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testRtpConfigSetup(t *testing.T) *RtpConfig {
+	t.Helper()
+	return &RtpConfig{ICEServer: []ICEServer{
+		{Urls: []string{"stun:stun.l.google.com:19302"}},
+		{Urls: []string{"turn:eu.turn.shig.de:3478"}, Realm: "eu", Secret: "eu-secret", CredentialTtl: 3600},
+		{Urls: []string{"turn:us.turn.shig.de:3478"}, Realm: "us", Secret: "us-secret", CredentialTtl: 600},
+		{Urls: []string{"turn:turn.shig.de:3478"}, Username: "shig_user", Credential: "shig_pass"},
+	}}
+}
+
+func TestRtpConfig_GetICEServerFor(t *testing.T) {
+	t.Run("create short-lived credentials of the user for every realm", func(t *testing.T) {
+		config := testRtpConfigSetup(t)
+		iceServer := config.GetICEServerFor("user")
+		assert.Len(t, iceServer, 4)
+
+		eu, us := iceServer[1], iceServer[2]
+		assert.True(t, strings.HasSuffix(eu.Username, ":user"))
+		assert.Equal(t, TurnPassword("eu-secret", eu.Username), eu.Credential)
+		assert.Equal(t, TurnPassword("us-secret", us.Username), us.Credential)
+		assert.Equal(t, "password", eu.CredentialType)
+		assert.InDelta(t, time.Now().Add(time.Hour).Unix(), eu.Expires, 2)
+		assert.InDelta(t, time.Now().Add(10*time.Minute).Unix(), us.Expires, 2)
+	})
+
+	t.Run("keep servers without secret", func(t *testing.T) {
+		config := testRtpConfigSetup(t)
+		iceServer := config.GetICEServerFor("user")
+		assert.Equal(t, config.ICEServer[0], iceServer[0])
+		assert.Equal(t, config.ICEServer[3], iceServer[3])
+	})
+
+	t.Run("do not change the config", func(t *testing.T) {
+		config := testRtpConfigSetup(t)
+		_ = config.GetICEServerFor("user")
+		assert.Empty(t, config.ICEServer[1].Username)
+		assert.Zero(t, config.ICEServer[1].Expires)
+	})
+
+	t.Run("use only servers without secret for the sfu", func(t *testing.T) {
+		config := testRtpConfigSetup(t)
+		assert.Len(t, config.getIceServer(), 2)
+	})
+}
+
+func TestValidateRtpConfig(t *testing.T) {
+	t.Run("accept servers with secrets", func(t *testing.T) {
+		assert.NoError(t, ValidateRtpConfig(testRtpConfigSetup(t)))
+	})
+
+	t.Run("reject secret without credential ttl", func(t *testing.T) {
+		config := &RtpConfig{ICEServer: []ICEServer{{Urls: []string{"turn:turn.shig.de:3478"}, Secret: "secret"}}}
+		assert.Error(t, ValidateRtpConfig(config))
+	})
+
+	t.Run("reject secret with static credentials", func(t *testing.T) {
+		config := &RtpConfig{ICEServer: []ICEServer{{Urls: []string{"turn:turn.shig.de:3478"}, Secret: "secret", CredentialTtl: 60, Username: "shig_user"}}}
+		assert.Error(t, ValidateRtpConfig(config))
+	})
+}
@@ -4,9 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/pion/dtls/v2"
+	"github.com/pion/interceptor/pkg/cc"
+	"github.com/pion/rtcp"
 	"github.com/pion/webrtc/v3"
 	"github.com/shigde/sfu/internal/metric"
 	"github.com/shigde/sfu/internal/rtp/stats"
@@ -29,7 +35,8 @@ type Endpoint struct {
 	// Means ice candidates are gathered
 	// The ICE candidates are exchanged via the SDP, so the caller must wait until
 	// all candidates have been written into the SDP.
-	gatherComplete <-chan struct{}
+	gatherComplete   <-chan struct{}
+	gatherCompleteMu sync.Mutex
 	// Means the "Offer Answer Exchange" cycle is complete.
 	// An egress endpoint is created without tracks. The renegotiation process begins
 	// only the connection is established (initComplete) for the first time.
@@ -37,16 +44,30 @@ type Endpoint struct {
 	closed        chan struct{}
 	statsRegistry *stats.Registry
 	iceState      webrtc.ICEConnectionState
+	// The simulcast layer an egress endpoint wants to receive
+	videoQuality   VideoQuality
+	videoQualityMu sync.Mutex
+	// Estimates the available bandwidth to the subscriber of an egress endpoint,
+	// it is set by the congestion controller when the peer connection is created
+	bandwidthEstimator   cc.BandwidthEstimator
+	bandwidthEstimatorMu sync.Mutex
+	// The rtcp feedback of an egress sender goes to the publisher of the current track of the sender
+	feedbacks   map[*webrtc.RTPSender]*atomic.Pointer[rtcpForwarder]
+	feedbacksMu sync.Mutex
 	// With Endpoint Optionals #######################################
-	onChannel           func(dc *webrtc.DataChannel)
-	onEstablished       func()
-	onNegotiationNeeded func(offer webrtc.SessionDescription)
-	waitBeforeONNSetup  <-chan struct{}
-	onLostConnection    func()
-	onIceStateConnected func()
-	getCurrentTracksCbk func(ctx context.Context, sessionId uuid.UUID) ([]*TrackInfo, error)
-	initTracks          []*initTrack // deprecated
-	dispatcher          TrackDispatcher
+	onChannel             func(dc *webrtc.DataChannel)
+	onEstablished         func()
+	onNegotiationNeeded   func(offer webrtc.SessionDescription)
+	waitBeforeONNSetup    <-chan struct{}
+	onLostConnection      func()
+	onIceStateConnected   func()
+	onIceStateChange      func(state webrtc.ICEConnectionState)
+	getCurrentTracksCbk   func(ctx context.Context, sessionId uuid.UUID) ([]*TrackInfo, error)
+	onTargetBitrateChange func(bitrate int)
+	onAudioLevel          func(level uint8)
+	qualityAdapter        *videoQualityAdapter
+	initTracks            []*initTrack // deprecated
+	dispatcher            TrackDispatcher
 }
 
 func newEndpoint(sessionCxt context.Context, sessionId string, liveStreamId string, endpointType EndpointType, options ...EndpointOption) *Endpoint {
@@ -57,6 +78,7 @@ func newEndpoint(sessionCxt context.Context, sessionId string, liveStreamId stri
 		endpointType:           endpointType,
 		trackSdpInfoRepository: newTrackSdpInfoRepository(),
 		initComplete:           make(chan struct{}),
+		videoQuality:           VideoQuality_HIGH,
 	}
 	for _, opt := range options {
 		opt(endpoint)
@@ -79,18 +101,204 @@ func (c *Endpoint) GetLocalDescription(ctx context.Context) (*webrtc.SessionDesc
 	_, span := rtpTrace(ctx, "endpoint_get_local_description")
 	defer span.End()
 	select {
-	case <-c.gatherComplete:
+	case <-c.getGatherComplete():
+		return c.localDescription(span), nil
+	case <-c.sessionCxt.Done():
+		span.RecordError(ErrSessionClosed)
+		return nil, ErrSessionClosed
+	case <-ctx.Done():
+		span.RecordError(ErrIceGatheringInterruption)
+		return nil, ErrIceGatheringInterruption
+	}
+}
+
+// GetCurrentLocalDescription returns the local sdp without waiting for the ICE gathering,
+// the remote peer has to receive the missing candidates by trickle ICE
+func (c *Endpoint) GetCurrentLocalDescription(ctx context.Context) (*webrtc.SessionDescription, error) {
+	_, span := rtpTrace(ctx, "endpoint_get_current_local_description")
+	defer span.End()
+	select {
+	case <-c.sessionCxt.Done():
+		span.RecordError(ErrSessionClosed)
+		return nil, ErrSessionClosed
+	default:
+		return c.localDescription(span), nil
+	}
+}
+
+// GetLocalCandidates returns the local ICE credentials and the candidates gathered so far.
+// The fragment ends the candidates, when the ICE gathering is complete.
+func (c *Endpoint) GetLocalCandidates() (*SdpFragment, error) {
+	local := c.peerConnection.LocalDescription()
+	if local == nil {
+		return nil, errors.New("getting local candidates without local description")
+	}
+	frag, err := ParseSdpFragment(local.SDP)
+	if err != nil {
+		return nil, fmt.Errorf("parsing local description: %w", err)
+	}
+	select {
+	case <-c.getGatherComplete():
+		for _, media := range frag.Media {
+			media.EndOfCandidates = true
+		}
+	default:
+	}
+	return frag, nil
+}
+
+func (c *Endpoint) getGatherComplete() <-chan struct{} {
+	c.gatherCompleteMu.Lock()
+	defer c.gatherCompleteMu.Unlock()
+	return c.gatherComplete
+}
+
+func (c *Endpoint) localDescription(span trace.Span) *webrtc.SessionDescription {
+	offer := c.peerConnection.LocalDescription()
+	if c.endpointType == EgressEndpoint {
 		var err error
-		offer := c.peerConnection.LocalDescription()
-		if c.endpointType == EgressEndpoint {
-			offer, err = setEgressTrackInfo(c.peerConnection.LocalDescription(), c.trackSdpInfoRepository)
-			if err != nil {
-				slog.Error("rtp.establish_egress:: sender doRenegotiation dc", "err", err)
-				span.RecordError(err)
-			}
-			slog.Debug("#### GetLocalDescription", "offer", offer.SDP)
+		offer, err = setEgressTrackInfo(c.peerConnection.LocalDescription(), c.trackSdpInfoRepository)
+		if err != nil {
+			slog.Error("rtp.establish_egress:: sender doRenegotiation dc", "err", err)
+			span.RecordError(err)
 		}
-		return offer, nil
+		slog.Debug("#### GetLocalDescription", "offer", offer.SDP)
+	}
+	return offer
+}
+
+// HasRemoteIceUfrag returns true, if the remote peer uses the ICE username fragment for the connection
+func (c *Endpoint) HasRemoteIceUfrag(ufrag string) bool {
+	remote := c.peerConnection.RemoteDescription()
+	if remote == nil {
+		return false
+	}
+	frag, err := ParseSdpFragment(remote.SDP)
+	if err != nil {
+		return false
+	}
+	return frag.IceUfrag == ufrag
+}
+
+// GetIceSessionETag returns the entity tag of the current ICE session of the remote peer
+func (c *Endpoint) GetIceSessionETag() string {
+	remote := c.peerConnection.RemoteDescription()
+	if remote == nil {
+		return ""
+	}
+	frag, err := ParseSdpFragment(remote.SDP)
+	if err != nil {
+		return ""
+	}
+	return IceSessionETag(frag.IceUfrag)
+}
+
+// IsIceConnected returns true, as long as the ICE connection is not disconnected, failed or closed
+func (c *Endpoint) IsIceConnected() bool {
+	state := c.peerConnection.ICEConnectionState()
+	return state == webrtc.ICEConnectionStateConnected || state == webrtc.ICEConnectionStateCompleted
+}
+
+// AddIceCandidates adds the trickled candidates of the remote peer
+func (c *Endpoint) AddIceCandidates(frag *SdpFragment) error {
+	for _, candidate := range frag.CandidateInits() {
+		if err := c.peerConnection.AddICECandidate(candidate); err != nil {
+			return fmt.Errorf("adding ice candidate: %w", err)
+		}
+	}
+	return nil
+}
+
+// RestartIce restarts ICE with the new credentials and the candidates of the remote peer.
+// It returns the new local credentials and candidates, after the ICE gathering is complete.
+func (c *Endpoint) RestartIce(ctx context.Context, frag *SdpFragment) (*SdpFragment, error) {
+	remote := c.peerConnection.RemoteDescription()
+	if remote == nil {
+		return nil, errors.New("restarting ice without remote description")
+	}
+	// the remote session with the new credentials is an offer, if the remote peer offered the session,
+	// otherwise the endpoint offers new credentials and the remote session is its answer
+	restart := c.restartIceAsAnswerer
+	if remote.Type == webrtc.SDPTypeAnswer {
+		restart = c.restartIceAsOfferer
+	}
+	// the new credentials can not be gathered, while the candidates of the former credentials are still gathered
+	if pc := c.getPeerConnection(); pc != nil && pc.ICEGatheringState() == webrtc.ICEGatheringStateGathering {
+		select {
+		case <-webrtc.GatheringCompletePromise(pc):
+		case <-ctx.Done():
+			return nil, ErrIceGatheringInterruption
+		}
+	}
+	if err := restart(replaceIceCredentials(remote.SDP, frag.IceUfrag, frag.IcePwd)); err != nil {
+		return nil, err
+	}
+	if err := c.AddIceCandidates(frag); err != nil {
+		return nil, err
+	}
+	local, err := c.GetLocalDescription(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSdpFragment(local.SDP)
+}
+
+func (c *Endpoint) restartIceAsAnswerer(remoteSdp string) error {
+	if err := c.peerConnection.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: remoteSdp}); err != nil {
+		return fmt.Errorf("set ice restart offer: %w", err)
+	}
+	c.gatherCompleteMu.Lock()
+	c.gatherComplete = webrtc.GatheringCompletePromise(c.getPeerConnection())
+	c.gatherCompleteMu.Unlock()
+	answer, err := c.peerConnection.CreateAnswer(nil)
+	if err != nil {
+		return fmt.Errorf("create ice restart answer: %w", err)
+	}
+	if err = c.peerConnection.SetLocalDescription(answer); err != nil {
+		return fmt.Errorf("set ice restart answer: %w", err)
+	}
+	return nil
+}
+
+func (c *Endpoint) restartIceAsOfferer(remoteSdp string) error {
+	offer, err := c.peerConnection.CreateOffer(&webrtc.OfferOptions{ICERestart: true})
+	if err != nil {
+		return fmt.Errorf("create ice restart offer: %w", err)
+	}
+	c.gatherCompleteMu.Lock()
+	c.gatherComplete = webrtc.GatheringCompletePromise(c.getPeerConnection())
+	c.gatherCompleteMu.Unlock()
+	if err = c.peerConnection.SetLocalDescription(offer); err != nil {
+		return fmt.Errorf("set ice restart offer: %w", err)
+	}
+	if err = c.peerConnection.SetRemoteDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeAnswer, SDP: remoteSdp}); err != nil {
+		return fmt.Errorf("set ice restart answer: %w", err)
+	}
+	return nil
+}
+
+// CreateIceRestartOffer creates an offer with new ICE credentials, the remote peer restarts ICE with its answer.
+// Only an egress endpoint that completed its first offer answer exchange can restart ICE.
+// It returns the offer after the ICE gathering is complete, because the offer is signaled over the data channel.
+func (c *Endpoint) CreateIceRestartOffer(ctx context.Context) (*webrtc.SessionDescription, error) {
+	_, span := rtpTrace(ctx, "endpoint_create_ice_restart_offer")
+	defer span.End()
+	if c.endpointType != EgressEndpoint || !c.IsInitComplete() {
+		return nil, errors.New("restarting ice without established egress endpoint")
+	}
+
+	offer, err := c.peerConnection.CreateOffer(&webrtc.OfferOptions{ICERestart: true})
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("create ice restart offer: %w", err)
+	}
+	gatherComplete := webrtc.GatheringCompletePromise(c.getPeerConnection())
+	if err = c.peerConnection.SetLocalDescription(offer); err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("set ice restart offer: %w", err)
+	}
+	select {
+	case <-gatherComplete:
 	case <-c.sessionCxt.Done():
 		span.RecordError(ErrSessionClosed)
 		return nil, ErrSessionClosed
@@ -98,12 +306,16 @@ func (c *Endpoint) GetLocalDescription(ctx context.Context) (*webrtc.SessionDesc
 		span.RecordError(ErrIceGatheringInterruption)
 		return nil, ErrIceGatheringInterruption
 	}
+	return c.localDescription(span), nil
 }
+
 func (c *Endpoint) SetAnswer(sdp *webrtc.SessionDescription) error {
 	return c.peerConnection.SetRemoteDescription(*sdp)
 }
 
-func (c *Endpoint) SetNewOffer(sdp *webrtc.SessionDescription) (*webrtc.SessionDescription, error) {
+// SetNewOffer answers an offer of the remote peer. If the offer restarts ICE, the answer contains the new candidates,
+// because the answer is signaled over the data channel. The ctx limits the waiting for the ICE gathering.
+func (c *Endpoint) SetNewOffer(ctx context.Context, sdp *webrtc.SessionDescription) (*webrtc.SessionDescription, error) {
 
 	err := getIngressTrackSdpInfo(*sdp, uuid.MustParse(c.sessionId), c.trackSdpInfoRepository)
 
@@ -111,6 +323,8 @@ func (c *Endpoint) SetNewOffer(sdp *webrtc.SessionDescription) (*webrtc.SessionD
 		return nil, fmt.Errorf("set new offer: %w", err)
 	}
 
+	// new ICE credentials in the offer restart the ICE gathering
+	gatherComplete := webrtc.GatheringCompletePromise(c.getPeerConnection())
 	answer, err := c.peerConnection.CreateAnswer(nil)
 	if err != nil {
 		return nil, err
@@ -118,7 +332,14 @@ func (c *Endpoint) SetNewOffer(sdp *webrtc.SessionDescription) (*webrtc.SessionD
 	if err = c.peerConnection.SetLocalDescription(answer); err != nil {
 		return nil, err
 	}
-	return &answer, nil
+	select {
+	case <-gatherComplete:
+	case <-c.sessionCxt.Done():
+		return nil, ErrSessionClosed
+	case <-ctx.Done():
+		return nil, ErrIceGatheringInterruption
+	}
+	return c.peerConnection.LocalDescription(), nil
 }
 
 func (c *Endpoint) SetInitComplete() {
@@ -199,6 +420,12 @@ func (c *Endpoint) AddTrack(ctx context.Context, info *TrackInfo) {
 		}
 		c.trackSdpInfoRepository.Set(info.Id, &sdpTrack)
 
+		c.videoQualityMu.Lock()
+		c.selectSimulcastLayer(sender, c.videoQuality)
+		c.videoQualityMu.Unlock()
+
+		go c.readRtcp(sender, c.setFeedback(sender, info.feedback))
+
 		// collect stats
 		if c.statsRegistry != nil {
 			labels := metric.Labels{
@@ -218,6 +445,25 @@ func (c *Endpoint) AddTrack(ctx context.Context, info *TrackInfo) {
 	}
 }
 
+// readRtcp reads the rtcp of the subscriber until the sender stops.
+// Reading is required in any case, because the interceptors (NACK responder, reports) process the packets while reading.
+func (c *Endpoint) readRtcp(sender *webrtc.RTPSender, feedback *atomic.Pointer[rtcpForwarder]) {
+	var egressSsrc uint32
+	if encodings := sender.GetParameters().Encodings; len(encodings) > 0 {
+		egressSsrc = uint32(encodings[0].SSRC)
+	}
+	for {
+		packets, _, err := sender.ReadRTCP()
+		if err != nil {
+			slog.Debug("rtp.endpoint: stop reading rtcp", "sessionId", c.sessionId, "ssrc", egressSsrc, "err", err)
+			return
+		}
+		if forwarder := feedback.Load(); forwarder != nil {
+			forwarder.onEgressRtcp(egressSsrc, packets)
+		}
+	}
+}
+
 func (c *Endpoint) RemoveTrack(ctx context.Context, info *TrackInfo) {
 	_, span := rtpTrace(ctx, "endpoint_remove_track")
 	defer span.End()
@@ -226,6 +472,7 @@ func (c *Endpoint) RemoveTrack(ctx context.Context, info *TrackInfo) {
 
 	if sender, has := c.getSender(track); has {
 		c.trackSdpInfoRepository.Delete(info.GetId())
+		c.deleteFeedback(sender)
 
 		span.AddEvent("Remove Track from Connection", trace.WithAttributes(
 			attribute.String("localTrack", track.ID())),
@@ -244,6 +491,66 @@ func (c *Endpoint) RemoveTrack(ctx context.Context, info *TrackInfo) {
 	}
 }
 
+// ReplaceTrack swaps the track of an egress sender without renegotiation, the sender keeps its mid.
+// It returns false if the old track is not sent or the new track can not be sent with the negotiated codec.
+func (c *Endpoint) ReplaceTrack(ctx context.Context, oldInfo *TrackInfo, newInfo *TrackInfo) (*TrackInfo, bool) {
+	_, span := rtpTrace(ctx, "endpoint_replace_track")
+	defer span.End()
+	oldTrack, newTrack := oldInfo.GetTrackLocal(), newInfo.GetTrackLocal()
+	if oldTrack.Kind() != newTrack.Kind() || c.hasTrack(newTrack) {
+		return nil, false
+	}
+	sender, has := c.getSender(oldTrack)
+	if !has {
+		return nil, false
+	}
+	if err := sender.ReplaceTrack(newTrack); err != nil {
+		slog.Debug("rtp.endpoint: replace track", "err", err, "oldTrackId", oldTrack.ID(), "newTrackId", newTrack.ID())
+		return nil, false
+	}
+	slog.Debug("rtp.endpoint: replaced track", "oldTrackId", oldTrack.ID(), "newTrackId", newTrack.ID())
+
+	sdpTrack := newInfo.TrackSdpInfo
+	sdpTrack.EgressTrackId = newTrack.ID()
+	for _, transceiver := range c.peerConnection.GetTransceivers() {
+		if tSender := transceiver.Sender(); tSender == sender {
+			sdpTrack.EgressMid = transceiver.Mid()
+			break
+		}
+	}
+	c.trackSdpInfoRepository.Delete(oldInfo.GetId())
+	c.trackSdpInfoRepository.Set(newInfo.GetId(), &sdpTrack)
+	c.setFeedback(sender, newInfo.feedback)
+
+	c.videoQualityMu.Lock()
+	c.selectSimulcastLayer(sender, c.videoQuality)
+	c.videoQualityMu.Unlock()
+	// the subscriber can only decode the new track from a keyframe on
+	newInfo.RequestKeyframe()
+	return newTrackInfo(newTrack, sdpTrack), true
+}
+
+func (c *Endpoint) setFeedback(sender *webrtc.RTPSender, forwarder *rtcpForwarder) *atomic.Pointer[rtcpForwarder] {
+	c.feedbacksMu.Lock()
+	defer c.feedbacksMu.Unlock()
+	if c.feedbacks == nil {
+		c.feedbacks = make(map[*webrtc.RTPSender]*atomic.Pointer[rtcpForwarder])
+	}
+	feedback, found := c.feedbacks[sender]
+	if !found {
+		feedback = &atomic.Pointer[rtcpForwarder]{}
+		c.feedbacks[sender] = feedback
+	}
+	feedback.Store(forwarder)
+	return feedback
+}
+
+func (c *Endpoint) deleteFeedback(sender *webrtc.RTPSender) {
+	c.feedbacksMu.Lock()
+	defer c.feedbacksMu.Unlock()
+	delete(c.feedbacks, sender)
+}
+
 func (c *Endpoint) SetIngressMute(ingressMid string, mute bool) (*TrackInfo, bool) {
 	if sdpInfo, ok := c.trackSdpInfoRepository.getTrackSdpInfoByIngressMid(ingressMid); ok {
 		sdpInfo.Mute = mute
@@ -252,6 +559,16 @@ func (c *Endpoint) SetIngressMute(ingressMid string, mute bool) (*TrackInfo, boo
 	return nil, false
 }
 
+// SetIngressTrackMute mutes a received track by its track id, like the tracks a remote instance announces muted.
+// It returns false, if the track is unknown or the mute state did not change.
+func (c *Endpoint) SetIngressTrackMute(ingressTrackId string, mute bool) (*TrackInfo, bool) {
+	if sdpInfo, ok := c.trackSdpInfoRepository.getSdpInfoByIngressTrackId(ingressTrackId); ok && sdpInfo.Mute != mute {
+		sdpInfo.Mute = mute
+		return newTrackInfo(nil, *sdpInfo), true
+	}
+	return nil, false
+}
+
 func (c *Endpoint) SetEgressMute(infoId uuid.UUID, mute bool) (*TrackInfo, bool) {
 	if sdpInfo, ok := c.trackSdpInfoRepository.Get(infoId); ok {
 		sdpInfo.Mute = mute
@@ -260,6 +577,149 @@ func (c *Endpoint) SetEgressMute(infoId uuid.UUID, mute bool) (*TrackInfo, bool)
 	return nil, false
 }
 
+// GetEgressMids returns the mids of the egress tracks of a source session
+func (c *Endpoint) GetEgressMids(sessionId uuid.UUID) []string {
+	mids := make([]string, 0)
+	for _, info := range c.trackSdpInfoRepository.getTrackSdpInfos() {
+		if info.SessionId == sessionId && info.EgressMid != "" {
+			mids = append(mids, info.EgressMid)
+		}
+	}
+	sort.Strings(mids)
+	return mids
+}
+
+// GetIngressMids returns the mids of the received tracks of a kind, all mids if the kind is unknown
+func (c *Endpoint) GetIngressMids(kind webrtc.RTPCodecType) []string {
+	mids := make([]string, 0)
+	for _, transceiver := range c.peerConnection.GetTransceivers() {
+		if transceiver.Mid() == "" || (kind != 0 && transceiver.Kind() != kind) {
+			continue
+		}
+		if _, ok := c.trackSdpInfoRepository.getTrackSdpInfoByIngressMid(transceiver.Mid()); ok {
+			mids = append(mids, transceiver.Mid())
+		}
+	}
+	sort.Strings(mids)
+	return mids
+}
+
+// GetEgressMid returns the mid of an egress track, the mid is empty until the track was negotiated
+func (c *Endpoint) GetEgressMid(infoId uuid.UUID) string {
+	if sdpInfo, ok := c.trackSdpInfoRepository.Get(infoId); ok {
+		return sdpInfo.EgressMid
+	}
+	return ""
+}
+
+// SetVideoQuality selects the simulcast layer of an egress track for this endpoint.
+// The layer is switched with the next keyframe of the selected layer.
+func (c *Endpoint) SetVideoQuality(infoId uuid.UUID, quality VideoQuality) bool {
+	sdpInfo, ok := c.trackSdpInfoRepository.Get(infoId)
+	if !ok {
+		return false
+	}
+	for _, sender := range c.peerConnection.GetSenders() {
+		if track := sender.Track(); track != nil && track.ID() == sdpInfo.EgressTrackId {
+			return c.selectSimulcastLayer(sender, quality)
+		}
+	}
+	return false
+}
+
+// SetVideoQualityForAll selects the simulcast layer of all egress tracks for this endpoint
+func (c *Endpoint) SetVideoQualityForAll(quality VideoQuality) {
+	c.videoQualityMu.Lock()
+	defer c.videoQualityMu.Unlock()
+	c.videoQuality = quality
+	for _, sender := range c.peerConnection.GetSenders() {
+		c.selectSimulcastLayer(sender, quality)
+	}
+}
+
+// GetTargetBitrate returns the estimated bandwidth to the subscriber in bit per second (bps).
+// It returns 0, if no estimation exists, for example for ingress endpoints.
+func (c *Endpoint) GetTargetBitrate() int {
+	estimator := c.getBandwidthEstimator()
+	if estimator == nil {
+		return 0
+	}
+	return estimator.GetTargetBitrate()
+}
+
+func (c *Endpoint) getBandwidthEstimator() cc.BandwidthEstimator {
+	c.bandwidthEstimatorMu.Lock()
+	defer c.bandwidthEstimatorMu.Unlock()
+	return c.bandwidthEstimator
+}
+
+func (c *Endpoint) setBandwidthEstimator(estimator cc.BandwidthEstimator) {
+	c.bandwidthEstimatorMu.Lock()
+	c.bandwidthEstimator = estimator
+	c.bandwidthEstimatorMu.Unlock()
+	estimator.OnTargetBitrateChange(c.targetBitrateChanged)
+	if c.qualityAdapter != nil {
+		go c.runVideoQualityProbes()
+	}
+}
+
+// runVideoQualityProbes probes the higher simulcast layers until the session ends,
+// because the bandwidth estimation never exceeds the bitrate of the current layers by much
+func (c *Endpoint) runVideoQualityProbes() {
+	ticker := time.NewTicker(probeCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.sessionCxt.Done():
+			return
+		case <-ticker.C:
+			c.probeVideoQuality()
+		}
+	}
+}
+
+func (c *Endpoint) probeVideoQuality() {
+	if quality, changed := c.qualityAdapter.probe(); changed {
+		slog.Info("rtp.endpoint: probe video quality", "sessionId", c.sessionId, "quality", quality)
+		c.SetVideoQualityForAll(quality)
+	}
+}
+
+func (c *Endpoint) targetBitrateChanged(bitrate int) {
+	slog.Debug("rtp.endpoint: target bitrate changed", "sessionId", c.sessionId, "type", c.endpointType, "bitrate", bitrate)
+	if c.qualityAdapter != nil {
+		if quality, changed := c.qualityAdapter.update(bitrate, c.countVideoSender()); changed {
+			slog.Info("rtp.endpoint: adapt video quality to bandwidth", "sessionId", c.sessionId, "bitrate", bitrate, "quality", quality)
+			c.SetVideoQualityForAll(quality)
+		}
+	}
+	if c.onTargetBitrateChange != nil {
+		c.onTargetBitrateChange(bitrate)
+	}
+}
+
+func (c *Endpoint) countVideoSender() int {
+	count := 0
+	for _, sender := range c.peerConnection.GetSenders() {
+		if track := sender.Track(); track != nil && track.Kind() == webrtc.RTPCodecTypeVideo {
+			count++
+		}
+	}
+	return count
+}
+
+func (c *Endpoint) selectSimulcastLayer(sender *webrtc.RTPSender, quality VideoQuality) bool {
+	track, ok := sender.Track().(*simulcastTrack)
+	if !ok {
+		return false
+	}
+	slog.Debug("rtp.endpoint: select simulcast layer", "sessionId", c.sessionId, "trackId", track.ID(), "quality", quality)
+	for _, param := range sender.GetParameters().Encodings {
+		track.setQuality(param.SSRC, quality)
+	}
+	return true
+}
+
 func (c *Endpoint) doRenegotiation() {
 	if c.onNegotiationNeeded == nil {
 		return
@@ -296,6 +756,9 @@ func (c *Endpoint) doRenegotiation() {
 
 func (c *Endpoint) onICEConnectionStateChange(state webrtc.ICEConnectionState) {
 	slog.Debug("rtp.endpoint: ice state:", "state", state, "sessionId", c.sessionId, "type", c.endpointType)
+	if c.onIceStateChange != nil {
+		c.onIceStateChange(state)
+	}
 
 	if state == webrtc.ICEConnectionStateFailed {
 		slog.Warn("rtp.endpoint: endpoint become idle", "sessionId", c.sessionId, "type", c.endpointType)
@@ -347,6 +810,9 @@ type peerConnection interface {
 	LocalDescription() *webrtc.SessionDescription
 	SetLocalDescription(desc webrtc.SessionDescription) error
 	SetRemoteDescription(desc webrtc.SessionDescription) error
+	RemoteDescription() *webrtc.SessionDescription
+	AddICECandidate(candidate webrtc.ICECandidateInit) error
+	ICEConnectionState() webrtc.ICEConnectionState
 	GetSenders() (result []*webrtc.RTPSender)
 	GetTransceivers() []*webrtc.RTPTransceiver
 	AddTrack(track webrtc.TrackLocal) (*webrtc.RTPSender, error)
@@ -358,6 +824,7 @@ type peerConnection interface {
 	OnICEConnectionStateChange(f func(webrtc.ICEConnectionState))
 	OnNegotiationNeeded(f func())
 	OnDataChannel(func(*webrtc.DataChannel))
+	WriteRTCP(pkts []rtcp.Packet) error
 	Close() error
 }
 
@@ -3,6 +3,7 @@ package rtp
 import (
 	"context"
 
+	"github.com/pion/rtcp"
 	"github.com/pion/webrtc/v3"
 )
 
@@ -38,6 +39,13 @@ func (m *mockPeerConnector) SetLocalDescription(_ webrtc.SessionDescription) err
 func (m *mockPeerConnector) SetRemoteDescription(_ webrtc.SessionDescription) error {
 	return nil
 }
+func (m *mockPeerConnector) RemoteDescription() *webrtc.SessionDescription {
+	return nil
+}
+func (m *mockPeerConnector) AddICECandidate(_ webrtc.ICECandidateInit) error { return nil }
+func (m *mockPeerConnector) ICEConnectionState() webrtc.ICEConnectionState {
+	return webrtc.ICEConnectionStateNew
+}
 func (m *mockPeerConnector) GetSenders() []*webrtc.RTPSender {
 	return m.RTPSender
 }
@@ -64,3 +72,4 @@ func (m *mockPeerConnector) Close() error {
 }
 func (m *mockPeerConnector) OnTrack(f func(*webrtc.TrackRemote, *webrtc.RTPReceiver)) {}
 func (m *mockPeerConnector) OnDataChannel(f func(*webrtc.DataChannel))                {}
+func (m *mockPeerConnector) WriteRTCP(_ []rtcp.Packet) error                          { return nil }
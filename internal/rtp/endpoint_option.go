@@ -56,6 +56,13 @@ func EndpointWithonIceStateConnectedListener(onIceStateConnected func()) func(en
 	}
 }
 
+// EndpointWithIceStateListener receives every change of the ICE connection state
+func EndpointWithIceStateListener(onIceStateChange func(state webrtc.ICEConnectionState)) func(endpoint *Endpoint) {
+	return func(endpoint *Endpoint) {
+		endpoint.onIceStateChange = onIceStateChange
+	}
+}
+
 func EndpointWithDataChannel(f func(dc *webrtc.DataChannel)) func(endpoint *Endpoint) {
 	return func(endpoint *Endpoint) {
 		endpoint.onChannel = f
@@ -67,3 +74,22 @@ func EndpointWithTrackDispatcher(dispatcher TrackDispatcher) func(endpoint *Endp
 		endpoint.dispatcher = dispatcher
 	}
 }
+
+func EndpointWithTargetBitrateListener(f func(bitrate int)) func(endpoint *Endpoint) {
+	return func(endpoint *Endpoint) {
+		endpoint.onTargetBitrateChange = f
+	}
+}
+
+func EndpointWithAdaptiveVideoQuality() func(endpoint *Endpoint) {
+	return func(endpoint *Endpoint) {
+		endpoint.qualityAdapter = newVideoQualityAdapter()
+	}
+}
+
+// EndpointWithAudioLevelListener receives the RFC 6464 audio levels of the ingress audio tracks
+func EndpointWithAudioLevelListener(f func(level uint8)) func(endpoint *Endpoint) {
+	return func(endpoint *Endpoint) {
+		endpoint.onAudioLevel = f
+	}
+}
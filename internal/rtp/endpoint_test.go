@@ -0,0 +1,267 @@
+package rtp
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pion/interceptor/pkg/cc"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+// testEgressEndpointSetup establishes an egress endpoint with the offer of a remote peer, like the first offer of a client
+func testEgressEndpointSetup(t *testing.T) (*Endpoint, *webrtc.PeerConnection, func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	remote, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	assert.NoError(t, err)
+	_, err = remote.CreateDataChannel("data", nil)
+	assert.NoError(t, err)
+	offer, err := remote.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, remote.SetLocalDescription(offer))
+
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	assert.NoError(t, err)
+	endpoint := newEndpoint(ctx, uuid.NewString(), uuid.NewString(), EgressEndpoint)
+	endpoint.peerConnection = pc
+	assert.NoError(t, pc.SetRemoteDescription(offer))
+	gatherComplete := webrtc.GatheringCompletePromise(pc)
+	answer, err := pc.CreateAnswer(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, pc.SetLocalDescription(answer))
+	<-gatherComplete
+	assert.NoError(t, remote.SetRemoteDescription(*pc.LocalDescription()))
+
+	return endpoint, remote, func() {
+		cancel()
+		_ = remote.Close()
+	}
+}
+
+// testOfferedEndpointSetup establishes an egress endpoint that offered the session to a remote peer, like a WHEP resource offered by the server
+func testOfferedEndpointSetup(t *testing.T) (*Endpoint, func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	assert.NoError(t, err)
+	endpoint := newEndpoint(ctx, uuid.NewString(), uuid.NewString(), EgressEndpoint)
+	endpoint.peerConnection = pc
+	_, err = pc.CreateDataChannel("data", nil)
+	assert.NoError(t, err)
+	offer, err := pc.CreateOffer(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, pc.SetLocalDescription(offer))
+
+	remote, err := webrtc.NewPeerConnection(webrtc.Configuration{})
+	assert.NoError(t, err)
+	assert.NoError(t, remote.SetRemoteDescription(offer))
+	answer, err := remote.CreateAnswer(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, remote.SetLocalDescription(answer))
+	assert.NoError(t, pc.SetRemoteDescription(answer))
+
+	return endpoint, func() {
+		cancel()
+		_ = remote.Close()
+	}
+}
+
+func TestEndpoint_RestartIce(t *testing.T) {
+	restart := &SdpFragment{IceUfrag: "restartufrag", IcePwd: "restartpasswordrestartpassword"}
+
+	t.Run("restart ice of a session the remote peer offered", func(t *testing.T) {
+		endpoint, _, stop := testEgressEndpointSetup(t)
+		defer stop()
+		before, _ := ParseSdpFragment(endpoint.peerConnection.LocalDescription().SDP)
+
+		local, err := endpoint.RestartIce(context.Background(), restart)
+
+		assert.NoError(t, err)
+		assert.NotEqual(t, before.IceUfrag, local.IceUfrag)
+		assert.Equal(t, webrtc.SDPTypeOffer, endpoint.peerConnection.RemoteDescription().Type)
+		assert.Contains(t, endpoint.peerConnection.RemoteDescription().SDP, "a=ice-ufrag:restartufrag")
+		assert.Equal(t, webrtc.SignalingStateStable, endpoint.peerConnection.SignalingState())
+	})
+
+	t.Run("restart ice of a session the endpoint offered", func(t *testing.T) {
+		endpoint, stop := testOfferedEndpointSetup(t)
+		defer stop()
+		before, _ := ParseSdpFragment(endpoint.peerConnection.LocalDescription().SDP)
+
+		local, err := endpoint.RestartIce(context.Background(), restart)
+
+		assert.NoError(t, err)
+		assert.NotEqual(t, before.IceUfrag, local.IceUfrag)
+		assert.Equal(t, webrtc.SDPTypeAnswer, endpoint.peerConnection.RemoteDescription().Type)
+		assert.Contains(t, endpoint.peerConnection.RemoteDescription().SDP, "a=ice-ufrag:restartufrag")
+		assert.Equal(t, webrtc.SignalingStateStable, endpoint.peerConnection.SignalingState())
+	})
+}
+
+func TestEndpoint_CreateIceRestartOffer(t *testing.T) {
+	t.Run("reject ice restart before the first offer answer exchange is complete", func(t *testing.T) {
+		endpoint, _, stop := testEgressEndpointSetup(t)
+		defer stop()
+
+		_, err := endpoint.CreateIceRestartOffer(context.Background())
+		assert.Error(t, err)
+	})
+
+	t.Run("offer new ice credentials", func(t *testing.T) {
+		endpoint, remote, stop := testEgressEndpointSetup(t)
+		defer stop()
+		endpoint.SetInitComplete()
+		before, err := ParseSdpFragment(endpoint.peerConnection.LocalDescription().SDP)
+		assert.NoError(t, err)
+
+		offer, err := endpoint.CreateIceRestartOffer(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, webrtc.SDPTypeOffer, offer.Type)
+		after, err := ParseSdpFragment(offer.SDP)
+		assert.NoError(t, err)
+		assert.NotEqual(t, before.IceUfrag, after.IceUfrag)
+		assert.NotEqual(t, before.IcePwd, after.IcePwd)
+
+		// the remote peer answers the restart offer like every other offer
+		assert.NoError(t, remote.SetRemoteDescription(*offer))
+		answer, err := remote.CreateAnswer(nil)
+		assert.NoError(t, err)
+		assert.NoError(t, remote.SetLocalDescription(answer))
+		assert.NoError(t, endpoint.SetAnswer(&answer))
+	})
+}
+
+func TestEndpoint_SetNewOffer(t *testing.T) {
+	t.Run("answer an ice restart offer of the remote peer", func(t *testing.T) {
+		endpoint, remote, stop := testEgressEndpointSetup(t)
+		defer stop()
+		before, err := ParseSdpFragment(endpoint.peerConnection.LocalDescription().SDP)
+		assert.NoError(t, err)
+		offer, err := remote.CreateOffer(&webrtc.OfferOptions{ICERestart: true})
+		assert.NoError(t, err)
+		assert.NoError(t, remote.SetLocalDescription(offer))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		answer, err := endpoint.SetNewOffer(ctx, &offer)
+
+		assert.NoError(t, err)
+		after, err := ParseSdpFragment(answer.SDP)
+		assert.NoError(t, err)
+		assert.NotEqual(t, before.IceUfrag, after.IceUfrag)
+		assert.NoError(t, remote.SetRemoteDescription(*answer))
+	})
+}
+
+func TestEndpoint_GetLocalCandidates(t *testing.T) {
+	answer := &webrtc.SessionDescription{Type: webrtc.SDPTypeAnswer, SDP: "v=0\r\nm=audio 9 UDP/TLS/RTP/SAVPF 111\r\na=ice-ufrag:38sdf4fdsf54\r\na=ice-pwd:2e13dde17c1cb009202f627fab90cbec358d766d049c9697\r\na=mid:0\r\na=candidate:1 1 UDP 2130706431 198.51.100.1 39132 typ host\r\n"}
+
+	t.Run("return the candidates gathered so far", func(t *testing.T) {
+		endpoint := NewMockConnection(MockConnectionOps{Answer: answer, GatherComplete: make(chan struct{})})
+
+		local, err := endpoint.GetLocalCandidates()
+
+		assert.NoError(t, err)
+		assert.Equal(t, "38sdf4fdsf54", local.IceUfrag)
+		assert.Equal(t, []string{"candidate:1 1 UDP 2130706431 198.51.100.1 39132 typ host"}, local.Media[0].Candidates)
+		assert.False(t, local.Media[0].EndOfCandidates)
+	})
+
+	t.Run("end the candidates after the gathering is complete", func(t *testing.T) {
+		gatherComplete := make(chan struct{})
+		close(gatherComplete)
+		endpoint := NewMockConnection(MockConnectionOps{Answer: answer, GatherComplete: gatherComplete})
+
+		local, err := endpoint.GetLocalCandidates()
+
+		assert.NoError(t, err)
+		assert.True(t, local.Media[0].EndOfCandidates)
+		assert.Contains(t, local.String(), "a=end-of-candidates")
+	})
+}
+
+// testBandwidthEstimator is a stand-in for the congestion control, the test changes the estimation
+type testBandwidthEstimator struct {
+	cc.BandwidthEstimator
+	mu       sync.Mutex
+	bitrate  int
+	onChange func(bitrate int)
+}
+
+func (e *testBandwidthEstimator) GetTargetBitrate() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.bitrate
+}
+
+func (e *testBandwidthEstimator) OnTargetBitrateChange(f func(bitrate int)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.onChange = f
+}
+
+func (e *testBandwidthEstimator) change(bitrate int) {
+	e.mu.Lock()
+	e.bitrate = bitrate
+	onChange := e.onChange
+	e.mu.Unlock()
+	onChange(bitrate)
+}
+
+func testVideoQuality(endpoint *Endpoint) VideoQuality {
+	endpoint.videoQualityMu.Lock()
+	defer endpoint.videoQualityMu.Unlock()
+	return endpoint.videoQuality
+}
+
+func TestEndpoint_AdaptVideoQuality(t *testing.T) {
+	endpoint, _, stop := testEgressEndpointSetup(t)
+	defer stop()
+	adapter, advance := testVideoQualityAdapterSetup(t)
+	endpoint.qualityAdapter = adapter
+	estimator := &testBandwidthEstimator{}
+	assert.Equal(t, 0, endpoint.GetTargetBitrate())
+	endpoint.setBandwidthEstimator(estimator)
+
+	// the estimation drops, the video is paused
+	estimator.change(80_000)
+	assert.Equal(t, 80_000, endpoint.GetTargetBitrate())
+	assert.Equal(t, VideoQuality_OFF, testVideoQuality(endpoint))
+
+	// without video the estimation stays at 1.5 times the audio bitrate, so the low layer is probed
+	estimator.change(90_000)
+	assert.Equal(t, VideoQuality_OFF, testVideoQuality(endpoint))
+	advance(probeInterval)
+	endpoint.probeVideoQuality()
+	assert.Equal(t, VideoQuality_LOW, testVideoQuality(endpoint))
+
+	// the estimation follows the probed layer and rises up to the high layer
+	estimator.change(150_000)
+	assert.Equal(t, VideoQuality_LOW, testVideoQuality(endpoint))
+	advance(probeDuration)
+	estimator.change(2_000_000)
+	assert.Equal(t, VideoQuality_LOW, testVideoQuality(endpoint))
+	advance(upgradeHoldTime)
+	estimator.change(2_100_000)
+	assert.Equal(t, VideoQuality_HIGH, testVideoQuality(endpoint))
+}
+
+func TestEndpoint_SetIngressTrackMute(t *testing.T) {
+	repo := newTrackSdpInfoRepository()
+	id := uuid.New()
+	repo.Set(id, &TrackSdpInfo{Id: id, IngressTrackId: "track"})
+	endpoint := &Endpoint{trackSdpInfoRepository: repo}
+
+	trackInfo, ok := endpoint.SetIngressTrackMute("track", true)
+	assert.True(t, ok)
+	assert.True(t, trackInfo.GetMute())
+
+	_, ok = endpoint.SetIngressTrackMute("track", true)
+	assert.False(t, ok)
+	_, ok = endpoint.SetIngressTrackMute("unknown", true)
+	assert.False(t, ok)
+}
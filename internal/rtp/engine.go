@@ -6,13 +6,23 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/pion/interceptor"
-	"github.com/pion/interceptor/pkg/intervalpli"
+	"github.com/pion/interceptor/pkg/cc"
+	"github.com/pion/interceptor/pkg/gcc"
 	"github.com/pion/interceptor/pkg/stats"
+	"github.com/pion/sdp/v3"
 	"github.com/pion/webrtc/v3"
 	"github.com/shigde/sfu/internal/static"
 	"golang.org/x/exp/slog"
 )
 
+const sdesRepairedRTPStreamIDURI = "urn:ietf:params:rtp-hdrext:sdes:repaired-rtp-stream-id"
+
+// bitrates of the bandwidth estimation in bit per second (bps)
+const (
+	initialBitrate = 1_000_000
+	minBitrate     = 30_000
+)
+
 type Engine struct {
 	config webrtc.Configuration
 }
@@ -36,6 +46,18 @@ func (e *Engine) createApi(apiOptions ...engineApiOption) (*engineApi, error) {
 		return nil, fmt.Errorf("register  default codecs: %w ", err)
 	}
 
+	// Ingress simulcast tracks are identified by their rid, so the header extensions must be known
+	for _, extension := range []string{sdp.SDESMidURI, sdp.SDESRTPStreamIDURI, sdesRepairedRTPStreamIDURI} {
+		if err := m.RegisterHeaderExtension(webrtc.RTPHeaderExtensionCapability{URI: extension}, webrtc.RTPCodecTypeVideo); err != nil {
+			return nil, fmt.Errorf("register simulcast header extension %s: %w ", extension, err)
+		}
+	}
+
+	// The audio levels of the ingress audio tracks are used to detect the active speaker
+	if err := m.RegisterHeaderExtension(webrtc.RTPHeaderExtensionCapability{URI: sdp.AudioLevelURI}, webrtc.RTPCodecTypeAudio); err != nil {
+		return nil, fmt.Errorf("register audio level header extension: %w ", err)
+	}
+
 	var statsInterceptorFactory *stats.InterceptorFactory
 	var err error
 	if api.onStatsGetter != nil {
@@ -58,15 +80,28 @@ func (e *Engine) createApi(apiOptions ...engineApiOption) (*engineApi, error) {
 		return nil, fmt.Errorf("register default interceptors: %w ", err)
 	}
 
-	// Register a intervalpli factory
-	// This interceptor sends a PLI every 3 seconds. A PLI causes a video keyframe to be generated by the sender.
-	// This makes our video seekable and more error resilent, but at a cost of lower picture quality and higher bitrates
-	// A real world application should process incoming RTCP packets from viewers and forward them to senders
-	intervalPliFactory, err := intervalpli.NewReceiverInterceptor()
-	if err != nil {
-		return nil, fmt.Errorf("create interval Pli factory: %w ", err)
+	// Congestion control is only needed for sending (egress) connections.
+	// The Google Congestion Control estimates the bandwidth by the TWCC feedback of the subscriber.
+	// It neither probes nor pads, so the videoQualityAdapter of the endpoint probes higher layers itself.
+	if api.onBandwidthEstimator != nil {
+		congestionController, err := cc.NewInterceptor(func() (cc.BandwidthEstimator, error) {
+			return gcc.NewSendSideBWE(gcc.SendSideBWEInitialBitrate(initialBitrate), gcc.SendSideBWEMinBitrate(minBitrate))
+		})
+		if err != nil {
+			return nil, fmt.Errorf("create congestion controller: %w ", err)
+		}
+		congestionController.OnNewPeerConnection(func(_ string, estimator cc.BandwidthEstimator) {
+			api.onBandwidthEstimator(estimator)
+		})
+		i.Add(congestionController)
+
+		if err = webrtc.ConfigureTWCCHeaderExtensionSender(m, i); err != nil {
+			return nil, fmt.Errorf("configure twcc header extension sender: %w ", err)
+		}
 	}
-	i.Add(intervalPliFactory)
+
+	// No periodic PLI is sent to the publishers. Keyframe requests and NACKs of the viewers are forwarded
+	// to the publishers by the rtcpForwarder of each ingress track instead.
 	if statsInterceptorFactory != nil {
 		i.Add(statsInterceptorFactory)
 	}
@@ -176,6 +211,7 @@ func (e *Engine) NewSignalConnection(ctx context.Context, handler StateEventHand
 		if connectionState == webrtc.ICEConnectionStateConnected {
 			iceConnectedCtxCancel()
 		}
+		handler.OnConnectionStateChange(connectionState)
 	})
 
 	err = creatDC(peerConnection, handler.OnChannel)
@@ -214,6 +250,7 @@ func (e *Engine) NewReceiverConnection(ctx context.Context, offer webrtc.Session
 		slog.Debug("rtp.engine: receiverEndpoint new DataChannel", "label", d.Label(), "id", d.ID())
 		handler.OnChannel(d)
 	})
+	peerConnection.OnICEConnectionStateChange(handler.OnConnectionStateChange)
 	// Allow us to receive 1 audio track, and 1 video track
 	if _, err = peerConnection.AddTransceiverFromKind(webrtc.RTPCodecTypeAudio); err != nil {
 		slog.Error("rtp.engine: .addTransceiverFromKind audio", "err", err)
@@ -1,13 +1,15 @@
 package rtp
 
 import (
+	"github.com/pion/interceptor/pkg/cc"
 	"github.com/pion/interceptor/pkg/stats"
 	"github.com/pion/webrtc/v3"
 )
 
 type engineApi struct {
 	*webrtc.API
-	onStatsGetter func(getter stats.Getter)
+	onStatsGetter        func(getter stats.Getter)
+	onBandwidthEstimator func(estimator cc.BandwidthEstimator)
 }
 
 type engineApiOption func(enginApi *engineApi)
@@ -17,3 +19,9 @@ func withOnStatsGetter(onStatsGetter func(getter stats.Getter)) func(api *engine
 		api.onStatsGetter = onStatsGetter
 	}
 }
+
+func withOnBandwidthEstimator(onBandwidthEstimator func(estimator cc.BandwidthEstimator)) func(api *engineApi) {
+	return func(api *engineApi) {
+		api.onBandwidthEstimator = onBandwidthEstimator
+	}
+}
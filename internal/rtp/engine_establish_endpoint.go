@@ -31,6 +31,7 @@ func establishEndpoint(ctx context.Context, sessionCxt context.Context, e *Engin
 		}
 
 		endpoint.receiver = newReceiver(sessionCxt, sessionId, liveStream, endpoint.dispatcher, endpoint.trackSdpInfoRepository)
+		endpoint.receiver.onAudioLevel = endpoint.onAudioLevel
 	}
 
 	// Setup stats
@@ -43,7 +44,13 @@ func establishEndpoint(ctx context.Context, sessionCxt context.Context, e *Engin
 		endpoint.statsRegistry = statsRegistry
 	})
 
-	api, err := e.createApi(withStatsGetter)
+	apiOptions := []engineApiOption{withStatsGetter}
+	// bandwidth estimation only for egress needed
+	if endpointType == EgressEndpoint {
+		apiOptions = append(apiOptions, withOnBandwidthEstimator(endpoint.setBandwidthEstimator))
+	}
+
+	api, err := e.createApi(apiOptions...)
 	if err != nil {
 		return nil, telemetry.RecordErrorf(span, "creating api", err)
 	}
@@ -54,6 +61,7 @@ func establishEndpoint(ctx context.Context, sessionCxt context.Context, e *Engin
 
 	// receive tracks only needed for ingress
 	if endpoint.receiver != nil {
+		endpoint.receiver.writeRtcp = endpoint.peerConnection.WriteRTCP
 		endpoint.peerConnection.OnTrack(endpoint.receiver.onTrack)
 	}
 
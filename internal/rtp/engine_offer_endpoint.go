@@ -28,6 +28,7 @@ func offerEndpoint(ctx context.Context, sessionCxt context.Context, e *Engine, s
 			return nil, telemetry.RecordErrorf(span, "setup ingress endpoint", errors.New("no track dispatcher found"))
 		}
 		endpoint.receiver = newReceiver(sessionCxt, sessionId, liveStream, endpoint.dispatcher, endpoint.trackSdpInfoRepository)
+		endpoint.receiver.onAudioLevel = endpoint.onAudioLevel
 	}
 
 	// Setup stats
@@ -40,7 +41,13 @@ func offerEndpoint(ctx context.Context, sessionCxt context.Context, e *Engine, s
 		endpoint.statsRegistry = statsRegistry
 	})
 
-	api, err := e.createApi(withStatsGetter)
+	apiOptions := []engineApiOption{withStatsGetter}
+	// bandwidth estimation only for egress needed
+	if endpointType == EgressEndpoint {
+		apiOptions = append(apiOptions, withOnBandwidthEstimator(endpoint.setBandwidthEstimator))
+	}
+
+	api, err := e.createApi(apiOptions...)
 	if err != nil {
 		return nil, telemetry.RecordErrorf(span, "creating api", err)
 	}
@@ -52,6 +59,7 @@ func offerEndpoint(ctx context.Context, sessionCxt context.Context, e *Engine, s
 	endpoint.peerConnection = pc
 	// receive tracks only needed for ingress
 	if endpoint.receiver != nil {
+		endpoint.receiver.writeRtcp = endpoint.peerConnection.WriteRTCP
 		endpoint.peerConnection.OnTrack(endpoint.receiver.onTrack)
 	}
 
@@ -0,0 +1,105 @@
+package rtp
+
+import (
+	"strings"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// isKeyframe checks if a rtp payload starts a new video keyframe.
+// A simulcast layer switch is only possible at this point, because the decoder of the
+// subscriber has no reference frames of the new layer.
+func isKeyframe(mimeType string, payload []byte) bool {
+	switch {
+	case strings.EqualFold(mimeType, webrtc.MimeTypeVP8):
+		return isVP8Keyframe(payload)
+	case strings.EqualFold(mimeType, webrtc.MimeTypeVP9):
+		return isVP9Keyframe(payload)
+	case strings.EqualFold(mimeType, webrtc.MimeTypeH264):
+		return isH264Keyframe(payload)
+	default:
+		return false
+	}
+}
+
+// isVP8Keyframe parses the VP8 payload descriptor (RFC 7741 section 4.2)
+func isVP8Keyframe(payload []byte) bool {
+	if len(payload) < 1 {
+		return false
+	}
+	// only the first partition of a frame (S=1, PID=0) contains the frame header
+	if payload[0]&0x10 == 0 || payload[0]&0x07 != 0 {
+		return false
+	}
+	idx := 1
+	if payload[0]&0x80 != 0 {
+		if len(payload) <= idx {
+			return false
+		}
+		ext := payload[idx]
+		idx++
+		if ext&0x80 != 0 { // I: picture id
+			if len(payload) <= idx {
+				return false
+			}
+			if payload[idx]&0x80 != 0 { // M: 15 bit picture id
+				idx++
+			}
+			idx++
+		}
+		if ext&0x40 != 0 { // L: tl0picidx
+			idx++
+		}
+		if ext&0x20 != 0 || ext&0x10 != 0 { // T or K: tid/keyidx
+			idx++
+		}
+	}
+	if len(payload) <= idx {
+		return false
+	}
+	// P bit of the VP8 payload header is 0 for keyframes
+	return payload[idx]&0x01 == 0
+}
+
+// isVP9Keyframe parses the VP9 payload descriptor (RFC 9628 section 4.2)
+func isVP9Keyframe(payload []byte) bool {
+	if len(payload) < 1 {
+		return false
+	}
+	// not inter-picture predicted (P=0) and start of a frame (B=1)
+	return payload[0]&0x40 == 0 && payload[0]&0x08 != 0
+}
+
+// isH264Keyframe looks for IDR or SPS nal units (RFC 6184)
+func isH264Keyframe(payload []byte) bool {
+	if len(payload) < 1 {
+		return false
+	}
+	switch naluType := payload[0] & 0x1F; naluType {
+	case 5, 7:
+		return true
+	case 24: // STAP-A
+		idx := 1
+		for idx+2 < len(payload) {
+			size := int(payload[idx])<<8 | int(payload[idx+1])
+			idx += 2
+			if idx >= len(payload) {
+				return false
+			}
+			if t := payload[idx] & 0x1F; t == 5 || t == 7 {
+				return true
+			}
+			idx += size
+		}
+		return false
+	case 28: // FU-A
+		if len(payload) < 2 {
+			return false
+		}
+		start := payload[1]&0x80 != 0
+		t := payload[1] & 0x1F
+		return start && (t == 5 || t == 7)
+	default:
+		return false
+	}
+}
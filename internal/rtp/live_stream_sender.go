@@ -5,68 +5,101 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/google/uuid"
-	"github.com/pion/interceptor"
-	"github.com/pion/rtp"
 	"github.com/pion/webrtc/v3"
 	"golang.org/x/exp/slog"
 )
 
+// LiveStreamSender forwards the main stream of a lobby as plain RTP to udp ports, where external tools like
+// ffmpeg can receive it. The ports are allocated per lobby and described in a generated sdp file.
+// The sdp file uses the codecs and payload types negotiated with the publisher, it is rewritten when a track is added.
 type LiveStreamSender struct {
 	ctx          context.Context
 	mu           sync.RWMutex
 	id           uuid.UUID
+	host         string
+	sdpDir       string
+	sdpFile      string
 	audio, video *UdpConnection
+	bindings     map[uuid.UUID]*TrackLocalContext
 	stopRunning  func()
 }
 
-func NewLiveStreamSender(lobbyContext context.Context, id uuid.UUID) (*LiveStreamSender, error) {
+func NewLiveStreamSender(lobbyContext context.Context, id uuid.UUID, config *LiveStreamSenderConfig, ports *PortAllocator) (*LiveStreamSender, error) {
+	audioPort, err := ports.Allocate()
+	if err != nil {
+		return nil, fmt.Errorf("allocating audio port: %w", err)
+	}
+	videoPort, err := ports.Allocate()
+	if err != nil {
+		ports.Release(audioPort)
+		return nil, fmt.Errorf("allocating video port: %w", err)
+	}
+
 	ctx, stop := context.WithCancel(lobbyContext)
 	f := &LiveStreamSender{
 		ctx:         ctx,
 		mu:          sync.RWMutex{},
 		id:          id,
-		audio:       &UdpConnection{port: 4000, payloadType: 111},
-		video:       &UdpConnection{port: 4002, payloadType: 96},
+		host:        config.Host,
+		sdpDir:      config.SdpDir,
+		audio:       &UdpConnection{port: audioPort},
+		video:       &UdpConnection{port: videoPort},
+		bindings:    make(map[uuid.UUID]*TrackLocalContext),
 		stopRunning: stop,
 	}
+	// the ports are released with the lobby
+	context.AfterFunc(ctx, func() {
+		ports.Release(audioPort)
+		ports.Release(videoPort)
+	})
+
+	if err := f.writeSdp(); err != nil {
+		stop()
+		return nil, err
+	}
+	if err := f.connectAll(); err != nil {
+		stop()
+		f.removeSdp()
+		return nil, err
+	}
 
 	go f.Run()
 	return f, nil
 }
 
 func (f *LiveStreamSender) Run() {
-	f.log("run")
-	defer func() {
-		f.log("stop")
-		if err := f.close(); err != nil {
-			slog.Error("forwarder closing udp ports", "err", err, "forwarderID", f.id)
-		}
-	}()
+	f.log("running")
+	<-f.ctx.Done()
+	f.log("stop")
+	f.mu.Lock()
+	f.removeSdp()
+	f.mu.Unlock()
+	if err := f.close(); err != nil {
+		slog.Error("forwarder closing udp ports", "err", err, "forwarderID", f.id)
+	}
+}
 
+func (f *LiveStreamSender) connectAll() error {
 	var err error
 	var laddr *net.UDPAddr
-	if laddr, err = net.ResolveUDPAddr("udp", "127.0.0.1:"); err != nil {
-		slog.Error("forwarder: resolving udp address", "err", err, "forwarderID", f.id.String())
-		return
+	if laddr, err = net.ResolveUDPAddr("udp", "0.0.0.0:"); err != nil {
+		return fmt.Errorf("resolving udp address: %w", err)
 	}
-
 	if err = f.connect(f.audio, laddr); err != nil {
-		slog.Error("forwarder: connecting audio", "err", err, "forwarderID", f.id.String())
-		return
+		return fmt.Errorf("connecting audio: %w", err)
 	}
 	if err = f.connect(f.video, laddr); err != nil {
-		slog.Error("forwarder: connecting video", "err", err, "forwarderID", f.id.String())
-		return
-	}
-
-	f.log("running")
-	select {
-	case <-f.ctx.Done():
+		_ = f.close()
+		return fmt.Errorf("connecting video: %w", err)
 	}
-	f.log("quit")
+	return nil
 }
 
 func (f *LiveStreamSender) Stop() {
@@ -77,7 +110,7 @@ func (f *LiveStreamSender) connect(udp *UdpConnection, laddr *net.UDPAddr) error
 	// Create remote addr
 	var raddr *net.UDPAddr
 	var err error
-	if raddr, err = net.ResolveUDPAddr("udp", fmt.Sprintf("127.0.0.1:%d", udp.port)); err != nil {
+	if raddr, err = net.ResolveUDPAddr("udp", net.JoinHostPort(f.host, strconv.Itoa(udp.port))); err != nil {
 		return fmt.Errorf("resolving udp port: %w", err)
 	}
 	// Dial udp
@@ -105,56 +138,9 @@ func (f *LiveStreamSender) close() error {
 	return nil
 }
 
-func (f *LiveStreamSender) writeTrack(udp *UdpConnection, track *webrtc.TrackRemote) error {
-	for {
-		select {
-		case <-f.ctx.Done():
-			f.log("stop writing because quit")
-			return nil
-		default:
-			var err error
-			b := make([]byte, 1500)
-			rtpPacket := &rtp.Packet{}
-			for {
-				// Read
-				n, _, readErr := track.Read(b)
-				if readErr != nil {
-					f.log(fmt.Sprintf("can not anymore read %s track %s  stream %s", track.Kind(), track.ID(), track.StreamID()))
-					return nil
-				}
-
-				// Unmarshal the packet and update the PayloadType
-				if err = rtpPacket.Unmarshal(b[:n]); err != nil {
-					return fmt.Errorf("unmarshaling pkg: %w", err)
-				}
-				rtpPacket.PayloadType = udp.payloadType
-
-				// Marshal into original buffer with updated PayloadType
-				if n, err = rtpPacket.MarshalTo(b); err != nil {
-					return fmt.Errorf("marshaling pkg: %w", err)
-				}
-
-				// Write
-				if _, writeErr := udp.conn.Write(b[:n]); writeErr != nil {
-					// For this particular example, third party applications usually timeout after a short
-					// amount of time during which the user doesn't have enough time to provide the answer
-					// to the browser.
-					// That's why, for this particular example, the user first needs to provide the answer
-					// to the browser then open the third party application. Therefore we must not kill
-					// the forward on "connection refused" errors
-					var opError *net.OpError
-					if errors.As(writeErr, &opError) && opError.Err.Error() == "write: connection refused" {
-						continue
-					}
-					return fmt.Errorf("writing pkg: %w", writeErr)
-				}
-			}
-		}
-	}
-	return nil
-}
-
 func (f *LiveStreamSender) GetConnData() UdpShare {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 	return UdpShare{
 		Audio: UdpShareInfo{Port: f.audio.port, PayloadType: f.audio.payloadType},
 		Video: UdpShareInfo{Port: f.video.port, PayloadType: f.video.payloadType},
@@ -165,89 +151,126 @@ func (f *LiveStreamSender) log(msg string) {
 	slog.Debug(msg, "forwarderId", f.id, "obj", "udpForwarder")
 }
 
-func (f *LiveStreamSender) AddTrack(track webrtc.TrackLocal) {
-	f.mu.Lock()
-	defer f.mu.Unlock()
-	binding := &baseTrackLocalContext{
-		id: uuid.NewString(),
-	}
-	if track.Kind() == webrtc.RTPCodecTypeAudio {
-		binding.ssrc = webrtc.SSRC(3450704251)
-		//binding.payloadType = webrtc.PayloadType(f.audio.payloadType)
-		binding.params.Codecs = []webrtc.RTPCodecParameters{
-			{
-				RTPCodecCapability: webrtc.RTPCodecCapability{webrtc.MimeTypeOpus, 48000, 2, "", nil},
-				PayloadType:        111,
-			},
-		}
-		binding.writeStream = newLiveStreamWriter(f.ctx, uuid.NewString(), f.audio)
-	}
-	if track.Kind() == webrtc.RTPCodecTypeVideo {
-		//videoRTCPFeedback := []webrtc.RTCPFeedback{{"goog-remb", ""}, {"ccm", "fir"}, {"nack", ""}, {"nack", "pli"}}
-		binding.ssrc = webrtc.SSRC(3450704222)
-		//binding.payloadType = webrtc.PayloadType(f.video.payloadType)
-		binding.params.Codecs = []webrtc.RTPCodecParameters{
-			{
-				RTPCodecCapability: webrtc.RTPCodecCapability{
-					webrtc.MimeTypeVP8,
-					90000,
-					0, "",
-					nil,
-				},
-				PayloadType: 96,
-			},
-		}
-		binding.writeStream = newLiveStreamWriter(f.ctx, uuid.NewString(), f.video)
-	}
-
-	if _, err := track.Bind(binding); err != nil {
-		slog.Error("binding track", "err", err)
-	}
+// Sdp describes the udp ports, codecs and payload types of the sender for the receiving tool.
+// A media section is only described, after a track of its kind was added.
+func (f *LiveStreamSender) Sdp() string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.sdp()
 }
 
-func (f *LiveStreamSender) RemoveTrack(_ webrtc.TrackLocal) {
-
+func (f *LiveStreamSender) sdp() string {
+	var sdp strings.Builder
+	sdp.WriteString("v=0\n")
+	fmt.Fprintf(&sdp, "o=- 0 0 IN IP4 %s\n", f.host)
+	fmt.Fprintf(&sdp, "s=Shig Stream %s\n", f.id)
+	fmt.Fprintf(&sdp, "c=IN IP4 %s\n", f.host)
+	sdp.WriteString("t=0 0\n")
+	writeSdpMedia(&sdp, "audio", f.audio)
+	writeSdpMedia(&sdp, "video", f.video)
+	return sdp.String()
 }
 
-// later -- > put in other file
-type baseTrackLocalContext struct {
-	id              string
-	params          webrtc.RTPParameters
-	ssrc            webrtc.SSRC
-	writeStream     webrtc.TrackLocalWriter
-	rtcpInterceptor interceptor.RTCPReader
+func writeSdpMedia(sdp *strings.Builder, kind string, udp *UdpConnection) {
+	if len(udp.codec.MimeType) == 0 {
+		return
+	}
+	// the mime type is "<kind>/<encoding name>"
+	encoding := udp.codec.MimeType[strings.Index(udp.codec.MimeType, "/")+1:]
+	fmt.Fprintf(sdp, "m=%s %d RTP/AVP %d\n", kind, udp.port, udp.payloadType)
+	if udp.codec.Channels > 0 {
+		fmt.Fprintf(sdp, "a=rtpmap:%d %s/%d/%d\n", udp.payloadType, encoding, udp.codec.ClockRate, udp.codec.Channels)
+	} else {
+		fmt.Fprintf(sdp, "a=rtpmap:%d %s/%d\n", udp.payloadType, encoding, udp.codec.ClockRate)
+	}
+	if len(udp.codec.SDPFmtpLine) > 0 {
+		fmt.Fprintf(sdp, "a=fmtp:%d %s\n", udp.payloadType, udp.codec.SDPFmtpLine)
+	}
 }
 
-// CodecParameters returns the negotiated RTPCodecParameters. These are the codecs supported by both
-// PeerConnections and the SSRC/PayloadTypes
-func (t *baseTrackLocalContext) CodecParameters() []webrtc.RTPCodecParameters {
-	return t.params.Codecs
+// SdpFile is the path of the sdp file, the receiving tool can be started with it
+func (f *LiveStreamSender) SdpFile() string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.sdpFile
 }
 
-// HeaderExtensions returns the negotiated RTPHeaderExtensionParameters. These are the header extensions supported by
-// both PeerConnections and the SSRC/PayloadTypes
-func (t *baseTrackLocalContext) HeaderExtensions() []webrtc.RTPHeaderExtensionParameter {
-	return t.params.HeaderExtensions
+func (f *LiveStreamSender) writeSdp() error {
+	dir := f.sdpDir
+	if len(dir) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating sdp directory: %w", err)
+	}
+	file := filepath.Join(dir, f.id.String()+".sdp")
+	if err := os.WriteFile(file, []byte(f.sdp()), 0644); err != nil {
+		return fmt.Errorf("writing sdp file: %w", err)
+	}
+	f.sdpFile = file
+	f.log(fmt.Sprintf("sdp file %s written", file))
+	return nil
 }
 
-// SSRC requires the negotiated SSRC of this track
-// This track may have multiple if RTX is enabled
-func (t *baseTrackLocalContext) SSRC() webrtc.SSRC {
-	return t.ssrc
+func (f *LiveStreamSender) removeSdp() {
+	if len(f.sdpFile) == 0 {
+		return
+	}
+	if err := os.Remove(f.sdpFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("forwarder: removing sdp file", "err", err, "forwarderID", f.id)
+	}
 }
 
-// WriteStream returns the WriteStream for this TrackLocal. The implementer writes the outbound
-// media packets to it
-func (t *baseTrackLocalContext) WriteStream() webrtc.TrackLocalWriter {
-	return t.writeStream
-}
+func (f *LiveStreamSender) AddTrack(info *TrackInfo) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, found := f.bindings[info.GetId()]; found {
+		return
+	}
+	// the packets are forwarded unchanged, so the receiving tool needs the codec negotiated with the publisher
+	codec := info.GetCodec()
+	if len(codec.MimeType) == 0 {
+		slog.Warn("rtp.LiveStreamSender: track without negotiated codec", "trackId", info.GetId(), "forwarderID", f.id)
+		return
+	}
+	track := info.GetTrackLocal()
+	udp := f.video
+	ssrc := webrtc.SSRC(3450704222)
+	if track.Kind() == webrtc.RTPCodecTypeAudio {
+		udp = f.audio
+		ssrc = webrtc.SSRC(3450704251)
+	}
+	binding := NewTrackLocalContext(uuid.NewString(), ssrc, []webrtc.RTPCodecParameters{codec}, newLiveStreamWriter(f.ctx, uuid.NewString(), udp))
 
-// ID is a unique identifier that is used for both Bind/Unbind
-func (t *baseTrackLocalContext) ID() string {
-	return t.id
+	if _, err := track.Bind(binding); err != nil {
+		slog.Error("binding track", "err", err)
+		return
+	}
+	f.bindings[info.GetId()] = binding
+
+	udp.payloadType = uint8(codec.PayloadType)
+	udp.codec = codec.RTPCodecCapability
+	// the sdp file is removed when the sender stops
+	if f.ctx.Err() != nil {
+		return
+	}
+	if err := f.writeSdp(); err != nil {
+		slog.Warn("rtp.LiveStreamSender: rewriting sdp file", "err", err, "forwarderID", f.id)
+	}
 }
 
-// RTCPReader returns the RTCP interceptor for this TrackLocal. Used to read RTCP of this TrackLocal.
-func (t *baseTrackLocalContext) RTCPReader() interceptor.RTCPReader {
-	return t.rtcpInterceptor
+func (f *LiveStreamSender) RemoveTrack(info *TrackInfo) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	binding, found := f.bindings[info.GetId()]
+	if !found {
+		return
+	}
+	delete(f.bindings, info.GetId())
+	if err := info.GetTrackLocal().Unbind(binding); err != nil {
+		slog.Warn("unbinding track", "err", err, "forwarderID", f.id)
+	}
+	if writer, ok := binding.WriteStream().(*liveStreamWriter); ok {
+		writer.close()
+	}
 }
@@ -0,0 +1,140 @@
+package rtp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+func testLiveStreamSenderConfig(t *testing.T) *LiveStreamSenderConfig {
+	t.Helper()
+	return &LiveStreamSenderConfig{Enable: true, Host: "127.0.0.1", PortMin: 41000, PortMax: 41007, SdpDir: t.TempDir()}
+}
+
+func testLiveStreamTrackInfo(t *testing.T, codec webrtc.RTPCodecParameters) *TrackInfo {
+	t.Helper()
+	track, err := webrtc.NewTrackLocalStaticRTP(codec.RTPCodecCapability, uuid.NewString(), "stream")
+	assert.NoError(t, err)
+	return &TrackInfo{TrackSdpInfo: TrackSdpInfo{Id: uuid.New()}, Track: track, Codec: codec}
+}
+
+func TestPortAllocator(t *testing.T) {
+	t.Run("allocate even ports until the range is exhausted", func(t *testing.T) {
+		ports, err := NewPortAllocator(40001, 40006)
+		assert.NoError(t, err)
+
+		first, _ := ports.Allocate()
+		second, _ := ports.Allocate()
+		_, err = ports.Allocate()
+		assert.Equal(t, 40002, first)
+		assert.Equal(t, 40004, second)
+		assert.ErrorIs(t, err, ErrNoPortAvailable)
+
+		ports.Release(first)
+		port, err := ports.Allocate()
+		assert.NoError(t, err)
+		assert.Equal(t, first, port)
+	})
+
+	t.Run("reject invalid range", func(t *testing.T) {
+		_, err := NewPortAllocator(40000, 40000)
+		assert.Error(t, err)
+	})
+}
+
+func TestLiveStreamSender(t *testing.T) {
+	t.Run("lobbies get different ports and sdp files", func(t *testing.T) {
+		config := testLiveStreamSenderConfig(t)
+		ports, _ := NewPortAllocator(config.PortMin, config.PortMax)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		first, err := NewLiveStreamSender(ctx, uuid.New(), config, ports)
+		assert.NoError(t, err)
+		second, err := NewLiveStreamSender(ctx, uuid.New(), config, ports)
+		assert.NoError(t, err)
+
+		assert.NotEqual(t, first.GetConnData(), second.GetConnData())
+		sdp, err := os.ReadFile(second.SdpFile())
+		assert.NoError(t, err)
+		assert.Contains(t, string(sdp), fmt.Sprintf("s=Shig Stream %s", second.id))
+		assert.NotContains(t, string(sdp), "m=")
+	})
+
+	t.Run("describe the negotiated codecs of the added tracks", func(t *testing.T) {
+		config := testLiveStreamSenderConfig(t)
+		ports, _ := NewPortAllocator(config.PortMin, config.PortMax)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		sender, err := NewLiveStreamSender(ctx, uuid.New(), config, ports)
+		assert.NoError(t, err)
+
+		sender.AddTrack(testLiveStreamTrackInfo(t, webrtc.RTPCodecParameters{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus, ClockRate: 48000, Channels: 2},
+			PayloadType:        109,
+		}))
+		sender.AddTrack(testLiveStreamTrackInfo(t, webrtc.RTPCodecParameters{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeH264, ClockRate: 90000, SDPFmtpLine: "packetization-mode=1"},
+			PayloadType:        125,
+		}))
+
+		conn := sender.GetConnData()
+		assert.Equal(t, uint8(109), conn.Audio.PayloadType)
+		assert.Equal(t, uint8(125), conn.Video.PayloadType)
+		sdp, err := os.ReadFile(sender.SdpFile())
+		assert.NoError(t, err)
+		assert.Contains(t, string(sdp), fmt.Sprintf("m=audio %d RTP/AVP 109\na=rtpmap:109 opus/48000/2\n", conn.Audio.Port))
+		assert.Contains(t, string(sdp), fmt.Sprintf("m=video %d RTP/AVP 125\na=rtpmap:125 H264/90000\na=fmtp:125 packetization-mode=1\n", conn.Video.Port))
+	})
+
+	t.Run("ignore tracks without negotiated codec", func(t *testing.T) {
+		config := testLiveStreamSenderConfig(t)
+		ports, _ := NewPortAllocator(config.PortMin, config.PortMax)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		sender, err := NewLiveStreamSender(ctx, uuid.New(), config, ports)
+		assert.NoError(t, err)
+
+		info := testLiveStreamTrackInfo(t, webrtc.RTPCodecParameters{RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000}})
+		info.Codec = webrtc.RTPCodecParameters{}
+		sender.AddTrack(info)
+
+		assert.Empty(t, sender.bindings)
+		assert.NotContains(t, sender.Sdp(), "m=video")
+	})
+
+	t.Run("fail if no ports are available", func(t *testing.T) {
+		config := testLiveStreamSenderConfig(t)
+		ports, _ := NewPortAllocator(config.PortMin, config.PortMin+3)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		_, err := NewLiveStreamSender(ctx, uuid.New(), config, ports)
+		assert.NoError(t, err)
+		_, err = NewLiveStreamSender(ctx, uuid.New(), config, ports)
+		assert.ErrorIs(t, err, ErrNoPortAvailable)
+		assert.Equal(t, 2, ports.Len())
+	})
+
+	t.Run("release ports when the lobby ends", func(t *testing.T) {
+		config := testLiveStreamSenderConfig(t)
+		ports, _ := NewPortAllocator(config.PortMin, config.PortMax)
+		ctx, cancel := context.WithCancel(context.Background())
+
+		sender, err := NewLiveStreamSender(ctx, uuid.New(), config, ports)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, ports.Len())
+		cancel()
+
+		assert.Eventually(t, func() bool {
+			_, err := os.Stat(sender.SdpFile())
+			return ports.Len() == 0 && os.IsNotExist(err)
+		}, time.Second, 10*time.Millisecond)
+	})
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/google/uuid"
+	"github.com/pion/rtcp"
 	"github.com/pion/webrtc/v3"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
@@ -15,18 +17,23 @@ import (
 const rtpBufferSize = 1500
 
 type mediaStream struct {
+	mu                       sync.Mutex
 	id                       uuid.UUID
 	sessionCxt               context.Context
 	remoteId                 string
 	sessionId                uuid.UUID
 	audioInfo, videoInfo     TrackSdpInfo
-	audioTrack, videoTrack   *webrtc.TrackLocalStaticRTP
+	audioTrack               *webrtc.TrackLocalStaticRTP
+	videoTrack               webrtc.TrackLocal
 	audioWriter, videoWriter *mediaWriter
 	purpose                  Purpose
 	dispatcher               TrackDispatcher
+	videoFeedback            *rtcpForwarder
+	writeRtcp                func([]rtcp.Packet) error
+	onAudioLevel             func(level uint8)
 }
 
-func newMediaStream(sessionCxt context.Context, remoteId string, sessionId uuid.UUID, dispatcher TrackDispatcher, purpose Purpose) *mediaStream {
+func newMediaStream(sessionCxt context.Context, remoteId string, sessionId uuid.UUID, dispatcher TrackDispatcher, purpose Purpose, writeRtcp func([]rtcp.Packet) error) *mediaStream {
 	return &mediaStream{
 		id:         uuid.New(),
 		sessionCxt: sessionCxt,
@@ -34,10 +41,11 @@ func newMediaStream(sessionCxt context.Context, remoteId string, sessionId uuid.
 		sessionId:  sessionId,
 		dispatcher: dispatcher,
 		purpose:    purpose,
+		writeRtcp:  writeRtcp,
 	}
 }
 
-func (s *mediaStream) writeAudioRtp(ctx context.Context, track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) error {
+func (s *mediaStream) writeAudioRtp(ctx context.Context, track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) error {
 	slog.Debug("rtp.mediaStream: write audio track", "streamId", s.id, "remoteTrackId", track.ID(), "purpose", s.purpose.ToString())
 	ctx, span := otel.Tracer(tracerName).Start(ctx, "rtp.ingress: write_audio_rtp")
 	defer span.End()
@@ -47,6 +55,7 @@ func (s *mediaStream) writeAudioRtp(ctx context.Context, track *webrtc.TrackRemo
 	}
 	s.audioTrack = audio
 	s.audioWriter = newMediaWriter(s.sessionCxt, s.audioTrack.ID())
+	s.audioWriter.setAudioLevelListener(audioLevelExtensionId(receiver), s.onAudioLevel)
 
 	// start local audio track
 	go func() {
@@ -84,6 +93,7 @@ func (s *mediaStream) writeVideoRtp(ctx context.Context, track *webrtc.TrackRemo
 	}
 
 	s.videoTrack = video
+	s.videoFeedback = newRtcpForwarder(staticFeedbackMapper{ssrc: uint32(track.SSRC())}, s.writeRtcp)
 	s.videoWriter = newMediaWriter(s.sessionCxt, s.videoTrack.ID())
 
 	// start local video track
@@ -92,7 +102,7 @@ func (s *mediaStream) writeVideoRtp(ctx context.Context, track *webrtc.TrackRemo
 		defer s.dispatcher.DispatchRemoveTrack(ctx, newTrackInfo(s.videoTrack, s.videoInfo))
 
 		// blocking loop
-		err := s.videoWriter.writeRtp(track, s.videoTrack)
+		err := s.videoWriter.writeRtp(track, video)
 
 		ctx, span := newTraceSpan(context.Background(), s.sessionCxt, "rtp.ingress: remove_video_track")
 		span.SetAttributes(
@@ -113,6 +123,89 @@ func (s *mediaStream) writeVideoRtp(ctx context.Context, track *webrtc.TrackRemo
 	return nil
 }
 
+// writeSimulcastVideoRtp writes one rid of a simulcast remote track into the shared simulcast track.
+// It returns true for the first layer, only then the track has to be dispatched.
+func (s *mediaStream) writeSimulcastVideoRtp(ctx context.Context, track *webrtc.TrackRemote, _ *webrtc.RTPReceiver) (bool, error) {
+	slog.Debug("rtp.ingress: write simulcast video track", "streamId", s.id, "remoteTrackId", track.ID(), "rid", track.RID(), "purpose", s.purpose.ToString())
+	_, span := otel.Tracer(tracerName).Start(ctx, "rtp.mediaStream: write_simulcast_video_rtp")
+	defer span.End()
+
+	video, quality, isNew, err := s.addSimulcastVideoLayer(track)
+	if err != nil {
+		return false, fmt.Errorf("adding simulcast video remote track (%s:%s) to local stream: %w", track.ID(), track.StreamID(), err)
+	}
+	writer := newMediaWriter(s.sessionCxt, video.ID()+":"+track.RID())
+
+	// start local video layer
+	go func() {
+		// blocking loop
+		err := writer.writeRtp(track, video.layerWriter(quality))
+		if err != nil {
+			slog.Error("rtp.mediaStream: writing local simulcast video layer", "streamId", s.id, "rid", track.RID(), "err", err)
+		}
+		slog.Debug("rtp.mediaStream: stop writing local simulcast video layer", "streamId", s.id, "trackId", video.ID(), "rid", track.RID(), "purpose", s.purpose.ToString())
+
+		// the track is removed when the last layer stopped
+		if remaining := s.removeSimulcastVideoLayer(video, quality); remaining > 0 {
+			return
+		}
+
+		ctx, span := newTraceSpan(context.Background(), s.sessionCxt, "rtp.ingress: remove_video_track")
+		span.SetAttributes(
+			attribute.String("mediaStreamId", video.StreamID()),
+			attribute.String("localTrack", video.ID()),
+			attribute.String("kind", "video"),
+			attribute.String("purpose", s.purpose.ToString()),
+		)
+		if err != nil {
+			span.RecordError(err)
+		}
+		s.dispatcher.DispatchRemoveTrack(ctx, newTrackInfo(video, s.videoInfo))
+		span.End()
+	}()
+
+	return isNew, nil
+}
+
+// addSimulcastVideoLayer adds the rid of the remote track as layer to the simulcast track of the stream.
+// The simulcast track is created with the first layer, it returns true then.
+func (s *mediaStream) addSimulcastVideoLayer(remoteTrack *webrtc.TrackRemote) (*simulcastTrack, VideoQuality, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.videoTrack == nil {
+		video := newSimulcastTrack(remoteTrack.Codec().RTPCodecCapability, uuid.NewString(), s.id.String())
+		quality, err := video.addLayer(remoteTrack.RID(), uint32(remoteTrack.SSRC()))
+		if err != nil {
+			return nil, VideoQuality_OFF, false, fmt.Errorf("adding layer %s: %w", remoteTrack.RID(), err)
+		}
+		s.videoFeedback = newRtcpForwarder(video, s.writeRtcp)
+		video.onKeyframeNeeded = s.videoFeedback.requestKeyframe
+		s.videoTrack = video
+		return video, quality, true, nil
+	}
+	video, ok := s.videoTrack.(*simulcastTrack)
+	if !ok {
+		return nil, VideoQuality_OFF, false, errors.New("has already none simulcast video track")
+	}
+	quality, err := video.addLayer(remoteTrack.RID(), uint32(remoteTrack.SSRC()))
+	if err != nil {
+		return nil, VideoQuality_OFF, false, fmt.Errorf("adding layer %s: %w", remoteTrack.RID(), err)
+	}
+	return video, quality, false, nil
+}
+
+// removeSimulcastVideoLayer removes a layer of the simulcast track and returns the number of remaining layers.
+// Without layers the track is removed from the stream, so that a republished video gets a new track.
+func (s *mediaStream) removeSimulcastVideoLayer(video *simulcastTrack, quality VideoQuality) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	remaining := video.removeLayer(quality)
+	if remaining == 0 && s.videoTrack == webrtc.TrackLocal(video) {
+		s.videoTrack = nil
+	}
+	return remaining
+}
+
 func (s *mediaStream) createNewAudioLocalTrack(remoteTrack *webrtc.TrackRemote) (*webrtc.TrackLocalStaticRTP, error) {
 	if s.audioTrack != nil {
 		return nil, errors.New("has already audio track")
@@ -135,10 +228,18 @@ func (s *mediaStream) createNewVideoLocalTrack(remoteTrack *webrtc.TrackRemote)
 	return video, nil
 }
 
-func (s *mediaStream) getVideoTrack() *webrtc.TrackLocalStaticRTP {
+func (s *mediaStream) getVideoTrack() webrtc.TrackLocal {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return s.videoTrack
 }
 
+func (s *mediaStream) getVideoFeedback() *rtcpForwarder {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.videoFeedback
+}
+
 func (s *mediaStream) getAudioTrack() *webrtc.TrackLocalStaticRTP {
 	return s.audioTrack
 }
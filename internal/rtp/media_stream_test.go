@@ -0,0 +1,23 @@
+package rtp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMediaStream_RemoveSimulcastVideoLayer(t *testing.T) {
+	stream := newMediaStream(context.Background(), "remote", uuid.New(), nil, PurposeMain, nil)
+	video, _ := testSimulcastTrackSetup(t)
+	low, _ := video.addLayer("q", 1)
+	high, _ := video.addLayer("f", 3)
+	stream.videoTrack = video
+
+	assert.Equal(t, 1, stream.removeSimulcastVideoLayer(video, low))
+	assert.Equal(t, video, stream.getVideoTrack())
+
+	assert.Equal(t, 0, stream.removeSimulcastVideoLayer(video, high))
+	assert.Nil(t, stream.getVideoTrack())
+}
@@ -14,6 +14,9 @@ type mediaWriter struct {
 	id         string
 	sessionCxt context.Context
 	quit       chan struct{}
+	// audio level of audio tracks, only read if the header extension was negotiated
+	audioLevelId uint8
+	onAudioLevel func(level uint8)
 }
 
 func newMediaWriter(sessionCxt context.Context, id string) *mediaWriter {
@@ -24,7 +27,7 @@ func newMediaWriter(sessionCxt context.Context, id string) *mediaWriter {
 	}
 }
 
-func (w *mediaWriter) writeRtp(remoteTrack *webrtc.TrackRemote, localTrack *webrtc.TrackLocalStaticRTP) error {
+func (w *mediaWriter) writeRtp(remoteTrack *webrtc.TrackRemote, localTrack io.Writer) error {
 	rtpBuf := make([]byte, rtpBufferSize)
 	slog.Debug("rtp.mediaWriter write RTP", "track id", w.id)
 	for {
@@ -47,6 +50,11 @@ func (w *mediaWriter) writeRtp(remoteTrack *webrtc.TrackRemote, localTrack *webr
 				slog.Error("rtp.mediaWriter reading rtp buffer", "track id", w.id)
 				return fmt.Errorf("reading rtp buffer: %w", err)
 			}
+			if w.audioLevelId != 0 {
+				if level, ok := readAudioLevel(rtpBuf[:i], w.audioLevelId); ok {
+					w.onAudioLevel(level)
+				}
+			}
 			// ErrClosedPipe means we don't have any subscribers, this is ok if no peers have connected yet
 			if _, err := localTrack.Write(rtpBuf[:i]); err != nil {
 				// stop reading because writing error
@@ -61,6 +69,14 @@ func (w *mediaWriter) writeRtp(remoteTrack *webrtc.TrackRemote, localTrack *webr
 	}
 }
 
+func (w *mediaWriter) setAudioLevelListener(extensionId uint8, onAudioLevel func(level uint8)) {
+	if onAudioLevel == nil {
+		return
+	}
+	w.audioLevelId = extensionId
+	w.onAudioLevel = onAudioLevel
+}
+
 func (w *mediaWriter) close() {
 	slog.Info("rtp.mediaWriter: close", "track id", w.id)
 	select {
@@ -0,0 +1,67 @@
+package rtp
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var ErrNoPortAvailable = errors.New("no udp port available")
+
+// PortAllocator hands out the udp ports of the live stream senders of all lobbies.
+// A port is always allocated with its successor, because RTCP uses the next port (RFC 3550, section 11).
+type PortAllocator struct {
+	mu   sync.Mutex
+	min  int
+	max  int
+	next int
+	used map[int]struct{}
+}
+
+func NewPortAllocator(min int, max int) (*PortAllocator, error) {
+	// RTP ports should be even
+	if min%2 != 0 {
+		min++
+	}
+	if min < 1 || max > 65535 || max-min < 1 {
+		return nil, fmt.Errorf("invalid port range %d-%d", min, max)
+	}
+	return &PortAllocator{
+		min:  min,
+		max:  max,
+		next: min,
+		used: make(map[int]struct{}),
+	}, nil
+}
+
+// Allocate returns an even port, the port and the following port are reserved until the port is released
+func (a *PortAllocator) Allocate() (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// start searching behind the last allocation, so that just released ports are not reused immediately
+	pairs := (a.max - a.min + 1) / 2
+	for i := 0; i < pairs; i++ {
+		port := a.next
+		a.next += 2
+		if a.next+1 > a.max {
+			a.next = a.min
+		}
+		if _, found := a.used[port]; !found {
+			a.used[port] = struct{}{}
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("port range %d-%d exhausted: %w", a.min, a.max, ErrNoPortAvailable)
+}
+
+func (a *PortAllocator) Release(port int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.used, port)
+}
+
+func (a *PortAllocator) Len() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.used)
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	"github.com/google/uuid"
+	"github.com/pion/rtcp"
 	"github.com/pion/webrtc/v3"
 	"github.com/shigde/sfu/internal/metric"
 	"github.com/shigde/sfu/internal/rtp/stats"
@@ -23,6 +24,10 @@ type receiver struct {
 	dispatcher    TrackDispatcher
 	trackSdpInfos *trackSdpInfoRepository
 	statsRegistry *stats.Registry
+	// writes rtcp feedback of the subscribers to the publisher
+	writeRtcp func([]rtcp.Packet) error
+	// receives the audio levels of the audio tracks
+	onAudioLevel func(level uint8)
 }
 
 func newReceiver(sessionCxt context.Context, sessionId uuid.UUID, liveStream uuid.UUID, d TrackDispatcher, trackSdpInfos *trackSdpInfoRepository) *receiver {
@@ -81,7 +86,24 @@ func (r *receiver) onTrack(remoteTrack *webrtc.TrackRemote, rtpReceiver *webrtc.
 		trackInfo = newTrackInfo(stream.getAudioTrack(), *trackSdpInfo)
 	}
 
-	if strings.HasPrefix(remoteTrack.Codec().RTPCodecCapability.MimeType, "video") {
+	if strings.HasPrefix(remoteTrack.Codec().RTPCodecCapability.MimeType, "video") && remoteTrack.RID() != "" {
+		slog.Debug("rtp.receiver: on ingress simulcast video track", "streamId", remoteTrack.StreamID(), "track", remoteTrack.ID(), "rid", remoteTrack.RID(), "purpose", stream.getPurpose().ToString())
+		isFirstLayer, err := stream.writeSimulcastVideoRtp(ctx, remoteTrack, rtpReceiver)
+		if err != nil {
+			slog.Error("rtp.receiver: on ingress simulcast video track", "err", err, "streamId", remoteTrack.StreamID(), "track", remoteTrack.ID(), "rid", remoteTrack.RID(), "purpose", stream.getPurpose().ToString())
+			_ = telemetry.RecordError(span, err)
+			// stop handler goroutine because error
+			return
+		}
+		// the hub knows the track already from the first layer
+		if !isFirstLayer {
+			return
+		}
+		trackInfo = newTrackInfo(stream.getVideoTrack(), *trackSdpInfo)
+		trackInfo.feedback = stream.getVideoFeedback()
+	}
+
+	if strings.HasPrefix(remoteTrack.Codec().RTPCodecCapability.MimeType, "video") && remoteTrack.RID() == "" {
 		slog.Debug("rtp.receiver: on ingress video track", "streamId", remoteTrack.StreamID(), "track", remoteTrack.ID(), "kind", remoteTrack.Kind(), "purpose", stream.getPurpose().ToString())
 		if err := stream.writeVideoRtp(ctx, remoteTrack, rtpReceiver); err != nil {
 			slog.Error("rtp.receiver: on ingress video track", "err", err, "streamId", remoteTrack.StreamID(), "track", remoteTrack.ID(), "kind", remoteTrack.Kind(), "purpose", stream.getPurpose().ToString())
@@ -91,8 +113,10 @@ func (r *receiver) onTrack(remoteTrack *webrtc.TrackRemote, rtpReceiver *webrtc.
 		}
 
 		trackInfo = newTrackInfo(stream.getVideoTrack(), *trackSdpInfo)
+		trackInfo.feedback = stream.getVideoFeedback()
 	}
 
+	trackInfo.Codec = remoteTrack.Codec()
 	slog.Debug("rtp.receiver: info track", "streamId", trackInfo.GetTrackLocal().StreamID(), "track", trackInfo.GetTrackLocal().ID(), "kind", trackInfo.GetTrackLocal().Kind(), "purpose", trackInfo.Purpose.ToString())
 	// send track to Lobby Hub
 	r.dispatcher.DispatchAddTrack(ctx, trackInfo)
@@ -103,6 +127,7 @@ func (r *receiver) getIngressTrackSdpInfo(ingressTrackId string) *TrackSdpInfo {
 	if !found {
 		info = newTrackSdpInfo(r.id)
 		info.Purpose = PurposeGuest
+		info.IngressTrackId = ingressTrackId
 		r.trackSdpInfos.Set(info.Id, info)
 	}
 	return info
@@ -113,7 +138,8 @@ func (r *receiver) getStream(sessionCxt context.Context, sessionId uuid.UUID, st
 	defer r.Unlock()
 	stream, ok := r.streams[streamId]
 	if !ok {
-		stream = newMediaStream(sessionCxt, streamId, sessionId, r.dispatcher, sdpInfo.Purpose)
+		stream = newMediaStream(sessionCxt, streamId, sessionId, r.dispatcher, sdpInfo.Purpose, r.writeRtcp)
+		stream.onAudioLevel = r.onAudioLevel
 		r.streams[streamId] = stream
 	}
 
@@ -0,0 +1,157 @@
+package rtp
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// keyframeRequestInterval limits the keyframe requests per ingress rtp stream,
+	// because every new or lossy subscriber asks for a keyframe.
+	keyframeRequestInterval = 500 * time.Millisecond
+	// nackInterval is the time a lost packet is not requested again from the publisher.
+	nackInterval = 100 * time.Millisecond
+)
+
+// feedbackMapper maps rtcp feedback of an egress binding to the ingress rtp stream the packets came from
+type feedbackMapper interface {
+	ingressSsrc(egressSsrc uint32) (uint32, bool)
+	ingressSequence(egressSsrc uint32, seq uint16) (uint32, uint16, bool)
+	allIngressSsrc() []uint32
+}
+
+// staticFeedbackMapper maps all bindings of a none simulcast track to one ingress stream.
+// The local track keeps the sequence numbers of the remote track.
+type staticFeedbackMapper struct {
+	ssrc uint32
+}
+
+func (m staticFeedbackMapper) ingressSsrc(_ uint32) (uint32, bool) {
+	return m.ssrc, true
+}
+
+func (m staticFeedbackMapper) ingressSequence(_ uint32, seq uint16) (uint32, uint16, bool) {
+	return m.ssrc, seq, true
+}
+
+func (m staticFeedbackMapper) allIngressSsrc() []uint32 {
+	return []uint32{m.ssrc}
+}
+
+// rtcpForwarder collects the rtcp feedback (PLI, FIR, NACK) of all subscribers of one ingress track,
+// aggregates it and sends it to the publisher.
+type rtcpForwarder struct {
+	mu                  sync.Mutex
+	mapper              feedbackMapper
+	writeRtcp           func([]rtcp.Packet) error
+	lastKeyframeRequest map[uint32]time.Time
+	lastNack            map[uint32]map[uint16]time.Time
+}
+
+func newRtcpForwarder(mapper feedbackMapper, writeRtcp func([]rtcp.Packet) error) *rtcpForwarder {
+	return &rtcpForwarder{
+		mapper:              mapper,
+		writeRtcp:           writeRtcp,
+		lastKeyframeRequest: make(map[uint32]time.Time),
+		lastNack:            make(map[uint32]map[uint16]time.Time),
+	}
+}
+
+// onEgressRtcp receives the rtcp packets read from an egress rtp sender
+func (f *rtcpForwarder) onEgressRtcp(egressSsrc uint32, packets []rtcp.Packet) {
+	for _, packet := range packets {
+		switch p := packet.(type) {
+		case *rtcp.PictureLossIndication, *rtcp.FullIntraRequest:
+			if ingressSsrc, ok := f.mapper.ingressSsrc(egressSsrc); ok {
+				f.requestKeyframe(ingressSsrc)
+			}
+		case *rtcp.TransportLayerNack:
+			f.forwardNack(egressSsrc, p)
+		}
+	}
+}
+
+// requestKeyframe sends a PLI to the publisher, if no other request was sent recently
+func (f *rtcpForwarder) requestKeyframe(ingressSsrc uint32) {
+	f.mu.Lock()
+	now := time.Now()
+	if last, ok := f.lastKeyframeRequest[ingressSsrc]; ok && now.Sub(last) < keyframeRequestInterval {
+		f.mu.Unlock()
+		return
+	}
+	f.lastKeyframeRequest[ingressSsrc] = now
+	f.mu.Unlock()
+
+	f.write([]rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: ingressSsrc}})
+}
+
+// requestAllKeyframes asks the publisher for keyframes of all layers of the track
+func (f *rtcpForwarder) requestAllKeyframes() {
+	for _, ssrc := range f.mapper.allIngressSsrc() {
+		f.requestKeyframe(ssrc)
+	}
+}
+
+func (f *rtcpForwarder) forwardNack(egressSsrc uint32, nack *rtcp.TransportLayerNack) {
+	lost := make(map[uint32][]uint16)
+
+	f.mu.Lock()
+	now := time.Now()
+	for _, pair := range nack.Nacks {
+		for _, seq := range pair.PacketList() {
+			ingressSsrc, ingressSeq, ok := f.mapper.ingressSequence(egressSsrc, seq)
+			if !ok {
+				continue
+			}
+			requested, found := f.lastNack[ingressSsrc]
+			if !found {
+				requested = make(map[uint16]time.Time)
+				f.lastNack[ingressSsrc] = requested
+			}
+			if last, ok := requested[ingressSeq]; ok && now.Sub(last) < nackInterval {
+				continue
+			}
+			requested[ingressSeq] = now
+			lost[ingressSsrc] = append(lost[ingressSsrc], ingressSeq)
+		}
+	}
+	f.pruneNacks(now)
+	f.mu.Unlock()
+
+	packets := make([]rtcp.Packet, 0, len(lost))
+	for ingressSsrc, seqs := range lost {
+		packets = append(packets, &rtcp.TransportLayerNack{
+			MediaSSRC: ingressSsrc,
+			Nacks:     rtcp.NackPairsFromSequenceNumbers(seqs),
+		})
+	}
+	if len(packets) > 0 {
+		f.write(packets)
+	}
+}
+
+// pruneNacks removes outdated requests, the caller must hold the lock
+func (f *rtcpForwarder) pruneNacks(now time.Time) {
+	for ssrc, requested := range f.lastNack {
+		for seq, last := range requested {
+			if now.Sub(last) >= nackInterval {
+				delete(requested, seq)
+			}
+		}
+		if len(requested) == 0 {
+			delete(f.lastNack, ssrc)
+		}
+	}
+}
+
+func (f *rtcpForwarder) write(packets []rtcp.Packet) {
+	if f.writeRtcp == nil {
+		return
+	}
+	if err := f.writeRtcp(packets); err != nil {
+		slog.Warn("rtp.rtcpForwarder: write rtcp to publisher", "err", err)
+	}
+}
@@ -0,0 +1,45 @@
+package rtp
+
+import (
+	"testing"
+
+	"github.com/pion/rtcp"
+	"github.com/stretchr/testify/assert"
+)
+
+func testRtcpForwarderSetup(t *testing.T) (*rtcpForwarder, *[]rtcp.Packet) {
+	t.Helper()
+	written := make([]rtcp.Packet, 0)
+	forwarder := newRtcpForwarder(staticFeedbackMapper{ssrc: 42}, func(packets []rtcp.Packet) error {
+		written = append(written, packets...)
+		return nil
+	})
+	return forwarder, &written
+}
+
+func TestRtcpForwarder(t *testing.T) {
+	t.Run("aggregate keyframe requests of all subscribers", func(t *testing.T) {
+		forwarder, written := testRtcpForwarderSetup(t)
+		forwarder.onEgressRtcp(1, []rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: 1}})
+		forwarder.onEgressRtcp(2, []rtcp.Packet{&rtcp.FullIntraRequest{MediaSSRC: 2}})
+		forwarder.onEgressRtcp(3, []rtcp.Packet{&rtcp.PictureLossIndication{MediaSSRC: 3}})
+
+		assert.Len(t, *written, 1)
+		pli, ok := (*written)[0].(*rtcp.PictureLossIndication)
+		assert.True(t, ok)
+		assert.Equal(t, uint32(42), pli.MediaSSRC)
+	})
+
+	t.Run("forward lost packets only once", func(t *testing.T) {
+		forwarder, written := testRtcpForwarderSetup(t)
+		nack := &rtcp.TransportLayerNack{MediaSSRC: 1, Nacks: rtcp.NackPairsFromSequenceNumbers([]uint16{10, 11})}
+		forwarder.onEgressRtcp(1, []rtcp.Packet{nack})
+		forwarder.onEgressRtcp(2, []rtcp.Packet{nack})
+
+		assert.Len(t, *written, 1)
+		forwarded, ok := (*written)[0].(*rtcp.TransportLayerNack)
+		assert.True(t, ok)
+		assert.Equal(t, uint32(42), forwarded.MediaSSRC)
+		assert.Equal(t, []uint16{10, 11}, forwarded.Nacks[0].PacketList())
+	})
+}
@@ -0,0 +1,151 @@
+package rtp
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// SdpFragmentMimeType is the content type of trickle ICE sdp fragments (RFC 8840)
+const SdpFragmentMimeType = "application/trickle-ice-sdpfrag"
+
+var ErrInvalidSdpFragment = errors.New("invalid sdp fragment")
+
+// SdpFragment is a trickle ICE sdp fragment (RFC 8840).
+// A fragment carries the ICE credentials and the candidates of the media sections of a peer.
+type SdpFragment struct {
+	IceUfrag string
+	IcePwd   string
+	Media    []*SdpFragmentMedia
+}
+
+type SdpFragmentMedia struct {
+	// the media line without "m=", candidates before the first media line belong to a media section without media line
+	MediaLine       string
+	Mid             string
+	Candidates      []string
+	EndOfCandidates bool
+}
+
+// ParseSdpFragment reads the ICE credentials and the candidates of a sdp fragment or of a complete sdp,
+// all other attributes are ignored
+func ParseSdpFragment(fragment string) (*SdpFragment, error) {
+	frag := &SdpFragment{Media: make([]*SdpFragmentMedia, 0)}
+	var media *SdpFragmentMedia
+	for _, line := range strings.Split(fragment, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "m="):
+			media = &SdpFragmentMedia{MediaLine: strings.TrimPrefix(line, "m=")}
+			frag.Media = append(frag.Media, media)
+		case strings.HasPrefix(line, "a=ice-ufrag:"):
+			if frag.IceUfrag == "" {
+				frag.IceUfrag = strings.TrimPrefix(line, "a=ice-ufrag:")
+			}
+		case strings.HasPrefix(line, "a=ice-pwd:"):
+			if frag.IcePwd == "" {
+				frag.IcePwd = strings.TrimPrefix(line, "a=ice-pwd:")
+			}
+		case strings.HasPrefix(line, "a=mid:"):
+			if media != nil {
+				media.Mid = strings.TrimPrefix(line, "a=mid:")
+			}
+		case strings.HasPrefix(line, "a=candidate:"):
+			if media == nil {
+				media = &SdpFragmentMedia{}
+				frag.Media = append(frag.Media, media)
+			}
+			media.Candidates = append(media.Candidates, strings.TrimPrefix(line, "a="))
+		case line == "a=end-of-candidates":
+			if media != nil {
+				media.EndOfCandidates = true
+			}
+		}
+	}
+	if frag.IceUfrag == "" {
+		return nil, ErrInvalidSdpFragment
+	}
+	return frag, nil
+}
+
+// CandidateInits returns the candidates of the fragment to add them to a peer connection
+func (f *SdpFragment) CandidateInits() []webrtc.ICECandidateInit {
+	inits := make([]webrtc.ICECandidateInit, 0)
+	for _, media := range f.Media {
+		for _, candidate := range media.Candidates {
+			init := webrtc.ICECandidateInit{Candidate: candidate}
+			if media.Mid != "" {
+				mid := media.Mid
+				init.SDPMid = &mid
+			}
+			inits = append(inits, init)
+		}
+	}
+	return inits
+}
+
+func (f *SdpFragment) String() string {
+	var builder strings.Builder
+	builder.WriteString("a=ice-ufrag:" + f.IceUfrag + "\r\n")
+	builder.WriteString("a=ice-pwd:" + f.IcePwd + "\r\n")
+	for _, media := range f.Media {
+		if media.MediaLine != "" {
+			builder.WriteString("m=" + media.MediaLine + "\r\n")
+		}
+		if media.Mid != "" {
+			builder.WriteString("a=mid:" + media.Mid + "\r\n")
+		}
+		for _, candidate := range media.Candidates {
+			builder.WriteString("a=" + candidate + "\r\n")
+		}
+		if media.EndOfCandidates {
+			builder.WriteString("a=end-of-candidates\r\n")
+		}
+	}
+	return builder.String()
+}
+
+// SupportsTrickle returns true, if the peer of the sdp trickles its ICE candidates
+func SupportsTrickle(sdp *webrtc.SessionDescription) bool {
+	for _, line := range strings.Split(sdp.SDP, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "a=ice-options:") {
+			continue
+		}
+		for _, option := range strings.Fields(strings.TrimPrefix(line, "a=ice-options:")) {
+			if option == "trickle" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IceSessionETag returns the entity tag of the ICE session of a remote peer, the tag changes with ICE restarts
+func IceSessionETag(ufrag string) string {
+	return fmt.Sprintf("\"%x\"", md5.Sum([]byte(ufrag)))
+}
+
+// replaceIceCredentials replaces the ICE credentials of all media sections of a sdp, to restart ICE with the remote peer
+func replaceIceCredentials(sdp string, ufrag string, pwd string) string {
+	lines := strings.Split(sdp, "\n")
+	for i, line := range lines {
+		// keep the line endings of the sdp
+		ending := ""
+		if strings.HasSuffix(line, "\r") {
+			ending = "\r"
+		}
+		switch {
+		case strings.HasPrefix(line, "a=ice-ufrag:"):
+			lines[i] = "a=ice-ufrag:" + ufrag + ending
+		case strings.HasPrefix(line, "a=ice-pwd:"):
+			lines[i] = "a=ice-pwd:" + pwd + ending
+		}
+	}
+	return strings.Join(lines, "\n")
+}
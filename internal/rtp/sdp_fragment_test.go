@@ -0,0 +1,71 @@
+package rtp
+
+import (
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+const sdpFragmentString = "a=ice-ufrag:EsAw\r\n" +
+	"a=ice-pwd:P2uYro0UCOQ4zxjKXaWCBui1\r\n" +
+	"m=audio 9 RTP/AVP 0\r\n" +
+	"a=mid:0\r\n" +
+	"a=candidate:1387637174 1 udp 2122260223 192.0.2.1 61764 typ host generation 0 ufrag EsAw network-id 1\r\n" +
+	"a=candidate:3471623853 1 udp 2122194687 198.51.100.2 61765 typ host generation 0 ufrag EsAw network-id 2\r\n" +
+	"a=end-of-candidates\r\n"
+
+func TestSdpFragment(t *testing.T) {
+	t.Run("parse sdp fragment", func(t *testing.T) {
+		frag, err := ParseSdpFragment(sdpFragmentString)
+		assert.NoError(t, err)
+		assert.Equal(t, "EsAw", frag.IceUfrag)
+		assert.Equal(t, "P2uYro0UCOQ4zxjKXaWCBui1", frag.IcePwd)
+		assert.Len(t, frag.Media, 1)
+		assert.Equal(t, "0", frag.Media[0].Mid)
+		assert.True(t, frag.Media[0].EndOfCandidates)
+
+		inits := frag.CandidateInits()
+		assert.Len(t, inits, 2)
+		assert.Equal(t, "candidate:1387637174 1 udp 2122260223 192.0.2.1 61764 typ host generation 0 ufrag EsAw network-id 1", inits[0].Candidate)
+		assert.Equal(t, "0", *inits[0].SDPMid)
+	})
+
+	t.Run("marshal sdp fragment", func(t *testing.T) {
+		frag, err := ParseSdpFragment(sdpFragmentString)
+		assert.NoError(t, err)
+		assert.Equal(t, sdpFragmentString, frag.String())
+	})
+
+	t.Run("reject sdp fragment without ice credentials", func(t *testing.T) {
+		_, err := ParseSdpFragment("a=candidate:1387637174 1 udp 2122260223 192.0.2.1 61764 typ host\r\n")
+		assert.ErrorIs(t, err, ErrInvalidSdpFragment)
+	})
+
+	t.Run("read ice credentials of a complete sdp", func(t *testing.T) {
+		frag, err := ParseSdpFragment(sdpOfferString)
+		assert.NoError(t, err)
+		assert.Equal(t, "vb2/", frag.IceUfrag)
+		assert.Equal(t, "lsvp3wbps99khhFRo2dGqpVz", frag.IcePwd)
+	})
+
+	t.Run("detect trickle ice support", func(t *testing.T) {
+		assert.True(t, SupportsTrickle(&webrtc.SessionDescription{SDP: sdpOfferString}))
+		assert.False(t, SupportsTrickle(&webrtc.SessionDescription{SDP: "v=0\r\na=ice-options:ice2\r\n"}))
+	})
+
+	t.Run("replace ice credentials for an ice restart", func(t *testing.T) {
+		sdp := replaceIceCredentials(sdpOfferString, "new", "newpassword")
+		frag, err := ParseSdpFragment(sdp)
+		assert.NoError(t, err)
+		assert.Equal(t, "new", frag.IceUfrag)
+		assert.Equal(t, "newpassword", frag.IcePwd)
+		assert.NotContains(t, sdp, "vb2/")
+	})
+
+	t.Run("tag ice sessions by the ice credentials", func(t *testing.T) {
+		assert.Equal(t, IceSessionETag("EsAw"), IceSessionETag("EsAw"))
+		assert.NotEqual(t, IceSessionETag("EsAw"), IceSessionETag("new"))
+		assert.Regexp(t, `^"[0-9a-f]{32}"$`, IceSessionETag("EsAw"))
+	})
+}
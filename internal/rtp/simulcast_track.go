@@ -0,0 +1,376 @@
+package rtp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"golang.org/x/exp/slog"
+)
+
+var ErrLayerAlreadyExists = errors.New("simulcast layer already exists")
+var errNoFreeLayer = errors.New("no free simulcast layer")
+
+// simulcastTrack is a local track, fed by all rids (layers) of one simulcast remote track.
+// Every egress endpoint binding the track gets its own layer selection. The selected layer is switched
+// on the next keyframe and the sequence numbers and timestamps are rewritten, so that the subscriber
+// receives one continuous rtp stream.
+type simulcastTrack struct {
+	mu       sync.RWMutex
+	id       string
+	streamId string
+	codec    webrtc.RTPCodecCapability
+	layers   map[VideoQuality]simulcastLayerSource
+	bindings map[string]*simulcastBinding
+	// selected qualities of senders, which are not bound yet
+	qualities map[uint32]VideoQuality
+	// asks the publisher for a keyframe of the ingress layer with the given ssrc
+	onKeyframeNeeded func(ingressSsrc uint32)
+}
+
+type simulcastLayerSource struct {
+	rid  string
+	ssrc uint32
+}
+
+func newSimulcastTrack(codec webrtc.RTPCodecCapability, id string, streamId string) *simulcastTrack {
+	return &simulcastTrack{
+		id:        id,
+		streamId:  streamId,
+		codec:     codec,
+		layers:    make(map[VideoQuality]simulcastLayerSource),
+		bindings:  make(map[string]*simulcastBinding),
+		qualities: make(map[uint32]VideoQuality),
+	}
+}
+
+// Bind is called by the PeerConnection after negotiation is complete
+func (t *simulcastTrack) Bind(ctx webrtc.TrackLocalContext) (webrtc.RTPCodecParameters, error) {
+	codec, err := t.findCodec(ctx.CodecParameters())
+	if err != nil {
+		return webrtc.RTPCodecParameters{}, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	target, ok := t.qualities[uint32(ctx.SSRC())]
+	if !ok {
+		target = VideoQuality_HIGH
+	}
+	t.bindings[ctx.ID()] = &simulcastBinding{
+		ssrc:        uint32(ctx.SSRC()),
+		payloadType: uint8(codec.PayloadType),
+		writeStream: ctx.WriteStream(),
+		target:      target,
+	}
+	return codec, nil
+}
+
+// Unbind is called by the PeerConnection when the track is removed from the connection
+func (t *simulcastTrack) Unbind(ctx webrtc.TrackLocalContext) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	binding, ok := t.bindings[ctx.ID()]
+	if !ok {
+		return webrtc.ErrUnbindFailed
+	}
+	delete(t.qualities, binding.ssrc)
+	delete(t.bindings, ctx.ID())
+	return nil
+}
+
+func (t *simulcastTrack) ID() string { return t.id }
+
+// RID is empty, because the subscriber receives only one layer
+func (t *simulcastTrack) RID() string { return "" }
+
+func (t *simulcastTrack) StreamID() string { return t.streamId }
+
+func (t *simulcastTrack) Kind() webrtc.RTPCodecType { return webrtc.RTPCodecTypeVideo }
+
+func (t *simulcastTrack) Codec() webrtc.RTPCodecCapability { return t.codec }
+
+func (t *simulcastTrack) findCodec(codecs []webrtc.RTPCodecParameters) (webrtc.RTPCodecParameters, error) {
+	for _, c := range codecs {
+		if strings.EqualFold(c.MimeType, t.codec.MimeType) && c.SDPFmtpLine == t.codec.SDPFmtpLine {
+			return c, nil
+		}
+	}
+	for _, c := range codecs {
+		if strings.EqualFold(c.MimeType, t.codec.MimeType) {
+			return c, nil
+		}
+	}
+	return webrtc.RTPCodecParameters{}, webrtc.ErrUnsupportedCodec
+}
+
+// addLayer registers a new rid of the remote track. Well known rids are mapped to their quality,
+// all other rids get the next free quality beginning with the lowest.
+func (t *simulcastTrack) addLayer(rid string, ssrc uint32) (VideoQuality, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, layer := range t.layers {
+		if layer.rid == rid {
+			return VideoQuality_OFF, ErrLayerAlreadyExists
+		}
+	}
+
+	quality, ok := ridToVideoQuality(rid)
+	if !ok {
+		for quality = VideoQuality_LOW; quality < VideoQuality_OFF; quality++ {
+			if _, used := t.layers[quality]; !used {
+				break
+			}
+		}
+	}
+	if quality == VideoQuality_OFF {
+		return VideoQuality_OFF, errNoFreeLayer
+	}
+	if _, used := t.layers[quality]; used {
+		return VideoQuality_OFF, ErrLayerAlreadyExists
+	}
+	t.layers[quality] = simulcastLayerSource{rid: rid, ssrc: ssrc}
+	return quality, nil
+}
+
+// removeLayer removes a layer and returns the number of remaining layers
+func (t *simulcastTrack) removeLayer(quality VideoQuality) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.layers, quality)
+	return len(t.layers)
+}
+
+// getQualities returns the qualities of the available layers, ordered from low to high
+func (t *simulcastTrack) getQualities() []VideoQuality {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	qualities := make([]VideoQuality, 0, len(t.layers))
+	for quality := VideoQuality_LOW; quality < VideoQuality_OFF; quality++ {
+		if _, ok := t.layers[quality]; ok {
+			qualities = append(qualities, quality)
+		}
+	}
+	return qualities
+}
+
+// setQuality selects the layer for the sender with the given ssrc.
+// The switch happens with the next keyframe of the selected layer.
+func (t *simulcastTrack) setQuality(ssrc webrtc.SSRC, quality VideoQuality) {
+	t.mu.Lock()
+	t.qualities[uint32(ssrc)] = quality
+	changed := false
+	for _, binding := range t.bindings {
+		if binding.ssrc == uint32(ssrc) && binding.getTarget() != quality {
+			binding.setTarget(quality)
+			changed = true
+		}
+	}
+	layer, found := t.layers[t.resolveQuality(quality)]
+	t.mu.Unlock()
+
+	// the switch needs a keyframe of the new layer, so we do not wait for the next one
+	if changed && found && t.onKeyframeNeeded != nil {
+		t.onKeyframeNeeded(layer.ssrc)
+	}
+}
+
+// ingressSsrc returns the ssrc of the layer the binding is currently forwarding or waiting for.
+// This is the layer a keyframe request of the subscriber belongs to.
+func (t *simulcastTrack) ingressSsrc(egressSsrc uint32) (uint32, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, binding := range t.bindings {
+		if binding.ssrc != egressSsrc {
+			continue
+		}
+		layer, ok := t.layers[t.resolveQuality(binding.getTarget())]
+		return layer.ssrc, ok
+	}
+	return 0, false
+}
+
+func (t *simulcastTrack) allIngressSsrc() []uint32 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	ssrcs := make([]uint32, 0, len(t.layers))
+	for _, layer := range t.layers {
+		ssrcs = append(ssrcs, layer.ssrc)
+	}
+	return ssrcs
+}
+
+// ingressSequence maps a sequence number written to a binding back to the ingress layer
+func (t *simulcastTrack) ingressSequence(egressSsrc uint32, seq uint16) (uint32, uint16, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, binding := range t.bindings {
+		if binding.ssrc != egressSsrc {
+			continue
+		}
+		quality, seqOffset, ok := binding.getForwarding()
+		if !ok {
+			return 0, 0, false
+		}
+		layer, ok := t.layers[quality]
+		return layer.ssrc, seq + seqOffset, ok
+	}
+	return 0, 0, false
+}
+
+// resolveQuality returns the available layer nearest to the wanted quality, preferring lower layers.
+// The caller must hold the lock.
+func (t *simulcastTrack) resolveQuality(wanted VideoQuality) VideoQuality {
+	if wanted == VideoQuality_OFF {
+		return VideoQuality_OFF
+	}
+	for q := wanted; q >= VideoQuality_LOW; q-- {
+		if _, ok := t.layers[q]; ok {
+			return q
+		}
+	}
+	for q := wanted + 1; q < VideoQuality_OFF; q++ {
+		if _, ok := t.layers[q]; ok {
+			return q
+		}
+	}
+	return VideoQuality_OFF
+}
+
+func (t *simulcastTrack) layerWriter(quality VideoQuality) io.Writer {
+	return &simulcastLayerWriter{track: t, quality: quality}
+}
+
+func (t *simulcastTrack) writeRtp(quality VideoQuality, buf []byte) (int, error) {
+	packet := &rtp.Packet{}
+	if err := packet.Unmarshal(buf); err != nil {
+		return 0, fmt.Errorf("unmarshal rtp packet: %w", err)
+	}
+	keyframe := isKeyframe(t.codec.MimeType, packet.Payload)
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var errs []error
+	for _, binding := range t.bindings {
+		target := t.resolveQuality(binding.getTarget())
+		if err := binding.write(quality, target, keyframe, packet.Header, packet.Payload, t.codec.ClockRate); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return len(buf), errors.Join(errs...)
+}
+
+type simulcastLayerWriter struct {
+	track   *simulcastTrack
+	quality VideoQuality
+}
+
+func (w *simulcastLayerWriter) Write(buf []byte) (int, error) {
+	return w.track.writeRtp(w.quality, buf)
+}
+
+type simulcastBinding struct {
+	mu          sync.Mutex
+	ssrc        uint32
+	payloadType uint8
+	writeStream webrtc.TrackLocalWriter
+	target      VideoQuality
+	current     VideoQuality
+	forwarding  bool
+	started     bool
+	seqOffset   uint16
+	tsOffset    uint32
+	lastSeq     uint16
+	lastTs      uint32
+	lastWrite   time.Time
+}
+
+func (b *simulcastBinding) setTarget(quality VideoQuality) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.target = quality
+}
+
+func (b *simulcastBinding) getTarget() VideoQuality {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.target
+}
+
+func (b *simulcastBinding) getForwarding() (VideoQuality, uint16, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.current, b.seqOffset, b.forwarding
+}
+
+func (b *simulcastBinding) write(quality VideoQuality, target VideoQuality, keyframe bool, header rtp.Header, payload []byte, clockRate uint32) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if target == VideoQuality_OFF {
+		b.forwarding = false
+		return nil
+	}
+
+	if !b.forwarding || quality != b.current {
+		if quality != target || !keyframe {
+			return nil
+		}
+		b.switchLayer(quality, header, clockRate)
+	}
+
+	header.SequenceNumber -= b.seqOffset
+	header.Timestamp -= b.tsOffset
+	header.SSRC = b.ssrc
+	header.PayloadType = b.payloadType
+
+	if !b.started || int16(header.SequenceNumber-b.lastSeq) > 0 {
+		b.lastSeq = header.SequenceNumber
+		b.lastTs = header.Timestamp
+		b.lastWrite = time.Now()
+	}
+	b.started = true
+
+	if _, err := b.writeStream.WriteRTP(&header, payload); err != nil {
+		return err
+	}
+	return nil
+}
+
+// switchLayer calculates the offsets for the new layer, so that sequence numbers and timestamps
+// continue after the last packet written for the previous layer
+func (b *simulcastBinding) switchLayer(quality VideoQuality, header rtp.Header, clockRate uint32) {
+	slog.Debug("rtp.simulcastTrack: switch layer", "ssrc", b.ssrc, "from", b.current, "to", quality)
+	b.current = quality
+	b.forwarding = true
+	if !b.started {
+		return
+	}
+
+	ticks := uint32(time.Since(b.lastWrite).Seconds() * float64(clockRate))
+	if ticks == 0 {
+		ticks = 1
+	}
+	b.seqOffset = header.SequenceNumber - (b.lastSeq + 1)
+	b.tsOffset = header.Timestamp - (b.lastTs + ticks)
+}
+
+func ridToVideoQuality(rid string) (VideoQuality, bool) {
+	switch rid {
+	case "q":
+		return VideoQuality_LOW, true
+	case "h":
+		return VideoQuality_MEDIUM, true
+	case "f":
+		return VideoQuality_HIGH, true
+	default:
+		return VideoQuality_OFF, false
+	}
+}
@@ -0,0 +1,128 @@
+package rtp
+
+import (
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	vp8Keyframe = []byte{0x10, 0x00}
+	vp8Delta    = []byte{0x10, 0x01}
+)
+
+type testTrackLocalWriter struct {
+	headers []rtp.Header
+}
+
+func (w *testTrackLocalWriter) WriteRTP(header *rtp.Header, _ []byte) (int, error) {
+	w.headers = append(w.headers, *header)
+	return 0, nil
+}
+
+func (w *testTrackLocalWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func testSimulcastTrackSetup(t *testing.T) (*simulcastTrack, *testTrackLocalWriter) {
+	t.Helper()
+	codec := webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000}
+	track := newSimulcastTrack(codec, "track", "stream")
+	writer := &testTrackLocalWriter{}
+	_, err := track.Bind(NewTrackLocalContext("binding", webrtc.SSRC(1234), []webrtc.RTPCodecParameters{
+		{RTPCodecCapability: codec, PayloadType: 96},
+	}, writer))
+	assert.NoError(t, err)
+	return track, writer
+}
+
+func testSimulcastPacket(t *testing.T, ssrc uint32, seq uint16, ts uint32, payload []byte) []byte {
+	t.Helper()
+	pkt := &rtp.Packet{
+		Header:  rtp.Header{Version: 2, SSRC: ssrc, SequenceNumber: seq, Timestamp: ts, PayloadType: 100},
+		Payload: payload,
+	}
+	buf, err := pkt.Marshal()
+	assert.NoError(t, err)
+	return buf
+}
+
+func TestSimulcastTrack(t *testing.T) {
+	t.Run("map rids to layers", func(t *testing.T) {
+		track, _ := testSimulcastTrackSetup(t)
+		quality, err := track.addLayer("f", 3)
+		assert.NoError(t, err)
+		assert.Equal(t, VideoQuality_HIGH, quality)
+
+		quality, err = track.addLayer("unknown", 4)
+		assert.NoError(t, err)
+		assert.Equal(t, VideoQuality_LOW, quality)
+
+		_, err = track.addLayer("f", 3)
+		assert.ErrorIs(t, err, ErrLayerAlreadyExists)
+		assert.Equal(t, []VideoQuality{VideoQuality_LOW, VideoQuality_HIGH}, track.getQualities())
+	})
+
+	t.Run("forward only the best available layer", func(t *testing.T) {
+		track, writer := testSimulcastTrackSetup(t)
+		low, _ := track.addLayer("q", 1)
+		medium, _ := track.addLayer("h", 2)
+
+		_, _ = track.layerWriter(low).Write(testSimulcastPacket(t, 1, 10, 100, vp8Keyframe))
+		_, _ = track.layerWriter(medium).Write(testSimulcastPacket(t, 2, 500, 9000, vp8Keyframe))
+		_, _ = track.layerWriter(low).Write(testSimulcastPacket(t, 1, 11, 200, vp8Delta))
+
+		assert.Len(t, writer.headers, 1)
+		assert.Equal(t, uint32(1234), writer.headers[0].SSRC)
+		assert.Equal(t, uint8(96), writer.headers[0].PayloadType)
+		assert.Equal(t, uint16(500), writer.headers[0].SequenceNumber)
+	})
+
+	t.Run("switch layer on keyframe and rewrite sequence numbers", func(t *testing.T) {
+		track, writer := testSimulcastTrackSetup(t)
+		low, _ := track.addLayer("q", 1)
+		high, _ := track.addLayer("f", 3)
+		track.setQuality(webrtc.SSRC(1234), VideoQuality_LOW)
+
+		_, _ = track.layerWriter(low).Write(testSimulcastPacket(t, 1, 10, 100, vp8Keyframe))
+		_, _ = track.layerWriter(low).Write(testSimulcastPacket(t, 1, 11, 100, vp8Delta))
+		assert.Len(t, writer.headers, 2)
+
+		track.setQuality(webrtc.SSRC(1234), VideoQuality_HIGH)
+		// no keyframe, stay on the low layer
+		_, _ = track.layerWriter(high).Write(testSimulcastPacket(t, 3, 7000, 50000, vp8Delta))
+		_, _ = track.layerWriter(low).Write(testSimulcastPacket(t, 1, 12, 3100, vp8Delta))
+		assert.Len(t, writer.headers, 3)
+
+		// keyframe, switch to the high layer
+		_, _ = track.layerWriter(high).Write(testSimulcastPacket(t, 3, 7001, 53000, vp8Keyframe))
+		_, _ = track.layerWriter(low).Write(testSimulcastPacket(t, 1, 13, 6100, vp8Delta))
+		_, _ = track.layerWriter(high).Write(testSimulcastPacket(t, 3, 7002, 53000, vp8Delta))
+		assert.Len(t, writer.headers, 5)
+
+		assert.Equal(t, uint16(13), writer.headers[3].SequenceNumber)
+		assert.Equal(t, uint16(14), writer.headers[4].SequenceNumber)
+		assert.Greater(t, writer.headers[3].Timestamp, writer.headers[2].Timestamp)
+		assert.Equal(t, writer.headers[3].Timestamp, writer.headers[4].Timestamp)
+	})
+
+	t.Run("pause video", func(t *testing.T) {
+		track, writer := testSimulcastTrackSetup(t)
+		low, _ := track.addLayer("q", 1)
+		track.setQuality(webrtc.SSRC(1234), VideoQuality_OFF)
+		_, _ = track.layerWriter(low).Write(testSimulcastPacket(t, 1, 10, 100, vp8Keyframe))
+		assert.Len(t, writer.headers, 0)
+	})
+
+	t.Run("detect keyframes", func(t *testing.T) {
+		assert.True(t, isKeyframe(webrtc.MimeTypeVP8, vp8Keyframe))
+		assert.False(t, isKeyframe(webrtc.MimeTypeVP8, vp8Delta))
+		assert.True(t, isKeyframe(webrtc.MimeTypeH264, []byte{0x65}))
+		assert.True(t, isKeyframe(webrtc.MimeTypeH264, []byte{0x7c, 0x85}))
+		assert.False(t, isKeyframe(webrtc.MimeTypeH264, []byte{0x41}))
+		assert.True(t, isKeyframe(webrtc.MimeTypeVP9, []byte{0x08}))
+		assert.False(t, isKeyframe(webrtc.MimeTypeOpus, vp8Keyframe))
+	})
+}
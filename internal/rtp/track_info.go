@@ -7,10 +7,14 @@ import (
 
 type TrackInfo struct {
 	TrackSdpInfo
-	Track *webrtc.TrackLocalStaticRTP
+	Track webrtc.TrackLocal
+	// feedback forwards the rtcp of egress endpoints to the ingress endpoint of the track
+	feedback *rtcpForwarder
+	// Codec is negotiated with the publisher of the track
+	Codec webrtc.RTPCodecParameters
 }
 
-func newTrackInfo(track *webrtc.TrackLocalStaticRTP, sdpInfo TrackSdpInfo) *TrackInfo {
+func newTrackInfo(track webrtc.TrackLocal, sdpInfo TrackSdpInfo) *TrackInfo {
 	return &TrackInfo{
 		Track:        track,
 		TrackSdpInfo: sdpInfo,
@@ -29,12 +33,14 @@ func (t *TrackInfo) GetSessionId() uuid.UUID {
 	return t.SessionId
 }
 
-func (t *TrackInfo) GetTrack() *webrtc.TrackLocalStaticRTP {
+func (t *TrackInfo) GetTrackLocal() webrtc.TrackLocal {
 	return t.Track
 }
 
-func (t *TrackInfo) GetTrackLocal() webrtc.TrackLocal {
-	return t.Track
+// GetCodec returns the codec and payload type negotiated with the publisher of the track.
+// Receivers forwarding the packets unchanged, like the live stream sender, need to describe it.
+func (t *TrackInfo) GetCodec() webrtc.RTPCodecParameters {
+	return t.Codec
 }
 
 func (t *TrackInfo) GetMute() bool {
@@ -47,6 +53,23 @@ func (t *TrackInfo) GetEgressMid() string {
 	return t.EgressMid
 }
 
+// RequestKeyframe asks the publisher of a video track for a keyframe.
+// Receivers without own rtcp feedback, like packagers, need it to start a stream or segment.
+func (t *TrackInfo) RequestKeyframe() {
+	if t.feedback != nil {
+		t.feedback.requestAllKeyframes()
+	}
+}
+
+// GetLayers returns the qualities of the simulcast layers of a video track, ordered from low to high.
+// Tracks without simulcast have no layers.
+func (t *TrackInfo) GetLayers() []VideoQuality {
+	if track, ok := t.Track.(*simulcastTrack); ok {
+		return track.getQualities()
+	}
+	return nil
+}
+
 func (t *TrackInfo) SetMute(mute bool) {
 	t.Mute = mute
 }
@@ -0,0 +1,60 @@
+package rtp
+
+import (
+	"github.com/pion/interceptor"
+	"github.com/pion/webrtc/v3"
+)
+
+// TrackLocalContext binds a track local of the hub to a consumer of the media, which is not a peer connection,
+// like the live stream sender, packagers, recorders or the compositor
+type TrackLocalContext struct {
+	id              string
+	params          webrtc.RTPParameters
+	ssrc            webrtc.SSRC
+	writeStream     webrtc.TrackLocalWriter
+	rtcpInterceptor interceptor.RTCPReader
+}
+
+// NewTrackLocalContext returns a binding with the codecs the consumer accepts, the consumer receives the packets with its write stream
+func NewTrackLocalContext(id string, ssrc webrtc.SSRC, codecs []webrtc.RTPCodecParameters, writeStream webrtc.TrackLocalWriter) *TrackLocalContext {
+	return &TrackLocalContext{
+		id:          id,
+		params:      webrtc.RTPParameters{Codecs: codecs},
+		ssrc:        ssrc,
+		writeStream: writeStream,
+	}
+}
+
+// CodecParameters returns the negotiated RTPCodecParameters. These are the codecs supported by both
+// PeerConnections and the SSRC/PayloadTypes
+func (t *TrackLocalContext) CodecParameters() []webrtc.RTPCodecParameters {
+	return t.params.Codecs
+}
+
+// HeaderExtensions returns the negotiated RTPHeaderExtensionParameters. These are the header extensions supported by
+// both PeerConnections and the SSRC/PayloadTypes
+func (t *TrackLocalContext) HeaderExtensions() []webrtc.RTPHeaderExtensionParameter {
+	return t.params.HeaderExtensions
+}
+
+// SSRC requires the negotiated SSRC of this track
+// This track may have multiple if RTX is enabled
+func (t *TrackLocalContext) SSRC() webrtc.SSRC {
+	return t.ssrc
+}
+
+// WriteStream returns the WriteStream for this TrackLocal. The implementer writes the outbound
+// media packets to it
+func (t *TrackLocalContext) WriteStream() webrtc.TrackLocalWriter {
+	return t.writeStream
+}
+
+// ID is a unique identifier that is used for both Bind/Unbind
+func (t *TrackLocalContext) ID() string {
+	return t.id
+}
+
+// RTCPReader returns the RTCP interceptor for this TrackLocal. Used to read RTCP of this TrackLocal.
+func (t *TrackLocalContext) RTCPReader() interceptor.RTCPReader {
+	return t.rtcpInterceptor
+}
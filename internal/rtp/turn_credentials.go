@@ -0,0 +1,25 @@
+package rtp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"time"
+)
+
+// NewTurnCredentials creates short-lived credentials of the TURN REST API.
+// The username is "<expiry as unix time>:<user>", the password is the base64 encoded HMAC-SHA1 of the username with the shared secret.
+// So a TURN server with the same secret checks the credentials without knowing the users.
+func NewTurnCredentials(secret string, user string, ttl time.Duration) (username string, password string, expires time.Time) {
+	expires = time.Now().Add(ttl)
+	username = fmt.Sprintf("%d:%s", expires.Unix(), user)
+	return username, TurnPassword(secret, username), expires
+}
+
+// TurnPassword returns the password of a username of the TURN REST API
+func TurnPassword(secret string, username string) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(username))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
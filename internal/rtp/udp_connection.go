@@ -1,11 +1,16 @@
 package rtp
 
-import "net"
+import (
+	"net"
+
+	"github.com/pion/webrtc/v3"
+)
 
 type UdpConnection struct {
 	conn        *net.UDPConn
 	port        int
 	payloadType uint8
+	codec       webrtc.RTPCodecCapability
 }
 
 type UdpShare struct {
@@ -0,0 +1,172 @@
+package rtp
+
+import (
+	"sync"
+	"time"
+)
+
+// minimal bitrates in bit per second (bps) a video track needs for a layer
+var videoQualityBitrates = []struct {
+	quality VideoQuality
+	bitrate int
+}{
+	{VideoQuality_HIGH, 1_200_000},
+	{VideoQuality_MEDIUM, 500_000},
+	{VideoQuality_LOW, 100_000},
+}
+
+const (
+	// upgradeHeadroom avoids switching up to a layer the subscriber only just can receive
+	upgradeHeadroom = 1.25
+	// upgradeHoldTime is the time the estimation must allow a better layer, before switching up
+	upgradeHoldTime = 3 * time.Second
+	// probeInterval is the time on a lower layer, before the next higher layer is probed
+	probeInterval = 10 * time.Second
+	// maxProbeInterval limits the backoff of failed probes
+	maxProbeInterval = 2 * time.Minute
+	// probeDuration is the time the estimation gets to follow the bitrate of a probed layer
+	probeDuration = 5 * time.Second
+	// probeCheckInterval is the interval an endpoint checks, whether a probe is due or over
+	probeCheckInterval = time.Second
+)
+
+// videoQualityAdapter selects the simulcast layer of an egress endpoint by the estimated bandwidth.
+// Switching down is done immediately to avoid stalling, switching up only if the bandwidth is stable.
+//
+// The congestion control estimates at most 1.5 times the bitrate that is actually sent. Without padding
+// the estimation never allows a higher layer than the current one, so the adapter probes the next higher
+// layer from time to time. A probe that congests the connection doubles the interval until the next probe.
+type videoQualityAdapter struct {
+	mu            sync.Mutex
+	quality       VideoQuality
+	bitrate       int
+	videoTracks   int
+	upgradeSince  time.Time
+	switchedAt    time.Time
+	probeSince    time.Time
+	probeBitrate  int
+	probeInterval time.Duration
+	now           func() time.Time
+}
+
+func newVideoQualityAdapter() *videoQualityAdapter {
+	return &videoQualityAdapter{
+		quality:       VideoQuality_HIGH,
+		probeInterval: probeInterval,
+		now:           time.Now,
+	}
+}
+
+// update returns the selected quality for the bandwidth shared by all video tracks and whether the quality changed
+func (a *videoQualityAdapter) update(bitrate int, videoTracks int) (VideoQuality, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if videoTracks < 1 {
+		videoTracks = 1
+	}
+	a.bitrate = bitrate
+	a.videoTracks = videoTracks
+	return a.adapt()
+}
+
+func (a *videoQualityAdapter) adapt() (VideoQuality, bool) {
+	perTrack := a.bitrate / a.videoTracks
+	wanted := qualityForBitrate(perTrack)
+
+	if !a.probeSince.IsZero() {
+		if a.now().Sub(a.probeSince) < probeDuration && a.bitrate >= a.probeBitrate {
+			// the estimation follows the bitrate of the probed layer
+			return a.quality, false
+		}
+		a.probeSince = time.Time{}
+		if isLowerQuality(wanted, a.quality) {
+			a.probeInterval = min(2*a.probeInterval, maxProbeInterval)
+		} else {
+			a.probeInterval = probeInterval
+		}
+	}
+
+	switch {
+	case isLowerQuality(wanted, a.quality):
+		a.switchTo(wanted)
+		return a.quality, true
+	case isLowerQuality(a.quality, wanted):
+		wanted = qualityForBitrate(int(float64(perTrack) / upgradeHeadroom))
+		if !isLowerQuality(a.quality, wanted) {
+			a.upgradeSince = time.Time{}
+			return a.quality, false
+		}
+		if a.upgradeSince.IsZero() {
+			a.upgradeSince = a.now()
+			return a.quality, false
+		}
+		if a.now().Sub(a.upgradeSince) < upgradeHoldTime {
+			return a.quality, false
+		}
+		a.switchTo(wanted)
+		a.probeInterval = probeInterval
+		return a.quality, true
+	default:
+		a.upgradeSince = time.Time{}
+		return a.quality, false
+	}
+}
+
+// probe switches up to the next higher layer, if the quality was not switched for the probe interval.
+// A probe ends after the probe duration or earlier if the estimation drops, then the estimation must allow the probed layer.
+func (a *videoQualityAdapter) probe() (VideoQuality, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.probeSince.IsZero() {
+		if a.now().Sub(a.probeSince) < probeDuration {
+			return a.quality, false
+		}
+		return a.adapt()
+	}
+	if a.videoTracks == 0 || a.quality == VideoQuality_HIGH || a.now().Sub(a.switchedAt) < a.probeInterval {
+		return a.quality, false
+	}
+	a.switchTo(nextHigherQuality(a.quality))
+	a.probeSince = a.switchedAt
+	a.probeBitrate = a.bitrate
+	return a.quality, true
+}
+
+func (a *videoQualityAdapter) switchTo(quality VideoQuality) {
+	a.quality = quality
+	a.upgradeSince = time.Time{}
+	a.switchedAt = a.now()
+}
+
+func qualityForBitrate(bitrate int) VideoQuality {
+	for _, layer := range videoQualityBitrates {
+		if bitrate >= layer.bitrate {
+			return layer.quality
+		}
+	}
+	return VideoQuality_OFF
+}
+
+func nextHigherQuality(quality VideoQuality) VideoQuality {
+	switch quality {
+	case VideoQuality_OFF:
+		return VideoQuality_LOW
+	case VideoQuality_LOW:
+		return VideoQuality_MEDIUM
+	default:
+		return VideoQuality_HIGH
+	}
+}
+
+// isLowerQuality compares qualities, where off is the lowest
+func isLowerQuality(q VideoQuality, than VideoQuality) bool {
+	rank := func(v VideoQuality) int {
+		if v == VideoQuality_OFF {
+			return -1
+		}
+		return int(v)
+	}
+	return rank(q) < rank(than)
+}
@@ -0,0 +1,167 @@
+package rtp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testVideoQualityAdapterSetup(t *testing.T) (*videoQualityAdapter, func(d time.Duration)) {
+	t.Helper()
+	now := time.Now()
+	adapter := newVideoQualityAdapter()
+	adapter.now = func() time.Time { return now }
+	return adapter, func(d time.Duration) {
+		adapter.mu.Lock()
+		defer adapter.mu.Unlock()
+		now = now.Add(d)
+	}
+}
+
+func TestVideoQualityAdapter(t *testing.T) {
+	t.Run("switch down immediately", func(t *testing.T) {
+		adapter, _ := testVideoQualityAdapterSetup(t)
+
+		quality, changed := adapter.update(600_000, 1)
+		assert.True(t, changed)
+		assert.Equal(t, VideoQuality_MEDIUM, quality)
+
+		quality, changed = adapter.update(50_000, 1)
+		assert.True(t, changed)
+		assert.Equal(t, VideoQuality_OFF, quality)
+	})
+
+	t.Run("keep quality if the bandwidth allows it", func(t *testing.T) {
+		adapter, _ := testVideoQualityAdapterSetup(t)
+
+		quality, changed := adapter.update(2_000_000, 1)
+		assert.False(t, changed)
+		assert.Equal(t, VideoQuality_HIGH, quality)
+	})
+
+	t.Run("share the bandwidth between all video tracks", func(t *testing.T) {
+		adapter, _ := testVideoQualityAdapterSetup(t)
+
+		quality, changed := adapter.update(2_000_000, 3)
+		assert.True(t, changed)
+		assert.Equal(t, VideoQuality_MEDIUM, quality)
+	})
+
+	t.Run("switch up after the hold time", func(t *testing.T) {
+		adapter, advance := testVideoQualityAdapterSetup(t)
+		_, _ = adapter.update(200_000, 1)
+
+		quality, changed := adapter.update(2_000_000, 1)
+		assert.False(t, changed)
+		assert.Equal(t, VideoQuality_LOW, quality)
+
+		advance(upgradeHoldTime - time.Millisecond)
+		_, changed = adapter.update(2_000_000, 1)
+		assert.False(t, changed)
+
+		advance(time.Millisecond)
+		quality, changed = adapter.update(2_000_000, 1)
+		assert.True(t, changed)
+		assert.Equal(t, VideoQuality_HIGH, quality)
+	})
+
+	t.Run("restart the hold time if the bandwidth drops", func(t *testing.T) {
+		adapter, advance := testVideoQualityAdapterSetup(t)
+		_, _ = adapter.update(200_000, 1)
+		_, _ = adapter.update(2_000_000, 1)
+
+		advance(upgradeHoldTime / 2)
+		_, changed := adapter.update(200_000, 1)
+		assert.False(t, changed)
+
+		_, _ = adapter.update(2_000_000, 1)
+		advance(upgradeHoldTime / 2)
+		quality, changed := adapter.update(2_000_000, 1)
+		assert.False(t, changed)
+		assert.Equal(t, VideoQuality_LOW, quality)
+	})
+
+	t.Run("switch up only with headroom", func(t *testing.T) {
+		adapter, advance := testVideoQualityAdapterSetup(t)
+		_, _ = adapter.update(200_000, 1)
+
+		// enough for medium, but without headroom
+		_, _ = adapter.update(550_000, 1)
+		advance(upgradeHoldTime)
+		quality, changed := adapter.update(550_000, 1)
+		assert.False(t, changed)
+		assert.Equal(t, VideoQuality_LOW, quality)
+
+		_, _ = adapter.update(650_000, 1)
+		advance(upgradeHoldTime)
+		quality, changed = adapter.update(650_000, 1)
+		assert.True(t, changed)
+		assert.Equal(t, VideoQuality_MEDIUM, quality)
+	})
+	t.Run("probe the next higher layer after the probe interval", func(t *testing.T) {
+		adapter, advance := testVideoQualityAdapterSetup(t)
+		_, _ = adapter.update(90_000, 1)
+
+		advance(probeInterval - time.Millisecond)
+		_, changed := adapter.probe()
+		assert.False(t, changed)
+
+		advance(time.Millisecond)
+		quality, changed := adapter.probe()
+		assert.True(t, changed)
+		assert.Equal(t, VideoQuality_LOW, quality)
+
+		// the estimation has not reached the probed layer yet
+		quality, changed = adapter.update(95_000, 1)
+		assert.False(t, changed)
+		assert.Equal(t, VideoQuality_LOW, quality)
+
+		advance(probeDuration)
+		quality, changed = adapter.update(120_000, 1)
+		assert.False(t, changed)
+		assert.Equal(t, VideoQuality_LOW, quality)
+	})
+
+	t.Run("end the probe if the estimation drops", func(t *testing.T) {
+		adapter, advance := testVideoQualityAdapterSetup(t)
+		_, _ = adapter.update(90_000, 1)
+		advance(probeInterval)
+		_, _ = adapter.probe()
+
+		quality, changed := adapter.update(80_000, 1)
+		assert.True(t, changed)
+		assert.Equal(t, VideoQuality_OFF, quality)
+
+		// the failed probe doubles the probe interval
+		advance(probeInterval)
+		_, changed = adapter.probe()
+		assert.False(t, changed)
+		advance(probeInterval)
+		quality, changed = adapter.probe()
+		assert.True(t, changed)
+		assert.Equal(t, VideoQuality_LOW, quality)
+	})
+
+	t.Run("end the probe without new estimation", func(t *testing.T) {
+		adapter, advance := testVideoQualityAdapterSetup(t)
+		_, _ = adapter.update(90_000, 1)
+		advance(probeInterval)
+		_, _ = adapter.probe()
+
+		advance(probeDuration)
+		quality, changed := adapter.probe()
+		assert.True(t, changed)
+		assert.Equal(t, VideoQuality_OFF, quality)
+	})
+
+	t.Run("do not probe above the high layer", func(t *testing.T) {
+		adapter, advance := testVideoQualityAdapterSetup(t)
+		_, _ = adapter.update(2_000_000, 1)
+
+		advance(probeInterval)
+		quality, changed := adapter.probe()
+		assert.False(t, changed)
+		assert.Equal(t, VideoQuality_HIGH, quality)
+	})
+}
@@ -5,22 +5,30 @@ import (
 
 	"github.com/shigde/sfu/internal/activitypub/instance"
 	"github.com/shigde/sfu/internal/auth"
+	"github.com/shigde/sfu/internal/compositor"
+	"github.com/shigde/sfu/internal/hls"
 	"github.com/shigde/sfu/internal/logging"
 	"github.com/shigde/sfu/internal/metric"
+	"github.com/shigde/sfu/internal/record"
 	"github.com/shigde/sfu/internal/rtp"
 	"github.com/shigde/sfu/internal/storage"
 	"github.com/shigde/sfu/internal/telemetry"
+	"github.com/shigde/sfu/internal/turn"
 )
 
 type Config struct {
-	*ServerConfig              `mapstructure:"server"`
-	*auth.SecurityConfig       `mapstructure:"security"`
-	*storage.StorageConfig     `mapstructure:"store"`
-	*logging.LogConfig         `mapstructure:"log"`
-	*metric.MetricConfig       `mapstructure:"metric"`
-	*telemetry.TelemetryConfig `mapstructure:"telemetry"`
-	*rtp.RtpConfig             `mapstructure:"rtp"`
-	*instance.FederationConfig `mapstructure:"federation"`
+	*ServerConfig                `mapstructure:"server"`
+	*auth.SecurityConfig         `mapstructure:"security"`
+	*storage.StorageConfig       `mapstructure:"store"`
+	*logging.LogConfig           `mapstructure:"log"`
+	*metric.MetricConfig         `mapstructure:"metric"`
+	*telemetry.TelemetryConfig   `mapstructure:"telemetry"`
+	*rtp.RtpConfig               `mapstructure:"rtp"`
+	*instance.FederationConfig   `mapstructure:"federation"`
+	*hls.HlsConfig               `mapstructure:"hls"`
+	*record.RecordConfig         `mapstructure:"record"`
+	*compositor.CompositorConfig `mapstructure:"compositor"`
+	*turn.TurnConfig             `mapstructure:"turn"`
 }
 
 type Environment struct {
@@ -10,6 +10,7 @@ import (
 
 	"github.com/shigde/sfu/internal/activitypub"
 	"github.com/shigde/sfu/internal/auth"
+	"github.com/shigde/sfu/internal/ffmpeg"
 	"github.com/shigde/sfu/internal/lobby"
 	"github.com/shigde/sfu/internal/media"
 	"github.com/shigde/sfu/internal/metric"
@@ -18,6 +19,7 @@ import (
 	"github.com/shigde/sfu/internal/storage"
 	"github.com/shigde/sfu/internal/stream"
 	"github.com/shigde/sfu/internal/telemetry"
+	"github.com/shigde/sfu/internal/turn"
 	"go.opentelemetry.io/otel/sdk/trace"
 	"golang.org/x/exp/slog"
 )
@@ -25,10 +27,12 @@ import (
 var maxRequestTime = time.Second * 5
 
 type Server struct {
-	ctx    context.Context
-	server *http.Server
-	config *Config
-	tp     *trace.TracerProvider
+	ctx        context.Context
+	cancel     context.CancelFunc
+	server     *http.Server
+	turnServer *turn.Server
+	config     *Config
+	tp         *trace.TracerProvider
 }
 
 func NewServer(ctx context.Context, config *Config) (*Server, error) {
@@ -53,14 +57,35 @@ func NewServer(ctx context.Context, config *Config) (*Server, error) {
 		return nil, fmt.Errorf("creating webrtc engine: %w", err)
 	}
 
+	// the compositor transcodes the guest tracks with ffmpeg
+	if config.CompositorConfig != nil && config.CompositorConfig.Enable {
+		codecs, err := ffmpeg.NewCompositorCodecs(config.CompositorConfig)
+		if err != nil {
+			return nil, fmt.Errorf("creating compositor codecs: %w", err)
+		}
+		config.CompositorConfig.Codecs = codecs
+	}
+
 	host, _ := url.Parse(config.FederationConfig.InstanceUrl.String())
 	host.Path = fmt.Sprintf("federation/accounts/%s", config.FederationConfig.InstanceUsername)
-	lobbyManager := lobby.NewLobbyManager(store, engine, host, config.FederationConfig.RegisterToken)
+	lobbyManager := lobby.NewLobbyManager(store, engine, host, config.FederationConfig.RegisterToken, config.HlsConfig, &config.RtpConfig.LiveStreamSender, config.RecordConfig, config.CompositorConfig, config.RtpConfig.GetReconnectGracePeriod())
 
 	streamRepo := stream.NewLiveStreamRepository(store)
 	spaceRepo := stream.NewSpaceRepository(store)
 	liveStreamService := stream.NewLiveStreamService(streamRepo, spaceRepo)
-	liveLobbyService := stream.NewLiveLobbyService(store, lobbyManager)
+
+	// federation api
+	api, err := activitypub.NewApApi(
+		config.FederationConfig,
+		store,
+		liveStreamService,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating federation api: %w", err)
+	}
+
+	// the followers of the instance are told, when a live stream starts or stops
+	liveLobbyService := stream.NewLiveLobbyService(store, lobbyManager, api.GetVideoService())
 
 	// Auth provider
 	accountRepo := auth.NewAccountRepository(store)
@@ -69,42 +94,52 @@ func NewServer(ctx context.Context, config *Config) (*Server, error) {
 	router := media.NewRouter(
 		config.SecurityConfig,
 		config.RtpConfig,
+		config.HlsConfig,
 		accountService,
 		liveStreamService,
 		liveLobbyService,
 	)
 
-	// federation api
-	api, err := activitypub.NewApApi(
-		config.FederationConfig,
-		store,
-		liveStreamService,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("creating federation api: %w", err)
-	}
-
-	if err := api.BoostrapApi(router); err != nil {
+	// the background workers of the federation stop on shutdown
+	workerCtx, cancel := context.WithCancel(ctx)
+	if err := api.BoostrapApi(workerCtx, router); err != nil {
+		cancel()
 		return nil, fmt.Errorf("boostrapping federation api: %w", err)
 	}
 
 	// monitoring
 	if err := metric.ServeMetrics(ctx, config.MetricConfig); err != nil {
+		cancel()
 		return nil, fmt.Errorf("serving metrics: %w", err)
 	}
 
 	tp, err := telemetry.NewTracerProvider(ctx, config.TelemetryConfig)
 	if err != nil {
+		cancel()
 		return nil, fmt.Errorf("starting telemetry tracer provider: %w", err)
 	}
 
+	// embedded turn server
+	var turnServer *turn.Server
+	if config.TurnConfig != nil && config.TurnConfig.Enable {
+		if turnServer, err = turn.NewServer(config.TurnConfig); err != nil {
+			cancel()
+			if shutdownErr := tp.Shutdown(ctx); shutdownErr != nil {
+				slog.Error("sfu.server: shutting down tracer provider", "err", shutdownErr)
+			}
+			return nil, fmt.Errorf("starting turn server: %w", err)
+		}
+	}
+
 	// mux := http.TimeoutHandler(router, maxRequestTime, "Request Timeout!")
 	// start server
 	return &Server{
-		ctx:    ctx,
-		server: &http.Server{Addr: fmt.Sprintf("%s:%d", config.Host, config.Port), Handler: router},
-		config: config,
-		tp:     tp,
+		ctx:        ctx,
+		cancel:     cancel,
+		server:     &http.Server{Addr: fmt.Sprintf("%s:%d", config.Host, config.Port), Handler: router},
+		turnServer: turnServer,
+		config:     config,
+		tp:         tp,
 	}, nil
 }
 
@@ -125,13 +160,22 @@ func (s *Server) Serve() error {
 	return nil
 }
 
+// Shutdown stops every part of the server, even if stopping another part fails, and returns all errors
 func (s *Server) Shutdown(ctx context.Context) error {
+	s.cancel()
+	var errs []error
 	if err := s.tp.Shutdown(ctx); err != nil {
-		return fmt.Errorf("shutting down tracer provider: %w", err)
+		errs = append(errs, fmt.Errorf("shutting down tracer provider: %w", err))
 	}
 
 	if err := s.server.Shutdown(ctx); err != nil {
-		return fmt.Errorf("shuting down http server: %w", err)
+		errs = append(errs, fmt.Errorf("shuting down http server: %w", err))
 	}
-	return nil
+
+	if s.turnServer != nil {
+		if err := s.turnServer.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("shutting down turn server: %w", err))
+		}
+	}
+	return errors.Join(errs...)
 }
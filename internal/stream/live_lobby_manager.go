@@ -5,12 +5,17 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/pion/webrtc/v3"
+	"github.com/shigde/sfu/internal/lobby"
 	"github.com/shigde/sfu/internal/lobby/resources"
+	"github.com/shigde/sfu/internal/lobby/sessions"
+	"github.com/shigde/sfu/internal/rtp"
 )
 
 type liveLobbyManager interface {
 	NewIngressResource(ctx context.Context, lobbyId uuid.UUID, user uuid.UUID, offer *webrtc.SessionDescription, option ...resources.Option) (*resources.WebRTC, error)
 	NewEgressResource(ctx context.Context, lobbyId uuid.UUID, user uuid.UUID, offer *webrtc.SessionDescription, option ...resources.Option) (*resources.WebRTC, error)
+	TrickleIce(ctx context.Context, lobbyId uuid.UUID, user uuid.UUID, fragment *rtp.SdpFragment, iceSession ...resources.IceSession) (*rtp.SdpFragment, error)
+	DeleteResource(ctx context.Context, lobbyId uuid.UUID, user uuid.UUID, resource uuid.UUID, endpointType rtp.EndpointType) (bool, error)
 	LeaveLobby(ctx context.Context, lobbyId uuid.UUID, userId uuid.UUID) (bool, error)
 
 	// Live Stream Publishing API
@@ -18,6 +23,28 @@ type liveLobbyManager interface {
 	StartLiveStream(ctx context.Context, lobbyId uuid.UUID, key string, rtmpUrl string, userId uuid.UUID) error
 	StopLiveStream(ctx context.Context, lobbyId uuid.UUID, userId uuid.UUID) error
 
+	// Recording API
+
+	StartRecording(ctx context.Context, lobbyId uuid.UUID, userId uuid.UUID) error
+	StopRecording(ctx context.Context, lobbyId uuid.UUID, userId uuid.UUID) error
+
+	// Forwarding API
+
+	SetLastN(ctx context.Context, lobbyId uuid.UUID, lastN int, userId uuid.UUID) error
+
+	// Event API
+
+	SubscribeEvents(ctx context.Context, lobbyId uuid.UUID, userId uuid.UUID) <-chan lobby.LobbyEvent
+
+	// Moderation API
+
+	MuteUser(ctx context.Context, lobbyId uuid.UUID, targetUserId uuid.UUID, kind string) error
+	KickUser(ctx context.Context, lobbyId uuid.UUID, targetUserId uuid.UUID) error
+	BanUser(ctx context.Context, lobbyId uuid.UUID, targetUserId uuid.UUID) error
+	UnbanUser(ctx context.Context, lobbyId uuid.UUID, targetUserId uuid.UUID) error
+	GetBannedUsers(ctx context.Context, lobbyId uuid.UUID) ([]uuid.UUID, error)
+	SetRole(ctx context.Context, lobbyId uuid.UUID, targetUserId uuid.UUID, role sessions.Role) error
+
 	// Deprecated API
 
 	// CreateLobbyIngressEndpoint
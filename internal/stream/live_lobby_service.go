@@ -2,42 +2,121 @@ package stream
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/google/uuid"
 	"github.com/pion/webrtc/v3"
+	"github.com/shigde/sfu/internal/activitypub/models"
+	"github.com/shigde/sfu/internal/auth"
+	"github.com/shigde/sfu/internal/lobby"
+	"github.com/shigde/sfu/internal/lobby/resources"
+	"github.com/shigde/sfu/internal/lobby/sessions"
+	"github.com/shigde/sfu/internal/rtp"
+	"golang.org/x/exp/slog"
+	"gorm.io/gorm"
 )
 
 type LiveLobbyService struct {
 	lobbyManager liveLobbyManager
 	store        storage
+	announcer    liveStreamAnnouncer
 }
 
-func NewLiveLobbyService(store storage, lobbyManager liveLobbyManager) *LiveLobbyService {
+// liveStreamAnnouncer tells the followers of this instance, when a live stream starts or stops
+type liveStreamAnnouncer interface {
+	PublishLiveState(ctx context.Context, iri string, live bool) error
+}
+
+// NewLiveLobbyService creates the service, without announcer the followers of the instance are not told about the live streams
+func NewLiveLobbyService(store storage, lobbyManager liveLobbyManager, announcer liveStreamAnnouncer) *LiveLobbyService {
 	return &LiveLobbyService{
 		store:        store,
 		lobbyManager: lobbyManager,
+		announcer:    announcer,
 	}
 }
 
-func (s *LiveLobbyService) CreateLobbyIngressEndpoint(ctx context.Context, sdp *webrtc.SessionDescription, stream *LiveStream, userId uuid.UUID) (*webrtc.SessionDescription, string, error) {
-	resource, err := s.lobbyManager.NewIngressResource(ctx, stream.Lobby.UUID, userId, sdp)
+// CreateLobbyIngressEndpoint creates the ingress resource of the user, with a resume token the user reconnects to its session
+func (s *LiveLobbyService) CreateLobbyIngressEndpoint(ctx context.Context, sdp *webrtc.SessionDescription, stream *LiveStream, userId uuid.UUID, resumeToken string) (*resources.WebRTC, error) {
+	role, err := s.resolveRole(ctx, stream, userId)
 	if err != nil {
-		return nil, "---", fmt.Errorf("accessing lobby: %w", err)
+		return nil, fmt.Errorf("resolving role: %w", err)
 	}
-	return resource.SDP, resource.Id, nil
+	resource, err := s.lobbyManager.NewIngressResource(ctx, stream.Lobby.UUID, userId, sdp, resources.Option{Role: role, ResumeToken: resumeToken})
+	if err != nil {
+		return nil, fmt.Errorf("accessing lobby: %w", err)
+	}
+	return resource, nil
 }
 
-func (s *LiveLobbyService) CreateLobbyEgressEndpoint(ctx context.Context, sdp *webrtc.SessionDescription, stream *LiveStream, userId uuid.UUID) (*webrtc.SessionDescription, string, error) {
-	resource, err := s.lobbyManager.NewEgressResource(ctx, stream.Lobby.UUID, userId, sdp)
+// CreateLobbyEgressEndpoint creates the egress resource of the user, with a resume token the user reconnects to its session
+func (s *LiveLobbyService) CreateLobbyEgressEndpoint(ctx context.Context, sdp *webrtc.SessionDescription, stream *LiveStream, userId uuid.UUID, resumeToken string) (*resources.WebRTC, error) {
+	role, err := s.resolveRole(ctx, stream, userId)
 	if err != nil {
-		return nil, "---", fmt.Errorf("accessing lobby: %w", err)
+		return nil, fmt.Errorf("resolving role: %w", err)
 	}
-	return resource.SDP, resource.Id, nil
+	resource, err := s.lobbyManager.NewEgressResource(ctx, stream.Lobby.UUID, userId, sdp, resources.Option{Role: role, ResumeToken: resumeToken})
+	if err != nil {
+		return nil, fmt.Errorf("accessing lobby: %w", err)
+	}
+	return resource, nil
+}
+
+// CreateLobbyInstanceIngressEndpoint creates the ingress resource of another Shig instance, it relays the tracks of its lobby to this lobby
+func (s *LiveLobbyService) CreateLobbyInstanceIngressEndpoint(ctx context.Context, sdp *webrtc.SessionDescription, stream *LiveStream, instanceId uuid.UUID) (*resources.WebRTC, error) {
+	if err := s.checkInstance(ctx, instanceId); err != nil {
+		return nil, err
+	}
+	resource, err := s.lobbyManager.NewIngressResource(ctx, stream.Lobby.UUID, instanceId, sdp, resources.Option{Role: sessions.RoleGuest, Instance: true})
+	if err != nil {
+		return nil, fmt.Errorf("accessing lobby: %w", err)
+	}
+	return resource, nil
+}
+
+// CreateLobbyInstanceEgressEndpoint creates the egress resource of another Shig instance, it relays the tracks of this lobby to its lobby
+func (s *LiveLobbyService) CreateLobbyInstanceEgressEndpoint(ctx context.Context, sdp *webrtc.SessionDescription, stream *LiveStream, instanceId uuid.UUID) (*resources.WebRTC, error) {
+	if err := s.checkInstance(ctx, instanceId); err != nil {
+		return nil, err
+	}
+	resource, err := s.lobbyManager.NewEgressResource(ctx, stream.Lobby.UUID, instanceId, sdp, resources.Option{Role: sessions.RoleGuest, Instance: true})
+	if err != nil {
+		return nil, fmt.Errorf("accessing lobby: %w", err)
+	}
+	return resource, nil
+}
+
+// TrickleIce adds the trickled ICE candidates of the user to the lobby and returns the ICE credentials and candidates of the lobby,
+// ICE restarts return the new ones
+func (s *LiveLobbyService) TrickleIce(ctx context.Context, stream *LiveStream, userId uuid.UUID, fragment *rtp.SdpFragment, iceSession ...resources.IceSession) (*rtp.SdpFragment, error) {
+	local, err := s.lobbyManager.TrickleIce(ctx, stream.Lobby.UUID, userId, fragment, iceSession...)
+	if errors.Is(err, lobby.ErrLobbyNotRunning) {
+		return nil, ErrLobbyNotActive
+	}
+	if err != nil {
+		return nil, fmt.Errorf("trickle ice: %w", err)
+	}
+	return local, nil
+}
+
+// DeleteResource ends the WHIP or WHEP resource of the user
+func (s *LiveLobbyService) DeleteResource(ctx context.Context, stream *LiveStream, userId uuid.UUID, resource uuid.UUID, endpointType rtp.EndpointType) (bool, error) {
+	deleted, err := s.lobbyManager.DeleteResource(ctx, stream.Lobby.UUID, userId, resource, endpointType)
+	if errors.Is(err, lobby.ErrLobbyNotRunning) {
+		return false, ErrLobbyNotActive
+	}
+	if err != nil {
+		return false, fmt.Errorf("delete resource: %w", err)
+	}
+	return deleted, nil
 }
 
 func (s *LiveLobbyService) LeaveLobby(ctx context.Context, stream *LiveStream, userId uuid.UUID) (bool, error) {
 	left, err := s.lobbyManager.LeaveLobby(ctx, stream.Lobby.UUID, userId)
+	if errors.Is(err, lobby.ErrLobbyNotRunning) {
+		return false, ErrLobbyNotActive
+	}
 	if err != nil {
 		return false, fmt.Errorf("leave lobby: %w", err)
 	}
@@ -48,6 +127,14 @@ func (s *LiveLobbyService) StartLiveStream(ctx context.Context, stream *LiveStre
 	if err := s.lobbyManager.StartLiveStream(ctx, stream.Lobby.UUID, streamInfo.StreamKey, streamInfo.RtmpUrl, userId); err != nil {
 		return fmt.Errorf("start live stream: %w", err)
 	}
+	// the replay of the live stream is recorded, if the video wants it and the instance records at all
+	if stream.Video != nil && stream.Video.LiveSaveReplay {
+		err := s.lobbyManager.StartRecording(ctx, stream.Lobby.UUID, userId)
+		if err != nil && !errors.Is(err, lobby.ErrAlreadyRecording) && !errors.Is(err, lobby.ErrRecordingDisabled) {
+			return fmt.Errorf("start recording live stream: %w", err)
+		}
+	}
+	s.publishLiveState(ctx, stream, true)
 	return nil
 }
 
@@ -55,9 +142,151 @@ func (s *LiveLobbyService) StopLiveStream(ctx context.Context, stream *LiveStrea
 	if err := s.lobbyManager.StopLiveStream(ctx, stream.Lobby.UUID, userId); err != nil {
 		return fmt.Errorf("stop live stream: %w", err)
 	}
+	if stream.Video != nil && stream.Video.LiveSaveReplay {
+		err := s.lobbyManager.StopRecording(ctx, stream.Lobby.UUID, userId)
+		if err != nil && !errors.Is(err, lobby.ErrNotRecording) && !errors.Is(err, lobby.ErrRecordingDisabled) {
+			return fmt.Errorf("stop recording live stream: %w", err)
+		}
+	}
+	s.publishLiveState(ctx, stream, false)
+	return nil
+}
+
+// publishLiveState tells the followers about the live stream, the live stream runs even if they are not told
+func (s *LiveLobbyService) publishLiveState(ctx context.Context, stream *LiveStream, live bool) {
+	if s.announcer == nil || stream.Video == nil {
+		return
+	}
+	if err := s.announcer.PublishLiveState(ctx, stream.Video.Iri, live); err != nil {
+		slog.Warn("stream.LiveLobbyService: publishing live state", "err", err, "video", stream.Video.Iri, "live", live)
+	}
+}
+
+func (s *LiveLobbyService) StartRecording(ctx context.Context, stream *LiveStream, userId uuid.UUID) error {
+	if err := s.lobbyManager.StartRecording(ctx, stream.Lobby.UUID, userId); err != nil {
+		return fmt.Errorf("start recording: %w", err)
+	}
+	return nil
+}
+
+func (s *LiveLobbyService) StopRecording(ctx context.Context, stream *LiveStream, userId uuid.UUID) error {
+	if err := s.lobbyManager.StopRecording(ctx, stream.Lobby.UUID, userId); err != nil {
+		return fmt.Errorf("stop recording: %w", err)
+	}
 	return nil
 }
 
+func (s *LiveLobbyService) SetLastN(ctx context.Context, stream *LiveStream, lastN int, userId uuid.UUID) error {
+	if err := s.lobbyManager.SetLastN(ctx, stream.Lobby.UUID, lastN, userId); err != nil {
+		return fmt.Errorf("set last-n: %w", err)
+	}
+	return nil
+}
+
+// SubscribeEvents returns the lifecycle events of the lobby until the context is done
+func (s *LiveLobbyService) SubscribeEvents(ctx context.Context, stream *LiveStream, userId uuid.UUID) <-chan lobby.LobbyEvent {
+	return s.lobbyManager.SubscribeEvents(ctx, stream.Lobby.UUID, userId)
+}
+
+func (s *LiveLobbyService) MuteUser(ctx context.Context, stream *LiveStream, targetUserId uuid.UUID, kind string) error {
+	if err := s.lobbyManager.MuteUser(ctx, stream.Lobby.UUID, targetUserId, kind); err != nil {
+		return fmt.Errorf("mute user: %w", err)
+	}
+	return nil
+}
+
+func (s *LiveLobbyService) KickUser(ctx context.Context, stream *LiveStream, targetUserId uuid.UUID) error {
+	if err := s.lobbyManager.KickUser(ctx, stream.Lobby.UUID, targetUserId); err != nil {
+		return fmt.Errorf("kick user: %w", err)
+	}
+	return nil
+}
+
+func (s *LiveLobbyService) BanUser(ctx context.Context, stream *LiveStream, targetUserId uuid.UUID) error {
+	if err := s.lobbyManager.BanUser(ctx, stream.Lobby.UUID, targetUserId); err != nil {
+		return fmt.Errorf("ban user: %w", err)
+	}
+	return nil
+}
+
+func (s *LiveLobbyService) UnbanUser(ctx context.Context, stream *LiveStream, targetUserId uuid.UUID) error {
+	if err := s.lobbyManager.UnbanUser(ctx, stream.Lobby.UUID, targetUserId); err != nil {
+		return fmt.Errorf("unban user: %w", err)
+	}
+	return nil
+}
+
+func (s *LiveLobbyService) GetBannedUsers(ctx context.Context, stream *LiveStream) ([]uuid.UUID, error) {
+	bannedUsers, err := s.lobbyManager.GetBannedUsers(ctx, stream.Lobby.UUID)
+	if err != nil {
+		return nil, fmt.Errorf("get banned users: %w", err)
+	}
+	return bannedUsers, nil
+}
+
+func (s *LiveLobbyService) SetRole(ctx context.Context, stream *LiveStream, targetUserId uuid.UUID, role sessions.Role) error {
+	if err := s.lobbyManager.SetRole(ctx, stream.Lobby.UUID, targetUserId, role); err != nil {
+		return fmt.Errorf("set role: %w", err)
+	}
+	return nil
+}
+
+// checkInstance only accounts of Shig instances are allowed to relay lobbies
+func (s *LiveLobbyService) checkInstance(ctx context.Context, userId uuid.UUID) error {
+	ctx, cancel := context.WithTimeout(ctx, queryTimeOut)
+	defer cancel()
+	tx := s.store.GetDatabase().WithContext(ctx)
+
+	var account auth.Account
+	result := tx.Preload("Actor").Where("uuid=?", userId.String()).First(&account)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return ErrNoInstance
+	}
+	if result.Error != nil {
+		return fmt.Errorf("finding account by uuid %s: %w", userId, result.Error)
+	}
+	if account.Actor == nil || account.Actor.GetActorType() != models.Application {
+		return ErrNoInstance
+	}
+	return nil
+}
+
+// resolveRole the owner of the stream video hosts the lobby, the guests of the video are guests and all other users are viewers
+func (s *LiveLobbyService) resolveRole(ctx context.Context, stream *LiveStream, userId uuid.UUID) (sessions.Role, error) {
+	if stream.Account != nil && stream.Account.UUID == userId.String() {
+		return sessions.RoleHost, nil
+	}
+	if stream.Video == nil {
+		return sessions.RoleViewer, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeOut)
+	defer cancel()
+	tx := s.store.GetDatabase().WithContext(ctx)
+
+	var account auth.Account
+	result := tx.Where("uuid=?", userId.String()).First(&account)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return sessions.RoleViewer, nil
+	}
+	if result.Error != nil {
+		return "", fmt.Errorf("finding account by uuid %s: %w", userId, result.Error)
+	}
+	if stream.Video.OwnerId == account.ActorId {
+		return sessions.RoleHost, nil
+	}
+
+	guests := tx.Model(stream.Video).Where("id=?", account.ActorId).Association("Guests")
+	count := guests.Count()
+	if guests.Error != nil {
+		return "", fmt.Errorf("finding guests of video: %w", guests.Error)
+	}
+	if count > 0 {
+		return sessions.RoleGuest, nil
+	}
+	return sessions.RoleViewer, nil
+}
+
 // InitLobbyEgressEndpoint
 // Deprecated: Because the Endpoint API is getting simpler
 func (s *LiveLobbyService) InitLobbyEgressEndpoint(ctx context.Context, stream *LiveStream, userId uuid.UUID) (*webrtc.SessionDescription, error) {
@@ -0,0 +1,218 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shigde/sfu/internal/activitypub/models"
+	"github.com/shigde/sfu/internal/auth"
+	"github.com/shigde/sfu/internal/lobby"
+	"github.com/shigde/sfu/internal/lobby/sessions"
+	"github.com/stretchr/testify/assert"
+)
+
+func testLiveLobbyServiceSetup(t *testing.T) (*LiveLobbyService, *LiveStream, *testStore) {
+	t.Helper()
+	store := newTestStore()
+	_ = store.db.AutoMigrate(&models.Actor{}, &models.Video{}, &auth.Account{})
+
+	owner := testAccount(t, store, "owner")
+	video := &models.Video{Iri: "video", Uuid: uuid.NewString(), OwnerId: owner.ActorId}
+	store.db.Create(video)
+
+	stream := &LiveStream{Video: video}
+	return NewLiveLobbyService(store, nil, nil), stream, store
+}
+
+func testAccount(t *testing.T, store *testStore, name string) *auth.Account {
+	t.Helper()
+	actor := &models.Actor{ActorIri: name, PreferredUsername: name}
+	store.db.Create(actor)
+	account := &auth.Account{User: name, UUID: uuid.NewString(), ActorId: actor.ID, Actor: actor}
+	store.db.Create(account)
+	return account
+}
+
+func TestLiveLobbyService_resolveRole(t *testing.T) {
+	t.Run("video owner is host", func(t *testing.T) {
+		service, stream, store := testLiveLobbyServiceSetup(t)
+		var owner auth.Account
+		store.db.Where("actor_id=?", stream.Video.OwnerId).First(&owner)
+
+		role, err := service.resolveRole(context.Background(), stream, uuid.MustParse(owner.UUID))
+		assert.NoError(t, err)
+		assert.Equal(t, sessions.RoleHost, role)
+	})
+
+	t.Run("stream account is host", func(t *testing.T) {
+		service, stream, store := testLiveLobbyServiceSetup(t)
+		stream.Account = testAccount(t, store, "creator")
+
+		role, err := service.resolveRole(context.Background(), stream, uuid.MustParse(stream.Account.UUID))
+		assert.NoError(t, err)
+		assert.Equal(t, sessions.RoleHost, role)
+	})
+
+	t.Run("video guest is guest", func(t *testing.T) {
+		service, stream, store := testLiveLobbyServiceSetup(t)
+		guest := testAccount(t, store, "guest")
+		_ = store.db.Model(stream.Video).Association("Guests").Append(guest.Actor)
+
+		role, err := service.resolveRole(context.Background(), stream, uuid.MustParse(guest.UUID))
+		assert.NoError(t, err)
+		assert.Equal(t, sessions.RoleGuest, role)
+	})
+
+	t.Run("other users are viewers", func(t *testing.T) {
+		service, stream, store := testLiveLobbyServiceSetup(t)
+		viewer := testAccount(t, store, "viewer")
+
+		role, err := service.resolveRole(context.Background(), stream, uuid.MustParse(viewer.UUID))
+		assert.NoError(t, err)
+		assert.Equal(t, sessions.RoleViewer, role)
+
+		role, err = service.resolveRole(context.Background(), stream, uuid.New())
+		assert.NoError(t, err)
+		assert.Equal(t, sessions.RoleViewer, role)
+	})
+}
+
+func TestLiveLobbyService_checkInstance(t *testing.T) {
+	t.Run("instance account relays lobby", func(t *testing.T) {
+		service, _, store := testLiveLobbyServiceSetup(t)
+		instance := testAccount(t, store, "instance")
+		instance.Actor.ActorType = models.Application.String()
+		store.db.Save(instance.Actor)
+
+		assert.NoError(t, service.checkInstance(context.Background(), uuid.MustParse(instance.UUID)))
+	})
+
+	t.Run("users do not relay lobby", func(t *testing.T) {
+		service, _, store := testLiveLobbyServiceSetup(t)
+		user := testAccount(t, store, "user")
+
+		assert.ErrorIs(t, service.checkInstance(context.Background(), uuid.MustParse(user.UUID)), ErrNoInstance)
+		assert.ErrorIs(t, service.checkInstance(context.Background(), uuid.New()), ErrNoInstance)
+	})
+}
+
+// testRecordingLobbyManager answers the live stream and recording calls, all other calls of the lobby manager are not expected
+type testRecordingLobbyManager struct {
+	liveLobbyManager
+	recordingErr   error
+	liveStreaming  bool
+	recordingCalls int
+}
+
+func (m *testRecordingLobbyManager) StartLiveStream(_ context.Context, _ uuid.UUID, _ string, _ string, _ uuid.UUID) error {
+	m.liveStreaming = true
+	return nil
+}
+
+func (m *testRecordingLobbyManager) StopLiveStream(_ context.Context, _ uuid.UUID, _ uuid.UUID) error {
+	m.liveStreaming = false
+	return nil
+}
+
+func (m *testRecordingLobbyManager) StartRecording(_ context.Context, _ uuid.UUID, _ uuid.UUID) error {
+	m.recordingCalls++
+	return m.recordingErr
+}
+
+func (m *testRecordingLobbyManager) StopRecording(_ context.Context, _ uuid.UUID, _ uuid.UUID) error {
+	m.recordingCalls++
+	return m.recordingErr
+}
+
+func testLiveStreamRecordingSetup(t *testing.T, recordingErr error) (*LiveLobbyService, *LiveStream, *testRecordingLobbyManager) {
+	t.Helper()
+	service, stream, _ := testLiveLobbyServiceSetup(t)
+	manager := &testRecordingLobbyManager{recordingErr: recordingErr}
+	service.lobbyManager = manager
+	stream.Lobby = lobby.NewLobbyEntity(uuid.New(), "space", "http://localhost:1234/federation/accounts/shig-test")
+	stream.Video.LiveSaveReplay = true
+	return service, stream, manager
+}
+
+func TestLiveLobbyService_LiveStreamRecording(t *testing.T) {
+	info := &LiveStreamInfo{StreamKey: "key", RtmpUrl: "rtmp://localhost/live"}
+
+	t.Run("start and stop live stream with recording", func(t *testing.T) {
+		service, stream, manager := testLiveStreamRecordingSetup(t, nil)
+
+		assert.NoError(t, service.StartLiveStream(context.Background(), stream, info, uuid.New()))
+		assert.True(t, manager.liveStreaming)
+		assert.NoError(t, service.StopLiveStream(context.Background(), stream, uuid.New()))
+		assert.False(t, manager.liveStreaming)
+		assert.Equal(t, 2, manager.recordingCalls)
+	})
+
+	t.Run("start and stop live stream while recording is disabled", func(t *testing.T) {
+		service, stream, manager := testLiveStreamRecordingSetup(t, lobby.ErrRecordingDisabled)
+
+		assert.NoError(t, service.StartLiveStream(context.Background(), stream, info, uuid.New()))
+		assert.True(t, manager.liveStreaming)
+		assert.NoError(t, service.StopLiveStream(context.Background(), stream, uuid.New()))
+		assert.False(t, manager.liveStreaming)
+	})
+
+	t.Run("stop live stream that is not recorded", func(t *testing.T) {
+		service, stream, manager := testLiveStreamRecordingSetup(t, lobby.ErrNotRecording)
+		manager.liveStreaming = true
+
+		assert.NoError(t, service.StopLiveStream(context.Background(), stream, uuid.New()))
+		assert.False(t, manager.liveStreaming)
+	})
+
+	t.Run("fail on other recording errors", func(t *testing.T) {
+		service, stream, _ := testLiveStreamRecordingSetup(t, lobby.ErrLobbyNotRunning)
+
+		assert.ErrorIs(t, service.StartLiveStream(context.Background(), stream, info, uuid.New()), lobby.ErrLobbyNotRunning)
+	})
+}
+
+// testLiveStreamAnnouncer remembers the published live states
+type testLiveStreamAnnouncer struct {
+	states []bool
+	err    error
+}
+
+func (a *testLiveStreamAnnouncer) PublishLiveState(_ context.Context, _ string, live bool) error {
+	a.states = append(a.states, live)
+	return a.err
+}
+
+func TestLiveLobbyService_PublishLiveState(t *testing.T) {
+	info := &LiveStreamInfo{StreamKey: "key", RtmpUrl: "rtmp://localhost/live"}
+
+	t.Run("announce start and stop of live stream", func(t *testing.T) {
+		service, stream, _ := testLiveStreamRecordingSetup(t, nil)
+		announcer := &testLiveStreamAnnouncer{}
+		service.announcer = announcer
+
+		assert.NoError(t, service.StartLiveStream(context.Background(), stream, info, uuid.New()))
+		assert.NoError(t, service.StopLiveStream(context.Background(), stream, uuid.New()))
+		assert.Equal(t, []bool{true, false}, announcer.states)
+	})
+
+	t.Run("live stream runs even if announcing fails", func(t *testing.T) {
+		service, stream, manager := testLiveStreamRecordingSetup(t, nil)
+		announcer := &testLiveStreamAnnouncer{err: errors.New("followers not reachable")}
+		service.announcer = announcer
+
+		assert.NoError(t, service.StartLiveStream(context.Background(), stream, info, uuid.New()))
+		assert.True(t, manager.liveStreaming)
+		assert.Equal(t, []bool{true}, announcer.states)
+	})
+
+	t.Run("do not announce live stream that failed to start", func(t *testing.T) {
+		service, stream, _ := testLiveStreamRecordingSetup(t, lobby.ErrLobbyNotRunning)
+		announcer := &testLiveStreamAnnouncer{}
+		service.announcer = announcer
+
+		assert.Error(t, service.StartLiveStream(context.Background(), stream, info, uuid.New()))
+		assert.Empty(t, announcer.states)
+	})
+}
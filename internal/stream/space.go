@@ -9,6 +9,7 @@ import (
 )
 
 var ErrLobbyNotActive = errors.New("lobby not active")
+var ErrNoInstance = errors.New("user is not a shig instance")
 
 type Space struct {
 	Identifier string        `gorm:"not null;unique;"`
@@ -0,0 +1,80 @@
+package turn
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// TurnConfig configures the embedded TURN server, small instances then need no separate TURN server like coturn
+type TurnConfig struct {
+	Enable bool `mapstructure:"enable"`
+	// public ip of the instance, the TURN server announces its relay addresses with it
+	PublicIp string `mapstructure:"publicIp"`
+	// host of the TURN urls handed out to the clients, the public ip if empty
+	Domain string `mapstructure:"domain"`
+	Realm  string `mapstructure:"realm"`
+	// ports of the listeners, 0 disables a listener
+	UdpPort int `mapstructure:"udpPort"`
+	TcpPort int `mapstructure:"tcpPort"`
+	TlsPort int `mapstructure:"tlsPort"`
+	// certificate of the TLS listener
+	TlsCrt string `mapstructure:"tlsCrt"`
+	TlsKey string `mapstructure:"tlsKey"`
+	// shared secret of the short-lived credentials
+	Secret string `mapstructure:"secret"`
+	// seconds the credentials of a client are valid
+	CredentialTtl int `mapstructure:"credentialTtl"`
+	// port range of the relay addresses, any port if empty
+	RelayPortMin int `mapstructure:"relayPortMin"`
+	RelayPortMax int `mapstructure:"relayPortMax"`
+	// relay to loopback and private peer addresses, otherwise clients can reach only public peers and the public ip
+	AllowPrivatePeers bool `mapstructure:"allowPrivatePeers"`
+}
+
+// GetCredentialTtl returns how long the credentials of a client are valid
+func (c *TurnConfig) GetCredentialTtl() time.Duration {
+	return time.Duration(c.CredentialTtl) * time.Second
+}
+
+func (c *TurnConfig) getDomain() string {
+	if len(c.Domain) == 0 {
+		return c.PublicIp
+	}
+	return c.Domain
+}
+
+func ValidateTurnConfig(config *TurnConfig) error {
+	if !config.Enable {
+		return nil
+	}
+	if net.ParseIP(config.PublicIp) == nil {
+		return fmt.Errorf("turn.publicIp should be an ip address")
+	}
+	if len(config.Realm) == 0 {
+		return fmt.Errorf("turn.realm should not be empty")
+	}
+	if config.UdpPort == 0 && config.TcpPort == 0 && config.TlsPort == 0 {
+		return fmt.Errorf("turn.udpPort, turn.tcpPort or turn.tlsPort should be set")
+	}
+	for _, port := range []int{config.UdpPort, config.TcpPort, config.TlsPort} {
+		if port < 0 || port > 65535 {
+			return fmt.Errorf("turn.udpPort, turn.tcpPort and turn.tlsPort should be between 0 and 65535")
+		}
+	}
+	if config.TlsPort != 0 && (len(config.TlsCrt) == 0 || len(config.TlsKey) == 0) {
+		return fmt.Errorf("turn.tlsCrt and turn.tlsKey should not be empty for the tls listener")
+	}
+	if len(config.Secret) == 0 {
+		return fmt.Errorf("turn.secret should not be empty")
+	}
+	if config.CredentialTtl < 1 {
+		return fmt.Errorf("turn.credentialTtl should be greater than 0")
+	}
+	if config.RelayPortMin != 0 || config.RelayPortMax != 0 {
+		if config.RelayPortMin < 1 || config.RelayPortMax > 65535 || config.RelayPortMin > config.RelayPortMax {
+			return fmt.Errorf("turn.relayPortMin and turn.relayPortMax should be a port range between 1 and 65535")
+		}
+	}
+	return nil
+}
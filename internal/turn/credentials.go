@@ -0,0 +1,53 @@
+package turn
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	pionTurn "github.com/pion/turn/v2"
+	"github.com/shigde/sfu/internal/rtp"
+	"golang.org/x/exp/slog"
+)
+
+// GetICEServer describes the embedded TURN server as ICE server with a shared secret,
+// so the clients get short-lived credentials of it like of every other TURN server with a shared secret
+func (c *TurnConfig) GetICEServer() rtp.ICEServer {
+	domain := c.getDomain()
+	urls := make([]string, 0)
+	if c.UdpPort != 0 {
+		urls = append(urls, fmt.Sprintf("stun:%s:%d", domain, c.UdpPort))
+		urls = append(urls, fmt.Sprintf("turn:%s:%d?transport=udp", domain, c.UdpPort))
+	}
+	if c.TcpPort != 0 {
+		urls = append(urls, fmt.Sprintf("turn:%s:%d?transport=tcp", domain, c.TcpPort))
+	}
+	if c.TlsPort != 0 {
+		urls = append(urls, fmt.Sprintf("turns:%s:%d?transport=tcp", domain, c.TlsPort))
+	}
+	return rtp.ICEServer{
+		Urls:          urls,
+		Realm:         c.Realm,
+		Secret:        c.Secret,
+		CredentialTtl: c.CredentialTtl,
+	}
+}
+
+// newAuthHandler accepts the credentials created with the shared secret, as long as they are not expired
+func newAuthHandler(secret string) pionTurn.AuthHandler {
+	return func(username string, realm string, srcAddr net.Addr) ([]byte, bool) {
+		expiry, _, found := strings.Cut(username, ":")
+		if !found {
+			slog.Debug("turn: invalid username", "username", username, "srcAddr", srcAddr)
+			return nil, false
+		}
+		expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+		if err != nil || expiresAt < time.Now().Unix() {
+			slog.Debug("turn: expired username", "username", username, "srcAddr", srcAddr)
+			return nil, false
+		}
+		return pionTurn.GenerateAuthKey(username, realm, rtp.TurnPassword(secret, username)), true
+	}
+}
@@ -0,0 +1,62 @@
+package turn
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	pionTurn "github.com/pion/turn/v2"
+	"github.com/shigde/sfu/internal/rtp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthHandler(t *testing.T) {
+	srcAddr := &net.UDPAddr{IP: net.ParseIP("192.0.2.2"), Port: 50000}
+
+	t.Run("accept valid credentials", func(t *testing.T) {
+		username, password, _ := rtp.NewTurnCredentials("secret", "user", time.Hour)
+		key, ok := newAuthHandler("secret")(username, "shig", srcAddr)
+		assert.True(t, ok)
+		assert.Equal(t, pionTurn.GenerateAuthKey(username, "shig", password), key)
+	})
+
+	// the username of another secret is valid, but the key does not match the password, so the message integrity check fails
+	t.Run("derive another key for credentials of another secret", func(t *testing.T) {
+		username, password, _ := rtp.NewTurnCredentials("other", "user", time.Hour)
+		key, ok := newAuthHandler("secret")(username, "shig", srcAddr)
+		assert.True(t, ok)
+		assert.NotEqual(t, pionTurn.GenerateAuthKey(username, "shig", password), key)
+	})
+
+	t.Run("reject expired credentials", func(t *testing.T) {
+		username, _, _ := rtp.NewTurnCredentials("secret", "user", -time.Minute)
+		_, ok := newAuthHandler("secret")(username, "shig", srcAddr)
+		assert.False(t, ok)
+	})
+
+	t.Run("reject usernames without expiry", func(t *testing.T) {
+		_, ok := newAuthHandler("secret")("user", "shig", srcAddr)
+		assert.False(t, ok)
+	})
+}
+
+func TestTurnConfig_GetICEServer(t *testing.T) {
+	config := &TurnConfig{Enable: true, PublicIp: "192.0.2.1", Domain: "turn.localhost", Realm: "shig", UdpPort: 3478, TlsPort: 5349, Secret: "secret", CredentialTtl: 3600}
+	srcAddr := &net.UDPAddr{IP: net.ParseIP("192.0.2.2"), Port: 50000}
+
+	t.Run("describe the listeners with the shared secret", func(t *testing.T) {
+		iceServer := config.GetICEServer()
+		assert.Equal(t, []string{"stun:turn.localhost:3478", "turn:turn.localhost:3478?transport=udp", "turns:turn.localhost:5349?transport=tcp"}, iceServer.Urls)
+		assert.Equal(t, "shig", iceServer.Realm)
+		assert.Equal(t, "secret", iceServer.Secret)
+		assert.Empty(t, iceServer.Username)
+	})
+
+	t.Run("accept the credentials handed out to the clients", func(t *testing.T) {
+		rtpConfig := &rtp.RtpConfig{ICEServer: []rtp.ICEServer{config.GetICEServer()}}
+		iceServer := rtpConfig.GetICEServerFor("user")[0]
+		key, ok := newAuthHandler(config.Secret)(iceServer.Username, config.Realm, srcAddr)
+		assert.True(t, ok)
+		assert.Equal(t, pionTurn.GenerateAuthKey(iceServer.Username, config.Realm, iceServer.Credential), key)
+	})
+}
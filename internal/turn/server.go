@@ -0,0 +1,129 @@
+package turn
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+
+	pionTurn "github.com/pion/turn/v2"
+	"golang.org/x/exp/slog"
+)
+
+// Server is the embedded TURN server with UDP, TCP and TLS listeners.
+// It accepts the short-lived credentials of rtp.NewTurnCredentials only.
+type Server struct {
+	server *pionTurn.Server
+}
+
+func NewServer(config *TurnConfig) (*Server, error) {
+	listeners := make([]io.Closer, 0)
+	closeListeners := func() {
+		for _, listener := range listeners {
+			_ = listener.Close()
+		}
+	}
+
+	serverConfig := pionTurn.ServerConfig{
+		Realm:       config.Realm,
+		AuthHandler: newAuthHandler(config.Secret),
+	}
+
+	if config.UdpPort != 0 {
+		udpListener, err := net.ListenPacket("udp4", fmt.Sprintf("0.0.0.0:%d", config.UdpPort))
+		if err != nil {
+			return nil, fmt.Errorf("listening turn udp: %w", err)
+		}
+		listeners = append(listeners, udpListener)
+		serverConfig.PacketConnConfigs = append(serverConfig.PacketConnConfigs, pionTurn.PacketConnConfig{
+			PacketConn:            udpListener,
+			RelayAddressGenerator: newRelayAddressGenerator(config),
+			PermissionHandler:     newPermissionHandler(config),
+		})
+	}
+
+	if config.TcpPort != 0 {
+		tcpListener, err := net.Listen("tcp4", fmt.Sprintf("0.0.0.0:%d", config.TcpPort))
+		if err != nil {
+			closeListeners()
+			return nil, fmt.Errorf("listening turn tcp: %w", err)
+		}
+		listeners = append(listeners, tcpListener)
+		serverConfig.ListenerConfigs = append(serverConfig.ListenerConfigs, pionTurn.ListenerConfig{
+			Listener:              tcpListener,
+			RelayAddressGenerator: newRelayAddressGenerator(config),
+			PermissionHandler:     newPermissionHandler(config),
+		})
+	}
+
+	if config.TlsPort != 0 {
+		cert, err := tls.LoadX509KeyPair(config.TlsCrt, config.TlsKey)
+		if err != nil {
+			closeListeners()
+			return nil, fmt.Errorf("loading turn tls certificate: %w", err)
+		}
+		tlsListener, err := tls.Listen("tcp4", fmt.Sprintf("0.0.0.0:%d", config.TlsPort), &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{cert},
+		})
+		if err != nil {
+			closeListeners()
+			return nil, fmt.Errorf("listening turn tls: %w", err)
+		}
+		listeners = append(listeners, tlsListener)
+		serverConfig.ListenerConfigs = append(serverConfig.ListenerConfigs, pionTurn.ListenerConfig{
+			Listener:              tlsListener,
+			RelayAddressGenerator: newRelayAddressGenerator(config),
+			PermissionHandler:     newPermissionHandler(config),
+		})
+	}
+
+	server, err := pionTurn.NewServer(serverConfig)
+	if err != nil {
+		closeListeners()
+		return nil, fmt.Errorf("creating turn server: %w", err)
+	}
+	slog.Info("turn: server started", "publicIp", config.PublicIp, "udpPort", config.UdpPort, "tcpPort", config.TcpPort, "tlsPort", config.TlsPort)
+	return &Server{server: server}, nil
+}
+
+// newRelayAddressGenerator announces the relay addresses with the public ip, in the port range if configured
+func newRelayAddressGenerator(config *TurnConfig) pionTurn.RelayAddressGenerator {
+	if config.RelayPortMin != 0 {
+		return &pionTurn.RelayAddressGeneratorPortRange{
+			RelayAddress: net.ParseIP(config.PublicIp),
+			Address:      "0.0.0.0",
+			MinPort:      uint16(config.RelayPortMin),
+			MaxPort:      uint16(config.RelayPortMax),
+		}
+	}
+	return &pionTurn.RelayAddressGeneratorStatic{
+		RelayAddress: net.ParseIP(config.PublicIp),
+		Address:      "0.0.0.0",
+	}
+}
+
+// newPermissionHandler keeps clients from relaying to the loopback and private networks of the instance,
+// the public ip of the instance is always a permitted peer
+func newPermissionHandler(config *TurnConfig) pionTurn.PermissionHandler {
+	publicIp := net.ParseIP(config.PublicIp)
+	return func(clientAddr net.Addr, peerIP net.IP) bool {
+		if config.AllowPrivatePeers || peerIP.Equal(publicIp) {
+			return true
+		}
+		if peerIP.IsLoopback() || peerIP.IsPrivate() || peerIP.IsUnspecified() ||
+			peerIP.IsLinkLocalUnicast() || peerIP.IsLinkLocalMulticast() || peerIP.IsMulticast() {
+			slog.Debug("turn: reject private peer", "peer", peerIP, "clientAddr", clientAddr)
+			return false
+		}
+		return true
+	}
+}
+
+// Close stops the listeners and the allocations of the server
+func (s *Server) Close() error {
+	if err := s.server.Close(); err != nil {
+		return fmt.Errorf("closing turn server: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package turn
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPermissionHandler(t *testing.T) {
+	clientAddr := &net.UDPAddr{IP: net.ParseIP("192.0.2.2"), Port: 50000}
+
+	t.Run("permit public peers and the public ip", func(t *testing.T) {
+		handler := newPermissionHandler(&TurnConfig{PublicIp: "10.0.0.1"})
+		assert.True(t, handler(clientAddr, net.ParseIP("203.0.113.7")))
+		assert.True(t, handler(clientAddr, net.ParseIP("10.0.0.1")))
+	})
+
+	t.Run("reject loopback and private peers", func(t *testing.T) {
+		handler := newPermissionHandler(&TurnConfig{PublicIp: "203.0.113.1"})
+		for _, peer := range []string{"127.0.0.1", "10.0.0.2", "172.16.0.1", "192.168.1.1", "169.254.169.254", "0.0.0.0", "::1", "fd00::1"} {
+			assert.False(t, handler(clientAddr, net.ParseIP(peer)), peer)
+		}
+	})
+
+	t.Run("permit private peers if allowed", func(t *testing.T) {
+		handler := newPermissionHandler(&TurnConfig{PublicIp: "203.0.113.1", AllowPrivatePeers: true})
+		assert.True(t, handler(clientAddr, net.ParseIP("192.168.1.1")))
+	})
+}
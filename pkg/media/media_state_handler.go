@@ -6,15 +6,21 @@ import (
 )
 
 type MediaStateHandler struct {
-	messenger *Messenger
-	quit      chan struct{}
+	messenger  *Messenger
+	negotiator *Negotiator
+	quit       chan struct{}
 }
 
-func NewMediaStateEventHandler(ms *Messenger) *MediaStateHandler {
-	return &MediaStateHandler{messenger: ms, quit: make(chan struct{})}
+// NewMediaStateEventHandler creates the handler of the sending connection, without a negotiator the handler does not restart ICE
+func NewMediaStateEventHandler(ms *Messenger, negotiator *Negotiator) *MediaStateHandler {
+	return &MediaStateHandler{messenger: ms, negotiator: negotiator, quit: make(chan struct{})}
 }
 
 func (h *MediaStateHandler) OnConnectionStateChange(state webrtc.ICEConnectionState) {
+	slog.Debug("messenger: connection state changed", "state", state)
+	if h.negotiator != nil {
+		h.negotiator.OnSenderStateChange(state)
+	}
 }
 func (h *MediaStateHandler) OnNegotiationNeeded(offer webrtc.SessionDescription) {}
 func (h *MediaStateHandler) OnChannel(dc *webrtc.DataChannel) {
@@ -46,6 +46,18 @@ func (m *Messenger) notifyAll(msg *message.ChannelMsg) {
 		m.handleOfferMsg(msg)
 	case message.MuteMsg:
 		m.handleMuteMsg(msg)
+	case message.ChatMsg:
+		m.handleChatMsg(msg)
+	case message.ReactionMsg:
+		m.handleReactionMsg(msg)
+	case message.RaiseHandMsg:
+		m.handleRaiseHandMsg(msg)
+	case message.HistoryMsg:
+		m.handleHistoryMsg(msg)
+	case message.ModerationMsg:
+		m.handleModerationMsg(msg)
+	case message.RoleMsg:
+		m.handleRoleMsg(msg)
 	default:
 		slog.Error("messenger: unknown msg type", "err", fmt.Sprintf("unknown msg type: %d", msg.Type))
 	}
@@ -87,6 +99,114 @@ func (m *Messenger) handleMuteMsg(msg *message.ChannelMsg) {
 	}
 }
 
+func (m *Messenger) handleChatMsg(msg *message.ChannelMsg) {
+	jsonStr, err := json.Marshal(msg.Data)
+	if err != nil {
+		slog.Error("messenger: handleChatMsg", "err", err)
+		return
+	}
+	chat, err := message.ChatUnmarshal(jsonStr)
+	if err != nil {
+		slog.Error("messenger: handleChatMsg", "err", err)
+		return
+	}
+	m.locker.RLock()
+	defer m.locker.RUnlock()
+	for _, observer := range m.observerList {
+		observer.OnChat(chat)
+	}
+}
+
+func (m *Messenger) handleReactionMsg(msg *message.ChannelMsg) {
+	jsonStr, err := json.Marshal(msg.Data)
+	if err != nil {
+		slog.Error("messenger: handleReactionMsg", "err", err)
+		return
+	}
+	reaction, err := message.ReactionUnmarshal(jsonStr)
+	if err != nil {
+		slog.Error("messenger: handleReactionMsg", "err", err)
+		return
+	}
+	m.locker.RLock()
+	defer m.locker.RUnlock()
+	for _, observer := range m.observerList {
+		observer.OnReaction(reaction)
+	}
+}
+
+func (m *Messenger) handleRaiseHandMsg(msg *message.ChannelMsg) {
+	jsonStr, err := json.Marshal(msg.Data)
+	if err != nil {
+		slog.Error("messenger: handleRaiseHandMsg", "err", err)
+		return
+	}
+	raiseHand, err := message.RaiseHandUnmarshal(jsonStr)
+	if err != nil {
+		slog.Error("messenger: handleRaiseHandMsg", "err", err)
+		return
+	}
+	m.locker.RLock()
+	defer m.locker.RUnlock()
+	for _, observer := range m.observerList {
+		observer.OnRaiseHand(raiseHand)
+	}
+}
+
+func (m *Messenger) handleHistoryMsg(msg *message.ChannelMsg) {
+	jsonStr, err := json.Marshal(msg.Data)
+	if err != nil {
+		slog.Error("messenger: handleHistoryMsg", "err", err)
+		return
+	}
+	history, err := message.HistoryUnmarshal(jsonStr)
+	if err != nil {
+		slog.Error("messenger: handleHistoryMsg", "err", err)
+		return
+	}
+	m.locker.RLock()
+	defer m.locker.RUnlock()
+	for _, observer := range m.observerList {
+		observer.OnHistory(history)
+	}
+}
+
+func (m *Messenger) handleModerationMsg(msg *message.ChannelMsg) {
+	jsonStr, err := json.Marshal(msg.Data)
+	if err != nil {
+		slog.Error("messenger: handleModerationMsg", "err", err)
+		return
+	}
+	moderation, err := message.ModerationUnmarshal(jsonStr)
+	if err != nil {
+		slog.Error("messenger: handleModerationMsg", "err", err)
+		return
+	}
+	m.locker.RLock()
+	defer m.locker.RUnlock()
+	for _, observer := range m.observerList {
+		observer.OnModeration(moderation)
+	}
+}
+
+func (m *Messenger) handleRoleMsg(msg *message.ChannelMsg) {
+	jsonStr, err := json.Marshal(msg.Data)
+	if err != nil {
+		slog.Error("messenger: handleRoleMsg", "err", err)
+		return
+	}
+	role, err := message.RoleUnmarshal(jsonStr)
+	if err != nil {
+		slog.Error("messenger: handleRoleMsg", "err", err)
+		return
+	}
+	m.locker.RLock()
+	defer m.locker.RUnlock()
+	for _, observer := range m.observerList {
+		observer.OnRole(role)
+	}
+}
+
 func (m *Messenger) SendSDP(sdp *webrtc.SessionDescription, id uint32, number uint32) (uint32, error) {
 	sdpMsg := &message.Sdp{
 		SDP:    sdp,
@@ -151,6 +271,106 @@ func (m *Messenger) SendMute(mute *message.Mute) error {
 	return nil
 }
 
+func (m *Messenger) SendChat(chat *message.Chat) error {
+	channelMsg := &message.ChannelMsg{
+		Id:   0,
+		Type: message.ChatMsg,
+		Data: chat,
+	}
+
+	byteMsg, err := message.Marshal(channelMsg)
+	if err != nil {
+		return fmt.Errorf("marshaling chat message: %w", err)
+	}
+
+	select {
+	case <-m.quit:
+	default:
+		select {
+		case m.QueueChan <- byteMsg:
+			slog.Debug("lobby.messenger: chat is send")
+		case <-m.quit:
+		}
+	}
+
+	return nil
+}
+
+func (m *Messenger) SendReaction(reaction *message.Reaction) error {
+	channelMsg := &message.ChannelMsg{
+		Id:   0,
+		Type: message.ReactionMsg,
+		Data: reaction,
+	}
+
+	byteMsg, err := message.Marshal(channelMsg)
+	if err != nil {
+		return fmt.Errorf("marshaling reaction message: %w", err)
+	}
+
+	select {
+	case <-m.quit:
+	default:
+		select {
+		case m.QueueChan <- byteMsg:
+			slog.Debug("lobby.messenger: reaction is send")
+		case <-m.quit:
+		}
+	}
+
+	return nil
+}
+
+func (m *Messenger) SendRaiseHand(raiseHand *message.RaiseHand) error {
+	channelMsg := &message.ChannelMsg{
+		Id:   0,
+		Type: message.RaiseHandMsg,
+		Data: raiseHand,
+	}
+
+	byteMsg, err := message.Marshal(channelMsg)
+	if err != nil {
+		return fmt.Errorf("marshaling raise hand message: %w", err)
+	}
+
+	select {
+	case <-m.quit:
+	default:
+		select {
+		case m.QueueChan <- byteMsg:
+			slog.Debug("lobby.messenger: raise hand is send")
+		case <-m.quit:
+		}
+	}
+
+	return nil
+}
+
+func (m *Messenger) SendModeration(moderation *message.Moderation) error {
+	channelMsg := &message.ChannelMsg{
+		Id:   0,
+		Type: message.ModerationMsg,
+		Data: moderation,
+	}
+
+	byteMsg, err := message.Marshal(channelMsg)
+	if err != nil {
+		return fmt.Errorf("marshaling moderation message: %w", err)
+	}
+
+	select {
+	case <-m.quit:
+	default:
+		select {
+		case m.QueueChan <- byteMsg:
+			slog.Debug("lobby.messenger: moderation is send")
+		case <-m.quit:
+		}
+	}
+
+	return nil
+}
+
 func (m *Messenger) Register(o msgObserver) {
 	m.locker.Lock()
 	defer m.locker.Unlock()
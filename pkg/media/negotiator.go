@@ -0,0 +1,163 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pion/webrtc/v3"
+	"github.com/shigde/sfu/pkg/message"
+	"golang.org/x/exp/slog"
+)
+
+var errNoConnection = errors.New("no connection to negotiate")
+var errGatheringTimeout = errors.New("timeout by waiting for ice gathering")
+var gatheringTimeout = 10 * time.Second
+
+// Negotiator handles the offer answer exchange of the client over the data channel.
+// The server renegotiates the receiving connection and restarts its ICE, when the connection is disconnected.
+// The Negotiator answers these offers and restarts ICE of the sending connection itself, when it is disconnected.
+// So a network switch, like from Wi-Fi to LTE, does not need a new session.
+type Negotiator struct {
+	id          uuid.UUID
+	messenger   *Messenger
+	locker      sync.RWMutex
+	sender      *webrtc.PeerConnection
+	receiver    *webrtc.PeerConnection
+	offerNumber atomic.Uint32
+}
+
+func NewNegotiator(ms *Messenger) *Negotiator {
+	n := &Negotiator{id: uuid.New(), messenger: ms}
+	ms.Register(n)
+	return n
+}
+
+// SetSender sets the connection the client sends its media and the data channel with
+func (n *Negotiator) SetSender(pc *webrtc.PeerConnection) {
+	n.locker.Lock()
+	defer n.locker.Unlock()
+	n.sender = pc
+}
+
+// SetReceiver sets the connection the client receives the media of the lobby with
+func (n *Negotiator) SetReceiver(pc *webrtc.PeerConnection) {
+	n.locker.Lock()
+	defer n.locker.Unlock()
+	n.receiver = pc
+}
+
+// OnSenderStateChange restarts ICE of the sending connection, when it is disconnected
+func (n *Negotiator) OnSenderStateChange(state webrtc.ICEConnectionState) {
+	if state != webrtc.ICEConnectionStateDisconnected {
+		return
+	}
+	go func() {
+		if err := n.restartIce(); err != nil {
+			slog.Error("media.Negotiator: restart ice", "err", err)
+		}
+	}()
+}
+
+func (n *Negotiator) restartIce() error {
+	n.locker.RLock()
+	sender := n.sender
+	n.locker.RUnlock()
+	if sender == nil {
+		return errNoConnection
+	}
+
+	offer, err := sender.CreateOffer(&webrtc.OfferOptions{ICERestart: true})
+	if err != nil {
+		return fmt.Errorf("creating ice restart offer: %w", err)
+	}
+	gatherComplete := webrtc.GatheringCompletePromise(sender)
+	if err = sender.SetLocalDescription(offer); err != nil {
+		return fmt.Errorf("setting ice restart offer: %w", err)
+	}
+	if err = waitForGathering(gatherComplete); err != nil {
+		return err
+	}
+
+	number := n.offerNumber.Add(1)
+	slog.Info("media.Negotiator: restart ice", "number", number)
+	if _, err = n.messenger.SendSDP(sender.LocalDescription(), 0, number); err != nil {
+		return fmt.Errorf("sending ice restart offer: %w", err)
+	}
+	return nil
+}
+
+// OnOffer answers the offers of the server for the receiving connection, the offers can restart ICE
+func (n *Negotiator) OnOffer(sdp *webrtc.SessionDescription, responseId uint32, responseMsgNumber uint32) {
+	n.locker.RLock()
+	receiver := n.receiver
+	n.locker.RUnlock()
+	if receiver == nil {
+		slog.Warn("media.Negotiator: no receiving connection to answer offer", "number", responseMsgNumber)
+		return
+	}
+
+	if err := receiver.SetRemoteDescription(*sdp); err != nil {
+		slog.Error("media.Negotiator: set offer", "err", err, "number", responseMsgNumber)
+		return
+	}
+	// new ICE credentials in the offer restart the ICE gathering
+	gatherComplete := webrtc.GatheringCompletePromise(receiver)
+	answer, err := receiver.CreateAnswer(nil)
+	if err != nil {
+		slog.Error("media.Negotiator: create answer", "err", err, "number", responseMsgNumber)
+		return
+	}
+	if err = receiver.SetLocalDescription(answer); err != nil {
+		slog.Error("media.Negotiator: set answer", "err", err, "number", responseMsgNumber)
+		return
+	}
+	if err = waitForGathering(gatherComplete); err != nil {
+		slog.Error("media.Negotiator: answer offer", "err", err, "number", responseMsgNumber)
+		return
+	}
+
+	if _, err = n.messenger.SendSDP(receiver.LocalDescription(), responseId, responseMsgNumber); err != nil {
+		slog.Error("media.Negotiator: send answer", "err", err, "number", responseMsgNumber)
+	}
+}
+
+// OnAnswer sets the answer of the server to the last ice restart offer of the sending connection
+func (n *Negotiator) OnAnswer(sdp *webrtc.SessionDescription, _ uint32, responseMsgNumber uint32) {
+	n.locker.RLock()
+	sender := n.sender
+	n.locker.RUnlock()
+	if sender == nil {
+		return
+	}
+
+	// ignore if offer outdated
+	if current := n.offerNumber.Load(); current != responseMsgNumber {
+		slog.Debug("media.Negotiator: ignore outdated answer", "number", responseMsgNumber, "currentNumber", current)
+		return
+	}
+	if err := sender.SetRemoteDescription(*sdp); err != nil {
+		slog.Error("media.Negotiator: set answer", "err", err, "number", responseMsgNumber)
+	}
+}
+
+func (n *Negotiator) OnMute(_ *message.Mute)             {}
+func (n *Negotiator) OnChat(_ *message.Chat)             {}
+func (n *Negotiator) OnReaction(_ *message.Reaction)     {}
+func (n *Negotiator) OnRaiseHand(_ *message.RaiseHand)   {}
+func (n *Negotiator) OnHistory(_ *message.History)       {}
+func (n *Negotiator) OnModeration(_ *message.Moderation) {}
+func (n *Negotiator) OnRole(_ *message.Role)             {}
+func (n *Negotiator) GetId() uuid.UUID                   { return n.id }
+
+func waitForGathering(gatherComplete <-chan struct{}) error {
+	select {
+	case <-gatherComplete:
+		return nil
+	case <-time.After(gatheringTimeout):
+		return errGatheringTimeout
+	}
+}
@@ -11,5 +11,14 @@ type msgObserver interface {
 	OnOffer(sdp *webrtc.SessionDescription, responseId uint32, responseMsgNumber uint32)
 	OnAnswer(sdp *webrtc.SessionDescription, responseId uint32, responseMsgNumber uint32)
 	OnMute(mute *message.Mute)
+	OnChat(chat *message.Chat)
+	OnReaction(reaction *message.Reaction)
+	OnRaiseHand(raiseHand *message.RaiseHand)
+	// OnHistory is called once after connecting, with the chat messages and raised hands before the connection
+	OnHistory(history *message.History)
+	// OnModeration is called when a moderator muted or removed this client
+	OnModeration(moderation *message.Moderation)
+	// OnRole is called after connecting and whenever the role of this client in the lobby changes
+	OnRole(role *message.Role)
 	GetId() uuid.UUID
 }
@@ -15,6 +15,17 @@ const (
 	OfferMsg MsgType = iota + 1
 	AnswerMsg
 	MuteMsg
+	DominantSpeakerMsg
+	LastNMsg
+	ForwardingMsg
+	ChatMsg
+	ReactionMsg
+	RaiseHandMsg
+	HistoryMsg
+	ModerationMsg
+	RoleMsg
+	RelayTracksMsg
+	RelaySubscriptionMsg
 )
 
 func Unmarshal(rawChannelMsg []byte) (*ChannelMsg, error) {
@@ -0,0 +1,33 @@
+package message
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Chat is a text message to all sessions of a lobby.
+// Clients only send the text, the server sets the other fields before it fans the message out.
+type Chat struct {
+	Id        string    `json:"id"`
+	SessionId string    `json:"sessionId"`
+	User      string    `json:"user"`
+	Role      string    `json:"role,omitempty"`
+	Text      string    `json:"text"`
+	Time      time.Time `json:"time"`
+}
+
+func ChatUnmarshal(data []byte) (*Chat, error) {
+	var newChat Chat
+	if err := json.Unmarshal(data, &newChat); err != nil {
+		return nil, err
+	}
+	return &newChat, nil
+}
+
+func ChatMarshal(chatObj *Chat) ([]byte, error) {
+	data, err := json.Marshal(chatObj)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
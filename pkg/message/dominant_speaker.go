@@ -0,0 +1,26 @@
+package message
+
+import "encoding/json"
+
+// DominantSpeaker names the session that speaks at the moment.
+// The Mids are the media ids of the tracks of the speaker in the egress connection of the receiver.
+type DominantSpeaker struct {
+	SessionId string   `json:"sessionId"`
+	Mids      []string `json:"mids"`
+}
+
+func DominantSpeakerUnmarshal(data []byte) (*DominantSpeaker, error) {
+	var newSpeaker DominantSpeaker
+	if err := json.Unmarshal(data, &newSpeaker); err != nil {
+		return nil, err
+	}
+	return &newSpeaker, nil
+}
+
+func DominantSpeakerMarshal(speakerObj *DominantSpeaker) ([]byte, error) {
+	data, err := json.Marshal(speakerObj)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,25 @@
+package message
+
+import "encoding/json"
+
+// History is sent to a session when it connects, with the latest chat messages and the raised hands of the lobby
+type History struct {
+	Chats       []Chat      `json:"chats"`
+	RaisedHands []RaiseHand `json:"raisedHands"`
+}
+
+func HistoryUnmarshal(data []byte) (*History, error) {
+	var newHistory History
+	if err := json.Unmarshal(data, &newHistory); err != nil {
+		return nil, err
+	}
+	return &newHistory, nil
+}
+
+func HistoryMarshal(historyObj *History) ([]byte, error) {
+	data, err := json.Marshal(historyObj)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,37 @@
+package message
+
+import "encoding/json"
+
+// LastN limits the guest videos a client receives to the N most recently active speakers plus the pinned sessions.
+// Without N the last-N setting of the lobby is used, N = 0 means all guest videos.
+type LastN struct {
+	N      *int     `json:"n,omitempty"`
+	Pinned []string `json:"pinned"`
+}
+
+// Forwarding lists the guest videos of a client after tracks were swapped in the egress connection without renegotiation
+type Forwarding struct {
+	Videos []ForwardedVideo `json:"videos"`
+}
+
+type ForwardedVideo struct {
+	Mid       string `json:"mid"`
+	SessionId string `json:"sessionId"`
+	Info      string `json:"info"`
+}
+
+func LastNUnmarshal(data []byte) (*LastN, error) {
+	var newLastN LastN
+	if err := json.Unmarshal(data, &newLastN); err != nil {
+		return nil, err
+	}
+	return &newLastN, nil
+}
+
+func LastNMarshal(lastNObj *LastN) ([]byte, error) {
+	data, err := json.Marshal(lastNObj)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
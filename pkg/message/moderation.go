@@ -0,0 +1,38 @@
+package message
+
+import "encoding/json"
+
+type ModerationAction string
+
+const (
+	ModerationMute   ModerationAction = "mute"
+	ModerationUnmute ModerationAction = "unmute"
+	ModerationKick   ModerationAction = "kick"
+	ModerationBan    ModerationAction = "ban"
+)
+
+// Moderation is a command of a moderator for a session of the lobby.
+// The moderated client receives the command as well, so that it knows it was muted or removed.
+// Kind is "audio" or "video" for a mute command, an empty kind mutes all tracks.
+// A client can not unmute the tracks a moderator muted, until a moderator sends an unmute command of the tracks.
+type Moderation struct {
+	Action    ModerationAction `json:"action"`
+	SessionId string           `json:"sessionId"`
+	Kind      string           `json:"kind,omitempty"`
+}
+
+func ModerationUnmarshal(data []byte) (*Moderation, error) {
+	var newModeration Moderation
+	if err := json.Unmarshal(data, &newModeration); err != nil {
+		return nil, err
+	}
+	return &newModeration, nil
+}
+
+func ModerationMarshal(moderationObj *Moderation) ([]byte, error) {
+	data, err := json.Marshal(moderationObj)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
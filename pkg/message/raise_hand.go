@@ -0,0 +1,31 @@
+package message
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// RaiseHand raises or lowers the hand of a session
+type RaiseHand struct {
+	SessionId string    `json:"sessionId"`
+	User      string    `json:"user"`
+	Role      string    `json:"role,omitempty"`
+	Raised    bool      `json:"raised"`
+	Time      time.Time `json:"time"`
+}
+
+func RaiseHandUnmarshal(data []byte) (*RaiseHand, error) {
+	var newRaiseHand RaiseHand
+	if err := json.Unmarshal(data, &newRaiseHand); err != nil {
+		return nil, err
+	}
+	return &newRaiseHand, nil
+}
+
+func RaiseHandMarshal(raiseHandObj *RaiseHand) ([]byte, error) {
+	data, err := json.Marshal(raiseHandObj)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,31 @@
+package message
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Reaction is an emoji reaction of a session, reactions are not kept in the history
+type Reaction struct {
+	SessionId string    `json:"sessionId"`
+	User      string    `json:"user"`
+	Role      string    `json:"role,omitempty"`
+	Emoji     string    `json:"emoji"`
+	Time      time.Time `json:"time"`
+}
+
+func ReactionUnmarshal(data []byte) (*Reaction, error) {
+	var newReaction Reaction
+	if err := json.Unmarshal(data, &newReaction); err != nil {
+		return nil, err
+	}
+	return &newReaction, nil
+}
+
+func ReactionMarshal(reactionObj *Reaction) ([]byte, error) {
+	data, err := json.Marshal(reactionObj)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
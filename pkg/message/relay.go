@@ -0,0 +1,67 @@
+package message
+
+import "encoding/json"
+
+// RelayTracks announces the tracks of a lobby to a remote Shig instance. The remote instance subscribes to the tracks
+// its viewers need, all other tracks stay on this instance.
+// Relayed tracks keep the id and the session of the instance the track was published on.
+type RelayTracks struct {
+	Tracks []RelayTrack `json:"tracks"`
+}
+
+// RelayTrack is a track of the lobby. The track id is the id of the track in the connection to the remote instance,
+// it is missing for relayed tracks this instance does not receive itself. Spoken is the unix time the session last spoke.
+type RelayTrack struct {
+	Id        string   `json:"id"`
+	TrackId   string   `json:"trackId,omitempty"`
+	SessionId string   `json:"sessionId"`
+	Kind      string   `json:"kind"`
+	Purpose   string   `json:"purpose"`
+	Mute      bool     `json:"mute"`
+	Info      string   `json:"info"`
+	Layers    []string `json:"layers,omitempty"`
+	Spoken    int64    `json:"spoken,omitempty"`
+}
+
+// RelaySubscription selects the announced tracks a Shig instance forwards to the remote instance.
+// A subscription replaces the former subscription. The layer selects the simulcast layer of a video: LOW, MEDIUM or HIGH.
+type RelaySubscription struct {
+	Tracks []SubscribedTrack `json:"tracks"`
+}
+
+type SubscribedTrack struct {
+	Id    string `json:"id"`
+	Layer string `json:"layer,omitempty"`
+}
+
+func RelayTracksUnmarshal(data []byte) (*RelayTracks, error) {
+	var newRelayTracks RelayTracks
+	if err := json.Unmarshal(data, &newRelayTracks); err != nil {
+		return nil, err
+	}
+	return &newRelayTracks, nil
+}
+
+func RelayTracksMarshal(relayTracksObj *RelayTracks) ([]byte, error) {
+	data, err := json.Marshal(relayTracksObj)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func RelaySubscriptionUnmarshal(data []byte) (*RelaySubscription, error) {
+	var newRelaySubscription RelaySubscription
+	if err := json.Unmarshal(data, &newRelaySubscription); err != nil {
+		return nil, err
+	}
+	return &newRelaySubscription, nil
+}
+
+func RelaySubscriptionMarshal(relaySubscriptionObj *RelaySubscription) ([]byte, error) {
+	data, err := json.Marshal(relaySubscriptionObj)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
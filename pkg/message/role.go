@@ -0,0 +1,26 @@
+package message
+
+import "encoding/json"
+
+// Role tells a client its role in the lobby, whenever the client connects or the role changes.
+// The roles are "host", "co-host", "guest" and "viewer".
+type Role struct {
+	SessionId string `json:"sessionId"`
+	Role      string `json:"role"`
+}
+
+func RoleUnmarshal(data []byte) (*Role, error) {
+	var newRole Role
+	if err := json.Unmarshal(data, &newRole); err != nil {
+		return nil, err
+	}
+	return &newRole, nil
+}
+
+func RoleMarshal(roleObj *Role) ([]byte, error) {
+	data, err := json.Marshal(roleObj)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}